@@ -0,0 +1,34 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+// AidlStrictImportsCheck returns a file that is only created when the imports of aidlFiles resolve
+// to aidlFiles themselves, to the types of the preprocessed file, if any, or to files in
+// includeDirs.  It is used by the modules that set aidl.strict_imports.
+func AidlStrictImportsCheck(ctx ModuleContext, aidlFiles Paths, preprocessed OptionalPath,
+	includeDirs Paths) Path {
+
+	stamp := PathForModuleOut(ctx, "aidl", "strict_imports.stamp")
+	rule := NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().BuiltTool("check_aidl_imports")
+	if preprocessed.Valid() {
+		cmd.FlagWithInput("--preprocessed ", preprocessed.Path())
+	}
+	cmd.FlagForEachArg("-I ", includeDirs.Strings()).
+		FlagWithOutput("--stamp ", stamp).
+		Inputs(aidlFiles)
+	rule.Build("aidl_strict_imports", "aidl strict imports")
+	return stamp
+}
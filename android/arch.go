@@ -1107,151 +1107,180 @@ func (m *ModuleBase) setOSProperties(ctx BottomUpMutatorContext) {
 // Squash the appropriate arch-specific property structs into the matching top level property
 // structs based on the CompileTarget value that was annotated on the variant.
 func (m *ModuleBase) setArchProperties(ctx BottomUpMutatorContext) {
-	arch := m.Arch()
-	os := m.Os()
-
 	for i := range m.generalProperties {
 		genProps := m.generalProperties[i]
 		if m.archProperties[i] == nil {
 			continue
 		}
-		for _, archProperties := range m.archProperties[i] {
-			archPropValues := reflect.ValueOf(archProperties).Elem()
+		m.appendArchProperties(ctx, genProps, m.archProperties[i], m.Target())
+	}
+}
 
-			archProp := archPropValues.FieldByName("Arch").Elem()
-			multilibProp := archPropValues.FieldByName("Multilib").Elem()
-			targetProp := archPropValues.FieldByName("Target").Elem()
+// ArchPropertiesForTarget returns a copy of props, which must be one of the property structs
+// added to the module, with the arch-specific properties for the given target squashed into it.
+// It is intended for modules that are built for the common architecture but include
+// dependencies for multiple targets, like apex, which need the arch: { arm: { ... } },
+// multilib: { lib32: { ... } } and target: { android_arm: { ... } } values of their arch_variant
+// properties for each of those targets.  The OS-specific properties of the variant have already
+// been squashed into props.
+func (m *ModuleBase) ArchPropertiesForTarget(ctx BottomUpMutatorContext, props interface{}, target Target) interface{} {
+	for i, genProps := range m.generalProperties {
+		if genProps != props {
+			continue
+		}
+		ret := proptools.CloneProperties(reflect.ValueOf(props)).Interface()
+		if m.archProperties[i] != nil {
+			m.appendArchProperties(ctx, ret, m.archProperties[i], target)
+		}
+		return ret
+	}
+	panic(fmt.Errorf("%T is not a property struct of module %q", props, m.Name()))
+}
 
-			// Handle arch-specific properties in the form:
-			// arch: {
-			//     arm64: {
-			//         key: value,
-			//     },
-			// },
-			t := arch.ArchType
-
-			if arch.ArchType != Common {
-				field := proptools.FieldNameForProperty(t.Name)
-				prefix := "arch." + t.Name
-				archStruct := m.appendProperties(ctx, genProps, archProp, field, prefix)
-
-				// Handle arch-variant-specific properties in the form:
-				// arch: {
-				//     variant: {
-				//         key: value,
-				//     },
-				// },
-				v := variantReplacer.Replace(arch.ArchVariant)
-				if v != "" {
-					field := proptools.FieldNameForProperty(v)
-					prefix := "arch." + t.Name + "." + v
-					m.appendProperties(ctx, genProps, archStruct, field, prefix)
-				}
+// appendArchProperties squashes the arch-specific property structs for the given target into
+// the genProps property struct.
+func (m *ModuleBase) appendArchProperties(ctx BottomUpMutatorContext, genProps interface{},
+	archPropertiesList []interface{}, target Target) {
 
-				// Handle cpu-variant-specific properties in the form:
-				// arch: {
-				//     variant: {
-				//         key: value,
-				//     },
-				// },
-				if arch.CpuVariant != arch.ArchVariant {
-					c := variantReplacer.Replace(arch.CpuVariant)
-					if c != "" {
-						field := proptools.FieldNameForProperty(c)
-						prefix := "arch." + t.Name + "." + c
-						m.appendProperties(ctx, genProps, archStruct, field, prefix)
-					}
-				}
+	arch := target.Arch
+	os := target.Os
 
-				// Handle arch-feature-specific properties in the form:
-				// arch: {
-				//     feature: {
-				//         key: value,
-				//     },
-				// },
-				for _, feature := range arch.ArchFeatures {
-					field := proptools.FieldNameForProperty(feature)
-					prefix := "arch." + t.Name + "." + feature
-					m.appendProperties(ctx, genProps, archStruct, field, prefix)
-				}
+	for _, archProperties := range archPropertiesList {
+		archPropValues := reflect.ValueOf(archProperties).Elem()
 
-				// Handle multilib-specific properties in the form:
-				// multilib: {
-				//     lib32: {
-				//         key: value,
-				//     },
-				// },
-				field = proptools.FieldNameForProperty(t.Multilib)
-				prefix = "multilib." + t.Multilib
-				m.appendProperties(ctx, genProps, multilibProp, field, prefix)
-			}
+		archProp := archPropValues.FieldByName("Arch").Elem()
+		multilibProp := archPropValues.FieldByName("Multilib").Elem()
+		targetProp := archPropValues.FieldByName("Target").Elem()
 
-			// Handle combined OS-feature and arch specific properties in the form:
-			// target: {
-			//     bionic_x86: {
-			//         key: value,
-			//     },
-			// }
-			if os.Linux() && arch.ArchType != Common {
-				field := "Linux_" + arch.ArchType.Name
-				prefix := "target.linux_" + arch.ArchType.Name
-				m.appendProperties(ctx, genProps, targetProp, field, prefix)
-			}
+		// Handle arch-specific properties in the form:
+		// arch: {
+		//     arm64: {
+		//         key: value,
+		//     },
+		// },
+		t := arch.ArchType
 
-			if os.Bionic() && arch.ArchType != Common {
-				field := "Bionic_" + t.Name
-				prefix := "target.bionic_" + t.Name
-				m.appendProperties(ctx, genProps, targetProp, field, prefix)
-			}
+		if arch.ArchType != Common {
+			field := proptools.FieldNameForProperty(t.Name)
+			prefix := "arch." + t.Name
+			archStruct := m.appendProperties(ctx, genProps, archProp, field, prefix)
 
-			// Handle combined OS and arch specific properties in the form:
-			// target: {
-			//     linux_glibc_x86: {
-			//         key: value,
-			//     },
-			//     linux_glibc_arm: {
-			//         key: value,
-			//     },
-			//     android_arm {
+			// Handle arch-variant-specific properties in the form:
+			// arch: {
+			//     variant: {
 			//         key: value,
 			//     },
-			//     android_x86 {
+			// },
+			v := variantReplacer.Replace(arch.ArchVariant)
+			if v != "" {
+				field := proptools.FieldNameForProperty(v)
+				prefix := "arch." + t.Name + "." + v
+				m.appendProperties(ctx, genProps, archStruct, field, prefix)
+			}
+
+			// Handle cpu-variant-specific properties in the form:
+			// arch: {
+			//     variant: {
 			//         key: value,
 			//     },
 			// },
-			if arch.ArchType != Common {
-				field := os.Field + "_" + t.Name
-				prefix := "target." + os.Name + "_" + t.Name
-				m.appendProperties(ctx, genProps, targetProp, field, prefix)
+			if arch.CpuVariant != arch.ArchVariant {
+				c := variantReplacer.Replace(arch.CpuVariant)
+				if c != "" {
+					field := proptools.FieldNameForProperty(c)
+					prefix := "arch." + t.Name + "." + c
+					m.appendProperties(ctx, genProps, archStruct, field, prefix)
+				}
 			}
 
-			// Handle arm on x86 properties in the form:
-			// target {
-			//     arm_on_x86 {
+			// Handle arch-feature-specific properties in the form:
+			// arch: {
+			//     feature: {
 			//         key: value,
 			//     },
-			//     arm_on_x86_64 {
+			// },
+			for _, feature := range arch.ArchFeatures {
+				field := proptools.FieldNameForProperty(feature)
+				prefix := "arch." + t.Name + "." + feature
+				m.appendProperties(ctx, genProps, archStruct, field, prefix)
+			}
+
+			// Handle multilib-specific properties in the form:
+			// multilib: {
+			//     lib32: {
 			//         key: value,
 			//     },
 			// },
-			if os.Class == Device {
-				if arch.ArchType == X86 && (hasArmAbi(arch) ||
-					hasArmAndroidArch(ctx.Config().Targets[Android])) {
-					field := "Arm_on_x86"
-					prefix := "target.arm_on_x86"
-					m.appendProperties(ctx, genProps, targetProp, field, prefix)
-				}
-				if arch.ArchType == X86_64 && (hasArmAbi(arch) ||
-					hasArmAndroidArch(ctx.Config().Targets[Android])) {
-					field := "Arm_on_x86_64"
-					prefix := "target.arm_on_x86_64"
-					m.appendProperties(ctx, genProps, targetProp, field, prefix)
-				}
-				if os == Android && m.Target().NativeBridge == NativeBridgeEnabled {
-					field := "Native_bridge"
-					prefix := "target.native_bridge"
-					m.appendProperties(ctx, genProps, targetProp, field, prefix)
-				}
+			field = proptools.FieldNameForProperty(t.Multilib)
+			prefix = "multilib." + t.Multilib
+			m.appendProperties(ctx, genProps, multilibProp, field, prefix)
+		}
+
+		// Handle combined OS-feature and arch specific properties in the form:
+		// target: {
+		//     bionic_x86: {
+		//         key: value,
+		//     },
+		// }
+		if os.Linux() && arch.ArchType != Common {
+			field := "Linux_" + arch.ArchType.Name
+			prefix := "target.linux_" + arch.ArchType.Name
+			m.appendProperties(ctx, genProps, targetProp, field, prefix)
+		}
+
+		if os.Bionic() && arch.ArchType != Common {
+			field := "Bionic_" + t.Name
+			prefix := "target.bionic_" + t.Name
+			m.appendProperties(ctx, genProps, targetProp, field, prefix)
+		}
+
+		// Handle combined OS and arch specific properties in the form:
+		// target: {
+		//     linux_glibc_x86: {
+		//         key: value,
+		//     },
+		//     linux_glibc_arm: {
+		//         key: value,
+		//     },
+		//     android_arm {
+		//         key: value,
+		//     },
+		//     android_x86 {
+		//         key: value,
+		//     },
+		// },
+		if arch.ArchType != Common {
+			field := os.Field + "_" + t.Name
+			prefix := "target." + os.Name + "_" + t.Name
+			m.appendProperties(ctx, genProps, targetProp, field, prefix)
+		}
+
+		// Handle arm on x86 properties in the form:
+		// target {
+		//     arm_on_x86 {
+		//         key: value,
+		//     },
+		//     arm_on_x86_64 {
+		//         key: value,
+		//     },
+		// },
+		if os.Class == Device {
+			if arch.ArchType == X86 && (hasArmAbi(arch) ||
+				hasArmAndroidArch(ctx.Config().Targets[Android])) {
+				field := "Arm_on_x86"
+				prefix := "target.arm_on_x86"
+				m.appendProperties(ctx, genProps, targetProp, field, prefix)
+			}
+			if arch.ArchType == X86_64 && (hasArmAbi(arch) ||
+				hasArmAndroidArch(ctx.Config().Targets[Android])) {
+				field := "Arm_on_x86_64"
+				prefix := "target.arm_on_x86_64"
+				m.appendProperties(ctx, genProps, targetProp, field, prefix)
+			}
+			if os == Android && target.NativeBridge == NativeBridgeEnabled {
+				field := "Native_bridge"
+				prefix := "target.native_bridge"
+				m.appendProperties(ctx, genProps, targetProp, field, prefix)
 			}
 		}
 	}
@@ -1546,17 +1575,21 @@ func getCommonTargets(targets []Target) []Target {
 // that contains zero or one Target for each OsType, selecting the one that matches the earliest
 // filter.
 func firstTarget(targets []Target, filters ...string) []Target {
-	// find the first target from each OS
+	// find the first target from each OS, and the first native bridge target from each OS so that
+	// the translated architectures get a first target as well
+	type osAndNativeBridge struct {
+		os           OsType
+		nativeBridge NativeBridgeSupport
+	}
 	var ret []Target
-	hasHost := false
-	set := make(map[OsType]bool)
+	set := make(map[osAndNativeBridge]bool)
 
 	for _, filter := range filters {
 		buildTargets := filterMultilibTargets(targets, filter)
 		for _, t := range buildTargets {
-			if _, found := set[t.Os]; !found {
-				hasHost = hasHost || (t.Os.Class == Host)
-				set[t.Os] = true
+			key := osAndNativeBridge{t.Os, t.NativeBridge}
+			if _, found := set[key]; !found {
+				set[key] = true
 				ret = append(ret, t)
 			}
 		}
@@ -397,6 +397,13 @@ func TestArchMutatorNativeBridge(t *testing.T) {
 			native_bridge_supported: true,
 		}
 
+		// This module is enabled for the first arch of x86 and of arm (via native bridge).
+		module {
+			name: "qux",
+			native_bridge_supported: true,
+			compile_multilib: "first",
+		}
+
 		// This module is enabled for arm (native_bridge) only.
 		module {
 			name: "baz",
@@ -416,6 +423,7 @@ func TestArchMutatorNativeBridge(t *testing.T) {
 		fooVariants []string
 		barVariants []string
 		bazVariants []string
+		quxVariants []string
 	}{
 		{
 			name:        "normal",
@@ -423,6 +431,7 @@ func TestArchMutatorNativeBridge(t *testing.T) {
 			fooVariants: []string{"android_x86_64_silvermont", "android_x86_silvermont"},
 			barVariants: []string{"android_x86_64_silvermont", "android_native_bridge_arm64_armv8-a", "android_x86_silvermont", "android_native_bridge_arm_armv7-a-neon"},
 			bazVariants: []string{"android_native_bridge_arm64_armv8-a", "android_native_bridge_arm_armv7-a-neon"},
+			quxVariants: []string{"android_x86_64_silvermont", "android_native_bridge_arm64_armv8-a"},
 		},
 	}
 
@@ -463,8 +472,98 @@ func TestArchMutatorNativeBridge(t *testing.T) {
 			}
 
 			if g, w := enabledVariants(ctx, "baz"), tt.bazVariants; !reflect.DeepEqual(w, g) {
+				t.Errorf("want baz variants:\n%q\ngot:\n%q\n", w, g)
+			}
+
+			if g, w := enabledVariants(ctx, "qux"), tt.quxVariants; !reflect.DeepEqual(w, g) {
 				t.Errorf("want qux variants:\n%q\ngot:\n%q\n", w, g)
 			}
 		})
 	}
 }
+
+type archMultiTargetsTestModule struct {
+	ModuleBase
+	props struct {
+		Srcs []string `android:"arch_variant"`
+	}
+
+	srcsPerArch map[string][]string
+}
+
+func (m *archMultiTargetsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func (m *archMultiTargetsTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	m.srcsPerArch = make(map[string][]string)
+	for _, target := range ctx.MultiTargets() {
+		props := m.ArchPropertiesForTarget(ctx, &m.props, target).(*struct {
+			Srcs []string `android:"arch_variant"`
+		})
+		m.srcsPerArch[target.Arch.ArchType.String()] = props.Srcs
+	}
+}
+
+func archMultiTargetsTestModuleFactory() Module {
+	m := &archMultiTargetsTestModule{}
+	m.AddProperties(&m.props)
+	InitAndroidMultiTargetsArchModule(m, DeviceSupported, MultilibCommon)
+	return m
+}
+
+func TestArchPropertiesForTarget(t *testing.T) {
+	bp := `
+		module {
+			name: "foo",
+			compile_multilib: "both",
+			srcs: ["common.c"],
+			arch: {
+				arm: {
+					srcs: ["arm.c"],
+				},
+				arm64: {
+					srcs: ["arm64.c"],
+				},
+			},
+			multilib: {
+				lib32: {
+					srcs: ["lib32.c"],
+				},
+			},
+			target: {
+				android: {
+					srcs: ["android.c"],
+				},
+				android_arm64: {
+					srcs: ["android_arm64.c"],
+				},
+			},
+		}
+	`
+
+	config := TestArchConfig(buildDir, nil, bp, nil)
+
+	ctx := NewTestArchContext(config)
+	ctx.RegisterModuleType("module", archMultiTargetsTestModuleFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	foo := ctx.ModuleForTests("foo", "android_common").Module().(*archMultiTargetsTestModule)
+
+	expected := map[string][]string{
+		"arm64": {"common.c", "android.c", "arm64.c", "android_arm64.c"},
+		"arm":   {"common.c", "android.c", "arm.c", "lib32.c"},
+	}
+	if !reflect.DeepEqual(expected, foo.srcsPerArch) {
+		t.Errorf("expected srcs %q, got %q", expected, foo.srcsPerArch)
+	}
+
+	// The arch-specific properties must not be squashed into the module's own properties.
+	if g, w := foo.props.Srcs, []string{"common.c", "android.c"}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected module srcs %q, got %q", w, g)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+func init() {
+	pctx.HostBinToolVariable("avbtool", "avbtool")
+}
+
+var avbAddFooter = pctx.AndroidStaticRule("avbAddFooter", blueprint.RuleParams{
+	Command: `cp -f $in $out && ` +
+		`${avbtool} $footerCmd --image $out --partition_name $partitionName $partitionSizeFlag ` +
+		`--key $key --algorithm $algorithm $signingArgs`,
+	CommandDeps: []string{"${avbtool}"},
+	Description: "avbtool $footerCmd $out",
+}, "footerCmd", "partitionName", "partitionSizeFlag", "key", "algorithm", "signingArgs")
+
+// AvbFooterParams describes how AvbAddFooter signs an image.
+type AvbFooterParams struct {
+	// Add a hashtree footer, used for partitions that are verified as they are read, instead of a
+	// hash footer, used for images that are verified as a whole when they are loaded.
+	Hashtree bool
+
+	// Name of the partition the image is flashed to. Required.
+	PartitionName string
+
+	// Size of the partition in bytes. If zero, the partition is as large as the signed image.
+	PartitionSize int64
+
+	// Private key to sign the image with. Required.
+	Key Path
+
+	// Hash and signing algorithm. Defaults to SHA256_RSA4096.
+	Algorithm string
+
+	// Salt for the hash or hashtree as a hex string. Defaults to a salt derived from the product,
+	// the build and the partition name, see avbDefaultSalt.
+	Salt string
+
+	// Rollback index of the image, if set.
+	RollbackIndex *int64
+
+	// Additional arguments to avbtool.
+	ExtraFlags []string
+}
+
+// avbDefaultSalt returns the salt that is used when AvbFooterParams.Salt isn't set. avbtool uses
+// a random salt otherwise, which makes the signed images differ each time they are signed. The salt
+// is the sha256 of the product, the build id and the partition name, so that every product, build
+// and partition has its own salt. It only depends on values known to Soong, so clean and
+// incremental builds sign the same image the same way.
+func avbDefaultSalt(config Config, partitionName string) string {
+	h := sha256.New()
+	for _, s := range []string{String(config.productVariables.DeviceName), config.BuildId(), partitionName} {
+		h.Write([]byte(s + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AvbSigningArgs returns the arguments to avbtool add_hash_footer or add_hashtree_footer that pin
+// the salt, set the rollback index and add the extra flags of params. AvbAddFooter passes them to
+// avbtool, and tools that call avbtool themselves, like apexer, should be given them too.
+func AvbSigningArgs(ctx BuilderContext, params AvbFooterParams) []string {
+	salt := params.Salt
+	if salt == "" {
+		salt = avbDefaultSalt(ctx.Config(), params.PartitionName)
+	}
+	args := []string{"--salt " + salt}
+	if params.RollbackIndex != nil {
+		args = append(args, "--rollback_index "+strconv.FormatInt(*params.RollbackIndex, 10))
+	}
+	return append(args, params.ExtraFlags...)
+}
+
+// AvbAddFooter creates a build rule that copies input to output and signs output with avbtool
+// add_hash_footer or add_hashtree_footer. Modules that sign images with avbtool should use it so
+// that they pin the salt and pass the partition size and rollback index the same way.
+func AvbAddFooter(ctx BuilderContext, input Path, output WritablePath, params AvbFooterParams) {
+	if params.PartitionName == "" {
+		ReportPathErrorf(ctx, "AvbAddFooter for %s requires a partition name", output)
+		return
+	}
+	if params.Key == nil {
+		ReportPathErrorf(ctx, "AvbAddFooter for %s requires a key", output)
+		return
+	}
+
+	footerCmd := "add_hash_footer"
+	if params.Hashtree {
+		footerCmd = "add_hashtree_footer"
+	}
+
+	partitionSizeFlag := "--dynamic_partition_size"
+	if params.PartitionSize > 0 {
+		partitionSizeFlag = "--partition_size " + strconv.FormatInt(params.PartitionSize, 10)
+	}
+
+	algorithm := params.Algorithm
+	if algorithm == "" {
+		algorithm = "SHA256_RSA4096"
+	}
+
+	ctx.Build(pctx, BuildParams{
+		Rule:        avbAddFooter,
+		Input:       input,
+		Implicit:    params.Key,
+		Output:      output,
+		Description: "avbtool " + footerCmd + " " + output.Base(),
+		Args: map[string]string{
+			"footerCmd":         footerCmd,
+			"partitionName":     params.PartitionName,
+			"partitionSizeFlag": partitionSizeFlag,
+			"key":               params.Key.String(),
+			"algorithm":         algorithm,
+			"signingArgs":       strings.Join(AvbSigningArgs(ctx, params), " "),
+		},
+	})
+}
@@ -0,0 +1,40 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+func TestAvbDefaultSalt(t *testing.T) {
+	config := TestConfig(buildDir, nil, "", nil)
+	config.TestProductVariables.BuildId = StringPtr("BUILD1")
+
+	salt := avbDefaultSalt(config, "system")
+	if len(salt) != 64 {
+		t.Errorf("expected a sha256 hex string, got %q", salt)
+	}
+	if again := avbDefaultSalt(config, "system"); again != salt {
+		t.Errorf("expected the same salt when signing again, got %q and %q", salt, again)
+	}
+	if vendor := avbDefaultSalt(config, "vendor"); vendor == salt {
+		t.Errorf("expected partitions to have their own salt, got %q for both", salt)
+	}
+
+	config.TestProductVariables.BuildId = StringPtr("BUILD2")
+	if other := avbDefaultSalt(config, "system"); other == salt {
+		t.Errorf("expected builds to have their own salt, got %q for both", salt)
+	}
+}
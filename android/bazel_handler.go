@@ -16,6 +16,9 @@ package android
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -23,6 +26,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
@@ -36,12 +40,86 @@ type CqueryRequestType int
 
 const (
 	getAllFiles CqueryRequestType = iota
+	getIncludeDirs
+	getLinkFlags
 )
 
+// All cquery request types, in the order their results are formatted by the cquery starlark file.
+var cqueryRequestTypes = []CqueryRequestType{getAllFiles, getIncludeDirs, getLinkFlags}
+
+// Name returns the name of the request type, which identifies its results in the cquery output.
+func (t CqueryRequestType) Name() string {
+	switch t {
+	case getAllFiles:
+		return "getAllFiles"
+	case getIncludeDirs:
+		return "getIncludeDirs"
+	case getLinkFlags:
+		return "getLinkFlags"
+	}
+	panic(fmt.Errorf("unknown cquery request type %d", t))
+}
+
+// starlarkExpression returns a Starlark expression that evaluates to the result of the request for
+// `target`, as a list of strings.
+func (t CqueryRequestType) starlarkExpression() string {
+	switch t {
+	case getAllFiles:
+		return `[f.path for f in target.files.to_list()]`
+	case getIncludeDirs:
+		return `providers(target)["CcInfo"].compilation_context.includes.to_list()`
+	case getLinkFlags:
+		return `[flag for linker_input in providers(target)["CcInfo"].linking_context.linker_inputs.to_list() for flag in linker_input.user_link_flags]`
+	}
+	panic(fmt.Errorf("unknown cquery request type %d", t))
+}
+
+func cqueryRequestTypeByName(name string) (CqueryRequestType, bool) {
+	for _, t := range cqueryRequestTypes {
+		if t.Name() == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// configKey identifies the configuration that a Bazel target is built for, which is the os and
+// arch of the variant of the module that requests it.
+type configKey struct {
+	arch   ArchType
+	osType OsType
+}
+
+// String returns the name of the configuration, such as "android_arm64", which is also the name of
+// the Bazel platform that the configuration is built with.
+func (key configKey) String() string {
+	return key.osType.Name + "_" + key.arch.Name
+}
+
+// GetConfigKey returns the configuration key for the variant of the module in ctx, to request the
+// outputs of a Bazel target that are built for that variant.
+func GetConfigKey(ctx BaseModuleContext) configKey {
+	arch := ctx.Arch().ArchType
+	if arch == Common {
+		// The outputs of arch independent variants are built for the primary arch of the os.
+		if targets := ctx.Config().Targets[ctx.Os()]; len(targets) > 0 {
+			arch = targets[0].Arch.ArchType
+		}
+	}
+	return configKey{arch: arch, osType: ctx.Os()}
+}
+
 // Map key to describe bazel cquery requests.
 type cqueryKey struct {
 	label       string
 	requestType CqueryRequestType
+	configKey   configKey
+}
+
+// String returns the key as "<label>|<configuration>|<request type>", which is how the results of
+// the request are identified in the cquery output and in the cquery cache.
+func (key cqueryKey) String() string {
+	return key.label + "|" + key.configKey.String() + "|" + key.requestType.Name()
 }
 
 type BazelContext interface {
@@ -49,8 +127,16 @@ type BazelContext interface {
 	// by bazel. If any of these methods return (_, false), then the request
 	// has been queued to be run later.
 
-	// Returns result files built by building the given bazel target label.
-	GetAllFiles(label string) ([]string, bool)
+	// Returns result files built by building the given bazel target label for the given
+	// configuration.
+	GetAllFiles(label string, cfgKey configKey) ([]string, bool)
+
+	// Returns the include directories of the given bazel cc target label for the given
+	// configuration.
+	GetIncludeDirs(label string, cfgKey configKey) ([]string, bool)
+
+	// Returns the user link flags of the given bazel cc target label for the given configuration.
+	GetLinkFlags(label string, cfgKey configKey) ([]string, bool)
 
 	// TODO(cparsons): Other cquery-related methods should be added here.
 	// ** End cquery methods
@@ -84,6 +170,10 @@ type bazelContext struct {
 
 	results map[cqueryKey]string // Results of cquery requests after Bazel invocations
 
+	// Identifies the product configuration, so that cached cquery results are only reused for the
+	// configuration they were computed for.
+	configKey string
+
 	// Build statements which should get registered to reflect Bazel's outputs.
 	buildStatements []bazel.BuildStatement
 }
@@ -95,16 +185,29 @@ type noopBazelContext struct{}
 
 var _ BazelContext = noopBazelContext{}
 
-// A bazel context to use for tests.
+// A bazel context to use for tests. The results are keyed by label, and are the same for all
+// configurations.
 type MockBazelContext struct {
-	AllFiles map[string][]string
+	AllFiles    map[string][]string
+	IncludeDirs map[string][]string
+	LinkFlags   map[string][]string
 }
 
-func (m MockBazelContext) GetAllFiles(label string) ([]string, bool) {
+func (m MockBazelContext) GetAllFiles(label string, cfgKey configKey) ([]string, bool) {
 	result, ok := m.AllFiles[label]
 	return result, ok
 }
 
+func (m MockBazelContext) GetIncludeDirs(label string, cfgKey configKey) ([]string, bool) {
+	result, ok := m.IncludeDirs[label]
+	return result, ok
+}
+
+func (m MockBazelContext) GetLinkFlags(label string, cfgKey configKey) ([]string, bool) {
+	result, ok := m.LinkFlags[label]
+	return result, ok
+}
+
 func (m MockBazelContext) InvokeBazel() error {
 	panic("unimplemented")
 }
@@ -123,17 +226,42 @@ func (m MockBazelContext) BuildStatementsToRegister() []bazel.BuildStatement {
 
 var _ BazelContext = MockBazelContext{}
 
-func (bazelCtx *bazelContext) GetAllFiles(label string) ([]string, bool) {
-	result, ok := bazelCtx.cquery(label, getAllFiles)
-	if ok {
-		bazelOutput := strings.TrimSpace(result)
-		return strings.Split(bazelOutput, ", "), true
-	} else {
+func (bazelCtx *bazelContext) GetAllFiles(label string, cfgKey configKey) ([]string, bool) {
+	return bazelCtx.cqueryList(label, getAllFiles, cfgKey)
+}
+
+func (bazelCtx *bazelContext) GetIncludeDirs(label string, cfgKey configKey) ([]string, bool) {
+	return bazelCtx.cqueryList(label, getIncludeDirs, cfgKey)
+}
+
+func (bazelCtx *bazelContext) GetLinkFlags(label string, cfgKey configKey) ([]string, bool) {
+	return bazelCtx.cqueryList(label, getLinkFlags, cfgKey)
+}
+
+// cqueryList is like cquery, but splits the result of requests whose results are lists.
+func (bazelCtx *bazelContext) cqueryList(label string, requestType CqueryRequestType,
+	cfgKey configKey) ([]string, bool) {
+
+	result, ok := bazelCtx.cquery(label, requestType, cfgKey)
+	if !ok {
 		return nil, false
 	}
+	bazelOutput := strings.TrimSpace(result)
+	if bazelOutput == "" {
+		return nil, true
+	}
+	return strings.Split(bazelOutput, ", "), true
 }
 
-func (n noopBazelContext) GetAllFiles(label string) ([]string, bool) {
+func (n noopBazelContext) GetAllFiles(label string, cfgKey configKey) ([]string, bool) {
+	panic("unimplemented")
+}
+
+func (n noopBazelContext) GetIncludeDirs(label string, cfgKey configKey) ([]string, bool) {
+	panic("unimplemented")
+}
+
+func (n noopBazelContext) GetLinkFlags(label string, cfgKey configKey) ([]string, bool) {
 	panic("unimplemented")
 }
 
@@ -160,7 +288,17 @@ func NewBazelContext(c *config) (BazelContext, error) {
 		return noopBazelContext{}, nil
 	}
 
-	bazelCtx := bazelContext{buildDir: c.buildDir, requests: make(map[cqueryKey]bool)}
+	productVariables, err := json.Marshal(c.productVariables)
+	if err != nil {
+		return nil, err
+	}
+	configHash := sha256.Sum256(productVariables)
+
+	bazelCtx := bazelContext{
+		buildDir:  c.buildDir,
+		requests:  make(map[cqueryKey]bool),
+		configKey: hex.EncodeToString(configHash[:]),
+	}
 	missingEnvVars := []string{}
 	if len(c.Getenv("BAZEL_HOME")) > 1 {
 		bazelCtx.homeDir = c.Getenv("BAZEL_HOME")
@@ -207,8 +345,10 @@ func (context *bazelContext) BazelEnabled() bool {
 // If the given request was already made (and the results are available), then
 // returns (result, true). If the request is queued but no results are available,
 // then returns ("", false).
-func (context *bazelContext) cquery(label string, requestType CqueryRequestType) (string, bool) {
-	key := cqueryKey{label, requestType}
+func (context *bazelContext) cquery(label string, requestType CqueryRequestType,
+	cfgKey configKey) (string, bool) {
+
+	key := cqueryKey{label, requestType, cfgKey}
 	if result, ok := context.results[key]; ok {
 		return result, true
 	} else {
@@ -285,6 +425,32 @@ mixed_build_root = rule(
 def _phony_root_impl(ctx):
     return []
 
+def _config_node_transition_impl(settings, attr):
+    return {
+        "//command_line_option:platforms": "@sourceroot//build/bazel/platforms:%s_%s" % (attr.os, attr.arch),
+    }
+
+_config_node_transition = transition(
+    implementation = _config_node_transition_impl,
+    inputs = [],
+    outputs = ["//command_line_option:platforms"],
+)
+
+def _passthrough_rule_impl(ctx):
+    return [DefaultInfo(files = depset(ctx.files.deps))]
+
+# Rule to build its dependencies for the platform of the given os and arch, so that the
+# dependencies are built once for each configuration that the variants of Soong modules request.
+config_node = rule(
+    implementation = _passthrough_rule_impl,
+    attrs = {
+        "arch": attr.string(mandatory = True),
+        "os": attr.string(mandatory = True),
+        "deps": attr.label_list(cfg = _config_node_transition),
+        "_allowlist_function_transition": attr.label(default = "@bazel_tools//tools/allowlists/function_transition_allowlist"),
+    },
+)
+
 # Rule to depend on other targets but build nothing.
 # This is useful as follows: building a target of this rule will generate
 # symlink forests for all dependencies of the target, without executing any
@@ -312,8 +478,8 @@ func canonicalizeLabel(label string) string {
 func (context *bazelContext) mainBuildFileContents() []byte {
 	formatString := `
 # This file is generated by soong_build. Do not edit.
-load(":main.bzl", "mixed_build_root", "phony_root")
-
+load(":main.bzl", "config_node", "mixed_build_root", "phony_root")
+%s
 mixed_build_root(name = "buildroot",
     deps = [%s],
 )
@@ -322,39 +488,177 @@ phony_root(name = "phonyroot",
     deps = [":buildroot"],
 )
 `
-	var buildRootDeps []string = nil
-	for val, _ := range context.requests {
-		buildRootDeps = append(buildRootDeps, fmt.Sprintf("\"%s\"", canonicalizeLabel(val.label)))
+	configNodeFormatString := `
+config_node(name = "%s",
+    arch = "%s",
+    os = "%s",
+    deps = [%s],
+)
+`
+	configs := context.requestedConfigs()
+	labelsByConfig := context.requestedLabelsByConfig()
+
+	var configNodes strings.Builder
+	var buildRootDeps []string
+	for _, config := range SortedStringKeys(labelsByConfig) {
+		var deps []string
+		for _, label := range labelsByConfig[config] {
+			deps = append(deps, fmt.Sprintf("\"%s\"", canonicalizeLabel(label)))
+		}
+		cfgKey := configs[config]
+		fmt.Fprintf(&configNodes, configNodeFormatString, config, cfgKey.arch.Name, cfgKey.osType.Name,
+			strings.Join(deps, ",\n            "))
+		buildRootDeps = append(buildRootDeps, fmt.Sprintf("\":%s\"", config))
 	}
 	buildRootDepsString := strings.Join(buildRootDeps, ",\n            ")
 
-	return []byte(fmt.Sprintf(formatString, buildRootDepsString))
+	return []byte(fmt.Sprintf(formatString, configNodes.String(), buildRootDepsString))
+}
+
+// requestedConfigs returns the configurations of all queued requests, keyed by their names.
+func (context *bazelContext) requestedConfigs() map[string]configKey {
+	configs := map[string]configKey{}
+	for key := range context.requests {
+		configs[key.configKey.String()] = key.configKey
+	}
+	return configs
 }
 
+// requestedLabelsByConfig returns the sorted labels of all queued requests, of any request type,
+// keyed by the names of the configurations they are requested for.
+func (context *bazelContext) requestedLabelsByConfig() map[string][]string {
+	labels := map[string]map[string]bool{}
+	for key := range context.requests {
+		config := key.configKey.String()
+		if labels[config] == nil {
+			labels[config] = map[string]bool{}
+		}
+		labels[config][key.label] = true
+	}
+	ret := map[string][]string{}
+	for config, configLabels := range labels {
+		ret[config] = SortedStringKeys(configLabels)
+	}
+	return ret
+}
+
+// Returns the contents of the starlark file used to format the cquery output. The format function
+// outputs one line per request of each requested target, as
+// "<label>|<configuration>|<request type>>><result>", where the configuration is the name of the
+// platform the target is built for.
 func (context *bazelContext) cqueryStarlarkFileContents() []byte {
-	formatString := `
-# This file is generated by soong_build. Do not edit.
-getAllFilesLabels = {
-  %s
+	keysByType := map[CqueryRequestType][]string{}
+	for key := range context.requests {
+		keysByType[key.requestType] = append(keysByType[key.requestType],
+			canonicalizeLabel(key.label)+"|"+key.configKey.String())
+	}
+
+	var contents strings.Builder
+	contents.WriteString("\n# This file is generated by soong_build. Do not edit.\n")
+	for _, requestType := range cqueryRequestTypes {
+		keys := keysByType[requestType]
+		sort.Strings(keys)
+		fmt.Fprintf(&contents, "%sLabels = {\n", requestType.Name())
+		for _, key := range keys {
+			fmt.Fprintf(&contents, "  \"%s\" : True,\n", key)
+		}
+		contents.WriteString("}\n")
+	}
+
+	contents.WriteString(`
+# Returns the name of the platform the target is built for, which is the name of the configuration
+# it was requested for.
+def get_config(target):
+  platforms = build_options(target)["//command_line_option:platforms"]
+  if len(platforms) != 1:
+    return "UNKNOWN"
+  return str(platforms[0]).split(":")[-1]
+`)
+	contents.WriteString("\ndef format(target):\n")
+	contents.WriteString("  key = str(target.label) + \"|\" + get_config(target)\n")
+	contents.WriteString("  results = []\n")
+	for _, requestType := range cqueryRequestTypes {
+		fmt.Fprintf(&contents, "  if key in %sLabels:\n", requestType.Name())
+		fmt.Fprintf(&contents, "    results.append(key + \"|%s>>\" + ', '.join(%s))\n",
+			requestType.Name(), requestType.starlarkExpression())
+	}
+	contents.WriteString("  # Targets that were not requested via cquery are dependencies of requested targets,\n")
+	contents.WriteString("  # and output nothing.\n")
+	contents.WriteString("  return \"\\n\".join(results)\n")
+
+	return []byte(contents.String())
+}
+
+// parseCqueryOutput returns the results in the output of a cquery formatted by the file returned
+// by cqueryStarlarkFileContents, keyed by the String() of their cqueryKey with the canonicalized
+// label.
+func parseCqueryOutput(output string) map[string]string {
+	results := map[string]string{}
+	for _, outputLine := range strings.Split(output, "\n") {
+		splitLine := strings.SplitN(outputLine, ">>", 2)
+		if len(splitLine) != 2 {
+			continue
+		}
+		splitKey := strings.Split(splitLine[0], "|")
+		if len(splitKey) != 3 {
+			continue
+		}
+		if _, ok := cqueryRequestTypeByName(splitKey[2]); ok {
+			results[splitLine[0]] = splitLine[1]
+		}
+	}
+	return results
 }
 
-def format(target):
-  if str(target.label) in getAllFilesLabels:
-    return str(target.label) + ">>" + ', '.join([f.path for f in target.files.to_list()])
-  else:
-    # This target was not requested via cquery, and thus must be a dependency
-    # of a requested target.
-    return ""
-`
-	var buildRootDeps []string = nil
-	// TODO(cparsons): Sort by request type instead of assuming all requests
-	// are of GetAllFiles type.
-	for val, _ := range context.requests {
-		buildRootDeps = append(buildRootDeps, fmt.Sprintf("\"%s\" : True", canonicalizeLabel(val.label)))
+// cqueryCache is the on-disk cache of cquery results, which saves invoking cquery when all the
+// requests of a build were already made by a previous build of the same configuration.
+type cqueryCache struct {
+	// Key identifies the configuration and the state of the BUILD files the results were
+	// computed for.
+	Key string
+
+	// Results of cquery requests, keyed by the String() of their cqueryKey.
+	Results map[string]string
+}
+
+// readCqueryCache returns the cquery cache stored in the given file if it has the given key, or
+// an empty cache with the given key otherwise.
+func readCqueryCache(path string, key string) *cqueryCache {
+	cache := &cqueryCache{}
+	if data, err := ioutil.ReadFile(path); err == nil {
+		if json.Unmarshal(data, cache) == nil && cache.Key == key && cache.Results != nil {
+			return cache
+		}
 	}
-	buildRootDepsString := strings.Join(buildRootDeps, ",\n  ")
+	return &cqueryCache{Key: key, Results: map[string]string{}}
+}
+
+func writeCqueryCache(path string, cache *cqueryCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
+
+// cqueryCacheKey returns the key of the cquery cache for the current build, which changes when the
+// product configuration changes or when any of the BUILD files Bazel reads is modified.
+func (context *bazelContext) cqueryCacheKey() (string, error) {
+	hash := sha256.New()
+	fmt.Fprintln(hash, context.configKey)
 
-	return []byte(fmt.Sprintf(formatString, buildRootDepsString))
+	data, err := ioutil.ReadFile(bazelBuildListFile())
+	if err != nil {
+		return "", err
+	}
+	for _, file := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fileInfo, err := os.Stat(absolutePath(file))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(hash, file, fileInfo.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // Returns a workspace-relative path containing build-related metadata required
@@ -371,7 +675,7 @@ func (context *bazelContext) InvokeBazel() error {
 	var cqueryOutput string
 	var err error
 
-	err = os.Mkdir(absolutePath(context.intermediatesDir()), 0777)
+	err = os.MkdirAll(absolutePath(context.intermediatesDir()), 0777)
 	if err != nil {
 		return err
 	}
@@ -387,13 +691,6 @@ func (context *bazelContext) InvokeBazel() error {
 	if err != nil {
 		return err
 	}
-	cqueryFileRelpath := filepath.Join(context.intermediatesDir(), "buildroot.cquery")
-	err = ioutil.WriteFile(
-		absolutePath(cqueryFileRelpath),
-		context.cqueryStarlarkFileContents(), 0666)
-	if err != nil {
-		return err
-	}
 	workspaceFileRelpath := filepath.Join(context.intermediatesDir(), "WORKSPACE.bazel")
 	err = ioutil.WriteFile(
 		absolutePath(workspaceFileRelpath),
@@ -402,28 +699,56 @@ func (context *bazelContext) InvokeBazel() error {
 		return err
 	}
 	buildrootLabel := "//:buildroot"
-	cqueryOutput, err = context.issueBazelCommand(bazel.CqueryBuildRootRunName, "cquery",
-		[]string{fmt.Sprintf("deps(%s)", buildrootLabel)},
-		"--output=starlark",
-		"--starlark:file="+cqueryFileRelpath)
 
+	// Reuse the results of a previous build of the same configuration, and only issue the cquery
+	// if some of the requests weren't made before.
+	cacheKey, err := context.cqueryCacheKey()
 	if err != nil {
 		return err
 	}
-
-	cqueryResults := map[string]string{}
-	for _, outputLine := range strings.Split(cqueryOutput, "\n") {
-		if strings.Contains(outputLine, ">>") {
-			splitLine := strings.SplitN(outputLine, ">>", 2)
-			cqueryResults[splitLine[0]] = splitLine[1]
+	cacheFile := absolutePath(filepath.Join(context.intermediatesDir(), "cquery_cache.json"))
+	cache := readCqueryCache(cacheFile, cacheKey)
+	cacheComplete := true
+	for val, _ := range context.requests {
+		if cqueryResult, ok := cache.Results[val.String()]; ok {
+			context.results[val] = cqueryResult
+		} else {
+			cacheComplete = false
 		}
 	}
 
-	for val, _ := range context.requests {
-		if cqueryResult, ok := cqueryResults[canonicalizeLabel(val.label)]; ok {
-			context.results[val] = string(cqueryResult)
-		} else {
-			return fmt.Errorf("missing result for bazel target %s", val.label)
+	if !cacheComplete {
+		cqueryFileRelpath := filepath.Join(context.intermediatesDir(), "buildroot.cquery")
+		err = ioutil.WriteFile(
+			absolutePath(cqueryFileRelpath),
+			context.cqueryStarlarkFileContents(), 0666)
+		if err != nil {
+			return err
+		}
+		cqueryOutput, err = context.issueBazelCommand(bazel.CqueryBuildRootRunName, "cquery",
+			[]string{fmt.Sprintf("deps(%s)", buildrootLabel)},
+			"--output=starlark",
+			"--starlark:file="+cqueryFileRelpath)
+
+		if err != nil {
+			return err
+		}
+
+		cqueryResults := parseCqueryOutput(cqueryOutput)
+		for val, _ := range context.requests {
+			canonicalKey := cqueryKey{canonicalizeLabel(val.label), val.requestType, val.configKey}
+			if cqueryResult, ok := cqueryResults[canonicalKey.String()]; ok {
+				context.results[val] = cqueryResult
+				cache.Results[val.String()] = cqueryResult
+			} else {
+				return fmt.Errorf("missing %s result for bazel target %s in configuration %s",
+					val.requestType.Name(), val.label, val.configKey)
+			}
+		}
+
+		err = writeCqueryCache(cacheFile, cache)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -467,6 +792,11 @@ func (context *bazelContext) OutputBase() string {
 	return context.outputBase
 }
 
+// Returns the path of the file listing the BUILD files that Bazel reads in mixed builds.
+func bazelBuildListFile() string {
+	return absolutePath(filepath.Join(filepath.Dir(bootstrap.ModuleListFile), "bazel.list"))
+}
+
 // Singleton used for registering BUILD file ninja dependencies (needed
 // for correctness of builds which use Bazel.
 func BazelSingleton() Singleton {
@@ -482,8 +812,7 @@ func (c *bazelSingleton) GenerateBuildActions(ctx SingletonContext) {
 	}
 
 	// Add ninja file dependencies for files which all bazel invocations require.
-	bazelBuildList := absolutePath(filepath.Join(
-		filepath.Dir(bootstrap.ModuleListFile), "bazel.list"))
+	bazelBuildList := bazelBuildListFile()
 	ctx.AddNinjaFileDeps(bazelBuildList)
 
 	data, err := ioutil.ReadFile(bazelBuildList)
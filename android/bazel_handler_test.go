@@ -0,0 +1,138 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var (
+	arm64Config  = configKey{arch: Arm64, osType: Android}
+	x86_64Config = configKey{arch: X86_64, osType: Android}
+)
+
+func TestCqueryStarlarkFileContents(t *testing.T) {
+	context := &bazelContext{requests: map[cqueryKey]bool{
+		{"//foo:bar", getAllFiles, arm64Config}:    true,
+		{"//foo:bar", getAllFiles, x86_64Config}:   true,
+		{"//foo:bar", getIncludeDirs, arm64Config}: true,
+		{"baz:qux", getLinkFlags, arm64Config}:     true,
+	}}
+	contents := string(context.cqueryStarlarkFileContents())
+
+	for _, expected := range []string{
+		"getAllFilesLabels = {\n  \"@sourceroot//foo:bar|android_arm64\" : True,\n  \"@sourceroot//foo:bar|android_x86_64\" : True,\n}",
+		"getIncludeDirsLabels = {\n  \"@sourceroot//foo:bar|android_arm64\" : True,\n}",
+		"getLinkFlagsLabels = {\n  \"@sourceroot//baz:qux|android_arm64\" : True,\n}",
+		"results.append(key + \"|getIncludeDirs>>\"",
+	} {
+		if !strings.Contains(contents, expected) {
+			t.Errorf("expected cquery starlark file to contain %q, got:\n%s", expected, contents)
+		}
+	}
+
+	// The requested targets are built once for each configuration they are requested for.
+	buildFile := string(context.mainBuildFileContents())
+	for _, expected := range []string{
+		"config_node(name = \"android_arm64\",\n    arch = \"arm64\",\n    os = \"android\",\n" +
+			"    deps = [\"@sourceroot//baz:qux\",\n            \"@sourceroot//foo:bar\"],\n)",
+		"config_node(name = \"android_x86_64\",\n    arch = \"x86_64\",\n    os = \"android\",\n" +
+			"    deps = [\"@sourceroot//foo:bar\"],\n)",
+		"deps = [\":android_arm64\",\n            \":android_x86_64\"],",
+	} {
+		if !strings.Contains(buildFile, expected) {
+			t.Errorf("expected the main BUILD file to contain %q, got:\n%s", expected, buildFile)
+		}
+	}
+}
+
+func TestParseCqueryOutput(t *testing.T) {
+	output := "@sourceroot//foo:bar|android_arm64|getAllFiles>>out/foo.a, out/foo.so\n" +
+		"@sourceroot//foo:bar|android_x86_64|getAllFiles>>out/x86_64/foo.a\n" +
+		"@sourceroot//foo:bar|android_arm64|getIncludeDirs>>foo/include\n" +
+		"\n" +
+		"@sourceroot//foo:baz|android_arm64|getUnknown>>ignored\n" +
+		"@sourceroot//foo:baz|getAllFiles>>ignored\n" +
+		"unrelated output\n"
+
+	expected := map[string]string{
+		cqueryKey{"@sourceroot//foo:bar", getAllFiles, arm64Config}.String():    "out/foo.a, out/foo.so",
+		cqueryKey{"@sourceroot//foo:bar", getAllFiles, x86_64Config}.String():   "out/x86_64/foo.a",
+		cqueryKey{"@sourceroot//foo:bar", getIncludeDirs, arm64Config}.String(): "foo/include",
+	}
+	if actual := parseCqueryOutput(output); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected cquery results %q, got %q", expected, actual)
+	}
+}
+
+func TestCqueryCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cquery_cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cacheFile := filepath.Join(dir, "cquery_cache.json")
+
+	if cache := readCqueryCache(cacheFile, "key"); cache.Key != "key" || len(cache.Results) != 0 {
+		t.Errorf("expected an empty cache when the file doesn't exist, got %v", cache)
+	}
+
+	cache := &cqueryCache{
+		Key:     "key",
+		Results: map[string]string{cqueryKey{"//foo:bar", getLinkFlags, arm64Config}.String(): "-lfoo"},
+	}
+	if err := writeCqueryCache(cacheFile, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := readCqueryCache(cacheFile, "key"); !reflect.DeepEqual(actual, cache) {
+		t.Errorf("expected cache %v, got %v", cache, actual)
+	}
+	if actual := readCqueryCache(cacheFile, "other key"); actual.Key != "other key" || len(actual.Results) != 0 {
+		t.Errorf("expected an empty cache for a different key, got %v", actual)
+	}
+}
+
+func TestCqueryList(t *testing.T) {
+	context := &bazelContext{
+		requests: map[cqueryKey]bool{},
+		results: map[cqueryKey]string{
+			{"//foo:bar", getLinkFlags, arm64Config}:   "-lfoo, -lbar\n",
+			{"//foo:bar", getIncludeDirs, arm64Config}: "",
+		},
+	}
+
+	if flags, ok := context.GetLinkFlags("//foo:bar", arm64Config); !ok || !reflect.DeepEqual(flags, []string{"-lfoo", "-lbar"}) {
+		t.Errorf("expected link flags [-lfoo -lbar], got %q (%v)", flags, ok)
+	}
+	if dirs, ok := context.GetIncludeDirs("//foo:bar", arm64Config); !ok || dirs != nil {
+		t.Errorf("expected no include dirs, got %q (%v)", dirs, ok)
+	}
+	if _, ok := context.GetAllFiles("//foo:bar", arm64Config); ok {
+		t.Errorf("expected the all files request not to have a result")
+	}
+	if !context.requests[cqueryKey{"//foo:bar", getAllFiles, arm64Config}] {
+		t.Errorf("expected the all files request to be queued")
+	}
+	// The results are specific to the configuration they were requested for.
+	if _, ok := context.GetLinkFlags("//foo:bar", x86_64Config); ok {
+		t.Errorf("expected the link flags request for x86_64 not to have a result")
+	}
+}
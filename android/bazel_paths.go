@@ -0,0 +1,252 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/pathtools"
+
+	"android/soong/bazel"
+)
+
+// This file implements the conversion of module references and source paths in the properties of
+// a module into Bazel labels. Module converters use it so that references are converted the same
+// way across all converters:
+//
+//   - A reference to a module, either by name in a dependency property or with the ":module"
+//     syntax in a path property, becomes the label of the module's target, in the Bazel package of
+//     the directory the module is defined in. This covers modules in other namespaces, as a
+//     namespace is defined by a directory.
+//   - A source file becomes the label of the file in the package of the module being converted,
+//     unless the file is in a subdirectory that is a package of its own.
+//   - A glob is expanded, and each file it matches is converted like a source file.
+//   - Excludes remove the files they match, including the sources of the filegroups they
+//     reference, so the converted sources are the same files that Soong would build.
+//
+// The referenced modules must be dependencies of the module being converted. Modules referenced
+// with the ":module" syntax in properties tagged with `android:"path"` are added as dependencies
+// by the pathdeps mutator.
+
+// BazelConversionPathContext is the context that is needed to convert module references and
+// source paths into Bazel labels.
+type BazelConversionPathContext interface {
+	PathContext
+	PathGlobContext
+
+	ModuleDir() string
+	GetDirectDep(name string) (blueprint.Module, blueprint.DependencyTag)
+	OtherModuleName(m blueprint.Module) string
+	OtherModuleDir(m blueprint.Module) string
+	ModuleErrorf(fmt string, args ...interface{})
+}
+
+// Files that mark a directory as a Bazel package. Android.bp files are included, as the directory
+// of an Android.bp file becomes a package when the file is converted.
+var bazelPackageMarkers = []string{"Android.bp", "BUILD", "BUILD.bazel"}
+
+// BazelLabelForModuleDeps returns the Bazel labels of the given modules, referenced by name, as in
+// static_libs, or with the ":module" syntax.
+func BazelLabelForModuleDeps(ctx BazelConversionPathContext, modules []string) bazel.LabelList {
+	var labels bazel.LabelList
+	for _, module := range modules {
+		if m := SrcIsModule(module); m != "" {
+			module = m
+		}
+		if _, label, ok := bazelLabelForModule(ctx, module, ""); ok {
+			labels.Includes = append(labels.Includes, label)
+		}
+	}
+	return labels
+}
+
+// BazelLabelForSrcPatterns returns the Bazel labels of the sources in the given paths, which may be
+// source files, globs or references to modules with the ":module" syntax, as in the srcs property
+// of a module. The excludes are applied the way PathsForModuleSrcExcludes applies them: files that
+// match an exclude, or that are sources of a module referenced by an exclude, are left out. A
+// referenced filegroup that has some of its sources excluded is expanded into the labels of its
+// remaining sources, as its own label would include the excluded ones. Modules referenced by the
+// excludes are also returned as the excludes of the label list.
+func BazelLabelForSrcPatterns(ctx BazelConversionPathContext, paths, excludes []string) bazel.LabelList {
+	var labels bazel.LabelList
+
+	var expandedExcludes []string
+	for _, e := range excludes {
+		if m, t := SrcIsModuleWithTag(e); m != "" {
+			module, label, ok := bazelLabelForModule(ctx, m, t)
+			if !ok {
+				continue
+			}
+			labels.Excludes = append(labels.Excludes, label)
+			files, _ := bazelFilesForModuleReference(ctx, module)
+			expandedExcludes = append(expandedExcludes, files...)
+		} else {
+			expandedExcludes = append(expandedExcludes, filepath.Join(ctx.ModuleDir(), e))
+		}
+	}
+
+	for _, p := range paths {
+		if m, t := SrcIsModuleWithTag(p); m != "" {
+			if module, label, ok := bazelLabelForModule(ctx, m, t); ok {
+				labels.Includes = append(labels.Includes,
+					bazelLabelsForModuleReference(ctx, p, module, label, expandedExcludes)...)
+			}
+			continue
+		}
+
+		path := filepath.Join(ctx.ModuleDir(), p)
+		var files []string
+		if pathtools.IsGlob(path) {
+			// Globs are expanded instead of being converted to Bazel globs, because a Bazel glob
+			// doesn't match files in subdirectories that are packages of their own, while a "**"
+			// glob in Soong does. The files in such subdirectories get labels in their packages.
+			var err error
+			files, err = ctx.GlobWithDeps(path, expandedExcludes)
+			if err != nil {
+				ctx.ModuleErrorf("glob %q: %s", p, err)
+				continue
+			}
+		} else if !InList(path, expandedExcludes) {
+			files = []string{path}
+		}
+
+		for _, file := range files {
+			if strings.HasSuffix(file, "/") {
+				// Globs match directories too, which are not sources.
+				continue
+			}
+			labels.Includes = append(labels.Includes, bazelLabelForFile(ctx, file))
+		}
+	}
+
+	return labels
+}
+
+// bazelLabelForModule returns the dependency with the given name, which must be a dependency of the
+// module being converted, and its label.
+func bazelLabelForModule(ctx BazelConversionPathContext, name, tag string) (blueprint.Module, bazel.Label, bool) {
+	if tag != "" {
+		ctx.ModuleErrorf("output tag %q of module %q can't be converted to a Bazel label", tag, name)
+		return nil, bazel.Label{}, false
+	}
+
+	// Dependencies on modules in other namespaces are referenced as "//namespace:module", but are
+	// found by the name of the module.
+	depName := name
+	if strings.HasPrefix(depName, "//") {
+		depName = depName[strings.LastIndex(depName, ":")+1:]
+	}
+	module, _ := ctx.GetDirectDep(depName)
+	if module == nil {
+		ctx.ModuleErrorf("module %q must be a dependency to be converted to a Bazel label", name)
+		return nil, bazel.Label{}, false
+	}
+
+	label := bazel.Label{Label: bazelLabel(ctx, ctx.OtherModuleDir(module), ":"+ctx.OtherModuleName(module))}
+	return module, label, true
+}
+
+// bazelFilesForModuleReference returns the files that a ":module" reference to the module expands
+// to in Soong, relative to the root of the source tree if they are source files, and whether they
+// are the sources of a SourceFileProducer such as a filegroup.
+func bazelFilesForModuleReference(ctx BazelConversionPathContext, module blueprint.Module) ([]string, bool) {
+	if outProducer, ok := module.(OutputFileProducer); ok {
+		outputFiles, err := outProducer.OutputFiles("")
+		if err != nil {
+			ctx.ModuleErrorf("path dependency %q: %s", ctx.OtherModuleName(module), err)
+		}
+		return outputFiles.Strings(), false
+	}
+	if srcProducer, ok := module.(SourceFileProducer); ok {
+		var files []string
+		for _, src := range srcProducer.Srcs() {
+			files = append(files, Rel(ctx, ctx.Config().srcDir, src.String()))
+		}
+		return files, true
+	}
+	ctx.ModuleErrorf("path dependency %q is not a source file producing module", ctx.OtherModuleName(module))
+	return nil, false
+}
+
+// bazelLabelsForModuleReference returns the labels for the reference ref to module in a path
+// property, with the given excludes applied.
+func bazelLabelsForModuleReference(ctx BazelConversionPathContext, ref string, module blueprint.Module,
+	label bazel.Label, expandedExcludes []string) []bazel.Label {
+
+	files, isSrcs := bazelFilesForModuleReference(ctx, module)
+
+	var remaining []string
+	for _, file := range files {
+		if !InList(file, expandedExcludes) {
+			remaining = append(remaining, file)
+		}
+	}
+	if len(remaining) == len(files) {
+		return []bazel.Label{label}
+	}
+
+	if !isSrcs {
+		// The outputs of a module can't be referenced individually in Bazel, so the excludes can't
+		// be applied to them.
+		ctx.ModuleErrorf("path dependency %q: excluding some of the output files of a module can't be converted to Bazel", ref)
+		return nil
+	}
+
+	var labels []bazel.Label
+	for _, file := range remaining {
+		labels = append(labels, bazelLabelForFile(ctx, file))
+	}
+	return labels
+}
+
+// bazelLabelForFile returns the label of the source file at path, relative to the root of the
+// source tree.
+func bazelLabelForFile(ctx BazelConversionPathContext, path string) bazel.Label {
+	pkg := bazelPackageForFile(ctx, path)
+	return bazel.Label{Label: bazelLabel(ctx, pkg, Rel(ctx, pkg, path))}
+}
+
+// bazelPackageForFile returns the directory of the Bazel package that the file at path is in, which
+// is the package of the module being converted unless the file is in a subdirectory that is a
+// package of its own.
+func bazelPackageForFile(ctx BazelConversionPathContext, path string) string {
+	moduleDir := ctx.ModuleDir()
+	for dir := filepath.Dir(path); dir != moduleDir && dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		for _, marker := range bazelPackageMarkers {
+			markerPath := filepath.Join(ctx.Config().srcDir, dir, marker)
+			if exists, isDir, _ := ctx.Config().fs.Exists(markerPath); exists && !isDir {
+				return dir
+			}
+		}
+	}
+	if strings.HasPrefix(path, moduleDir+"/") || moduleDir == "." {
+		return moduleDir
+	}
+	return "."
+}
+
+// bazelLabel returns the label of target in the package in directory pkg, relative to the package
+// of the module being converted if it is the same package.
+func bazelLabel(ctx BazelConversionPathContext, pkg, target string) string {
+	if pkg == ctx.ModuleDir() {
+		return target
+	}
+	if pkg == "." {
+		pkg = ""
+	}
+	return "//" + pkg + ":" + strings.TrimPrefix(target, ":")
+}
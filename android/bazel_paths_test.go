@@ -0,0 +1,200 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/google/blueprint"
+
+	"android/soong/bazel"
+)
+
+type bazelPathsTestModule struct {
+	ModuleBase
+	props struct {
+		Srcs         []string `android:"path"`
+		Exclude_srcs []string `android:"path"`
+		Deps         []string
+	}
+
+	srcLabels bazel.LabelList
+	depLabels bazel.LabelList
+}
+
+type bazelPathsTestDepTag struct {
+	blueprint.BaseDependencyTag
+}
+
+func bazelPathsTestModuleFactory() Module {
+	m := &bazelPathsTestModule{}
+	m.AddProperties(&m.props)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *bazelPathsTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), bazelPathsTestDepTag{}, m.props.Deps...)
+}
+
+func (m *bazelPathsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.srcLabels = BazelLabelForSrcPatterns(ctx, m.props.Srcs, m.props.Exclude_srcs)
+	m.depLabels = BazelLabelForModuleDeps(ctx, m.props.Deps)
+}
+
+func labels(labels ...string) []bazel.Label {
+	var ret []bazel.Label
+	for _, label := range labels {
+		ret = append(ret, bazel.Label{Label: label})
+	}
+	return ret
+}
+
+func TestBazelLabels(t *testing.T) {
+	fooBp := `
+		test {
+			name: "foo",
+			srcs: ["a.c", "*.cc", "sub/*.c", ":fg"],
+			exclude_srcs: ["b.cc", ":excluded"],
+			deps: ["bar", "baz"],
+		}
+
+		test {
+			name: "baz",
+		}
+
+		filegroup {
+			name: "excluded",
+		}
+	`
+	fgBp := `
+		filegroup {
+			name: "fg",
+		}
+	`
+	barBp := `
+		test {
+			name: "bar",
+		}
+	`
+	mockFS := map[string][]byte{
+		"foo/Android.bp":     []byte(fooBp),
+		"foo/a.c":            nil,
+		"foo/b.cc":           nil,
+		"foo/c.cc":           nil,
+		"foo/sub/Android.bp": nil,
+		"foo/sub/d.c":        nil,
+		"fg/Android.bp":      []byte(fgBp),
+		"bar/Android.bp":     []byte(barBp),
+	}
+
+	config := TestConfig(buildDir, nil, "", mockFS)
+	ctx := NewTestContext(config)
+	ctx.RegisterModuleType("test", bazelPathsTestModuleFactory)
+	ctx.RegisterModuleType("filegroup", FileGroupFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"foo/Android.bp", "fg/Android.bp", "bar/Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	foo := ctx.ModuleForTests("foo", "").Module().(*bazelPathsTestModule)
+
+	expectedSrcs := bazel.LabelList{
+		Includes: labels("a.c", "c.cc", "//foo/sub:d.c", "//fg:fg"),
+		Excludes: labels(":excluded"),
+	}
+	if !reflect.DeepEqual(foo.srcLabels, expectedSrcs) {
+		t.Errorf("expected src labels %v, got %v", expectedSrcs, foo.srcLabels)
+	}
+
+	expectedDeps := bazel.LabelList{
+		Includes: labels("//bar:bar", ":baz"),
+	}
+	if !reflect.DeepEqual(foo.depLabels, expectedDeps) {
+		t.Errorf("expected dep labels %v, got %v", expectedDeps, foo.depLabels)
+	}
+}
+
+func TestBazelLabelsExcludes(t *testing.T) {
+	fooBp := `
+		test {
+			name: "foo",
+			srcs: ["**/*.c", ":fg", ":fg_all"],
+			exclude_srcs: ["b.c", ":excluded"],
+		}
+	`
+	fgBp := `
+		filegroup {
+			name: "fg",
+			srcs: ["fg1.c", "fg2.c"],
+		}
+
+		filegroup {
+			name: "fg_all",
+			srcs: ["fg3.c"],
+		}
+
+		filegroup {
+			name: "excluded",
+			srcs: ["fg2.c"],
+		}
+	`
+	mockFS := map[string][]byte{
+		"foo/Android.bp":     []byte(fooBp),
+		"foo/a.c":            nil,
+		"foo/b.c":            nil,
+		"foo/sub/c.c":        nil,
+		"foo/pkg/Android.bp": nil,
+		"foo/pkg/d.c":        nil,
+		"foo/pkg/inner/e.c":  nil,
+		"fg/Android.bp":      []byte(fgBp),
+		"fg/fg1.c":           nil,
+		"fg/fg2.c":           nil,
+		"fg/fg3.c":           nil,
+	}
+
+	config := TestConfig(buildDir, nil, "", mockFS)
+	ctx := NewTestContext(config)
+	ctx.RegisterModuleType("test", bazelPathsTestModuleFactory)
+	ctx.RegisterModuleType("filegroup", FileGroupFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"foo/Android.bp", "fg/Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	foo := ctx.ModuleForTests("foo", "").Module().(*bazelPathsTestModule)
+
+	got := foo.srcLabels
+	sort.Slice(got.Includes, func(i, j int) bool {
+		return got.Includes[i].Label < got.Includes[j].Label
+	})
+
+	// b.c is excluded from the glob, fg is expanded into the sources that aren't excluded, and
+	// fg_all, which has no excluded sources, keeps its label. The files in foo/pkg are in the
+	// package of foo/pkg/Android.bp, and the files in foo/sub in the package of foo.
+	expected := bazel.LabelList{
+		Includes: labels("//fg:fg1.c", "//fg:fg_all", "//foo/pkg:d.c", "//foo/pkg:inner/e.c", "a.c", "sub/c.c"),
+		Excludes: labels("//fg:excluded"),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected src labels %v, got %v", expected, got)
+	}
+}
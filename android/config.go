@@ -23,7 +23,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
@@ -168,18 +170,59 @@ func loadFromConfigFile(configurable jsonConfigurable, filename string) error {
 	} else if err != nil {
 		return fmt.Errorf("config file: could not open %s: %s", filename, err.Error())
 	} else {
-		// Make a decoder for it
-		jsonDecoder := json.NewDecoder(configFileReader)
-		err = jsonDecoder.Decode(configurable)
+		data, err := ioutil.ReadAll(configFileReader)
+		if err != nil {
+			return fmt.Errorf("config file: could not read %s: %s", filename, err.Error())
+		}
+		err = json.Unmarshal(data, configurable)
+		if err != nil {
+			return fmt.Errorf("config file: %s did not parse correctly: %s", filename, err.Error())
+		}
+
+		// Fields that don't exist in the configurable are silently dropped by the decoder, which
+		// would leave a misspelled variable at its default value.  Warn about them instead.
+		unknown, err := unknownConfigFields(configurable, data)
 		if err != nil {
 			return fmt.Errorf("config file: %s did not parse correctly: %s", filename, err.Error())
 		}
+		for _, field := range unknown {
+			fmt.Fprintf(os.Stderr, "warning: config file %s: unknown variable %q\n", filename, field)
+		}
 	}
 
 	// No error
 	return nil
 }
 
+// unknownConfigFields returns the sorted top level fields of a JSON configuration file that don't
+// correspond to a field of the configurable.  Field names are matched case-insensitively, like
+// encoding/json does when decoding.
+func unknownConfigFields(configurable jsonConfigurable, data []byte) ([]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	reflectType := reflect.TypeOf(configurable).Elem()
+	known := make(map[string]bool, reflectType.NumField())
+	for i := 0; i < reflectType.NumField(); i++ {
+		field := reflectType.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("json") == "-" {
+			continue
+		}
+		known[strings.ToLower(field.Name)] = true
+	}
+
+	var unknown []string
+	for field := range fields {
+		if !known[strings.ToLower(field)] {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
 // atomically writes the config file in case two copies of soong_build are running simultaneously
 // (for example, docs generation and ninja manifest generation)
 func saveToConfigFile(config jsonConfigurable, filename string) error {
@@ -448,6 +491,10 @@ func NewConfig(srcDir, buildDir string, moduleListFile string) (Config, error) {
 		return Config{}, fmt.Errorf("GcovCoverage and ClangCoverage cannot both be set")
 	}
 
+	if err := validatePrebuiltVersionPolicy(config.PrebuiltVersionPolicy()); err != nil {
+		return Config{}, err
+	}
+
 	config.productVariables.Native_coverage = proptools.BoolPtr(
 		Bool(config.productVariables.GcovCoverage) ||
 			Bool(config.productVariables.ClangCoverage))
@@ -578,9 +625,15 @@ func (c *config) CpPreserveSymlinksFlags() string {
 	}
 }
 
+// Getenv returns the value of an environment variable and adds a dependency on it, so that the
+// build manifest is regenerated when its value changes, unless the variable was registered with
+// RegisterEnvVarWithoutDependency.
 func (c *config) Getenv(key string) string {
 	var val string
 	var exists bool
+	if envVarsWithoutDeps[key] {
+		return c.env[key]
+	}
 	c.envLock.Lock()
 	defer c.envLock.Unlock()
 	if c.envDeps == nil {
@@ -609,6 +662,31 @@ func (c *config) IsEnvTrue(key string) bool {
 	return value == "1" || value == "y" || value == "yes" || value == "on" || value == "true"
 }
 
+// DepsAuditEnabled returns true if the dependencies declared by cc and java modules should be
+// audited against the headers and classes that they actually use.
+func (c *config) DepsAuditEnabled() bool {
+	return c.IsEnvTrue("SOONG_DEPS_AUDIT")
+}
+
+// TestAnnotationIndexEnabled returns true if java test modules should install an index of their
+// test classes and methods by filtering annotation.
+func (c *config) TestAnnotationIndexEnabled() bool {
+	return c.IsEnvTrue("SOONG_TEST_ANNOTATION_INDEX")
+}
+
+// GenruleSandboxInputs returns true if genrule commands should run with only their declared
+// inputs visible, so that undeclared dependencies fail the build instead of making incremental
+// builds flaky.
+func (c *config) GenruleSandboxInputs() bool {
+	return c.IsEnvTrue("SOONG_GENRULE_SANDBOX_INPUTS")
+}
+
+// VerboseRuleDescriptions returns true if the descriptions of build rules should list the full
+// variant of the module they belong to.
+func (c *config) VerboseRuleDescriptions() bool {
+	return c.IsEnvTrue("SOONG_VERBOSE_RULE_DESCRIPTIONS")
+}
+
 func (c *config) IsEnvFalse(key string) bool {
 	value := c.Getenv(key)
 	return value == "0" || value == "n" || value == "no" || value == "off" || value == "false"
@@ -796,6 +874,12 @@ func (c *config) UnbundledBuild() bool {
 	return Bool(c.productVariables.Unbundled_build)
 }
 
+// PrebuiltVersionPolicy returns which of the versioned prebuilts of a module are used instead of
+// the source module: "source", the default, "latest" or a version number.
+func (c *config) PrebuiltVersionPolicy() string {
+	return StringDefault(c.productVariables.PrebuiltVersionPolicy, "source")
+}
+
 // Returns true if building apps that aren't bundled with the platform.
 // UnbundledBuild() is always true when this is true.
 func (c *config) UnbundledBuildApps() bool {
@@ -977,7 +1061,20 @@ func (c *config) ExportedNamespaces() []string {
 }
 
 func (c *config) HostStaticBinaries() bool {
-	return Bool(c.productVariables.HostStaticBinaries)
+	return Bool(c.productVariables.HostStaticBinaries) || c.HostHermeticBinaries()
+}
+
+// SizeOptimizedLinking returns true if device binaries and shared libraries should be linked
+// with identical code folding and unused section garbage collection to reduce their size.
+func (c *config) SizeOptimizedLinking() bool {
+	return Bool(c.productVariables.SizeOptimizedLinking)
+}
+
+// HostHermeticBinaries returns true if host executables should be built as fully static,
+// stripped binaries with a reproducible version stamp, suitable for checking into prebuilts
+// or running in minimal containers.
+func (c *config) HostHermeticBinaries() bool {
+	return Bool(c.productVariables.HostHermeticBinaries)
 }
 
 func (c *config) UncompressPrivAppDex() bool {
@@ -1014,6 +1111,13 @@ func (c *config) DexpreoptGlobalConfig(ctx PathContext) ([]byte, error) {
 	return ioutil.ReadFile(absolutePath(path.String()))
 }
 
+// BootImageProfiles returns the paths to the boot-image-profile.txt files of the product, relative
+// to the root of the source tree. They are concatenated into the profile that guides the
+// compilation of the boot image.
+func (c *config) BootImageProfiles() []string {
+	return c.productVariables.BootImageProfiles
+}
+
 func (c *config) FrameworksBaseDirExists(ctx PathContext) bool {
 	return ExistentPathForSource(ctx, "frameworks", "base").Valid()
 }
@@ -1177,6 +1281,12 @@ func (c *deviceConfig) PgoAdditionalProfileDirs() []string {
 	return c.config.productVariables.PgoAdditionalProfileDirs
 }
 
+// PgoInstrumentBenchmarks returns the list of PGO benchmarks whose modules should be built with
+// profile instrumentation for this product, in addition to those listed in ANDROID_PGO_INSTRUMENT.
+func (c *deviceConfig) PgoInstrumentBenchmarks() []string {
+	return c.config.productVariables.PgoInstrumentBenchmarks
+}
+
 func (c *deviceConfig) VendorSepolicyDirs() []string {
 	return c.config.productVariables.BoardVendorSepolicyDirs
 }
@@ -1253,7 +1363,16 @@ func (c *config) CFIEnabledForPath(path string) bool {
 	if len(c.productVariables.CFIIncludePaths) == 0 {
 		return false
 	}
-	return HasAnyPrefix(path, c.productVariables.CFIIncludePaths)
+	return HasAnyPrefix(path, c.productVariables.CFIIncludePaths) && !c.CFIDisabledForPath(path)
+}
+
+// CFIDiagForPath returns true if CFI enabled modules under path should be built in diagnostic
+// mode, which reports CFI violations instead of aborting.
+func (c *config) CFIDiagForPath(path string) bool {
+	if len(c.productVariables.CFIDiagPaths) == 0 {
+		return false
+	}
+	return HasAnyPrefix(path, c.productVariables.CFIDiagPaths)
 }
 
 func (c *config) VendorConfig(name string) VendorConfig {
@@ -1336,6 +1455,26 @@ func (c *config) MissingUsesLibraries() []string {
 	return c.productVariables.MissingUsesLibraries
 }
 
+func (c *config) ReleaseFeatureFlagValues() []string {
+	return c.productVariables.Release_feature_flag_values
+}
+
+// EnforcePackageBoundaries returns true if modules in dir may only reference source paths in their
+// own package: under dir, but not in a subdirectory that contains its own Android.bp file, or
+// under one of the paths in PackageBoundariesAllowList.
+func (c *config) EnforcePackageBoundaries(dir string) bool {
+	for _, enforcedDir := range c.productVariables.EnforcePackageBoundariesDirs {
+		if isPathUnder(dir, enforcedDir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *config) PackageBoundariesAllowList() []string {
+	return c.productVariables.PackageBoundariesAllowList
+}
+
 func (c *deviceConfig) DeviceArch() string {
 	return String(c.config.productVariables.DeviceArch)
 }
@@ -1368,6 +1507,14 @@ func (c *deviceConfig) BoardMoveRecoveryResourcesToVendorBoot() bool {
 	return Bool(c.config.productVariables.BoardMoveRecoveryResourcesToVendorBoot)
 }
 
+func (c *deviceConfig) BoardRamdiskCompression() string {
+	return String(c.config.productVariables.BoardRamdiskCompression)
+}
+
+func (c *deviceConfig) BoardRamdiskCompressionLevel() *int64 {
+	return c.config.productVariables.BoardRamdiskCompressionLevel
+}
+
 func (c *deviceConfig) PlatformSepolicyVersion() string {
 	return String(c.config.productVariables.PlatformSepolicyVersion)
 }
@@ -80,6 +80,23 @@ func TestProductConfigAnnotations(t *testing.T) {
 	}
 }
 
+func TestUnknownConfigFields(t *testing.T) {
+	data := []byte(`{
+		"Platform_sdk_version": 30,
+		"platform_sdk_codename": "REL",
+		"Platform_sdk_verison": 31,
+		"Unbundled_bulid": true
+	}`)
+	unknown, err := unknownConfigFields(&productVariables{}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Platform_sdk_verison", "Unbundled_bulid"}
+	if !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("expected unknown fields %q, got %q", expected, unknown)
+	}
+}
+
 func TestMissingVendorConfig(t *testing.T) {
 	c := &config{}
 	if c.VendorConfig("test").Bool("not_set") {
@@ -132,3 +149,33 @@ func TestConfiguredJarList(t *testing.T) {
 		assertStringEquals(t, "apex1:jarA", list5.String())
 	})
 }
+
+func TestGetenvWithoutDependency(t *testing.T) {
+	RegisterEnvVarWithoutDependency("TEST_ENV_WITHOUT_DEPENDENCY")
+	defer delete(envVarsWithoutDeps, "TEST_ENV_WITHOUT_DEPENDENCY")
+
+	config := TestConfig(buildDir, map[string]string{
+		"TEST_ENV_WITH_DEPENDENCY":    "foo",
+		"TEST_ENV_WITHOUT_DEPENDENCY": "bar",
+	}, "", nil)
+
+	if g, w := config.Getenv("TEST_ENV_WITH_DEPENDENCY"), "foo"; g != w {
+		t.Errorf("expected %q, got %q", w, g)
+	}
+	if g, w := config.Getenv("TEST_ENV_WITHOUT_DEPENDENCY"), "bar"; g != w {
+		t.Errorf("expected %q, got %q", w, g)
+	}
+
+	envDeps := config.EnvDeps()
+	if _, ok := envDeps["TEST_ENV_WITH_DEPENDENCY"]; !ok {
+		t.Errorf("expected a dependency on TEST_ENV_WITH_DEPENDENCY, got %q", envDeps)
+	}
+	if _, ok := envDeps["TEST_ENV_WITHOUT_DEPENDENCY"]; ok {
+		t.Errorf("expected no dependency on TEST_ENV_WITHOUT_DEPENDENCY, got %q", envDeps)
+	}
+
+	// Variables without a dependency can still be read after the dependencies are frozen.
+	if g, w := config.Getenv("TEST_ENV_WITHOUT_DEPENDENCY"), "bar"; g != w {
+		t.Errorf("expected %q, got %q", w, g)
+	}
+}
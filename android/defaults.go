@@ -28,6 +28,8 @@ type defaultsDependencyTag struct {
 var DefaultsDepTag defaultsDependencyTag
 
 type defaultsProperties struct {
+	// Names of defaults modules whose properties are applied to this module.  A defaults module
+	// may itself list defaults, which are applied transitively before its own properties.
 	Defaults []string
 }
 
@@ -154,3 +154,38 @@ func TestDefaultsAllowMissingDependencies(t *testing.T) {
 	// TODO: missing transitive defaults is currently not handled
 	_ = missingTransitiveDefaults
 }
+
+func TestDefaultsCycle(t *testing.T) {
+	bp := `
+		defaults {
+			name: "a",
+			defaults: ["b"],
+		}
+
+		defaults {
+			name: "b",
+			defaults: ["a"],
+		}
+
+		test {
+			name: "foo",
+			defaults: ["a"],
+		}
+	`
+
+	config := TestConfig(buildDir, nil, bp, nil)
+
+	ctx := NewTestContext(config)
+
+	ctx.RegisterModuleType("test", defaultsTestModuleFactory)
+	ctx.RegisterModuleType("defaults", defaultsTestDefaultsFactory)
+
+	ctx.PreArchMutators(RegisterDefaultsPreArchMutators)
+
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfNoMatchingErrors(t, "encountered dependency cycle", errs)
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/google/blueprint/parser"
+)
+
+// When SOONG_JSON_DIAGNOSTICS is set to a file path, every error reported against a module with
+// ModuleErrorf or PropertyErrorf is also appended to that file as JSON, one line per module, along
+// with the position of the property in the Android.bp file and the suggested fix if the message
+// has one, so that IDEs and error aggregation services can consume Soong errors without parsing
+// its console output.
+// The errors are still reported on the console as usual.
+const jsonDiagnosticsEnvVar = "SOONG_JSON_DIAGNOSTICS"
+
+func init() {
+	// The location of the diagnostics doesn't affect the build graph.
+	RegisterEnvVarWithoutDependency(jsonDiagnosticsEnvVar)
+}
+
+// JsonDiagnostic holds the errors reported against a module.  The errors reported while
+// generating the build actions of a module are written together as one JsonDiagnostic, errors
+// reported by mutators are written as they are reported.
+type JsonDiagnostic struct {
+	Module string
+	Type   string
+	// Blueprints_file is the path of the Android.bp file that defines the module.
+	Blueprints_file string
+	Errors          []JsonDiagnosticError
+}
+
+// JsonDiagnosticError is a single error reported against a module.
+type JsonDiagnosticError struct {
+	Property string `json:",omitempty"`
+	// Line and Column are the position of the property in the Android.bp file, or of the module if
+	// the error isn't reported against a property.  They are 0 if the position isn't known.
+	Line    int `json:",omitempty"`
+	Column  int `json:",omitempty"`
+	Message string
+
+	// Suggestion is the part of the message that suggests how to fix the error, e.g.
+	// "use shared_libs instead", if it has one.
+	Suggestion string `json:",omitempty"`
+}
+
+// suggestionRegexp matches the part of an error message that suggests how to fix the error, like
+// ", use shared_libs instead" in "Bad flag: `-lfoo`, use shared_libs instead".
+var suggestionRegexp = regexp.MustCompile(
+	`(?:^|[,.;] )((?:[Uu]se|[Aa]dd|[Ss]et|[Rr]emove|[Ii]nstead use|[Dd]id you mean) .*)`)
+
+// suggestedFix returns the suggestion to fix the error in an error message, or "" if it doesn't
+// suggest one.
+func suggestedFix(message string) string {
+	match := suggestionRegexp.FindStringSubmatch(message)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSuffix(match[1], ".")
+}
+
+type jsonDiagnosticsWriter struct {
+	lock sync.Mutex
+	file *os.File
+
+	// parsed Blueprints files, used to find the positions of modules and properties.
+	blueprints map[string]*parser.File
+}
+
+var jsonDiagnosticsWriterKey = NewOnceKey("jsonDiagnosticsWriter")
+
+// jsonDiagnostics returns the writer for the diagnostics stream, or nil if it is disabled.  The
+// file is only created when the first error is reported.
+func jsonDiagnostics(config Config) *jsonDiagnosticsWriter {
+	return config.Once(jsonDiagnosticsWriterKey, func() interface{} {
+		path := config.Getenv(jsonDiagnosticsEnvVar)
+		if path == "" {
+			return (*jsonDiagnosticsWriter)(nil)
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open %s: %s\n", path, err)
+			return (*jsonDiagnosticsWriter)(nil)
+		}
+		return &jsonDiagnosticsWriter{file: file, blueprints: make(map[string]*parser.File)}
+	}).(*jsonDiagnosticsWriter)
+}
+
+// RemoveStaleJsonDiagnostics deletes the diagnostics written by a previous run, so that a
+// successful run doesn't leave its errors behind.
+func RemoveStaleJsonDiagnostics(config Config) {
+	if path := config.Getenv(jsonDiagnosticsEnvVar); path != "" {
+		os.Remove(path)
+	}
+}
+
+// reportJsonDiagnostic records an error for the diagnostics stream if it is enabled.  The error is
+// written immediately unless the context batches its errors, in which case it is written by
+// flushJsonDiagnostics.
+func reportJsonDiagnostic(ctx *earlyModuleContext, property string, message string) {
+	w := jsonDiagnostics(ctx.config)
+	if w == nil {
+		return
+	}
+
+	line, column := w.position(ctx.config, ctx.BlueprintsFile(), ctx.ModuleName(), property)
+	diagnosticError := JsonDiagnosticError{
+		Property:   property,
+		Line:       line,
+		Column:     column,
+		Message:    message,
+		Suggestion: suggestedFix(message),
+	}
+
+	if ctx.batchJsonDiagnostics {
+		ctx.jsonDiagnosticErrors = append(ctx.jsonDiagnosticErrors, diagnosticError)
+		return
+	}
+	w.write(ctx, []JsonDiagnosticError{diagnosticError})
+}
+
+// flushJsonDiagnostics writes the errors batched by the context as a single diagnostic.
+func flushJsonDiagnostics(ctx *earlyModuleContext) {
+	if len(ctx.jsonDiagnosticErrors) == 0 {
+		return
+	}
+	if w := jsonDiagnostics(ctx.config); w != nil {
+		w.write(ctx, ctx.jsonDiagnosticErrors)
+	}
+	ctx.jsonDiagnosticErrors = nil
+}
+
+func (w *jsonDiagnosticsWriter) write(ctx *earlyModuleContext, errors []JsonDiagnosticError) {
+	buf, err := json.Marshal(JsonDiagnostic{
+		Module:          ctx.ModuleName(),
+		Type:            ctx.ModuleType(),
+		Blueprints_file: ctx.BlueprintsFile(),
+		Errors:          errors,
+	})
+	if err != nil {
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.file.Write(append(buf, '\n'))
+}
+
+// position returns the line and column of a property of a module in a Blueprints file, or of the
+// module itself if property is empty.  Nested properties are separated by dots, e.g. "dist.tag".
+// If the property isn't set in the file, e.g. because it comes from a defaults module, the
+// position of the module is returned.  It returns zeros if the module can't be found.
+func (w *jsonDiagnosticsWriter) position(config Config, blueprintsFile, moduleName, property string) (line, column int) {
+	w.lock.Lock()
+	file, parsed := w.blueprints[blueprintsFile]
+	if !parsed {
+		file = parseBlueprintsFileForDiagnostics(config, blueprintsFile)
+		w.blueprints[blueprintsFile] = file
+	}
+	w.lock.Unlock()
+
+	if file == nil {
+		return 0, 0
+	}
+
+	for _, def := range file.Defs {
+		module, ok := def.(*parser.Module)
+		if !ok {
+			continue
+		}
+		if name, ok := module.GetProperty("name"); !ok || !isStringValue(name.Value, moduleName) {
+			continue
+		}
+
+		pos := module.TypePos
+		m := &module.Map
+		for _, part := range strings.Split(property, ".") {
+			if part == "" {
+				break
+			}
+			// Strip list indexes, e.g. "dists[0]".
+			if i := strings.IndexByte(part, '['); i >= 0 {
+				part = part[:i]
+			}
+			prop, ok := m.GetProperty(part)
+			if !ok {
+				break
+			}
+			pos = prop.NamePos
+			if m, ok = prop.Value.(*parser.Map); !ok {
+				break
+			}
+		}
+		return pos.Line, pos.Column
+	}
+	return 0, 0
+}
+
+func isStringValue(value parser.Expression, s string) bool {
+	str, ok := value.(*parser.String)
+	return ok && str.Value == s
+}
+
+// parseBlueprintsFileForDiagnostics parses a Blueprints file, or returns nil if it can't.  Errors
+// are ignored, they have already been reported when the file was parsed by Blueprint.
+func parseBlueprintsFileForDiagnostics(config Config, blueprintsFile string) *parser.File {
+	r, err := config.fs.Open(blueprintsFile)
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+
+	file, errs := parser.Parse(blueprintsFile, r, parser.NewScope(nil))
+	if len(errs) > 0 {
+		return nil
+	}
+	return file
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type diagnosticsTestModule struct {
+	ModuleBase
+	properties struct {
+		Srcs []string
+	}
+}
+
+func (m *diagnosticsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	ctx.PropertyErrorf("srcs", "unsupported source %q, use a .cpp file instead", m.properties.Srcs[0])
+	ctx.ModuleErrorf("module error")
+}
+
+func diagnosticsTestModuleFactory() Module {
+	module := &diagnosticsTestModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+func TestJsonDiagnostics(t *testing.T) {
+	bp := `
+		test {
+			name: "foo",
+			srcs: ["a.c"],
+		}
+	`
+
+	diagnosticsFile := filepath.Join(buildDir, "diagnostics.json")
+	config := TestConfig(buildDir, map[string]string{"SOONG_JSON_DIAGNOSTICS": diagnosticsFile}, bp, nil)
+
+	ctx := NewTestContext(config)
+	ctx.RegisterModuleType("test", diagnosticsTestModuleFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfNoMatchingErrors(t, `srcs: unsupported source "a.c", use a .cpp file instead`, errs)
+
+	buf, err := ioutil.ReadFile(diagnosticsFile)
+	if err != nil {
+		t.Fatalf("failed to read diagnostics: %s", err)
+	}
+
+	var diagnostics []JsonDiagnostic
+	for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+		var diagnostic JsonDiagnostic
+		if err := json.Unmarshal([]byte(line), &diagnostic); err != nil {
+			t.Fatalf("failed to parse diagnostic %q: %s", line, err)
+		}
+		diagnostics = append(diagnostics, diagnostic)
+	}
+
+	// Both errors are reported while generating the build actions, so they are batched into one
+	// diagnostic, with the positions of the srcs property and of the module.
+	expected := []JsonDiagnostic{
+		{
+			Module:          "foo",
+			Type:            "test",
+			Blueprints_file: "Android.bp",
+			Errors: []JsonDiagnosticError{
+				{
+					Property:   "srcs",
+					Line:       4,
+					Column:     4,
+					Message:    `unsupported source "a.c", use a .cpp file instead`,
+					Suggestion: "use a .cpp file instead",
+				},
+				{
+					Line:    2,
+					Column:  3,
+					Message: "module error",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, diagnostics) {
+		t.Errorf("incorrect diagnostics:\nwant: %#v\n got: %#v", expected, diagnostics)
+	}
+}
+
+func TestSuggestedFix(t *testing.T) {
+	for _, tc := range []struct {
+		message, suggestion string
+	}{
+		{"Bad flag: `-lfoo`, use shared_libs instead", "use shared_libs instead"},
+		{"Header file foo.h is not supported, instead use export_include_dirs or local_include_dirs.",
+			"instead use export_include_dirs or local_include_dirs"},
+		{"expected '(' after '$', did you mean $(location)?", "did you mean $(location)?"},
+		{"it is a prebuilt. Use a library with stubs instead", "Use a library with stubs instead"},
+		{`unsupported source "a.c"`, ""},
+		{"module error", ""},
+	} {
+		if got := suggestedFix(tc.message); got != tc.suggestion {
+			t.Errorf("suggestedFix(%q): expected %q, got %q", tc.message, tc.suggestion, got)
+		}
+	}
+}
@@ -99,6 +99,18 @@ func getenv(key string) string {
 	}
 }
 
+// envVarsWithoutDeps is the set of environment variables registered with
+// RegisterEnvVarWithoutDependency.
+var envVarsWithoutDeps = map[string]bool{}
+
+// RegisterEnvVarWithoutDependency marks an environment variable whose value doesn't affect the
+// generated build graph, for example one that only changes the verbosity of a tool.  Reading it
+// with ctx.Config().Getenv doesn't add a dependency on it, so changing its value doesn't cause
+// the build manifest to be regenerated.  It must be called from an init() function.
+func RegisterEnvVarWithoutDependency(key string) {
+	envVarsWithoutDeps[key] = true
+}
+
 func EnvSingleton() Singleton {
 	return &envSingleton{}
 }
@@ -89,3 +89,84 @@ func getMapping(s string, mapping func(string) (string, bool, error)) (string, b
 		return "", false, 0, fmt.Errorf("expected '(' after '$', did you mean $(%s)?", s[:i])
 	}
 }
+
+// ExpandLocations substitutes $(location <label>) and $(locations <label>) in the values of the
+// given property with the paths of the files referenced by label, which must be one of labels.
+// $(location) without a label refers to the only label when there is exactly one. Labels are
+// resolved with PathsForModuleSrc, so the labels of other modules must also be listed in a
+// property tagged with `android:"path"` for the dependencies on them to be added. Errors are
+// reported as property errors. It returns the expanded values along with the files referenced by
+// labels, which should be added as inputs of the rules that use the expanded values.
+func ExpandLocations(ctx ModuleContext, property string, values []string, labels []string) ([]string, Paths) {
+	return expandLocationsForModule(ctx, property, values, labels, Path.String)
+}
+
+// ExpandDataLocations is like ExpandLocations, but substitutes the paths of the files relative to
+// the directory they are installed in when they are the data files of a test, i.e. Path.Rel.  It
+// is used for properties that the test reads on the device, like the options in its test config.
+func ExpandDataLocations(ctx ModuleContext, property string, values []string, labels []string) ([]string, Paths) {
+	return expandLocationsForModule(ctx, property, values, labels, Path.Rel)
+}
+
+func expandLocationsForModule(ctx ModuleContext, property string, values []string, labels []string,
+	pathString func(Path) string) ([]string, Paths) {
+
+	locations := make(map[string]Paths, len(labels))
+	var deps Paths
+	for _, label := range labels {
+		if _, exists := locations[label]; exists {
+			continue
+		}
+		paths := PathsForModuleSrc(ctx, []string{label})
+		locations[label] = paths
+		deps = append(deps, paths...)
+	}
+
+	ret := make([]string, 0, len(values))
+	for _, value := range values {
+		expanded, err := expandLocations(value, labels, locations, pathString)
+		if err != nil {
+			ctx.PropertyErrorf(property, "%s", err)
+		}
+		ret = append(ret, expanded)
+	}
+	return ret, deps
+}
+
+func expandLocations(s string, labels []string, locations map[string]Paths,
+	pathString func(Path) string) (string, error) {
+
+	return Expand(s, func(name string) (string, error) {
+		var label string
+		multiple := false
+		switch {
+		case name == "location":
+			if len(labels) != 1 {
+				return "", fmt.Errorf("$(location) requires exactly one label, use $(location <label>) with one of %q",
+					labels)
+			}
+			label = labels[0]
+		case strings.HasPrefix(name, "location "):
+			label = strings.TrimSpace(strings.TrimPrefix(name, "location "))
+		case strings.HasPrefix(name, "locations "):
+			label = strings.TrimSpace(strings.TrimPrefix(name, "locations "))
+			multiple = true
+		default:
+			return "", fmt.Errorf("unknown variable '$(%s)'", name)
+		}
+
+		paths, ok := locations[label]
+		if !ok {
+			return "", fmt.Errorf("unknown location label %q, expecting one of %q", label, labels)
+		}
+		if !multiple && len(paths) != 1 {
+			return "", fmt.Errorf("label %q has %d files, use $(locations %s) to reference it",
+				label, len(paths), label)
+		}
+		var ret []string
+		for _, path := range paths {
+			ret = append(ret, pathString(path))
+		}
+		return strings.Join(ret, " "), nil
+	})
+}
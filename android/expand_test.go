@@ -190,3 +190,65 @@ func TestExpandNinjaEscaped(t *testing.T) {
 		}
 	}
 }
+
+func TestExpandLocations(t *testing.T) {
+	labels := []string{"a.txt", ":gen"}
+	locations := map[string]Paths{
+		"a.txt": PathsForTesting("dir/a.txt"),
+		":gen":  PathsForTesting("out/gen/x.txt", "out/gen/y.txt"),
+	}
+
+	testCases := []struct {
+		in     string
+		labels []string
+		out    string
+		err    string
+	}{
+		{
+			in:  "--file=$(location a.txt)",
+			out: "--file=dir/a.txt",
+		},
+		{
+			in:  "$(locations :gen) $(locations a.txt)",
+			out: "out/gen/x.txt out/gen/y.txt dir/a.txt",
+		},
+		{
+			in:     "$(location)",
+			labels: []string{"a.txt"},
+			out:    "dir/a.txt",
+		},
+		{
+			in:  "$(location)",
+			err: `$(location) requires exactly one label, use $(location <label>) with one of ["a.txt" ":gen"]`,
+		},
+		{
+			in:  "$(location :gen)",
+			err: `label ":gen" has 2 files, use $(locations :gen) to reference it`,
+		},
+		{
+			in:  "$(location b.txt)",
+			err: `unknown location label "b.txt", expecting one of ["a.txt" ":gen"]`,
+		},
+		{
+			in:  "$(genDir)",
+			err: `unknown variable '$(genDir)'`,
+		},
+	}
+
+	for _, test := range testCases {
+		testLabels := labels
+		if test.labels != nil {
+			testLabels = test.labels
+		}
+		got, err := expandLocations(test.in, testLabels, locations, Path.String)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%q: expected error %q, got %v", test.in, test.err, err)
+			}
+		} else if err != nil {
+			t.Errorf("%q: unexpected error %s", test.in, err.Error())
+		} else if got != test.out {
+			t.Errorf("%q: expected %q, got %q", test.in, test.out, got)
+		}
+	}
+}
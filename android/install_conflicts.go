@@ -0,0 +1,117 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+func init() {
+	RegisterSingletonType("install_conflicts", installConflictsSingletonFactory)
+}
+
+// OverridingModule is implemented by modules that replace other modules, usually through an
+// overrides property.  A module is allowed to install to the same path as a module it overrides,
+// directly or through a chain of overrides, as only one of them ends up in the image.
+type OverridingModule interface {
+	Overrides() []string
+}
+
+// moduleOverrides maps the names of modules to the names of the modules they override.
+type moduleOverrides map[string][]string
+
+func (o moduleOverrides) add(name string, module Module) {
+	if overriding, ok := module.(OverridingModule); ok {
+		o[name] = FirstUniqueStrings(append(o[name], overriding.Overrides()...))
+	}
+}
+
+// overrides returns true if module a overrides module b, directly or through other modules.
+func (o moduleOverrides) overrides(a, b string) bool {
+	seen := map[string]bool{a: true}
+	queue := []string{a}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, overridden := range o[name] {
+			if overridden == b {
+				return true
+			}
+			if !seen[overridden] {
+				seen[overridden] = true
+				queue = append(queue, overridden)
+			}
+		}
+	}
+	return false
+}
+
+// conflicts returns true if modules a and b may not install a file to the same path, i.e. they are
+// different modules and neither of them overrides the other.
+func (o moduleOverrides) conflicts(a, b string) bool {
+	return a != b && !o.overrides(a, b) && !o.overrides(b, a)
+}
+
+func installConflictsSingletonFactory() Singleton {
+	return &installConflictsSingleton{}
+}
+
+// installConflictsSingleton reports an error when two different modules install a file to the
+// same path on the device or host through different output paths, e.g. one of them installed
+// directly by Soong and the other one through Make.  Ninja can't see those conflicts, which would
+// otherwise be resolved by whichever install happens to run last.  Modules that write the same
+// output path are already reported by ninja as multiple rules generating the same output.
+type installConflictsSingleton struct{}
+
+type installer struct {
+	name string
+	path InstallPath
+}
+
+func (s *installConflictsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	installers := make(map[string][]installer)
+	overrides := make(moduleOverrides)
+
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() {
+			return
+		}
+		name := ctx.ModuleName(module)
+		overrides.add(name, module)
+		for _, installed := range module.FilesToInstall() {
+			// The path relative to the output directory is the same whether the file is installed
+			// by Soong or by Make.
+			installers[installed.basePath.path] = append(installers[installed.basePath.path],
+				installer{name, installed})
+		}
+	})
+
+	for _, path := range SortedStringKeys(installers) {
+		if conflict := firstInstallConflict(installers[path], overrides); conflict != nil {
+			ctx.Errorf("modules %q and %q both install to %q, as %q and %q", conflict[0].name,
+				conflict[1].name, path, conflict[0].path.String(), conflict[1].path.String())
+		}
+	}
+}
+
+// firstInstallConflict returns the first pair of installers of different modules that write
+// different output paths, or nil if there are none.
+func firstInstallConflict(installers []installer, overrides moduleOverrides) []installer {
+	for i := 0; i < len(installers); i++ {
+		for j := i + 1; j < len(installers); j++ {
+			a, b := installers[i], installers[j]
+			if a.path.String() != b.path.String() && overrides.conflicts(a.name, b.name) {
+				return []installer{a, b}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type installConflictsTestModule struct {
+	ModuleBase
+	props struct {
+		Install_name *string
+		Overrides    []string
+
+		// Install the file to Make's output directory instead of Soong's.
+		Make_install *bool
+	}
+}
+
+func installConflictsTestModuleFactory() Module {
+	m := &installConflictsTestModule{}
+	m.AddProperties(&m.props)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *installConflictsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	builtFile := PathForModuleOut(ctx, m.Name())
+	installDir := PathForModuleInstall(ctx, "bin")
+	if Bool(m.props.Make_install) {
+		installDir = installDir.ToMakePath()
+	}
+	ctx.InstallFile(installDir, String(m.props.Install_name), builtFile)
+}
+
+func (m *installConflictsTestModule) Overrides() []string {
+	return m.props.Overrides
+}
+
+func testInstallConflicts(t *testing.T, bp string) []error {
+	t.Helper()
+	config := TestConfig(buildDir, nil, bp, nil)
+
+	ctx := NewTestContext(config)
+	ctx.RegisterModuleType("test_install", installConflictsTestModuleFactory)
+	ctx.RegisterSingletonType("install_conflicts", installConflictsSingletonFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	return errs
+}
+
+func TestInstallConflicts(t *testing.T) {
+	errs := testInstallConflicts(t, `
+		test_install {
+			name: "foo",
+			install_name: "tool",
+		}
+
+		test_install {
+			name: "bar",
+			install_name: "tool",
+			make_install: true,
+		}
+
+		test_install {
+			name: "baz",
+			install_name: "baz",
+		}
+	`)
+	FailIfNoMatchingErrors(t, `modules "(foo|bar)" and "(foo|bar)" both install to "target/product/test_device/system/bin/tool"`, errs)
+}
+
+func TestInstallConflictsSameOutput(t *testing.T) {
+	// Modules that write the same output path are reported by ninja.
+	errs := testInstallConflicts(t, `
+		test_install {
+			name: "foo",
+			install_name: "tool",
+		}
+
+		test_install {
+			name: "bar",
+			install_name: "tool",
+		}
+	`)
+	FailIfErrored(t, errs)
+}
+
+func TestInstallConflictsOverrides(t *testing.T) {
+	errs := testInstallConflicts(t, `
+		test_install {
+			name: "foo",
+			install_name: "tool",
+			overrides: ["bar"],
+		}
+
+		test_install {
+			name: "bar",
+			install_name: "tool",
+			make_install: true,
+			overrides: ["baz"],
+		}
+
+		test_install {
+			name: "baz",
+			install_name: "tool",
+			make_install: true,
+		}
+	`)
+	FailIfErrored(t, errs)
+}
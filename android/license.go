@@ -0,0 +1,86 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package android
+
+import (
+	"github.com/google/blueprint"
+)
+
+type licenseKindDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+var (
+	licenseKindTag = licenseKindDependencyTag{}
+)
+
+func init() {
+	RegisterLicenseBuildComponents(InitRegistrationContext)
+}
+
+// Register the license module type.
+func RegisterLicenseBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterModuleType("license", LicenseFactory)
+}
+
+type licenseProperties struct {
+	// Specifies the kinds of license that apply.
+	License_kinds []string
+	// Specifies a short copyright notice to use for the license.
+	Copyright_notice *string
+	// Specifies the package name to which the license applies.
+	Package_name *string
+	// Specifies where the license text can be found.
+	License_text []string `android:"path"`
+}
+
+type licenseModule struct {
+	ModuleBase
+
+	properties licenseProperties
+
+	// The conditions of the kinds of the license, set in GenerateAndroidBuildActions.
+	conditions []string
+	// The license texts, set in GenerateAndroidBuildActions.
+	licenseTexts Paths
+}
+
+func (m *licenseModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), licenseKindTag, m.properties.License_kinds...)
+}
+
+func (m *licenseModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	var conditions []string
+	ctx.VisitDirectDepsWithTag(licenseKindTag, func(dep Module) {
+		if kind, ok := dep.(*licenseKindModule); ok {
+			conditions = append(conditions, kind.properties.Conditions...)
+		} else {
+			ctx.PropertyErrorf("license_kinds", "%q is not a license_kind module", ctx.OtherModuleName(dep))
+		}
+	})
+	m.conditions = SortedUniqueStrings(conditions)
+	m.licenseTexts = PathsForModuleSrc(ctx, m.properties.License_text)
+}
+
+// license describes the license of a package, i.e. its kinds, its texts and the package it applies
+// to.  Modules refer to the licenses that apply to them with the applicable_licenses property, or
+// through the default_applicable_licenses property of their package.
+func LicenseFactory() Module {
+	module := &licenseModule{}
+
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+
+	return module
+}
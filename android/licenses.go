@@ -0,0 +1,221 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package android
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/google/blueprint"
+)
+
+// Attaches licenses to modules.
+//
+// Multi stage process:
+// * First stage works bottom up, after defaults expansion, to record the default_applicable_licenses
+//   of each package in a map by package id.
+//
+// * Second stage works bottom up to add dependencies from each module onto the license modules in
+//   its applicable_licenses property or, if it does not have any, in the
+//   default_applicable_licenses property of its closest ancestor package that has one.  The
+//   dependencies are subject to visibility enforcement like any other dependency.
+//
+// * Finally, in GenerateBuildActions, the information of the license modules, including the
+//   conditions of their license_kind modules, is flattened into a LicenseInfo provided by the
+//   module.  Packaging modules use it to write the license metadata of their contents, see
+//   BuildLicenseMetadata.
+
+type licensesDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+// Licenses are not part of the contents of an apex.
+func (l licensesDependencyTag) ExcludeFromApexContents() {}
+
+// Licenses annotate a module, they are the same for the source and the prebuilt of the module.
+func (l licensesDependencyTag) ReplaceSourceWithPrebuilt() bool {
+	return false
+}
+
+var (
+	licensesTag = licensesDependencyTag{}
+
+	_ ExcludeFromApexContentsTag = licensesTag
+	_ ReplaceSourceWithPrebuilt  = licensesTag
+)
+
+// LicenseInfo describes the licenses that apply to a module.
+type LicenseInfo struct {
+	// The names of the license modules that apply to the module.
+	Licenses []string
+
+	// The kinds of the licenses, e.g. SPDX-license-identifier-Apache-2.0.
+	Kinds []string
+
+	// The conditions of the kinds of the licenses, e.g. notice.
+	Conditions []string
+
+	// The name of the package to which the licenses apply, if any.
+	PackageName string
+
+	// The license texts.
+	Texts Paths
+}
+
+var LicenseInfoProvider = blueprint.NewProvider(LicenseInfo{})
+
+func RegisterLicensesPackageMapper(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("licensesPackageMapper", licensesPackageMapper).Parallel()
+}
+
+func RegisterLicensesPropertyGatherer(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("licensesPropertyGatherer", licensesPropertyGatherer).Parallel()
+}
+
+var packageToLicensesMapKey = NewOnceKey("packageToLicensesMap")
+
+// The map from package id to the default_applicable_licenses of the package.
+func packageToLicensesMap(config Config) *sync.Map {
+	return config.Once(packageToLicensesMapKey, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+}
+
+func licensesPackageMapper(ctx BottomUpMutatorContext) {
+	p, ok := ctx.Module().(*packageModule)
+	if !ok || p.properties.Default_applicable_licenses == nil {
+		return
+	}
+
+	packageToLicensesMap(ctx.Config()).Store(p.qualifiedModuleId(ctx), p.properties.Default_applicable_licenses)
+
+	// Depend on the licenses so that unknown or invisible licenses are reported against the
+	// package.
+	ctx.AddDependency(ctx.Module(), licensesTag, p.properties.Default_applicable_licenses...)
+}
+
+func licensesPropertyGatherer(ctx BottomUpMutatorContext) {
+	m, ok := ctx.Module().(Module)
+	if !ok {
+		return
+	}
+	switch m.(type) {
+	case *packageModule, *licenseModule, *licenseKindModule, Defaults:
+		return
+	}
+
+	licenses := m.base().commonProperties.Applicable_licenses
+	if licenses == nil {
+		licenses = packageDefaultLicenses(ctx.Config(), m.qualifiedModuleId(ctx))
+	}
+	ctx.AddDependency(ctx.Module(), licensesTag, licenses...)
+}
+
+// packageDefaultLicenses returns the default_applicable_licenses of the closest ancestor package
+// of the module that specifies them.
+func packageDefaultLicenses(config Config, moduleId qualifiedModuleName) []string {
+	packageToLicenses := packageToLicensesMap(config)
+	packageQualifiedId := moduleId.getContainingPackageId()
+	for {
+		value, ok := packageToLicenses.Load(packageQualifiedId)
+		if ok {
+			return value.([]string)
+		}
+
+		if packageQualifiedId.isRootPackage() {
+			return nil
+		}
+
+		packageQualifiedId = packageQualifiedId.getContainingPackageId()
+	}
+}
+
+// gatherLicenseInfo flattens the information of the license modules the module depends on into
+// the LicenseInfoProvider of the module.
+func (m *ModuleBase) gatherLicenseInfo(ctx ModuleContext) {
+	var info LicenseInfo
+	ctx.VisitDirectDepsWithTag(licensesTag, func(dep Module) {
+		l, ok := dep.(*licenseModule)
+		if !ok {
+			ctx.PropertyErrorf("applicable_licenses", "%q is not a license module", ctx.OtherModuleName(dep))
+			return
+		}
+		info.Licenses = append(info.Licenses, ctx.OtherModuleName(dep))
+		info.Kinds = append(info.Kinds, l.properties.License_kinds...)
+		info.Conditions = append(info.Conditions, l.conditions...)
+		info.Texts = append(info.Texts, l.licenseTexts...)
+		if info.PackageName == "" {
+			info.PackageName = String(l.properties.Package_name)
+		}
+	})
+	if len(info.Licenses) == 0 {
+		return
+	}
+
+	info.Licenses = SortedUniqueStrings(info.Licenses)
+	info.Kinds = SortedUniqueStrings(info.Kinds)
+	info.Conditions = SortedUniqueStrings(info.Conditions)
+	info.Texts = FirstUniquePaths(info.Texts)
+	ctx.SetProvider(LicenseInfoProvider, info)
+}
+
+// licenseMetadata is the entry of a module in a license metadata file.
+type licenseMetadata struct {
+	Module      string   `json:"module"`
+	Licenses    []string `json:"licenses,omitempty"`
+	Kinds       []string `json:"license_kinds,omitempty"`
+	Conditions  []string `json:"license_conditions,omitempty"`
+	PackageName string   `json:"package_name,omitempty"`
+	Texts       []string `json:"license_texts,omitempty"`
+}
+
+// BuildLicenseMetadata writes a license metadata file for a packaging module, i.e. a JSON file that
+// lists the licenses of the packaging module itself and of each of the given modules packaged in
+// it.  Variants of a module are merged into a single entry, and modules without licenses are listed
+// without any so that unlicensed contents can be found.
+func BuildLicenseMetadata(ctx ModuleContext, contents []Module) OutputPath {
+	entries := make(map[string]*licenseMetadata)
+	add := func(name string, info LicenseInfo) {
+		entry, ok := entries[name]
+		if !ok {
+			entry = &licenseMetadata{Module: name}
+			entries[name] = entry
+		}
+		entry.Licenses = SortedUniqueStrings(append(entry.Licenses, info.Licenses...))
+		entry.Kinds = SortedUniqueStrings(append(entry.Kinds, info.Kinds...))
+		entry.Conditions = SortedUniqueStrings(append(entry.Conditions, info.Conditions...))
+		if entry.PackageName == "" {
+			entry.PackageName = info.PackageName
+		}
+		entry.Texts = FirstUniqueStrings(append(entry.Texts, info.Texts.Strings()...))
+	}
+
+	add(ctx.ModuleName(), ctx.Provider(LicenseInfoProvider).(LicenseInfo))
+	for _, m := range contents {
+		add(ctx.OtherModuleName(m), ctx.OtherModuleProvider(m, LicenseInfoProvider).(LicenseInfo))
+	}
+
+	var metadata []licenseMetadata
+	for _, name := range SortedStringKeys(entries) {
+		metadata = append(metadata, *entries[name])
+	}
+	content, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		ctx.ModuleErrorf("failed to marshal license metadata: %s", err)
+	}
+
+	out := PathForModuleOut(ctx, "license_metadata.json").OutputPath
+	WriteFileRule(ctx, out, string(content))
+	return out
+}
@@ -0,0 +1,349 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package android
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testLicenses(t *testing.T, fs map[string][]byte) (*TestContext, []error) {
+	t.Helper()
+
+	// Create a new config per test as license information is stored in the config.
+	config := TestArchConfig(buildDir, nil, "", fs)
+
+	ctx := NewTestArchContext(config)
+	ctx.RegisterModuleType("mock_library", newMockLibraryModule)
+	ctx.RegisterModuleType("mock_defaults", defaultsFactory)
+	ctx.RegisterModuleType("component", componentTestModuleFactory)
+	ctx.RegisterModuleType("package_module", packageTestModuleFactory)
+
+	// Order of the following method calls is significant.
+	RegisterPackageBuildComponents(ctx)
+	RegisterLicenseKindBuildComponents(ctx)
+	RegisterLicenseBuildComponents(ctx)
+	ctx.PreArchMutators(RegisterVisibilityRuleChecker)
+	ctx.PreArchMutators(RegisterDefaultsPreArchMutators)
+	ctx.PreArchMutators(RegisterVisibilityRuleGatherer)
+	ctx.PreArchMutators(RegisterLicensesPackageMapper)
+	ctx.PreArchMutators(RegisterLicensesPropertyGatherer)
+	ctx.PostDepsMutators(RegisterVisibilityRuleEnforcer)
+	ctx.Register()
+
+	_, errs := ctx.ParseBlueprintsFiles(".")
+	if len(errs) > 0 {
+		return ctx, errs
+	}
+
+	_, errs = ctx.PrepareBuildActions(config)
+	return ctx, errs
+}
+
+var licensesTestKinds = []byte(`
+	license_kind {
+		name: "notice_kind",
+		conditions: ["notice"],
+	}
+
+	license_kind {
+		name: "restricted_kind",
+		conditions: ["restricted"],
+	}`)
+
+func TestLicenses(t *testing.T) {
+	testCases := []struct {
+		name             string
+		fs               map[string][]byte
+		expectedErrors   []string
+		expectedLicenses map[string]LicenseInfo
+		expectedTexts    map[string][]string
+	}{
+		{
+			name: "applicable_licenses",
+			fs: map[string][]byte{
+				"kinds/Blueprints": licensesTestKinds,
+				"top/LICENSE":      nil,
+				"top/Blueprints": []byte(`
+					license {
+						name: "top_license",
+						license_kinds: ["notice_kind", "restricted_kind"],
+						package_name: "top",
+						license_text: ["LICENSE"],
+					}
+
+					mock_library {
+						name: "foo",
+						applicable_licenses: ["top_license"],
+					}
+
+					mock_library {
+						name: "bar",
+					}`),
+			},
+			expectedLicenses: map[string]LicenseInfo{
+				"foo": {
+					Licenses:    []string{"top_license"},
+					Kinds:       []string{"notice_kind", "restricted_kind"},
+					Conditions:  []string{"notice", "restricted"},
+					PackageName: "top",
+				},
+				"bar": {},
+			},
+			expectedTexts: map[string][]string{
+				"foo": {"top/LICENSE"},
+			},
+		},
+		{
+			name: "default_applicable_licenses",
+			fs: map[string][]byte{
+				"kinds/Blueprints": licensesTestKinds,
+				"top/Blueprints": []byte(`
+					package {
+						default_applicable_licenses: ["top_license"],
+					}
+
+					license {
+						name: "top_license",
+						license_kinds: ["notice_kind"],
+					}
+
+					license {
+						name: "other_license",
+						license_kinds: ["restricted_kind"],
+					}
+
+					mock_library {
+						name: "foo",
+					}
+
+					mock_library {
+						name: "bar",
+						applicable_licenses: ["other_license"],
+					}`),
+				"top/nested/Blueprints": []byte(`
+					mock_library {
+						name: "baz",
+					}`),
+			},
+			expectedLicenses: map[string]LicenseInfo{
+				"foo": {
+					Licenses:   []string{"top_license"},
+					Kinds:      []string{"notice_kind"},
+					Conditions: []string{"notice"},
+				},
+				"bar": {
+					Licenses:   []string{"other_license"},
+					Kinds:      []string{"restricted_kind"},
+					Conditions: []string{"restricted"},
+				},
+				"baz": {
+					Licenses:   []string{"top_license"},
+					Kinds:      []string{"notice_kind"},
+					Conditions: []string{"notice"},
+				},
+			},
+		},
+		{
+			name: "applicable_licenses from defaults",
+			fs: map[string][]byte{
+				"kinds/Blueprints": licensesTestKinds,
+				"top/Blueprints": []byte(`
+					license {
+						name: "top_license",
+						license_kinds: ["notice_kind"],
+					}
+
+					mock_defaults {
+						name: "defaults",
+						applicable_licenses: ["top_license"],
+					}
+
+					mock_library {
+						name: "foo",
+						defaults: ["defaults"],
+					}`),
+			},
+			expectedLicenses: map[string]LicenseInfo{
+				"foo": {
+					Licenses:   []string{"top_license"},
+					Kinds:      []string{"notice_kind"},
+					Conditions: []string{"notice"},
+				},
+			},
+		},
+		{
+			name: "not a license",
+			fs: map[string][]byte{
+				"kinds/Blueprints": licensesTestKinds,
+				"top/Blueprints": []byte(`
+					mock_library {
+						name: "foo",
+						applicable_licenses: ["bar"],
+					}
+
+					mock_library {
+						name: "bar",
+					}`),
+			},
+			expectedErrors: []string{
+				`module "foo" variant "android_common": applicable_licenses: "bar" is not a license module`,
+			},
+		},
+		{
+			name: "not a license_kind",
+			fs: map[string][]byte{
+				"kinds/Blueprints": licensesTestKinds,
+				"top/Blueprints": []byte(`
+					license {
+						name: "top_license",
+						license_kinds: ["other_license"],
+					}
+
+					license {
+						name: "other_license",
+					}`),
+			},
+			expectedErrors: []string{
+				`module "top_license": license_kinds: "other_license" is not a license_kind module`,
+			},
+		},
+		{
+			name: "invisible license",
+			fs: map[string][]byte{
+				"kinds/Blueprints": licensesTestKinds,
+				"top/Blueprints": []byte(`
+					license {
+						name: "top_license",
+						license_kinds: ["notice_kind"],
+						visibility: ["//visibility:private"],
+					}`),
+				"other/Blueprints": []byte(`
+					mock_library {
+						name: "foo",
+						applicable_licenses: ["top_license"],
+					}`),
+			},
+			expectedErrors: []string{
+				`module "foo" variant "android_common": depends on //top:top_license which is not visible to this module`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, errs := testLicenses(t, tc.fs)
+
+			if len(tc.expectedErrors) > 0 {
+				for _, expectedError := range tc.expectedErrors {
+					FailIfNoMatchingErrors(t, expectedError, errs)
+				}
+				return
+			}
+			FailIfErrored(t, errs)
+
+			for name, expected := range tc.expectedLicenses {
+				m := ctx.ModuleForTests(name, "android_common").Module()
+				actual := ctx.ModuleProvider(m, LicenseInfoProvider).(LicenseInfo)
+				if g, w := actual.Texts.Strings(), tc.expectedTexts[name]; !reflect.DeepEqual(g, w) {
+					t.Errorf("%s: expected license texts %q, got %q", name, w, g)
+				}
+				actual.Texts = nil
+				if !reflect.DeepEqual(actual, expected) {
+					t.Errorf("%s: expected licenses %#v, got %#v", name, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestLicenseMetadata(t *testing.T) {
+	ctx, errs := testLicenses(t, map[string][]byte{
+		"kinds/Blueprints": licensesTestKinds,
+		"top/LICENSE":      nil,
+		"top/Blueprints": []byte(`
+			package {
+				default_applicable_licenses: ["top_license"],
+			}
+
+			license {
+				name: "top_license",
+				license_kinds: ["notice_kind"],
+				license_text: ["LICENSE"],
+			}
+
+			package_module {
+				name: "package",
+				deps: ["foo"],
+			}`),
+		"other/Blueprints": []byte(`
+			license {
+				name: "other_license",
+				license_kinds: ["restricted_kind"],
+				package_name: "other",
+			}
+
+			component {
+				name: "foo",
+				deps: ["bar"],
+				applicable_licenses: ["other_license"],
+			}
+
+			component {
+				name: "bar",
+			}`),
+	})
+	FailIfErrored(t, errs)
+
+	p := ctx.ModuleForTests("package", "android_common")
+	licenseMetadata := p.Module().(*packageTestModule).licenseMetadata
+	actual := ContentFromFileRuleForTests(t, p.Output(licenseMetadata.String()))
+	expected := `[
+  {
+    "module": "bar"
+  },
+  {
+    "module": "foo",
+    "licenses": [
+      "other_license"
+    ],
+    "license_kinds": [
+      "restricted_kind"
+    ],
+    "license_conditions": [
+      "restricted"
+    ],
+    "package_name": "other"
+  },
+  {
+    "module": "package",
+    "licenses": [
+      "top_license"
+    ],
+    "license_kinds": [
+      "notice_kind"
+    ],
+    "license_conditions": [
+      "notice"
+    ],
+    "license_texts": [
+      "top/LICENSE"
+    ]
+  }
+]
+`
+	if actual != expected {
+		t.Errorf("expected license metadata:\n%s\ngot:\n%s", expected, actual)
+	}
+}
@@ -17,8 +17,12 @@ package android
 import (
 	"io/ioutil"
 	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/google/blueprint"
 
 	soong_metrics_proto "android/soong/ui/metrics/metrics_proto"
 )
@@ -55,6 +59,135 @@ func (soongMetricsSingleton) GenerateBuildActions(ctx SingletonContext) {
 	})
 }
 
+var soongMetricsEventsOnceKey = NewOnceKey("soong metrics events")
+
+// soongMetricsEvents records the time spent in each mutator and singleton, and in globs.  The
+// lock only protects the list of events, each event is updated atomically so that mutators running
+// in parallel don't contend on it.
+type soongMetricsEvents struct {
+	sync.Mutex
+	events []*soongMetricsEvent
+
+	glob *soongMetricsEvent
+}
+
+// soongMetricsEvent is the time spent in a mutator, a singleton or in globs.  The times are in
+// nanoseconds since the Unix epoch, and are only accessed atomically.
+type soongMetricsEvent struct {
+	desc, name string
+	start      int64
+	end        int64
+	duration   int64
+}
+
+func soongMetricsEventsForConfig(config Config) *soongMetricsEvents {
+	return config.Once(soongMetricsEventsOnceKey, func() interface{} {
+		e := &soongMetricsEvents{}
+		e.glob = e.event("glob", "glob")
+		return e
+	}).(*soongMetricsEvents)
+}
+
+// event returns a new event.  It is called once per mutator, singleton or kind of event, not once
+// per call.
+func (e *soongMetricsEvents) event(desc, name string) *soongMetricsEvent {
+	e.Lock()
+	defer e.Unlock()
+	event := &soongMetricsEvent{desc: desc, name: name}
+	e.events = append(e.events, event)
+	return event
+}
+
+// updateStart moves the start of the event back to start if it is earlier.
+func (event *soongMetricsEvent) updateStart(start int64) {
+	for {
+		old := atomic.LoadInt64(&event.start)
+		if old != 0 && old <= start {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&event.start, old, start) {
+			return
+		}
+	}
+}
+
+// addSpan records a call of a mutator or singleton.  Mutators are called once for every module,
+// possibly in parallel, so the time of the event is from the start of the first call to the end
+// of the last one.
+func (event *soongMetricsEvent) addSpan(start, end time.Time) {
+	event.updateStart(start.UnixNano())
+	for {
+		old := atomic.LoadInt64(&event.end)
+		if old >= end.UnixNano() || atomic.CompareAndSwapInt64(&event.end, old, end.UnixNano()) {
+			return
+		}
+	}
+}
+
+// addDuration records a call that happens at unrelated times during the build, like a glob.
+// The time of the event is the sum of the time spent in all the calls.
+func (event *soongMetricsEvent) addDuration(start time.Time, duration time.Duration) {
+	event.updateStart(start.UnixNano())
+	atomic.AddInt64(&event.duration, int64(duration))
+}
+
+func (e *soongMetricsEvents) perfInfos() []*soong_metrics_proto.PerfInfo {
+	e.Lock()
+	defer e.Unlock()
+	var ret []*soong_metrics_proto.PerfInfo
+	for _, event := range e.events {
+		start := atomic.LoadInt64(&event.start)
+		if start == 0 {
+			// The event never happened, e.g. there were no globs.
+			continue
+		}
+		duration := atomic.LoadInt64(&event.duration)
+		if end := atomic.LoadInt64(&event.end); end != 0 {
+			duration = end - start
+		}
+		ret = append(ret, &soong_metrics_proto.PerfInfo{
+			Desc:      proto.String(event.desc),
+			Name:      proto.String(event.name),
+			StartTime: proto.Uint64(uint64(start)),
+			RealTime:  proto.Uint64(uint64(duration)),
+		})
+	}
+	return ret
+}
+
+// timedMutatorEvent returns a function that returns the event of the mutator called name, looking
+// it up only on the first call.
+func timedMutatorEvent(name string) func(Config) *soongMetricsEvent {
+	var once sync.Once
+	var event *soongMetricsEvent
+	return func(config Config) *soongMetricsEvent {
+		once.Do(func() {
+			event = soongMetricsEventsForConfig(config).event("mutator", name)
+		})
+		return event
+	}
+}
+
+// timedBottomUpMutator wraps a mutator to record the time spent in it in the soong metrics.
+func timedBottomUpMutator(name string, m blueprint.BottomUpMutator) blueprint.BottomUpMutator {
+	event := timedMutatorEvent(name)
+	return func(ctx blueprint.BottomUpMutatorContext) {
+		start := time.Now()
+		m(ctx)
+		event(ctx.Config().(Config)).addSpan(start, time.Now())
+	}
+}
+
+// timedTopDownMutator wraps a mutator to record the time spent in it in the soong metrics.
+func timedTopDownMutator(name string, m blueprint.TopDownMutator) blueprint.TopDownMutator {
+	event := timedMutatorEvent(name)
+	return func(ctx blueprint.TopDownMutatorContext) {
+		start := time.Now()
+		m(ctx)
+		event(ctx.Config().(Config)).addSpan(start, time.Now())
+	}
+}
+
 func collectMetrics(config Config) *soong_metrics_proto.SoongBuildMetrics {
 	metrics := &soong_metrics_proto.SoongBuildMetrics{}
 
@@ -68,6 +201,8 @@ func collectMetrics(config Config) *soong_metrics_proto.SoongBuildMetrics {
 	metrics.TotalAllocCount = proto.Uint64(memStats.Mallocs)
 	metrics.TotalAllocSize = proto.Uint64(memStats.TotalAlloc)
 
+	metrics.Events = soongMetricsEventsForConfig(config).perfInfos()
+
 	return metrics
 }
 
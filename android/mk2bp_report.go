@@ -0,0 +1,64 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+
+	"github.com/google/blueprint"
+)
+
+// This singleton adds a mk2bp-report phony target that writes $OUT/soong/mk2bp/report.txt. The
+// report scores every directory that still has an Android.mk file, including the nested ones that
+// are included by the Android.mk file of a parent directory, by how hard it is to convert to an
+// Android.bp file, and lists the Soong module types that would replace its modules, to drive the
+// remaining Make to Soong migration.
+
+func init() {
+	RegisterSingletonType("mk2bp_report", mk2bpReportSingletonFactory)
+
+	pctx.HostBinToolVariable("mk2bpReportCmd", "mk2bp_report")
+}
+
+var mk2bpReportRule = pctx.AndroidStaticRule("mk2bpReport", blueprint.RuleParams{
+	Command:     `${mk2bpReportCmd} -l $in -o $out -d $out.d`,
+	CommandDeps: []string{"${mk2bpReportCmd}"},
+	Deps:        blueprint.DepsGCC,
+	Depfile:     "$out.d",
+	Description: "mk2bp conversion report",
+})
+
+func mk2bpReportSingletonFactory() Singleton {
+	return &mk2bpReportSingleton{}
+}
+
+type mk2bpReportSingleton struct{}
+
+func (s *mk2bpReportSingleton) GenerateBuildActions(ctx SingletonContext) {
+	// soong_ui writes the list of all the Android.mk files in the tree next to the list of
+	// Android.bp files. Android.mk.list only has the first Android.mk file of each subtree, which
+	// is the one Make reads.
+	androidMkList := pathForBuildToolDep(ctx,
+		filepath.Join(filepath.Dir(ctx.Config().moduleListFile), "Android.mk.all.list"))
+	report := PathForOutput(ctx, "mk2bp", "report.txt")
+
+	ctx.Build(pctx, BuildParams{
+		Rule:   mk2bpReportRule,
+		Input:  androidMkList,
+		Output: report,
+	})
+
+	ctx.Phony("mk2bp-report", report)
+}
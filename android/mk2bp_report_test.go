@@ -0,0 +1,40 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMk2bpReport(t *testing.T) {
+	config := TestConfig(buildDir, nil, "", nil)
+	ctx := NewTestContext(config)
+	ctx.RegisterSingletonType("mk2bp_report", mk2bpReportSingletonFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	report := ctx.SingletonForTests("mk2bp_report").Rule("mk2bpReport")
+	if g, w := report.Output.String(), buildDir+"/mk2bp/report.txt"; g != w {
+		t.Errorf("expected report output %q, got %q", w, g)
+	}
+	if g, w := report.Input.String(), "Android.mk.all.list"; !strings.HasSuffix(g, w) {
+		t.Errorf("expected report input to be %q, got %q", w, g)
+	}
+}
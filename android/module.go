@@ -22,6 +22,7 @@ import (
 	"regexp"
 	"strings"
 	"text/scanner"
+	"time"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
@@ -555,7 +556,8 @@ type Dist struct {
 	// If no tag is specified then it will select the default dist paths provided
 	// by the module type. If a tag of "" is specified then it will return the
 	// default output files provided by the modules, i.e. the result of calling
-	// OutputFiles("").
+	// OutputFiles("").  Other tags select non-default outputs, e.g. ".proguard_map" for the
+	// proguard mappings of a java module or ".bundle" for the bundle module zip of an apex.
 	Tag *string `android:"arch_variant"`
 }
 
@@ -701,6 +703,10 @@ type commonProperties struct {
 	// relative path to a file to include in the list of notices for the device
 	Notice *string `android:"path"`
 
+	// Describes the licenses applicable to this module.  If not set, the
+	// default_applicable_licenses of the closest ancestor package that specifies them are used.
+	Applicable_licenses []string
+
 	// The OsType of artifacts that this module variant is responsible for creating.
 	//
 	// Set by osMutator
@@ -1655,6 +1661,10 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 		variables:         make(map[string]string),
 	}
 
+	// Write all the errors reported while generating the build actions as one diagnostic.
+	ctx.batchJsonDiagnostics = true
+	defer flushJsonDiagnostics(&ctx.earlyModuleContext)
+
 	dependencyInstallFiles, dependencyPackagingSpecs := m.computeInstallDeps(ctx)
 	// set m.installFilesDepSet to only the transitive dependencies to be used as the dependencies
 	// of installed files of this module.  It will be replaced by a depset including the installed
@@ -1677,25 +1687,9 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 		ctx.ruleParams = make(map[blueprint.Rule]blueprint.RuleParams)
 	}
 
-	desc := "//" + ctx.ModuleDir() + ":" + ctx.ModuleName() + " "
-	var suffix []string
-	if ctx.Os().Class != Device && ctx.Os().Class != Generic {
-		suffix = append(suffix, ctx.Os().String())
-	}
-	if !ctx.PrimaryArch() {
-		suffix = append(suffix, ctx.Arch().ArchType.String())
-	}
-	if apexInfo := ctx.Provider(ApexInfoProvider).(ApexInfo); !apexInfo.IsForPlatform() {
-		suffix = append(suffix, apexInfo.ApexVariationName)
-	}
-
+	desc, suffix := ruleDescriptionParts(ctx)
 	ctx.Variable(pctx, "moduleDesc", desc)
-
-	s := ""
-	if len(suffix) > 0 {
-		s = " [" + strings.Join(suffix, " ") + "]"
-	}
-	ctx.Variable(pctx, "moduleDescSuffix", s)
+	ctx.Variable(pctx, "moduleDescSuffix", suffix)
 
 	// Some common property checks for properties that will be used later in androidmk.go
 	checkDistProperties(ctx, "dist", &m.distProperties.Dist)
@@ -1711,6 +1705,9 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			}
 		})
 
+		m.gatherLicenseInfo(ctx)
+		m.gatherModuleGraphDeps(ctx)
+
 		m.noticeFiles = make([]Path, 0)
 		optPath := OptionalPath{}
 		notice := proptools.StringDefault(m.commonProperties.Notice, "")
@@ -1718,7 +1715,7 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			optPath = ctx.ExpandOptionalSource(&notice, "notice")
 		} else if notice != "" {
 			noticePath := filepath.Join(ctx.ModuleDir(), notice)
-			optPath = ExistentPathForSource(ctx, noticePath)
+			optPath = ExistentPathForSandboxedSource(ctx, "notice", noticePath)
 		}
 		if optPath.Valid() {
 			m.noticeFiles = append(m.noticeFiles, optPath.Path())
@@ -1732,7 +1729,7 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			}
 		}
 
-		m.module.GenerateAndroidBuildActions(ctx)
+		m.generateAndroidBuildActions(ctx)
 		if ctx.Failed() {
 			return
 		}
@@ -1808,6 +1805,33 @@ type earlyModuleContext struct {
 
 	kind   moduleKind
 	config Config
+
+	// If batchJsonDiagnostics is true, the errors for the JSON diagnostics stream are collected in
+	// jsonDiagnosticErrors and written together by flushJsonDiagnostics.
+	batchJsonDiagnostics bool
+	jsonDiagnosticErrors []JsonDiagnosticError
+}
+
+// GlobWithDeps records the time spent in globs in the soong metrics.
+func (e *earlyModuleContext) GlobWithDeps(globPattern string, excludes []string) ([]string, error) {
+	start := time.Now()
+	ret, err := e.EarlyModuleContext.GlobWithDeps(globPattern, excludes)
+	soongMetricsEventsForConfig(e.config).glob.addDuration(start, time.Since(start))
+	return ret, err
+}
+
+// ModuleErrorf reports an error against the module, and also writes it to the JSON diagnostics
+// stream if it is enabled.
+func (e *earlyModuleContext) ModuleErrorf(format string, args ...interface{}) {
+	e.EarlyModuleContext.ModuleErrorf(format, args...)
+	reportJsonDiagnostic(e, "", fmt.Sprintf(format, args...))
+}
+
+// PropertyErrorf reports an error against a property of the module, and also writes it to the
+// JSON diagnostics stream if it is enabled.
+func (e *earlyModuleContext) PropertyErrorf(property, format string, args ...interface{}) {
+	e.EarlyModuleContext.PropertyErrorf(property, format, args...)
+	reportJsonDiagnostic(e, property, fmt.Sprintf(format, args...))
 }
 
 func (e *earlyModuleContext) Glob(globPattern string, excludes []string) Paths {
@@ -2079,6 +2103,37 @@ func (m *moduleContext) Rule(pctx PackageContext, name string, params blueprint.
 	return rule
 }
 
+// ruleDescriptionParts returns the prefix and the suffix that are added around the description of
+// every build rule of a module, so that the ninja status output and the build logs show which module
+// and variant each action is for, as "//<dir>:<module> <action> [<variant>]".  By default only the
+// parts of the variant that differ from the common case are listed, setting
+// SOONG_VERBOSE_RULE_DESCRIPTIONS lists the full variant of every rule instead.
+func ruleDescriptionParts(ctx *moduleContext) (prefix, suffix string) {
+	prefix = "//" + ctx.ModuleDir() + ":" + ctx.ModuleName() + " "
+
+	var variant []string
+	if ctx.Config().VerboseRuleDescriptions() {
+		if subDir := ctx.ModuleSubDir(); subDir != "" {
+			variant = append(variant, subDir)
+		}
+	} else {
+		if ctx.Os().Class != Device && ctx.Os().Class != Generic {
+			variant = append(variant, ctx.Os().String())
+		}
+		if !ctx.PrimaryArch() {
+			variant = append(variant, ctx.Arch().ArchType.String())
+		}
+		if apexInfo := ctx.Provider(ApexInfoProvider).(ApexInfo); !apexInfo.IsForPlatform() {
+			variant = append(variant, apexInfo.ApexVariationName)
+		}
+	}
+
+	if len(variant) > 0 {
+		suffix = " [" + strings.Join(variant, " ") + "]"
+	}
+	return prefix, suffix
+}
+
 func (m *moduleContext) Build(pctx PackageContext, params BuildParams) {
 	if params.Description != "" {
 		params.Description = "${moduleDesc}" + params.Description + "${moduleDescSuffix}"
@@ -0,0 +1,137 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// This singleton writes the complete module graph to $OUT/soong/module_graph.json on every build
+// so that IDE indexers and dependency analysis tools can read it instead of parsing the Ninja file.
+// Each entry describes a single variant of a module.
+
+func init() {
+	RegisterSingletonType("module_graph", moduleGraphSingletonFactory)
+}
+
+const moduleGraphJsonFileName = "module_graph.json"
+
+// ModuleGraphDep describes a direct dependency of a module variant in the module graph.
+type ModuleGraphDep struct {
+	Name    string
+	Variant string
+	// Tag is the Go type and value of the dependency tag, e.g.
+	// "java.dependencyTag{BaseDependencyTag:{} name:staticlib}".
+	Tag string
+}
+
+// ModuleGraphEntry describes a single module variant in the module graph.
+type ModuleGraphEntry struct {
+	Name          string
+	Type          string
+	Variant       string
+	Dir           string
+	Deps          []ModuleGraphDep `json:",omitempty"`
+	Output_files  []string         `json:",omitempty"`
+	Install_paths []string         `json:",omitempty"`
+}
+
+// moduleGraphDep is a direct dependency of a module variant along with its dependency tag.
+type moduleGraphDep struct {
+	module blueprint.Module
+	tag    blueprint.DependencyTag
+}
+
+// moduleGraphDepsProvider holds the direct dependencies of a module variant, recorded while the
+// dependency tags are still available from the ModuleContext.
+var moduleGraphDepsProvider = blueprint.NewProvider([]moduleGraphDep{})
+
+// gatherModuleGraphDeps records the direct dependencies of the module, with their dependency
+// tags, for the module graph singleton.
+func (m *ModuleBase) gatherModuleGraphDeps(ctx ModuleContext) {
+	var deps []moduleGraphDep
+	ctx.VisitDirectDepsBlueprint(func(dep blueprint.Module) {
+		deps = append(deps, moduleGraphDep{dep, ctx.OtherModuleDependencyTag(dep)})
+	})
+	ctx.SetProvider(moduleGraphDepsProvider, deps)
+}
+
+func moduleGraphSingletonFactory() Singleton {
+	return &moduleGraphSingleton{}
+}
+
+type moduleGraphSingleton struct{}
+
+func (s *moduleGraphSingleton) GenerateBuildActions(ctx SingletonContext) {
+	var entries []ModuleGraphEntry
+
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() {
+			return
+		}
+
+		entry := ModuleGraphEntry{
+			Name:          ctx.ModuleName(module),
+			Type:          ctx.ModuleType(module),
+			Variant:       ctx.ModuleSubDir(module),
+			Dir:           ctx.ModuleDir(module),
+			Install_paths: module.FilesToInstall().Strings(),
+		}
+		if ctx.ModuleHasProvider(module, moduleGraphDepsProvider) {
+			for _, dep := range ctx.ModuleProvider(module, moduleGraphDepsProvider).([]moduleGraphDep) {
+				entry.Deps = append(entry.Deps, ModuleGraphDep{
+					Name:    ctx.ModuleName(dep.module),
+					Variant: ctx.ModuleSubDir(dep.module),
+					Tag:     fmt.Sprintf("%T%+v", dep.tag, dep.tag),
+				})
+			}
+		}
+		if producer, ok := module.(OutputFileProducer); ok {
+			// Not every module supports the default tag, ignore the error for the ones that don't.
+			if outputFiles, err := producer.OutputFiles(""); err == nil {
+				entry.Output_files = outputFiles.Strings()
+			}
+		}
+		entries = append(entries, entry)
+	})
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Variant < entries[j].Variant
+	})
+
+	path := PathForOutput(ctx, moduleGraphJsonFileName)
+	buf, err := json.MarshalIndent(entries, "", "\t")
+	if err != nil {
+		ctx.Errorf("JSON marshal of module graph failed: %s", err)
+		return
+	}
+	if err := WriteFileToOutputDir(path, buf, 0666); err != nil {
+		ctx.Errorf("Writing module graph to %s failed: %s", path.String(), err)
+		return
+	}
+
+	// This is necessary to satisfy the dangling rules check as this file is written by Soong rather than a rule.
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: path,
+	})
+}
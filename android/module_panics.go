@@ -0,0 +1,86 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// A panic in the GenerateAndroidBuildActions of a module normally aborts the analysis with the
+// first panicking module.  When SOONG_CONTINUE_AFTER_PANICS is set to a positive number, up to that
+// many panics are recovered and reported as errors of the panicking modules instead, and the
+// analysis of the other modules continues.  This surfaces all of the modules broken by a large
+// refactoring in a single run.
+
+var modulePanicsKey = NewOnceKey("modulePanics")
+
+// modulePanics counts the panics that have been recovered so far.
+type modulePanics struct {
+	sync.Mutex
+	count int
+}
+
+func getModulePanics(config Config) *modulePanics {
+	return config.Once(modulePanicsKey, func() interface{} {
+		return &modulePanics{}
+	}).(*modulePanics)
+}
+
+// ContinueAfterPanics returns the maximum number of panics in GenerateAndroidBuildActions that are
+// recovered to continue the analysis of the other modules, or 0 if panics should not be recovered.
+func (c *config) ContinueAfterPanics() int {
+	if v := c.Getenv("SOONG_CONTINUE_AFTER_PANICS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// generateAndroidBuildActions calls GenerateAndroidBuildActions on the module.  If the config
+// allows it a panic is recovered and reported as an error of the module, which stops
+// GenerateBuildActions from creating the install, checkbuild and AndroidMk rules of the half built
+// module.
+func (m *ModuleBase) generateAndroidBuildActions(ctx *moduleContext) {
+	if limit := ctx.Config().ContinueAfterPanics(); limit > 0 {
+		defer func() {
+			if r := recover(); r != nil {
+				if !recoverModulePanic(ctx.Config(), limit) {
+					// Over the limit, let blueprint abort the analysis.
+					panic(fmt.Errorf("%v\n\nstopped after %d recovered panics, increase "+
+						"SOONG_CONTINUE_AFTER_PANICS to see more", r, limit))
+				}
+				ctx.ModuleErrorf("panic in GenerateAndroidBuildActions: %v\n%s", r, debug.Stack())
+			}
+		}()
+	}
+	m.module.GenerateAndroidBuildActions(ctx)
+}
+
+// recoverModulePanic counts a recovered panic, and returns false if the limit of recovered panics
+// has already been reached.
+func recoverModulePanic(config Config, limit int) bool {
+	p := getModulePanics(config)
+	p.Lock()
+	defer p.Unlock()
+	if p.count >= limit {
+		return false
+	}
+	p.count++
+	return true
+}
@@ -15,7 +15,14 @@
 package android
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/google/blueprint/proptools"
 )
 
 func TestSrcIsModule(t *testing.T) {
@@ -187,6 +194,162 @@ func TestErrorDependsOnDisabledModule(t *testing.T) {
 	FailIfNoMatchingErrors(t, `module "foo": depends on disabled module "bar"`, errs)
 }
 
+type ruleDescriptionModule struct {
+	ModuleBase
+	prefix, suffix string
+}
+
+func (m *ruleDescriptionModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	m.prefix, m.suffix = ruleDescriptionParts(ctx.(*moduleContext))
+}
+
+func ruleDescriptionModuleFactory() Module {
+	m := &ruleDescriptionModule{}
+	InitAndroidArchModule(m, HostAndDeviceSupported, MultilibBoth)
+	return m
+}
+
+func TestRuleDescriptionParts(t *testing.T) {
+	bp := `
+		rule_description {
+			name: "foo",
+			host_supported: true,
+		}
+	`
+
+	testCases := []struct {
+		name     string
+		env      map[string]string
+		variants map[string]string
+	}{
+		{
+			name: "default",
+			variants: map[string]string{
+				"android_arm64_armv8-a":      "",
+				"android_arm_armv7-a-neon":   " [arm]",
+				BuildOs.String() + "_x86_64": " [" + BuildOs.String() + "]",
+			},
+		},
+		{
+			name: "verbose",
+			env:  map[string]string{"SOONG_VERBOSE_RULE_DESCRIPTIONS": "true"},
+			variants: map[string]string{
+				"android_arm64_armv8-a":      " [android_arm64_armv8-a]",
+				"android_arm_armv7-a-neon":   " [android_arm_armv7-a-neon]",
+				BuildOs.String() + "_x86_64": " [" + BuildOs.String() + "_x86_64]",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := TestArchConfig(buildDir, tc.env, bp, nil)
+
+			ctx := NewTestArchContext(config)
+			ctx.RegisterModuleType("rule_description", ruleDescriptionModuleFactory)
+			ctx.Register()
+
+			_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+			FailIfErrored(t, errs)
+			_, errs = ctx.PrepareBuildActions(config)
+			FailIfErrored(t, errs)
+
+			for variant, expectedSuffix := range tc.variants {
+				m := ctx.ModuleForTests("foo", variant).Module().(*ruleDescriptionModule)
+				if m.prefix != "//.:foo " {
+					t.Errorf("%s: expected prefix %q, got %q", variant, "//.:foo ", m.prefix)
+				}
+				if m.suffix != expectedSuffix {
+					t.Errorf("%s: expected suffix %q, got %q", variant, expectedSuffix, m.suffix)
+				}
+			}
+		})
+	}
+}
+
+type panickingModule struct {
+	ModuleBase
+	properties struct {
+		Panic *bool
+	}
+}
+
+func (m *panickingModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	if proptools.Bool(m.properties.Panic) {
+		panic(fmt.Errorf("%s is broken", ctx.ModuleName()))
+	}
+}
+
+func panickingModuleFactory() Module {
+	m := &panickingModule{}
+	m.AddProperties(&m.properties)
+	InitAndroidModule(m)
+	return m
+}
+
+func TestContinueAfterPanics(t *testing.T) {
+	bp := `
+		panicking {
+			name: "foo",
+			panic: true,
+		}
+
+		panicking {
+			name: "bar",
+			panic: true,
+		}
+
+		panicking {
+			name: "baz",
+		}
+	`
+
+	testCases := []struct {
+		name           string
+		env            map[string]string
+		expectedErrors []string
+	}{
+		{
+			name: "disabled",
+			expectedErrors: []string{
+				`(foo|bar) is broken`,
+			},
+		},
+		{
+			name: "enabled",
+			env:  map[string]string{"SOONG_CONTINUE_AFTER_PANICS": "5"},
+			expectedErrors: []string{
+				`module "bar": panic in GenerateAndroidBuildActions: bar is broken`,
+				`module "foo": panic in GenerateAndroidBuildActions: foo is broken`,
+			},
+		},
+		{
+			name: "limited",
+			env:  map[string]string{"SOONG_CONTINUE_AFTER_PANICS": "1"},
+			expectedErrors: []string{
+				`(?s)(foo|bar) is broken.*stopped after 1 recovered panics`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := TestConfig(buildDir, tc.env, bp, nil)
+
+			ctx := NewTestContext(config)
+			ctx.RegisterModuleType("panicking", panickingModuleFactory)
+			ctx.Register()
+
+			_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+			FailIfErrored(t, errs)
+			_, errs = ctx.PrepareBuildActions(config)
+			for _, expectedError := range tc.expectedErrors {
+				FailIfNoMatchingErrors(t, expectedError, errs)
+			}
+		})
+	}
+}
+
 func TestValidateCorrectBuildParams(t *testing.T) {
 	config := TestConfig(buildDir, nil, "", nil)
 	pathContext := PathContextForTesting(config)
@@ -280,3 +443,84 @@ func TestDistErrorChecking(t *testing.T) {
 	}
 	CheckErrorsAgainstExpectations(t, errs, expectedErrs)
 }
+
+func TestModuleGraph(t *testing.T) {
+	bp := `
+		test_module {
+			name: "foo",
+			deps: ["dep"],
+			install_deps: ["install_dep"],
+		}
+
+		test_module {
+			name: "install_dep",
+		}
+
+		test_module {
+			name: "dep",
+		}
+	`
+
+	config := TestArchConfig(buildDir, nil, bp, nil)
+	ctx := NewTestArchContext(config)
+	ctx.RegisterModuleType("test_module", testInstallDependencyTagModuleFactory)
+	ctx.RegisterSingletonType("module_graph", moduleGraphSingletonFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	path := ctx.SingletonForTests("module_graph").Rule("touch").Output
+	buf, err := ioutil.ReadFile(path.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ModuleGraphEntry
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	var foo *ModuleGraphEntry
+	var names []string
+	for i, entry := range entries {
+		names = append(names, entry.Name+" "+entry.Variant)
+		if entry.Name == "foo" && entry.Variant == "android_common" {
+			foo = &entries[i]
+		}
+	}
+
+	expectedNames := []string{
+		"dep android_common",
+		"dep " + config.BuildOSCommonTarget.String(),
+		"foo android_common",
+		"foo " + config.BuildOSCommonTarget.String(),
+		"install_dep android_common",
+		"install_dep " + config.BuildOSCommonTarget.String(),
+	}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("expected modules %q, got %q", expectedNames, names)
+	}
+	if foo == nil {
+		t.Fatalf("missing module graph entry for foo")
+	}
+
+	if g, w := foo.Type, "test_module"; g != w {
+		t.Errorf("expected type %q, got %q", w, g)
+	}
+	if len(foo.Install_paths) != 1 || !strings.HasSuffix(foo.Install_paths[0], "/system/foo") {
+		t.Errorf("expected foo to install to system/foo, got %q", foo.Install_paths)
+	}
+
+	var deps []string
+	for _, dep := range foo.Deps {
+		deps = append(deps, dep.Name+" "+dep.Variant)
+		if dep.Name == "install_dep" && !strings.Contains(dep.Tag, "InstallAlwaysNeededDependencyTag") {
+			t.Errorf("expected install_dep tag to be the install dependency tag, got %q", dep.Tag)
+		}
+	}
+	if g, w := deps, []string{"install_dep android_common", "dep android_common"}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected deps %q, got %q", w, g)
+	}
+}
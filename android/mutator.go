@@ -34,9 +34,9 @@ func registerMutatorsToContext(ctx *blueprint.Context, mutators []*mutator) {
 	for _, t := range mutators {
 		var handle blueprint.MutatorHandle
 		if t.bottomUpMutator != nil {
-			handle = ctx.RegisterBottomUpMutator(t.name, t.bottomUpMutator)
+			handle = ctx.RegisterBottomUpMutator(t.name, timedBottomUpMutator(t.name, t.bottomUpMutator))
 		} else if t.topDownMutator != nil {
-			handle = ctx.RegisterTopDownMutator(t.name, t.topDownMutator)
+			handle = ctx.RegisterTopDownMutator(t.name, timedTopDownMutator(t.name, t.topDownMutator))
 		}
 		if t.parallel {
 			handle.Parallel()
@@ -45,8 +45,16 @@ func registerMutatorsToContext(ctx *blueprint.Context, mutators []*mutator) {
 }
 
 func registerMutatorsForBazelConversion(ctx *blueprint.Context) {
+	mctx := &registerMutatorsContext{}
+
+	// Add dependencies on the modules referenced with the ":module" syntax, for
+	// BazelLabelForSrcPatterns to convert them into the labels of the modules.
+	registerPathDepsMutator(mctx)
+
 	// FIXME(b/171263886): Start bringing in mutators to make the Bionic
 	// module subgraph suitable for automated conversion.
+
+	registerMutatorsToContext(ctx, mctx.mutators)
 }
 
 func registerMutators(ctx *blueprint.Context, preArch, preDeps, postDeps, finalDeps []RegisterMutatorFunc) {
@@ -146,6 +154,14 @@ var preArch = []RegisterMutatorFunc{
 	// This must come after the defaults mutators to ensure that any visibility supplied
 	// in a defaults module has been successfully applied before the rules are gathered.
 	RegisterVisibilityRuleGatherer,
+
+	// Record the default_applicable_licenses of the packages, and then add the dependencies of the
+	// modules on their licenses.
+	//
+	// This must come after the defaults mutators to ensure that any applicable_licenses supplied
+	// in a defaults module have been applied.
+	RegisterLicensesPackageMapper,
+	RegisterLicensesPropertyGatherer,
 }
 
 func registerArchMutator(ctx RegisterMutatorsContext) {
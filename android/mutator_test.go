@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
@@ -296,3 +298,77 @@ func TestNoCreateVariationsInFinalDeps(t *testing.T) {
 	_, errs = ctx.PrepareBuildActions(config)
 	FailIfErrored(t, errs)
 }
+
+func TestMutatorMetrics(t *testing.T) {
+	bp := `
+		test {
+			name: "foo",
+		}
+
+		test {
+			name: "bar",
+		}
+	`
+
+	config := TestConfig(buildDir, nil, bp, nil)
+
+	ctx := NewTestContext(config)
+	ctx.RegisterModuleType("test", mutatorTestModuleFactory)
+	ctx.PreDepsMutators(func(ctx RegisterMutatorsContext) {
+		ctx.BottomUp("metrics_bottom_up", func(BottomUpMutatorContext) {}).Parallel()
+		ctx.TopDown("metrics_top_down", func(TopDownMutatorContext) {})
+	})
+	ctx.RegisterSingletonType("soong_metrics", soongMetricsSingletonFactory)
+
+	ctx.Register()
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	var events []string
+	for _, event := range collectMetrics(config).Events {
+		events = append(events, event.GetDesc()+" "+event.GetName())
+	}
+
+	for _, w := range []string{"mutator metrics_bottom_up", "mutator metrics_top_down", "singleton soong_metrics"} {
+		count := 0
+		for _, event := range events {
+			if event == w {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Errorf("expected metrics event %q once, got %q", w, events)
+		}
+	}
+}
+
+func TestMetricsEventSpan(t *testing.T) {
+	e := &soongMetricsEvents{}
+	event := e.event("mutator", "parallel")
+	base := time.Unix(1000, 0)
+
+	// Calls in parallel extend the event from the earliest start to the latest end.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			start := base.Add(time.Duration(i) * time.Second)
+			event.addSpan(start, start.Add(time.Second))
+		}(i)
+	}
+	wg.Wait()
+
+	infos := e.perfInfos()
+	if len(infos) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(infos))
+	}
+	if g, w := infos[0].GetStartTime(), uint64(base.UnixNano()); g != w {
+		t.Errorf("expected start %d, got %d", w, g)
+	}
+	if g, w := time.Duration(infos[0].GetRealTime()), 10*time.Second; g != w {
+		t.Errorf("expected duration %s, got %s", w, g)
+	}
+}
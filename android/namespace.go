@@ -15,8 +15,10 @@
 package android
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -85,6 +87,10 @@ type NameResolver struct {
 
 	// func telling whether to export a namespace to Kati
 	namespaceExportFilter func(*Namespace) bool
+
+	// file to write the namespace import graph to, if not empty
+	namespaceGraphFile string
+	writeGraphOnce     sync.Once
 }
 
 func NewNameResolver(namespaceExportFilter func(*Namespace) bool) *NameResolver {
@@ -321,11 +327,148 @@ func (r *NameResolver) MissingDependencyError(depender string, dependerNamespace
 		}
 		text += fmt.Sprintf("\nModule %q is defined in namespace %q which can read these %v namespaces: %q", depender, dependerNs.Path, len(importedNames), importedNames)
 		text += fmt.Sprintf("\nModule %q can be found in these namespaces: %q", depName, foundInNamespaces)
+
+		// Explain the common mistake of expecting imports to be transitive.
+		for _, path := range foundInNamespaces {
+			ns, _ := r.namespaceAt(path)
+			if chain := r.importChain(dependerNs, ns); len(chain) > 2 {
+				text += fmt.Sprintf("\nNamespace %q imports %q only indirectly: %s. Imports are not transitive, add %q to the imports of %q",
+					dependerNs.Path, path, formatNamespaceChain(chain), path, dependerNs.Path)
+				break
+			}
+		}
 	}
 
 	return fmt.Errorf(text)
 }
 
+// importChain returns the shortest chain of imports that leads from one namespace to another,
+// starting with from and ending with to, or nil if to can't be reached through imports.
+func (r *NameResolver) importChain(from, to *Namespace) []*Namespace {
+	previous := map[*Namespace]*Namespace{from: nil}
+	queue := []*Namespace{from}
+	for len(queue) > 0 {
+		namespace := queue[0]
+		queue = queue[1:]
+		if namespace == to {
+			var chain []*Namespace
+			for ns := to; ns != nil; ns = previous[ns] {
+				chain = append([]*Namespace{ns}, chain...)
+			}
+			return chain
+		}
+		for _, name := range namespace.importedNamespaceNames {
+			imp, ok := r.namespaceAt(name)
+			if !ok {
+				continue
+			}
+			if _, seen := previous[imp]; !seen {
+				previous[imp] = namespace
+				queue = append(queue, imp)
+			}
+		}
+	}
+	return nil
+}
+
+func formatNamespaceChain(chain []*Namespace) string {
+	paths := make([]string, len(chain))
+	for i, ns := range chain {
+		paths[i] = strconv.Quote(ns.Path)
+	}
+	return strings.Join(paths, " -> ")
+}
+
+// ImportCycles returns the cycles in the namespace import graph, each as the list of namespace
+// paths along the cycle starting and ending with the same namespace.  Namespaces may import each
+// other, so the cycles are not errors, but they are reported in the namespace graph to help
+// debugging.
+func (r *NameResolver) ImportCycles() [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*Namespace]int)
+	var stack []*Namespace
+	var cycles [][]string
+
+	var visit func(namespace *Namespace)
+	visit = func(namespace *Namespace) {
+		state[namespace] = visiting
+		stack = append(stack, namespace)
+		for _, name := range namespace.importedNamespaceNames {
+			imp, ok := r.namespaceAt(name)
+			if !ok {
+				continue
+			}
+			switch state[imp] {
+			case unvisited:
+				visit(imp)
+			case visiting:
+				var cycle []string
+				for i := len(stack) - 1; i >= 0; i-- {
+					cycle = append([]string{stack[i].Path}, cycle...)
+					if stack[i] == imp {
+						break
+					}
+				}
+				cycles = append(cycles, append(cycle, imp.Path))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[namespace] = visited
+	}
+
+	for _, namespace := range r.sortedNamespaces.sortedItems() {
+		if state[namespace] == unvisited {
+			visit(namespace)
+		}
+	}
+	return cycles
+}
+
+// SetNamespaceGraphFile sets the file that the namespace import graph is written to once all
+// namespaces have been parsed.
+func (r *NameResolver) SetNamespaceGraphFile(path string) {
+	r.namespaceGraphFile = path
+}
+
+type namespaceGraphNode struct {
+	Path           string
+	Imports        []string `json:",omitempty"`
+	Export_to_kati bool
+}
+
+type namespaceGraph struct {
+	Namespaces    []namespaceGraphNode
+	Import_cycles [][]string `json:",omitempty"`
+}
+
+// writeNamespaceGraph writes the namespace import graph to the file set by
+// SetNamespaceGraphFile, if any.
+func (r *NameResolver) writeNamespaceGraph() error {
+	if r.namespaceGraphFile == "" {
+		return nil
+	}
+
+	var graph namespaceGraph
+	for _, namespace := range r.sortedNamespaces.sortedItems() {
+		graph.Namespaces = append(graph.Namespaces, namespaceGraphNode{
+			Path:           namespace.Path,
+			Imports:        namespace.importedNamespaceNames,
+			Export_to_kati: namespace.exportToKati,
+		})
+	}
+	graph.Import_cycles = r.ImportCycles()
+
+	buf, err := json.MarshalIndent(graph, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.namespaceGraphFile, buf, 0666)
+}
+
 func (r *NameResolver) GetNamespace(ctx blueprint.NamespaceContext) blueprint.Namespace {
 	return r.findNamespaceFromCtx(ctx)
 }
@@ -418,6 +561,14 @@ func RegisterNamespaceMutator(ctx RegisterMutatorsContext) {
 func namespaceMutator(ctx BottomUpMutatorContext) {
 	module, ok := ctx.Module().(*NamespaceModule)
 	if ok {
+		// All namespaces have been parsed by now.  Write the graph before resolving the imports
+		// and dependencies so that it is available to debug errors from either.
+		module.resolver.writeGraphOnce.Do(func() {
+			if err := module.resolver.writeNamespaceGraph(); err != nil {
+				ctx.ModuleErrorf("failed to write the namespace graph: %s", err)
+			}
+		})
+
 		err := module.resolver.FindNamespaceImports(module.namespace)
 		if err != nil {
 			ctx.ModuleErrorf(err.Error())
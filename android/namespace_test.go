@@ -15,7 +15,9 @@
 package android
 
 import (
+	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -377,6 +379,67 @@ func TestTwoNamespacesCanImportEachOther(t *testing.T) {
 	// setupTest will report any errors
 }
 
+func TestNamespaceGraph(t *testing.T) {
+	graphFile := filepath.Join(buildDir, "namespace_graph.json")
+
+	config := TestConfig(buildDir, nil, "", mockFiles(map[string]string{
+		"dir1": `
+			soong_namespace {
+				imports: ["dir2"],
+			}
+			`,
+		"dir2": `
+			soong_namespace {
+				imports: ["dir3"],
+			}
+			`,
+		"dir3": `
+			soong_namespace {
+				imports: ["dir1"],
+			}
+			`,
+		"dir4": `
+			soong_namespace {
+				imports: ["dir1"],
+			}
+			`,
+	}))
+
+	ctx := NewTestContext(config)
+	ctx.NameResolver.SetNamespaceGraphFile(graphFile)
+	ctx.RegisterModuleType("soong_namespace", NamespaceFactory)
+	ctx.PreArchMutators(RegisterNamespaceMutator)
+	ctx.Register()
+
+	_, errs := ctx.ParseBlueprintsFiles("Android.bp")
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	buf, err := ioutil.ReadFile(graphFile)
+	if err != nil {
+		t.Fatalf("failed to read namespace graph: %s", err)
+	}
+	var graph namespaceGraph
+	if err := json.Unmarshal(buf, &graph); err != nil {
+		t.Fatalf("failed to parse namespace graph: %s", err)
+	}
+
+	expected := namespaceGraph{
+		Namespaces: []namespaceGraphNode{
+			{Path: ".", Export_to_kati: true},
+			{Path: "dir1", Imports: []string{"dir2"}, Export_to_kati: true},
+			{Path: "dir2", Imports: []string{"dir3"}, Export_to_kati: true},
+			{Path: "dir3", Imports: []string{"dir1"}, Export_to_kati: true},
+			{Path: "dir4", Imports: []string{"dir1"}, Export_to_kati: true},
+		},
+		Import_cycles: [][]string{{"dir1", "dir2", "dir3", "dir1"}},
+	}
+	if !reflect.DeepEqual(expected, graph) {
+		t.Errorf("incorrect namespace graph.\nexpected: %#v\nactual:   %#v", expected, graph)
+	}
+}
+
 func TestImportingNonexistentNamespace(t *testing.T) {
 	_, errs := setupTestExpectErrs(
 		map[string]string{
@@ -488,7 +551,8 @@ func TestNamespaceImportsNotTransitive(t *testing.T) {
 	expectedErrors := []error{
 		errors.New(`dir3/Android.bp:5:4: "c" depends on undefined module "a"
 Module "c" is defined in namespace "dir3" which can read these 3 namespaces: ["dir3" "dir2" "."]
-Module "a" can be found in these namespaces: ["dir1"]`),
+Module "a" can be found in these namespaces: ["dir1"]
+Namespace "dir3" imports "dir1" only indirectly: "dir3" -> "dir2" -> "dir1". Imports are not transitive, add "dir1" to the imports of "dir3"`),
 	}
 	if len(errs) != 1 || errs[0].Error() != expectedErrors[0].Error() {
 		t.Errorf("Incorrect errors. Expected:\n%v\n, got:\n%v\n", expectedErrors, errs)
@@ -230,6 +230,10 @@ func neverallowMutator(ctx BottomUpMutatorContext) {
 			continue
 		}
 
+		if !n.appliesToModuleName(ctx.ModuleName()) {
+			continue
+		}
+
 		if !n.appliesToProperties(properties) {
 			continue
 		}
@@ -347,6 +351,8 @@ type Rule interface {
 
 	NotModuleType(types ...string) Rule
 
+	NotModuleName(names ...string) Rule
+
 	BootclasspathJar() Rule
 
 	With(properties, value string) Rule
@@ -374,6 +380,8 @@ type rule struct {
 	moduleTypes       []string
 	unlessModuleTypes []string
 
+	unlessModuleNames []string
+
 	props       []ruleProperty
 	unlessProps []ruleProperty
 
@@ -417,6 +425,12 @@ func (r *rule) NotModuleType(types ...string) Rule {
 	return r
 }
 
+// NotModuleName exempts the named modules, and their prebuilts, from the rule.
+func (r *rule) NotModuleName(names ...string) Rule {
+	r.unlessModuleNames = append(r.unlessModuleNames, names...)
+	return r
+}
+
 func (r *rule) With(properties, value string) Rule {
 	return r.WithMatcher(properties, selectMatcher(value))
 }
@@ -472,6 +486,9 @@ func (r *rule) String() string {
 	for _, v := range r.unlessModuleTypes {
 		s += " -type:" + v
 	}
+	for _, v := range r.unlessModuleNames {
+		s += " -name:" + v
+	}
 	for _, v := range r.props {
 		s += " " + strings.Join(v.fields, ".") + v.matcher.String()
 	}
@@ -507,7 +524,8 @@ func (r *rule) appliesToDirectDeps(ctx BottomUpMutatorContext) bool {
 	matches := false
 	ctx.VisitDirectDeps(func(m Module) {
 		if !matches {
-			name := ctx.OtherModuleName(m)
+			// A prebuilt that replaces a restricted module is just as restricted.
+			name := RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(m))
 			matches = r.directDeps[name]
 		}
 	})
@@ -541,6 +559,10 @@ func (r *rule) appliesToModuleType(moduleType string) bool {
 	return (len(r.moduleTypes) == 0 || InList(moduleType, r.moduleTypes)) && !InList(moduleType, r.unlessModuleTypes)
 }
 
+func (r *rule) appliesToModuleName(name string) bool {
+	return !InList(RemoveOptionalPrebuiltPrefix(name), r.unlessModuleNames)
+}
+
 func (r *rule) appliesToProperties(properties []interface{}) bool {
 	includeProps := hasAllProperties(properties, r.props)
 	excludeProps := hasAnyProperty(properties, r.unlessProps)
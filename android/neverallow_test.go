@@ -59,6 +59,62 @@ var neverallowTests = []struct {
 		},
 	},
 
+	{
+		name: "not_allowed_in_direct_deps prebuilt",
+		rules: []Rule{
+			NeverAllow().InDirectDeps("not_allowed_in_direct_deps"),
+		},
+		fs: map[string][]byte{
+			"top/Android.bp": []byte(`
+				cc_library {
+					name: "prebuilt_not_allowed_in_direct_deps",
+				}`),
+			"other/Android.bp": []byte(`
+				cc_library {
+					name: "libother",
+					static_libs: ["prebuilt_not_allowed_in_direct_deps"],
+				}`),
+		},
+		expectedErrors: []string{
+			`module "libother": violates neverallow deps:not_allowed_in_direct_deps`,
+		},
+	},
+
+	// exempt module name tests
+	{
+		name: "not_allowed_in_direct_deps exempt module",
+		rules: []Rule{
+			NeverAllow().
+				NotIn("top").
+				InDirectDeps("not_allowed_in_direct_deps").
+				NotModuleName("libexempt"),
+		},
+		fs: map[string][]byte{
+			"top/Android.bp": []byte(`
+				cc_library {
+					name: "not_allowed_in_direct_deps",
+				}
+
+				cc_library {
+					name: "libtop",
+					static_libs: ["not_allowed_in_direct_deps"],
+				}`),
+			"other/Android.bp": []byte(`
+				cc_library {
+					name: "libexempt",
+					static_libs: ["not_allowed_in_direct_deps"],
+				}
+
+				cc_library {
+					name: "libother",
+					static_libs: ["not_allowed_in_direct_deps"],
+				}`),
+		},
+		expectedErrors: []string{
+			`module "libother": violates neverallow -dir:top/\* -name:libexempt deps:not_allowed_in_direct_deps`,
+		},
+	},
+
 	// Test android specific rules
 
 	// include_dir rule tests
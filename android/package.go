@@ -31,6 +31,8 @@ func RegisterPackageBuildComponents(ctx RegistrationContext) {
 type packageProperties struct {
 	// Specifies the default visibility for all modules defined in this package.
 	Default_visibility []string
+	// Specifies the default license applicable to all modules defined in this package.
+	Default_applicable_licenses []string
 }
 
 type packageModule struct {
@@ -52,6 +54,11 @@ func (p *packageModule) qualifiedModuleId(ctx BaseModuleContext) qualifiedModule
 	return newPackageId(ctx.ModuleDir())
 }
 
+// package {} describes the package defined by the directory containing the Android.bp file.
+// Its default_visibility and default_applicable_licenses apply to every module in that directory,
+// and in any subdirectory that does not define its own package with the same property, unless
+// the module sets visibility or applicable_licenses itself.  There may be at most one package
+// module per directory; its name is implicitly "//" followed by the directory.
 func PackageFactory() Module {
 	module := &packageModule{}
 
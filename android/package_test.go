@@ -24,6 +24,16 @@ var packageTests = []struct {
 			`top/Blueprints:4:16: unrecognized property "visibility"`,
 		},
 	},
+	{
+		name: "package accepts default_visibility and default_applicable_licenses",
+		fs: map[string][]byte{
+			"top/Blueprints": []byte(`
+				package {
+					default_visibility: ["//visibility:private"],
+					default_applicable_licenses: ["top_license"],
+				}`),
+		},
+	},
 	{
 		name: "multiple packages in separate directories",
 		fs: map[string][]byte{
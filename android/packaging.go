@@ -49,6 +49,25 @@ func (p *PackagingSpec) FileName() string {
 	return ""
 }
 
+// SrcPath returns the path to the built artifact, or nil if the spec is for a symlink.
+func (p *PackagingSpec) SrcPath() Path {
+	return p.srcPath
+}
+
+// source returns the symlink target or the source path of the file, which identifies the file
+// regardless of the module that provides it.
+func (p *PackagingSpec) source() string {
+	if p.symlinkTarget != "" {
+		return "-> " + p.symlinkTarget
+	}
+	return p.srcPath.String()
+}
+
+// RelPathInPackage returns the path of the artifact relative to the root of the package.
+func (p *PackagingSpec) RelPathInPackage() string {
+	return p.relPathInPackage
+}
+
 type PackageModule interface {
 	Module
 	packagingBase() *PackagingBase
@@ -62,6 +81,11 @@ type PackageModule interface {
 	// followed by a build rule that unzips it and creates the final output (img, zip, tar.gz,
 	// etc.) from the extracted files
 	CopyDepsToZip(ctx ModuleContext, zipOut OutputPath) []string
+
+	// BuildLicenseMetadata writes the licenses of the package and of the dependencies packaged in
+	// it to a license metadata file, and returns the path to the file. This is expected to be
+	// called in GenerateAndroidBuildActions.
+	BuildLicenseMetadata(ctx ModuleContext) OutputPath
 }
 
 // PackagingBase provides basic functionality for packaging dependencies. A module is expected to
@@ -116,10 +140,16 @@ func (p *PackagingBase) getDepsForArch(ctx BaseModuleContext, arch ArchType) []s
 	} else if arch == Common {
 		ret = append(ret, p.properties.Multilib.Common.Deps...)
 	}
+	firstNativeBridgeTarget := true
 	for i, t := range ctx.MultiTargets() {
+		// The first target of the translated architectures is a first target as well.
+		isFirst := i == 0 || (t.NativeBridge == NativeBridgeEnabled && firstNativeBridgeTarget)
+		if t.NativeBridge == NativeBridgeEnabled {
+			firstNativeBridgeTarget = false
+		}
 		if t.Arch.ArchType == arch {
 			ret = append(ret, p.properties.Deps...)
-			if i == 0 {
+			if isFirst {
 				ret = append(ret, p.properties.Multilib.First.Deps...)
 			}
 		}
@@ -154,17 +184,32 @@ func (p *PackagingBase) AddDeps(ctx BottomUpMutatorContext, depTag blueprint.Dep
 // See PackageModule.CopyDepsToZip
 func (p *PackagingBase) CopyDepsToZip(ctx ModuleContext, zipOut OutputPath) (entries []string) {
 	m := make(map[string]PackagingSpec)
+	// The module that provides each of the packaged files, to report modules that package
+	// different files to the same path, directly or through the modules they require.
+	owners := make(map[string]string)
+	overrides := make(moduleOverrides)
+	var conflicts [][3]string
 	ctx.WalkDeps(func(child Module, parent Module) bool {
 		if !IsInstallDepNeeded(ctx.OtherModuleDependencyTag(child)) {
 			return false
 		}
+		name := ctx.OtherModuleName(child)
+		overrides.add(name, child)
 		for _, ps := range child.PackagingSpecs() {
-			if _, ok := m[ps.relPathInPackage]; !ok {
+			if existing, ok := m[ps.relPathInPackage]; !ok {
 				m[ps.relPathInPackage] = ps
+				owners[ps.relPathInPackage] = name
+			} else if existing.source() != ps.source() {
+				conflicts = append(conflicts, [3]string{owners[ps.relPathInPackage], name, ps.relPathInPackage})
 			}
 		}
 		return true
 	})
+	for _, conflict := range conflicts {
+		if overrides.conflicts(conflict[0], conflict[1]) {
+			ctx.ModuleErrorf("modules %q and %q both package %q", conflict[0], conflict[1], conflict[2])
+		}
+	}
 
 	builder := NewRuleBuilder(pctx, ctx)
 
@@ -204,6 +249,21 @@ func (p *PackagingBase) CopyDepsToZip(ctx ModuleContext, zipOut OutputPath) (ent
 	return entries
 }
 
+// See PackageModule.BuildLicenseMetadata
+func (p *PackagingBase) BuildLicenseMetadata(ctx ModuleContext) OutputPath {
+	var contents []Module
+	ctx.WalkDeps(func(child Module, parent Module) bool {
+		if !IsInstallDepNeeded(ctx.OtherModuleDependencyTag(child)) {
+			return false
+		}
+		if len(child.PackagingSpecs()) > 0 {
+			contents = append(contents, child)
+		}
+		return true
+	})
+	return BuildLicenseMetadata(ctx, contents)
+}
+
 // packagingSpecsDepSet is a thin type-safe wrapper around the generic depSet.  It always uses
 // topological order.
 type packagingSpecsDepSet struct {
@@ -25,7 +25,9 @@ import (
 type componentTestModule struct {
 	ModuleBase
 	props struct {
-		Deps []string
+		Deps      []string
+		Stem      *string
+		Overrides []string
 	}
 }
 
@@ -50,7 +52,11 @@ func (m *componentTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
 	builtFile := PathForModuleOut(ctx, m.Name())
 	dir := ctx.Target().Arch.ArchType.Multilib
 	installDir := PathForModuleInstall(ctx, dir)
-	ctx.InstallFile(installDir, m.Name(), builtFile)
+	ctx.InstallFile(installDir, StringDefault(m.props.Stem, m.Name()), builtFile)
+}
+
+func (m *componentTestModule) Overrides() []string {
+	return m.props.Overrides
 }
 
 // Module that itself is a package
@@ -58,7 +64,8 @@ type packageTestModule struct {
 	ModuleBase
 	PackagingBase
 
-	entries []string
+	entries         []string
+	licenseMetadata OutputPath
 }
 
 func packageTestModuleFactory() Module {
@@ -75,9 +82,10 @@ func (m *packageTestModule) DepsMutator(ctx BottomUpMutatorContext) {
 func (m *packageTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
 	zipFile := PathForModuleOut(ctx, "myzip.zip").OutputPath
 	m.entries = m.CopyDepsToZip(ctx, zipFile)
+	m.licenseMetadata = m.BuildLicenseMetadata(ctx)
 }
 
-func runPackagingTest(t *testing.T, bp string, expected []string) {
+func preparePackagingTest(t *testing.T, bp string) (*TestContext, []error) {
 	t.Helper()
 
 	config := TestArchConfig(buildDir, nil, bp, nil)
@@ -90,6 +98,13 @@ func runPackagingTest(t *testing.T, bp string, expected []string) {
 	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
 	FailIfErrored(t, errs)
 	_, errs = ctx.PrepareBuildActions(config)
+	return ctx, errs
+}
+
+func runPackagingTest(t *testing.T, bp string, expected []string) {
+	t.Helper()
+
+	ctx, errs := preparePackagingTest(t, bp)
 	FailIfErrored(t, errs)
 
 	p := ctx.ModuleForTests("package", "android_common").Module().(*packageTestModule)
@@ -194,3 +209,53 @@ func TestPackagingBase(t *testing.T) {
 		}
 		`, []string{"lib32/foo", "lib64/foo", "lib64/bar"})
 }
+
+func TestPackagingConflicts(t *testing.T) {
+	bp := `
+		component {
+			name: "foo",
+			stem: "tool",
+			deps: ["bar"],
+		}
+
+		// Packaged through foo, which requires it.
+		component {
+			name: "bar",
+			stem: "tool",
+		}
+
+		package_module {
+			name: "package",
+			deps: ["foo"],
+		}
+	`
+	_, errs := preparePackagingTest(t, bp)
+	FailIfNoMatchingErrors(t, `modules "foo" and "bar" both package "lib64/tool"`, errs)
+
+	// An overriding module is allowed to package the same path as the modules it replaces,
+	// including through a chain of overrides.
+	runPackagingTest(t, `
+		component {
+			name: "foo",
+			stem: "tool",
+			deps: ["bar", "baz"],
+			overrides: ["bar"],
+		}
+
+		component {
+			name: "bar",
+			stem: "tool",
+			overrides: ["baz"],
+		}
+
+		component {
+			name: "baz",
+			stem: "tool",
+		}
+
+		package_module {
+			name: "package",
+			deps: ["foo"],
+		}
+	`, []string{"lib64/tool"})
+}
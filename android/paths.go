@@ -415,6 +415,11 @@ func expandOneSrcPath(ctx ModuleContext, s string, expandedExcludes []string) (P
 		}
 	} else if pathtools.IsGlob(s) {
 		paths := ctx.GlobFiles(pathForModuleSrc(ctx, s).String(), expandedExcludes)
+		for _, p := range paths {
+			if err := checkPackageBoundary(ctx, p.String()); err != nil {
+				return nil, err
+			}
+		}
 		return PathsWithModuleSrcSubDir(ctx, paths, ""), nil
 	} else {
 		p := pathForModuleSrc(ctx, s)
@@ -427,10 +432,114 @@ func expandOneSrcPath(ctx ModuleContext, s string, expandedExcludes []string) (P
 		if InList(p.String(), expandedExcludes) {
 			return nil, nil
 		}
+		if err := checkPackageBoundary(ctx, p.String()); err != nil {
+			return nil, err
+		}
 		return Paths{p}, nil
 	}
 }
 
+// PackageBoundaryError is returned when a module references a source file that is in a
+// subdirectory of the module's directory containing its own Android.bp file, and so belongs to
+// a different package.
+type PackageBoundaryError struct {
+	// Path is the referenced source file, relative to the root of the source tree.
+	Path string
+	// ModuleDir is the directory of the module referencing the file.
+	ModuleDir string
+	// PackageDir is the directory of the package that contains the file.
+	PackageDir string
+}
+
+func (e PackageBoundaryError) Error() string {
+	return fmt.Sprintf("source path %q belongs to package %q, not %q; "+
+		"reference it through a filegroup in %q that is visible to this module instead",
+		e.Path, e.PackageDir, e.ModuleDir, e.PackageDir)
+}
+
+// checkPackageBoundary returns a PackageBoundaryError if the source file at path is in a
+// subdirectory of the module's directory that contains an Android.bp file. The check is only
+// enforced for modules in the directories listed in EnforcePackageBoundariesDirs, to allow
+// existing violations to be fixed before it is turned on everywhere.
+func checkPackageBoundary(ctx ModuleContext, path string) error {
+	if !ctx.Config().EnforcePackageBoundaries(ctx.ModuleDir()) {
+		return nil
+	}
+	srcDir := ctx.Config().srcDir
+	moduleDir := filepath.Join(srcDir, ctx.ModuleDir())
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, moduleDir+"/"); dir = filepath.Dir(dir) {
+		if exists, _, _ := ctx.Config().fs.Exists(filepath.Join(dir, "Android.bp")); exists {
+			return PackageBoundaryError{
+				Path:       Rel(ctx, srcDir, path),
+				ModuleDir:  ctx.ModuleDir(),
+				PackageDir: Rel(ctx, srcDir, dir),
+			}
+		}
+	}
+	return nil
+}
+
+// SourceSandboxError is returned when a module in a directory listed in
+// EnforcePackageBoundariesDirs references a source path outside of its own directory that is not
+// in PackageBoundariesAllowList.
+type SourceSandboxError struct {
+	// Path is the referenced source path, relative to the root of the source tree.
+	Path string
+	// ModuleDir is the directory of the module referencing the path.
+	ModuleDir string
+}
+
+func (e SourceSandboxError) Error() string {
+	return fmt.Sprintf("source path %q is outside of the module directory %q; "+
+		"add it to PackageBoundariesAllowList or reference it through a filegroup that is visible to this module instead",
+		e.Path, e.ModuleDir)
+}
+
+// isPathUnder returns true if path is dir or is inside dir.  Both must be relative to the root of
+// the source tree.
+func isPathUnder(path, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	return dir == "." || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// checkSourceSandbox returns a SourceSandboxError if the module's directory is in
+// EnforcePackageBoundariesDirs and path, relative to the root of the source tree, is neither under
+// the module's directory nor under one of the paths in PackageBoundariesAllowList.
+func checkSourceSandbox(ctx ModuleContext, path string) error {
+	if !ctx.Config().EnforcePackageBoundaries(ctx.ModuleDir()) || isPathUnder(path, ctx.ModuleDir()) {
+		return nil
+	}
+	for _, allowed := range ctx.Config().PackageBoundariesAllowList() {
+		if isPathUnder(path, allowed) {
+			return nil
+		}
+	}
+	return SourceSandboxError{Path: filepath.Clean(path), ModuleDir: ctx.ModuleDir()}
+}
+
+// PathsForSandboxedSource is like PathsForSource, but is intended for paths relative to the root of
+// the source tree that come from the module's properties, such as include_dirs.  If the module's
+// directory is in EnforcePackageBoundariesDirs, paths outside of it that are not in
+// PackageBoundariesAllowList are reported as errors on the given property.
+func PathsForSandboxedSource(ctx ModuleContext, property string, paths []string) Paths {
+	for _, path := range paths {
+		if err := checkSourceSandbox(ctx, path); err != nil {
+			ctx.PropertyErrorf(property, "%s", err.Error())
+		}
+	}
+	return PathsForSource(ctx, paths)
+}
+
+// ExistentPathForSandboxedSource is like ExistentPathForSource, but reports an error if the path
+// is outside of the module's sandbox as described in PathsForSandboxedSource.
+func ExistentPathForSandboxedSource(ctx ModuleContext, property string, pathComponents ...string) OptionalPath {
+	if err := checkSourceSandbox(ctx, filepath.Join(pathComponents...)); err != nil {
+		ctx.PropertyErrorf(property, "%s", err.Error())
+		return OptionalPath{}
+	}
+	return ExistentPathForSource(ctx, pathComponents...)
+}
+
 // pathsForModuleSrcFromFullPath returns Paths rooted from the module's local
 // source directory, but strip the local source directory from the beginning of
 // each string. If incDirs is false, strip paths with a trailing '/' from the list.
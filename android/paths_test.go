@@ -1261,6 +1261,106 @@ func TestPathsForModuleSrc_AllowMissingDependencies(t *testing.T) {
 	}
 }
 
+func TestPathsForModuleSrc_PackageBoundaries(t *testing.T) {
+	bp := `
+		test {
+			name: "foo",
+			srcs: ["src/b", "sub/c"],
+		}
+	`
+
+	mockFS := map[string][]byte{
+		"foo/Android.bp":     []byte(bp),
+		"foo/src/b":          nil,
+		"foo/sub/Android.bp": nil,
+		"foo/sub/c":          nil,
+	}
+
+	for _, enforce := range []bool{false, true} {
+		config := TestConfig(buildDir, nil, "", mockFS)
+		if enforce {
+			config.TestProductVariables.EnforcePackageBoundariesDirs = []string{"foo"}
+		}
+
+		ctx := NewTestContext(config)
+		ctx.RegisterModuleType("test", pathForModuleSrcTestModuleFactory)
+		ctx.Register()
+
+		_, errs := ctx.ParseFileList(".", []string{"foo/Android.bp"})
+		FailIfErrored(t, errs)
+		_, errs = ctx.PrepareBuildActions(config)
+
+		if enforce {
+			CheckErrorsAgainstExpectations(t, errs, []string{
+				`source path "foo/sub/c" belongs to package "foo/sub", not "foo"`,
+			})
+		} else {
+			FailIfErrored(t, errs)
+		}
+	}
+}
+
+func TestSourceSandbox(t *testing.T) {
+	bp := `
+		test {
+			name: "foo",
+			notice: "../other/NOTICE",
+		}
+	`
+
+	mockFS := map[string][]byte{
+		"foo/Android.bp": []byte(bp),
+		"other/NOTICE":   nil,
+	}
+
+	tests := []struct {
+		name      string
+		dirs      []string
+		allowList []string
+		err       string
+	}{
+		{
+			name: "not enforced",
+		},
+		{
+			name: "enforced",
+			dirs: []string{"foo"},
+			err:  `notice: source path "other/NOTICE" is outside of the module directory "foo"`,
+		},
+		{
+			name: "enforced for another directory with the same prefix",
+			dirs: []string{"fo"},
+		},
+		{
+			name:      "allowed",
+			dirs:      []string{"foo"},
+			allowList: []string{"other"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := TestConfig(buildDir, nil, "", mockFS)
+			config.TestProductVariables.EnforcePackageBoundariesDirs = test.dirs
+			config.TestProductVariables.PackageBoundariesAllowList = test.allowList
+
+			ctx := NewTestContext(config)
+			ctx.RegisterModuleType("test", pathForModuleSrcTestModuleFactory)
+			ctx.Register()
+
+			_, errs := ctx.ParseFileList(".", []string{"foo/Android.bp"})
+			FailIfErrored(t, errs)
+			_, errs = ctx.PrepareBuildActions(config)
+
+			if test.err != "" {
+				CheckErrorsAgainstExpectations(t, errs, []string{test.err})
+			} else {
+				FailIfErrored(t, errs)
+			}
+		})
+	}
+}
+
 func ExampleOutputPath_ReplaceExtension() {
 	ctx := &configErrorWrapper{
 		config: TestConfig("out", nil, "", nil),
@@ -15,9 +15,13 @@
 package android
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
@@ -26,6 +30,10 @@ import (
 // This file implements common functionality for handling modules that may exist as prebuilts,
 // source, or both.
 
+func init() {
+	RegisterSingletonType("prebuilt_replacements", prebuiltReplacementsSingletonFactory)
+}
+
 func RegisterPrebuiltMutators(ctx RegistrationContext) {
 	ctx.PreArchMutators(RegisterPrebuiltsPreArchMutators)
 	ctx.PostDepsMutators(RegisterPrebuiltsPostDepsMutators)
@@ -56,11 +64,26 @@ func (t prebuiltDependencyTag) ExcludeFromApexContents() {}
 var _ ExcludeFromVisibilityEnforcementTag = PrebuiltDepTag
 var _ ExcludeFromApexContentsTag = PrebuiltDepTag
 
+// PrebuiltReplacementVetoer is implemented by source modules that can prevent a preferred
+// prebuilt from replacing them.
+type PrebuiltReplacementVetoer interface {
+	// VetoPrebuiltReplacement returns a non-empty reason if the source module must be used instead
+	// of its preferred prebuilt.
+	VetoPrebuiltReplacement(ctx BaseModuleContext) string
+}
+
 type PrebuiltProperties struct {
 	// When prefer is set to true the prebuilt will be used instead of any source module with
 	// a matching name.
 	Prefer *bool `android:"arch_variant"`
 
+	// Version of the prebuilt, a positive number. Several versions of a prebuilt can coexist with
+	// the source module, and the PrebuiltVersionPolicy product variable picks the one that is used:
+	// "source" uses the source module if it exists and is enabled, and the latest version
+	// otherwise, "latest" uses the latest version, and a number uses that version if it exists.
+	// prefer is ignored for versioned prebuilts.
+	Prebuilt_version *string
+
 	SourceExists bool `blueprint:"mutated"`
 	UsePrebuilt  bool `blueprint:"mutated"`
 
@@ -73,6 +96,13 @@ type Prebuilt struct {
 
 	srcsSupplier     PrebuiltSrcsSupplier
 	srcsPropertyName string
+
+	// The reason the prebuilt is used or not used instead of the source module, set by
+	// PrebuiltSelectModuleMutator.
+	selectionReason string
+
+	// The value of the prebuilt_version property, or 0 if it isn't set.
+	version int
 }
 
 // RemoveOptionalPrebuiltPrefix returns the result of removing the "prebuilt_" prefix from the
@@ -82,6 +112,9 @@ func RemoveOptionalPrebuiltPrefix(name string) string {
 }
 
 func (p *Prebuilt) Name(name string) string {
+	if version := String(p.properties.Prebuilt_version); version != "" {
+		return "prebuilt_" + name + string(SdkVersionSeparator) + version
+	}
 	return "prebuilt_" + name
 }
 
@@ -126,6 +159,11 @@ func (p *Prebuilt) UsePrebuilt() bool {
 	return p.properties.UsePrebuilt
 }
 
+// SelectionReason returns why the prebuilt is used or not used instead of the source module.
+func (p *Prebuilt) SelectionReason() string {
+	return p.selectionReason
+}
+
 // Called to provide the srcs value for the prebuilt module.
 //
 // Return the src value or nil if it is not available.
@@ -211,6 +249,7 @@ type PrebuiltInterface interface {
 }
 
 func RegisterPrebuiltsPreArchMutators(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("prebuilt_versions", PrebuiltVersionsMutator).Parallel()
 	ctx.BottomUp("prebuilt_rename", PrebuiltRenameMutator).Parallel()
 }
 
@@ -220,11 +259,106 @@ func RegisterPrebuiltsPostDepsMutators(ctx RegisterMutatorsContext) {
 	ctx.BottomUp("prebuilt_postdeps", PrebuiltPostDepsMutator).Parallel()
 }
 
+// validatePrebuiltVersionPolicy returns an error if the PrebuiltVersionPolicy product variable is
+// neither "source", "latest" nor a positive number.
+func validatePrebuiltVersionPolicy(policy string) error {
+	if policy == "source" || policy == "latest" {
+		return nil
+	}
+	if n, err := strconv.Atoi(policy); err != nil || n <= 0 {
+		return fmt.Errorf("PrebuiltVersionPolicy %q is neither \"source\", \"latest\" nor a positive number", policy)
+	}
+	return nil
+}
+
+// prebuiltVersionKey identifies the module that versioned prebuilts replace.  Modules with the same
+// name in different namespaces are different modules, each with its own set of versions.
+type prebuiltVersionKey struct {
+	namespace string
+	name      string
+}
+
+// prebuiltVersions records the versions of the versioned prebuilts of each module.
+type prebuiltVersions struct {
+	lock     sync.Mutex
+	versions map[prebuiltVersionKey][]int
+}
+
+var prebuiltVersionsKey = NewOnceKey("prebuiltVersions")
+
+func prebuiltVersionsForConfig(config Config) *prebuiltVersions {
+	return config.Once(prebuiltVersionsKey, func() interface{} {
+		return &prebuiltVersions{versions: make(map[prebuiltVersionKey][]int)}
+	}).(*prebuiltVersions)
+}
+
+// PrebuiltVersionsMutator records the versions of the versioned prebuilts, so that the one to use
+// can be selected once all of them are known.
+func PrebuiltVersionsMutator(ctx BottomUpMutatorContext) {
+	if m, ok := ctx.Module().(PrebuiltInterface); ok && m.Prebuilt() != nil {
+		p := m.Prebuilt()
+		if p.properties.Prebuilt_version == nil {
+			return
+		}
+		version, err := strconv.Atoi(*p.properties.Prebuilt_version)
+		if err != nil || version <= 0 {
+			ctx.PropertyErrorf("prebuilt_version", "%q is not a positive number", *p.properties.Prebuilt_version)
+			return
+		}
+		p.version = version
+
+		versions := prebuiltVersionsForConfig(ctx.Config())
+		versions.lock.Lock()
+		defer versions.lock.Unlock()
+		key := prebuiltVersionKey{ctx.Namespace().Path, m.base().BaseModuleName()}
+		versions.versions[key] = append(versions.versions[key], version)
+	}
+}
+
+// selectedPrebuiltVersion returns the version of the versioned prebuilts of a module that is used,
+// or 0 if the source module is used instead.  sourceUsable is whether the source module exists and
+// is enabled.
+func selectedPrebuiltVersion(config Config, key prebuiltVersionKey, sourceUsable bool) int {
+	versions := prebuiltVersionsForConfig(config)
+	versions.lock.Lock()
+	defer versions.lock.Unlock()
+
+	latest := 0
+	for _, v := range versions.versions[key] {
+		if v > latest {
+			latest = v
+		}
+	}
+
+	switch policy := config.PrebuiltVersionPolicy(); policy {
+	case "latest":
+		return latest
+	case "source":
+	default:
+		n, _ := strconv.Atoi(policy)
+		for _, v := range versions.versions[key] {
+			if v == n {
+				return n
+			}
+		}
+	}
+	if sourceUsable {
+		return 0
+	}
+	return latest
+}
+
 // PrebuiltRenameMutator ensures that there always is a module with an
 // undecorated name.
 func PrebuiltRenameMutator(ctx BottomUpMutatorContext) {
 	if m, ok := ctx.Module().(PrebuiltInterface); ok && m.Prebuilt() != nil {
 		name := m.base().BaseModuleName()
+		p := m.Prebuilt()
+		if p.version != 0 && !ctx.OtherModuleExists(name) &&
+			p.version != selectedPrebuiltVersion(ctx.Config(), prebuiltVersionKey{ctx.Namespace().Path, name}, false) {
+			// Only the selected version replaces the missing source module.
+			return
+		}
 		if !ctx.OtherModuleExists(name) {
 			ctx.Rename(name)
 			m.Prebuilt().properties.PrebuiltRenamedToSource = true
@@ -295,20 +429,111 @@ func PrebuiltPostDepsMutator(ctx BottomUpMutatorContext) {
 }
 
 // usePrebuilt returns true if a prebuilt should be used instead of the source module.  The prebuilt
-// will be used if it is marked "prefer" or if the source module is disabled.
+// will be used if it is marked "prefer" or if the source module is disabled, unless the source
+// module vetoes the replacement.  The reason for the decision is recorded for the
+// prebuilt_replacements report.
 func (p *Prebuilt) usePrebuilt(ctx TopDownMutatorContext, source Module) bool {
 	if p.srcsSupplier != nil && len(p.srcsSupplier(ctx)) == 0 {
+		p.selectionReason = "prebuilt has no sources"
 		return false
 	}
 
+	if p.version != 0 {
+		return p.useVersionedPrebuilt(ctx, source)
+	}
+
+	if source == nil {
+		p.selectionReason = "source module does not exist"
+		return true
+	}
+
+	if !source.Enabled() {
+		p.selectionReason = "source module is disabled"
+		return true
+	}
+
 	// TODO: use p.Properties.Name and ctx.ModuleDir to override preference
 	if Bool(p.properties.Prefer) {
+		if vetoer, ok := source.(PrebuiltReplacementVetoer); ok {
+			if reason := vetoer.VetoPrebuiltReplacement(ctx); reason != "" {
+				p.selectionReason = "replacement vetoed by source module: " + reason
+				return false
+			}
+		}
+		p.selectionReason = "prefer: true"
 		return true
 	}
 
-	return source == nil || !source.Enabled()
+	p.selectionReason = "source module is preferred"
+	return false
+}
+
+// useVersionedPrebuilt returns true if the version of a versioned prebuilt is the one selected by
+// the PrebuiltVersionPolicy product variable.
+func (p *Prebuilt) useVersionedPrebuilt(ctx TopDownMutatorContext, source Module) bool {
+	key := prebuiltVersionKey{ctx.Namespace().Path, ctx.Module().base().BaseModuleName()}
+	sourceUsable := source != nil && source.Enabled()
+	selected := selectedPrebuiltVersion(ctx.Config(), key, sourceUsable)
+	policy := ctx.Config().PrebuiltVersionPolicy()
+	if selected == p.version {
+		p.selectionReason = "prebuilt version " + strconv.Itoa(p.version) + " selected by policy " + policy
+		return true
+	}
+	if selected == 0 {
+		p.selectionReason = "source module selected by policy " + policy
+	} else {
+		p.selectionReason = "prebuilt version " + strconv.Itoa(selected) + " selected by policy " + policy
+	}
+	return false
 }
 
 func (p *Prebuilt) SourceExists() bool {
 	return p.properties.SourceExists
 }
+
+// PrebuiltReplacement describes whether a prebuilt module variant is used instead of the source
+// module with the same name, and why.
+type PrebuiltReplacement struct {
+	Name    string
+	Variant string
+	Used    bool
+	Reason  string
+}
+
+func prebuiltReplacementsSingletonFactory() Singleton {
+	return &prebuiltReplacementsSingleton{}
+}
+
+// prebuiltReplacementsSingleton writes $OUT/soong/prebuilt_replacements.json, which lists every
+// prebuilt module variant along with whether it replaces its source module and why.
+type prebuiltReplacementsSingleton struct{}
+
+func (s *prebuiltReplacementsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	var replacements []PrebuiltReplacement
+	ctx.VisitAllModules(func(module Module) {
+		m, ok := module.(PrebuiltInterface)
+		if !ok || m.Prebuilt() == nil || !module.Enabled() {
+			return
+		}
+		replacements = append(replacements, PrebuiltReplacement{
+			Name:    module.base().BaseModuleName(),
+			Variant: ctx.ModuleSubDir(module),
+			Used:    m.Prebuilt().UsePrebuilt(),
+			Reason:  m.Prebuilt().SelectionReason(),
+		})
+	})
+
+	sort.SliceStable(replacements, func(i, j int) bool {
+		if replacements[i].Name != replacements[j].Name {
+			return replacements[i].Name < replacements[j].Name
+		}
+		return replacements[i].Variant < replacements[j].Variant
+	})
+
+	content, err := json.MarshalIndent(replacements, "", "  ")
+	if err != nil {
+		ctx.Errorf("JSON marshal of prebuilt replacements failed: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "prebuilt_replacements.json"), string(content))
+}
@@ -15,10 +15,13 @@
 package android
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 )
 
 var prebuiltsTests = []struct {
@@ -380,6 +383,296 @@ func TestPrebuilts(t *testing.T) {
 	}
 }
 
+func TestPrebuiltReplacements(t *testing.T) {
+	bp := `
+		source {
+			name: "bar",
+		}
+
+		prebuilt {
+			name: "bar",
+			prefer: true,
+			srcs: ["prebuilt_file"],
+		}
+
+		source {
+			name: "baz",
+		}
+
+		prebuilt {
+			name: "baz",
+			srcs: ["prebuilt_file"],
+		}
+
+		prebuilt {
+			name: "qux",
+			srcs: ["prebuilt_file"],
+		}
+
+		veto_source {
+			name: "vetoed",
+			veto: "needs local changes",
+		}
+
+		prebuilt {
+			name: "vetoed",
+			prefer: true,
+			srcs: ["prebuilt_file"],
+		}
+	`
+	fs := map[string][]byte{
+		"prebuilt_file": nil,
+		"source_file":   nil,
+	}
+	config := TestArchConfig(buildDir, nil, bp, fs)
+
+	ctx := NewTestArchContext(config)
+	registerTestPrebuiltBuildComponents(ctx)
+	ctx.RegisterModuleType("veto_source", newVetoSourceModule)
+	ctx.RegisterSingletonType("prebuilt_replacements", prebuiltReplacementsSingletonFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseBlueprintsFiles("Android.bp")
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	content := ContentFromFileRuleForTests(t, ctx.SingletonForTests("prebuilt_replacements").Output("prebuilt_replacements.json"))
+	var replacements []PrebuiltReplacement
+	if err := json.Unmarshal([]byte(content), &replacements); err != nil {
+		t.Fatalf("failed to parse %q: %s", content, err)
+	}
+
+	var got []PrebuiltReplacement
+	for _, r := range replacements {
+		if r.Variant == "android_common" {
+			got = append(got, r)
+		}
+	}
+
+	expected := []PrebuiltReplacement{
+		{"bar", "android_common", true, "prefer: true"},
+		{"baz", "android_common", false, "source module is preferred"},
+		{"qux", "android_common", true, "source module does not exist"},
+		{"vetoed", "android_common", false, "replacement vetoed by source module: needs local changes"},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected prebuilt replacements:\n  %#v\ngot:\n  %#v", expected, got)
+	}
+}
+
+func TestVersionedPrebuilts(t *testing.T) {
+	const versionedPrebuilts = `
+		prebuilt {
+			name: "bar",
+			prebuilt_version: "1",
+			srcs: ["prebuilt_file_1"],
+		}
+
+		prebuilt {
+			name: "bar",
+			prebuilt_version: "2",
+			srcs: ["prebuilt_file_2"],
+		}
+
+		source {
+			name: "foo",
+			deps: [":bar"],
+		}
+	`
+	const sourceBar = `
+		source {
+			name: "bar",
+		}
+	`
+
+	testCases := []struct {
+		name     string
+		bp       string
+		policy   string
+		expected string
+	}{
+		{
+			name:     "source by default",
+			bp:       versionedPrebuilts + sourceBar,
+			expected: "source_file",
+		},
+		{
+			name:     "source",
+			bp:       versionedPrebuilts + sourceBar,
+			policy:   "source",
+			expected: "source_file",
+		},
+		{
+			name:     "latest",
+			bp:       versionedPrebuilts + sourceBar,
+			policy:   "latest",
+			expected: "prebuilt_file_2",
+		},
+		{
+			name:     "version",
+			bp:       versionedPrebuilts + sourceBar,
+			policy:   "1",
+			expected: "prebuilt_file_1",
+		},
+		{
+			name:     "missing version",
+			bp:       versionedPrebuilts + sourceBar,
+			policy:   "3",
+			expected: "source_file",
+		},
+		{
+			name:     "no source",
+			bp:       versionedPrebuilts,
+			policy:   "source",
+			expected: "prebuilt_file_2",
+		},
+		{
+			name:     "no source with version",
+			bp:       versionedPrebuilts,
+			policy:   "1",
+			expected: "prebuilt_file_1",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			fs := map[string][]byte{
+				"prebuilt_file_1": nil,
+				"prebuilt_file_2": nil,
+				"source_file":     nil,
+			}
+			config := TestArchConfig(buildDir, nil, test.bp, fs)
+			if test.policy != "" {
+				config.TestProductVariables.PrebuiltVersionPolicy = proptools.StringPtr(test.policy)
+			}
+
+			ctx := NewTestArchContext(config)
+			registerTestPrebuiltBuildComponents(ctx)
+			ctx.Register()
+
+			_, errs := ctx.ParseBlueprintsFiles("Android.bp")
+			FailIfErrored(t, errs)
+			_, errs = ctx.PrepareBuildActions(config)
+			FailIfErrored(t, errs)
+
+			deps := ctx.ModuleForTests("foo", "android_common").Module().(*sourceModule).deps
+			if len(deps) != 1 || deps[0].String() != test.expected {
+				t.Errorf("expected foo to use %q, got %q", test.expected, deps)
+			}
+		})
+	}
+}
+
+func TestVersionedPrebuiltsInNamespaces(t *testing.T) {
+	// Versions of modules with the same name in different namespaces are selected independently.
+	fs := map[string][]byte{
+		"Android.bp": nil,
+		"a/Android.bp": []byte(`
+			soong_namespace {}
+
+			prebuilt {
+				name: "bar",
+				prebuilt_version: "1",
+				srcs: ["prebuilt_file_1"],
+			}
+
+			prebuilt {
+				name: "bar",
+				prebuilt_version: "2",
+				srcs: ["prebuilt_file_2"],
+			}
+
+			source {
+				name: "bar",
+			}
+
+			source {
+				name: "foo_a",
+				deps: [":bar"],
+			}
+		`),
+		"b/Android.bp": []byte(`
+			soong_namespace {}
+
+			prebuilt {
+				name: "bar",
+				prebuilt_version: "1",
+				srcs: ["prebuilt_file_1"],
+			}
+
+			source {
+				name: "bar",
+			}
+
+			source {
+				name: "foo_b",
+				deps: [":bar"],
+			}
+		`),
+		"a/prebuilt_file_1": nil,
+		"a/prebuilt_file_2": nil,
+		"a/source_file":     nil,
+		"b/prebuilt_file_1": nil,
+		"b/source_file":     nil,
+	}
+	config := TestArchConfig(buildDir, nil, "", fs)
+	config.TestProductVariables.PrebuiltVersionPolicy = proptools.StringPtr("latest")
+
+	ctx := NewTestArchContext(config)
+	ctx.RegisterModuleType("soong_namespace", NamespaceFactory)
+	ctx.PreArchMutators(RegisterNamespaceMutator)
+	registerTestPrebuiltBuildComponents(ctx)
+	ctx.Register()
+
+	_, errs := ctx.ParseBlueprintsFiles("Android.bp")
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfErrored(t, errs)
+
+	for name, expected := range map[string]string{
+		"foo_a": "a/prebuilt_file_2",
+		"foo_b": "b/prebuilt_file_1",
+	} {
+		deps := ctx.ModuleForTests(name, "android_common").Module().(*sourceModule).deps
+		if len(deps) != 1 || deps[0].String() != expected {
+			t.Errorf("expected %s to use %q, got %q", name, expected, deps)
+		}
+	}
+}
+
+func TestValidatePrebuiltVersionPolicy(t *testing.T) {
+	for _, policy := range []string{"source", "latest", "1", "42"} {
+		if err := validatePrebuiltVersionPolicy(policy); err != nil {
+			t.Errorf("expected policy %q to be valid, got %s", policy, err)
+		}
+	}
+	for _, policy := range []string{"", "next", "0", "-1"} {
+		if err := validatePrebuiltVersionPolicy(policy); err == nil {
+			t.Errorf("expected policy %q to be rejected", policy)
+		}
+	}
+}
+
+func TestVersionedPrebuiltsErrors(t *testing.T) {
+	bp := `
+		prebuilt {
+			name: "bar",
+			prebuilt_version: "next",
+			srcs: ["prebuilt_file"],
+		}
+	`
+	config := TestArchConfig(buildDir, nil, bp, nil)
+	ctx := NewTestArchContext(config)
+	registerTestPrebuiltBuildComponents(ctx)
+	ctx.Register()
+
+	_, errs := ctx.ParseBlueprintsFiles("Android.bp")
+	FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	FailIfNoMatchingErrors(t, `prebuilt_version: "next" is not a positive number`, errs)
+}
+
 func registerTestPrebuiltBuildComponents(ctx RegistrationContext) {
 	ctx.RegisterModuleType("prebuilt", newPrebuiltModule)
 	ctx.RegisterModuleType("source", newSourceModule)
@@ -481,3 +774,22 @@ func newOverrideSourceModule() Module {
 	InitOverrideModule(m)
 	return m
 }
+
+type vetoSourceModule struct {
+	sourceModule
+	vetoProperties struct {
+		Veto *string
+	}
+}
+
+func newVetoSourceModule() Module {
+	m := &vetoSourceModule{}
+	m.AddProperties(&m.properties, &m.vetoProperties)
+	InitAndroidArchModule(m, HostAndDeviceDefault, MultilibCommon)
+	InitOverridableModule(m, nil)
+	return m
+}
+
+func (s *vetoSourceModule) VetoPrebuiltReplacement(ctx BaseModuleContext) string {
+	return String(s.vetoProperties.Veto)
+}
@@ -66,7 +66,7 @@ func GetProtoFlags(ctx ModuleContext, p *ProtoProperties) ProtoFlags {
 		flags = append(flags, JoinWithPrefix(localProtoIncludeDirs.Strings(), "-I"))
 	}
 	if len(p.Proto.Include_dirs) > 0 {
-		rootProtoIncludeDirs := PathsForSource(ctx, p.Proto.Include_dirs)
+		rootProtoIncludeDirs := PathsForSandboxedSource(ctx, "proto.include_dirs", p.Proto.Include_dirs)
 		flags = append(flags, JoinWithPrefix(rootProtoIncludeDirs.Strings(), "-I"))
 	}
 
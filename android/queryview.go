@@ -73,8 +73,10 @@ func generateBuildActionsForBazelConversion(ctx SingletonContext, converterMode
 	bazelQueryView := ctx.Rule(pctx, "bazelQueryView",
 		blueprint.RuleParams{
 			Command: fmt.Sprintf(
-				"rm -rf ${outDir}/* && "+
-					"%s %s --bazel_queryview_dir ${outDir} %s && "+
+				// soong_build only rewrites the files in ${outDir} that changed, so touch the
+				// WORKSPACE file that marks the output as up to date.
+				"%s %s --bazel_queryview_dir ${outDir} %s && "+
+					"touch ${outDir}/WORKSPACE && "+
 					"echo WORKSPACE: `cat %s` > ${outDir}/.queryview-depfile.d",
 				additionalEnvVars,
 				primaryBuilder.String(),
@@ -50,6 +50,7 @@ type RuleBuilder struct {
 	remoteable       RemoteRuleSupports
 	outDir           WritablePath
 	sboxTools        bool
+	sboxInputs       bool
 	sboxManifestPath WritablePath
 	missingDeps      []string
 }
@@ -155,6 +156,18 @@ func (r *RuleBuilder) SandboxTools() *RuleBuilder {
 	return r
 }
 
+// SandboxInputs enables input sandboxing for the rule by copying every input into the sandbox at
+// the same relative path and running the command from the top of the sandbox directory, so that
+// the command fails if it reads a file that was not declared as an input.  It requires
+// SandboxTools().
+func (r *RuleBuilder) SandboxInputs() *RuleBuilder {
+	if !r.sboxTools {
+		panic("SandboxInputs() must be called after SandboxTools()")
+	}
+	r.sboxInputs = true
+	return r
+}
+
 // Install associates an output of the rule with an install location, which can be retrieved later using
 // RuleBuilder.Installs.
 func (r *RuleBuilder) Install(from Path, to string) {
@@ -504,6 +517,19 @@ func (r *RuleBuilder) Build(name string, desc string) {
 			}
 		}
 
+		// If sandboxing inputs is enabled, add copy rules to the manifest to copy each input into
+		// the sbox directory, and run the command from there so that undeclared inputs can't be
+		// found.
+		if r.sboxInputs {
+			command.Chdir = proto.Bool(true)
+			for _, input := range inputs {
+				command.CopyBefore = append(command.CopyBefore, &sbox_proto.Copy{
+					From: proto.String(input.String()),
+					To:   proto.String(input.String()),
+				})
+			}
+		}
+
 		// Add copy rules to the manifest to copy each output file from the sbox directory.
 		// to the output directory after running the commands.
 		sboxOutputs := make([]string, len(outputs))
@@ -15,6 +15,8 @@
 package android
 
 import (
+	"time"
+
 	"github.com/google/blueprint"
 )
 
@@ -106,7 +108,9 @@ func (s *singletonAdaptor) GenerateBuildActions(ctx blueprint.SingletonContext)
 		sctx.ruleParams = make(map[blueprint.Rule]blueprint.RuleParams)
 	}
 
+	start := time.Now()
 	s.Singleton.GenerateBuildActions(sctx)
+	soongMetricsEventsForConfig(sctx.Config()).event("singleton", ctx.Name()).addSpan(start, time.Now())
 
 	s.buildParams = sctx.buildParams
 	s.ruleParams = sctx.ruleParams
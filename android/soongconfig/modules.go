@@ -491,12 +491,20 @@ func (s *stringVariable) initializeProperties(v reflect.Value, typ reflect.Type)
 }
 
 func (s *stringVariable) PropertiesToApply(config SoongConfig, values reflect.Value) (interface{}, error) {
+	configValue := config.String(s.variable)
 	for j, v := range s.values {
-		if config.String(s.variable) == v {
+		if configValue == v {
 			return values.Field(j).Interface(), nil
 		}
 	}
 
+	// A value that isn't listed is most likely a typo in the board config, which would otherwise
+	// silently leave the properties of every value unapplied.
+	if configValue != "" {
+		return nil, fmt.Errorf("soong_config_variables.%s: invalid value %q, must be one of %q",
+			s.variable, configValue, s.values)
+	}
+
 	return nil, nil
 }
 
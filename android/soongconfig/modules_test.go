@@ -318,3 +318,77 @@ func Test_PropertiesToApply(t *testing.T) {
 		}
 	}
 }
+
+func Test_PropertiesToApplyStringVariable(t *testing.T) {
+	type boardValues struct {
+		Soc_a interface{}
+		Soc_b interface{}
+	}
+	type stringVarProps struct {
+		Soong_config_variables struct {
+			Board boardValues
+		}
+	}
+
+	mt := &ModuleType{
+		BaseModuleType:  "foo",
+		ConfigNamespace: "bar",
+		Variables: []soongConfigVariable{
+			&stringVariable{
+				baseVariable: baseVariable{variable: "board"},
+				values:       []string{"soc_a", "soc_b"},
+			},
+		},
+		affectableProperties: []string{"a"},
+	}
+	props := stringVarProps{}
+	props.Soong_config_variables.Board = boardValues{
+		Soc_a: &properties{A: proptools.StringPtr("a")},
+		Soc_b: &properties{A: proptools.StringPtr("b")},
+	}
+
+	testCases := []struct {
+		name      string
+		config    SoongConfig
+		wantProps []interface{}
+		wantErr   string
+	}{
+		{
+			name:   "unset",
+			config: Config(map[string]string{}),
+		},
+		{
+			name:   "empty",
+			config: Config(map[string]string{"board": ""}),
+		},
+		{
+			name:      "soc_b",
+			config:    Config(map[string]string{"board": "soc_b"}),
+			wantProps: []interface{}{props.Soong_config_variables.Board.Soc_b},
+		},
+		{
+			name:    "invalid",
+			config:  Config(map[string]string{"board": "soc_c"}),
+			wantErr: `soong_config_variables.board: invalid value "soc_c", must be one of ["soc_a" "soc_b"]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gotProps, err := PropertiesToApply(mt, reflect.ValueOf(&props), tc.config)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("Expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error in PropertiesToApply: %s", err)
+			}
+
+			if !reflect.DeepEqual(gotProps, tc.wantProps) {
+				t.Errorf("Expected %s, got %s", tc.wantProps, gotProps)
+			}
+		})
+	}
+}
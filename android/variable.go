@@ -47,6 +47,7 @@ type variableProperties struct {
 			Shared_libs         []string `android:"arch_variant"`
 			Whole_static_libs   []string `android:"arch_variant"`
 			Exclude_static_libs []string `android:"arch_variant"`
+			Exclude_shared_libs []string `android:"arch_variant"`
 		} `android:"arch_variant"`
 
 		Malloc_zero_contents struct {
@@ -126,13 +127,15 @@ type variableProperties struct {
 		}
 
 		Arc struct {
-			Cflags            []string `android:"arch_variant"`
-			Exclude_srcs      []string `android:"arch_variant"`
-			Include_dirs      []string `android:"arch_variant"`
-			Shared_libs       []string `android:"arch_variant"`
-			Static_libs       []string `android:"arch_variant"`
-			Srcs              []string `android:"arch_variant"`
-			Whole_static_libs []string `android:"arch_variant"`
+			Cflags              []string `android:"arch_variant"`
+			Exclude_srcs        []string `android:"arch_variant"`
+			Include_dirs        []string `android:"arch_variant"`
+			Shared_libs         []string `android:"arch_variant"`
+			Static_libs         []string `android:"arch_variant"`
+			Srcs                []string `android:"arch_variant"`
+			Whole_static_libs   []string `android:"arch_variant"`
+			Exclude_shared_libs []string `android:"arch_variant"`
+			Exclude_static_libs []string `android:"arch_variant"`
 		} `android:"arch_variant"`
 
 		Flatten_apex struct {
@@ -233,6 +236,8 @@ type productVariables struct {
 	Malloc_pattern_fill_contents *bool `json:",omitempty"`
 	Safestack                    *bool `json:",omitempty"`
 	HostStaticBinaries           *bool `json:",omitempty"`
+	HostHermeticBinaries         *bool `json:",omitempty"`
+	SizeOptimizedLinking         *bool `json:",omitempty"`
 	Binder32bit                  *bool `json:",omitempty"`
 	UseGoma                      *bool `json:",omitempty"`
 	UseRBE                       *bool `json:",omitempty"`
@@ -261,6 +266,7 @@ type productVariables struct {
 	EnableCFI       *bool    `json:",omitempty"`
 	CFIExcludePaths []string `json:",omitempty"`
 	CFIIncludePaths []string `json:",omitempty"`
+	CFIDiagPaths    []string `json:",omitempty"`
 
 	DisableScudo *bool `json:",omitempty"`
 
@@ -307,6 +313,7 @@ type productVariables struct {
 	NamespacesToExport []string `json:",omitempty"`
 
 	PgoAdditionalProfileDirs []string `json:",omitempty"`
+	PgoInstrumentBenchmarks  []string `json:",omitempty"`
 
 	VndkUseCoreVariant         *bool `json:",omitempty"`
 	VndkSnapshotBuildArtifacts *bool `json:",omitempty"`
@@ -331,7 +338,8 @@ type productVariables struct {
 	CompressedApex               *bool `json:",omitempty"`
 	Aml_abis                     *bool `json:",omitempty"`
 
-	DexpreoptGlobalConfig *string `json:",omitempty"`
+	DexpreoptGlobalConfig *string  `json:",omitempty"`
+	BootImageProfiles     []string `json:",omitempty"`
 
 	ManifestPackageNameOverrides []string `json:",omitempty"`
 	CertificateOverrides         []string `json:",omitempty"`
@@ -360,12 +368,26 @@ type productVariables struct {
 
 	InstallExtraFlattenedApexes *bool `json:",omitempty"`
 
+	PrebuiltVersionPolicy *string `json:",omitempty"`
+
 	BoardUsesRecoveryAsBoot *bool `json:",omitempty"`
 
 	BoardKernelBinaries                []string `json:",omitempty"`
 	BoardKernelModuleInterfaceVersions []string `json:",omitempty"`
 
 	BoardMoveRecoveryResourcesToVendorBoot *bool `json:",omitempty"`
+
+	BoardRamdiskCompression      *string `json:",omitempty"`
+	BoardRamdiskCompressionLevel *int64  `json:",omitempty"`
+
+	// Names of the feature_flag_values modules that set the values of build time feature flags
+	// for this release configuration.
+	Release_feature_flag_values []string `json:",omitempty"`
+
+	// Directories whose modules may only reference source paths in their own package, or under
+	// one of the paths in PackageBoundariesAllowList.
+	EnforcePackageBoundariesDirs []string `json:",omitempty"`
+	PackageBoundariesAllowList   []string `json:",omitempty"`
 }
 
 func boolPtr(v bool) *bool {
@@ -783,7 +783,8 @@ var visibilityTests = []struct {
 				}`),
 		},
 		expectedErrors: []string{
-			`module "libnamespace" variant "android_common": depends on //top:libexample which is not visible to this module`,
+			`module "libnamespace" variant "android_common": depends on //top:libexample which is not visible to this module\n` +
+				`You may need to add "//namespace" to its visibility, which is \[//visibility:private\]`,
 		},
 	},
 	{
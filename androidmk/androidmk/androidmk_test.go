@@ -17,6 +17,7 @@ package androidmk
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -1466,3 +1467,59 @@ func TestEndToEnd(t *testing.T) {
 		}
 	}
 }
+
+func TestAssessFile(t *testing.T) {
+	ready, errs := AssessFile("<ready>", bytes.NewBufferString(`
+LOCAL_PATH := $(call my-dir)
+include $(CLEAR_VARS)
+LOCAL_MODULE := foo
+LOCAL_SRC_FILES := foo.c
+include $(BUILD_EXECUTABLE)
+`))
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %q", errs)
+	}
+	if g, w := ready.ModuleTypes, []string{"cc_binary"}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected module types %q, got %q", w, g)
+	}
+	if g, w := ready.Readiness(), "ready"; g != w {
+		t.Errorf("expected readiness %q, got %q (score %d)", w, g, ready.Score())
+	}
+
+	hard, errs := AssessFile("<hard>", bytes.NewBufferString(`
+LOCAL_PATH := $(call my-dir)
+include $(CLEAR_VARS)
+LOCAL_MODULE := foo
+LOCAL_SRC_FILES := foo.c
+include $(BUILD_SHARED_LIBRARY)
+
+include $(CLEAR_VARS)
+LOCAL_MODULE := bar
+LOCAL_GENERATED_SOURCES := $(LOCAL_PATH)/gen/bar.c
+include $(BUILD_STATIC_LIBRARY)
+
+$(LOCAL_PATH)/gen/bar.c: $(LOCAL_PATH)/gen.py
+	@mkdir -p $(dir $@) && python $< > $@
+`))
+	if len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %q", errs)
+	}
+	if g, w := hard.ModuleTypes, []string{"cc_library_shared", "cc_library_static"}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected module types %q, got %q", w, g)
+	}
+	if g, w := hard.CustomRules, 1; g != w {
+		t.Errorf("expected %d custom rules, got %d", w, g)
+	}
+	if g, w := hard.Tools, []string{"mkdir", "python"}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected tools %q, got %q", w, g)
+	}
+	if g, w := hard.SpecialVariables, []string{"LOCAL_GENERATED_SOURCES"}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected special variables %q, got %q", w, g)
+	}
+	if hard.TranslationErrors == 0 {
+		t.Errorf("expected the make rule to be a translation error")
+	}
+	if hard.Score() <= ready.Score() {
+		t.Errorf("expected score %d to be higher than %d", hard.Score(), ready.Score())
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package androidmk
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	mkparser "android/soong/androidmk/parser"
+
+	bpparser "github.com/google/blueprint/parser"
+)
+
+// specialVariables are variables whose use in an Android.mk file indicates hand written build logic
+// that has no direct equivalent in an Android.bp file.
+var specialVariables = map[string]bool{
+	"LOCAL_ADDITIONAL_DEPENDENCIES": true,
+	"LOCAL_BUILT_MODULE":            true,
+	"LOCAL_GENERATED_SOURCES":       true,
+	"LOCAL_INTERMEDIATE_TARGETS":    true,
+	"LOCAL_POST_INSTALL_CMD":        true,
+	"LOCAL_PREBUILT_MODULE_FILE":    true,
+}
+
+// Weights of the findings in an Assessment that make up its score.
+const (
+	translationErrorScore   = 3
+	translationWarningScore = 1
+	customRuleScore         = 5
+	specialVariableScore    = 2
+)
+
+// Assessment describes how much work is left to convert an Android.mk file to an Android.bp file.
+type Assessment struct {
+	// The Soong module types that the modules in the file are converted to.
+	ModuleTypes []string
+
+	// The number of lines that androidmk failed to translate, or that it translated with a warning.
+	TranslationErrors, TranslationWarnings int
+
+	// The number of make rules in the file.
+	CustomRules int
+
+	// The commands run by the recipes of the make rules in the file.
+	Tools []string
+
+	// The variables used in the file that indicate hand written build logic.
+	SpecialVariables []string
+}
+
+// Score returns the conversion difficulty of the file, where 0 means that androidmk can convert it
+// without any manual work.
+func (a Assessment) Score() int {
+	return a.TranslationErrors*translationErrorScore +
+		a.TranslationWarnings*translationWarningScore +
+		a.CustomRules*customRuleScore +
+		len(a.SpecialVariables)*specialVariableScore
+}
+
+// Readiness returns a coarse description of the conversion difficulty of the file.
+func (a Assessment) Readiness() string {
+	return ReadinessForScore(a.Score())
+}
+
+// ReadinessForScore returns a coarse description of a conversion difficulty score, e.g. the sum
+// of the scores of the Android.mk files in a directory.
+func ReadinessForScore(score int) string {
+	switch {
+	case score == 0:
+		return "ready"
+	case score < 10:
+		return "easy"
+	case score < 30:
+		return "moderate"
+	default:
+		return "hard"
+	}
+}
+
+// AssessFile returns an Assessment of the conversion of an Android.mk file to an Android.bp file.
+func AssessFile(filename string, buffer *bytes.Buffer) (Assessment, []error) {
+	var a Assessment
+
+	contents := buffer.Bytes()
+	nodes, errs := mkparser.NewParser(filename, bytes.NewBuffer(contents)).Parse()
+	if len(errs) > 0 {
+		return a, errs
+	}
+
+	tools := make(map[string]bool)
+	variables := make(map[string]bool)
+	for _, node := range nodes {
+		switch x := node.(type) {
+		case *mkparser.Assignment:
+			if x.Name.Const() && specialVariables[x.Name.Dump()] {
+				variables[x.Name.Dump()] = true
+			}
+		case *mkparser.Rule:
+			a.CustomRules++
+			for _, tool := range recipeTools(x.Recipe) {
+				tools[tool] = true
+			}
+		}
+	}
+	a.Tools = sortedKeys(tools)
+	a.SpecialVariables = sortedKeys(variables)
+
+	bp, errs := ConvertFile(filename, bytes.NewBuffer(contents))
+	if len(errs) > 0 {
+		return a, errs
+	}
+	a.TranslationErrors = strings.Count(bp, "ANDROIDMK TRANSLATION ERROR")
+	a.TranslationWarnings = strings.Count(bp, "ANDROIDMK TRANSLATION WARNING")
+
+	tree, errs := bpparser.Parse(filename, strings.NewReader(bp), bpparser.NewScope(nil))
+	if len(errs) > 0 {
+		return a, errs
+	}
+	moduleTypes := make(map[string]bool)
+	for _, def := range tree.Defs {
+		if module, ok := def.(*bpparser.Module); ok {
+			moduleTypes[module.Type] = true
+		}
+	}
+	a.ModuleTypes = sortedKeys(moduleTypes)
+
+	return a, nil
+}
+
+// recipeTools returns the first word of each command in a make recipe.
+func recipeTools(recipe string) []string {
+	var tools []string
+	for _, line := range strings.Split(recipe, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "@-+")
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, command := range strings.Split(line, "&&") {
+			if fields := strings.Fields(command); len(fields) > 0 {
+				tools = append(tools, fields[0])
+			}
+		}
+	}
+	return tools
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -196,6 +196,11 @@ func (a *apexBundle) androidMkForFiles(w io.Writer, apexBundleName, apexName, mo
 				host = true
 			case android.Device:
 				if fi.module.Target().Arch.ArchType != android.Common {
+					// Make only knows the archs of the device, native bridge files are
+					// installed as files of the arch that runs them.
+					if fi.module.Target().NativeBridge == android.NativeBridgeEnabled {
+						archStr = fi.module.Target().NativeBridgeHostArchName
+					}
 					fmt.Fprintln(w, "LOCAL_MODULE_TARGET_ARCH :=", archStr)
 				}
 			}
@@ -404,6 +409,7 @@ func (a *apexBundle) androidMkForType() android.AndroidMkData {
 				if a.mergedNotices.Merged.Valid() {
 					fmt.Fprintln(w, "LOCAL_NOTICE_FILE :=", a.mergedNotices.Merged.Path().String())
 				}
+				fmt.Fprintln(w, "LOCAL_SOONG_LICENSE_METADATA :=", a.licenseMetadataFile.String())
 
 				fmt.Fprintln(w, "include $(BUILD_PREBUILT)")
 
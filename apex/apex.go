@@ -65,11 +65,29 @@ func RegisterPostDepsMutators(ctx android.RegisterMutatorsContext) {
 	ctx.BottomUp("mark_platform_availability", markPlatformAvailability).Parallel()
 }
 
+type bundleConfigProperties struct {
+	// Globs of the files in the bundle that are stored uncompressed in the APKs generated from it,
+	// in addition to the APEX payload and manifest that are always stored uncompressed.
+	Uncompressed_globs []string
+
+	// The dimensions on which the APKs generated from the bundle are split. Allowed values are
+	// "abi", "screen_density", "language", "texture_compression_format" and "device_tier".
+	Split_dimensions []string
+}
+
 type apexBundleProperties struct {
 	// Json manifest file describing meta info of this APEX bundle. Refer to
 	// system/apex/proto/apex_manifest.proto for the schema. Default: "apex_manifest.json"
 	Manifest *string `android:"path"`
 
+	// Extra arguments passed to jsonmodify when the manifest is prepared, e.g.
+	// ["-v version 2"].  $(location <label>) and $(locations <label>) expand to the paths of the
+	// files in manifest_preprocessor_files with the given label.
+	Manifest_preprocessor_args []string
+
+	// list of files or modules that can be referenced from manifest_preprocessor_args.
+	Manifest_preprocessor_files []string `android:"path"`
+
 	// AndroidManifest.xml file used for the zip container of this APEX bundle. If unspecified,
 	// a default one is automatically generated.
 	AndroidManifest *string `android:"path"`
@@ -99,6 +117,11 @@ type apexBundleProperties struct {
 	// Name of the apex_key module that provides the private key to sign this APEX bundle.
 	Key *string
 
+	// Path or module to the previously released version of this APEX.  When set, the build fails
+	// if the previous version isn't signed with the public key of `key`.  A deliberate key rotation
+	// has to update this property in the same change.
+	Previous_apex *string `android:"path"`
+
 	// Specifies the certificate and the private key to sign the zip container of this APEX. If
 	// this is "foo", foo.x509.pem and foo.pk8 under PRODUCT_DEFAULT_DEV_CERTIFICATE are used
 	// as the certificate and the private key, respectively. If this is ":module", then the
@@ -116,6 +139,24 @@ type apexBundleProperties struct {
 	// symlinking to the system libs. Default is false.
 	Updatable *bool
 
+	// Whether the native code in this APEX is checked to be updatable independently of the
+	// platform. When set to true, the following dependencies are rejected:
+	//
+	// - shared libraries outside of the APEX without a stable interface, i.e. other than
+	//   libraries with stubs, LLNDK libraries and NDK libraries. With use_vndk_as_stable these
+	//   are the VNDK libraries, whose interface can change with the platform.
+	// - shared libraries without stubs that are packaged in the APEX but are also available to
+	//   the platform. The platform then has its own copy of the library that is not updated
+	//   with the APEX, and the two copies can both be loaded in the same process.
+	//
+	// Default is false.
+	Strict_updatability *bool
+
+	// Names of libraries that native code in this APEX may depend on even though
+	// strict_updatability would reject them. This is used to turn on strict_updatability before
+	// all the existing dependencies are fixed.
+	Strict_updatability_allowed_deps []string
+
 	// Whether this APEX is installable to one of the partitions like system, vendor, etc.
 	// Default: true.
 	Installable *bool
@@ -152,10 +193,30 @@ type apexBundleProperties struct {
 	// Default 'ext4'.
 	Payload_fs_type *string
 
+	// Controls the bundle config used by bundletool to build the app bundle of the APEX from its
+	// -base.zip.
+	Bundle_config bundleConfigProperties
+
 	// For telling the APEX to ignore special handling for system libraries such as bionic.
 	// Default is false.
 	Ignore_system_library_special_case *bool
 
+	// Whether to also build <name>-debug.apex next to this APEX. In the debug APEX, native
+	// libraries and executables in the payload keep their full symbols instead of being stripped
+	// down to the mini debug info. Only applies when payload_type is image. Default is false.
+	Debug_apex *bool
+
+	// List of native libraries and executables in the payload that keep their full symbols in
+	// the debug APEX. The other payload files are packaged stripped, as in this APEX. If unset,
+	// all native payload files keep their symbols. Requires debug_apex to be true.
+	Debug_apex_keep_symbols []string
+
+	// Whether to also build <name>-payload.zip, which contains the payload files as they are laid
+	// out in the APEX, and <name>-symbols.zip, which contains the unstripped native files at the
+	// same paths. They can be dist'd with the ".payload" and ".symbols" tags for symbolication.
+	// Default is false.
+	Payload_zips *bool
+
 	// Whenever apex_payload.img of the APEX should include dm-verity hashtree. Should be only
 	// used in tests.
 	Test_only_no_hashtree *bool
@@ -164,6 +225,15 @@ type apexBundleProperties struct {
 	// used in tests.
 	Test_only_unsigned_payload *bool
 
+	// List of raw files to put in the payload of an apex_test, each in the form
+	// "<path in payload>=<file>" where <file> is a path or a ":module" reference. The files are
+	// placed at the given paths without the checks that apply to the files from dependencies, so
+	// that malformed APEXes can be built for negative tests of apexd and ART. A file for
+	// apex_manifest.pb or apex_manifest.json replaces the manifest generated by the build. The
+	// other paths that the build creates, apex_pubkey and lost+found, and the paths of the files
+	// from dependencies can't be used.
+	Test_only_payload_files []string
+
 	IsCoverageVariant bool `blueprint:"mutated"`
 
 	// List of sanitizer names that this APEX is enabled for
@@ -254,6 +324,35 @@ type apexArchBundleProperties struct {
 	}
 }
 
+// Properties of an APEX that only apply to some build variants. They are appended to the
+// properties of the same name of the APEX.
+type apexBuildVariantProperties struct {
+	// Properties that only apply to debuggable builds, i.e. userdebug and eng builds.
+	Debuggable apexBuildVariantContents
+
+	// Properties that only apply to eng builds.
+	Eng apexBuildVariantContents
+}
+
+type apexBuildVariantContents struct {
+	// Whether this APEX is installable to one of the partitions.
+	Installable *bool
+
+	// The type of APEX to build. Either "image", "zip" or "both".
+	Payload_type *string
+
+	ApexNativeDependencies
+
+	// List of java libraries that are embedded inside this APEX bundle.
+	Java_libs []string
+
+	// List of prebuilt files that are embedded inside this APEX bundle.
+	Prebuilts []string
+
+	// List of APKs that are embedded inside this APEX.
+	Apps []string
+}
+
 // These properties can be used in override_apex to override the corresponding properties in the
 // base apex.
 type overridableProperties struct {
@@ -292,6 +391,7 @@ type apexBundle struct {
 	targetProperties      apexTargetBundleProperties
 	archProperties        apexArchBundleProperties
 	overridableProperties overridableProperties
+	variantProperties     apexBuildVariantProperties
 	vndkProperties        apexVndkProperties // only for apex_vndk modules
 
 	///////////////////////////////////////////////////////////////////////////////////////////
@@ -300,6 +400,7 @@ type apexBundle struct {
 	// Keys for apex_paylaod.img
 	publicKeyFile  android.Path
 	privateKeyFile android.Path
+	keyPairCheck   android.Path
 
 	// Cert/priv-key for the zip container
 	containerCertificateFile android.Path
@@ -334,11 +435,18 @@ type apexBundle struct {
 	///////////////////////////////////////////////////////////////////////////////////////////
 	// Outputs (final and intermediates)
 
-	// Processed apex manifest in JSONson format (for Q)
-	manifestJsonOut android.WritablePath
+	// Processed apex manifest in JSONson format (for Q), or the file from test_only_payload_files
+	manifestJsonOut android.Path
+
+	// Processed apex manifest in JSON format, including the keys that Q devices don't understand
+	manifestJsonFullOut android.WritablePath
+
+	// Processed apex manifest in PB format (for R+), or the file from test_only_payload_files
+	manifestPbOut android.Path
 
-	// Processed apex manifest in PB format (for R+)
-	manifestPbOut android.WritablePath
+	// Files from test_only_payload_files that replace the processed apex manifests
+	testOnlyManifestJson android.Path
+	testOnlyManifestPb   android.Path
 
 	// Processed file_contexts files
 	fileContexts android.WritablePath
@@ -349,6 +457,15 @@ type apexBundle struct {
 	// The built APEX file. This is the main product.
 	outputFile android.WritablePath
 
+	// The built <name>-debug.apex file, whose native payload files keep their full symbols. Only
+	// set when debug_apex is true.
+	debugOutputFile android.WritablePath
+
+	// Zips of the payload files and of their unstripped versions. Only set when payload_zips is
+	// true.
+	payloadZipFile android.WritablePath
+	symbolsZipFile android.WritablePath
+
 	// The built APEX file in app bundle format. This file is not directly installed to the
 	// device. For an APEX, multiple app bundles are created each of which is for a specific ABI
 	// like arm, arm64, x86, etc. Then they are processed again (outside of the Android build
@@ -359,6 +476,9 @@ type apexBundle struct {
 	// Target path to install this APEX. Usually out/target/product/<device>/<partition>/apex.
 	installDir android.InstallPath
 
+	// Path of the installed APEX file on the device. Only set for installable image APEXes.
+	onDevicePath string
+
 	// List of commands to create symlinks for backward compatibility. These commands will be
 	// attached as LOCAL_POST_INSTALL_CMD to apex package itself (for unflattened build) or
 	// apex_manifest (for flattened build) so that compat symlinks are always installed
@@ -376,6 +496,9 @@ type apexBundle struct {
 	// Optional list of lint report zip files for apexes that contain java or app modules
 	lintReports android.Paths
 
+	// License metadata of the apex and of its contents.
+	licenseMetadataFile android.OutputPath
+
 	prebuiltFileToDelete string
 
 	isCompressed bool
@@ -413,6 +536,10 @@ type apexFile struct {
 	customStem  string
 	symlinks    []string // additional symlinks
 
+	// unstrippedBuiltFile is put in the installDir inside the debug APEX instead of builtFile.
+	// Only set for native libraries and executables.
+	unstrippedBuiltFile android.Path
+
 	// Info for Android.mk Module name of `module` in AndroidMk. Note the generated AndroidMk
 	// module for apexFile is named something like <AndroidMk module name>.<apex name>[<apex
 	// suffix>]
@@ -573,6 +700,23 @@ func (a *apexBundle) combineProperties(ctx android.BottomUpMutatorContext) {
 			proptools.AppendProperties(&a.properties.Multilib, &a.targetProperties.Target.Linux_glibc.Multilib, nil)
 		}
 	}
+
+	appendVariantProperties := func(property string, src *apexBuildVariantContents) {
+		dst := []interface{}{&a.properties, &a.overridableProperties}
+		if err := proptools.AppendMatchingProperties(dst, src, nil); err != nil {
+			if propertyErr, ok := err.(*proptools.ExtendPropertyError); ok {
+				ctx.PropertyErrorf(property+"."+propertyErr.Property, "%s", propertyErr.Err.Error())
+			} else {
+				panic(err)
+			}
+		}
+	}
+	if ctx.Config().Debuggable() {
+		appendVariantProperties("debuggable", &a.variantProperties.Debuggable)
+	}
+	if ctx.Config().Eng() {
+		appendVariantProperties("eng", &a.variantProperties.Eng)
+	}
 }
 
 // getImageVariation returns the image variant name for this apexBundle. In most cases, it's simply
@@ -622,10 +766,14 @@ func (a *apexBundle) DepsMutator(ctx android.BottomUpMutatorContext) {
 	a.combineProperties(ctx)
 
 	has32BitTarget := false
-	for _, target := range targets {
+	firstNativeBridgeTarget := -1
+	for i, target := range targets {
 		if target.Arch.ArchType.Multilib == "lib32" {
 			has32BitTarget = true
 		}
+		if target.NativeBridge == android.NativeBridgeEnabled && firstNativeBridgeTarget == -1 {
+			firstNativeBridgeTarget = i
+		}
 	}
 	for i, target := range targets {
 		// Don't include artifacts for the host cross targets because there is no way for us
@@ -647,8 +795,9 @@ func (a *apexBundle) DepsMutator(ctx android.BottomUpMutatorContext) {
 		})
 
 		// Add native modules targeting the first ABI When multilib.* is omitted for
-		// binaries, it implies multilib.first
-		isPrimaryAbi := i == 0
+		// binaries, it implies multilib.first. The first ABI of the translated architectures is
+		// a primary ABI as well, so that binaries are packaged for the native bridge too.
+		isPrimaryAbi := i == 0 || i == firstNativeBridgeTarget
 		if isPrimaryAbi {
 			depsList = append(depsList, a.properties.Multilib.First)
 			depsList = append(depsList, ApexNativeDependencies{
@@ -741,6 +890,12 @@ func (a *apexBundle) DepsMutator(ctx android.BottomUpMutatorContext) {
 		}
 		a.BuildWithSdks(sdkRefs)
 	}
+
+	for _, entry := range a.properties.Test_only_payload_files {
+		if _, src, ok := splitTestOnlyPayloadFile(entry); ok {
+			android.ExtractSourceDeps(ctx, &src)
+		}
+	}
 }
 
 // DepsMutator for the overridden properties.
@@ -1157,12 +1312,40 @@ func (a *apexBundle) DepIsInSameApex(ctx android.BaseModuleContext, dep android.
 
 var _ android.OutputFileProducer = (*apexBundle)(nil)
 
+// Overrides returns the names of the modules this APEX overrides.
+func (a *apexBundle) Overrides() []string {
+	return a.overridableProperties.Overrides
+}
+
+var _ android.OverridingModule = (*apexBundle)(nil)
+
 // Implements android.OutputFileProducer
 func (a *apexBundle) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
 	case "", android.DefaultDistTag:
 		// This is the default dist path.
 		return android.Paths{a.outputFile}, nil
+	case ".debug":
+		if a.debugOutputFile != nil {
+			return android.Paths{a.debugOutputFile}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no debug APEX was built.", tag)
+	case ".bundle":
+		// The bundle module zip used to build app bundles, e.g. to dist it for the app store.
+		if a.bundleModuleFile != nil {
+			return android.Paths{a.bundleModuleFile}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no bundle was built.", tag)
+	case ".payload":
+		if a.payloadZipFile != nil {
+			return android.Paths{a.payloadZipFile}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but payload_zips is not set.", tag)
+	case ".symbols":
+		if a.symbolsZipFile != nil {
+			return android.Paths{a.symbolsZipFile}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but payload_zips is not set.", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -1315,7 +1498,9 @@ func apexFileForNativeLibrary(ctx android.BaseModuleContext, ccMod *cc.Module, h
 
 	fileToCopy := ccMod.OutputFile().Path()
 	androidMkModuleName := ccMod.BaseModuleName() + ccMod.Properties.SubName
-	return newApexFile(ctx, fileToCopy, androidMkModuleName, dirInApex, nativeSharedLib, ccMod)
+	af := newApexFile(ctx, fileToCopy, androidMkModuleName, dirInApex, nativeSharedLib, ccMod)
+	af.unstrippedBuiltFile = ccMod.UnstrippedOutputFile()
+	return af
 }
 
 func apexFileForExecutable(ctx android.BaseModuleContext, cc *cc.Module) apexFile {
@@ -1327,6 +1512,7 @@ func apexFileForExecutable(ctx android.BaseModuleContext, cc *cc.Module) apexFil
 	fileToCopy := cc.OutputFile().Path()
 	androidMkModuleName := cc.BaseModuleName() + cc.Properties.SubName
 	af := newApexFile(ctx, fileToCopy, androidMkModuleName, dirInApex, nativeExecutable, cc)
+	af.unstrippedBuiltFile = cc.UnstrippedOutputFile()
 	af.symlinks = cc.Symlinks()
 	af.dataPaths = cc.DataPaths()
 	return af
@@ -1461,6 +1647,33 @@ func apexFileForAndroidApp(ctx android.BaseModuleContext, aapp androidApp) apexF
 	return af
 }
 
+// apexFilesForAndroidAppDexpreopt returns the dexpreopt artifacts (.odex, .vdex, etc.) of the APEX
+// variant of the app, which are installed next to the APK so that the app doesn't have to be
+// compiled on the device.
+func (a *apexBundle) apexFilesForAndroidAppDexpreopt(ctx android.BaseModuleContext, aapp *java.AndroidApp, appFile apexFile) []apexFile {
+	// The app is dexpreopted for the mount path of the APEX with the name of the apexBundle, which
+	// is not the mount path of this APEX if apex_name is set to another name.
+	if apexName := proptools.StringDefault(a.properties.Apex_name, ctx.ModuleName()); apexName != ctx.ModuleName() {
+		return nil
+	}
+
+	var ret []apexFile
+	for _, install := range aapp.DexpreoptBuiltInstalledForApex() {
+		// The artifacts are installed in the directory of the APK, e.g. oat/<arch>/<name>.odex
+		// next to app/<name>/<name>.apk.
+		rel, err := filepath.Rel(appFile.installDir, install.To)
+		if err != nil || strings.HasPrefix(rel, "../") {
+			ctx.ModuleErrorf("dexpreopt artifact %q of %q is not in %q", install.To, aapp.Name(), appFile.installDir)
+			continue
+		}
+		localModule := aapp.BaseModuleName() + "_" + strings.ReplaceAll(rel, "/", "_")
+		af := newApexFile(ctx, install.From, localModule, filepath.Dir(install.To), etc, nil)
+		af.customStem = filepath.Base(install.To)
+		ret = append(ret, af)
+	}
+	return ret
+}
+
 func apexFileForRuntimeResourceOverlay(ctx android.BaseModuleContext, rro java.RuntimeResourceOverlayModule) apexFile {
 	rroDir := "overlay"
 	dirInApex := filepath.Join(rroDir, rro.Theme())
@@ -1539,12 +1752,17 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// 1) do some validity checks such as apex_available, min_sdk_version, etc.
 	a.checkApexAvailability(ctx)
 	a.checkUpdatable(ctx)
+	a.checkStrictUpdatability(ctx)
 	a.checkMinSdkVersion(ctx)
 	a.checkStaticLinkingToStubLibraries(ctx)
 	if len(a.properties.Tests) > 0 && !a.testApex {
 		ctx.PropertyErrorf("tests", "property allowed only in apex_test module type")
 		return
 	}
+	if len(a.properties.Test_only_payload_files) > 0 && !a.testApex {
+		ctx.PropertyErrorf("test_only_payload_files", "property allowed only in apex_test module type")
+		return
+	}
 
 	////////////////////////////////////////////////////////////////////////////////////////////
 	// 2) traverse the dependency tree to collect apexFile structs from them.
@@ -1594,7 +1812,10 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 			case executableTag:
 				if cc, ok := child.(*cc.Module); ok {
 					filesInfo = append(filesInfo, apexFileForExecutable(ctx, cc))
-					return true // track transitive dependencies
+					// Track transitive dependencies, except for static executables. They
+					// don't load any shared library, so neither their system_shared_libs
+					// nor other shared deps are needed in or by the APEX.
+					return !cc.StaticExecutable()
 				} else if sh, ok := child.(*sh.ShBinary); ok {
 					filesInfo = append(filesInfo, apexFileForShBinary(ctx, sh))
 				} else if py, ok := child.(*python.Module); ok && py.HostToolPath().Valid() {
@@ -1622,7 +1843,9 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 				}
 			case androidAppTag:
 				if ap, ok := child.(*java.AndroidApp); ok {
-					filesInfo = append(filesInfo, apexFileForAndroidApp(ctx, ap))
+					af := apexFileForAndroidApp(ctx, ap)
+					filesInfo = append(filesInfo, af)
+					filesInfo = append(filesInfo, a.apexFilesForAndroidAppDexpreopt(ctx, ap, af)...)
 					return true // track transitive dependencies
 				} else if ap, ok := child.(*java.AndroidAppImport); ok {
 					filesInfo = append(filesInfo, apexFileForAndroidApp(ctx, ap))
@@ -1686,6 +1909,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 				if key, ok := child.(*apexKey); ok {
 					a.privateKeyFile = key.privateKeyFile
 					a.publicKeyFile = key.publicKeyFile
+					a.keyPairCheck = key.keyPairCheck
 				} else {
 					ctx.PropertyErrorf("key", "%q is not an apex_key module", depName)
 				}
@@ -1783,7 +2007,8 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 						// these are not considered transitive dep
 						af.transitiveDep = false
 						filesInfo = append(filesInfo, af)
-						return true // track transitive dependencies
+						// Track transitive dependencies, except for static executables.
+						return !cc.StaticExecutable()
 					}
 				} else if cc.IsHeaderDepTag(depTag) {
 					// nothing
@@ -1829,6 +2054,8 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		}
 	}
 
+	filesInfo = append(filesInfo, a.testOnlyPayloadFiles(ctx, filesInfo)...)
+
 	// Remove duplicates in filesInfo
 	removeDup := func(filesInfo []apexFile) []apexFile {
 		encountered := make(map[string]apexFile)
@@ -1938,13 +2165,16 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 	a.buildApexDependencyInfo(ctx)
 	a.buildLintReports(ctx)
+	a.buildLicenseMetadata(ctx)
 
 	// Append meta-files to the filesInfo list so that they are reflected in Android.mk as well.
 	if a.installable() {
 		// For flattened APEX, make sure that APEX manifest and apex_pubkey are also copied
 		// along with other ordinary files. (Note that this is done by apexer for
 		// non-flattened APEXes)
-		a.filesInfo = append(a.filesInfo, newApexFile(ctx, a.manifestPbOut, "apex_manifest.pb", ".", etc, nil))
+		manifest := newApexFile(ctx, a.manifestPbOut, "apex_manifest.pb", ".", etc, nil)
+		manifest.customStem = "apex_manifest.pb"
+		a.filesInfo = append(a.filesInfo, manifest)
 
 		// Place the public key as apex_pubkey. This is also done by apexer for
 		// non-flattened APEXes case.
@@ -1959,6 +2189,69 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 }
 
+// splitTestOnlyPayloadFile splits an entry of test_only_payload_files into the path in the payload
+// and the file to put there.
+func splitTestOnlyPayloadFile(entry string) (pathInApex, src string, ok bool) {
+	i := strings.IndexByte(entry, '=')
+	if i <= 0 || i == len(entry)-1 {
+		return "", "", false
+	}
+	return entry[:i], entry[i+1:], true
+}
+
+// testOnlyPayloadFiles returns the apexFiles for the entries of test_only_payload_files. An entry
+// for apex_manifest.pb or apex_manifest.json replaces the manifest that the build generates instead,
+// and entries for the other paths that the build creates itself, or for the paths of the files
+// from the dependencies, are rejected.
+func (a *apexBundle) testOnlyPayloadFiles(ctx android.ModuleContext, filesInfo []apexFile) []apexFile {
+	dependencyPaths := make(map[string]string)
+	for _, fi := range filesInfo {
+		dependencyPaths[fi.path()] = fi.androidMkModuleName
+	}
+
+	var ret []apexFile
+	for _, entry := range a.properties.Test_only_payload_files {
+		pathInApex, src, ok := splitTestOnlyPayloadFile(entry)
+		if !ok {
+			ctx.PropertyErrorf("test_only_payload_files", "%q is not in the form <path in payload>=<file>", entry)
+			continue
+		}
+		if filepath.IsAbs(pathInApex) || filepath.Clean(pathInApex) != pathInApex ||
+			pathInApex == ".." || strings.HasPrefix(pathInApex, "../") {
+			ctx.PropertyErrorf("test_only_payload_files", "%q is not a clean relative path", pathInApex)
+			continue
+		}
+
+		switch {
+		case pathInApex == "apex_manifest.pb":
+			a.testOnlyManifestPb = android.PathForModuleSrc(ctx, src)
+			continue
+		case pathInApex == "apex_manifest.json":
+			a.testOnlyManifestJson = android.PathForModuleSrc(ctx, src)
+			continue
+		case pathInApex == "apex_pubkey", pathInApex == "lost+found", strings.HasPrefix(pathInApex, "lost+found/"):
+			ctx.PropertyErrorf("test_only_payload_files", "%q is created by the build and can't be replaced",
+				pathInApex)
+			continue
+		}
+		if module, exists := dependencyPaths[pathInApex]; exists {
+			ctx.PropertyErrorf("test_only_payload_files", "%q is already the path of %q in the payload",
+				pathInApex, module)
+			continue
+		}
+
+		dirInApex := filepath.Dir(pathInApex)
+		if dirInApex == "." {
+			dirInApex = ""
+		}
+		localModule := "test_only_" + strings.ReplaceAll(pathInApex, "/", "_")
+		af := newApexFile(ctx, android.PathForModuleSrc(ctx, src), localModule, dirInApex, etc, nil)
+		af.customStem = filepath.Base(pathInApex)
+		ret = append(ret, af)
+	}
+	return ret
+}
+
 ///////////////////////////////////////////////////////////////////////////////////////////////////
 // Factory functions
 //
@@ -1970,6 +2263,7 @@ func newApexBundle() *apexBundle {
 	module.AddProperties(&module.targetProperties)
 	module.AddProperties(&module.archProperties)
 	module.AddProperties(&module.overridableProperties)
+	module.AddProperties(&module.variantProperties)
 
 	android.InitAndroidMultiTargetsArchModule(module, android.HostAndDeviceSupported, android.MultilibCommon)
 	android.InitDefaultableModule(module)
@@ -2017,6 +2311,7 @@ func DefaultsFactory(props ...interface{}) android.Module {
 		&apexBundleProperties{},
 		&apexTargetBundleProperties{},
 		&overridableProperties{},
+		&apexBuildVariantProperties{},
 	)
 
 	android.InitDefaultsModule(module)
@@ -2136,6 +2431,46 @@ func (a *apexBundle) checkUpdatable(ctx android.ModuleContext) {
 	}
 }
 
+// Ensures that native code in an APEX with strict_updatability only depends on libraries outside
+// of the APEX through stable interfaces, and that the shared libraries packaged in the APEX are
+// not also used by the platform.
+func (a *apexBundle) checkStrictUpdatability(ctx android.ModuleContext) {
+	if !proptools.Bool(a.properties.Strict_updatability) || ctx.Host() {
+		return
+	}
+
+	allowedDeps := a.properties.Strict_updatability_allowed_deps
+	a.WalkPayloadDeps(ctx, func(ctx android.ModuleContext, from blueprint.Module, to android.ApexModule, externalDep bool) bool {
+		if !externalDep {
+			toName := ctx.OtherModuleName(to)
+			if ccm, ok := to.(*cc.Module); ok && ccm.CcLibraryInterface() && ccm.Shared() &&
+				!ccm.HasStubsVariants() && to.AvailableFor(android.AvailableToPlatform) &&
+				!android.InList(toName, allowedDeps) {
+				ctx.ModuleErrorf("%q requires %q, which is packaged in the APEX but is also available "+
+					"to the platform. Remove %q from its apex_available, add stubs to it, or add it "+
+					"to strict_updatability_allowed_deps. Dependency path:%s",
+					ctx.OtherModuleName(from), toName, android.AvailableToPlatform, ctx.GetPathString(true))
+			}
+			return true
+		}
+
+		// Only dependencies that are linked at runtime matter.
+		depTag := ctx.OtherModuleDependencyTag(to)
+		linked := (cc.IsSharedDepTag(depTag) || cc.IsRuntimeDepTag(depTag)) && !cc.IsExcludedInApexDepTag(depTag)
+
+		toName := ctx.OtherModuleName(to)
+		if ccm, ok := to.(*cc.Module); ok && linked && !android.InList(toName, allowedDeps) {
+			if err := ccm.ApexSafeDependencyError(ctx.Config()); err != nil {
+				ctx.ModuleErrorf("%q requires %q, which %s. Use a library with stubs instead, or add %q "+
+					"to strict_updatability_allowed_deps. Dependency path:%s",
+					ctx.OtherModuleName(from), toName, err, toName, ctx.GetPathString(true))
+			}
+		}
+		// As soon as the dependency graph crosses the APEX boundary, don't go further.
+		return false
+	})
+}
+
 func (a *apexBundle) checkJavaStableSdkVersion(ctx android.ModuleContext) {
 	// Visit direct deps only. As long as we guarantee top-level deps are using stable SDKs,
 	// java's checkLinkType guarantees correct usage for transitive deps
@@ -17,6 +17,8 @@
 package apex
 
 import (
+	"strings"
+
 	"android/soong/android"
 
 	"github.com/google/blueprint"
@@ -24,6 +26,11 @@ import (
 
 func init() {
 	android.RegisterSingletonType("apex_depsinfo_singleton", apexDepsInfoSingletonFactory)
+	android.RegisterSingletonType("apex_info_list", apexInfoListSingletonFactory)
+	android.RegisterSingletonType("apex_duplicate_payload", apexDuplicatePayloadSingletonFactory)
+
+	pctx.HostBinToolVariable("gen_apex_info_list", "gen_apex_info_list")
+	pctx.HostBinToolVariable("gen_apex_duplicate_payload_report", "gen_apex_duplicate_payload_report")
 }
 
 type apexDepsInfoSingleton struct {
@@ -108,3 +115,111 @@ func (s *apexDepsInfoSingleton) MakeVars(ctx android.MakeVarsContext) {
 	// Export check result to Make. The path is added to droidcore.
 	ctx.Strict("APEX_ALLOWED_DEPS_CHECK", s.allowedApexDepsInfoCheckResult.String())
 }
+
+// apexInfoListSingleton generates the build time equivalent of the apex-info-list.xml that apexd
+// writes on the device, listing the name, version and path of every APEX installed on the image.
+// It lets test infrastructure and OTA tooling inspect the APEXes without booting a device.
+type apexInfoListSingleton struct {
+	output android.OutputPath
+}
+
+func apexInfoListSingletonFactory() android.Singleton {
+	return &apexInfoListSingleton{}
+}
+
+var apexInfoListRule = pctx.AndroidStaticRule("apexInfoListRule", blueprint.RuleParams{
+	Command:     `rm -f $out && ${gen_apex_info_list} -o $out ${apexes}`,
+	CommandDeps: []string{"${gen_apex_info_list}"},
+	Description: "apex-info-list.xml",
+}, "apexes")
+
+func (s *apexInfoListSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var manifests android.Paths
+	var apexes []string
+	ctx.VisitAllModules(func(module android.Module) {
+		if a, ok := module.(*apexBundle); ok && a.Enabled() && a.onDevicePath != "" {
+			manifests = append(manifests, a.manifestJsonFullOut)
+			apexes = append(apexes, a.manifestJsonFullOut.String()+":"+a.onDevicePath)
+		}
+	})
+
+	s.output = android.PathForOutput(ctx, "apex", "apex-info-list.xml")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   apexInfoListRule,
+		Inputs: manifests,
+		Output: s.output,
+		Args: map[string]string{
+			"apexes": strings.Join(apexes, " "),
+		},
+	})
+
+	ctx.Phony("apex-info-list", s.output)
+}
+
+func (s *apexInfoListSingleton) MakeVars(ctx android.MakeVarsContext) {
+	ctx.Strict("SOONG_APEX_INFO_LIST", s.output.String())
+}
+
+// apexDuplicatePayloadSingleton generates a report of the files that are packaged, with the same
+// content, into more than one APEX or into both an APEX and the platform image. Each duplicated
+// file is listed with the size it costs, to guide deduplication through stubs or shared APEX
+// libraries.
+type apexDuplicatePayloadSingleton struct {
+	output android.OutputPath
+}
+
+func apexDuplicatePayloadSingletonFactory() android.Singleton {
+	return &apexDuplicatePayloadSingleton{}
+}
+
+var apexDuplicatePayloadRule = pctx.AndroidStaticRule("apexDuplicatePayloadRule", blueprint.RuleParams{
+	Command:     `rm -f $out && ${gen_apex_duplicate_payload_report} -o $out $in`,
+	CommandDeps: []string{"${gen_apex_duplicate_payload_report}"},
+	Description: "apex duplicate payload report",
+})
+
+func (s *apexDuplicatePayloadSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var files android.Paths
+	var lines []string
+	ctx.VisitAllModules(func(module android.Module) {
+		if !module.Enabled() {
+			return
+		}
+		switch m := module.(type) {
+		case *apexBundle:
+			if m.testApex {
+				return
+			}
+			for _, fi := range m.filesInfo {
+				if !fi.ok() {
+					continue
+				}
+				files = append(files, fi.builtFile)
+				lines = append(lines, m.Name()+"\t"+fi.path()+"\t"+fi.builtFile.String())
+			}
+		case *Prebuilt, *ApexSet:
+			// The payload of a prebuilt APEX can't be inspected at build time.
+		default:
+			if module.Target().Os.Class != android.Device {
+				return
+			}
+			for _, installed := range module.FilesToInstall() {
+				files = append(files, installed)
+				lines = append(lines, "platform\t"+installed.String()+"\t"+installed.String())
+			}
+		}
+	})
+
+	fileList := android.PathForOutput(ctx, "apex", "duplicate-payload-files.txt")
+	android.WriteFileRule(ctx, fileList, strings.Join(lines, "\n"))
+
+	s.output = android.PathForOutput(ctx, "apex", "duplicate-payload-report.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:      apexDuplicatePayloadRule,
+		Input:     fileList,
+		Implicits: files,
+		Output:    s.output,
+	})
+
+	ctx.Phony("apex-duplicate-payload-report", s.output)
+}
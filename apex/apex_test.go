@@ -126,6 +126,17 @@ func withUnbundledBuild(_ map[string][]byte, config android.Config) {
 	config.TestProductVariables.Unbundled_build = proptools.BoolPtr(true)
 }
 
+// withoutSepolicy removes system/sepolicy from the checkout. The myapex-file_contexts filegroup
+// of the test fixture then refers to a missing file, so missing dependencies are allowed.
+func withoutSepolicy(fs map[string][]byte, config android.Config) {
+	for path := range fs {
+		if strings.HasPrefix(path, "system/sepolicy/") {
+			delete(fs, path)
+		}
+	}
+	config.TestProductVariables.Allow_missing_dependencies = proptools.BoolPtr(true)
+}
+
 func testApexContext(_ *testing.T, bp string, handlers ...testCustomizer) (*android.TestContext, android.Config) {
 	bp = bp + `
 		filegroup {
@@ -223,6 +234,8 @@ func testApexContext(_ *testing.T, bp string, handlers ...testCustomizer) (*andr
 
 	// from android package
 	android.RegisterPackageBuildComponents(ctx)
+	android.RegisterLicenseKindBuildComponents(ctx)
+	android.RegisterLicenseBuildComponents(ctx)
 	ctx.PreArchMutators(android.RegisterVisibilityRuleChecker)
 
 	ctx.RegisterModuleType("apex", BundleFactory)
@@ -243,6 +256,8 @@ func testApexContext(_ *testing.T, bp string, handlers ...testCustomizer) (*andr
 	// Register these after the prebuilt mutators have been registered to match what
 	// happens at runtime.
 	ctx.PreArchMutators(android.RegisterVisibilityRuleGatherer)
+	ctx.PreArchMutators(android.RegisterLicensesPackageMapper)
+	ctx.PreArchMutators(android.RegisterLicensesPropertyGatherer)
 	ctx.PostDepsMutators(android.RegisterVisibilityRuleEnforcer)
 
 	cc.RegisterRequiredBuildComponentsForTest(ctx)
@@ -264,6 +279,8 @@ func testApexContext(_ *testing.T, bp string, handlers ...testCustomizer) (*andr
 	java.RegisterSdkLibraryBuildComponents(ctx)
 	java.RegisterPrebuiltApisBuildComponents(ctx)
 	ctx.RegisterSingletonType("apex_keys_text", apexKeysTextFactory)
+	ctx.RegisterSingletonType("apex_info_list", apexInfoListSingletonFactory)
+	ctx.RegisterSingletonType("apex_duplicate_payload", apexDuplicatePayloadSingletonFactory)
 	ctx.RegisterModuleType("bpf", bpf.BpfFactory)
 
 	ctx.PreDepsMutators(RegisterPreDepsMutators)
@@ -580,6 +597,8 @@ func TestBasicApex(t *testing.T) {
 	ensureContains(t, optFlags, "--pubkey vendor/foo/devkeys/testkey.avbpubkey")
 	// Ensure that the NOTICE output is being packaged as an asset.
 	ensureContains(t, optFlags, "--assets_dir "+buildDir+"/.intermediates/myapex/android_common_myapex_image/NOTICE")
+	// apexer signs the payload with the pinned avb salt.
+	ensureContains(t, optFlags, "--signing_args '--salt ")
 
 	copyCmds := apexRule.Args["copy_commands"]
 
@@ -838,6 +857,7 @@ func TestApexWithStubs(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "mylib2.map.txt",
 				versions: ["1", "2", "3"],
 			},
 		}
@@ -849,6 +869,7 @@ func TestApexWithStubs(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "mylib3.map.txt",
 				versions: ["10", "11", "12"],
 			},
 			apex_available: [ "myapex" ],
@@ -901,6 +922,75 @@ func TestApexWithStubs(t *testing.T) {
 	})
 }
 
+func TestApexWithRestrictedImplementation(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib", "mylib2"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			stubs: {
+				symbol_file: "mylib.map.txt",
+				versions: ["1"],
+				restrict_implementation: true,
+			},
+			apex_available: ["//apex_available:platform", "myapex"],
+		}
+
+		cc_library {
+			name: "mylib2",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["mylib"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+
+		cc_binary {
+			name: "platformbin",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["mylib"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`
+	ctx, _ := testApex(t, bp)
+
+	// mylib2 is in the same APEX, so it links the implementation of mylib.
+	mylib2LdFlags := ctx.ModuleForTests("mylib2", "android_arm64_armv8-a_shared_apex10000").Rule("ld").Args["libFlags"]
+	ensureContains(t, mylib2LdFlags, "mylib/android_arm64_armv8-a_shared_apex10000/mylib.so")
+	ensureNotContains(t, mylib2LdFlags, "mylib/android_arm64_armv8-a_shared_1/mylib.so")
+
+	// platformbin is not in the APEX, so it links the stubs even though mylib is also available
+	// to the platform.
+	platformLdFlags := ctx.ModuleForTests("platformbin", "android_arm64_armv8-a").Rule("ld").Args["libFlags"]
+	ensureContains(t, platformLdFlags, "mylib/android_arm64_armv8-a_shared_1/mylib.so")
+	ensureNotContains(t, platformLdFlags, "mylib/android_arm64_armv8-a_shared/mylib.so")
+
+	testApexError(t, `cannot statically link "mylib" outside of its APEXes`, strings.Replace(bp,
+		`shared_libs: ["mylib"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`, `static_libs: ["mylib"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`, 1))
+}
+
 func TestApexWithStubsWithMinSdkVersion(t *testing.T) {
 	t.Parallel()
 	ctx, _ := testApex(t, `
@@ -934,6 +1024,7 @@ func TestApexWithStubsWithMinSdkVersion(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "mylib2.map.txt",
 				versions: ["28", "29", "30", "current"],
 			},
 			min_sdk_version: "28",
@@ -946,6 +1037,7 @@ func TestApexWithStubsWithMinSdkVersion(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "mylib3.map.txt",
 				versions: ["28", "29", "30", "current"],
 			},
 			apex_available: [ "myapex" ],
@@ -1031,6 +1123,7 @@ func TestApexWithExplicitStubsDependency(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "libfoo.map.txt",
 				versions: ["10", "20", "30"],
 			},
 		}
@@ -1124,6 +1217,7 @@ func TestApexWithRuntimeLibsDependency(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "libfoo.map.txt",
 				versions: ["10", "20", "30"],
 			},
 		}
@@ -1156,6 +1250,130 @@ func TestApexWithRuntimeLibsDependency(t *testing.T) {
 
 }
 
+func TestApexWithStaticExecutable(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			binaries: ["mybin"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_binary {
+			name: "mybin",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["libfoo", "libbar"],
+			system_shared_libs: [],
+			static_executable: true,
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+
+		cc_library {
+			name: "libfoo",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			stubs: {
+				symbol_file: "libfoo.map.txt",
+				versions: ["10", "20", "30"],
+			},
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`)
+
+	apexRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule")
+	copyCmds := apexRule.Args["copy_commands"]
+
+	ensureContains(t, copyCmds, "image.apex/bin/mybin")
+
+	// Ensure that the shared deps of a static executable are neither included nor required
+	ensureNotContains(t, copyCmds, "image.apex/lib64/libbar.so")
+	ensureNotContains(t, copyCmds, "image.apex/lib64/libfoo.so")
+
+	apexManifestRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexManifestRule")
+	ensureListEmpty(t, names(apexManifestRule.Args["requireNativeLibs"]))
+}
+
+func TestApexRequireNativeLibsWithExcludedSystemSharedLibs(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			exclude_system_shared_libs: ["libm"],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`)
+
+	apexManifestRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexManifestRule")
+	requireNativeLibs := names(apexManifestRule.Args["requireNativeLibs"])
+	ensureListContains(t, requireNativeLibs, "libc.so")
+	ensureListContains(t, requireNativeLibs, "libdl.so")
+	ensureListNotContains(t, requireNativeLibs, "libm.so")
+}
+
+func TestApexManifestPreprocessorArgs(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			manifest_preprocessor_args: ["-a extraFiles $(location extra.txt)"],
+			manifest_preprocessor_files: ["extra.txt"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`, withFiles(map[string][]byte{
+		"extra.txt": nil,
+	}))
+
+	apexManifestRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexManifestRule")
+	ensureContains(t, apexManifestRule.Args["opt"], "-a extraFiles extra.txt")
+	ensureListContains(t, apexManifestRule.Implicits.Strings(), "extra.txt")
+
+	testApexError(t, `manifest_preprocessor_args: unknown location label "other.txt"`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			manifest_preprocessor_args: ["-a extraFiles $(location other.txt)"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+}
+
 func TestRuntimeApexShouldInstallHwasanIfLibcDependsOnIt(t *testing.T) {
 	ctx, _ := testApex(t, "", func(fs map[string][]byte, config android.Config) {
 		bp := `
@@ -1177,7 +1395,7 @@ func TestRuntimeApexShouldInstallHwasanIfLibcDependsOnIt(t *testing.T) {
 			nocrt: true,
 			stl: "none",
 			system_shared_libs: [],
-			stubs: { versions: ["1"] },
+			stubs: { symbol_file: "libc.map.txt", versions: ["1"] },
 			apex_available: ["com.android.runtime"],
 
 			sanitize: {
@@ -1192,7 +1410,7 @@ func TestRuntimeApexShouldInstallHwasanIfLibcDependsOnIt(t *testing.T) {
 			stl: "none",
 			system_shared_libs: [],
 			srcs: [""],
-			stubs: { versions: ["1"] },
+			stubs: { symbol_file: "libclang_rt.hwasan-aarch64-android.map.txt", versions: ["1"] },
 
 			sanitize: {
 				never: true,
@@ -1240,7 +1458,7 @@ func TestRuntimeApexShouldInstallHwasanIfHwaddressSanitized(t *testing.T) {
 			nocrt: true,
 			stl: "none",
 			system_shared_libs: [],
-			stubs: { versions: ["1"] },
+			stubs: { symbol_file: "libc.map.txt", versions: ["1"] },
 			apex_available: ["com.android.runtime"],
 		}
 
@@ -1251,7 +1469,7 @@ func TestRuntimeApexShouldInstallHwasanIfHwaddressSanitized(t *testing.T) {
 			stl: "none",
 			system_shared_libs: [],
 			srcs: [""],
-			stubs: { versions: ["1"] },
+			stubs: { symbol_file: "libclang_rt.hwasan-aarch64-android.map.txt", versions: ["1"] },
 
 			sanitize: {
 				never: true,
@@ -1336,7 +1554,7 @@ func TestApexDependsOnLLNDKTransitively(t *testing.T) {
 				srcs: ["mylib.cpp"],
 				system_shared_libs: [],
 				stl: "none",
-				stubs: { versions: ["29","30"] },
+				stubs: { symbol_file: "libbar.map.txt", versions: ["29","30"] },
 				llndk_stubs: "libbar.llndk",
 			}
 
@@ -1522,6 +1740,7 @@ func TestApexMinSdkVersion_NativeModulesShouldBeBuiltAgainstStubs(t *testing.T)
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "libz.map.txt",
 				versions: ["28", "30"],
 			},
 		}
@@ -1576,6 +1795,7 @@ func TestApexMinSdkVersion_SupportsCodeNames(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "libz.map.txt",
 				versions: ["29", "R"],
 			},
 		}
@@ -1623,6 +1843,7 @@ func TestApexMinSdkVersion_DefaultsToLatest(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "libz.map.txt",
 				versions: ["1", "2"],
 			},
 		}
@@ -1661,6 +1882,7 @@ func TestPlatformUsesLatestStubsFromApexes(t *testing.T) {
 			stl: "none",
 			apex_available: [ "myapex" ],
 			stubs: {
+				symbol_file: "libx.map.txt",
 				versions: ["1", "2"],
 			},
 		}
@@ -1713,6 +1935,7 @@ func TestQApexesUseLatestStubsInBundledBuildsAndHWASAN(t *testing.T) {
 		cc_library {
 			name: "libbar",
 			stubs: {
+				symbol_file: "libbar.map.txt",
 				versions: ["29", "30"],
 			},
 		}
@@ -1786,6 +2009,7 @@ func TestApexMinSdkVersion_ErrorIfIncompatibleStubs(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "libz.map.txt",
 				versions: ["30"],
 			},
 		}
@@ -2121,7 +2345,7 @@ func TestApexMinSdkVersion_OkayEvenWhenDepIsNewer_IfItSatisfiesApexMinSdkVersion
 			system_shared_libs: [],
 			stl: "none",
 			apex_available: ["otherapex"],
-			stubs: { versions: ["29", "30"] },
+			stubs: { symbol_file: "mylib2.map.txt", versions: ["29", "30"] },
 			min_sdk_version: "30",
 		}
 
@@ -2203,8 +2427,138 @@ func TestFilesInSubDir(t *testing.T) {
 	ensureListContains(t, dirs, "bin/foo/bar")
 }
 
-func TestFilesInSubDirWhenNativeBridgeEnabled(t *testing.T) {
+func TestApexCopyCommandsCreateDirsOnce(t *testing.T) {
 	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib", "mylib2"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+
+		cc_library {
+			name: "mylib2",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`)
+
+	copyCmds := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule").Args["copy_commands"]
+	mkdirs := map[string]int{}
+	for _, cmd := range strings.Split(copyCmds, " && ") {
+		if strings.HasPrefix(cmd, "mkdir -p ") {
+			mkdirs[strings.TrimPrefix(cmd, "mkdir -p ")]++
+		}
+	}
+
+	lib64Dir := buildDir + "/.intermediates/myapex/android_common_myapex_image/image.apex/lib64"
+	if g, w := mkdirs[lib64Dir], 1; g != w {
+		t.Errorf("expected %d mkdir of %q, got %d in:\n%s", w, lib64Dir, g, copyCmds)
+	}
+	for dir, count := range mkdirs {
+		if count != 1 {
+			t.Errorf("expected %q to be created once, got %d", dir, count)
+		}
+	}
+	ensureContains(t, copyCmds, "image.apex/lib64/mylib.so")
+	ensureContains(t, copyCmds, "image.apex/lib64/mylib2.so")
+}
+
+func TestApexCreatedDirs(t *testing.T) {
+	dirs := createdDirs{"image": true}
+	if !dirs.add("image/app/Foo") {
+		t.Errorf("expected image/app/Foo to be created")
+	}
+	if dirs.add("image/app/Foo") {
+		t.Errorf("expected image/app/Foo to be created only once")
+	}
+	dirs.add("image/app/Foo/lib")
+	dirs.add("image/app/FooBar")
+
+	// Removing a directory removes its subdirectories, but not its siblings.
+	dirs.remove("image/app/Foo")
+	for _, dir := range []string{"image/app/Foo", "image/app/Foo/lib"} {
+		if !dirs.add(dir) {
+			t.Errorf("expected %q to be created again after its removal", dir)
+		}
+	}
+	for _, dir := range []string{"image", "image/app/FooBar"} {
+		if dirs.add(dir) {
+			t.Errorf("expected %q to stay created", dir)
+		}
+	}
+}
+
+func TestApexPayloadInputsGrouped(t *testing.T) {
+	var libs []string
+	bp := ""
+	for i := 0; i < 8; i++ {
+		lib := fmt.Sprintf("mylib%d", i)
+		libs = append(libs, `"`+lib+`"`)
+		bp += `
+		cc_library {
+			name: "` + lib + `",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+		`
+	}
+	ctx, _ := testApex(t, bp+`
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: [`+strings.Join(libs, ", ")+`],
+			allowed_files: "allowed.txt",
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`, withFiles(map[string][]byte{
+		"allowed.txt": nil,
+	}))
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	phony := module.Output("payload.apex-phony")
+	ensureListContains(t, phony.Inputs.Strings(),
+		buildDir+"/.intermediates/mylib0/android_arm64_armv8-a_shared_apex10000/mylib0.so")
+
+	// The build statements that need the payload files depend on the phony target instead.
+	for _, rule := range []string{"apexRule", "emitApexContentRule", "diffApexContentRule"} {
+		implicits := module.Rule(rule).Implicits.Strings()
+		ensureListContains(t, implicits, phony.Output.String())
+		for _, input := range phony.Inputs.Strings() {
+			ensureListNotContains(t, implicits, input)
+		}
+	}
+
+	content := module.Rule("emitApexContentRule")
+	paths := strings.Split(content.Args["paths"], " ")
+	ensureListContains(t, paths, "apex_manifest.pb")
+	ensureListContains(t, paths, "lib64/mylib0.so")
+}
+
+func TestFilesInSubDirWhenNativeBridgeEnabled(t *testing.T) {
+	ctx, config := testApex(t, `
 		apex {
 			name: "myapex",
 			key: "myapex.key",
@@ -2214,6 +2568,7 @@ func TestFilesInSubDirWhenNativeBridgeEnabled(t *testing.T) {
 					binaries: ["mybin"],
 				},
 			},
+			binaries: ["mybin_first"],
 			compile_multilib: "both",
 			native_bridge_supported: true,
 		}
@@ -2248,21 +2603,157 @@ func TestFilesInSubDirWhenNativeBridgeEnabled(t *testing.T) {
 				},
 			},
 		}
+
+		cc_binary {
+			name: "mybin_first",
+			system_shared_libs: [],
+			static_executable: true,
+			stl: "none",
+			apex_available: [ "myapex" ],
+			native_bridge_supported: true,
+		}
 	`, withNativeBridgeEnabled)
 	ensureExactContents(t, ctx, "myapex", "android_common_myapex_image", []string{
 		"bin/foo/bar/mybin",
 		"bin/foo/bar/mybin64",
 		"bin/arm/foo/bar/mybin",
 		"bin/arm64/foo/bar/mybin64",
+		// binaries are built for the first arch, and for the first translated arch
+		"bin/mybin_first",
+		"bin/arm64/mybin_first",
 		"lib/foo/bar/mylib.so",
 		"lib/arm/foo/bar/mylib.so",
 		"lib64/foo/bar/mylib.so",
 		"lib64/arm64/foo/bar/mylib.so",
 	})
-}
 
-func TestUseVendor(t *testing.T) {
-	ctx, _ := testApex(t, `
+	generateFsRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("generateFsConfig")
+	dirs := strings.Split(generateFsRule.Args["exec_paths"], " ")
+	roFiles := strings.Split(generateFsRule.Args["ro_paths"], " ")
+
+	// Ensure that the translated arch subdirectories get fs_config entries
+	ensureListContains(t, dirs, "bin/arm")
+	ensureListContains(t, dirs, "bin/arm/foo/bar")
+	ensureListContains(t, dirs, "bin/arm/foo/bar/mybin")
+	ensureListContains(t, dirs, "bin/arm64/foo/bar/mybin64")
+	ensureListContains(t, dirs, "lib/arm")
+	ensureListContains(t, dirs, "lib/arm/foo/bar")
+	ensureListContains(t, dirs, "lib64/arm64")
+	ensureListContains(t, dirs, "lib64/arm64/foo/bar")
+	ensureListContains(t, roFiles, "lib/arm/foo/bar/mylib.so")
+	ensureListContains(t, roFiles, "lib64/arm64/foo/bar/mylib.so")
+
+	// Ensure that the native bridge files are installed for the arch that runs them, which is
+	// the only one Make knows about
+	apexBundle := ctx.ModuleForTests("myapex", "android_common_myapex_image").Module().(*apexBundle)
+	data := android.AndroidMkDataForTest(t, config, "", apexBundle)
+	var builder strings.Builder
+	data.Custom(&builder, apexBundle.BaseModuleName(), "TARGET_", "", data)
+	androidMk := builder.String()
+	ensureContains(t, androidMk, "LOCAL_MODULE := mylib.native_bridge.myapex\n")
+	ensureContains(t, androidMk, "LOCAL_MODULE := mybin.native_bridge.myapex\n")
+	ensureContains(t, androidMk, "LOCAL_MODULE := mybin_first.native_bridge.myapex\n")
+	ensureNotContains(t, androidMk, "LOCAL_MODULE_TARGET_ARCH := arm\n")
+	ensureNotContains(t, androidMk, "LOCAL_MODULE_TARGET_ARCH := arm64\n")
+}
+
+func TestApexLicenseMetadata(t *testing.T) {
+	ctx, config := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			binaries: ["mybin"],
+			applicable_licenses: ["myapex_license"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		license_kind {
+			name: "notice_kind",
+			conditions: ["notice"],
+		}
+
+		license {
+			name: "myapex_license",
+			license_kinds: ["notice_kind"],
+		}
+
+		license {
+			name: "mylib_license",
+			license_kinds: ["notice_kind"],
+			package_name: "mylib",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+			applicable_licenses: ["mylib_license"],
+		}
+
+		cc_binary {
+			name: "mybin",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			static_executable: true,
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	apexBundle := module.Module().(*apexBundle)
+	actual := android.ContentFromFileRuleForTests(t, module.Output(apexBundle.licenseMetadataFile.String()))
+	expected := `[
+  {
+    "module": "mybin"
+  },
+  {
+    "module": "myapex",
+    "licenses": [
+      "myapex_license"
+    ],
+    "license_kinds": [
+      "notice_kind"
+    ],
+    "license_conditions": [
+      "notice"
+    ]
+  },
+  {
+    "module": "mylib",
+    "licenses": [
+      "mylib_license"
+    ],
+    "license_kinds": [
+      "notice_kind"
+    ],
+    "license_conditions": [
+      "notice"
+    ],
+    "package_name": "mylib"
+  }
+]
+`
+	if actual != expected {
+		t.Errorf("expected license metadata:\n%s\ngot:\n%s", expected, actual)
+	}
+
+	data := android.AndroidMkDataForTest(t, config, "", apexBundle)
+	var builder strings.Builder
+	data.Custom(&builder, apexBundle.BaseModuleName(), "TARGET_", "", data)
+	ensureContains(t, builder.String(), "LOCAL_SOONG_LICENSE_METADATA := "+apexBundle.licenseMetadataFile.String())
+}
+
+func TestUseVendor(t *testing.T) {
+	ctx, _ := testApex(t, `
 		apex {
 			name: "myapex",
 			key: "myapex.key",
@@ -2303,6 +2794,10 @@ func TestUseVendor(t *testing.T) {
 		for _, implicit := range i.Implicits {
 			inputsList = append(inputsList, implicit.String())
 		}
+		// The payload files may be grouped behind a phony target.
+		for _, input := range i.Inputs {
+			inputsList = append(inputsList, input.String())
+		}
 	}
 	inputsString := strings.Join(inputsList, " ")
 
@@ -2470,6 +2965,109 @@ func TestVendorApex_use_vndk_as_stable(t *testing.T) {
 	ensureListContains(t, requireNativeLibs, ":vndk")
 }
 
+func TestStrictUpdatability(t *testing.T) {
+	bp := func(allowedDeps string) string {
+		return `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			binaries: ["mybin"],
+			vendor: true,
+			use_vndk_as_stable: true,
+			strict_updatability: true,
+			strict_updatability_allowed_deps: [` + allowedDeps + `],
+		}
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+		cc_binary {
+			name: "mybin",
+			vendor: true,
+			shared_libs: ["libvndk", "libvendor"],
+		}
+		cc_library {
+			name: "libvndk",
+			vndk: {
+				enabled: true,
+			},
+			vendor_available: true,
+			product_available: true,
+		}
+		cc_library {
+			name: "libvendor",
+			vendor: true,
+			apex_available: ["myapex"],
+		}
+	`
+	}
+
+	testApexError(t, `"mybin" requires "libvndk", which is a VNDK library whose interface is not stable`,
+		bp(`"libc++"`))
+
+	// The libraries in the allow list are not reported.
+	testApex(t, bp(`"libc++", "libvndk"`))
+}
+
+func TestStrictUpdatabilityPackagedLibs(t *testing.T) {
+	bp := func(libplatformAvailable, allowedDeps string) string {
+		return `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			strict_updatability: true,
+			strict_updatability_allowed_deps: [` + allowedDeps + `],
+		}
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["libplatform", "libstubs"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+		cc_library {
+			name: "libplatform",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [` + libplatformAvailable + `],
+		}
+		cc_library {
+			name: "libstubs",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			stubs: {
+				versions: ["1"],
+			},
+			apex_available: ["//apex_available:platform"],
+		}
+	`
+	}
+
+	// A library without stubs that is packaged in the APEX but also used by the platform is
+	// rejected.
+	testApexError(t, `"mylib" requires "libplatform", which is packaged in the APEX but is also available to the platform`,
+		bp(`"//apex_available:platform", "myapex"`, ""))
+
+	// The same library is accepted when it is private to the APEX or allowed explicitly. The
+	// library with stubs is always accepted, it is not packaged in the APEX.
+	ctx, _ := testApex(t, bp(`"myapex"`, ""))
+	ensureExactContents(t, ctx, "myapex", "android_common_myapex_image", []string{
+		"lib64/mylib.so",
+		"lib64/libplatform.so",
+	})
+	testApex(t, bp(`"//apex_available:platform", "myapex"`, `"libplatform"`))
+}
+
 func TestApex_withPrebuiltFirmware(t *testing.T) {
 	testCases := []struct {
 		name           string
@@ -2620,6 +3218,7 @@ func TestStaticLinking(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "mylib.map.txt",
 				versions: ["1", "2", "3"],
 			},
 			apex_available: [
@@ -2692,6 +3291,16 @@ func TestKeys(t *testing.T) {
 			"vendor/foo/devkeys/testkey.pem")
 	}
 
+	// check that the key pair is validated before the payload is signed
+	keyPairCheck := ctx.ModuleForTests("myapex.key", "android_common").Rule("checkApexKeyPair")
+	if g, w := keyPairCheck.Args["publicKey"], "vendor/foo/devkeys/testkey.avbpubkey"; g != w {
+		t.Errorf("key pair check public key %q is not %q", g, w)
+	}
+	apexRule := ctx.ModuleForTests("myapex_keytest", "android_common_myapex_keytest_image").Rule("apexRule")
+	if !android.InList(keyPairCheck.Output.String(), apexRule.Validations.Strings()) {
+		t.Errorf("expected apexRule to be validated by %q, got %v", keyPairCheck.Output, apexRule.Validations)
+	}
+
 	// check the APK certs. It should be overridden to myapex.certificate.override
 	certs := ctx.ModuleForTests("myapex_keytest", "android_common_myapex_keytest_image").Rule("signapk").Args["certificates"]
 	if certs != "testkey.override.x509.pem testkey.override.pk8" {
@@ -2700,6 +3309,56 @@ func TestKeys(t *testing.T) {
 	}
 }
 
+func TestApexPublicKeyCheck(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			previous_apex: "myapex-arm.apex",
+		}
+
+		prebuilt_apex {
+			name: "myapex.prebuilt",
+			src: "myapex-arm64.apex",
+			key: "myapex.key",
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+
+	// The previous version of the APEX must be signed with the same key.
+	apex := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	previousCheck := apex.Output("public_key_check/previous_apex.timestamp")
+	if g, w := previousCheck.Input.String(), "myapex-arm.apex"; g != w {
+		t.Errorf("expected the key of %q to be checked, got %q", w, g)
+	}
+	if g, w := previousCheck.Args["publicKey"], "testkey.avbpubkey"; g != w {
+		t.Errorf("expected the public key %q to be checked, got %q", w, g)
+	}
+	apexRule := apex.Rule("apexRule")
+	if !android.InList(previousCheck.Output.String(), apexRule.Validations.Strings()) {
+		t.Errorf("expected apexRule to be validated by %q, got %v", previousCheck.Output, apexRule.Validations)
+	}
+
+	// The payload of the prebuilt must be signed with the key, before the prebuilt is installed.
+	prebuilt := ctx.ModuleForTests("myapex.prebuilt", "android_common")
+	prebuiltCheck := prebuilt.Output("public_key_check/src.timestamp")
+	if g, w := prebuiltCheck.Input.String(), "myapex-arm64.apex"; g != w {
+		t.Errorf("expected the key of %q to be checked, got %q", w, g)
+	}
+	if g, w := prebuiltCheck.Args["keyName"], "myapex.key"; g != w {
+		t.Errorf("expected the key of %q to be checked, got %q", w, g)
+	}
+	prebuiltCopy := prebuilt.Output("myapex.prebuilt.apex")
+	if prebuiltCopy.Validation == nil || prebuiltCopy.Validation.String() != prebuiltCheck.Output.String() {
+		t.Errorf("expected the prebuilt to be validated by %q, got %v", prebuiltCheck.Output, prebuiltCopy.Validation)
+	}
+}
+
 func TestCertificate(t *testing.T) {
 	t.Run("if unspecified, it defaults to DefaultAppCertificate", func(t *testing.T) {
 		ctx, _ := testApex(t, `
@@ -2985,6 +3644,7 @@ func TestHeaderLibsDependency(t *testing.T) {
 			header_libs: ["mylib_headers"],
 			export_header_lib_headers: ["mylib_headers"],
 			stubs: {
+				symbol_file: "mylib.map.txt",
 				versions: ["1", "2", "3"],
 			},
 			apex_available: [ "myapex" ],
@@ -3623,6 +4283,7 @@ func TestDependenciesInApexManifest(t *testing.T) {
 			name: "libfoo",
 			srcs: ["mytest.cpp"],
 			stubs: {
+				symbol_file: "libfoo.map.txt",
 				versions: ["1"],
 			},
 			system_shared_libs: [],
@@ -3806,32 +4467,15 @@ func TestTestApex(t *testing.T) {
 	ensureListContains(t, ctx.ModuleVariantsForTests("mylib_common_test"), "android_arm64_armv8-a_shared")
 }
 
-func TestApexWithTarget(t *testing.T) {
+func TestTestApexPayloadFiles(t *testing.T) {
 	ctx, _ := testApex(t, `
-		apex {
+		apex_test {
 			name: "myapex",
 			key: "myapex.key",
-			multilib: {
-				first: {
-					native_shared_libs: ["mylib_common"],
-				}
-			},
-			target: {
-				android: {
-					multilib: {
-						first: {
-							native_shared_libs: ["mylib"],
-						}
-					}
-				},
-				host: {
-					multilib: {
-						first: {
-							native_shared_libs: ["mylib2"],
-						}
-					}
-				}
-			}
+			test_only_payload_files: [
+				"etc/corrupt.json=corrupt.json",
+				"lib64/libbad.so=:bad_lib",
+			],
 		}
 
 		apex_key {
@@ -3840,50 +4484,197 @@ func TestApexWithTarget(t *testing.T) {
 			private_key: "testkey.pem",
 		}
 
-		cc_library {
-			name: "mylib",
-			srcs: ["mylib.cpp"],
-			system_shared_libs: [],
-			stl: "none",
-			// TODO: remove //apex_available:platform
-			apex_available: [
-				"//apex_available:platform",
-				"myapex",
-			],
+		filegroup {
+			name: "bad_lib",
+			srcs: ["bad_lib.txt"],
 		}
+	`, withFiles(map[string][]byte{
+		"corrupt.json": nil,
+		"bad_lib.txt":  nil,
+	}))
 
-		cc_library {
-			name: "mylib_common",
-			srcs: ["mylib.cpp"],
-			system_shared_libs: [],
-			stl: "none",
-			compile_multilib: "first",
-			// TODO: remove //apex_available:platform
-			apex_available: [
-				"//apex_available:platform",
-				"myapex",
-			],
-		}
+	ensureExactContents(t, ctx, "myapex", "android_common_myapex_image", []string{
+		"etc/corrupt.json",
+		"lib64/libbad.so",
+	})
 
-		cc_library {
-			name: "mylib2",
-			srcs: ["mylib.cpp"],
-			system_shared_libs: [],
-			stl: "none",
-			compile_multilib: "first",
+	copyCmds := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule").Args["copy_commands"]
+	ensureContains(t, copyCmds, "cp -f bad_lib.txt ")
+
+	testApexError(t, `test_only_payload_files: property allowed only in apex_test module type`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			test_only_payload_files: ["etc/corrupt.json=corrupt.json"],
 		}
-	`)
 
-	apexRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule")
-	copyCmds := apexRule.Args["copy_commands"]
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`, withFiles(map[string][]byte{
+		"corrupt.json": nil,
+	}))
 
-	// Ensure that main rule creates an output
-	ensureContains(t, apexRule.Output.String(), "myapex.apex.unsigned")
+	testApexError(t, `"../escape" is not a clean relative path`, `
+		apex_test {
+			name: "myapex",
+			key: "myapex.key",
+			test_only_payload_files: ["../escape=corrupt.json"],
+		}
 
-	// Ensure that apex variant is created for the direct dep
-	ensureListContains(t, ctx.ModuleVariantsForTests("mylib"), "android_arm64_armv8-a_shared_apex10000")
-	ensureListContains(t, ctx.ModuleVariantsForTests("mylib_common"), "android_arm64_armv8-a_shared_apex10000")
-	ensureListNotContains(t, ctx.ModuleVariantsForTests("mylib2"), "android_arm64_armv8-a_shared_apex10000")
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`, withFiles(map[string][]byte{
+		"corrupt.json": nil,
+	}))
+
+	testApexError(t, `"apex_pubkey" is created by the build and can't be replaced`, `
+		apex_test {
+			name: "myapex",
+			key: "myapex.key",
+			test_only_payload_files: ["apex_pubkey=corrupt.json"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`, withFiles(map[string][]byte{
+		"corrupt.json": nil,
+	}))
+
+	testApexError(t, `"etc/myetc" is already the path of "myetc" in the payload`, `
+		apex_test {
+			name: "myapex",
+			key: "myapex.key",
+			prebuilts: ["myetc"],
+			test_only_payload_files: ["etc/myetc=corrupt.json"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		prebuilt_etc {
+			name: "myetc",
+			src: "myprebuilt",
+		}
+	`, withFiles(map[string][]byte{
+		"corrupt.json": nil,
+	}))
+}
+
+func TestTestApexPayloadFilesManifest(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex_test {
+			name: "myapex",
+			key: "myapex.key",
+			test_only_payload_files: ["apex_manifest.pb=corrupt_manifest.pb"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`, withFiles(map[string][]byte{
+		"corrupt_manifest.pb": nil,
+	}))
+
+	// The file replaces the generated manifest instead of being copied into the payload.
+	ensureExactContents(t, ctx, "myapex", "android_common_myapex_image", []string{})
+
+	apexRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule")
+	ensureEquals(t, apexRule.Args["manifest"], "corrupt_manifest.pb")
+	ensureNotContains(t, apexRule.Args["copy_commands"], "corrupt_manifest.pb")
+}
+
+func TestApexWithTarget(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			multilib: {
+				first: {
+					native_shared_libs: ["mylib_common"],
+				}
+			},
+			target: {
+				android: {
+					multilib: {
+						first: {
+							native_shared_libs: ["mylib"],
+						}
+					}
+				},
+				host: {
+					multilib: {
+						first: {
+							native_shared_libs: ["mylib2"],
+						}
+					}
+				}
+			}
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			// TODO: remove //apex_available:platform
+			apex_available: [
+				"//apex_available:platform",
+				"myapex",
+			],
+		}
+
+		cc_library {
+			name: "mylib_common",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			compile_multilib: "first",
+			// TODO: remove //apex_available:platform
+			apex_available: [
+				"//apex_available:platform",
+				"myapex",
+			],
+		}
+
+		cc_library {
+			name: "mylib2",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			compile_multilib: "first",
+		}
+	`)
+
+	apexRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule")
+	copyCmds := apexRule.Args["copy_commands"]
+
+	// Ensure that main rule creates an output
+	ensureContains(t, apexRule.Output.String(), "myapex.apex.unsigned")
+
+	// Ensure that apex variant is created for the direct dep
+	ensureListContains(t, ctx.ModuleVariantsForTests("mylib"), "android_arm64_armv8-a_shared_apex10000")
+	ensureListContains(t, ctx.ModuleVariantsForTests("mylib_common"), "android_arm64_armv8-a_shared_apex10000")
+	ensureListNotContains(t, ctx.ModuleVariantsForTests("mylib2"), "android_arm64_armv8-a_shared_apex10000")
 
 	// Ensure that both direct and indirect deps are copied into apex
 	ensureContains(t, copyCmds, "image.apex/lib64/mylib.so")
@@ -3982,6 +4773,129 @@ func TestApexWithShBinary(t *testing.T) {
 	ensureContains(t, copyCmds, "image.apex/bin/script/myscript.sh")
 }
 
+func TestApexBuildVariantProperties(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			defaults: ["myapex-defaults"],
+			binaries: ["myscript"],
+			debuggable: {
+				binaries: ["myscript_debug"],
+				installable: false,
+			},
+		}
+
+		apex_defaults {
+			name: "myapex-defaults",
+			eng: {
+				binaries: ["myscript_eng"],
+			},
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		sh_binary {
+			name: "myscript",
+			src: "mylib.cpp",
+			filename: "myscript.sh",
+		}
+
+		sh_binary {
+			name: "myscript_debug",
+			src: "mylib.cpp",
+			filename: "myscript_debug.sh",
+		}
+
+		sh_binary {
+			name: "myscript_eng",
+			src: "mylib.cpp",
+			filename: "myscript_eng.sh",
+		}
+	`
+
+	testCases := []struct {
+		variant         string
+		debuggable, eng bool
+	}{
+		{"user", false, false},
+		{"userdebug", true, false},
+		{"eng", true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.variant, func(t *testing.T) {
+			ctx, _ := testApex(t, bp, func(_ map[string][]byte, config android.Config) {
+				config.TestProductVariables.Debuggable = proptools.BoolPtr(tc.debuggable)
+				config.TestProductVariables.Eng = proptools.BoolPtr(tc.eng)
+			})
+
+			module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+			copyCmds := module.Rule("apexRule").Args["copy_commands"]
+			ensureContains(t, copyCmds, "image.apex/bin/myscript.sh")
+			if tc.debuggable {
+				ensureContains(t, copyCmds, "image.apex/bin/myscript_debug.sh")
+			} else {
+				ensureNotContains(t, copyCmds, "image.apex/bin/myscript_debug.sh")
+			}
+			if tc.eng {
+				ensureContains(t, copyCmds, "image.apex/bin/myscript_eng.sh")
+			} else {
+				ensureNotContains(t, copyCmds, "image.apex/bin/myscript_eng.sh")
+			}
+
+			if g, w := module.Module().(*apexBundle).installable(), !tc.debuggable; g != w {
+				t.Errorf("expected installable %v, got %v", w, g)
+			}
+		})
+	}
+}
+
+func TestApexElfPayloadCheck(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			binaries: ["myscript"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+
+		sh_binary {
+			name: "myscript",
+			src: "mylib.cpp",
+			filename: "myscript.sh",
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	files := android.ContentFromFileRuleForTests(t, module.Output("elf_payload_files.txt"))
+	ensureContains(t, files, "lib64/mylib.so ")
+	ensureContains(t, files, "lib/mylib.so ")
+	ensureNotContains(t, files, "myscript.sh")
+
+	check := module.Rule("elf_payload_check")
+	ensureContains(t, check.RuleParams.Command, "check_elf_payload")
+	ensureListContains(t, module.Rule("apexRule").Validations.Strings(), check.Output.String())
+}
+
 func TestApexInVariousPartition(t *testing.T) {
 	testcases := []struct {
 		propName, parition, flattenedPartition string
@@ -4126,6 +5040,32 @@ func TestFileContexts_SetViaFileGroup(t *testing.T) {
 	ensureContains(t, rule.RuleParams.Command, "cat product_specific_file_contexts")
 }
 
+func TestFileContexts_StubWhenDefaultIsMissing(t *testing.T) {
+	bp := `
+		apex {
+			name: "newapex",
+			key: "myapex.key",
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`
+
+	// Checkouts with system/sepolicy need its file_contexts for the APEX.
+	testApexError(t, `"newapex" .*: file_contexts: cannot find file_contexts file: "system/sepolicy/apex/newapex-file_contexts"`, bp)
+
+	// Checkouts without system/sepolicy use a stub instead.
+	ctx, _ := testApex(t, bp, withoutSepolicy)
+	module := ctx.ModuleForTests("newapex", "android_common_newapex_image")
+	stub := module.Output("file_contexts.stub")
+	ensureContains(t, android.ContentFromFileRuleForTests(t, stub), "(/.*)?    u:object_r:system_file:s0")
+	rule := module.Output("file_contexts")
+	ensureContains(t, rule.RuleParams.Command, "cat "+stub.Output.String())
+}
+
 func TestApexKeyFromOtherModule(t *testing.T) {
 	ctx, _ := testApex(t, `
 		apex_key {
@@ -4764,6 +5704,7 @@ func TestApexAvailable_InvalidApexName(t *testing.T) {
 		stl: "none",
 		system_shared_libs: [],
 		stubs: {
+			symbol_file: "libbaz.map.txt",
 			versions: ["10", "20", "30"],
 		},
 	}`)
@@ -4812,6 +5753,7 @@ func TestApexAvailable_CheckForPlatform(t *testing.T) {
 		system_shared_libs: [],
 		apex_available: ["myapex"],
 		stubs: {
+			symbol_file: "libbaz.map.txt",
 			versions: ["1"],
 		},
 	}`)
@@ -5629,14 +6571,18 @@ func TestAppBundle(t *testing.T) {
 
 	ensureContains(t, content, `"compression":{"uncompressed_glob":["apex_payload.img","apex_manifest.*"]}`)
 	ensureContains(t, content, `"apex_config":{"apex_embedded_apk_config":[{"package_name":"com.android.foo","path":"app/AppFoo/AppFoo.apk"}]}`)
+	ensureNotContains(t, content, `"optimizations"`)
 }
 
-func TestAppSetBundle(t *testing.T) {
+func TestBundleConfig(t *testing.T) {
 	ctx, _ := testApex(t, `
 		apex {
 			name: "myapex",
 			key: "myapex.key",
-			apps: ["AppSet"],
+			bundle_config: {
+				uncompressed_globs: ["etc/*.bin"],
+				split_dimensions: ["abi", "screen_density"],
+			},
 		}
 
 		apex_key {
@@ -5644,30 +6590,68 @@ func TestAppSetBundle(t *testing.T) {
 			public_key: "testkey.avbpubkey",
 			private_key: "testkey.pem",
 		}
+		`)
 
-		android_app_set {
-			name: "AppSet",
-			set: "AppSet.apks",
-		}`)
-	mod := ctx.ModuleForTests("myapex", "android_common_myapex_image")
-	bundleConfigRule := mod.Output("bundle_config.json")
+	bundleConfigRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Output("bundle_config.json")
 	content := bundleConfigRule.Args["content"]
-	ensureContains(t, content, `"compression":{"uncompressed_glob":["apex_payload.img","apex_manifest.*"]}`)
-	s := mod.Rule("apexRule").Args["copy_commands"]
-	copyCmds := regexp.MustCompile(" *&& *").Split(s, -1)
-	if len(copyCmds) != 3 {
-		t.Fatalf("Expected 3 commands, got %d in:\n%s", len(copyCmds), s)
-	}
-	ensureMatches(t, copyCmds[0], "^rm -rf .*/app/AppSet$")
-	ensureMatches(t, copyCmds[1], "^mkdir -p .*/app/AppSet$")
-	ensureMatches(t, copyCmds[2], "^unzip .*-d .*/app/AppSet .*/AppSet.zip$")
-}
 
-func TestAppSetBundlePrebuilt(t *testing.T) {
-	ctx, _ := testApex(t, "", func(fs map[string][]byte, config android.Config) {
-		bp := `
-		apex_set {
-			name: "myapex",
+	ensureContains(t, content, `"optimizations":{"splits_config":{"split_dimension":[{"value":"ABI"},{"value":"SCREEN_DENSITY"}]}}`)
+	ensureContains(t, content, `"compression":{"uncompressed_glob":["apex_payload.img","apex_manifest.*","etc/*.bin"]}`)
+
+	testApexError(t, `bundle_config.split_dimensions: unknown split dimension "density"`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			bundle_config: {
+				split_dimensions: ["density"],
+			},
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+		`)
+}
+
+func TestAppSetBundle(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			apps: ["AppSet"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		android_app_set {
+			name: "AppSet",
+			set: "AppSet.apks",
+		}`)
+	mod := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	bundleConfigRule := mod.Output("bundle_config.json")
+	content := bundleConfigRule.Args["content"]
+	ensureContains(t, content, `"compression":{"uncompressed_glob":["apex_payload.img","apex_manifest.*"]}`)
+	s := mod.Rule("apexRule").Args["copy_commands"]
+	copyCmds := regexp.MustCompile(" *&& *").Split(s, -1)
+	if len(copyCmds) != 3 {
+		t.Fatalf("Expected 3 commands, got %d in:\n%s", len(copyCmds), s)
+	}
+	ensureMatches(t, copyCmds[0], "^rm -rf .*/app/AppSet$")
+	ensureMatches(t, copyCmds[1], "^mkdir -p .*/app/AppSet$")
+	ensureMatches(t, copyCmds[2], "^unzip .*-d .*/app/AppSet .*/AppSet.zip$")
+}
+
+func TestAppSetBundlePrebuilt(t *testing.T) {
+	ctx, _ := testApex(t, "", func(fs map[string][]byte, config android.Config) {
+		bp := `
+		apex_set {
+			name: "myapex",
 			filename: "foo_v2.apex",
 			sanitized: {
 				none: { set: "myapex.apks", },
@@ -5783,7 +6767,7 @@ func testNoUpdatableJarsInBootImage(t *testing.T, errmsg string, transformDexpre
 	testDexpreoptWithApexes(t, bp, errmsg, transformDexpreoptConfig)
 }
 
-func testDexpreoptWithApexes(t *testing.T, bp, errmsg string, transformDexpreoptConfig func(*dexpreopt.GlobalConfig)) {
+func testDexpreoptWithApexes(t *testing.T, bp, errmsg string, transformDexpreoptConfig func(*dexpreopt.GlobalConfig)) *android.TestContext {
 	t.Helper()
 
 	bp += cc.GatherRequiredDepsForTest(android.Android)
@@ -5816,6 +6800,7 @@ func testDexpreoptWithApexes(t *testing.T, bp, errmsg string, transformDexpreopt
 	java.RegisterSystemModulesBuildComponents(ctx)
 	java.RegisterAppBuildComponents(ctx)
 	java.RegisterDexpreoptBootJarsComponents(ctx)
+	java.RegisterUpdatableSystemServerJarsComponents(ctx)
 	ctx.PostDepsMutators(android.RegisterOverridePostDepsMutators)
 	ctx.PreDepsMutators(RegisterPreDepsMutators)
 	ctx.PostDepsMutators(RegisterPostDepsMutators)
@@ -5837,10 +6822,202 @@ func testDexpreoptWithApexes(t *testing.T, bp, errmsg string, transformDexpreopt
 		android.FailIfErrored(t, errs)
 	} else if len(errs) > 0 {
 		android.FailIfNoMatchingErrors(t, errmsg, errs)
-		return
+		return nil
 	} else {
 		t.Fatalf("missing expected error %q (0 errors are returned)", errmsg)
 	}
+
+	return ctx
+}
+
+func TestDexpreoptAppsInApex(t *testing.T) {
+	bp := `
+		android_app {
+			name: "AppFoo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			apex_available: ["some-non-updatable-apex"],
+		}
+
+		android_app {
+			name: "AppBar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			apex_available: ["some-updatable-apex"],
+		}
+
+		apex {
+			name: "some-non-updatable-apex",
+			key: "some-non-updatable-apex.key",
+			apps: ["AppFoo"],
+		}
+
+		apex {
+			name: "some-updatable-apex",
+			key: "some-updatable-apex.key",
+			apps: ["AppBar"],
+			updatable: true,
+			min_sdk_version: "current",
+		}
+
+		apex_key {
+			name: "some-non-updatable-apex.key",
+		}
+
+		apex_key {
+			name: "some-updatable-apex.key",
+		}
+
+		filegroup {
+			name: "some-non-updatable-apex-file_contexts",
+			srcs: [
+				"system/sepolicy/apex/some-non-updatable-apex-file_contexts",
+			],
+		}
+
+		filegroup {
+			name: "some-updatable-apex-file_contexts",
+			srcs: [
+				"system/sepolicy/apex/some-updatable-apex-file_contexts",
+			],
+		}
+	`
+
+	ctx := testDexpreoptWithApexes(t, bp, "", func(*dexpreopt.GlobalConfig) {})
+
+	// The app is dexpreopted for the path of the APK in the mounted APEX...
+	dexpreoptRule := ctx.ModuleForTests("AppFoo", "android_common_apex10000").Rule("dexpreopt")
+	ensureContains(t, dexpreoptRule.RuleParams.Command,
+		"--dex-location=/apex/some-non-updatable-apex/app/AppFoo/AppFoo.apk")
+
+	// ... and the artifacts are installed next to the APK in the APEX.
+	copyCmds := ctx.ModuleForTests("some-non-updatable-apex", "android_common_some-non-updatable-apex_image").
+		Rule("apexRule").Args["copy_commands"]
+	ensureContains(t, copyCmds, "image.apex/app/AppFoo/AppFoo.apk")
+	ensureContains(t, copyCmds, "image.apex/app/AppFoo/oat/arm64/AppFoo.odex")
+	ensureContains(t, copyCmds, "image.apex/app/AppFoo/oat/arm64/AppFoo.vdex")
+
+	// Apps in updatable APEXes aren't dexpreopted, as the APEX can be installed on devices with
+	// another boot image.
+	if rule := ctx.ModuleForTests("AppBar", "android_common_apex10000").MaybeRule("dexpreopt"); rule.Rule != nil {
+		t.Errorf("AppBar in an updatable APEX should not be dexpreopted")
+	}
+	copyCmds = ctx.ModuleForTests("some-updatable-apex", "android_common_some-updatable-apex_image").
+		Rule("apexRule").Args["copy_commands"]
+	ensureContains(t, copyCmds, "image.apex/app/AppBar/AppBar.apk")
+	ensureNotContains(t, copyCmds, "image.apex/app/AppBar/oat")
+}
+
+func TestDexpreoptUpdatableSystemServerJars(t *testing.T) {
+	bp := `
+		java_library {
+			name: "service-platform",
+			srcs: ["a.java"],
+			installable: true,
+		}
+
+		java_library {
+			name: "service-a",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			installable: true,
+			apex_available: ["some-updatable-apex"],
+		}
+
+		java_library {
+			name: "service-b",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			installable: true,
+			apex_available: ["some-updatable-apex"],
+		}
+
+		android_app {
+			name: "AppFoo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			apex_available: ["some-non-updatable-apex"],
+		}
+
+		apex {
+			name: "some-updatable-apex",
+			key: "some-updatable-apex.key",
+			java_libs: ["service-a", "service-b"],
+			updatable: true,
+			min_sdk_version: "current",
+		}
+
+		apex {
+			name: "some-non-updatable-apex",
+			key: "some-non-updatable-apex.key",
+			apps: ["AppFoo"],
+		}
+
+		apex_key {
+			name: "some-updatable-apex.key",
+		}
+
+		apex_key {
+			name: "some-non-updatable-apex.key",
+		}
+
+		filegroup {
+			name: "some-non-updatable-apex-file_contexts",
+			srcs: [
+				"system/sepolicy/apex/some-non-updatable-apex-file_contexts",
+			],
+		}
+
+		filegroup {
+			name: "some-updatable-apex-file_contexts",
+			srcs: [
+				"system/sepolicy/apex/some-updatable-apex-file_contexts",
+			],
+		}
+	`
+
+	ctx := testDexpreoptWithApexes(t, bp, "", func(config *dexpreopt.GlobalConfig) {
+		config.SystemServerJars = []string{"service-platform"}
+		config.UpdatableSystemServerJars = android.CreateTestConfiguredJarList(
+			[]string{"some-updatable-apex:service-a", "some-updatable-apex:service-b"})
+	})
+
+	// The jar is dexpreopted for its location in the mounted APEX, with all the non-updatable jars
+	// and the preceding updatable jars in its class loader context...
+	dexpreoptRule := ctx.ModuleForTests("service-b", "android_common_apex10000").Rule("dexpreopt")
+	ensureContains(t, dexpreoptRule.RuleParams.Command,
+		"--dex-location=/apex/some-updatable-apex/javalib/service-b.jar")
+	ensureContains(t, dexpreoptRule.RuleParams.Command,
+		"--stored-class-loader-context=PCL[/system/framework/service-platform.jar:/apex/some-updatable-apex/javalib/service-a.jar]")
+
+	// ... and the artifacts are installed in the system partition, not in the APEX.
+	serviceB := ctx.ModuleForTests("service-b", "android_common_apex10000").Module().(*java.Library)
+	ensureContains(t, serviceB.DexpreoptBuiltInstalledOnSystem().String(),
+		"/system/framework/oat/arm64/apex@some-updatable-apex@javalib@service-b.jar@classes.odex")
+	copyCmds := ctx.ModuleForTests("some-updatable-apex", "android_common_some-updatable-apex_image").
+		Rule("apexRule").Args["copy_commands"]
+	ensureContains(t, copyCmds, "image.apex/javalib/service-b.jar")
+	ensureNotContains(t, copyCmds, "odex")
+
+	// Apps in non-updatable APEXes still have their artifacts installed next to the APK in the
+	// APEX.
+	copyCmds = ctx.ModuleForTests("some-non-updatable-apex", "android_common_some-non-updatable-apex_image").
+		Rule("apexRule").Args["copy_commands"]
+	ensureContains(t, copyCmds, "image.apex/app/AppFoo/oat/arm64/AppFoo.odex")
+	ensureContains(t, copyCmds, "image.apex/app/AppFoo/oat/arm64/AppFoo.vdex")
+
+	// A jar that is only in an updatable APEX can't be a non-updatable system server jar.
+	testDexpreoptWithApexes(t, bp, `system server jar "service-a" is only in updatable APEXes`,
+		func(config *dexpreopt.GlobalConfig) {
+			config.SystemServerJars = []string{"service-platform", "service-a"}
+		})
+
+	// An updatable system server jar must be in an APEX.
+	testDexpreoptWithApexes(t, bp, `updatable system server jar "service-platform" must be in an APEX`,
+		func(config *dexpreopt.GlobalConfig) {
+			config.UpdatableSystemServerJars = android.CreateTestConfiguredJarList(
+				[]string{"platform:service-platform"})
+		})
 }
 
 func TestUpdatable_should_set_min_sdk_version(t *testing.T) {
@@ -6095,6 +7272,7 @@ func TestTestFor(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "mylib.map.txt",
 				versions: ["1"],
 			},
 			apex_available: ["myapex"],
@@ -6228,6 +7406,7 @@ func TestNoStaticLinkingToStubsLib(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "otherlib.map.txt",
 				versions: ["1", "2", "3"],
 			},
 			apex_available: [ "myapex" ],
@@ -6275,6 +7454,311 @@ func TestApexKeysTxt(t *testing.T) {
 	ensureContains(t, content, `name="myapex.apex" public_key="PRESIGNED" private_key="PRESIGNED" container_certificate="PRESIGNED" container_private_key="PRESIGNED" partition="system"`)
 }
 
+func TestApexInfoList(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+		}
+
+		apex {
+			name: "otherapex",
+			key: "myapex.key",
+			installable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+
+	apexInfoList := ctx.SingletonForTests("apex_info_list").Output("apex/apex-info-list.xml")
+	manifest := ctx.ModuleForTests("myapex", "android_common_myapex_image").Output("apex_manifest_full.json")
+	ensureListContains(t, apexInfoList.Inputs.Strings(), manifest.Output.String())
+	ensureContains(t, apexInfoList.Args["apexes"], manifest.Output.String()+":/system/apex/myapex.apex")
+	ensureNotContains(t, apexInfoList.Args["apexes"], "otherapex")
+}
+
+func TestApexDuplicatePayload(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+		}
+
+		apex {
+			name: "otherapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+		}
+
+		apex_test {
+			name: "myapex_test",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			file_contexts: ":myapex-file_contexts",
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["//apex_available:platform", "myapex", "otherapex", "myapex_test"],
+		}
+	`)
+
+	report := ctx.SingletonForTests("apex_duplicate_payload").Output("apex/duplicate-payload-report.txt")
+	fileList := ctx.SingletonForTests("apex_duplicate_payload").Output("apex/duplicate-payload-files.txt")
+	ensureEquals(t, report.Input.String(), fileList.Output.String())
+
+	apexLib := ctx.ModuleForTests("mylib", "android_arm64_armv8-a_shared_apex10000").Module().(*cc.Module).OutputFile().Path()
+	platformLib := ctx.ModuleForTests("mylib", "android_arm64_armv8-a_shared").Module().FilesToInstall()[0]
+	ensureListContains(t, report.Implicits.Strings(), apexLib.String())
+	ensureListContains(t, report.Implicits.Strings(), platformLib.String())
+
+	content := android.ContentFromFileRuleForTests(t, fileList)
+	ensureContains(t, content, "myapex\tlib64/mylib.so\t"+apexLib.String()+"\n")
+	ensureContains(t, content, "otherapex\tlib64/mylib.so\t"+apexLib.String()+"\n")
+	ensureContains(t, content, "platform\t"+platformLib.String()+"\t"+platformLib.String()+"\n")
+	ensureNotContains(t, content, "myapex_test")
+}
+
+func TestDebugApex(t *testing.T) {
+	ctx, _ := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib", "mylib2"],
+			debug_apex: true,
+			debug_apex_keep_symbols: ["mylib"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+
+		cc_library {
+			name: "mylib2",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	mylib := ctx.ModuleForTests("mylib", "android_arm64_armv8-a_shared_apex10000").Module().(*cc.Module)
+	mylib2 := ctx.ModuleForTests("mylib2", "android_arm64_armv8-a_shared_apex10000").Module().(*cc.Module)
+
+	apexRule := module.Output("myapex.apex.unsigned")
+	ensureContains(t, apexRule.Args["copy_commands"], "cp -f "+mylib.OutputFile().String()+" ")
+	ensureNotContains(t, apexRule.Args["copy_commands"], mylib.UnstrippedOutputFile().String())
+
+	debugApexRule := module.Output("myapex-debug.apex.unsigned")
+	debugCopyCmds := debugApexRule.Args["copy_commands"]
+	ensureContains(t, debugCopyCmds, "cp -f "+mylib.UnstrippedOutputFile().String()+" ")
+	ensureContains(t, debugCopyCmds, "cp -f "+mylib2.OutputFile().String()+" ")
+	ensureNotContains(t, debugCopyCmds, mylib2.UnstrippedOutputFile().String())
+	ensureContains(t, debugApexRule.Args["image_dir"], "debug_image.apex")
+
+	signedDebugApex := module.Output("myapex-debug.apex")
+	ensureEquals(t, signedDebugApex.Input.String(), debugApexRule.Output.String())
+
+	outputs, err := module.Module().(*apexBundle).OutputFiles(".debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ensureEquals(t, outputs.Strings()[0], signedDebugApex.Output.String())
+}
+
+func TestApexDistBundle(t *testing.T) {
+	ctx, config := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			dists: [
+				{
+					targets: ["apps_only"],
+					tag: ".bundle",
+				},
+				{
+					targets: ["apps_only"],
+					tag: ".bundle",
+					dir: "bundles",
+					suffix: "-unsigned",
+				},
+			],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	apexBundle := module.Module().(*apexBundle)
+	bundle := module.Output("myapex-base.zip").Output.String()
+
+	data := android.AndroidMkDataForTest(t, config, "", apexBundle)
+	var builder strings.Builder
+	data.Custom(&builder, apexBundle.BaseModuleName(), "TARGET_", "", data)
+	androidMk := builder.String()
+	ensureContains(t, androidMk, "$(call dist-for-goals,apps_only,"+bundle+":myapex-base.zip)")
+	ensureContains(t, androidMk, "$(call dist-for-goals,apps_only,"+bundle+":bundles/myapex-base-unsigned.zip)")
+}
+
+func TestApexPayloadZips(t *testing.T) {
+	ctx, config := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			payload_zips: true,
+			dists: [
+				{
+					targets: ["symbols"],
+					tag: ".payload",
+				},
+				{
+					targets: ["symbols"],
+					tag: ".symbols",
+				},
+			],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	apexBundle := module.Module().(*apexBundle)
+
+	payloadZip := module.Output("myapex-payload.zip")
+	ensureContains(t, payloadZip.RuleParams.Command, "-C "+buildDir+"/.intermediates/myapex/android_common_myapex_image/image.apex")
+	ensureListContains(t, payloadZip.Implicits.Strings(), module.Output("myapex.apex.unsigned").Output.String())
+
+	mylib := ctx.ModuleForTests("mylib", "android_arm64_armv8-a_shared_apex10000").Module().(*cc.Module)
+	symbolsZip := module.Output("myapex-symbols.zip")
+	ensureContains(t, symbolsZip.RuleParams.Command, "-e lib64/mylib.so -f "+mylib.UnstrippedOutputFile().String())
+
+	data := android.AndroidMkDataForTest(t, config, "", apexBundle)
+	var builder strings.Builder
+	data.Custom(&builder, apexBundle.BaseModuleName(), "TARGET_", "", data)
+	androidMk := builder.String()
+	ensureContains(t, androidMk, "$(call dist-for-goals,symbols,"+payloadZip.Output.String()+":myapex-payload.zip)")
+	ensureContains(t, androidMk, "$(call dist-for-goals,symbols,"+symbolsZip.Output.String()+":myapex-symbols.zip)")
+}
+
+func TestApexPayloadZipsNotBuiltByDefault(t *testing.T) {
+	testApexError(t, `"\.payload" was requested, but payload_zips is not set`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			dist: {
+				targets: ["symbols"],
+				tag: ".payload",
+			},
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+	`)
+}
+
+func TestDebugApexKeepSymbolsErrors(t *testing.T) {
+	testApexError(t, `debug_apex_keep_symbols: requires debug_apex to be true`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			debug_apex_keep_symbols: ["mylib"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+	`)
+
+	testApexError(t, `debug_apex_keep_symbols: "libfoo" is not a native library or executable in this APEX`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			debug_apex: true,
+			debug_apex_keep_symbols: ["libfoo"],
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["myapex"],
+		}
+	`)
+}
+
 func TestAllowedFiles(t *testing.T) {
 	ctx, _ := testApex(t, `
 		apex {
@@ -6348,6 +7832,7 @@ func TestNonPreferredPrebuiltDependency(t *testing.T) {
 			name: "mylib",
 			srcs: ["mylib.cpp"],
 			stubs: {
+				symbol_file: "mylib.map.txt",
 				versions: ["current"],
 			},
 			apex_available: ["myapex"],
@@ -6358,6 +7843,7 @@ func TestNonPreferredPrebuiltDependency(t *testing.T) {
 			prefer: false,
 			srcs: ["prebuilt.so"],
 			stubs: {
+				symbol_file: "mylib.map.txt",
 				versions: ["current"],
 			},
 			apex_available: ["myapex"],
@@ -6425,6 +7911,7 @@ func TestPreferredPrebuiltSharedLibDep(t *testing.T) {
 			name: "otherlib",
 			srcs: ["mylib.cpp"],
 			stubs: {
+				symbol_file: "otherlib.map.txt",
 				versions: ["current"],
 			},
 		}
@@ -6434,6 +7921,7 @@ func TestPreferredPrebuiltSharedLibDep(t *testing.T) {
 			prefer: true,
 			srcs: ["prebuilt.so"],
 			stubs: {
+				symbol_file: "otherlib.map.txt",
 				versions: ["current"],
 			},
 		}
@@ -6534,6 +8022,7 @@ func TestPrebuiltStubLibDep(t *testing.T) {
 			system_shared_libs: [],
 			stl: "none",
 			stubs: {
+				symbol_file: "stublib.map.txt",
 				versions: ["1"],
 			},
 		}
@@ -6545,6 +8034,7 @@ func TestPrebuiltStubLibDep(t *testing.T) {
 			srcs: ["prebuilt.so"],
 			apex_available: ["otherapex"],
 			stubs: {
+				symbol_file: "stublib.map.txt",
 				versions: ["1"],
 			},
 			%s
@@ -162,11 +162,11 @@ var (
 	}, "abi", "config")
 
 	emitApexContentRule = pctx.StaticRule("emitApexContentRule", blueprint.RuleParams{
-		Command:        `rm -f ${out} && touch ${out} && (. ${out}.emit_commands)`,
-		Rspfile:        "${out}.emit_commands",
-		RspfileContent: "${emit_commands}",
+		Command:        `tr ' ' '\n' < ${out}.rsp | sed 's:^:./:' | sort > ${out}`,
+		Rspfile:        "${out}.rsp",
+		RspfileContent: "${paths}",
 		Description:    "Emit APEX image content",
-	}, "emit_commands")
+	}, "paths")
 
 	diffApexContentRule = pctx.StaticRule("diffApexContentRule", blueprint.RuleParams{
 		Command: `diff --unchanged-group-format='' \` +
@@ -217,11 +217,17 @@ func (a *apexBundle) buildManifest(ctx android.ModuleContext, provideNativeLibs,
 		optCommands = append(optCommands, "-a jniLibs "+strings.Join(jniLibs, " "))
 	}
 
+	preprocessorArgs, preprocessorDeps := android.ExpandLocations(ctx, "manifest_preprocessor_args",
+		a.properties.Manifest_preprocessor_args, a.properties.Manifest_preprocessor_files)
+	optCommands = append(optCommands, preprocessorArgs...)
+
 	manifestJsonFullOut := android.PathForModuleOut(ctx, "apex_manifest_full.json")
+	a.manifestJsonFullOut = manifestJsonFullOut
 	ctx.Build(pctx, android.BuildParams{
-		Rule:   apexManifestRule,
-		Input:  src,
-		Output: manifestJsonFullOut,
+		Rule:      apexManifestRule,
+		Input:     src,
+		Implicits: preprocessorDeps,
+		Output:    manifestJsonFullOut,
 		Args: map[string]string{
 			"provideNativeLibs": strings.Join(provideNativeLibs, " "),
 			"requireNativeLibs": strings.Join(requireNativeLibs, " "),
@@ -233,21 +239,50 @@ func (a *apexBundle) buildManifest(ctx android.ModuleContext, provideNativeLibs,
 	// stripped-down version so that APEX modules built from R+ can be installed to Q
 	minSdkVersion := a.minSdkVersion(ctx)
 	if minSdkVersion.EqualTo(android.SdkVersion_Android10) {
-		a.manifestJsonOut = android.PathForModuleOut(ctx, "apex_manifest.json")
+		manifestJsonOut := android.PathForModuleOut(ctx, "apex_manifest.json")
 		ctx.Build(pctx, android.BuildParams{
 			Rule:   stripApexManifestRule,
 			Input:  manifestJsonFullOut,
-			Output: a.manifestJsonOut,
+			Output: manifestJsonOut,
 		})
+		a.manifestJsonOut = manifestJsonOut
 	}
 
 	// From R+, protobuf binary format (.pb) is the standard format for apex_manifest
-	a.manifestPbOut = android.PathForModuleOut(ctx, "apex_manifest.pb")
+	manifestPbOut := android.PathForModuleOut(ctx, "apex_manifest.pb")
 	ctx.Build(pctx, android.BuildParams{
 		Rule:   pbApexManifestRule,
 		Input:  manifestJsonFullOut,
-		Output: a.manifestPbOut,
+		Output: manifestPbOut,
 	})
+	a.manifestPbOut = manifestPbOut
+
+	// Negative tests can replace the manifests through test_only_payload_files.
+	if a.testOnlyManifestJson != nil {
+		a.manifestJsonOut = a.testOnlyManifestJson
+	}
+	if a.testOnlyManifestPb != nil {
+		a.manifestPbOut = a.testOnlyManifestPb
+	}
+}
+
+// useStubFileContexts returns true when the default file_contexts of an APEX doesn't exist and
+// the build can't be expected to provide it, i.e. when system/sepolicy isn't in the checkout at
+// all. Any other build still requires the real file, even when missing dependencies are allowed.
+func useStubFileContexts(ctx android.ModuleContext, defaultFileContexts android.Path) bool {
+	if android.ExistentPathForSource(ctx, defaultFileContexts.String()).Valid() {
+		return false
+	}
+	return !android.ExistentPathForSource(ctx, "system/sepolicy").Valid()
+}
+
+// buildStubFileContexts writes a minimal file_contexts that labels every file in the APEX as
+// system_file. It stands in for system/sepolicy/apex/<apexname>-file_contexts in unit tests and
+// in checkouts without system/sepolicy.
+func (a *apexBundle) buildStubFileContexts(ctx android.ModuleContext) android.Path {
+	output := android.PathForModuleOut(ctx, "file_contexts.stub")
+	android.WriteFileRule(ctx, output, "(/.*)?    u:object_r:system_file:s0")
+	return output
 }
 
 // buildFileContexts create build rules to append an entry for apex_manifest.pb to the file_contexts
@@ -256,18 +291,25 @@ func (a *apexBundle) buildManifest(ctx android.ModuleContext, provideNativeLibs,
 // labeled as system_file.
 func (a *apexBundle) buildFileContexts(ctx android.ModuleContext) android.OutputPath {
 	var fileContexts android.Path
+	stub := false
 	if a.properties.File_contexts == nil {
 		fileContexts = android.PathForSource(ctx, "system/sepolicy/apex", ctx.ModuleName()+"-file_contexts")
+		if useStubFileContexts(ctx, fileContexts) {
+			fileContexts = a.buildStubFileContexts(ctx)
+			stub = true
+		}
 	} else {
 		fileContexts = android.PathForModuleSrc(ctx, *a.properties.File_contexts)
 	}
-	if a.Platform() {
-		if matched, err := path.Match("system/sepolicy/**/*", fileContexts.String()); err != nil || !matched {
-			ctx.PropertyErrorf("file_contexts", "should be under system/sepolicy, but %q", fileContexts)
+	if !stub {
+		if a.Platform() {
+			if matched, err := path.Match("system/sepolicy/**/*", fileContexts.String()); err != nil || !matched {
+				ctx.PropertyErrorf("file_contexts", "should be under system/sepolicy, but %q", fileContexts)
+			}
+		}
+		if !android.ExistentPathForSource(ctx, fileContexts.String()).Valid() {
+			ctx.PropertyErrorf("file_contexts", "cannot find file_contexts file: %q", fileContexts.String())
 		}
-	}
-	if !android.ExistentPathForSource(ctx, fileContexts.String()).Valid() {
-		ctx.PropertyErrorf("file_contexts", "cannot find file_contexts file: %q", fileContexts.String())
 	}
 
 	output := android.PathForModuleOut(ctx, "file_contexts")
@@ -349,6 +391,47 @@ func (a *apexBundle) buildInstalledFilesFile(ctx android.ModuleContext, builtApe
 	return output.OutputPath
 }
 
+// buildElfPayloadCheck creates a build rule that verifies that the native executables and
+// libraries in the payload use one of the device's dynamic linkers as their interpreter, including
+// the bootstrap and ASan linkers, and don't have absolute RPATH or RUNPATH entries outside of /apex
+// and /system.  It returns the timestamp file written by the check, or nil if the APEX has no
+// native files.
+func (a *apexBundle) buildElfPayloadCheck(ctx android.ModuleContext) android.Path {
+	if ctx.Host() {
+		return nil
+	}
+
+	var lines []string
+	var inputs android.Paths
+	for _, fi := range a.filesInfo {
+		switch fi.class {
+		case nativeExecutable, nativeSharedLib, nativeTest:
+			lines = append(lines, fi.path()+" "+fi.builtFile.String())
+			inputs = append(inputs, fi.builtFile)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	sort.Strings(lines)
+
+	fileList := android.PathForModuleOut(ctx, "elf_payload_files.txt")
+	android.WriteFileRule(ctx, fileList, strings.Join(lines, "\n"))
+
+	timestamp := android.PathForModuleOut(ctx, "elf_payload_check.timestamp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("check_elf_payload").
+		FlagWithInput("-l ", fileList).
+		FlagWithOutput("-o ", timestamp).
+		Implicits(inputs)
+	rule.Build("elf_payload_check", "Check ELF payload of "+a.Name())
+	return timestamp
+}
+
+// The split dimensions of bundletool that can be set in bundle_config.split_dimensions.
+var bundleSplitDimensions = []string{"abi", "screen_density", "language", "texture_compression_format", "device_tier"}
+
 // buildBundleConfig creates a build rule for the bundle config file that will control the bundle
 // creation process.
 func (a *apexBundle) buildBundleConfig(ctx android.ModuleContext) android.OutputPath {
@@ -358,8 +441,17 @@ func (a *apexBundle) buildBundleConfig(ctx android.ModuleContext) android.Output
 		Package_name string `json:"package_name"`
 		Apk_path     string `json:"path"`
 	}
+	type SplitDimension struct {
+		Value string `json:"value"`
+	}
+	type Optimizations struct {
+		Splits_config struct {
+			Split_dimension []SplitDimension `json:"split_dimension"`
+		} `json:"splits_config"`
+	}
 	config := struct {
-		Compression struct {
+		Optimizations *Optimizations `json:"optimizations,omitempty"`
+		Compression   struct {
 			Uncompressed_glob []string `json:"uncompressed_glob"`
 		} `json:"compression"`
 		Apex_config struct {
@@ -371,6 +463,22 @@ func (a *apexBundle) buildBundleConfig(ctx android.ModuleContext) android.Output
 		"apex_payload.img",
 		"apex_manifest.*",
 	}
+	config.Compression.Uncompressed_glob = append(config.Compression.Uncompressed_glob,
+		a.properties.Bundle_config.Uncompressed_globs...)
+
+	if dimensions := a.properties.Bundle_config.Split_dimensions; len(dimensions) > 0 {
+		config.Optimizations = &Optimizations{}
+		for _, dimension := range dimensions {
+			if !android.InList(dimension, bundleSplitDimensions) {
+				ctx.PropertyErrorf("bundle_config.split_dimensions", "unknown split dimension %q, must be one of %q",
+					dimension, bundleSplitDimensions)
+				continue
+			}
+			config.Optimizations.Splits_config.Split_dimension = append(
+				config.Optimizations.Splits_config.Split_dimension,
+				SplitDimension{Value: strings.ToUpper(dimension)})
+		}
+	}
 
 	// Collect the manifest names and paths of android apps if their manifest names are
 	// overridden.
@@ -399,28 +507,69 @@ func (a *apexBundle) buildBundleConfig(ctx android.ModuleContext) android.Output
 	return output.OutputPath
 }
 
-// buildUnflattendApex creates build rules to build an APEX using apexer.
-func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
-	apexType := a.properties.ApexType
-	suffix := apexType.suffix()
+// createdDirs is the set of directories created by the commands that copy the payload files of
+// an APEX.
+type createdDirs map[string]bool
 
-	////////////////////////////////////////////////////////////////////////////////////////////
-	// Step 1: copy built files to appropriate directories under the image directory
+// add marks dir as created, and returns true if it wasn't already.
+func (c createdDirs) add(dir string) bool {
+	if c[dir] {
+		return false
+	}
+	c[dir] = true
+	return true
+}
 
-	imageDir := android.PathForModuleOut(ctx, "image"+suffix)
+// remove marks dir and all of its subdirectories as no longer created.
+func (c createdDirs) remove(dir string) {
+	for d := range c {
+		if d == dir || strings.HasPrefix(d, dir+"/") {
+			delete(c, d)
+		}
+	}
+}
 
+// groupInputs returns a phony target depending on inputs when there are enough of them, so that the
+// build statements that depend on all of them don't each list them in the ninja file.
+func groupInputs(ctx android.ModuleContext, name string, inputs android.Paths) android.Paths {
+	// As for genrule outputs, a handful of inputs is cheaper to list than to group.
+	if len(inputs) <= 6 {
+		return inputs
+	}
+	phony := android.PathForModuleOut(ctx, name+"-phony")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   blueprint.Phony,
+		Output: phony,
+		Inputs: inputs,
+	})
+	return android.Paths{phony}
+}
+
+// buildCopyCommands returns the commands that copy the payload files of this APEX into imageDir,
+// along with the files they read. When debug is true, native files listed in
+// debug_apex_keep_symbols are copied unstripped.
+func (a *apexBundle) buildCopyCommands(ctx android.ModuleContext, imageDir android.ModuleOutPath,
+	debug bool) (copyCommands []string, implicitInputs android.Paths) {
 	// TODO(jiyong): use the RuleBuilder
-	var copyCommands []string
-	var implicitInputs []android.Path
+	// Each directory only needs to be created once. This keeps the commands, which are stored in
+	// the ninja file, short for APEXes with many files in the same directory.
+	createdDirs := createdDirs{imageDir.String(): true}
 	for _, fi := range a.filesInfo {
 		destPath := imageDir.Join(ctx, fi.path()).String()
+		builtFile := fi.builtFile
+		if debug && a.keepSymbolsInDebugApex(ctx, fi) {
+			builtFile = fi.unstrippedBuiltFile
+		}
 
 		// Prepare the destination path
 		destPathDir := filepath.Dir(destPath)
 		if fi.class == appSet {
 			copyCommands = append(copyCommands, "rm -rf "+destPathDir)
+			createdDirs.remove(destPathDir)
+		}
+		if createdDirs.add(destPathDir) {
+			copyCommands = append(copyCommands, "mkdir -p "+destPathDir)
 		}
-		copyCommands = append(copyCommands, "mkdir -p "+destPathDir)
 
 		// Copy the built file to the directory. But if the symlink optimization is turned
 		// on, place a symlink to the corresponding file in /system partition instead.
@@ -431,11 +580,11 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		} else {
 			if fi.class == appSet {
 				copyCommands = append(copyCommands,
-					fmt.Sprintf("unzip -qDD -d %s %s", destPathDir, fi.builtFile.String()))
+					fmt.Sprintf("unzip -qDD -d %s %s", destPathDir, builtFile.String()))
 			} else {
-				copyCommands = append(copyCommands, "cp -f "+fi.builtFile.String()+" "+destPath)
+				copyCommands = append(copyCommands, "cp -f "+builtFile.String()+" "+destPath)
 			}
-			implicitInputs = append(implicitInputs, fi.builtFile)
+			implicitInputs = append(implicitInputs, builtFile)
 		}
 
 		// Create additional symlinks pointing the file inside the APEX (if any). Note that
@@ -460,6 +609,66 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 			implicitInputs = append(implicitInputs, d.SrcPath)
 		}
 	}
+	return copyCommands, implicitInputs
+}
+
+// buildPayloadZips creates the rules to zip the payload files of the APEX and the unstripped
+// versions of its native files, both laid out as in the APEX, for symbolication pipelines.
+func (a *apexBundle) buildPayloadZips(ctx android.ModuleContext, imageDir android.ModuleOutPath,
+	unsignedOutputFile android.Path) {
+	a.payloadZipFile = android.PathForModuleOut(ctx, a.Name()+"-payload.zip")
+	// The image directory is filled by the rule that builds the unsigned APEX.
+	payloadRule := android.NewRuleBuilder(pctx, ctx)
+	payloadRule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", a.payloadZipFile).
+		FlagWithArg("-C ", imageDir.String()).
+		FlagWithArg("-D ", imageDir.String()).
+		Implicit(unsignedOutputFile)
+	payloadRule.Build("apexPayloadZip", "apex payload zip")
+
+	a.symbolsZipFile = android.PathForModuleOut(ctx, a.Name()+"-symbols.zip")
+	symbolsRule := android.NewRuleBuilder(pctx, ctx)
+	cmd := symbolsRule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", a.symbolsZipFile)
+	for _, fi := range a.filesInfo {
+		if fi.unstrippedBuiltFile != nil {
+			cmd.FlagWithArg("-e ", fi.path()).FlagWithInput("-f ", fi.unstrippedBuiltFile)
+		}
+	}
+	symbolsRule.Build("apexSymbolsZip", "apex symbols zip")
+}
+
+// keepSymbolsInDebugApex returns true if the given payload file is packaged with its full symbols
+// in the debug APEX.
+func (a *apexBundle) keepSymbolsInDebugApex(ctx android.ModuleContext, fi apexFile) bool {
+	if fi.unstrippedBuiltFile == nil {
+		return false
+	}
+	keepSymbols := a.properties.Debug_apex_keep_symbols
+	if len(keepSymbols) == 0 {
+		return true
+	}
+	return android.InList(android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(fi.module)), keepSymbols)
+}
+
+// buildUnflattendApex creates build rules to build an APEX using apexer.
+func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
+	apexType := a.properties.ApexType
+	suffix := apexType.suffix()
+
+	if len(a.properties.Debug_apex_keep_symbols) > 0 && !proptools.Bool(a.properties.Debug_apex) {
+		ctx.PropertyErrorf("debug_apex_keep_symbols", "requires debug_apex to be true")
+	}
+
+	////////////////////////////////////////////////////////////////////////////////////////////
+	// Step 1: copy built files to appropriate directories under the image directory
+
+	imageDir := android.PathForModuleOut(ctx, "image"+suffix)
+	copyCommands, payloadInputs := a.buildCopyCommands(ctx, imageDir, false)
+	// The payload files are inputs of several of the build statements below.
+	implicitInputs := groupInputs(ctx, "payload"+suffix, payloadInputs)
 	implicitInputs = append(implicitInputs, a.manifestPbOut)
 
 	////////////////////////////////////////////////////////////////////////////////////////////
@@ -474,24 +683,21 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 	// TODO(jiyong): use RuleBuilder
 	if a.overridableProperties.Allowed_files != nil {
 		// Build content.txt
-		var emitCommands []string
 		imageContentFile := android.PathForModuleOut(ctx, "content.txt")
-		emitCommands = append(emitCommands, "echo ./apex_manifest.pb >> "+imageContentFile.String())
-		minSdkVersion := a.minSdkVersion(ctx)
-		if minSdkVersion.EqualTo(android.SdkVersion_Android10) {
-			emitCommands = append(emitCommands, "echo ./apex_manifest.json >> "+imageContentFile.String())
+		contentPaths := []string{"apex_manifest.pb"}
+		if a.manifestJsonOut != nil {
+			contentPaths = append(contentPaths, "apex_manifest.json")
 		}
 		for _, fi := range a.filesInfo {
-			emitCommands = append(emitCommands, "echo './"+fi.path()+"' >> "+imageContentFile.String())
+			contentPaths = append(contentPaths, fi.path())
 		}
-		emitCommands = append(emitCommands, "sort -o "+imageContentFile.String()+" "+imageContentFile.String())
 		ctx.Build(pctx, android.BuildParams{
 			Rule:        emitApexContentRule,
 			Implicits:   implicitInputs,
 			Output:      imageContentFile,
 			Description: "emit apex image content",
 			Args: map[string]string{
-				"emit_commands": strings.Join(emitCommands, " && "),
+				"paths": strings.Join(contentPaths, " "),
 			},
 		})
 		implicitInputs = append(implicitInputs, imageContentFile)
@@ -514,6 +720,7 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 	}
 
 	unsignedOutputFile := android.PathForModuleOut(ctx, a.Name()+suffix+".unsigned")
+	var unsignedDebugOutputFile android.WritablePath
 	outHostBinDir := android.PathForOutput(ctx, "host", ctx.Config().PrebuiltOS(), "bin").String()
 	prebuiltSdkToolsBinDir := filepath.Join("prebuilts", "sdk", "tools", runtime.GOOS, "bin")
 
@@ -640,6 +847,11 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 
 		if a.testOnlyShouldSkipPayloadSign() {
 			optFlags = append(optFlags, "--unsigned_payload")
+		} else {
+			// apexer signs the payload with avbtool itself, pin the salt the same way as the
+			// other signed images.
+			signingArgs := android.AvbSigningArgs(ctx, android.AvbFooterParams{PartitionName: a.Name()})
+			optFlags = append(optFlags, "--signing_args "+proptools.ShellEscape(strings.Join(signingArgs, " ")))
 		}
 
 		if a.properties.Apex_name != nil {
@@ -648,30 +860,91 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 			optFlags = append(optFlags, "--do_not_check_keyname")
 		}
 
-		if moduleMinSdkVersion == android.SdkVersion_Android10 {
+		if a.manifestJsonOut != nil {
 			implicitInputs = append(implicitInputs, a.manifestJsonOut)
 			optFlags = append(optFlags, "--manifest_json "+a.manifestJsonOut.String())
 		}
 
 		optFlags = append(optFlags, "--payload_fs_type "+a.payloadFsType.string())
 
+		apexArgs := map[string]string{
+			"tool_path":        outHostBinDir + ":" + prebuiltSdkToolsBinDir,
+			"image_dir":        imageDir.String(),
+			"copy_commands":    strings.Join(copyCommands, " && "),
+			"manifest":         a.manifestPbOut.String(),
+			"file_contexts":    fileContexts.String(),
+			"canned_fs_config": cannedFsConfig.String(),
+			"key":              a.privateKeyFile.String(),
+			"opt_flags":        strings.Join(optFlags, " "),
+		}
+		var validations android.Paths
+		if a.keyPairCheck != nil {
+			validations = append(validations, a.keyPairCheck)
+		}
+		if a.properties.Previous_apex != nil && a.publicKeyFile != nil {
+			previousApex := android.PathForModuleSrc(ctx, *a.properties.Previous_apex)
+			validations = append(validations,
+				buildPublicKeyCheck(ctx, "previous_apex", previousApex, a.publicKeyFile, String(a.properties.Key)))
+		}
+		if elfPayloadCheck := a.buildElfPayloadCheck(ctx); elfPayloadCheck != nil {
+			validations = append(validations, elfPayloadCheck)
+		}
 		ctx.Build(pctx, android.BuildParams{
 			Rule:        apexRule,
 			Implicits:   implicitInputs,
+			Validations: validations,
 			Output:      unsignedOutputFile,
 			Description: "apex (" + apexType.name() + ")",
-			Args: map[string]string{
-				"tool_path":        outHostBinDir + ":" + prebuiltSdkToolsBinDir,
-				"image_dir":        imageDir.String(),
-				"copy_commands":    strings.Join(copyCommands, " && "),
-				"manifest":         a.manifestPbOut.String(),
-				"file_contexts":    fileContexts.String(),
-				"canned_fs_config": cannedFsConfig.String(),
-				"key":              a.privateKeyFile.String(),
-				"opt_flags":        strings.Join(optFlags, " "),
-			},
+			Args:        apexArgs,
 		})
 
+		////////////////////////////////////////////////////////////////////////////////////
+		// Step 3.a: Create the unsigned debug APEX, which has the same payload except that
+		// the native files keep their full symbols.
+		if proptools.Bool(a.properties.Debug_apex) {
+			for _, name := range a.properties.Debug_apex_keep_symbols {
+				found := false
+				for _, fi := range a.filesInfo {
+					if fi.unstrippedBuiltFile != nil &&
+						android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(fi.module)) == name {
+						found = true
+						break
+					}
+				}
+				if !found {
+					ctx.PropertyErrorf("debug_apex_keep_symbols", "%q is not a native library or executable in this APEX", name)
+				}
+			}
+
+			debugImageDir := android.PathForModuleOut(ctx, "debug_image"+suffix)
+			debugCopyCommands, debugPayloadInputs := a.buildCopyCommands(ctx, debugImageDir, true)
+			// Only the files kept with their symbols differ from the payload of the APEX.
+			var debugInputs android.Paths
+			payloadInputStrings := payloadInputs.Strings()
+			for _, input := range debugPayloadInputs {
+				if !android.InList(input.String(), payloadInputStrings) {
+					debugInputs = append(debugInputs, input)
+				}
+			}
+
+			debugArgs := make(map[string]string, len(apexArgs))
+			for k, v := range apexArgs {
+				debugArgs[k] = v
+			}
+			debugArgs["image_dir"] = debugImageDir.String()
+			debugArgs["copy_commands"] = strings.Join(debugCopyCommands, " && ")
+
+			unsignedDebugOutputFile = android.PathForModuleOut(ctx, a.Name()+"-debug"+suffix+".unsigned")
+			ctx.Build(pctx, android.BuildParams{
+				Rule:        apexRule,
+				Implicits:   append(debugInputs, implicitInputs...),
+				Validations: validations,
+				Output:      unsignedDebugOutputFile,
+				Description: "apex (" + apexType.name() + ", debug)",
+				Args:        debugArgs,
+			})
+		}
+
 		// TODO(jiyong): make the two rules below as separate functions
 		apexProtoFile := android.PathForModuleOut(ctx, a.Name()+".pb"+suffix)
 		bundleModuleFile := android.PathForModuleOut(ctx, a.Name()+suffix+"-base.zip")
@@ -737,6 +1010,10 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		})
 	}
 
+	if proptools.Bool(a.properties.Payload_zips) {
+		a.buildPayloadZips(ctx, imageDir, unsignedOutputFile)
+	}
+
 	////////////////////////////////////////////////////////////////////////////////////
 	// Step 4: Sign the APEX using signapk
 	signedOutputFile := android.PathForModuleOut(ctx, a.Name()+suffix)
@@ -763,6 +1040,27 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 	})
 	a.outputFile = signedOutputFile
 
+	if unsignedDebugOutputFile != nil {
+		signedDebugOutputFile := android.PathForModuleOut(ctx, a.Name()+"-debug"+suffix)
+		debugArgs := make(map[string]string, len(args))
+		for k, v := range args {
+			debugArgs[k] = v
+		}
+		if _, ok := debugArgs["outCommaList"]; ok {
+			debugArgs["outCommaList"] = signedDebugOutputFile.String()
+		}
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        rule,
+			Description: "signapk debug apex",
+			Output:      signedDebugOutputFile,
+			Input:       unsignedDebugOutputFile,
+			Implicits:   implicits,
+			Args:        debugArgs,
+		})
+		a.debugOutputFile = signedDebugOutputFile
+		ctx.CheckbuildFile(signedDebugOutputFile)
+	}
+
 	// Process APEX compression if enabled
 	compressionEnabled := ctx.Config().CompressedApex() && proptools.BoolDefault(a.properties.Compressible, true)
 	if compressionEnabled && apexType == imageApex {
@@ -795,7 +1093,10 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 
 	// Install to $OUT/soong/{target,host}/.../apex
 	if a.installable() {
-		ctx.InstallFile(a.installDir, a.Name()+suffix, a.outputFile)
+		installedFile := ctx.InstallFile(a.installDir, a.Name()+suffix, a.outputFile)
+		if apexType == imageApex {
+			a.onDevicePath = android.InstallPathToOnDevicePath(ctx, installedFile)
+		}
 	}
 
 	// installed-files.txt is dist'ed
@@ -954,3 +1255,14 @@ func (a *apexBundle) buildLintReports(ctx android.ModuleContext) {
 
 	a.lintReports = java.BuildModuleLintReportZips(ctx, depSetsBuilder.Build())
 }
+
+// buildLicenseMetadata writes the licenses of the apex and of the modules in it.
+func (a *apexBundle) buildLicenseMetadata(ctx android.ModuleContext) {
+	var contents []android.Module
+	for _, fi := range a.filesInfo {
+		if fi.module != nil {
+			contents = append(contents, fi.module)
+		}
+	}
+	a.licenseMetadataFile = android.BuildLicenseMetadata(ctx, contents)
+}
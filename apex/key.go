@@ -21,6 +21,7 @@ import (
 
 	"android/soong/android"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -31,6 +32,29 @@ func init() {
 	android.RegisterSingletonType("apex_keys_text", apexKeysTextFactory)
 }
 
+var (
+	// Checks that the public key in avbpubkey format is the one derived from the private key, so
+	// that a mismatched key pair is reported before the APEX fails to verify on the device.
+	checkApexKeyPair = pctx.StaticRule("checkApexKeyPair", blueprint.RuleParams{
+		Command: `rm -f $out && ${avbtool} extract_public_key --key $privateKey --output $out.tmp && ` +
+			`if ! cmp -s $out.tmp $publicKey; then ` +
+			`echo "error: public key $publicKey does not match private key $privateKey of $keyName" && ` +
+			`rm -f $out.tmp && exit 1; fi && mv $out.tmp $out`,
+		CommandDeps: []string{"${avbtool}"},
+		Description: "check apex key pair $keyName",
+	}, "keyName", "privateKey", "publicKey")
+
+	// Checks that the avb public key embedded in an APEX, the apex_pubkey entry that apexer adds
+	// to it, is the expected one, so that a key rotation mistake is reported before OTA testing.
+	checkApexPublicKey = pctx.StaticRule("checkApexPublicKey", blueprint.RuleParams{
+		Command: `rm -f $out && unzip -p $in apex_pubkey > $out.tmp && ` +
+			`if ! cmp -s $out.tmp $publicKey; then ` +
+			`echo "error: $in is not signed with the public key $publicKey of $keyName" && ` +
+			`rm -f $out.tmp && exit 1; fi && mv $out.tmp $out`,
+		Description: "check apex public key $in",
+	}, "keyName", "publicKey")
+)
+
 type apexKey struct {
 	android.ModuleBase
 
@@ -39,6 +63,9 @@ type apexKey struct {
 	publicKeyFile  android.Path
 	privateKeyFile android.Path
 
+	// Timestamp of the check that publicKeyFile matches privateKeyFile.
+	keyPairCheck android.Path
+
 	keyName string
 }
 
@@ -96,6 +123,39 @@ func (m *apexKey) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		return
 	}
 	m.keyName = pubKeyName
+
+	if m.properties.Public_key != nil && m.properties.Private_key != nil {
+		keyPairCheck := android.PathForModuleOut(ctx, "key_pair_check.timestamp")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:      checkApexKeyPair,
+			Output:    keyPairCheck,
+			Implicits: android.Paths{m.privateKeyFile, m.publicKeyFile},
+			Args: map[string]string{
+				"keyName":    ctx.ModuleName(),
+				"privateKey": m.privateKeyFile.String(),
+				"publicKey":  m.publicKeyFile.String(),
+			},
+		})
+		m.keyPairCheck = keyPairCheck
+	}
+}
+
+// buildPublicKeyCheck registers the build statement checking that apexFile is signed with the
+// public key of the apex_key module keyName, and returns the timestamp of the check.
+func buildPublicKeyCheck(ctx android.ModuleContext, name string, apexFile, publicKey android.Path,
+	keyName string) android.Path {
+	check := android.PathForModuleOut(ctx, "public_key_check", name+".timestamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:     checkApexPublicKey,
+		Input:    apexFile,
+		Output:   check,
+		Implicit: publicKey,
+		Args: map[string]string{
+			"keyName":   keyName,
+			"publicKey": publicKey.String(),
+		},
+	})
+	return check
 }
 
 ////////////////////////////////////////////////////////////////////////
@@ -138,6 +138,10 @@ type PrebuiltProperties struct {
 	// binaries would be installed by default (in PRODUCT_PACKAGES) the other binary will be removed
 	// from PRODUCT_PACKAGES.
 	Overrides []string
+
+	// Name of the apex_key module whose public key the payload of the prebuilt is expected to be
+	// signed with. When set, the build fails if the prebuilt is signed with another key.
+	Key *string
 }
 
 func (a *Prebuilt) hasSanitizedSource(sanitizer string) bool {
@@ -200,21 +204,36 @@ func (p *Prebuilt) DepsMutator(ctx android.BottomUpMutatorContext) {
 		src = String(p.properties.Src)
 	}
 	p.properties.Source = src
+
+	if p.properties.Key != nil {
+		ctx.AddDependency(ctx.Module(), keyTag, String(p.properties.Key))
+	}
 }
 
 func (p *Prebuilt) GenerateAndroidBuildActions(ctx android.ModuleContext) {
-	// TODO(jungjw): Check the key validity.
 	p.inputApex = p.Prebuilt().SingleSourcePath(ctx)
 	p.installDir = android.PathForModuleInstall(ctx, "apex")
 	p.installFilename = p.InstallFilename()
 	if !strings.HasSuffix(p.installFilename, imageApexSuffix) {
 		ctx.ModuleErrorf("filename should end in %s for prebuilt_apex", imageApexSuffix)
 	}
+
+	var keyCheck android.Path
+	ctx.VisitDirectDepsWithTag(keyTag, func(dep android.Module) {
+		if key, ok := dep.(*apexKey); ok {
+			keyCheck = buildPublicKeyCheck(ctx, "src", p.inputApex, key.publicKeyFile, ctx.OtherModuleName(dep))
+		} else {
+			ctx.PropertyErrorf("key", "%q is not an apex_key module", ctx.OtherModuleName(dep))
+		}
+	})
+
+	// The installed copy of the prebuilt is validated by the key check.
 	p.outputApex = android.PathForModuleOut(ctx, p.installFilename)
 	ctx.Build(pctx, android.BuildParams{
-		Rule:   android.Cp,
-		Input:  p.inputApex,
-		Output: p.outputApex,
+		Rule:       android.Cp,
+		Input:      p.inputApex,
+		Output:     p.outputApex,
+		Validation: keyCheck,
 	})
 
 	if p.prebuiltCommon.checkForceDisable(ctx) {
@@ -223,7 +242,7 @@ func (p *Prebuilt) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	if p.installable() {
-		ctx.InstallFile(p.installDir, p.installFilename, p.inputApex)
+		ctx.InstallFile(p.installDir, p.installFilename, p.outputApex)
 	}
 
 	// in case that prebuilt_apex replaces source apex (using prefer: prop)
@@ -237,7 +256,7 @@ func (p *Prebuilt) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 func (p *Prebuilt) AndroidMkEntries() []android.AndroidMkEntries {
 	return []android.AndroidMkEntries{android.AndroidMkEntries{
 		Class:      "ETC",
-		OutputFile: android.OptionalPathForPath(p.inputApex),
+		OutputFile: android.OptionalPathForPath(p.outputApex),
 		Include:    "$(BUILD_PREBUILT)",
 		ExtraEntries: []android.AndroidMkExtraEntriesFunc{
 			func(entries *android.AndroidMkEntries) {
@@ -25,3 +25,14 @@ type Properties struct {
 	// this Soong module.
 	Bazel_module bazelModuleProperties
 }
+
+// Label is used to represent a Bazel compatible Label.
+type Label struct {
+	Label string
+}
+
+// LabelList is used to represent a list of Bazel labels.
+type LabelList struct {
+	Includes []Label
+	Excludes []Label
+}
@@ -220,7 +220,13 @@ func NewBinary(hod android.HostOrDeviceSupported) (*Module, *binaryDecorator) {
 func (binary *binaryDecorator) linkerInit(ctx BaseModuleContext) {
 	binary.baseLinker.linkerInit(ctx)
 
-	if !ctx.toolchain().Bionic() {
+	if ctx.toolchain().Bionic() {
+		// Host bionic binaries are linked against static bionic when building hermetic host tools.
+		if ctx.Os() == android.LinuxBionic && binary.Properties.Static_executable == nil &&
+			ctx.Config().HostHermeticBinaries() {
+			binary.Properties.Static_executable = BoolPtr(true)
+		}
+	} else {
 		if ctx.Os() == android.Linux {
 			// Unless explicitly specified otherwise, host static binaries are built with -static
 			// if HostStaticBinaries is true for the product configuration.
@@ -251,6 +257,7 @@ func (binary *binaryDecorator) binary() bool {
 // combined with the given flags.
 func (binary *binaryDecorator) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 	flags = binary.baseLinker.linkerFlags(ctx, flags)
+	binary.stripper.CheckProperties(ctx)
 
 	// Passing -pie to clang for Windows binaries causes a warning that -pie is unused.
 	if ctx.Host() && !ctx.Windows() && !binary.static() {
@@ -369,6 +376,7 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 	}
 
 	binary.unstrippedOutputFile = outputFile
+	binary.buildSizeReport(ctx, outputFile, fileName)
 
 	if String(binary.Properties.Prefix_symbols) != "" {
 		afterPrefixSymbols := outputFile
@@ -462,6 +470,10 @@ func (binary *binaryDecorator) unstrippedOutputFilePath() android.Path {
 	return binary.unstrippedOutputFile
 }
 
+func (binary *binaryDecorator) overrides() []string {
+	return binary.Properties.Overrides
+}
+
 func (binary *binaryDecorator) symlinkList() []string {
 	return binary.symlinks
 }
@@ -268,7 +268,7 @@ var (
 	sAbiDiff = pctx.RuleFunc("sAbiDiff",
 		func(ctx android.PackageRuleContext) blueprint.RuleParams {
 			commandStr := "($sAbiDiffer ${extraFlags} -lib ${libName} -arch ${arch} -o ${out} -new ${in} -old ${referenceDump})"
-			commandStr += "|| (echo 'error: Please update ABI references with: $$ANDROID_BUILD_TOP/development/vndk/tools/header-checker/utils/create_reference_dumps.py ${createReferenceDumpFlags} -l ${libName}'"
+			commandStr += "|| (echo 'error: Please update ABI references with: ${updateCommand}'"
 			commandStr += " && (mkdir -p $$DIST_DIR/abidiffs && cp ${out} $$DIST_DIR/abidiffs/)"
 			commandStr += " && exit 1)"
 			return blueprint.RuleParams{
@@ -276,7 +276,7 @@ var (
 				CommandDeps: []string{"$sAbiDiffer"},
 			}
 		},
-		"extraFlags", "referenceDump", "libName", "arch", "createReferenceDumpFlags")
+		"extraFlags", "referenceDump", "libName", "arch", "updateCommand")
 
 	// Rule to unzip a reference abi dump.
 	unzipRefSAbiDump = pctx.AndroidStaticRule("unzipRefSAbiDump",
@@ -368,11 +368,16 @@ type builderFlags struct {
 	rsFlags       string // Flags that apply to renderscript source files
 	toolchain     config.Toolchain
 
+	// Directories that the imports of aidl sources may resolve to, if aidlStrictImports is true.
+	aidlStrictImports    bool
+	aidlStrictImportDirs android.Paths
+
 	// True if these extra features are enabled.
 	tidy         bool
 	gcovCoverage bool
 	sAbiDump     bool
 	emitXrefs    bool
+	depsAudit    bool
 
 	assemblerWithCpp bool // True if .s files should be processed with the c preprocessor.
 
@@ -408,6 +413,12 @@ type Objects struct {
 	coverageFiles android.Paths
 	sAbiDumpFiles android.Paths
 	kytheFiles    android.Paths
+
+	// Lists of the headers included by each source, generated in deps audit mode.
+	depsAuditFiles android.Paths
+
+	// Static libraries passed in srcs, which are linked rather than compiled.
+	staticLibSrcs android.Paths
 }
 
 func (a Objects) Copy() Objects {
@@ -417,6 +428,9 @@ func (a Objects) Copy() Objects {
 		coverageFiles: append(android.Paths{}, a.coverageFiles...),
 		sAbiDumpFiles: append(android.Paths{}, a.sAbiDumpFiles...),
 		kytheFiles:    append(android.Paths{}, a.kytheFiles...),
+
+		depsAuditFiles: append(android.Paths{}, a.depsAuditFiles...),
+		staticLibSrcs:  append(android.Paths{}, a.staticLibSrcs...),
 	}
 }
 
@@ -427,6 +441,9 @@ func (a Objects) Append(b Objects) Objects {
 		coverageFiles: append(a.coverageFiles, b.coverageFiles...),
 		sAbiDumpFiles: append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
 		kytheFiles:    append(a.kytheFiles, b.kytheFiles...),
+
+		depsAuditFiles: append(a.depsAuditFiles, b.depsAuditFiles...),
+		staticLibSrcs:  append(a.staticLibSrcs, b.staticLibSrcs...),
 	}
 }
 
@@ -448,6 +465,10 @@ func transformSourceToObj(ctx android.ModuleContext, subdir string, srcFiles and
 	if flags.emitXrefs {
 		kytheFiles = make(android.Paths, 0, len(srcFiles))
 	}
+	var depsAuditFiles android.Paths
+	if flags.depsAudit {
+		depsAuditFiles = make(android.Paths, 0, len(srcFiles))
+	}
 
 	// Produce fully expanded flags for use by C tools, C compiles, C++ tools, C++ compiles, and asm compiles
 	// respectively.
@@ -547,6 +568,7 @@ func transformSourceToObj(ctx android.ModuleContext, subdir string, srcFiles and
 		dump := flags.sAbiDump
 		rule := cc
 		emitXref := flags.emitXrefs
+		depsAudit := flags.depsAudit
 
 		switch srcFile.Ext() {
 		case ".s":
@@ -561,6 +583,7 @@ func transformSourceToObj(ctx android.ModuleContext, subdir string, srcFiles and
 			coverage = false
 			dump = false
 			emitXref = false
+			depsAudit = false
 		case ".c":
 			ccCmd = "clang"
 			moduleFlags = cflags
@@ -619,6 +642,23 @@ func transformSourceToObj(ctx android.ModuleContext, subdir string, srcFiles and
 			kytheFiles = append(kytheFiles, kytheFile)
 		}
 
+		if depsAudit {
+			depsAuditFile := android.ObjPathWithExt(ctx, subdir, srcFile, "hdeps")
+			depsAuditFiles = append(depsAuditFiles, depsAuditFile)
+			ctx.Build(pctx, android.BuildParams{
+				Rule:        ccDepsAuditHeaders,
+				Description: "list headers " + srcFile.Rel(),
+				Output:      depsAuditFile,
+				Input:       srcFile,
+				Implicits:   cFlagsDeps,
+				OrderOnly:   pathDeps,
+				Args: map[string]string{
+					"cFlags": moduleFlags,
+					"ccCmd":  ccCmd,
+				},
+			})
+		}
+
 		if tidy {
 			tidyFile := android.ObjPathWithExt(ctx, subdir, srcFile, "tidy")
 			tidyFiles = append(tidyFiles, tidyFile)
@@ -676,6 +716,8 @@ func transformSourceToObj(ctx android.ModuleContext, subdir string, srcFiles and
 		coverageFiles: coverageFiles,
 		sAbiDumpFiles: sAbiDumpFiles,
 		kytheFiles:    kytheFiles,
+
+		depsAuditFiles: depsAuditFiles,
 	}
 }
 
@@ -863,9 +905,12 @@ func unzipRefDump(ctx android.ModuleContext, zippedRefDump android.Path, baseNam
 	return outputFile
 }
 
-// sourceAbiDiff registers a build statement to compare linked sAbi dump files (.ldump).
+// sourceAbiDiff registers a build statement to compare linked sAbi dump files (.ldump) against
+// referenceDump. The failure message tells to update the reference dump with updateCommand, or
+// with create_reference_dumps.py if updateCommand is empty.
 func sourceAbiDiff(ctx android.ModuleContext, inputDump android.Path, referenceDump android.Path,
-	baseName, exportedHeaderFlags string, checkAllApis, isLlndk, isNdk, isVndkExt bool) android.OptionalPath {
+	baseName, exportedHeaderFlags, updateCommand string, diffFlags []string,
+	checkAllApis, isLlndk, isNdk, isVndkExt bool) android.OptionalPath {
 
 	outputFile := android.PathForModuleOut(ctx, baseName+".abidiff")
 	libName := strings.TrimSuffix(baseName, filepath.Ext(baseName))
@@ -896,6 +941,12 @@ func sourceAbiDiff(ctx android.ModuleContext, inputDump android.Path, referenceD
 	if isVndkExt {
 		extraFlags = append(extraFlags, "-allow-extensions")
 	}
+	extraFlags = append(extraFlags, diffFlags...)
+
+	if updateCommand == "" {
+		updateCommand = "$$ANDROID_BUILD_TOP/development/vndk/tools/header-checker/utils/create_reference_dumps.py " +
+			createReferenceDumpFlags + " -l " + libName
+	}
 
 	ctx.Build(pctx, android.BuildParams{
 		Rule:        sAbiDiff,
@@ -904,11 +955,11 @@ func sourceAbiDiff(ctx android.ModuleContext, inputDump android.Path, referenceD
 		Input:       inputDump,
 		Implicit:    referenceDump,
 		Args: map[string]string{
-			"referenceDump":            referenceDump.String(),
-			"libName":                  libName,
-			"arch":                     ctx.Arch().ArchType.Name,
-			"extraFlags":               strings.Join(extraFlags, " "),
-			"createReferenceDumpFlags": createReferenceDumpFlags,
+			"referenceDump": referenceDump.String(),
+			"libName":       libName,
+			"arch":          ctx.Arch().ArchType.Name,
+			"extraFlags":    strings.Join(extraFlags, " "),
+			"updateCommand": updateCommand,
 		},
 	})
 	return android.OptionalPathForPath(outputFile)
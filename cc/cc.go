@@ -68,6 +68,7 @@ func RegisterCCBuildComponents(ctx android.RegistrationContext) {
 
 		// cfi mutator shouldn't run before sanitizers that return true for
 		// incompatibleWithCfi()
+		ctx.BottomUp("cfi_export_group", cfiExportGroupMutator).Parallel()
 		ctx.TopDown("cfi_deps", sanitizerDepsMutator(cfi))
 		ctx.BottomUp("cfi", sanitizerMutator(cfi)).Parallel()
 
@@ -220,6 +221,10 @@ type Flags struct {
 	TidyFlags     []string // Flags that apply to clang-tidy
 	SAbiFlags     []string // Flags that apply to header-abi-dumper
 
+	// Directories that the imports of aidl sources may resolve to, if aidlStrictImports is true.
+	aidlStrictImports    bool
+	aidlStrictImportDirs android.Paths
+
 	// Global include flags that apply to C, C++, and assembly source files
 	// These must be after any module include flags, which will be in CommonFlags.
 	SystemIncludeFlags []string
@@ -229,6 +234,7 @@ type Flags struct {
 	GcovCoverage bool // True if coverage files should be generated.
 	SAbiDump     bool // True if header abi dumps should be generated.
 	EmitXrefs    bool // If true, generate Ninja rules to generate emitXrefs input files for Kythe
+	DepsAudit    bool // If true, list the headers used by each source to audit declared dependencies.
 
 	// The instruction set required for clang ("arm" or "thumb").
 	RequiredInstructionSet string
@@ -320,6 +326,8 @@ type BaseProperties struct {
 
 	// Used by vendor snapshot to record dependencies from snapshot modules.
 	SnapshotSharedLibs  []string `blueprint:"mutated"`
+	SnapshotStaticLibs  []string `blueprint:"mutated"`
+	SnapshotHeaderLibs  []string `blueprint:"mutated"`
 	SnapshotRuntimeLibs []string `blueprint:"mutated"`
 
 	Installable *bool
@@ -503,6 +511,7 @@ type compiler interface {
 	appendCflags([]string)
 	appendAsflags([]string)
 	compile(ctx ModuleContext, flags Flags, deps PathDeps) Objects
+	linkStaticLibSrcs(ctx ModuleContext, deps PathDeps, staticLibSrcs android.Paths) PathDeps
 }
 
 // linker is the interface for a linker decorator object. Individual module types can provide
@@ -526,6 +535,13 @@ type linker interface {
 	linkerSpecifiedDeps(specifiedDeps specifiedDeps) specifiedDeps
 }
 
+// bazelHandler is implemented by linkers whose outputs can be built by Bazel in mixed builds.
+type bazelHandler interface {
+	// generateBazelBuildActions returns the output of the module built by Bazel, and false if
+	// the module is to be built by Soong instead.
+	generateBazelBuildActions(ctx ModuleContext, deps PathDeps) (android.Path, bool)
+}
+
 // specifiedDeps is a tuple struct representing dependencies of a linked binary owned by the linker.
 type specifiedDeps struct {
 	sharedLibs []string
@@ -708,6 +724,13 @@ func IsRuntimeDepTag(depTag blueprint.DependencyTag) bool {
 	return depTag == runtimeDepTag
 }
 
+// IsExcludedInApexDepTag returns true if the dependency isn't used by the APEX variants of the
+// module, e.g. because it is listed in target.apex.exclude_shared_libs.
+func IsExcludedInApexDepTag(depTag blueprint.DependencyTag) bool {
+	ccLibDepTag, ok := depTag.(libraryDependencyTag)
+	return ok && ccLibDepTag.excludeInApex
+}
+
 func IsTestPerSrcDepTag(depTag blueprint.DependencyTag) bool {
 	ccDepTag, ok := depTag.(dependencyTag)
 	return ok && ccDepTag == testPerSrcDepTag
@@ -779,6 +802,9 @@ type Module struct {
 	// Kythe (source file indexer) paths for this compilation module
 	kytheFiles android.Paths
 
+	// Report of the deps audit mode, see deps_audit.go.
+	depsAuditReport android.OptionalPath
+
 	// For apex variants, this is set as apex.min_sdk_version
 	apexSdkVersion android.ApiLevel
 
@@ -958,6 +984,18 @@ func (c *Module) UnstrippedOutputFile() android.Path {
 	return nil
 }
 
+// Overrides returns the names of the modules this module overrides.
+func (c *Module) Overrides() []string {
+	if overrides, ok := c.linker.(interface {
+		overrides() []string
+	}); ok {
+		return overrides.overrides()
+	}
+	return nil
+}
+
+var _ android.OverridingModule = (*Module)(nil)
+
 func (c *Module) CoverageOutputFile() android.OptionalPath {
 	if c.linker != nil {
 		return c.linker.coverageOutputFilePath()
@@ -1170,6 +1208,23 @@ func (c *Module) HasStubsVariants() bool {
 	return false
 }
 
+// ApexSafeDependencyError returns an error describing why native code in an APEX may not depend
+// on this library from outside of the APEX when the APEX enforces strict_updatability, or nil if
+// it can.  Libraries with stubs, LLNDK libraries and NDK libraries have stable interfaces and are
+// safe to depend on.
+func (c *Module) ApexSafeDependencyError(config android.Config) error {
+	if !c.CcLibraryInterface() || !c.Shared() {
+		return nil
+	}
+	if c.IsStubs() || c.HasStubsVariants() || c.IsLlndk() || c.IsNdk(config) {
+		return nil
+	}
+	if c.IsVndk() {
+		return fmt.Errorf("is a VNDK library whose interface is not stable across platform releases")
+	}
+	return fmt.Errorf("is a platform library without stubs")
+}
+
 // If this is a stubs library, ImplementationModuleName returns the name of the module that contains
 // the implementation.  If it is an implementation library it returns its own name.
 func (c *Module) ImplementationModuleName(ctx android.BaseModuleContext) string {
@@ -1193,6 +1248,45 @@ func (c *Module) ImplementationModuleNameForMake(ctx android.BaseModuleContext)
 	return name
 }
 
+// implementationRestricted returns true if dep is a library that only allows modules in its APEXes
+// to link against its implementation, see stubs.restrict_implementation.
+func implementationRestricted(dep android.Module) bool {
+	if m, ok := dep.(*Module); ok {
+		if lib, ok := m.linker.(interface {
+			implementationRestricted() bool
+		}); ok {
+			return lib.implementationRestricted()
+		}
+	}
+	return false
+}
+
+// canLinkRestrictedImplementation returns true if this module may link against the implementation
+// of dep even though dep sets stubs.restrict_implementation. That is the case when this module is
+// in APEXes that dep is available to, is a test for an APEX that contains dep, or is a variant
+// that never links against stubs.
+func (c *Module) canLinkRestrictedImplementation(ctx android.ModuleContext, dep android.Module,
+	depName string, apexInfo android.ApexInfo) bool {
+	if c.bootstrap() || !ctx.Device() || c.UseVndk() || c.InRecovery() || c.InRamdisk() || c.InVendorRamdisk() {
+		return true
+	}
+	if apexInfo.IsForPlatform() {
+		testFor := ctx.Provider(android.ApexTestForInfoProvider).(android.ApexTestForInfo)
+		for _, apexContents := range testFor.ApexContents {
+			if apexContents.DirectlyInApex(depName) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, apex := range apexInfo.InApexes {
+		if !dep.(android.ApexModule).AvailableFor(apex) {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Module) bootstrap() bool {
 	return Bool(c.Properties.Bootstrap)
 }
@@ -1546,6 +1640,11 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		return
 	}
 
+	if c.maybeGenerateBazelActions(ctx, deps) {
+		c.maybeInstall(ctx, apexInfo)
+		return
+	}
+
 	if c.Properties.Clang != nil && *c.Properties.Clang == false {
 		ctx.PropertyErrorf("clang", "false (GCC) is no longer supported")
 	}
@@ -1612,6 +1711,8 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	flags.Local.CppFlags = []string{"$cppflags"}
 	flags.Local.AsFlags = []string{"$asflags"}
 
+	flags.DepsAudit = ctx.Config().DepsAuditEnabled()
+
 	var objs Objects
 	if c.compiler != nil {
 		objs = c.compiler.compile(ctx, flags, deps)
@@ -1619,15 +1720,31 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 			return
 		}
 		c.kytheFiles = objs.kytheFiles
+		if len(objs.depsAuditFiles) > 0 {
+			c.depsAuditReport = android.OptionalPathForPath(depsAuditBuildActions(ctx, objs.depsAuditFiles))
+		}
 	}
 
 	if c.linker != nil {
+		if c.compiler != nil {
+			// Static libraries in srcs, including those in the srcs of the static variant whose
+			// objects are reused, are linked rather than compiled.
+			staticLibSrcs := append(append(android.Paths{}, objs.staticLibSrcs...), deps.Objs.staticLibSrcs...)
+			deps = c.compiler.linkStaticLibSrcs(ctx, deps, staticLibSrcs)
+			if ctx.Failed() {
+				return
+			}
+		}
 		outputFile := c.linker.link(ctx, flags, deps, objs)
 		if ctx.Failed() {
 			return
 		}
 		c.outputFile = android.OptionalPathForPath(outputFile)
 
+		if c.IsReplacedByPrebuilt() {
+			checkPrebuiltAbiCompatWithSource(ctx, c)
+		}
+
 		// If a lib is directly included in any of the APEXes or is not available to the
 		// platform (which is often the case when the stub is provided as a prebuilt),
 		// unhide the stubs variant having the latest version gets visible to make. In
@@ -1651,6 +1768,25 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		}
 	}
 
+	c.maybeInstall(ctx, apexInfo)
+}
+
+// maybeGenerateBazelActions returns true if the outputs of the module were taken from Bazel in a
+// mixed build, in which case Soong neither compiles nor links it.
+func (c *Module) maybeGenerateBazelActions(ctx ModuleContext, deps PathDeps) bool {
+	handler, ok := c.linker.(bazelHandler)
+	if !ok || !ctx.Config().BazelContext.BazelEnabled() {
+		return false
+	}
+	outputFile, ok := handler.generateBazelBuildActions(ctx, deps)
+	if !ok {
+		return false
+	}
+	c.outputFile = android.OptionalPathForPath(outputFile)
+	return true
+}
+
+func (c *Module) maybeInstall(ctx ModuleContext, apexInfo android.ApexInfo) {
 	if !proptools.BoolDefault(c.Properties.Installable, true) {
 		// If the module has been specifically configure to not be installed then
 		// hide from make as otherwise it will break when running inside make
@@ -1666,9 +1802,6 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	// to allow using the outputs in a genrule.
 	if c.installer != nil && c.outputFile.Valid() {
 		c.installer.install(ctx, c.outputFile.Path())
-		if ctx.Failed() {
-			return
-		}
 	}
 }
 
@@ -2571,6 +2704,13 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 						useStubs = !android.DirectlyInAllApexes(apexInfo, depName)
 					}
 
+					// Libraries that restrict their implementation to their APEXes are linked
+					// through their stubs from everywhere else.
+					if !useStubs && implementationRestricted(dep) &&
+						!c.canLinkRestrictedImplementation(ctx, dep, depName, apexInfo) {
+						useStubs = true
+					}
+
 					// when to use (unspecified) stubs, check min_sdk_version and choose the right one
 					if useStubs {
 						sharedLibraryStubsInfo, err :=
@@ -2624,6 +2764,12 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 					break
 				}
 
+				if implementationRestricted(dep) && !c.canLinkRestrictedImplementation(ctx, dep, depName, apexInfo) {
+					ctx.ModuleErrorf("cannot statically link %q outside of its APEXes because it sets "+
+						"stubs.restrict_implementation, depend on it through shared_libs to use its stubs", depName)
+					return
+				}
+
 				staticLibraryInfo := ctx.OtherModuleProvider(dep, StaticLibraryInfoProvider).(StaticLibraryInfo)
 				linkFile = android.OptionalPathForPath(staticLibraryInfo.StaticLibrary)
 				if libDepTag.wholeStatic {
@@ -2723,6 +2869,8 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			case libDepTag.header():
 				c.Properties.AndroidMkHeaderLibs = append(
 					c.Properties.AndroidMkHeaderLibs, makeLibName)
+				// Record baseLibName for snapshots.
+				c.Properties.SnapshotHeaderLibs = append(c.Properties.SnapshotHeaderLibs, baseLibName(depName))
 			case libDepTag.shared():
 				if lib := moduleLibraryInterface(dep); lib != nil {
 					if lib.buildStubs() && dep.(android.ApexModule).InAnyApex() {
@@ -2749,6 +2897,9 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				} else {
 					c.Properties.AndroidMkStaticLibs = append(
 						c.Properties.AndroidMkStaticLibs, makeLibName)
+					// Record baseLibName for snapshots.  Whole static libraries are not recorded, as
+					// they are part of the archive.
+					c.Properties.SnapshotStaticLibs = append(c.Properties.SnapshotStaticLibs, baseLibName(depName))
 				}
 			}
 		} else if !c.IsStubs() {
@@ -2944,6 +3095,11 @@ func (c *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{c.outputFile.Path()}, nil
 		}
 		return android.Paths{}, nil
+	case ".unstripped":
+		if unstripped := c.UnstrippedOutputFile(); unstripped != nil {
+			return android.Paths{unstripped}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no unstripped output file was found.", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -2958,6 +3114,11 @@ func (c *Module) static() bool {
 	return false
 }
 
+// StaticExecutable returns true if this is a binary or test module with "static_executable: true".
+func (c *Module) StaticExecutable() bool {
+	return c.staticBinary()
+}
+
 func (c *Module) staticBinary() bool {
 	if static, ok := c.linker.(interface {
 		staticBinary() bool
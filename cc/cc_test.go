@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -23,6 +24,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint"
+
 	"android/soong/android"
 )
 
@@ -717,6 +720,169 @@ func TestDataLibsRelativeInstallPath(t *testing.T) {
 	}
 }
 
+func TestBenchmarkDataLibs(t *testing.T) {
+	bp := `
+		cc_test_library {
+			name: "bench_lib",
+			srcs: ["test_lib.cpp"],
+			relative_install_path: "foo",
+			gtest: false,
+		}
+
+		cc_benchmark {
+			name: "main_bench",
+			srcs: ["bench.cpp"],
+			data: ["data.txt"],
+			data_libs: ["bench_lib"],
+			disable_framework: true,
+		}
+	`
+
+	config := TestConfig(buildDir, android.Android, nil, bp, map[string][]byte{
+		"data.txt": nil,
+	})
+	ctx := testCcWithConfig(t, config)
+
+	module := ctx.ModuleForTests("main_bench", "android_arm64_armv8-a").Module().(*Module)
+	dataPaths := module.DataPaths()
+	if len(dataPaths) != 2 {
+		t.Fatalf("expected exactly two data files, got %q", dataPaths)
+	}
+
+	entries := android.AndroidMkEntriesForTest(t, config, "", module)[0]
+	testData := entries.EntryMap["LOCAL_TEST_DATA"]
+	if len(testData) != 2 {
+		t.Fatalf("expected two LOCAL_TEST_DATA entries, got %q", testData)
+	}
+	if !strings.HasSuffix(testData[0], ":data.txt") {
+		t.Errorf("expected LOCAL_TEST_DATA to contain data.txt, got %q", testData[0])
+	}
+	if !strings.HasSuffix(testData[1], ":bench_lib.so:foo") {
+		t.Errorf("expected LOCAL_TEST_DATA to contain bench_lib.so installed to foo, got %q", testData[1])
+	}
+}
+
+func TestBenchmarkDisableFramework(t *testing.T) {
+	ctx := testCc(t, `
+		cc_benchmark {
+			name: "framework_bench",
+			srcs: ["bench.cpp"],
+		}
+
+		cc_benchmark {
+			name: "no_framework_bench",
+			srcs: ["bench.cpp"],
+			disable_framework: true,
+		}
+	`)
+
+	stopServices := "com.android.tradefed.targetprep.StopServicesSetup"
+	for _, tc := range []struct {
+		name         string
+		stopServices bool
+	}{
+		{"framework_bench", false},
+		{"no_framework_bench", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testConfig := ctx.ModuleForTests(tc.name, "android_arm64_armv8-a").Output(tc.name + ".config")
+			extraConfigs := testConfig.Args["extraConfigs"]
+			if g, w := strings.Contains(extraConfigs, stopServices), tc.stopServices; g != w {
+				t.Errorf("expected %s in the test config to be %v, got extra configs %q", stopServices, w, extraConfigs)
+			}
+		})
+	}
+}
+
+func TestTestTradefedOptions(t *testing.T) {
+	bp := `
+		cc_test {
+			name: "main_test",
+			srcs: ["main_test.cpp"],
+			data: ["testdata/config.txt"],
+			test_options: {
+				tradefed_options: [
+					"native-test-flag=--config=$(location testdata/config.txt)",
+					"native-test-timeout=5m",
+				],
+			},
+		}
+	`
+	config := TestConfig(buildDir, android.Android, nil, bp, map[string][]byte{
+		"testdata/config.txt": nil,
+	})
+	ctx := testCcWithConfig(t, config)
+
+	testConfig := ctx.ModuleForTests("main_test", "android_arm64_armv8-a").Output("main_test.config")
+	extraConfigs := testConfig.Args["extraConfigs"]
+	for _, w := range []string{
+		`<option name="native-test-flag" value="--config=testdata/config.txt" />`,
+		`<option name="native-test-timeout" value="5m" />`,
+	} {
+		if !strings.Contains(extraConfigs, w) {
+			t.Errorf("expected %q in the test config, got extra configs %q", w, extraConfigs)
+		}
+	}
+
+	testCcError(t, `unknown location label "testdata/other.txt"`, `
+		cc_test {
+			name: "main_test",
+			srcs: ["main_test.cpp"],
+			test_options: {
+				tradefed_options: ["native-test-flag=$(location testdata/other.txt)"],
+			},
+		}
+	`)
+}
+
+func TestTestRuntimeLibs(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library {
+			name: "libruntime",
+			srcs: ["runtime.cpp"],
+			shared_libs: ["libruntime_dep"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libruntime_dep",
+			srcs: ["runtime_dep.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+
+		cc_test {
+			name: "main_test",
+			runtime_libs: ["libruntime"],
+			test_suites: ["device-tests"],
+			gtest: false,
+		}
+
+		cc_test {
+			name: "other_test",
+			runtime_libs: ["libruntime"],
+			gtest: false,
+		}
+	`)
+
+	module := ctx.ModuleForTests("main_test", "android_arm64_armv8-a").Module()
+	entries := android.AndroidMkEntriesForTest(t, ctx.Config(), "", module)[0]
+	testData := entries.EntryMap["LOCAL_TEST_DATA"]
+	for _, lib := range []string{"libruntime.so", "libruntime_dep.so"} {
+		if !android.SuffixInList(testData, ":"+lib+":lib64") {
+			t.Errorf("expected LOCAL_TEST_DATA to install %q into lib64, but was %q", lib, testData)
+		}
+	}
+
+	// runtime_libs are only copied alongside tests that are packaged into test suites.
+	module = ctx.ModuleForTests("other_test", "android_arm64_armv8-a").Module()
+	entries = android.AndroidMkEntriesForTest(t, ctx.Config(), "", module)[0]
+	if testData := entries.EntryMap["LOCAL_TEST_DATA"]; len(testData) > 0 {
+		t.Errorf("expected no LOCAL_TEST_DATA, but was %q", testData)
+	}
+}
+
 func TestVndkWhenVndkVersionIsNotSet(t *testing.T) {
 	ctx := testCcNoVndk(t, `
 		cc_library {
@@ -1100,6 +1266,72 @@ func TestDoubleLoadbleDep(t *testing.T) {
 	`)
 }
 
+func TestVendorSnapshotCaptureJsonFlags(t *testing.T) {
+	bp := `
+	cc_library {
+		name: "libvendor_available",
+		vendor_available: true,
+		min_sdk_version: "29",
+		nocrt: true,
+	}
+
+	cc_library_static {
+		name: "libvendor_static",
+		vendor_available: true,
+		static_libs: ["libvendor_dep"],
+		whole_static_libs: ["libvendor_whole"],
+		header_libs: ["libvendor_headers"],
+	}
+
+	cc_library_static {
+		name: "libvendor_dep",
+		vendor_available: true,
+	}
+
+	cc_library_static {
+		name: "libvendor_whole",
+		vendor_available: true,
+	}
+
+	cc_library_headers {
+		name: "libvendor_headers",
+		vendor_available: true,
+	}
+`
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.DeviceVndkVersion = StringPtr("current")
+	config.TestProductVariables.Platform_vndk_version = StringPtr("VER")
+	ctx := testCcWithConfig(t, config)
+
+	snapshotSingleton := ctx.SingletonForTests("vendor-snapshot")
+	snapshotJson := func(libType, stem string) snapshotJsonFlags {
+		t.Helper()
+		jsonPath := filepath.Join(buildDir, "vendor-snapshot", "arm64", "arch-arm64-armv8-a",
+			libType, stem+".json")
+		content := android.ContentFromFileRuleForTests(t, snapshotSingleton.Output(jsonPath))
+		var prop snapshotJsonFlags
+		if err := json.Unmarshal([]byte(content), &prop); err != nil {
+			t.Fatalf("failed to parse %s: %s", jsonPath, err)
+		}
+		return prop
+	}
+
+	if g, w := snapshotJson("shared", "libvendor_available.so").MinSdkVersion, "29"; g != w {
+		t.Errorf("expected min_sdk_version %q in snapshot json, got %q", w, g)
+	}
+
+	static := snapshotJson("static", "libvendor_static.a")
+	if !android.InList("libvendor_dep", static.StaticLibs) {
+		t.Errorf("expected libvendor_dep in the static libs %q", static.StaticLibs)
+	}
+	if android.InList("libvendor_whole", static.StaticLibs) {
+		t.Errorf("expected whole static libs not to be recorded, got %q", static.StaticLibs)
+	}
+	if !android.InList("libvendor_headers", static.HeaderLibs) {
+		t.Errorf("expected libvendor_headers in the header libs %q", static.HeaderLibs)
+	}
+}
+
 func TestVendorSnapshotCapture(t *testing.T) {
 	bp := `
 	cc_library {
@@ -3440,7 +3672,7 @@ func TestLlndkLibrary(t *testing.T) {
 	ctx := testCc(t, `
 	cc_library {
 		name: "libllndk",
-		stubs: { versions: ["1", "2"] },
+		stubs: { symbol_file: "libllndk.map.txt", versions: ["1", "2"] },
 		llndk_stubs: "libllndk.llndk",
 	}
 	llndk_library {
@@ -3449,7 +3681,7 @@ func TestLlndkLibrary(t *testing.T) {
 
 	cc_prebuilt_library_shared {
 		name: "libllndkprebuilt",
-		stubs: { versions: ["1", "2"] },
+		stubs: { symbol_file: "libllndkprebuilt.map.txt", versions: ["1", "2"] },
 		llndk_stubs: "libllndkprebuilt.llndk",
 	}
 	llndk_library {
@@ -3458,7 +3690,7 @@ func TestLlndkLibrary(t *testing.T) {
 
 	cc_library {
 		name: "libllndk_with_external_headers",
-		stubs: { versions: ["1", "2"] },
+		stubs: { symbol_file: "libllndk_with_external_headers.map.txt", versions: ["1", "2"] },
 		llndk_stubs: "libllndk_with_external_headers.llndk",
 		header_libs: ["libexternal_headers"],
 		export_header_lib_headers: ["libexternal_headers"],
@@ -3987,7 +4219,7 @@ func TestVersionedStubs(t *testing.T) {
 	} else {
 		for _, v := range expectedVariants {
 			if !inList(v, variants) {
-				variantsMismatch = false
+				variantsMismatch = true
 			}
 		}
 	}
@@ -4017,6 +4249,17 @@ func TestVersionedStubs(t *testing.T) {
 	}
 }
 
+func TestVersionedStubsRequireSymbolFile(t *testing.T) {
+	testCcError(t, `"libFoo" .*: stubs.symbol_file: must be set when stubs.versions is set`, `
+		cc_library_shared {
+			name: "libFoo",
+			srcs: ["foo.c"],
+			stubs: {
+				versions: ["1", "2"],
+			},
+		}`)
+}
+
 func TestVersioningMacro(t *testing.T) {
 	for _, tc := range []struct{ moduleName, expected string }{
 		{"libc", "__LIBC_API__"},
@@ -4029,232 +4272,1167 @@ func TestVersioningMacro(t *testing.T) {
 	}
 }
 
-func TestStaticExecutable(t *testing.T) {
+func TestLinkerScriptProperties(t *testing.T) {
 	ctx := testCc(t, `
-		cc_binary {
-			name: "static_test",
-			srcs: ["foo.c", "baz.o"],
-			static_executable: true,
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			static_libs: ["libbar"],
+			arch: {
+				arm64: {
+					dynamic_list: "foo_arm64.dynamic",
+					exclude_libs: ["libbar"],
+				},
+			},
+		}
+
+		cc_library_static {
+			name: "libbar",
+			srcs: ["bar.c"],
 		}`)
 
-	variant := "android_arm64_armv8-a"
-	binModuleRule := ctx.ModuleForTests("static_test", variant).Rule("ld")
-	libFlags := binModuleRule.Args["libFlags"]
-	systemStaticLibs := []string{"libc.a", "libm.a"}
-	for _, lib := range systemStaticLibs {
-		if !strings.Contains(libFlags, lib) {
-			t.Errorf("Static lib %q was not found in %q", lib, libFlags)
+	arm64Ld := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Rule("ld")
+	for _, flag := range []string{"-Wl,--dynamic-list,foo_arm64.dynamic", "-Wl,--exclude-libs,libbar.a"} {
+		if !strings.Contains(arm64Ld.Args["ldFlags"], flag) {
+			t.Errorf("expected arm64 ldFlags to contain %q, got %q", flag, arm64Ld.Args["ldFlags"])
 		}
 	}
-	systemSharedLibs := []string{"libc.so", "libm.so", "libdl.so"}
-	for _, lib := range systemSharedLibs {
-		if strings.Contains(libFlags, lib) {
-			t.Errorf("Shared lib %q was found in %q", lib, libFlags)
-		}
+	if !android.InList("foo_arm64.dynamic", arm64Ld.Implicits.Strings()) {
+		t.Errorf("expected dynamic list to be an implicit input of the link, got %v", arm64Ld.Implicits)
 	}
-}
 
-func TestStaticDepsOrderWithStubs(t *testing.T) {
-	ctx := testCc(t, `
-		cc_binary {
-			name: "mybin",
-			srcs: ["foo.c"],
-			static_libs: ["libfooC", "libfooB"],
-			static_executable: true,
-			stl: "none",
-		}
+	armLd := ctx.ModuleForTests("libfoo", "android_arm_armv7-a-neon_shared").Rule("ld")
+	if strings.Contains(armLd.Args["ldFlags"], "--dynamic-list") || strings.Contains(armLd.Args["ldFlags"], "libbar.a") {
+		t.Errorf("expected no arm64 specific linker flags on arm, got %q", armLd.Args["ldFlags"])
+	}
 
-		cc_library {
-			name: "libfooB",
+	testCcError(t, `"libbaz" is not listed in static_libs or whole_static_libs`, `
+		cc_library_shared {
+			name: "libfoo",
 			srcs: ["foo.c"],
-			shared_libs: ["libfooC"],
-			stl: "none",
-		}
+			exclude_libs: ["libbaz"],
+		}`)
 
+	// The static libs of the shared: block can be excluded, and the static variant, which doesn't
+	// link against them, doesn't report them.
+	ctx = testCc(t, `
 		cc_library {
-			name: "libfooC",
+			name: "libfoo",
 			srcs: ["foo.c"],
-			stl: "none",
-			stubs: {
-				versions: ["1"],
+			shared: {
+				whole_static_libs: ["libbar"],
 			},
+			exclude_libs: ["libbar"],
+		}
+
+		cc_library_static {
+			name: "libbar",
+			srcs: ["bar.c"],
 		}`)
 
-	mybin := ctx.ModuleForTests("mybin", "android_arm64_armv8-a").Rule("ld")
-	actual := mybin.Implicits[:2]
-	expected := getOutputPaths(ctx, "android_arm64_armv8-a_static", []string{"libfooB", "libfooC"})
+	sharedLd := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Rule("ld")
+	if !strings.Contains(sharedLd.Args["ldFlags"], "-Wl,--exclude-libs,libbar.a") {
+		t.Errorf("expected ldFlags to exclude libbar.a, got %q", sharedLd.Args["ldFlags"])
+	}
+}
 
-	if !reflect.DeepEqual(actual, expected) {
-		t.Errorf("staticDeps orderings were not propagated correctly"+
-			"\nactual:   %v"+
-			"\nexpected: %v",
-			actual,
-			expected,
-		)
+func TestCfiPaths(t *testing.T) {
+	mockFS := map[string][]byte{
+		"deps/Android.bp": []byte(GatherRequiredDepsForTest(android.Android)),
+		"cfi/Android.bp": []byte(`
+			cc_binary {
+				name: "cfi_bin",
+				srcs: ["foo.c"],
+			}`),
+		"cfi/excluded/Android.bp": []byte(`
+			cc_binary {
+				name: "excluded_bin",
+				srcs: ["foo.c"],
+			}`),
+		"cfi/diag/Android.bp": []byte(`
+			cc_binary {
+				name: "diag_bin",
+				srcs: ["foo.c"],
+			}`),
+	}
+
+	config := TestConfig(buildDir, android.Android, nil, "", mockFS)
+	config.TestProductVariables.CFIIncludePaths = []string{"cfi"}
+	config.TestProductVariables.CFIExcludePaths = []string{"cfi/excluded"}
+	config.TestProductVariables.CFIDiagPaths = []string{"cfi/diag"}
+	ctx := CreateTestContext(config)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"deps/Android.bp", "cfi/Android.bp",
+		"cfi/excluded/Android.bp", "cfi/diag/Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	for _, tc := range []struct {
+		name    string
+		variant string
+		cfi     bool
+		diag    bool
+	}{
+		{"cfi_bin", "android_arm64_armv8-a_cfi", true, false},
+		{"excluded_bin", "android_arm64_armv8-a", false, false},
+		{"diag_bin", "android_arm64_armv8-a_cfi", true, true},
+	} {
+		sanitize := ctx.ModuleForTests(tc.name, tc.variant).Module().(*Module).sanitize
+		if got := Bool(sanitize.Properties.Sanitize.Cfi); got != tc.cfi {
+			t.Errorf("%s: expected cfi %v, got %v", tc.name, tc.cfi, got)
+		}
+		if got := Bool(sanitize.Properties.Sanitize.Diag.Cfi); got != tc.diag {
+			t.Errorf("%s: expected diag cfi %v, got %v", tc.name, tc.diag, got)
+		}
 	}
 }
 
-func TestErrorsIfAModuleDependsOnDisabled(t *testing.T) {
-	testCcError(t, `module "libA" .* depends on disabled module "libB"`, `
-		cc_library {
-			name: "libA",
+func TestCfiExportGroup(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libroot",
 			srcs: ["foo.c"],
-			shared_libs: ["libB"],
-			stl: "none",
+			export_include_dirs: ["include"],
+			sanitize: {
+				cfi: true,
+				diag: {
+					cfi: true,
+				},
+				config: {
+					cfi_export_group: true,
+				},
+			},
 		}
 
-		cc_library {
-			name: "libB",
+		cc_library_shared {
+			name: "libreexporter",
 			srcs: ["foo.c"],
-			enabled: false,
-			stl: "none",
+			shared_libs: ["libroot"],
+			export_shared_lib_headers: ["libroot"],
 		}
-	`)
-}
 
-// Simple smoke test for the cc_fuzz target that ensures the rule compiles
-// correctly.
-func TestFuzzTarget(t *testing.T) {
-	ctx := testCc(t, `
-		cc_fuzz {
-			name: "fuzz_smoke_test",
+		cc_library_shared {
+			name: "libtransitive",
 			srcs: ["foo.c"],
-		}`)
+			shared_libs: ["libreexporter"],
+			export_shared_lib_headers: ["libreexporter"],
+		}
 
-	variant := "android_arm64_armv8-a_fuzzer"
-	ctx.ModuleForTests("fuzz_smoke_test", variant).Rule("cc")
-}
+		cc_library_shared {
+			name: "libuser",
+			srcs: ["foo.c"],
+			shared_libs: ["libroot"],
+		}
 
-func TestAidl(t *testing.T) {
-}
+		cc_library_shared {
+			name: "libdisabled",
+			srcs: ["foo.c"],
+			shared_libs: ["libroot"],
+			export_shared_lib_headers: ["libroot"],
+			sanitize: {
+				cfi: false,
+			},
+		}
+	`
+	ctx := testCc(t, bp)
 
-func assertString(t *testing.T, got, expected string) {
+	for _, tc := range []struct {
+		name string
+		cfi  bool
+	}{
+		{"libroot", true},
+		{"libreexporter", true},
+		{"libtransitive", true},
+		{"libuser", false},
+		{"libdisabled", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			module := ctx.ModuleForTests(tc.name, "android_arm64_armv8-a_shared")
+			sanitize := module.Module().(*Module).sanitize
+			if got := Bool(sanitize.Properties.Sanitize.Cfi); got != tc.cfi {
+				t.Errorf("expected cfi %v, got %v", tc.cfi, got)
+			}
+			// The libraries in the group are built in the diag mode of the library exporting the headers.
+			if got := Bool(sanitize.Properties.Sanitize.Diag.Cfi); got != tc.cfi {
+				t.Errorf("expected diag cfi %v, got %v", tc.cfi, got)
+			}
+			cflags := module.Rule("cc").Args["cFlags"]
+			if got := strings.Contains(cflags, "-fsanitize-cfi-cross-dso"); got != tc.cfi {
+				t.Errorf("expected cross-DSO CFI cflags %v, got %q", tc.cfi, cflags)
+			}
+		})
+	}
+}
+
+func TestCfiExportGroupExcludedPath(t *testing.T) {
+	mockFS := map[string][]byte{
+		"deps/Android.bp": []byte(GatherRequiredDepsForTest(android.Android)),
+		"root/Android.bp": []byte(`
+			cc_library_shared {
+				name: "libroot",
+				srcs: ["foo.c"],
+				export_include_dirs: ["include"],
+				sanitize: {
+					cfi: true,
+					config: {
+						cfi_export_group: true,
+					},
+				},
+			}
+
+			cc_library_shared {
+				name: "libreexporter",
+				srcs: ["foo.c"],
+				shared_libs: ["libroot"],
+				export_shared_lib_headers: ["libroot"],
+			}`),
+		"excluded/Android.bp": []byte(`
+			cc_library_shared {
+				name: "libexcluded",
+				srcs: ["foo.c"],
+				shared_libs: ["libroot"],
+				export_shared_lib_headers: ["libroot"],
+			}`),
+	}
+
+	config := TestConfig(buildDir, android.Android, nil, "", mockFS)
+	config.TestProductVariables.CFIExcludePaths = []string{"excluded"}
+	ctx := CreateTestContext(config)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"deps/Android.bp", "root/Android.bp", "excluded/Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	for _, tc := range []struct {
+		name string
+		cfi  bool
+	}{
+		{"libreexporter", true},
+		{"libexcluded", false},
+	} {
+		sanitize := ctx.ModuleForTests(tc.name, "android_arm64_armv8-a_shared").Module().(*Module).sanitize
+		if got := Bool(sanitize.Properties.Sanitize.Cfi); got != tc.cfi {
+			t.Errorf("%s: expected cfi %v, got %v", tc.name, tc.cfi, got)
+		}
+	}
+}
+
+func TestPgoInstrumentBenchmarks(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			pgo: {
+				instrumentation: true,
+				profile_file: "foo.profdata",
+				benchmarks: ["foo_benchmark"],
+			},
+		}
+
+		toolchain_library {
+			name: "libclang_rt.profile-arm-android",
+			src: "",
+		}
+
+		toolchain_library {
+			name: "libclang_rt.profile-aarch64-android",
+			src: "",
+		}`
+
+	for _, benchmarks := range [][]string{nil, {"bar_benchmark"}, {"foo_benchmark"}, {"all"}} {
+		config := TestConfig(buildDir, android.Android, nil, bp, nil)
+		config.TestProductVariables.PgoInstrumentBenchmarks = benchmarks
+		ctx := testCcWithConfig(t, config)
+
+		cFlags := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Rule("cc").Args["cFlags"]
+		expected := android.InList("foo_benchmark", benchmarks) || android.InList("all", benchmarks)
+		if got := strings.Contains(cFlags, "-fprofile-generate"); got != expected {
+			t.Errorf("with PgoInstrumentBenchmarks %q, expected instrumentation %v, got cFlags %q",
+				benchmarks, expected, cFlags)
+		}
+	}
+}
+
+func TestPgoMissingProfileReport(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			pgo: {
+				sampling: true,
+				profile_file: "foo.profdata",
+			},
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.c"],
+			pgo: {
+				sampling: true,
+				profile_file: "bar.profdata",
+			},
+		}`
+
+	fs := map[string][]byte{
+		"toolchain/pgo-profiles/bar.profdata": nil,
+	}
+	config := TestConfig(buildDir, android.Android, nil, bp, fs)
+	ctx := CreateTestContext(config)
+	ctx.RegisterSingletonType("pgo_profile_report", pgoProfileReportSingletonFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	report := ctx.SingletonForTests("pgo_profile_report").Output(pgoMissingProfilesFileName)
+	if g, w := android.ContentFromFileRuleForTests(t, report), "foo.profdata:./Android.bp:libfoo"; g != w {
+		t.Errorf("expected missing profiles %q, got %q", w, g)
+	}
+}
+
+func TestSizeOptimizedLinking(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["bar.c"],
+			size_optimized_linking: false,
+		}
+
+		cc_library_shared {
+			name: "libbaz",
+			srcs: ["baz.c"],
+			size_optimized_linking: false,
+			icf: "all",
+		}
+
+		cc_library_shared {
+			name: "libqux",
+			srcs: ["qux.c"],
+			use_clang_lld: false,
+		}`
+
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.SizeOptimizedLinking = BoolPtr(true)
+	ctx := testCcWithConfig(t, config)
+
+	// The default mode of the target already folds identical code, only sections are collected.
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	if ldFlags := libfoo.Rule("ld").Args["ldFlags"]; !strings.Contains(ldFlags, "-Wl,--gc-sections") ||
+		strings.Contains(ldFlags, "-Wl,--icf=") {
+		t.Errorf("expected libfoo to be linked with --gc-sections and the default icf mode, got %q", ldFlags)
+	}
+	sizeReport := libfoo.Output("size_report/libfoo.so.txt")
+	if g, w := sizeReport.Input.String(), libfoo.Module().(*Module).UnstrippedOutputFile().String(); g != w {
+		t.Errorf("expected size report of %q, got %q", w, g)
+	}
+
+	libbar := ctx.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	if ldFlags := libbar.Rule("ld").Args["ldFlags"]; !strings.Contains(ldFlags, "-Wl,--icf=none") ||
+		strings.Contains(ldFlags, "-Wl,--gc-sections") {
+		t.Errorf("expected libbar to be linked without identical code folding, got %q", ldFlags)
+	}
+	if libbar.MaybeOutput("size_report/libbar.so.txt").Rule != nil {
+		t.Errorf("expected no size report for libbar")
+	}
+
+	// The icf property takes precedence over opting out.
+	libbaz := ctx.ModuleForTests("libbaz", "android_arm64_armv8-a_shared")
+	if ldFlags := libbaz.Rule("ld").Args["ldFlags"]; !strings.Contains(ldFlags, "-Wl,--icf=all") ||
+		strings.Contains(ldFlags, "-Wl,--icf=none") {
+		t.Errorf("expected libbaz to be linked with --icf=all only, got %q", ldFlags)
+	}
+
+	// Only lld links with SizeOptimizedLinking.
+	libqux := ctx.ModuleForTests("libqux", "android_arm64_armv8-a_shared")
+	if ldFlags := libqux.Rule("ld").Args["ldFlags"]; strings.Contains(ldFlags, "-Wl,--gc-sections") ||
+		strings.Contains(ldFlags, "-Wl,--icf=") {
+		t.Errorf("expected libqux to be linked without lld flags, got %q", ldFlags)
+	}
+	if libqux.MaybeOutput("size_report/libqux.so.txt").Rule != nil {
+		t.Errorf("expected no size report for libqux")
+	}
+}
+
+func TestLldProperties(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			icf: "all",
+			rosegment: false,
+		}`
+
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.SizeOptimizedLinking = BoolPtr(true)
+	ctx := testCcWithConfig(t, config)
+
+	ldFlags := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Rule("ld").Args["ldFlags"]
+	if !strings.Contains(ldFlags, "-Wl,--no-rosegment") {
+		t.Errorf("expected libfoo to be linked with --no-rosegment, got %q", ldFlags)
+	}
+	if !strings.Contains(ldFlags, "-Wl,--icf=all") {
+		t.Errorf("expected libfoo to be linked with --icf=all, got %q", ldFlags)
+	}
+}
+
+func TestLldPropertiesErrors(t *testing.T) {
+	testCcError(t, `module "libfoo".*: icf: requires the lld linker`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			use_clang_lld: false,
+			icf: "all",
+		}`)
+
+	testCcError(t, `module "libfoo".*: pack_relocations: requires the lld linker`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			use_clang_lld: false,
+			pack_relocations: true,
+		}`)
+
+	// Not packing relocations is what other linkers do anyway.
+	testCc(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			use_clang_lld: false,
+			pack_relocations: false,
+		}`)
+
+	testCcError(t, `module "libfoo".*: icf: must be one of "none", "safe" or "all", got "everything"`, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			icf: "everything",
+		}`)
+}
+
+func TestStripProperties(t *testing.T) {
+	ctx := testCc(t, `
+		cc_binary {
+			name: "bin_keep_list",
+			srcs: ["foo.c"],
+			compile_multilib: "both",
+			strip: {
+				keep_symbols: true,
+			},
+			arch: {
+				arm64: {
+					strip: {
+						keep_symbols_list: ["foo", "bar"],
+					},
+				},
+				arm: {
+					strip: {
+						keep_symbols: false,
+						keep_symbols_and_debug_frame: true,
+					},
+				},
+			},
+		}
+
+		cc_binary {
+			name: "bin_none",
+			srcs: ["foo.c"],
+			strip: {
+				none: true,
+			},
+		}
+
+		cc_library_shared {
+			name: "libkeep",
+			srcs: ["foo.c"],
+			strip: {
+				keep_symbols: true,
+			},
+		}`)
+
+	binKeepList := ctx.ModuleForTests("bin_keep_list", "android_arm64_armv8-a")
+	if args := binKeepList.Rule("strip").Args["args"]; !strings.Contains(args, "-kfoo,bar") ||
+		strings.Contains(args, "--keep-mini-debug-info") {
+		t.Errorf("expected only the listed symbols to be kept, got strip args %q", args)
+	}
+
+	binKeepListArm := ctx.ModuleForTests("bin_keep_list", "android_arm_armv7-a-neon")
+	if args := binKeepListArm.Rule("strip").Args["args"]; !strings.Contains(args, "--keep-symbols-and-debug-frame") {
+		t.Errorf("expected arm variant to keep symbols and debug frame, got strip args %q", args)
+	}
+
+	binNone := ctx.ModuleForTests("bin_none", "android_arm64_armv8-a")
+	if binNone.MaybeRule("strip").Rule != nil {
+		t.Errorf("expected bin_none not to be stripped")
+	}
+	outputs, err := binNone.Module().(*Module).OutputFiles(".unstripped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := outputs.Strings(), []string{binNone.Module().(*Module).UnstrippedOutputFile().String()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected .unstripped output %q, got %q", w, g)
+	}
+
+	libKeep := ctx.ModuleForTests("libkeep", "android_arm64_armv8-a_shared")
+	strip := libKeep.Rule("strip")
+	if args := strip.Args["args"]; !strings.Contains(args, "--keep-symbols") {
+		t.Errorf("expected all symbols to be kept, got strip args %q", args)
+	}
+	outputs, err = libKeep.Module().(*Module).OutputFiles(".unstripped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, w := outputs.Strings(), []string{strip.Input.String()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected .unstripped output %q, got %q", w, g)
+	}
+}
+
+func TestStripPropertiesConflict(t *testing.T) {
+	testCcError(t, `strip.none: cannot be set together with strip.all`, `
+		cc_binary {
+			name: "bin",
+			srcs: ["foo.c"],
+			strip: {
+				none: true,
+				all: true,
+			},
+		}`)
+
+	testCcError(t, `only one of strip.all, strip.keep_symbols may be set`, `
+		cc_binary {
+			name: "bin",
+			srcs: ["foo.c"],
+			strip: {
+				all: true,
+				keep_symbols: true,
+			},
+		}`)
+}
+
+func TestStripPropertiesReportedOnce(t *testing.T) {
+	// use_version_lib strips the binary a second time, the conflict must still be reported once.
+	bp := `
+		cc_binary {
+			name: "bin",
+			srcs: ["foo.c"],
+			use_version_lib: true,
+			compile_multilib: "first",
+			strip: {
+				all: true,
+				keep_symbols: true,
+			},
+		}
+
+		cc_library_static {
+			name: "libbuildversion",
+			srcs: ["foo.c"],
+		}`
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	ctx := CreateTestContext(config)
+	ctx.Register()
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+
+	count := 0
+	for _, err := range errs {
+		if strings.Contains(err.Error(), "only one of strip.all, strip.keep_symbols may be set") {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected the strip conflict to be reported once, got %d times in %q", count, errs)
+	}
+}
+
+func TestStaticExecutable(t *testing.T) {
+	ctx := testCc(t, `
+		cc_binary {
+			name: "static_test",
+			srcs: ["foo.c", "baz.o"],
+			static_executable: true,
+		}`)
+
+	variant := "android_arm64_armv8-a"
+	binModuleRule := ctx.ModuleForTests("static_test", variant).Rule("ld")
+	libFlags := binModuleRule.Args["libFlags"]
+	systemStaticLibs := []string{"libc.a", "libm.a"}
+	for _, lib := range systemStaticLibs {
+		if !strings.Contains(libFlags, lib) {
+			t.Errorf("Static lib %q was not found in %q", lib, libFlags)
+		}
+	}
+	systemSharedLibs := []string{"libc.so", "libm.so", "libdl.so"}
+	for _, lib := range systemSharedLibs {
+		if strings.Contains(libFlags, lib) {
+			t.Errorf("Shared lib %q was found in %q", lib, libFlags)
+		}
+	}
+}
+
+func TestHermeticHostBinaries(t *testing.T) {
+	bp := `
+		cc_binary_host {
+			name: "host_tool",
+			srcs: ["foo.c"],
+			use_version_lib: true,
+		}
+
+		cc_library_static {
+			name: "libbuildversion",
+			host_supported: true,
+			srcs: ["foo.c"],
+		}`
+
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.HostHermeticBinaries = BoolPtr(true)
+	config.TestProductVariables.BuildId = StringPtr("TEST.ID")
+	ctx := testCcWithConfig(t, config)
+
+	hostTool := ctx.ModuleForTests("host_tool", "linux_glibc_x86_64")
+	if !Bool(hostTool.Module().(*Module).linker.(*binaryDecorator).Properties.Static_executable) {
+		t.Errorf("expected host_tool to be a static executable")
+	}
+
+	strip := hostTool.Rule("strip")
+	if args := strip.Args["args"]; strings.Contains(args, "--keep-mini-debug-info") ||
+		strings.Contains(args, "--add-gnu-debuglink") {
+		t.Errorf("expected host_tool to be fully stripped, got strip args %q", args)
+	}
+
+	buildIdFile := hostTool.Output("build_id.txt")
+	if content := android.ContentFromFileRuleForTests(t, buildIdFile); content != "TEST.ID" {
+		t.Errorf("expected build_id.txt to contain %q, got %q", "TEST.ID", content)
+	}
+	inject := hostTool.Rule("injectVersionSymbol")
+	if got, want := inject.Args["buildNumberFile"], buildIdFile.Output.String(); got != want {
+		t.Errorf("expected version symbol to be injected from %q, got %q", want, got)
+	}
+}
+
+func TestStaticDepsOrderWithStubs(t *testing.T) {
+	ctx := testCc(t, `
+		cc_binary {
+			name: "mybin",
+			srcs: ["foo.c"],
+			static_libs: ["libfooC", "libfooB"],
+			static_executable: true,
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libfooB",
+			srcs: ["foo.c"],
+			shared_libs: ["libfooC"],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libfooC",
+			srcs: ["foo.c"],
+			stl: "none",
+			stubs: {
+				symbol_file: "libfooC.map.txt",
+				versions: ["1"],
+			},
+		}`)
+
+	mybin := ctx.ModuleForTests("mybin", "android_arm64_armv8-a").Rule("ld")
+	actual := mybin.Implicits[:2]
+	expected := getOutputPaths(ctx, "android_arm64_armv8-a_static", []string{"libfooB", "libfooC"})
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("staticDeps orderings were not propagated correctly"+
+			"\nactual:   %v"+
+			"\nexpected: %v",
+			actual,
+			expected,
+		)
+	}
+}
+
+func TestErrorsIfAModuleDependsOnDisabled(t *testing.T) {
+	testCcError(t, `module "libA" .* depends on disabled module "libB"`, `
+		cc_library {
+			name: "libA",
+			srcs: ["foo.c"],
+			shared_libs: ["libB"],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libB",
+			srcs: ["foo.c"],
+			enabled: false,
+			stl: "none",
+		}
+	`)
+}
+
+// Simple smoke test for the cc_fuzz target that ensures the rule compiles
+// correctly.
+func TestFuzzTarget(t *testing.T) {
+	ctx := testCc(t, `
+		cc_fuzz {
+			name: "fuzz_smoke_test",
+			srcs: ["foo.c"],
+		}`)
+
+	variant := "android_arm64_armv8-a_fuzzer"
+	ctx.ModuleForTests("fuzz_smoke_test", variant).Rule("cc")
+}
+
+func TestFuzzPackaging(t *testing.T) {
+	bp := `
+		cc_fuzz {
+			name: "fuzz_host_only",
+			host_supported: true,
+			srcs: ["foo.c"],
+			corpus: ["corpus/a"],
+			dictionary: "foo.dict",
+			fuzz_config: {
+				fuzz_on_haiku_device: false,
+			},
+		}`
+	config := TestConfig(buildDir, android.Android, nil, bp, map[string][]byte{
+		"corpus/a": nil,
+		"foo.dict": nil,
+	})
+	ctx := testCcWithConfig(t, config)
+
+	packager := ctx.SingletonForTests("cc_fuzz_packaging")
+
+	hostZip := packager.Output("fuzz-host-x86_64.zip")
+	inputs := append(hostZip.Inputs.Strings(), hostZip.Implicits.Strings()...)
+	if g, w := inputs, "fuzz_host_only.zip"; !android.SuffixInList(g, w) {
+		t.Errorf("expected host fuzz package to contain %q, got %q", w, g)
+	}
+
+	if p := packager.MaybeOutput("fuzz-target-arm64.zip"); p.Rule != nil {
+		t.Errorf("expected no device fuzz package for a target with fuzz_on_haiku_device: false")
+	}
+
+	fuzzTargets := packager.Singleton().(*fuzzPackager).fuzzTargets
+	if !fuzzTargets["fuzz_host_only"] {
+		t.Errorf("expected fuzz_host_only in fuzz targets, got %v", fuzzTargets)
+	}
+}
+
+func TestAidl(t *testing.T) {
+}
+
+func assertString(t *testing.T, got, expected string) {
 	t.Helper()
 	if got != expected {
 		t.Errorf("expected %q got %q", expected, got)
 	}
 }
 
-func assertArrayString(t *testing.T, got, expected []string) {
-	t.Helper()
-	if len(got) != len(expected) {
-		t.Errorf("expected %d (%q) got (%d) %q", len(expected), expected, len(got), got)
-		return
-	}
-	for i := range got {
-		if got[i] != expected[i] {
-			t.Errorf("expected %d-th %q (%q) got %q (%q)",
-				i, expected[i], expected, got[i], got)
-			return
+func assertArrayString(t *testing.T, got, expected []string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Errorf("expected %d (%q) got (%d) %q", len(expected), expected, len(got), got)
+		return
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			t.Errorf("expected %d-th %q (%q) got %q (%q)",
+				i, expected[i], expected, got[i], got)
+			return
+		}
+	}
+}
+
+func assertMapKeys(t *testing.T, m map[string]string, expected []string) {
+	t.Helper()
+	assertArrayString(t, android.SortedStringKeys(m), expected)
+}
+
+func TestDefaults(t *testing.T) {
+	ctx := testCc(t, `
+		cc_defaults {
+			name: "defaults",
+			srcs: ["foo.c"],
+			static: {
+				srcs: ["bar.c"],
+			},
+			shared: {
+				srcs: ["baz.c"],
+			},
+		}
+
+		cc_library_static {
+			name: "libstatic",
+			defaults: ["defaults"],
+		}
+
+		cc_library_shared {
+			name: "libshared",
+			defaults: ["defaults"],
+		}
+
+		cc_library {
+			name: "libboth",
+			defaults: ["defaults"],
+		}
+
+		cc_binary {
+			name: "binary",
+			defaults: ["defaults"],
+		}`)
+
+	pathsToBase := func(paths android.Paths) []string {
+		var ret []string
+		for _, p := range paths {
+			ret = append(ret, p.Base())
+		}
+		return ret
+	}
+
+	shared := ctx.ModuleForTests("libshared", "android_arm64_armv8-a_shared").Rule("ld")
+	if g, w := pathsToBase(shared.Inputs), []string{"foo.o", "baz.o"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("libshared ld rule wanted %q, got %q", w, g)
+	}
+	bothShared := ctx.ModuleForTests("libboth", "android_arm64_armv8-a_shared").Rule("ld")
+	if g, w := pathsToBase(bothShared.Inputs), []string{"foo.o", "baz.o"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("libboth ld rule wanted %q, got %q", w, g)
+	}
+	binary := ctx.ModuleForTests("binary", "android_arm64_armv8-a").Rule("ld")
+	if g, w := pathsToBase(binary.Inputs), []string{"foo.o"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("binary ld rule wanted %q, got %q", w, g)
+	}
+
+	static := ctx.ModuleForTests("libstatic", "android_arm64_armv8-a_static").Rule("ar")
+	if g, w := pathsToBase(static.Inputs), []string{"foo.o", "bar.o"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("libstatic ar rule wanted %q, got %q", w, g)
+	}
+	bothStatic := ctx.ModuleForTests("libboth", "android_arm64_armv8-a_static").Rule("ar")
+	if g, w := pathsToBase(bothStatic.Inputs), []string{"foo.o", "bar.o"}; !reflect.DeepEqual(w, g) {
+		t.Errorf("libboth ar rule wanted %q, got %q", w, g)
+	}
+}
+
+func TestProductVariableDefaults(t *testing.T) {
+	bp := `
+		cc_defaults {
+			name: "libfoo_defaults",
+			srcs: ["foo.c"],
+			cppflags: ["-DFOO"],
+			product_variables: {
+				debuggable: {
+					cppflags: ["-DBAR"],
+				},
+			},
+		}
+
+		cc_library {
+			name: "libfoo",
+			defaults: ["libfoo_defaults"],
+		}
+	`
+
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.Debuggable = BoolPtr(true)
+
+	ctx := CreateTestContext(config)
+	ctx.PreDepsMutators(func(ctx android.RegisterMutatorsContext) {
+		ctx.BottomUp("variable", android.VariableMutator).Parallel()
+	})
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Module().(*Module)
+	if !android.InList("-DBAR", libfoo.flags.Local.CppFlags) {
+		t.Errorf("expected -DBAR in cppflags, got %q", libfoo.flags.Local.CppFlags)
+	}
+}
+
+func TestExcludeLibsConditional(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			vendor_available: true,
+			shared_libs: ["liba", "libb", "libg"],
+			export_shared_lib_headers: ["libb"],
+			static_libs: ["libd"],
+			export_static_lib_headers: ["libd"],
+			arch: {
+				arm: {
+					exclude_shared_libs: ["libb"],
+					exclude_static_libs: ["libd"],
+				},
+			},
+			target: {
+				vendor: {
+					shared_libs: ["libe"],
+				},
+			},
+			product_variables: {
+				malloc_not_svelte: {
+					exclude_shared_libs: ["liba", "libe"],
+				},
+			},
+		}
+
+		cc_library {
+			name: "liba",
+			vendor_available: true,
+		}
+
+		cc_library {
+			name: "libb",
+			vendor_available: true,
+		}
+
+		cc_library {
+			name: "libg",
+			vendor_available: true,
+		}
+
+		cc_library {
+			name: "libd",
+			vendor_available: true,
+		}
+
+		cc_library {
+			name: "libe",
+			vendor_available: true,
+		}
+	`
+
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.DeviceVndkVersion = StringPtr("current")
+	config.TestProductVariables.Platform_vndk_version = StringPtr("VER")
+	config.TestProductVariables.Malloc_not_svelte = BoolPtr(true)
+
+	ctx := CreateTestContext(config)
+	ctx.PreDepsMutators(func(ctx android.RegisterMutatorsContext) {
+		ctx.BottomUp("variable", android.VariableMutator).Parallel()
+	})
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	checkLibs := func(variant string, expected, excluded []string) {
+		t.Helper()
+		libFlags := ctx.ModuleForTests("libfoo", variant).Rule("ld").Args["libFlags"]
+		for _, lib := range expected {
+			if !strings.Contains(libFlags, "/"+lib+".") {
+				t.Errorf("%s: expected %s in libFlags %q", variant, lib, libFlags)
+			}
+		}
+		for _, lib := range excluded {
+			if strings.Contains(libFlags, "/"+lib+".") {
+				t.Errorf("%s: expected %s to be excluded from libFlags %q", variant, lib, libFlags)
+			}
 		}
 	}
-}
 
-func assertMapKeys(t *testing.T, m map[string]string, expected []string) {
-	t.Helper()
-	assertArrayString(t, android.SortedStringKeys(m), expected)
+	checkLibs("android_arm64_armv8-a_shared", []string{"libb", "libg", "libd"}, []string{"liba", "libe"})
+	checkLibs("android_arm_armv7-a-neon_shared", []string{"libg"}, []string{"liba", "libb", "libd", "libe"})
+	checkLibs("android_vendor.VER_arm64_armv8-a_shared", []string{"libb", "libg", "libd"}, []string{"liba", "libe"})
 }
 
-func TestDefaults(t *testing.T) {
-	ctx := testCc(t, `
-		cc_defaults {
-			name: "defaults",
+func TestExcludeLibsStaticSharedAndSoongConfig(t *testing.T) {
+	bp := `
+		soong_config_module_type {
+			name: "acme_cc_library",
+			module_type: "cc_library",
+			config_namespace: "acme",
+			bool_variables: ["no_liba"],
+			properties: ["exclude_shared_libs"],
+		}
+
+		acme_cc_library {
+			name: "libfoo",
 			srcs: ["foo.c"],
+			shared_libs: ["libg"],
 			static: {
-				srcs: ["bar.c"],
+				static_libs: ["libd"],
 			},
 			shared: {
-				srcs: ["baz.c"],
+				shared_libs: ["liba", "libb"],
+				static_libs: ["libd"],
+				export_static_lib_headers: ["libd"],
+			},
+			exclude_static_libs: ["libd"],
+			soong_config_variables: {
+				no_liba: {
+					exclude_shared_libs: ["liba"],
+				},
 			},
 		}
 
-		cc_library_static {
-			name: "libstatic",
-			defaults: ["defaults"],
+		cc_library {
+			name: "liba",
 		}
 
-		cc_library_shared {
-			name: "libshared",
-			defaults: ["defaults"],
+		cc_library {
+			name: "libb",
 		}
 
 		cc_library {
-			name: "libboth",
-			defaults: ["defaults"],
+			name: "libd",
 		}
 
-		cc_binary {
-			name: "binary",
-			defaults: ["defaults"],
-		}`)
-
-	pathsToBase := func(paths android.Paths) []string {
-		var ret []string
-		for _, p := range paths {
-			ret = append(ret, p.Base())
+		cc_library {
+			name: "libg",
 		}
-		return ret
-	}
+	`
 
-	shared := ctx.ModuleForTests("libshared", "android_arm64_armv8-a_shared").Rule("ld")
-	if g, w := pathsToBase(shared.Inputs), []string{"foo.o", "baz.o"}; !reflect.DeepEqual(w, g) {
-		t.Errorf("libshared ld rule wanted %q, got %q", w, g)
-	}
-	bothShared := ctx.ModuleForTests("libboth", "android_arm64_armv8-a_shared").Rule("ld")
-	if g, w := pathsToBase(bothShared.Inputs), []string{"foo.o", "baz.o"}; !reflect.DeepEqual(w, g) {
-		t.Errorf("libboth ld rule wanted %q, got %q", w, g)
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.VendorVars = map[string]map[string]string{
+		"acme": {"no_liba": "true"},
 	}
-	binary := ctx.ModuleForTests("binary", "android_arm64_armv8-a").Rule("ld")
-	if g, w := pathsToBase(binary.Inputs), []string{"foo.o"}; !reflect.DeepEqual(w, g) {
-		t.Errorf("binary ld rule wanted %q, got %q", w, g)
+
+	ctx := CreateTestContext(config)
+	ctx.RegisterModuleType("soong_config_module_type", android.ModuleTypeFactories()["soong_config_module_type"])
+	ctx.Register()
+
+	_, errs := ctx.ParseBlueprintsFiles("Android.bp")
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	deps := func(variant string) []string {
+		var names []string
+		ctx.VisitDirectDeps(ctx.ModuleForTests("libfoo", variant).Module(), func(m blueprint.Module) {
+			names = append(names, m.Name())
+		})
+		return names
 	}
 
-	static := ctx.ModuleForTests("libstatic", "android_arm64_armv8-a_static").Rule("ar")
-	if g, w := pathsToBase(static.Inputs), []string{"foo.o", "bar.o"}; !reflect.DeepEqual(w, g) {
-		t.Errorf("libstatic ar rule wanted %q, got %q", w, g)
+	shared := deps("android_arm64_armv8-a_shared")
+	ensureListContains := func(list []string, lib string) {
+		t.Helper()
+		if !android.InList(lib, list) {
+			t.Errorf("expected %s in deps %q", lib, list)
+		}
 	}
-	bothStatic := ctx.ModuleForTests("libboth", "android_arm64_armv8-a_static").Rule("ar")
-	if g, w := pathsToBase(bothStatic.Inputs), []string{"foo.o", "bar.o"}; !reflect.DeepEqual(w, g) {
-		t.Errorf("libboth ar rule wanted %q, got %q", w, g)
+	ensureListNotContains := func(list []string, lib string) {
+		t.Helper()
+		if android.InList(lib, list) {
+			t.Errorf("expected %s to be excluded from deps %q", lib, list)
+		}
 	}
+
+	// libd is added by both blocks, liba by the shared block and excluded by the soong config
+	// variable.
+	ensureListContains(shared, "libb")
+	ensureListContains(shared, "libg")
+	ensureListNotContains(shared, "liba")
+	ensureListNotContains(shared, "libd")
+
+	static := deps("android_arm64_armv8-a_static")
+	ensureListContains(static, "libg")
+	ensureListNotContains(static, "libd")
 }
 
-func TestProductVariableDefaults(t *testing.T) {
-	bp := `
-		cc_defaults {
-			name: "libfoo_defaults",
+func TestExcludeSystemSharedLibs(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library {
+			name: "libfoo",
 			srcs: ["foo.c"],
-			cppflags: ["-DFOO"],
-			product_variables: {
-				debuggable: {
-					cppflags: ["-DBAR"],
+			exclude_system_shared_libs: ["libm"],
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["foo.c"],
+			system_shared_libs: ["libc", "libdl"],
+			arch: {
+				arm: {
+					exclude_system_shared_libs: ["libdl"],
 				},
 			},
 		}
+	`)
+
+	checkLibs := func(module, variant string, expected, excluded []string) {
+		t.Helper()
+		libFlags := ctx.ModuleForTests(module, variant).Rule("ld").Args["libFlags"]
+		for _, lib := range expected {
+			if !strings.Contains(libFlags, "/"+lib+".") {
+				t.Errorf("%s %s: expected %s in libFlags %q", module, variant, lib, libFlags)
+			}
+		}
+		for _, lib := range excluded {
+			if strings.Contains(libFlags, "/"+lib+".") {
+				t.Errorf("%s %s: expected %s to be excluded from libFlags %q", module, variant, lib, libFlags)
+			}
+		}
+	}
 
+	checkLibs("libfoo", "android_arm64_armv8-a_shared", []string{"libc", "libdl"}, []string{"libm"})
+	checkLibs("libbar", "android_arm64_armv8-a_shared", []string{"libc", "libdl"}, []string{"libm"})
+	checkLibs("libbar", "android_arm_armv7-a-neon_shared", []string{"libc"}, []string{"libm", "libdl"})
+}
+
+func TestExcludeSystemSharedLibsErrors(t *testing.T) {
+	testCcError(t, `"libz" is not in system_shared_libs`, `
 		cc_library {
 			name: "libfoo",
-			defaults: ["libfoo_defaults"],
+			srcs: ["foo.c"],
+			exclude_system_shared_libs: ["libz"],
 		}
-	`
+	`)
 
-	config := TestConfig(buildDir, android.Android, nil, bp, nil)
-	config.TestProductVariables.Debuggable = BoolPtr(true)
+	testCcError(t, `exclude_system_shared_libs: cannot be used with allow_undefined_symbols`, `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			exclude_system_shared_libs: ["libm"],
+			allow_undefined_symbols: true,
+		}
+	`)
+}
 
-	ctx := CreateTestContext(config)
-	ctx.PreDepsMutators(func(ctx android.RegisterMutatorsContext) {
-		ctx.BottomUp("variable", android.VariableMutator).Parallel()
-	})
-	ctx.Register()
+func TestExcludeSystemSharedLibsStatic(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library_static {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			exclude_system_shared_libs: ["libm"],
+		}
 
-	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
-	android.FailIfErrored(t, errs)
-	_, errs = ctx.PrepareBuildActions(config)
-	android.FailIfErrored(t, errs)
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			static_libs: ["libfoo"],
+		}
+	`)
 
-	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Module().(*Module)
-	if !android.InList("-DBAR", libfoo.flags.Local.CppFlags) {
-		t.Errorf("expected -DBAR in cppflags, got %q", libfoo.flags.Local.CppFlags)
+	// The static library only drops the excluded library from its compile time dependencies.
+	ctx.VisitDirectDeps(ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Module(),
+		func(dep blueprint.Module) {
+			if name := ctx.ModuleName(dep); name == "libm" {
+				t.Errorf("expected libfoo not to depend on libm")
+			}
+		})
+
+	// The module that links the static library resolves its symbols against its own
+	// system_shared_libs.
+	libFlags := ctx.ModuleForTests("foo", "android_arm64_armv8-a").Rule("ld").Args["libFlags"]
+	if !strings.Contains(libFlags, "/libm.") {
+		t.Errorf("expected libm in the libFlags of foo, got %q", libFlags)
 	}
 }
 
@@ -4445,3 +5623,202 @@ func TestAidlFlagsPassedToTheAidlCompiler(t *testing.T) {
 		t.Errorf("aidl command %q does not contain %q", aidlCommand, expectedAidlFlag)
 	}
 }
+
+func TestAidlStrictImports(t *testing.T) {
+	ctx := testCc(t, `
+		cc_library {
+			name: "libfoo",
+			srcs: ["a/Foo.aidl"],
+			aidl: {
+				local_include_dirs: ["a"],
+				strict_imports: true,
+			},
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["a/Foo.aidl"],
+		}
+	`)
+
+	check := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_static").Rule("aidl_strict_imports")
+	for _, expected := range []string{"-I a ", "a/Foo.aidl"} {
+		if !strings.Contains(check.RuleParams.Command, expected) {
+			t.Errorf("strict imports check %q does not contain %q", check.RuleParams.Command, expected)
+		}
+	}
+
+	if check := ctx.ModuleForTests("libbar", "android_arm64_armv8-a_static").MaybeRule("aidl_strict_imports"); check.Rule != nil {
+		t.Errorf("expected no strict imports check for libbar")
+	}
+
+	testCcError(t, `aidl.include_dirs: not allowed with aidl.strict_imports`, `
+		cc_library {
+			name: "libfoo",
+			srcs: ["a/Foo.aidl"],
+			aidl: {
+				include_dirs: ["frameworks/base/core/java"],
+				strict_imports: true,
+			},
+		}
+	`)
+}
+
+func TestDepsAudit(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.S"],
+			shared_libs: ["libbar"],
+			header_libs: ["libbar_headers"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			export_include_dirs: ["include/libbar"],
+			srcs: ["foo.c"],
+		}
+
+		cc_library_headers {
+			name: "libbar_headers",
+			export_include_dirs: ["include/libbar_headers"],
+		}`
+	config := TestConfig(buildDir, android.Android, map[string]string{
+		"SOONG_DEPS_AUDIT": "true",
+	}, bp, nil)
+	ctx := testCcWithConfig(t, config)
+
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+
+	libfoo.Output("obj/foo.hdeps")
+	if rule := libfoo.MaybeOutput("obj/bar.hdeps").Rule; rule != nil {
+		t.Errorf("expected no header list for assembly sources")
+	}
+
+	declared := android.ContentFromFileRuleForTests(t, libfoo.Output("deps_audit/declared.txt"))
+	for _, w := range []string{
+		"shared_libs libbar include/libbar\n",
+		"header_libs libbar_headers include/libbar_headers\n",
+	} {
+		if !strings.Contains(declared, w) {
+			t.Errorf("expected %q in declared dependencies, got %q", w, declared)
+		}
+	}
+
+	report := libfoo.Output("deps_audit/report.txt")
+	if g, w := report.Inputs.Strings(), []string{libfoo.Output("obj/foo.hdeps").Output.String()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected report inputs %q, got %q", w, g)
+	}
+
+	ctx.SingletonForTests("cc_deps_audit").Output("cc_deps_audit.txt")
+}
+
+func TestVersionedNdkHeadersValidation(t *testing.T) {
+	bp := `
+		versioned_ndk_headers {
+			name: "libfoo_headers",
+			from: "include",
+			to: "",
+			license: "NOTICE",
+			platform_dir: "platform",
+		}
+
+		versioned_ndk_headers {
+			name: "libbar_headers",
+			from: "bar",
+			to: "",
+			license: "NOTICE",
+		}
+	`
+	config := TestConfig(buildDir, android.Android, nil, bp, map[string][]byte{
+		"include/foo.h":                        nil,
+		"bar/bar.h":                            nil,
+		"NOTICE":                               nil,
+		"platform/arm64/libc.so.functions.txt": nil,
+		"bionic/libc/versioner-dependencies/common/a": nil,
+	})
+	ctx := CreateTestContext(config)
+	ctx.RegisterModuleType("versioned_ndk_headers", versionedNdkHeadersFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	foo := ctx.ModuleForTests("libfoo_headers", "")
+	validate := foo.Rule("validateBionicHeaders")
+	if g, w := validate.Args["platformDir"], "platform"; g != w {
+		t.Errorf("expected platform dir %q, got %q", w, g)
+	}
+	for _, implicit := range []string{
+		"include/foo.h",
+		"platform/arm64/libc.so.functions.txt",
+		"bionic/libc/versioner-dependencies/common/a",
+	} {
+		if !android.InList(implicit, validate.Implicits.Strings()) {
+			t.Errorf("expected %q in the validation implicits %q", implicit, validate.Implicits)
+		}
+	}
+
+	preprocess := foo.Rule("versionBionicHeaders")
+	if !android.InList(validate.Output.String(), preprocess.Validations.Strings()) {
+		t.Errorf("expected the preprocessing to be validated by %q, got %q", validate.Output, preprocess.Validations)
+	}
+	if g, w := preprocess.Args["depsPath"], validate.Args["depsPath"]; g != w {
+		t.Errorf("expected the validation to use the versioner dependencies %q, got %q", g, w)
+	}
+
+	bar := ctx.ModuleForTests("libbar_headers", "")
+	if bar.MaybeRule("validateBionicHeaders").Rule != nil {
+		t.Errorf("expected no validation without platform_dir")
+	}
+	if v := bar.Rule("versionBionicHeaders").Validations; len(v) != 0 {
+		t.Errorf("expected no validation without platform_dir, got %q", v)
+	}
+}
+
+func TestNdkSysrootZip(t *testing.T) {
+	bp := `
+		ndk_headers {
+			name: "libfoo_headers",
+			from: "include",
+			to: "foo",
+			srcs: ["include/foo.h"],
+			license: "NOTICE",
+		}
+	`
+	config := TestConfig(buildDir, android.Android, nil, bp, map[string][]byte{
+		"include/foo.h": nil,
+		"NOTICE":        nil,
+	})
+	ctx := CreateTestContext(config)
+	ctx.RegisterModuleType("ndk_headers", ndkHeadersFactory)
+	ctx.RegisterSingletonType("ndk", NdkSingleton)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	ndk := ctx.SingletonForTests("ndk")
+
+	manifest := android.ContentFromFileRuleForTests(t, ndk.Output("ndk/sysroot.manifest"))
+	if g, w := manifest, "NOTICE\nsysroot/usr/include/foo/foo.h\n"; g != w {
+		t.Errorf("expected manifest %q, got %q", w, g)
+	}
+
+	zip := ndk.Output("ndk/sysroot.zip")
+	if !strings.Contains(zip.RuleParams.Command, "-C "+buildDir+"/ndk ") {
+		t.Errorf("expected the sysroot to be zipped relative to the NDK install directory, got %q",
+			zip.RuleParams.Command)
+	}
+	var inputs []string
+	for _, input := range zip.Inputs {
+		inputs = append(inputs, input.Rel())
+	}
+	if g, w := inputs, []string{"sysroot/usr/include/foo/foo.h", "NOTICE"}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected zip inputs %q, got %q", w, g)
+	}
+}
@@ -43,6 +43,13 @@ type BaseCompilerProperties struct {
 	// This is most useful in the arch/multilib variants to remove non-common files
 	Exclude_srcs []string `android:"path,arch_variant"`
 
+	// how static libraries (.a files) in srcs, e.g. the per-arch outputs of a cc_genrule, are
+	// linked into binaries and shared libraries. "first" (the default) links them before the
+	// libraries in static_libs so that they can use symbols from those libraries, "last" links
+	// them after static_libs, and "whole" links them as if they were listed in whole_static_libs.
+	// Static libraries always merge them into the archive.
+	Srcs_static_libs_link *string `android:"arch_variant"`
+
 	// list of module-specific flags that will be used for C and C++ compiles.
 	Cflags []string `android:"arch_variant"`
 
@@ -126,6 +133,11 @@ type BaseCompilerProperties struct {
 
 		// list of flags that will be passed to the AIDL compiler
 		Flags []string
+
+		// if true, the imports of aidl sources must resolve to the aidl sources of this module or
+		// to a file in aidl.local_include_dirs.  An import that would only resolve through the
+		// directory of the module is reported, and aidl.include_dirs is not allowed.
+		Strict_imports *bool
 	}
 
 	Renderscript struct {
@@ -230,6 +242,9 @@ type baseCompiler struct {
 	// other modules and filegroups. May include source files that have not yet been translated to
 	// C/C++ (.aidl, .proto, etc.)
 	srcsBeforeGen android.Paths
+
+	// Static libraries that were passed in srcs, to be linked instead of compiled.
+	staticLibSrcs android.Paths
 }
 
 var _ compiler = (*baseCompiler)(nil)
@@ -295,6 +310,7 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 
 	compiler.srcsBeforeGen = android.PathsForModuleSrcExcludes(ctx, compiler.Properties.Srcs, compiler.Properties.Exclude_srcs)
 	compiler.srcsBeforeGen = append(compiler.srcsBeforeGen, deps.GeneratedSources...)
+	compiler.srcsBeforeGen, compiler.staticLibSrcs = splitStaticLibSrcs(compiler.srcsBeforeGen)
 
 	CheckBadCompilerFlags(ctx, "cflags", compiler.Properties.Cflags)
 	CheckBadCompilerFlags(ctx, "cppflags", compiler.Properties.Cppflags)
@@ -323,7 +339,7 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 		flags.Local.CommonFlags = append(flags.Local.CommonFlags, f)
 		flags.Local.YasmFlags = append(flags.Local.YasmFlags, f)
 	}
-	rootIncludeDirs := android.PathsForSource(ctx, compiler.Properties.Include_dirs)
+	rootIncludeDirs := android.PathsForSandboxedSource(ctx, "include_dirs", compiler.Properties.Include_dirs)
 	if len(rootIncludeDirs) > 0 {
 		f := includeDirsToFlags(rootIncludeDirs)
 		flags.Local.CommonFlags = append(flags.Local.CommonFlags, f)
@@ -528,9 +544,16 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 		if len(compiler.Properties.Aidl.Local_include_dirs) > 0 {
 			localAidlIncludeDirs := android.PathsForModuleSrc(ctx, compiler.Properties.Aidl.Local_include_dirs)
 			flags.aidlFlags = append(flags.aidlFlags, includeDirsToFlags(localAidlIncludeDirs))
+			flags.aidlStrictImportDirs = localAidlIncludeDirs
+		}
+		if Bool(compiler.Properties.Aidl.Strict_imports) {
+			flags.aidlStrictImports = true
+			if len(compiler.Properties.Aidl.Include_dirs) > 0 {
+				ctx.PropertyErrorf("aidl.include_dirs", "not allowed with aidl.strict_imports")
+			}
 		}
 		if len(compiler.Properties.Aidl.Include_dirs) > 0 {
-			rootAidlIncludeDirs := android.PathsForSource(ctx, compiler.Properties.Aidl.Include_dirs)
+			rootAidlIncludeDirs := android.PathsForSandboxedSource(ctx, "aidl.include_dirs", compiler.Properties.Aidl.Include_dirs)
 			flags.aidlFlags = append(flags.aidlFlags, includeDirsToFlags(rootAidlIncludeDirs))
 		}
 
@@ -640,6 +663,7 @@ func (compiler *baseCompiler) compile(ctx ModuleContext, flags Flags, deps PathD
 
 	// Compile files listed in c.Properties.Srcs into objects
 	objs := compileObjs(ctx, buildFlags, "", srcs, pathDeps, compiler.cFlagsDeps)
+	objs.staticLibSrcs = append(objs.staticLibSrcs, compiler.staticLibSrcs...)
 
 	if ctx.Failed() {
 		return Objects{}
@@ -648,6 +672,49 @@ func (compiler *baseCompiler) compile(ctx ModuleContext, flags Flags, deps PathD
 	return objs
 }
 
+// splitStaticLibSrcs separates the static libraries from the files that need to be compiled.
+func splitStaticLibSrcs(srcs android.Paths) (compiled, staticLibs android.Paths) {
+	for _, src := range srcs {
+		if src.Ext() == staticLibraryExtension {
+			staticLibs = append(staticLibs, src)
+		} else {
+			compiled = append(compiled, src)
+		}
+	}
+	return compiled, staticLibs
+}
+
+// linkStaticLibSrcs adds the static libraries that were passed in srcs to the libraries to link,
+// at the position selected by srcs_static_libs_link.
+func (compiler *baseCompiler) linkStaticLibSrcs(ctx ModuleContext, deps PathDeps, staticLibSrcs android.Paths) PathDeps {
+	link := String(compiler.Properties.Srcs_static_libs_link)
+	switch link {
+	case "", "first", "last", "whole":
+	default:
+		ctx.PropertyErrorf("srcs_static_libs_link", "unknown value %q, expected \"first\", \"last\" or \"whole\"", link)
+		return deps
+	}
+
+	if len(staticLibSrcs) == 0 {
+		return deps
+	}
+
+	switch {
+	case ctx.object():
+		ctx.PropertyErrorf("srcs", "static libraries are not supported in the srcs of cc_object modules: %s",
+			staticLibSrcs.Strings())
+	case ctx.static() && !ctx.binary():
+		deps.WholeStaticLibsFromPrebuilts = append(deps.WholeStaticLibsFromPrebuilts, staticLibSrcs...)
+	case link == "whole":
+		deps.WholeStaticLibs = append(deps.WholeStaticLibs, staticLibSrcs...)
+	case link == "last":
+		deps.StaticLibs = append(deps.StaticLibs, staticLibSrcs...)
+	default:
+		deps.StaticLibs = append(append(android.Paths{}, staticLibSrcs...), deps.StaticLibs...)
+	}
+	return deps
+}
+
 // Compile a list of source files into objects a specified subdirectory
 func compileObjs(ctx android.ModuleContext, flags builderFlags,
 	subdir string, srcFiles, pathDeps android.Paths, cFlagsDeps android.Paths) Objects {
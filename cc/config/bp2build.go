@@ -0,0 +1,183 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"android/soong/android"
+)
+
+// Variables of the cc toolchain that are exported to Bazel, so that Bazel's cc toolchain
+// configuration compiles with the same flags as Soong instead of a copy of them.
+var (
+	exportedStringVars          = map[string]string{}
+	exportedStringListVars      = map[string][]string{}
+	exportedConfigDependingVars = map[string]func(android.Config) []string{}
+)
+
+// ExportString exports a string variable of the cc toolchain to Bazel.
+func ExportString(name string, value string) {
+	exportedStringVars[name] = value
+}
+
+// ExportStringList exports a string list variable of the cc toolchain, such as a list of flags, to
+// Bazel. An element of the list may be a reference like "${ClangExtraCflags}" to another exported
+// string list variable, which is expanded in place for Bazel.
+func ExportStringList(name string, value []string) {
+	exportedStringListVars[name] = value
+}
+
+// exportStringListStaticVariable declares a static ninja variable with the joined list and exports
+// the list to Bazel.
+func exportStringListStaticVariable(name string, value []string) {
+	pctx.StaticVariable(name, strings.Join(value, " "))
+	ExportStringList(name, value)
+}
+
+// exportStringListVariableFunc declares a ninja variable with the joined list returned by f for the
+// config and exports the same list to Bazel, so that the ninja and Bazel flags cannot diverge.
+func exportStringListVariableFunc(name string, f func(android.Config) []string) {
+	pctx.VariableFunc(name, func(ctx android.PackageVarContext) string {
+		return strings.Join(f(ctx.Config()), " ")
+	})
+	exportedConfigDependingVars[name] = f
+}
+
+// The toolchains whose clang target triples are exported to Bazel, by os and arch.  Only the
+// triples are exported, the per-arch cflags and ldflags of the toolchains are not.
+var bazelToolchains = map[string]Toolchain{
+	"android_arm":         &toolchainArm{},
+	"android_arm64":       &toolchainArm64{},
+	"android_x86":         &toolchainX86{},
+	"android_x86_64":      &toolchainX86_64{},
+	"darwin_x86_64":       &toolchainDarwin{},
+	"fuchsia_arm64":       &toolchainFuchsiaArm64{},
+	"fuchsia_x86_64":      &toolchainFuchsiaX8664{},
+	"linux_bionic_arm64":  &toolchainLinuxArm64{},
+	"linux_bionic_x86_64": &toolchainLinuxBionic{},
+	"linux_glibc_x86":     &toolchainLinuxX86{},
+	"linux_glibc_x86_64":  &toolchainLinuxX8664{},
+	"windows_x86":         &toolchainWindowsX86{},
+	"windows_x86_64":      &toolchainWindowsX8664{},
+}
+
+// BazelCcToolchainVars returns the contents of a Starlark file that defines a `constants` struct
+// with the exported variables of the cc toolchain and the clang target triples, for Bazel's cc
+// toolchain configuration to load.
+func BazelCcToolchainVars(config android.Config) (string, error) {
+	stringListVars := make(map[string][]string, len(exportedStringListVars)+len(exportedConfigDependingVars))
+	for name, value := range exportedStringListVars {
+		stringListVars[name] = value
+	}
+	for name, f := range exportedConfigDependingVars {
+		stringListVars[name] = f(config)
+	}
+	stringListVars, err := expandStringListVars(stringListVars)
+	if err != nil {
+		return "", err
+	}
+
+	triples := map[string]string{}
+	for osArch, toolchain := range bazelToolchains {
+		triples[osArch] = toolchain.ClangTriple()
+	}
+	return bazelCcToolchainVars(exportedStringVars, stringListVars, triples), nil
+}
+
+// expandStringListVars returns the string list variables with the references to other string list
+// variables expanded in place, as Bazel cannot expand ninja variables.
+func expandStringListVars(vars map[string][]string) (map[string][]string, error) {
+	var expand func(name string, seen []string) ([]string, error)
+	expand = func(name string, seen []string) ([]string, error) {
+		if android.InList(name, seen) {
+			return nil, fmt.Errorf("cc toolchain variable %q refers to itself through %s",
+				name, strings.Join(seen, ", "))
+		}
+		seen = append(seen, name)
+
+		var ret []string
+		for _, value := range vars[name] {
+			if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
+				ref := strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}")
+				if _, ok := vars[ref]; !ok {
+					return nil, fmt.Errorf("cc toolchain variable %q refers to %q which is not exported to Bazel",
+						name, value)
+				}
+				expanded, err := expand(ref, seen)
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, expanded...)
+			} else if strings.Contains(value, "$") {
+				return nil, fmt.Errorf("cc toolchain variable %q contains %q, only whole elements can refer to other variables",
+					name, value)
+			} else {
+				ret = append(ret, value)
+			}
+		}
+		return ret, nil
+	}
+
+	ret := make(map[string][]string, len(vars))
+	for name := range vars {
+		expanded, err := expand(name, nil)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = expanded
+	}
+	return ret, nil
+}
+
+func bazelCcToolchainVars(stringVars map[string]string, stringListVars map[string][]string,
+	triples map[string]string) string {
+
+	var names []string
+	ret := "# GENERATED FOR BAZEL FROM SOONG. DO NOT EDIT.\n"
+
+	if len(stringVars) > 0 {
+		ret += "\n"
+	}
+	for _, name := range android.SortedStringKeys(stringVars) {
+		ret += fmt.Sprintf("_%s = %q\n", name, stringVars[name])
+		names = append(names, name)
+	}
+
+	for _, name := range android.SortedStringKeys(stringListVars) {
+		ret += fmt.Sprintf("\n_%s = [\n", name)
+		for _, value := range stringListVars[name] {
+			ret += fmt.Sprintf("    %q,\n", value)
+		}
+		ret += "]\n"
+		names = append(names, name)
+	}
+
+	ret += "\n_ClangTriples = {\n"
+	for _, osArch := range android.SortedStringKeys(triples) {
+		ret += fmt.Sprintf("    %q: %q,\n", osArch, triples[osArch])
+	}
+	ret += "}\n"
+	names = append(names, "ClangTriples")
+
+	ret += "\nconstants = struct(\n"
+	for _, name := range names {
+		ret += fmt.Sprintf("    %s = _%s,\n", name, name)
+	}
+	ret += ")\n"
+
+	return ret
+}
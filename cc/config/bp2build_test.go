@@ -0,0 +1,166 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestBazelCcToolchainVars(t *testing.T) {
+	actual := bazelCcToolchainVars(
+		map[string]string{
+			"ClangDefaultVersion": "clang-r123",
+		},
+		map[string][]string{
+			"DeviceGlobalLdflags": {"-Wl,-z,now", "-Wl,--build-id=md5"},
+			"AsanCflags":          {"-fno-omit-frame-pointer"},
+		},
+		map[string]string{
+			"android_arm64": "aarch64-linux-android",
+			"android_arm":   "armv7a-linux-androideabi",
+		})
+
+	expected := `# GENERATED FOR BAZEL FROM SOONG. DO NOT EDIT.
+
+_ClangDefaultVersion = "clang-r123"
+
+_AsanCflags = [
+    "-fno-omit-frame-pointer",
+]
+
+_DeviceGlobalLdflags = [
+    "-Wl,-z,now",
+    "-Wl,--build-id=md5",
+]
+
+_ClangTriples = {
+    "android_arm": "armv7a-linux-androideabi",
+    "android_arm64": "aarch64-linux-android",
+}
+
+constants = struct(
+    ClangDefaultVersion = _ClangDefaultVersion,
+    AsanCflags = _AsanCflags,
+    DeviceGlobalLdflags = _DeviceGlobalLdflags,
+    ClangTriples = _ClangTriples,
+)
+`
+	if actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestExpandStringListVars(t *testing.T) {
+	vars := map[string][]string{
+		"Extra":  {"-extra1", "-extra2"},
+		"Nested": {"-nested", "${Extra}"},
+		"Flags":  {"-a", "${Nested}", "-b"},
+	}
+	expanded, err := expandStringListVars(vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"-a", "-nested", "-extra1", "-extra2", "-b"}
+	if !reflect.DeepEqual(expanded["Flags"], expected) {
+		t.Errorf("expected %q, got %q", expected, expanded["Flags"])
+	}
+
+	for _, tc := range []struct {
+		name  string
+		vars  map[string][]string
+		error string
+	}{
+		{
+			name:  "unknown",
+			vars:  map[string][]string{"Flags": {"${Unknown}"}},
+			error: `cc toolchain variable "Flags" refers to "${Unknown}" which is not exported to Bazel`,
+		},
+		{
+			name:  "partial",
+			vars:  map[string][]string{"Extra": {"-extra"}, "Flags": {"-I${Extra}"}},
+			error: `cc toolchain variable "Flags" contains "-I${Extra}", only whole elements can refer to other variables`,
+		},
+		{
+			name:  "cycle",
+			vars:  map[string][]string{"Flags": {"${Flags}"}},
+			error: `cc toolchain variable "Flags" refers to itself through Flags`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := expandStringListVars(tc.vars)
+			if err == nil || err.Error() != tc.error {
+				t.Errorf("expected error %q, got %v", tc.error, err)
+			}
+		})
+	}
+}
+
+func TestBazelCcToolchainVarsExportsSoongConfig(t *testing.T) {
+	vars, err := BazelCcToolchainVars(android.TestConfig("", nil, "", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`_ClangDefaultVersion = "` + ClangDefaultVersion + `"`,
+		"_DeviceGlobalLdflags = [\n",
+		"_ClangExtraCflags = [\n",
+		`"android_arm64": "aarch64-linux-android",`,
+		`"linux_glibc_x86_64": "x86_64-linux-gnu",`,
+	} {
+		if !strings.Contains(vars, expected) {
+			t.Errorf("expected the cc toolchain variables to contain %q, got:\n%s", expected, vars)
+		}
+	}
+	if strings.Contains(vars, "${") {
+		t.Errorf("expected the cc toolchain variables not to refer to ninja variables, got:\n%s", vars)
+	}
+}
+
+func TestBazelCcToolchainVarsMatchNinja(t *testing.T) {
+	config := android.TestConfig("", map[string]string{"AUTO_PATTERN_INITIALIZE": "true"}, "", nil)
+	vars, err := BazelCcToolchainVars(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The common clang cflags are computed with the config, and include the expanded ClangExtraCflags.
+	start := strings.Index(vars, "_CommonClangGlobalCflags = [\n")
+	if start < 0 {
+		t.Fatalf("expected the cc toolchain variables to contain CommonClangGlobalCflags, got:\n%s", vars)
+	}
+	cflags := vars[start : start+strings.Index(vars[start:], "]\n")]
+	for _, expected := range []string{
+		`"-ftrivial-auto-var-init=pattern",`,
+		`"-D__compiler_offsetof=__builtin_offsetof",`,
+	} {
+		if !strings.Contains(cflags, expected) {
+			t.Errorf("expected CommonClangGlobalCflags to contain %q, got:\n%s", expected, cflags)
+		}
+	}
+	if strings.Contains(cflags, "-ftrivial-auto-var-init=zero") {
+		t.Errorf("expected CommonClangGlobalCflags not to contain the default zero initialization, got:\n%s", cflags)
+	}
+
+	// The ninja variable is computed by the same function.
+	expected := strings.Join(commonClangGlobalCflags(config), " ")
+	if !strings.Contains(expected, "-ftrivial-auto-var-init=pattern ") ||
+		!strings.HasPrefix(expected, strings.Join(ClangFilterUnknownCflags(commonGlobalCflags), " ")+" ${ClangExtraCflags}") {
+		t.Errorf("unexpected CommonClangGlobalCflags ninja value %q", expected)
+	}
+}
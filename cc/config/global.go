@@ -15,8 +15,6 @@
 package config
 
 import (
-	"strings"
-
 	"android/soong/android"
 	"android/soong/remoteexec"
 )
@@ -150,54 +148,56 @@ var (
 
 var pctx = android.NewPackageContext("android/soong/cc/config")
 
+func commonClangGlobalCflags(config android.Config) []string {
+	flags := ClangFilterUnknownCflags(commonGlobalCflags)
+	flags = append(flags, "${ClangExtraCflags}")
+
+	// http://b/131390872
+	// Automatically initialize any uninitialized stack variables.
+	// Prefer zero-init if multiple options are set.
+	if config.IsEnvTrue("AUTO_ZERO_INITIALIZE") {
+		flags = append(flags, "-ftrivial-auto-var-init=zero", "-enable-trivial-auto-var-init-zero-knowing-it-will-be-removed-from-clang")
+	} else if config.IsEnvTrue("AUTO_PATTERN_INITIALIZE") {
+		flags = append(flags, "-ftrivial-auto-var-init=pattern")
+	} else if config.IsEnvTrue("AUTO_UNINITIALIZE") {
+		flags = append(flags, "-ftrivial-auto-var-init=uninitialized")
+	} else {
+		// Default to zero initialization.
+		flags = append(flags, "-ftrivial-auto-var-init=zero", "-enable-trivial-auto-var-init-zero-knowing-it-will-be-removed-from-clang")
+	}
+
+	return flags
+}
+
+func deviceClangGlobalCflags(config android.Config) []string {
+	if config.Fuchsia() {
+		return ClangFilterUnknownCflags(deviceGlobalCflags)
+	} else {
+		return append(ClangFilterUnknownCflags(deviceGlobalCflags), "${ClangExtraTargetCflags}")
+	}
+}
+
 func init() {
 	if android.BuildOs == android.Linux {
 		commonGlobalCflags = append(commonGlobalCflags, "-fdebug-prefix-map=/proc/self/cwd=")
 	}
 
-	pctx.StaticVariable("CommonGlobalConlyflags", strings.Join(commonGlobalConlyflags, " "))
-	pctx.StaticVariable("DeviceGlobalCppflags", strings.Join(deviceGlobalCppflags, " "))
-	pctx.StaticVariable("DeviceGlobalLdflags", strings.Join(deviceGlobalLdflags, " "))
-	pctx.StaticVariable("DeviceGlobalLldflags", strings.Join(deviceGlobalLldflags, " "))
-	pctx.StaticVariable("HostGlobalCppflags", strings.Join(hostGlobalCppflags, " "))
-	pctx.StaticVariable("HostGlobalLdflags", strings.Join(hostGlobalLdflags, " "))
-	pctx.StaticVariable("HostGlobalLldflags", strings.Join(hostGlobalLldflags, " "))
-
-	pctx.VariableFunc("CommonClangGlobalCflags", func(ctx android.PackageVarContext) string {
-		flags := ClangFilterUnknownCflags(commonGlobalCflags)
-		flags = append(flags, "${ClangExtraCflags}")
-
-		// http://b/131390872
-		// Automatically initialize any uninitialized stack variables.
-		// Prefer zero-init if multiple options are set.
-		if ctx.Config().IsEnvTrue("AUTO_ZERO_INITIALIZE") {
-			flags = append(flags, "-ftrivial-auto-var-init=zero -enable-trivial-auto-var-init-zero-knowing-it-will-be-removed-from-clang")
-		} else if ctx.Config().IsEnvTrue("AUTO_PATTERN_INITIALIZE") {
-			flags = append(flags, "-ftrivial-auto-var-init=pattern")
-		} else if ctx.Config().IsEnvTrue("AUTO_UNINITIALIZE") {
-			flags = append(flags, "-ftrivial-auto-var-init=uninitialized")
-		} else {
-			// Default to zero initialization.
-			flags = append(flags, "-ftrivial-auto-var-init=zero -enable-trivial-auto-var-init-zero-knowing-it-will-be-removed-from-clang")
-		}
+	exportStringListStaticVariable("CommonGlobalConlyflags", commonGlobalConlyflags)
+	exportStringListStaticVariable("DeviceGlobalCppflags", deviceGlobalCppflags)
+	exportStringListStaticVariable("DeviceGlobalLdflags", deviceGlobalLdflags)
+	exportStringListStaticVariable("DeviceGlobalLldflags", deviceGlobalLldflags)
+	exportStringListStaticVariable("HostGlobalCppflags", hostGlobalCppflags)
+	exportStringListStaticVariable("HostGlobalLdflags", hostGlobalLdflags)
+	exportStringListStaticVariable("HostGlobalLldflags", hostGlobalLldflags)
 
-		return strings.Join(flags, " ")
-	})
+	exportStringListVariableFunc("CommonClangGlobalCflags", commonClangGlobalCflags)
+	exportStringListVariableFunc("DeviceClangGlobalCflags", deviceClangGlobalCflags)
+	exportStringListStaticVariable("HostClangGlobalCflags", ClangFilterUnknownCflags(hostGlobalCflags))
+	exportStringListStaticVariable("NoOverrideClangGlobalCflags",
+		append(ClangFilterUnknownCflags(noOverrideGlobalCflags), "${ClangExtraNoOverrideCflags}"))
 
-	pctx.VariableFunc("DeviceClangGlobalCflags", func(ctx android.PackageVarContext) string {
-		if ctx.Config().Fuchsia() {
-			return strings.Join(ClangFilterUnknownCflags(deviceGlobalCflags), " ")
-		} else {
-			return strings.Join(append(ClangFilterUnknownCflags(deviceGlobalCflags), "${ClangExtraTargetCflags}"), " ")
-		}
-	})
-	pctx.StaticVariable("HostClangGlobalCflags",
-		strings.Join(ClangFilterUnknownCflags(hostGlobalCflags), " "))
-	pctx.StaticVariable("NoOverrideClangGlobalCflags",
-		strings.Join(append(ClangFilterUnknownCflags(noOverrideGlobalCflags), "${ClangExtraNoOverrideCflags}"), " "))
-
-	pctx.StaticVariable("CommonClangGlobalCppflags",
-		strings.Join(append(ClangFilterUnknownCflags(commonGlobalCppflags), "${ClangExtraCppflags}"), " "))
+	exportStringListStaticVariable("CommonClangGlobalCppflags",
+		append(ClangFilterUnknownCflags(commonGlobalCppflags), "${ClangExtraCppflags}"))
 
 	pctx.StaticVariable("ClangExternalCflags", "${ClangExtraExternalCflags}")
 
@@ -220,6 +220,12 @@ func init() {
 	pctx.PrefixedExistentPathsForSourcesVariable("CommonNativehelperInclude", "-I",
 		[]string{"libnativehelper/include_jni"})
 
+	ExportString("ClangDefaultBase", ClangDefaultBase)
+	ExportString("ClangDefaultVersion", ClangDefaultVersion)
+	ExportString("ClangDefaultShortVersion", ClangDefaultShortVersion)
+	ExportString("CStdVersion", CStdVersion)
+	ExportString("CppStdVersion", CppStdVersion)
+
 	pctx.SourcePathVariable("ClangDefaultBase", ClangDefaultBase)
 	pctx.VariableFunc("ClangBase", func(ctx android.PackageVarContext) string {
 		if override := ctx.Config().Getenv("LLVM_PREBUILTS_BASE"); override != "" {
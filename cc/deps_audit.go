@@ -0,0 +1,142 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// This file implements an audit mode, enabled by setting SOONG_DEPS_AUDIT=true, that compares
+// the dependencies declared by each cc module with the headers its sources actually include.
+// The headers are read from the dependency files generated by the compiler, so the comparison
+// happens at build time. Each module reports the declared libraries whose exported include
+// directories provided no header, and the headers that were found through include directories
+// that no declared dependency exports (include_dirs, global includes, ...). The reports of all
+// modules are collected in $OUT/soong/cc_deps_audit.txt by the `cc_deps_audit` phony target.  The
+// same mode audits java modules, see java/deps_audit.go.
+
+func init() {
+	android.RegisterSingletonType("cc_deps_audit", ccDepsAuditSingletonFactory)
+
+	pctx.HostBinToolVariable("ccDepsAuditCmd", "cc_deps_audit")
+}
+
+var (
+	// Rule to list the headers included by a source file without compiling it.
+	ccDepsAuditHeaders = pctx.AndroidStaticRule("ccDepsAuditHeaders",
+		blueprint.RuleParams{
+			Command:     "$relPwd $ccCmd -M $cFlags -MF $out $in",
+			CommandDeps: []string{"$ccCmd"},
+		},
+		"ccCmd", "cFlags")
+
+	// Rule to compare the headers used by a module with its declared dependencies.
+	ccDepsAudit = pctx.AndroidStaticRule("ccDepsAudit",
+		blueprint.RuleParams{
+			Command:        "$ccDepsAuditCmd --module $module --declared $declared $allowed -o $out @$out.rsp",
+			CommandDeps:    []string{"$ccDepsAuditCmd"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		},
+		"module", "declared", "allowed")
+)
+
+// depsAuditBuildActions registers the build statement comparing the headers listed in
+// headerDepFiles with the include directories exported by the direct library dependencies of the
+// module, and returns the path to the resulting report.
+func depsAuditBuildActions(ctx ModuleContext, headerDepFiles android.Paths) android.Path {
+	var declared strings.Builder
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		libDepTag, ok := ctx.OtherModuleDependencyTag(dep).(libraryDependencyTag)
+		if !ok || !ctx.OtherModuleHasProvider(dep, FlagExporterInfoProvider) {
+			return
+		}
+		var kind string
+		switch {
+		case libDepTag.header():
+			kind = "header_libs"
+		case libDepTag.shared():
+			kind = "shared_libs"
+		case libDepTag.static():
+			kind = "static_libs"
+		default:
+			return
+		}
+		exporterInfo := ctx.OtherModuleProvider(dep, FlagExporterInfoProvider).(FlagExporterInfo)
+		fields := []string{kind, ctx.OtherModuleName(dep)}
+		fields = append(fields, exporterInfo.IncludeDirs.Strings()...)
+		fields = append(fields, exporterInfo.SystemIncludeDirs.Strings()...)
+		declared.WriteString(strings.Join(fields, " "))
+		declared.WriteString("\n")
+	})
+
+	declaredFile := android.PathForModuleOut(ctx, "deps_audit", "declared.txt")
+	android.WriteFileRule(ctx, declaredFile, declared.String())
+
+	// Headers of the module itself, generated headers and the compiler's builtin headers never
+	// need a declared dependency.
+	allowed := []string{
+		ctx.ModuleDir(),
+		android.PathForOutput(ctx).String(),
+		"${config.ClangBase}",
+	}
+
+	report := android.PathForModuleOut(ctx, "deps_audit", "report.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        ccDepsAudit,
+		Description: "audit header dependencies",
+		Output:      report,
+		Inputs:      headerDepFiles,
+		Implicit:    declaredFile,
+		Args: map[string]string{
+			"module":   ctx.ModuleName() + ":" + ctx.ModuleSubDir(),
+			"declared": declaredFile.String(),
+			"allowed":  android.JoinWithPrefix(allowed, "--allowed "),
+		},
+	})
+	return report
+}
+
+func ccDepsAuditSingletonFactory() android.Singleton {
+	return &ccDepsAuditSingleton{}
+}
+
+type ccDepsAuditSingleton struct{}
+
+func (s *ccDepsAuditSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !ctx.Config().DepsAuditEnabled() {
+		return
+	}
+
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if m, ok := module.(*Module); ok && m.depsAuditReport.Valid() {
+			reports = append(reports, m.depsAuditReport.Path())
+		}
+	})
+
+	output := android.PathForOutput(ctx, "cc_deps_audit.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        android.Cat,
+		Description: "cc dependency audit report",
+		Output:      output,
+		Inputs:      reports,
+	})
+	ctx.Phony("cc_deps_audit", output)
+}
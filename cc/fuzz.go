@@ -512,7 +512,7 @@ func (s *fuzzPackager) GenerateBuildActions(ctx android.SingletonContext) {
 		if config := fuzzModule.Properties.Fuzz_config; config != nil {
 			if ccModule.Host() && !BoolDefault(config.Fuzz_on_haiku_host, true) {
 				return
-			} else if !BoolDefault(config.Fuzz_on_haiku_device, true) {
+			} else if !ccModule.Host() && !BoolDefault(config.Fuzz_on_haiku_device, true) {
 				return
 			}
 		}
@@ -226,6 +226,9 @@ func genSources(ctx android.ModuleContext, srcFiles android.Paths,
 	var deps android.Paths
 	var rsFiles android.Paths
 
+	// The sources are replaced with the generated files below.
+	origSrcFiles := append(android.Paths(nil), srcFiles...)
+
 	var aidlRule *android.RuleBuilder
 
 	var yaccRule_ *android.RuleBuilder
@@ -284,6 +287,16 @@ func genSources(ctx android.ModuleContext, srcFiles android.Paths,
 	}
 
 	if aidlRule != nil {
+		if buildFlags.aidlStrictImports {
+			var aidlFiles android.Paths
+			for _, srcFile := range origSrcFiles {
+				if srcFile.Ext() == ".aidl" {
+					aidlFiles = append(aidlFiles, srcFile)
+				}
+			}
+			deps = append(deps, android.AidlStrictImportsCheck(ctx, aidlFiles, android.OptionalPath{},
+				buildFlags.aidlStrictImportDirs))
+		}
 		aidlRule.Build("aidl", "gen aidl")
 	}
 
@@ -16,6 +16,7 @@ package cc
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -115,3 +116,123 @@ func TestLibraryGenruleCmd(t *testing.T) {
 		t.Errorf(`want inputs %v, got %v`, expected, got)
 	}
 }
+
+func TestGenruleStaticLibSrcs(t *testing.T) {
+	bp := `
+		cc_genrule {
+			name: "gen",
+			tool_files: ["tool"],
+			cmd: "$(location tool) $(out)",
+			arch: {
+				arm: {
+					out: ["gen_arm.o", "libgen_arm.a"],
+				},
+				arm64: {
+					out: ["gen_arm64.o", "libgen_arm64.a"],
+				},
+			},
+		}
+
+		cc_library_static {
+			name: "libdep",
+		}
+
+		cc_binary {
+			name: "bin",
+			srcs: ["foo.c", ":gen"],
+			static_libs: ["libdep"],
+			compile_multilib: "both",
+		}
+
+		cc_binary {
+			name: "bin_last",
+			srcs: [":gen"],
+			static_libs: ["libdep"],
+			srcs_static_libs_link: "last",
+		}
+
+		cc_binary {
+			name: "bin_whole",
+			srcs: [":gen"],
+			srcs_static_libs_link: "whole",
+		}
+
+		cc_library {
+			name: "libboth",
+			srcs: [":gen"],
+		}
+		`
+	ctx := testCc(t, bp)
+
+	baseNames := func(paths android.Paths) []string {
+		var ret []string
+		for _, p := range paths {
+			ret = append(ret, p.Base())
+		}
+		return ret
+	}
+
+	libFlags := func(module, variant string) string {
+		t.Helper()
+		return ctx.ModuleForTests(module, variant).Rule("ld").Args["libFlags"]
+	}
+
+	for _, arch := range []struct{ variant, name string }{
+		{"android_arm64_armv8-a", "arm64"},
+		{"android_arm_armv7-a-neon", "arm"},
+	} {
+		ld := ctx.ModuleForTests("bin", arch.variant).Rule("ld")
+		if g, w := baseNames(ld.Inputs), []string{"foo.o", "gen_" + arch.name + ".o"}; !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: want objects %v, got %v", arch.name, w, g)
+		}
+		genLib := "libgen_" + arch.name + ".a"
+		flags := libFlags("bin", arch.variant)
+		if i, j := strings.Index(flags, genLib), strings.Index(flags, "libdep.a"); i < 0 || j < 0 || i > j {
+			t.Errorf("%s: want %s linked before libdep.a, got %q", arch.name, genLib, flags)
+		}
+	}
+
+	flags := libFlags("bin_last", "android_arm64_armv8-a")
+	if i, j := strings.Index(flags, "libgen_arm64.a"), strings.Index(flags, "libdep.a"); i < 0 || j < 0 || i < j {
+		t.Errorf("want libgen_arm64.a linked after libdep.a, got %q", flags)
+	}
+
+	flags = libFlags("bin_whole", "android_arm64_armv8-a")
+	if i, j := strings.Index(flags, "-Wl,--whole-archive"), strings.Index(flags, "libgen_arm64.a"); i < 0 || j < i {
+		t.Errorf("want libgen_arm64.a linked as a whole archive, got %q", flags)
+	}
+
+	static := ctx.ModuleForTests("libboth", "android_arm64_armv8-a_static").Output("libboth.a")
+	if !android.InList("libgen_arm64.a", baseNames(static.Inputs)) {
+		t.Errorf("want libgen_arm64.a merged into libboth.a, got inputs %v", static.Inputs)
+	}
+
+	shared := libFlags("libboth", "android_arm64_armv8-a_shared")
+	if !strings.Contains(shared, "libgen_arm64.a") {
+		t.Errorf("want libgen_arm64.a linked into libboth.so, got %q", shared)
+	}
+}
+
+func TestGenruleStaticLibSrcsErrors(t *testing.T) {
+	testCcError(t, `srcs_static_libs_link: unknown value "early"`, `
+		cc_binary {
+			name: "bin",
+			srcs: ["foo.c"],
+			srcs_static_libs_link: "early",
+		}
+	`)
+
+	testCcError(t, `srcs: static libraries are not supported in the srcs of cc_object modules`, `
+		cc_genrule {
+			name: "gen",
+			tool_files: ["tool"],
+			cmd: "$(location tool) $(out)",
+			out: ["libgen.a"],
+		}
+
+		cc_object {
+			name: "obj",
+			srcs: [":gen"],
+		}
+	`)
+}
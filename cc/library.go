@@ -26,6 +26,7 @@ import (
 	"github.com/google/blueprint/pathtools"
 
 	"android/soong/android"
+	"android/soong/bazel"
 	"android/soong/cc/config"
 )
 
@@ -65,6 +66,12 @@ type LibraryProperties struct {
 
 		// List versions to generate stubs libs for.
 		Versions []string
+
+		// If true, only modules in an APEX that this library is available to may link against
+		// its implementation. All other modules, including platform modules when the library
+		// is also available to the platform, are linked against the stubs, and linking the
+		// library statically from them is an error.
+		Restrict_implementation *bool
 	}
 
 	// set the name of the output
@@ -105,6 +112,15 @@ type LibraryProperties struct {
 		// Run checks on all APIs (in addition to the ones referred by
 		// one of exported ELF symbols.)
 		Check_all_apis *bool
+
+		// Extra flags passed to header-abi-diff
+		Diff_flags []string
+
+		// Path to a directory, relative to the module directory, containing the reference ABI
+		// dumps of this library in <arch>_<arch variant>/<name>.so.lsdump files.  When set, the
+		// library is diffed against the reference dump in this directory instead of the one in
+		// prebuilts/abi-dumps, and a missing reference dump fails the build.
+		Ref_dump_dir *string
 	}
 
 	// Order symbols in .bss section by their sizes.  Only useful for shared libraries.
@@ -119,6 +135,10 @@ type LibraryProperties struct {
 	// If this is an LLNDK library, properties to describe the LLNDK stubs.  Will be copied from
 	// the module pointed to by llndk_stubs if it is set.
 	Llndk llndkLibraryProperties
+
+	// In mixed builds, the static and shared variants of the library are taken from the outputs
+	// of this Bazel target instead of being compiled by Soong.
+	bazel.Properties
 }
 
 // StaticProperties is a properties stanza to affect only attributes of the "static" variants of a
@@ -516,6 +536,7 @@ func (library *libraryDecorator) linkerProps() []interface{} {
 // shared library).
 func (library *libraryDecorator) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 	flags = library.baseLinker.linkerFlags(ctx, flags)
+	library.stripper.CheckProperties(ctx)
 
 	// MinGW spits out warnings about -fPIC even for -fpie?!) being ignored because
 	// all code is position independent, and then those warnings get promoted to
@@ -632,6 +653,10 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 		return objs
 	}
 	if library.buildStubs() {
+		if library.Properties.Stubs.Symbol_file == nil {
+			ctx.PropertyErrorf("stubs.symbol_file", "must be set when stubs.versions is set")
+			return Objects{}
+		}
 		objs, versionScript := compileStubLibrary(ctx, flags, String(library.Properties.Stubs.Symbol_file), library.MutatedProperties.StubsVersion, "--apex")
 		library.versionScriptPath = android.OptionalPathForPath(versionScript)
 		return objs
@@ -853,6 +878,8 @@ func (library *libraryDecorator) linkerDeps(ctx DepsContext, deps Deps) Deps {
 		deps.ReexportSharedLibHeaders = append(deps.ReexportSharedLibHeaders, library.SharedProperties.Shared.Export_shared_lib_headers...)
 		deps.ReexportStaticLibHeaders = append(deps.ReexportStaticLibHeaders, library.SharedProperties.Shared.Export_static_lib_headers...)
 	}
+	// The static: and shared: blocks may add libs that are excluded too.
+	deps = library.baseLinker.excludeLibs(deps)
 	if ctx.inVendor() {
 		deps.WholeStaticLibs = removeListFromList(deps.WholeStaticLibs, library.baseLinker.Properties.Target.Vendor.Exclude_static_libs)
 		deps.SharedLibs = removeListFromList(deps.SharedLibs, library.baseLinker.Properties.Target.Vendor.Exclude_shared_libs)
@@ -1042,6 +1069,9 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 		library.stripper.StripExecutableOrSharedLib(ctx, outputFile, strippedOutputFile, stripFlags)
 	}
 	library.unstrippedOutputFile = outputFile
+	if !library.buildStubs() {
+		library.buildSizeReport(ctx, outputFile, fileName)
+	}
 
 	outputFile = maybeInjectBoringSSLHash(ctx, outputFile, library.Properties.Inject_bssl_hash, fileName)
 
@@ -1138,6 +1168,10 @@ func (library *libraryDecorator) unstrippedOutputFilePath() android.Path {
 	return library.unstrippedOutputFile
 }
 
+func (library *libraryDecorator) overrides() []string {
+	return library.Properties.Overrides
+}
+
 func (library *libraryDecorator) disableStripping() {
 	library.stripper.StripProperties.Strip.None = BoolPtr(true)
 }
@@ -1204,16 +1238,52 @@ func (library *libraryDecorator) linkSAbiDumpFiles(ctx ModuleContext, objs Objec
 
 		addLsdumpPath(classifySourceAbiDump(ctx) + ":" + library.sAbiOutputFile.String())
 
-		refAbiDumpFile := getRefAbiDumpFile(ctx, vndkVersion, fileName)
+		var refAbiDumpFile android.Path
+		updateCommand := ""
+		if refDumpDir := library.Properties.Header_abi_checker.Ref_dump_dir; refDumpDir != nil {
+			// The reference dump is checked in next to the library, so it is updated by copying
+			// the dump of the library over it.
+			refAbiDumpPath := refAbiDumpPathInDir(ctx, String(refDumpDir), fileName)
+			updateCommand = "cp " + library.sAbiOutputFile.String() + " " + refAbiDumpPath
+			if ref := android.ExistentPathForSource(ctx, refAbiDumpPath); ref.Valid() {
+				refAbiDumpFile = ref.Path()
+			} else {
+				sAbiDiff := android.PathForModuleOut(ctx, fileName+".abidiff")
+				ctx.Build(pctx, android.BuildParams{
+					Rule:        android.ErrorRule,
+					Description: "header-abi-diff " + sAbiDiff.Base(),
+					Output:      sAbiDiff,
+					Args: map[string]string{
+						"error": "error: Missing ABI reference " + refAbiDumpPath +
+							", please create it with: " + updateCommand,
+					},
+				})
+				library.sAbiDiff = android.OptionalPathForPath(sAbiDiff)
+				return
+			}
+		} else {
+			refAbiDumpFile = getRefAbiDumpFile(ctx, vndkVersion, fileName)
+		}
 		if refAbiDumpFile != nil {
 			library.sAbiDiff = sourceAbiDiff(ctx, library.sAbiOutputFile.Path(),
-				refAbiDumpFile, fileName, exportedHeaderFlags,
+				refAbiDumpFile, fileName, exportedHeaderFlags, updateCommand,
+				library.Properties.Header_abi_checker.Diff_flags,
 				Bool(library.Properties.Header_abi_checker.Check_all_apis),
 				ctx.IsLlndk(), ctx.isNdk(ctx.Config()), ctx.IsVndkExt())
 		}
 	}
 }
 
+// refAbiDumpPathInDir returns the path of the reference ABI dump of the current variant in the
+// ref_dump_dir of the module.
+func refAbiDumpPathInDir(ctx ModuleContext, refDumpDir, fileName string) string {
+	archNameAndVariant := ctx.Arch().ArchType.String()
+	if ctx.Arch().ArchVariant != "" {
+		archNameAndVariant += "_" + ctx.Arch().ArchVariant
+	}
+	return filepath.Join(ctx.ModuleDir(), refDumpDir, archNameAndVariant, fileName+".lsdump")
+}
+
 func processLLNDKHeaders(ctx ModuleContext, srcHeaderDir string, outDir android.ModuleGenPath) android.Path {
 	srcDir := android.PathForModuleSrc(ctx, srcHeaderDir)
 	srcFiles := ctx.GlobFiles(filepath.Join(srcDir.String(), "**/*.h"), nil)
@@ -1231,7 +1301,7 @@ func processLLNDKHeaders(ctx ModuleContext, srcHeaderDir string, outDir android.
 		installPaths = append(installPaths, outDir.Join(ctx, relHeaderDir, header.Base()))
 	}
 
-	return processHeadersWithVersioner(ctx, srcDir, outDir, srcFiles, installPaths)
+	return processHeadersWithVersioner(ctx, srcDir, outDir, nil, srcFiles, installPaths)
 }
 
 // link registers actions to link this library, and sets various fields
@@ -1348,6 +1418,88 @@ func (library *libraryDecorator) link(ctx ModuleContext,
 	return out
 }
 
+// generateBazelBuildActions implements bazelHandler. It replaces the compile and link actions of
+// the static or shared variant with the matching output of the library's Bazel target, built for
+// the os and arch of the variant. It returns false if the library doesn't set bazel_module, for
+// stubs and APEX variants, or if Bazel hasn't been queried for the target yet, in which case Soong
+// builds the library itself.
+func (library *libraryDecorator) generateBazelBuildActions(ctx ModuleContext, deps PathDeps) (android.Path, bool) {
+	label := library.Properties.Bazel_module.Label
+	if label == "" || !(library.static() || library.shared()) {
+		return nil, false
+	}
+	// The Bazel target builds the implementation library, and is built for the platform, not for
+	// the min_sdk_version of an APEX.
+	if library.buildStubs() || !ctx.isForPlatform() {
+		return nil, false
+	}
+	// The Bazel target is built without the flags of the sanitizer and image variants.
+	m := ctx.Module().(*Module)
+	if (m.sanitize != nil && !m.sanitize.isUnsanitizedVariant()) ||
+		m.UseVndk() || m.InRecovery() || m.InRamdisk() || m.InVendorRamdisk() {
+		ctx.PropertyErrorf("bazel_module", "the %q variant can't be built by Bazel yet", ctx.ModuleSubDir())
+		return nil, false
+	}
+	filePaths, ok := ctx.Config().BazelContext.GetAllFiles(label, android.GetConfigKey(ctx))
+	if !ok {
+		return nil, false
+	}
+
+	ext := staticLibraryExtension
+	if library.shared() {
+		ext = ctx.toolchain().ShlibSuffix()
+	}
+	var outputFile android.Path
+	for _, filePath := range filePaths {
+		if filepath.Ext(filePath) != ext {
+			continue
+		}
+		if outputFile != nil {
+			ctx.PropertyErrorf("bazel_module", "%s has more than one %s output: %q", label, ext, filePaths)
+			return nil, false
+		}
+		outputFile = android.PathForBazelOut(ctx, filePath)
+	}
+	if outputFile == nil {
+		ctx.PropertyErrorf("bazel_module", "%s has no %s output: %q", label, ext, filePaths)
+		return nil, false
+	}
+
+	// Headers are still exported by Soong, as the modules that depend on this library are
+	// compiled by Soong.
+	library.exportIncludes(ctx)
+	library.reexportDirs(deps.ReexportedDirs...)
+	library.reexportSystemDirs(deps.ReexportedSystemDirs...)
+	library.reexportFlags(deps.ReexportedFlags...)
+	library.reexportDeps(deps.ReexportedDeps...)
+	library.addExportedGeneratedHeaders(deps.ReexportedGeneratedHeaders...)
+	library.flagExporter.setProvider(ctx)
+
+	if library.static() {
+		ctx.SetProvider(StaticLibraryInfoProvider, StaticLibraryInfo{
+			StaticLibrary: outputFile,
+
+			TransitiveStaticLibrariesForOrdering: android.NewDepSetBuilder(android.TOPOLOGICAL).
+				Direct(outputFile).
+				Transitive(deps.TranstiveStaticLibrariesForOrdering).
+				Build(),
+		})
+		return outputFile, true
+	}
+
+	tocFile := android.PathForModuleOut(ctx, library.getLibName(ctx)+ext+".toc")
+	library.tocFile = android.OptionalPathForPath(tocFile)
+	transformSharedObjectToToc(ctx, outputFile, tocFile, flagsToBuilderFlags(Flags{Toolchain: ctx.toolchain()}))
+	library.unstrippedOutputFile = outputFile
+
+	ctx.SetProvider(SharedLibraryInfoProvider, SharedLibraryInfo{
+		TableOfContents:         android.OptionalPathForPath(tocFile),
+		SharedLibrary:           outputFile,
+		UnstrippedSharedLibrary: outputFile,
+	})
+	return outputFile, true
+}
+
 func (library *libraryDecorator) exportVersioningMacroIfNeeded(ctx android.BaseModuleContext) {
 	if library.buildStubs() && library.stubsVersion() != "" && !library.skipAPIDefine {
 		name := versioningMacroName(ctx.Module().(*Module).ImplementationModuleName(ctx))
@@ -1545,6 +1697,10 @@ func (library *libraryDecorator) hasStubsVariants() bool {
 	return len(library.Properties.Stubs.Versions) > 0
 }
 
+func (library *libraryDecorator) implementationRestricted() bool {
+	return library.hasStubsVariants() && Bool(library.Properties.Stubs.Restrict_implementation)
+}
+
 func (library *libraryDecorator) stubsVersions(ctx android.BaseMutatorContext) []string {
 	return library.Properties.Stubs.Versions
 }
@@ -16,6 +16,7 @@ package cc
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -195,6 +196,7 @@ func TestStubsVersions(t *testing.T) {
 			name: "libfoo",
 			srcs: ["foo.c"],
 			stubs: {
+				symbol_file: "libfoo.map.txt",
 				versions: ["29", "R", "current"],
 			},
 		}
@@ -218,6 +220,7 @@ func TestStubsVersions_NotSorted(t *testing.T) {
 			name: "libfoo",
 			srcs: ["foo.c"],
 			stubs: {
+				symbol_file: "libfoo.map.txt",
 				versions: ["29", "current", "R"],
 			},
 		}
@@ -233,6 +236,7 @@ func TestStubsVersions_ParseError(t *testing.T) {
 			name: "libfoo",
 			srcs: ["foo.c"],
 			stubs: {
+				symbol_file: "libfoo.map.txt",
 				versions: ["29", "current", "X"],
 			},
 		}
@@ -240,3 +244,130 @@ func TestStubsVersions_ParseError(t *testing.T) {
 
 	testCcError(t, `"libfoo" .*: versions: "X" could not be parsed as an integer and is not a recognized codename`, bp)
 }
+
+func TestCcLibraryWithBazel(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "foo",
+			srcs: ["foo.cc"],
+			bazel_module: { label: "//foo/bar:bar" },
+			stubs: {
+				symbol_file: "foo.map.txt",
+				versions: ["29"],
+			},
+		}
+	`
+	config := TestConfig(buildDir, android.Android, nil, bp, map[string][]byte{"foo.map.txt": nil})
+	config.BazelContext = android.MockBazelContext{
+		AllFiles: map[string][]string{
+			"//foo/bar:bar": []string{"foo.a", "foo.so"}}}
+	ctx := testCcWithConfig(t, config)
+
+	staticFoo := ctx.ModuleForTests("foo", "android_arm_armv7-a-neon_static")
+	if got, want := staticFoo.Module().(*Module).outputFile.String(), "outputbase/execroot/__main__/foo.a"; got != want {
+		t.Errorf("expected static output %q, got %q", want, got)
+	}
+	if staticFoo.MaybeRule("ar").Rule != nil {
+		t.Errorf("expected the static library not to be archived by Soong")
+	}
+
+	sharedFoo := ctx.ModuleForTests("foo", "android_arm_armv7-a-neon_shared")
+	if got, want := sharedFoo.Module().(*Module).outputFile.String(), "outputbase/execroot/__main__/foo.so"; got != want {
+		t.Errorf("expected shared output %q, got %q", want, got)
+	}
+	if sharedFoo.MaybeRule("ld").Rule != nil || sharedFoo.MaybeRule("cc").Rule != nil {
+		t.Errorf("expected the shared library not to be compiled or linked by Soong")
+	}
+	sharedInfo := ctx.ModuleProvider(sharedFoo.Module(), SharedLibraryInfoProvider).(SharedLibraryInfo)
+	if got, want := sharedInfo.SharedLibrary.String(), "outputbase/execroot/__main__/foo.so"; got != want {
+		t.Errorf("expected shared library info %q, got %q", want, got)
+	}
+
+	// The stubs are built by Soong, as the Bazel target builds the implementation library.
+	stubsFoo := ctx.ModuleForTests("foo", "android_arm_armv7-a-neon_shared_29")
+	if got := stubsFoo.Module().(*Module).outputFile.String(); strings.HasPrefix(got, "outputbase/") {
+		t.Errorf("expected the stubs not to be taken from Bazel, got %q", got)
+	}
+}
+
+func TestCcLibraryWithBazelVendorVariant(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "foo",
+			srcs: ["foo.cc"],
+			vendor_available: true,
+			bazel_module: { label: "//foo/bar:bar" },
+		}
+	`
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.TestProductVariables.DeviceVndkVersion = StringPtr("current")
+	config.BazelContext = android.MockBazelContext{
+		AllFiles: map[string][]string{
+			"//foo/bar:bar": []string{"foo.a", "foo.so"}}}
+	testCcErrorWithConfig(t, `bazel_module: the "android_vendor\..*" variant can't be built by Bazel yet`, config)
+}
+
+func TestCcLibraryWithBazelMissingOutput(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "foo",
+			srcs: ["foo.cc"],
+			bazel_module: { label: "//foo/bar:bar" },
+		}
+	`
+	config := TestConfig(buildDir, android.Android, nil, bp, nil)
+	config.BazelContext = android.MockBazelContext{
+		AllFiles: map[string][]string{
+			"//foo/bar:bar": []string{"foo.so"}}}
+	testCcErrorWithConfig(t, `bazel_module: //foo/bar:bar has no \.a output: \["foo.so"\]`, config)
+}
+
+func TestHeaderAbiCheckerRefDumpDir(t *testing.T) {
+	bp := `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.cc"],
+			header_abi_checker: {
+				enabled: true,
+				ref_dump_dir: "abi-dumps",
+				diff_flags: ["-allow-adding-removing-weak-symbols"],
+			},
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["bar.cc"],
+			header_abi_checker: {
+				enabled: true,
+				ref_dump_dir: "abi-dumps",
+			},
+		}
+	`
+	config := TestConfig(buildDir, android.Android, nil, bp, map[string][]byte{
+		"abi-dumps/arm64_armv8-a/libfoo.so.lsdump": nil,
+	})
+	ctx := testCcWithConfig(t, config)
+
+	// libfoo is diffed against the reference dump in its ref_dump_dir, with the diff_flags.
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	diff := libfoo.Rule("sAbiDiff")
+	if g, w := diff.Args["referenceDump"], "abi-dumps/arm64_armv8-a/libfoo.so.lsdump"; g != w {
+		t.Errorf("expected reference dump %q, got %q", w, g)
+	}
+	if !strings.Contains(diff.Args["extraFlags"], "-allow-adding-removing-weak-symbols") {
+		t.Errorf("expected the diff_flags in %q", diff.Args["extraFlags"])
+	}
+	dump := libfoo.Output("libfoo.so.lsdump").Output.String()
+	if g, w := diff.Args["updateCommand"], "cp "+dump+" abi-dumps/arm64_armv8-a/libfoo.so.lsdump"; g != w {
+		t.Errorf("expected update command %q, got %q", w, g)
+	}
+
+	// libbar has no reference dump, which fails the build with the command to create it.
+	missing := ctx.ModuleForTests("libbar", "android_arm64_armv8-a_shared").Output("libbar.so.abidiff")
+	if missing.Rule != android.ErrorRule {
+		t.Errorf("expected the diff of libbar to be an error, got %q", missing.Rule)
+	}
+	if !strings.Contains(missing.Args["error"], "please create it with: cp ") {
+		t.Errorf("expected the command creating the reference dump in %q", missing.Args["error"])
+	}
+}
@@ -188,17 +188,54 @@ type BaseLinkerProperties struct {
 	// make android::build:GetBuildNumber() available containing the build ID.
 	Use_version_lib *bool `android:"arch_variant"`
 
-	// Generate compact dynamic relocation table, default true.
+	// Generate compact dynamic relocation table, default true.  Only the lld linker packs
+	// relocations, so it can't be set to true with another linker.
 	Pack_relocations *bool `android:"arch_variant"`
 
+	// identical code folding mode passed to the linker as --icf, one of "none", "safe" or "all".
+	// Overrides the default mode of the target.  Requires the lld linker.
+	Icf *string `android:"arch_variant"`
+
+	// if false, don't place read-only non-executable sections in their own segment, which is
+	// passed to the linker as --no-rosegment.  Defaults to true.  Requires the lld linker.
+	Rosegment *bool `android:"arch_variant"`
+
 	// local file name to pass to the linker as --version_script
 	Version_script *string `android:"path,arch_variant"`
 
-	// list of static libs that should not be used to build this module
+	// local file name to pass to the linker as --dynamic-list
+	Dynamic_list *string `android:"path,arch_variant"`
+
+	// if false, opts this module out of the identical code folding and section garbage collection
+	// used to link device modules with lld when the product sets SizeOptimizedLinking.  The icf
+	// property takes precedence over the identical code folding mode.  Defaults to true.
+	Size_optimized_linking *bool `android:"arch_variant"`
+
+	// list of static libs whose symbols should not be exported from this module, passed to the
+	// linker as --exclude-libs.  Each entry must also be listed in static_libs or
+	// whole_static_libs, possibly in the static: or shared: block of a library or in a target
+	// block.  It is ignored by static libraries, which aren't linked.
+	Exclude_libs []string `android:"arch_variant"`
+
+	// list of static libs that should not be used to build this module.  They are removed from
+	// static_libs, whole_static_libs and export_static_lib_headers after the libs of all the other
+	// blocks were added, including target.vendor, target.recovery, etc. and the static: and
+	// shared: blocks of libraries, so it can be set in an arch, os, product variable or
+	// soong_config_variables block to exclude a lib that one of those adds.
 	Exclude_static_libs []string `android:"arch_variant"`
 
-	// list of shared libs that should not be used to build this module
+	// list of shared libs that should not be used to build this module.  They are removed from
+	// shared_libs and export_shared_lib_headers in the same way as exclude_static_libs.
 	Exclude_shared_libs []string `android:"arch_variant"`
+
+	// list of system libraries to remove from system_shared_libs, or from the default
+	// system_shared_libs if it is unset, e.g. ["libm"] for a module that doesn't use libm.  The
+	// linker still reports any symbol that was provided by an excluded library, so this can't be
+	// combined with allow_undefined_symbols.  Static libraries aren't linked, so nothing checks
+	// that a static library doesn't use the libraries it excludes.  The symbols are resolved, and
+	// checked, when the static library is linked into a shared library or an executable, against
+	// the system_shared_libs of that module.
+	Exclude_system_shared_libs []string `android:"arch_variant"`
 }
 
 func NewBaseLinker(sanitize *sanitize) *baseLinker {
@@ -233,6 +270,18 @@ func (linker *baseLinker) linkerProps() []interface{} {
 	return []interface{}{&linker.Properties, &linker.dynamicProperties}
 }
 
+// excludeLibs removes the libs listed in exclude_shared_libs and exclude_static_libs from deps.
+// They may be set under any arch, os, product variable or soong_config_variables block, so they
+// are applied after all the other libs were added so that they are pruned consistently.
+func (linker *baseLinker) excludeLibs(deps Deps) Deps {
+	deps.SharedLibs = removeListFromList(deps.SharedLibs, linker.Properties.Exclude_shared_libs)
+	deps.ReexportSharedLibHeaders = removeListFromList(deps.ReexportSharedLibHeaders, linker.Properties.Exclude_shared_libs)
+	deps.StaticLibs = removeListFromList(deps.StaticLibs, linker.Properties.Exclude_static_libs)
+	deps.ReexportStaticLibHeaders = removeListFromList(deps.ReexportStaticLibHeaders, linker.Properties.Exclude_static_libs)
+	deps.WholeStaticLibs = removeListFromList(deps.WholeStaticLibs, linker.Properties.Exclude_static_libs)
+	return deps
+}
+
 func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	deps.WholeStaticLibs = append(deps.WholeStaticLibs, linker.Properties.Whole_static_libs...)
 	deps.HeaderLibs = append(deps.HeaderLibs, linker.Properties.Header_libs...)
@@ -245,10 +294,6 @@ func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	deps.ReexportSharedLibHeaders = append(deps.ReexportSharedLibHeaders, linker.Properties.Export_shared_lib_headers...)
 	deps.ReexportGeneratedHeaders = append(deps.ReexportGeneratedHeaders, linker.Properties.Export_generated_headers...)
 
-	deps.SharedLibs = removeListFromList(deps.SharedLibs, linker.Properties.Exclude_shared_libs)
-	deps.StaticLibs = removeListFromList(deps.StaticLibs, linker.Properties.Exclude_static_libs)
-	deps.WholeStaticLibs = removeListFromList(deps.WholeStaticLibs, linker.Properties.Exclude_static_libs)
-
 	// Record the libraries that need to be excluded when building for APEX. Unlike other
 	// target.*.exclude_* properties, SharedLibs and StaticLibs are not modified here because
 	// this module hasn't yet passed the apexMutator. Therefore, we can't tell whether this is
@@ -320,6 +365,8 @@ func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 		deps.SharedLibs = append(deps.SharedLibs, linker.Properties.Target.Platform.Shared_libs...)
 	}
 
+	deps = linker.excludeLibs(deps)
+
 	if ctx.toolchain().Bionic() {
 		// libclang_rt.builtins and libatomic have to be last on the command line
 		if !Bool(linker.Properties.No_libcrt) {
@@ -335,6 +382,22 @@ func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 			deps.SystemSharedLibs = []string{"libc", "libm", "libdl"}
 		}
 
+		if excludes := linker.Properties.Exclude_system_shared_libs; len(excludes) > 0 {
+			// The usage of the excluded libraries is only checked by --no-undefined when
+			// linking a shared library or an executable.  For a static library the excluded
+			// libraries are only removed from the compile time dependencies.
+			if Bool(linker.Properties.Allow_undefined_symbols) {
+				ctx.PropertyErrorf("exclude_system_shared_libs",
+					"cannot be used with allow_undefined_symbols, as the symbols used from the excluded libraries would not be checked")
+			}
+			for _, lib := range excludes {
+				if !inList(lib, deps.SystemSharedLibs) {
+					ctx.PropertyErrorf("exclude_system_shared_libs", "%q is not in system_shared_libs", lib)
+				}
+			}
+			deps.SystemSharedLibs = removeListFromList(deps.SystemSharedLibs, excludes)
+		}
+
 		if inList("libdl", deps.SharedLibs) {
 			// If system_shared_libs has libc but not libdl, make sure shared_libs does not
 			// have libdl to avoid loading libdl before libc.
@@ -388,6 +451,31 @@ func (linker *baseLinker) useClangLld(ctx ModuleContext) bool {
 	return true
 }
 
+// checkLldProperties reports properties that only apply to lld when the module explicitly selects
+// another linker, as they would otherwise be silently ignored.
+func (linker *baseLinker) checkLldProperties(ctx ModuleContext) {
+	if icf := linker.Properties.Icf; icf != nil && !inList(*icf, []string{"none", "safe", "all"}) {
+		ctx.PropertyErrorf("icf", `must be one of "none", "safe" or "all", got %q`, *icf)
+	}
+
+	if linker.useClangLld(ctx) || ctx.Darwin() {
+		// Darwin never uses lld, so the properties are ignored there rather than rejected for
+		// modules that also build for other hosts.
+		return
+	}
+	// Other linkers never pack relocations, so only asking for them is an error.  This keeps
+	// pack_relocations: false, which is also set by modules converted from Make, working.
+	if Bool(linker.Properties.Pack_relocations) {
+		ctx.PropertyErrorf("pack_relocations", "requires the lld linker, but use_clang_lld is false")
+	}
+	if linker.Properties.Icf != nil {
+		ctx.PropertyErrorf("icf", "requires the lld linker, but use_clang_lld is false")
+	}
+	if linker.Properties.Rosegment != nil {
+		ctx.PropertyErrorf("rosegment", "requires the lld linker, but use_clang_lld is false")
+	}
+}
+
 // Check whether the SDK version is not older than the specific one
 func CheckSdkVersionAtLeast(ctx ModuleContext, SdkVersion int) bool {
 	if ctx.sdkVersion() == "current" {
@@ -475,6 +563,20 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 		flags.Global.LdFlags = append(flags.Global.LdFlags, "-Wl,--exclude-libs="+config.BuiltinsRuntimeLibrary(ctx.toolchain())+".a")
 	}
 
+	linker.checkLldProperties(ctx)
+	if linker.useClangLld(ctx) {
+		if icf := linker.icfMode(ctx); icf != "" {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--icf="+icf)
+		}
+		if linker.sizeOptimizedLinking(ctx) {
+			// The default mode of the targets already folds identical code safely.
+			flags.Global.LdFlags = append(flags.Global.LdFlags, "-Wl,--gc-sections")
+		}
+		if !BoolDefault(linker.Properties.Rosegment, true) {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--no-rosegment")
+		}
+	}
+
 	CheckBadLinkerFlags(ctx, "ldflags", linker.Properties.Ldflags)
 
 	flags.Local.LdFlags = append(flags.Local.LdFlags, proptools.NinjaAndShellEscapeList(linker.Properties.Ldflags)...)
@@ -539,6 +641,49 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 		}
 	}
 
+	dynamicList := ctx.ExpandOptionalSource(linker.Properties.Dynamic_list, "dynamic_list")
+	if dynamicList.Valid() {
+		if ctx.Darwin() {
+			ctx.PropertyErrorf("dynamic_list", "Not supported on Darwin")
+		} else {
+			flags.Local.LdFlags = append(flags.Local.LdFlags,
+				"-Wl,--dynamic-list,"+dynamicList.String())
+			flags.LdFlagsDeps = append(flags.LdFlagsDeps, dynamicList.Path())
+		}
+	}
+
+	// Static libraries aren't linked, and may not have the static libs that the shared: block of
+	// a cc_library adds.
+	if len(linker.Properties.Exclude_libs) > 0 && !(ctx.static() && !ctx.binary()) {
+		flags = linker.excludeLibsFlags(ctx, flags)
+	}
+
+	return flags
+}
+
+// excludeLibsFlags adds --exclude-libs for each of the exclude_libs of the module.  They are checked
+// against the static lib dependencies of the module, which may come from any block of the module,
+// e.g. the static: and shared: blocks of libraries or target.vendor.
+func (linker *baseLinker) excludeLibsFlags(ctx ModuleContext, flags Flags) Flags {
+	var staticLibs []string
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		if IsStaticDepTag(ctx.OtherModuleDependencyTag(dep)) {
+			staticLibs = append(staticLibs, android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(dep)))
+		}
+	})
+
+	for _, lib := range linker.Properties.Exclude_libs {
+		if !inList(lib, staticLibs) {
+			ctx.PropertyErrorf("exclude_libs", "%q is not listed in static_libs or whole_static_libs", lib)
+			continue
+		}
+		if ctx.Darwin() {
+			ctx.PropertyErrorf("exclude_libs", "Not supported on Darwin")
+			break
+		}
+		flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--exclude-libs,"+lib+".a")
+	}
+
 	return flags
 }
 
@@ -579,6 +724,13 @@ var injectVersionSymbol = pctx.AndroidStaticRule("injectVersionSymbol",
 
 func (linker *baseLinker) injectVersionSymbol(ctx ModuleContext, in android.Path, out android.WritablePath) {
 	buildNumberFile := ctx.Config().BuildNumberFile(ctx)
+	if ctx.Host() && ctx.Config().HostHermeticBinaries() {
+		// The build number changes on every build, stamp hermetic host tools with the build id
+		// instead so that they are reproducible.
+		buildIdFile := android.PathForModuleOut(ctx, "build_id.txt")
+		android.WriteFileRule(ctx, buildIdFile, ctx.Config().BuildId())
+		buildNumberFile = buildIdFile
+	}
 	ctx.Build(pctx, android.BuildParams{
 		Rule:        injectVersionSymbol,
 		Description: "inject version symbol",
@@ -591,6 +743,50 @@ func (linker *baseLinker) injectVersionSymbol(ctx ModuleContext, in android.Path
 	})
 }
 
+// Size reports for modules linked with SizeOptimizedLinking.
+
+var linkSizeReport = pctx.AndroidStaticRule("linkSizeReport",
+	blueprint.RuleParams{
+		Command: "(${config.ClangBin}/llvm-size -A $in && " +
+			"${config.ClangBin}/llvm-nm --print-size --size-sort --radix=d $in) > $out",
+		CommandDeps: []string{"${config.ClangBin}/llvm-size", "${config.ClangBin}/llvm-nm"},
+	})
+
+func (linker *baseLinker) sizeOptimizedLinking(ctx ModuleContext) bool {
+	return ctx.Device() && ctx.Config().SizeOptimizedLinking() && linker.useClangLld(ctx) &&
+		BoolDefault(linker.Properties.Size_optimized_linking, true)
+}
+
+// icfMode returns the identical code folding mode to pass to lld after the default mode of the
+// target, or "" to keep the default.  The icf property takes precedence over opting out of
+// SizeOptimizedLinking, which disables identical code folding.
+func (linker *baseLinker) icfMode(ctx ModuleContext) string {
+	if linker.Properties.Icf != nil {
+		return *linker.Properties.Icf
+	}
+	if ctx.Device() && ctx.Config().SizeOptimizedLinking() &&
+		!BoolDefault(linker.Properties.Size_optimized_linking, true) {
+		return "none"
+	}
+	return ""
+}
+
+// buildSizeReport writes the section sizes and the symbols sorted by size of the linked module,
+// so that the effect of SizeOptimizedLinking can be tracked.
+func (linker *baseLinker) buildSizeReport(ctx ModuleContext, in android.Path, fileName string) {
+	if !linker.sizeOptimizedLinking(ctx) {
+		return
+	}
+	report := android.PathForModuleOut(ctx, "size_report", fileName+".txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        linkSizeReport,
+		Description: "size report " + fileName,
+		Input:       in,
+		Output:      report,
+	})
+	ctx.CheckbuildFile(report)
+}
+
 // Rule to generate .bss symbol ordering file.
 
 var (
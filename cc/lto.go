@@ -40,9 +40,16 @@ type LTOProperties struct {
 	// Lto must violate capitialization style for acronyms so that it can be
 	// referred to in blueprint files as "lto"
 	Lto struct {
+		// Never build this module with LTO, even when it is a static dependency of a module
+		// built with LTO or when LTO is enabled globally with GLOBAL_THINLTO.  Its object files
+		// are linked into LTO modules as native code.
 		Never *bool `android:"arch_variant"`
-		Full  *bool `android:"arch_variant"`
-		Thin  *bool `android:"arch_variant"`
+		// Build this module with full LTO.  Its static dependencies that don't set lto.never are
+		// built as bitcode in an "lto-full" variant.
+		Full *bool `android:"arch_variant"`
+		// Build this module with ThinLTO.  Its static dependencies that don't set lto.never are
+		// built as bitcode in an "lto-thin" variant.  Mutually exclusive with full.
+		Thin *bool `android:"arch_variant"`
 	} `android:"arch_variant"`
 
 	// Dep properties indicate that this module needs to be built with LTO
@@ -40,15 +40,20 @@ func getNamedMapForConfig(config android.Config, key android.OnceKey) *sync.Map
 	}).(*sync.Map)
 }
 
-func makeStringOfKeys(ctx android.MakeVarsContext, key android.OnceKey) string {
-	set := getNamedMapForConfig(ctx.Config(), key)
+// sortedKeysOfNamedMap returns the sorted keys of the named map.
+func sortedKeysOfNamedMap(config android.Config, key android.OnceKey) []string {
+	set := getNamedMapForConfig(config, key)
 	keys := []string{}
 	set.Range(func(key interface{}, value interface{}) bool {
 		keys = append(keys, key.(string))
 		return true
 	})
 	sort.Strings(keys)
-	return strings.Join(keys, " ")
+	return keys
+}
+
+func makeStringOfKeys(ctx android.MakeVarsContext, key android.OnceKey) string {
+	return strings.Join(sortedKeysOfNamedMap(ctx.Config(), key), " ")
 }
 
 func makeStringOfWarningAllowedProjects() string {
@@ -33,6 +33,15 @@ var (
 		},
 		"depsPath", "srcDir", "outDir")
 
+	// Runs the versioner without preprocessing to validate the availability annotations in the
+	// headers against the per-API level symbol lists in $platformDir.
+	validateBionicHeaders = pctx.AndroidStaticRule("validateBionicHeaders",
+		blueprint.RuleParams{
+			Command:     "$versionerCmd -p $platformDir $srcDir $depsPath && touch $out",
+			CommandDeps: []string{"$versionerCmd"},
+		},
+		"depsPath", "platformDir", "srcDir")
+
 	preprocessNdkHeader = pctx.AndroidStaticRule("preprocessNdkHeader",
 		blueprint.RuleParams{
 			Command:     "$preprocessor -o $out $in",
@@ -185,6 +194,12 @@ type versionedHeaderProperties struct {
 	// Path to the NOTICE file associated with the headers.
 	License *string
 
+	// Directory containing the per-API level symbol lists that the availability
+	// annotations in the headers are validated against. If set, the build fails
+	// when a header declares a symbol as available at an API level that does not
+	// export it.
+	Platform_dir *string
+
 	// True if this API is not yet ready to be shipped in the NDK. It will be
 	// available in the platform for testing, but will be excluded from the
 	// sysroot provided to the NDK proper.
@@ -229,11 +244,16 @@ func (m *versionedHeaderModule) GenerateAndroidBuildActions(ctx android.ModuleCo
 		ctx.ModuleErrorf("glob %q matched zero files", String(m.properties.From))
 	}
 
-	processHeadersWithVersioner(ctx, fromSrcPath, toOutputPath, srcFiles, installPaths)
+	var platformDir android.Path
+	if m.properties.Platform_dir != nil {
+		platformDir = android.PathForModuleSrc(ctx, String(m.properties.Platform_dir))
+	}
+
+	processHeadersWithVersioner(ctx, fromSrcPath, toOutputPath, platformDir, srcFiles, installPaths)
 }
 
-func processHeadersWithVersioner(ctx android.ModuleContext, srcDir, outDir android.Path,
-	srcFiles android.Paths, installPaths []android.WritablePath) android.Path {
+// versionerDependencies returns the versioner dependencies directory and the files it contains.
+func versionerDependencies(ctx android.ModuleContext) (android.Path, android.Paths) {
 	// The versioner depends on a dependencies directory to simplify determining include paths
 	// when parsing headers. This directory contains architecture specific directories as well
 	// as a common directory, each of which contains symlinks to the actually directories to
@@ -253,6 +273,47 @@ func processHeadersWithVersioner(ctx android.ModuleContext, srcDir, outDir andro
 		}
 	}
 
+	return depsPath, depsGlob
+}
+
+// validateHeadersWithVersioner checks the availability annotations of the headers in srcDir
+// against the symbol lists in platformDir, and returns a timestamp file that can be used as a
+// validation of the preprocessing step.
+func validateHeadersWithVersioner(ctx android.ModuleContext, srcDir, platformDir, depsPath android.Path,
+	srcFiles, depsGlob android.Paths) android.Path {
+	symbolFiles := ctx.GlobFiles(filepath.Join(platformDir.String(), "**/*"), nil)
+
+	var implicits android.Paths
+	implicits = append(implicits, srcFiles...)
+	implicits = append(implicits, depsGlob...)
+	implicits = append(implicits, symbolFiles...)
+
+	timestampFile := android.PathForModuleOut(ctx, "versioner_validate.timestamp")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        validateBionicHeaders,
+		Description: "versioner validate " + srcDir.Rel(),
+		Output:      timestampFile,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"depsPath":    depsPath.String(),
+			"platformDir": platformDir.String(),
+			"srcDir":      srcDir.String(),
+		},
+	})
+
+	return timestampFile
+}
+
+func processHeadersWithVersioner(ctx android.ModuleContext, srcDir, outDir, platformDir android.Path,
+	srcFiles android.Paths, installPaths []android.WritablePath) android.Path {
+	depsPath, depsGlob := versionerDependencies(ctx)
+
+	var validations android.Paths
+	if platformDir != nil {
+		validations = append(validations,
+			validateHeadersWithVersioner(ctx, srcDir, platformDir, depsPath, srcFiles, depsGlob))
+	}
+
 	timestampFile := android.PathForModuleOut(ctx, "versioner.timestamp")
 	ctx.Build(pctx, android.BuildParams{
 		Rule:            versionBionicHeaders,
@@ -260,6 +321,7 @@ func processHeadersWithVersioner(ctx android.ModuleContext, srcDir, outDir andro
 		Output:          timestampFile,
 		Implicits:       append(srcFiles, depsGlob...),
 		ImplicitOutputs: installPaths,
+		Validations:     validations,
 		Args: map[string]string{
 			"depsPath": depsPath.String(),
 			"srcDir":   srcDir.String(),
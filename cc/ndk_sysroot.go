@@ -53,6 +53,10 @@ package cc
 // TODO(danalbert): Write `ndk_static_library` rule.
 
 import (
+	"path/filepath"
+	"sort"
+	"strings"
+
 	"android/soong/android"
 )
 
@@ -82,6 +86,18 @@ func getNdkBaseTimestampFile(ctx android.PathContext) android.WritablePath {
 	return android.PathForOutput(ctx, "ndk_base.timestamp")
 }
 
+// The sysroot zip contains the headers, stub shared libraries and static
+// libraries of the full sysroot, laid out like getNdkInstallBase, so that it
+// can be used as a hermetic NDK outside of the out directory.
+func getNdkSysrootZip(ctx android.PathContext) android.WritablePath {
+	return android.PathForOutput(ctx, "ndk", "sysroot.zip")
+}
+
+// The sysroot manifest lists the files in the sysroot zip, one per line.
+func getNdkSysrootManifest(ctx android.PathContext) android.WritablePath {
+	return android.PathForOutput(ctx, "ndk", "sysroot.manifest")
+}
+
 // The full timestamp file depends on the base timestamp *and* the static
 // libraries.
 func getNdkFullTimestampFile(ctx android.PathContext) android.WritablePath {
@@ -177,4 +193,40 @@ func (n *ndkSingleton) GenerateBuildActions(ctx android.SingletonContext) {
 		Output:    getNdkFullTimestampFile(ctx),
 		Implicits: fullDepPaths,
 	})
+
+	sysrootPaths := append(android.Paths{}, baseDepPaths...)
+	sysrootPaths = append(sysrootPaths, staticLibInstallPaths...)
+	buildNdkSysrootZip(ctx, sysrootPaths)
+}
+
+// buildNdkSysrootZip zips the installed sysroot files and writes the manifest
+// of the zip. `m ndk_sysroot` builds both.
+func buildNdkSysrootZip(ctx android.SingletonContext, sysrootPaths android.Paths) {
+	base := getNdkInstallBase(ctx).String()
+	sysrootPaths = android.FirstUniquePaths(sysrootPaths)
+
+	var entries []string
+	for _, path := range sysrootPaths {
+		rel, err := filepath.Rel(base, path.String())
+		if err != nil || strings.HasPrefix(rel, "..") {
+			ctx.Errorf("NDK sysroot file %q is not in %q", path, base)
+			continue
+		}
+		entries = append(entries, rel)
+	}
+	sort.Strings(entries)
+
+	manifest := getNdkSysrootManifest(ctx)
+	android.WriteFileRule(ctx, manifest, strings.Join(entries, "\n"))
+
+	zip := getNdkSysrootZip(ctx)
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", zip).
+		FlagWithArg("-C ", base).
+		FlagWithRspFileInputList("-r ", sysrootPaths)
+	rule.Build("ndk_sysroot_zip", "zip NDK sysroot")
+
+	ctx.Phony("ndk_sysroot", zip, manifest)
 }
@@ -40,6 +40,10 @@ var (
 
 var pgoProfileProjectsConfigKey = android.NewOnceKey("PgoProfileProjects")
 
+func init() {
+	android.RegisterSingletonType("pgo_profile_report", pgoProfileReportSingletonFactory)
+}
+
 const profileInstrumentFlag = "-fprofile-generate=/data/local/tmp"
 const profileUseInstrumentFormat = "-fprofile-use=%s"
 const profileUseSamplingFormat = "-fprofile-sample-accurate -fprofile-sample-use=%s"
@@ -54,6 +58,23 @@ func recordMissingProfileFile(ctx BaseModuleContext, missing string) {
 	getNamedMapForConfig(ctx.Config(), modulesMissingProfileFileKey).Store(missing, true)
 }
 
+const pgoMissingProfilesFileName = "pgo_missing_profiles.txt"
+
+func pgoProfileReportSingletonFactory() android.Singleton {
+	return &pgoProfileReportSingleton{}
+}
+
+// pgoProfileReportSingleton writes the modules whose pgo.profile_file was not found in any PGO
+// profile project to $OUT/soong/pgo_missing_profiles.txt, one
+// "<profile_file>:<dir>/Android.bp:<module>" entry per line.  The same list is exported to Make
+// as SOONG_MODULES_MISSING_PGO_PROFILE_FILE.
+type pgoProfileReportSingleton struct{}
+
+func (s *pgoProfileReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	missing := sortedKeysOfNamedMap(ctx.Config(), modulesMissingProfileFileKey)
+	android.WriteFileRule(ctx, android.PathForOutput(ctx, pgoMissingProfilesFileName), strings.Join(missing, "\n"))
+}
+
 type PgoProperties struct {
 	Pgo struct {
 		Instrumentation    *bool
@@ -233,8 +254,9 @@ func (pgo *pgo) begin(ctx BaseModuleContext) {
 		return
 	}
 
-	// This module should be instrumented if ANDROID_PGO_INSTRUMENT is set
-	// and includes 'all', 'ALL' or a benchmark listed for this module.
+	// This module should be instrumented if ANDROID_PGO_INSTRUMENT or the
+	// product's PgoInstrumentBenchmarks include 'all', 'ALL' or a benchmark
+	// listed for this module.
 	//
 	// TODO Validate that each benchmark instruments at least one module
 	pgo.Properties.ShouldProfileModule = false
@@ -243,6 +265,9 @@ func (pgo *pgo) begin(ctx BaseModuleContext) {
 	for _, b := range strings.Split(pgoBenchmarks, ",") {
 		pgoBenchmarksMap[b] = true
 	}
+	for _, b := range ctx.DeviceConfig().PgoInstrumentBenchmarks() {
+		pgoBenchmarksMap[b] = true
+	}
 
 	if pgoBenchmarksMap["all"] == true || pgoBenchmarksMap["ALL"] == true {
 		pgo.Properties.ShouldProfileModule = true
@@ -18,6 +18,8 @@ import (
 	"android/soong/android"
 	"path/filepath"
 	"strings"
+
+	"github.com/google/blueprint"
 )
 
 func init() {
@@ -48,12 +50,80 @@ type prebuiltLinkerProperties struct {
 	// symbols, etc), default true.
 	Check_elf_files *bool
 
+	// If true, check that this prebuilt shared library exports all the dynamic symbols of the
+	// source module it replaces, or all the symbols listed in abi_reference if it is set, and fail
+	// the build if any are missing.
+	Check_abi_compat *bool
+
+	// Optional file listing the dynamic symbols, one per line, that a prebuilt shared library with
+	// check_abi_compat set must export.  Used instead of the symbols of the source module.
+	Abi_reference *string `android:"path,arch_variant"`
+
 	// Optionally provide an import library if this is a Windows PE DLL prebuilt.
 	// This is needed only if this library is linked by other modules in build time.
 	// Only makes sense for the Windows target.
 	Windows_import_lib *string `android:"path,arch_variant"`
 }
 
+var checkPrebuiltAbiCompat = pctx.AndroidStaticRule("checkPrebuiltAbiCompat",
+	blueprint.RuleParams{
+		Command: "rm -f $out && ($referenceSymbolsCmd) | sort -u > $out.reference && " +
+			"${config.ClangBin}/llvm-nm -D --defined-only --format=just-symbols $in | sort -u > $out.prebuilt && " +
+			"comm -23 $out.reference $out.prebuilt > $out.missing && " +
+			"if [ -s $out.missing ]; then " +
+			"echo \"error: prebuilt $in is missing symbols exported by $reference:\" && cat $out.missing && exit 1; " +
+			"fi && touch $out",
+		CommandDeps: []string{"${config.ClangBin}/llvm-nm"},
+		Description: "check abi compat $in",
+	},
+	"referenceSymbolsCmd", "reference")
+
+// buildPrebuiltAbiCompatCheck adds a rule that fails if the prebuilt shared library is missing
+// any of the dynamic symbols in reference, which is either a shared library or a list of symbols.
+func buildPrebuiltAbiCompatCheck(ctx ModuleContext, prebuilt, reference android.Path,
+	referenceIsList bool, out android.WritablePath) {
+
+	referenceSymbolsCmd := "${config.ClangBin}/llvm-nm -D --defined-only --format=just-symbols " + reference.String()
+	if referenceIsList {
+		referenceSymbolsCmd = "grep -v '^#' " + reference.String()
+	}
+	ctx.Build(pctx, android.BuildParams{
+		Rule:     checkPrebuiltAbiCompat,
+		Input:    prebuilt,
+		Implicit: reference,
+		Output:   out,
+		Args: map[string]string{
+			"referenceSymbolsCmd": referenceSymbolsCmd,
+			"reference":           reference.String(),
+		},
+	})
+}
+
+// checkPrebuiltAbiCompatWithSource is called for a source module that has been replaced by a
+// prebuilt.  If the prebuilt is a shared library with check_abi_compat set and no abi_reference,
+// it adds a check that the prebuilt exports all the dynamic symbols of the source module.
+func checkPrebuiltAbiCompatWithSource(ctx ModuleContext, source *Module) {
+	if library, ok := source.linker.(*libraryDecorator); !ok || !library.shared() {
+		return
+	}
+	ctx.VisitDirectDepsWithTag(android.PrebuiltDepTag, func(m android.Module) {
+		prebuilt, ok := m.(*Module)
+		if !ok {
+			return
+		}
+		p, ok := prebuilt.linker.(*prebuiltLibraryLinker)
+		if !ok || !p.shared() || !Bool(p.properties.Check_abi_compat) || p.properties.Abi_reference != nil {
+			return
+		}
+		if prebuilt.UnstrippedOutputFile() == nil || source.UnstrippedOutputFile() == nil {
+			return
+		}
+		out := android.PathForModuleOut(ctx, "prebuilt_abi_compat.check")
+		buildPrebuiltAbiCompatCheck(ctx, prebuilt.UnstrippedOutputFile(), source.UnstrippedOutputFile(), false, out)
+		ctx.CheckbuildFile(out)
+	})
+}
+
 type prebuiltLinker struct {
 	android.Prebuilt
 
@@ -89,6 +159,7 @@ func (p *prebuiltLibraryLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 }
 
 func (p *prebuiltLibraryLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
+	p.stripper.CheckProperties(ctx)
 	return flags
 }
 
@@ -171,12 +242,20 @@ func (p *prebuiltLibraryLinker) link(ctx ModuleContext,
 				})
 			}
 
+			var abiCompatCheck android.WritablePath
+			if Bool(p.properties.Check_abi_compat) && p.properties.Abi_reference != nil {
+				abiCompatCheck = android.PathForModuleOut(ctx, "abi_compat.check")
+				buildPrebuiltAbiCompatCheck(ctx, p.unstrippedOutputFile,
+					android.PathForModuleSrc(ctx, String(p.properties.Abi_reference)), true, abiCompatCheck)
+			}
+
 			ctx.Build(pctx, android.BuildParams{
 				Rule:        android.Cp,
 				Description: "prebuilt shared library",
 				Implicits:   implicits,
 				Input:       in,
 				Output:      outputFile,
+				Validation:  abiCompatCheck,
 				Args: map[string]string{
 					"cpFlags": "-L",
 				},
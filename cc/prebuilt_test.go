@@ -386,3 +386,46 @@ func TestPrebuiltLibrarySanitized(t *testing.T) {
 	static2 = ctx.ModuleForTests("libtest_static", "android_arm64_armv8-a_static_hwasan").Module().(*Module)
 	assertString(t, static2.OutputFile().Path().Base(), "libf.hwasan.a")
 }
+
+func TestPrebuiltLibraryCheckAbiCompat(t *testing.T) {
+	ctx := testPrebuilt(t, `
+	cc_library_shared {
+		name: "libfoo",
+		srcs: ["foo.c"],
+	}
+
+	cc_prebuilt_library_shared {
+		name: "libfoo",
+		prefer: true,
+		srcs: ["libfoo.so"],
+		check_abi_compat: true,
+	}
+
+	cc_prebuilt_library_shared {
+		name: "libbar",
+		srcs: ["libbar.so"],
+		check_abi_compat: true,
+		abi_reference: "libbar.symbols.txt",
+	}
+	`, map[string][]byte{
+		"libfoo.so":          nil,
+		"libbar.so":          nil,
+		"libbar.symbols.txt": nil,
+	})
+
+	variant := "android_arm64_armv8-a_shared"
+
+	// The prebuilt replacing a source module is checked against the source module.
+	sourceCheck := ctx.ModuleForTests("libfoo", variant).Output("prebuilt_abi_compat.check")
+	assertString(t, sourceCheck.Input.String(), "libfoo.so")
+	source := ctx.ModuleForTests("libfoo", variant).Module().(*Module)
+	assertString(t, sourceCheck.Implicit.String(), source.UnstrippedOutputFile().String())
+
+	// A prebuilt with abi_reference is checked against it, and the check validates its output.
+	libbar := ctx.ModuleForTests("libbar", variant)
+	referenceCheck := libbar.Output("abi_compat.check")
+	assertString(t, referenceCheck.Input.String(), "libbar.so")
+	assertString(t, referenceCheck.Implicit.String(), "libbar.symbols.txt")
+	assertString(t, libbar.Description("prebuilt shared library").Validation.String(),
+		referenceCheck.Output.String())
+}
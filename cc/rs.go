@@ -120,7 +120,7 @@ func rsFlags(ctx ModuleContext, flags Flags, properties *BaseCompilerProperties)
 	}
 	flags.rsFlags = append(flags.rsFlags, "${config.RsGlobalIncludes}")
 
-	rootRsIncludeDirs := android.PathsForSource(ctx, properties.Renderscript.Include_dirs)
+	rootRsIncludeDirs := android.PathsForSandboxedSource(ctx, "renderscript.include_dirs", properties.Renderscript.Include_dirs)
 	flags.rsFlags = append(flags.rsFlags, includeDirsToFlags(rootRsIncludeDirs))
 
 	flags.Local.CommonFlags = append(flags.Local.CommonFlags,
@@ -176,6 +176,12 @@ type SanitizeUserProps struct {
 	Config struct {
 		// Enables CFI support flags for assembly-heavy libraries
 		Cfi_assembly_support *bool `android:"arch_variant"`
+
+		// Enables CFI, in the same mode as this library, for the shared libraries that re-export
+		// the headers of this library, and transitively for the libraries that re-export theirs.
+		// This builds the whole group of DSOs exposing the types of the exported headers with
+		// cross-DSO CFI. Only applies if CFI is enabled for this library.
+		Cfi_export_group *bool `android:"arch_variant"`
 	} `android:"arch_variant"`
 
 	// value to pass to -fsanitize-recover=
@@ -192,6 +198,7 @@ type SanitizeProperties struct {
 	Sanitize          SanitizeUserProps `android:"arch_variant"`
 	SanitizerEnabled  bool              `blueprint:"mutated"`
 	SanitizeDep       bool              `blueprint:"mutated"`
+	CfiExportGroup    bool              `blueprint:"mutated"`
 	MinimalRuntimeDep bool              `blueprint:"mutated"`
 	BuiltinsDep       bool              `blueprint:"mutated"`
 	UbsanRuntimeDep   bool              `blueprint:"mutated"`
@@ -214,6 +221,16 @@ var _ android.SkipApexAllowedDependenciesCheck = (*libraryDependencyTag)(nil)
 func init() {
 	android.RegisterMakeVarsProvider(pctx, cfiMakeVarsProvider)
 	android.RegisterMakeVarsProvider(pctx, hwasanMakeVarsProvider)
+
+	config.ExportStringList("AsanCflags", asanCflags)
+	config.ExportStringList("AsanLdflags", asanLdflags)
+	config.ExportStringList("HwasanCflags", hwasanCflags)
+	config.ExportStringList("CfiCflags", cfiCflags)
+	config.ExportStringList("CfiAsflags", cfiAsflags)
+	config.ExportStringList("CfiLdflags", cfiLdflags)
+	config.ExportStringList("IntOverflowCflags", intOverflowCflags)
+	config.ExportStringList("SanitizeMinimalRuntimeFlags", minimalRuntimeFlags)
+	config.ExportStringList("HwasanGlobalOptions", hwasanGlobalOptions)
 }
 
 func (sanitize *sanitize) props() []interface{} {
@@ -337,6 +354,11 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		}
 	}
 
+	// Build CFI in diagnostic mode for all components in the diag paths
+	if Bool(s.Cfi) && s.Diag.Cfi == nil && ctx.Config().CFIDiagForPath(ctx.ModuleDir()) {
+		s.Diag.Cfi = boolPtr(true)
+	}
+
 	// Is CFI actually enabled?
 	if !ctx.Config().EnableCFI() {
 		s.Cfi = boolPtr(false)
@@ -804,6 +826,44 @@ func needsCfiForVendorSnapshot(mctx android.TopDownMutatorContext) bool {
 		!c.sanitize.isSanitizerExplicitlyDisabled(cfi)
 }
 
+// cfiExportGroupMutator enables CFI for the shared libraries that re-export the headers of a
+// library with sanitize.config.cfi_export_group set.  It runs bottom up, so that the group
+// extends to the libraries re-exporting the headers of a library that joined the group.
+func cfiExportGroupMutator(mctx android.BottomUpMutatorContext) {
+	c, ok := mctx.Module().(*Module)
+	if !ok || c.sanitize == nil {
+		return
+	}
+	s := &c.sanitize.Properties
+	if Bool(s.Sanitize.Cfi) && Bool(s.Sanitize.Config.Cfi_export_group) {
+		s.CfiExportGroup = true
+		return
+	}
+
+	// Only shared libraries for which CFI was neither enabled nor disabled, e.g. with cfi: false,
+	// join the group, sanitize.begin disables CFI for the variants that don't support it.  The
+	// libraries in CFIExcludePaths don't join the group either.
+	if !mctx.Device() || c.library == nil || !c.library.shared() ||
+		s.Sanitize.Cfi != nil || Bool(s.Sanitize.Never) ||
+		mctx.Config().CFIDisabledForPath(mctx.ModuleDir()) {
+		return
+	}
+	mctx.VisitDirectDeps(func(dep android.Module) {
+		tag, ok := mctx.OtherModuleDependencyTag(dep).(libraryDependencyTag)
+		if !ok || !tag.shared() || !tag.reexportFlags {
+			return
+		}
+		if d, ok := dep.(*Module); ok && d.sanitize != nil && d.sanitize.Properties.CfiExportGroup {
+			s.Sanitize.Cfi = boolPtr(true)
+			if Bool(d.sanitize.Properties.Sanitize.Diag.Cfi) {
+				s.Sanitize.Diag.Cfi = boolPtr(true)
+			}
+			s.SanitizerEnabled = true
+			s.CfiExportGroup = true
+		}
+	})
+}
+
 // Propagate sanitizer requirements down from binaries
 func sanitizerDepsMutator(t sanitizerType) func(android.TopDownMutatorContext) {
 	return func(mctx android.TopDownMutatorContext) {
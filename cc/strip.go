@@ -35,8 +35,8 @@ type StripProperties struct {
 		// keep_symbols enables stripping but keeps all symbols.
 		Keep_symbols *bool `android:"arch_variant"`
 
-		// keep_symbols_list specifies a list of symbols to keep if keep_symbols is enabled.
-		// If it is unset then all symbols are kept.
+		// keep_symbols_list enables stripping but keeps only the listed symbols.
+		// If it is unset and keep_symbols is enabled then all symbols are kept.
 		Keep_symbols_list []string `android:"arch_variant"`
 
 		// keep_symbols_and_debug_frame enables stripping but keeps all symbols and debug frames.
@@ -52,28 +52,61 @@ type Stripper struct {
 // NeedsStrip determines if stripping is required for a module.
 func (stripper *Stripper) NeedsStrip(actx android.ModuleContext) bool {
 	forceDisable := Bool(stripper.StripProperties.Strip.None)
-	defaultEnable := (!actx.Config().KatiEnabled() || actx.Device())
+	defaultEnable := (!actx.Config().KatiEnabled() || actx.Device() || hermeticHostStrip(actx))
 	forceEnable := Bool(stripper.StripProperties.Strip.All) ||
 		Bool(stripper.StripProperties.Strip.Keep_symbols) ||
+		len(stripper.StripProperties.Strip.Keep_symbols_list) > 0 ||
 		Bool(stripper.StripProperties.Strip.Keep_symbols_and_debug_frame)
 	return !forceDisable && (forceEnable || defaultEnable)
 }
 
+// CheckProperties reports an error if the strip properties, after arch variant properties have
+// been applied, select more than one stripping mode.  It is called once per module variant, from
+// the flags of the linker or compiler that owns the stripper.
+func (stripper *Stripper) CheckProperties(actx android.ModuleContext) {
+	strip := stripper.StripProperties.Strip
+	var modes []string
+	if Bool(strip.All) {
+		modes = append(modes, "all")
+	}
+	if Bool(strip.Keep_symbols_and_debug_frame) {
+		modes = append(modes, "keep_symbols_and_debug_frame")
+	}
+	if len(strip.Keep_symbols_list) > 0 {
+		modes = append(modes, "keep_symbols_list")
+	} else if Bool(strip.Keep_symbols) {
+		modes = append(modes, "keep_symbols")
+	}
+	if Bool(strip.None) && len(modes) > 0 {
+		actx.PropertyErrorf("strip.none", "cannot be set together with strip.%s", modes[0])
+	} else if len(modes) > 1 {
+		actx.PropertyErrorf("strip", "only one of strip.%s may be set", strings.Join(modes, ", strip."))
+	}
+}
+
+// hermeticHostStrip returns true if the module is a host module being built as a hermetic host
+// tool, in which case it is stripped of everything by default, including the mini debug info
+// and the gnu debuglink to the unstripped file.
+func hermeticHostStrip(actx android.ModuleContext) bool {
+	return actx.Host() && actx.Config().HostHermeticBinaries()
+}
+
 func (stripper *Stripper) strip(actx android.ModuleContext, in android.Path, out android.ModuleOutPath,
 	flags StripFlags, isStaticLib bool) {
 	if actx.Darwin() {
 		transformDarwinStrip(actx, in, out)
 	} else {
-		if Bool(stripper.StripProperties.Strip.Keep_symbols) {
+		if len(stripper.StripProperties.Strip.Keep_symbols_list) > 0 {
+			flags.StripKeepSymbolsList = strings.Join(stripper.StripProperties.Strip.Keep_symbols_list, ",")
+		} else if Bool(stripper.StripProperties.Strip.Keep_symbols) {
 			flags.StripKeepSymbols = true
 		} else if Bool(stripper.StripProperties.Strip.Keep_symbols_and_debug_frame) {
 			flags.StripKeepSymbolsAndDebugFrame = true
-		} else if len(stripper.StripProperties.Strip.Keep_symbols_list) > 0 {
-			flags.StripKeepSymbolsList = strings.Join(stripper.StripProperties.Strip.Keep_symbols_list, ",")
-		} else if !Bool(stripper.StripProperties.Strip.All) {
+		} else if !Bool(stripper.StripProperties.Strip.All) && !hermeticHostStrip(actx) {
 			flags.StripKeepMiniDebugInfo = true
 		}
-		if actx.Config().Debuggable() && !flags.StripKeepMiniDebugInfo && !isStaticLib {
+		if actx.Config().Debuggable() && !flags.StripKeepMiniDebugInfo && !isStaticLib &&
+			!hermeticHostStrip(actx) {
 			flags.StripAddGnuDebuglink = true
 		}
 		transformStrip(actx, in, out, flags)
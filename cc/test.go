@@ -44,6 +44,11 @@ type TestOptions struct {
 
 	// If the test is a hostside(no device required) unittest that shall be run during presubmit check.
 	Unit_test *bool
+
+	// Options passed to the test runner in the auto generated test config, in the form
+	// "<name>=<value>".  $(location <label>) and $(locations <label>) in the values expand to the
+	// paths of the files in data with the given label, relative to the directory of the test.
+	Tradefed_options []string
 }
 
 type TestBinaryProperties struct {
@@ -338,19 +343,10 @@ func (test *testBinary) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 	return flags
 }
 
-func (test *testBinary) install(ctx ModuleContext, file android.Path) {
-	// TODO: (b/167308193) Switch to /data/local/tests/unrestricted as the default install base.
-	testInstallBase := "/data/local/tmp"
-	if ctx.inVendor() || ctx.useVndk() {
-		testInstallBase = "/data/local/tests/vendor"
-	}
-
-	dataSrcPaths := android.PathsForModuleSrc(ctx, test.Properties.Data)
-
-	for _, dataSrcPath := range dataSrcPaths {
-		test.data = append(test.data, android.DataPath{SrcPath: dataSrcPath})
-	}
-
+// dataLibPaths returns the outputs of the data_libs dependencies of a test or benchmark, to be
+// installed alongside it.
+func dataLibPaths(ctx ModuleContext) []android.DataPath {
+	var data []android.DataPath
 	ctx.VisitDirectDepsWithTag(dataLibDepTag, func(dep android.Module) {
 		depName := ctx.OtherModuleName(dep)
 		ccDep, ok := dep.(LinkableInterface)
@@ -363,11 +359,51 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 			ctx.ModuleErrorf("data_lib %q is not a cc module", depName)
 		}
 		if ccDep.OutputFile().Valid() {
-			test.data = append(test.data,
+			data = append(data,
 				android.DataPath{SrcPath: ccDep.OutputFile().Path(),
 					RelativeInstallPath: ccModule.installer.relativeInstallPath()})
 		}
 	})
+	return data
+}
+
+// runtimeLibPaths returns the files installed by the runtime_libs dependencies of a test, including
+// their own transitive installed dependencies, to be installed alongside the test in test suites.
+// Make's LOCAL_REQUIRED_MODULES only installs them into the images, leaving them missing from the
+// test zips. Each file keeps its path relative to its partition, e.g. lib64/libfoo.so.
+func runtimeLibPaths(ctx ModuleContext) []android.DataPath {
+	var data []android.DataPath
+	seen := make(map[string]bool)
+	ctx.VisitDirectDepsWithTag(runtimeDepTag, func(dep android.Module) {
+		for _, spec := range dep.TransitivePackagingSpecs() {
+			if spec.SrcPath() == nil || seen[spec.RelPathInPackage()] {
+				continue
+			}
+			seen[spec.RelPathInPackage()] = true
+			data = append(data, android.DataPath{SrcPath: spec.SrcPath(),
+				RelativeInstallPath: filepath.Dir(spec.RelPathInPackage())})
+		}
+	})
+	return data
+}
+
+func (test *testBinary) install(ctx ModuleContext, file android.Path) {
+	// TODO: (b/167308193) Switch to /data/local/tests/unrestricted as the default install base.
+	testInstallBase := "/data/local/tmp"
+	if ctx.inVendor() || ctx.useVndk() {
+		testInstallBase = "/data/local/tests/vendor"
+	}
+
+	dataSrcPaths := android.PathsForModuleSrc(ctx, test.Properties.Data)
+
+	for _, dataSrcPath := range dataSrcPaths {
+		test.data = append(test.data, android.DataPath{SrcPath: dataSrcPath})
+	}
+
+	test.data = append(test.data, dataLibPaths(ctx)...)
+	if len(test.Properties.Test_suites) > 0 {
+		test.data = append(test.data, runtimeLibPaths(ctx)...)
+	}
 
 	var apiLevelProp string
 	var configs []tradefed.Config
@@ -395,6 +431,21 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 	for _, tag := range test.Properties.Test_options.Test_suite_tag {
 		configs = append(configs, tradefed.Option{Name: "test-suite-tag", Value: tag})
 	}
+	var optionNames, optionValues []string
+	for _, option := range test.Properties.Test_options.Tradefed_options {
+		i := strings.IndexByte(option, '=')
+		if i < 0 {
+			ctx.PropertyErrorf("test_options.tradefed_options", "%q is not in the form <name>=<value>", option)
+			continue
+		}
+		optionNames = append(optionNames, option[:i])
+		optionValues = append(optionValues, option[i+1:])
+	}
+	optionValues, _ = android.ExpandDataLocations(ctx, "test_options.tradefed_options", optionValues,
+		test.Properties.Data)
+	for i, name := range optionNames {
+		configs = append(configs, tradefed.Option{Name: name, Value: optionValues[i]})
+	}
 	if test.Properties.Test_min_api_level != nil && test.Properties.Test_min_sdk_version != nil {
 		ctx.PropertyErrorf("test_min_api_level", "'test_min_api_level' and 'test_min_sdk_version' should not be set at the same time.")
 	} else if test.Properties.Test_min_api_level != nil {
@@ -488,7 +539,10 @@ func NewTestLibrary(hod android.HostOrDeviceSupported) *Module {
 type BenchmarkProperties struct {
 	// list of files or filegroup modules that provide data that should be installed alongside
 	// the test
-	Data []string `android:"path"`
+	Data []string `android:"path,arch_variant"`
+
+	// list of shared library modules that should be installed alongside the test
+	Data_libs []string `android:"arch_variant"`
 
 	// list of compatibility suites (for example "cts", "vts") that the module should be
 	// installed into.
@@ -506,6 +560,9 @@ type BenchmarkProperties struct {
 	// with root permission.
 	Require_root *bool
 
+	// Add RunCommandTargetPreparer to stop framework before the test and start it after the test.
+	Disable_framework *bool
+
 	// Flag to indicate whether or not to create test config automatically. If AndroidTest.xml
 	// doesn't exist next to the Android.bp, this attribute doesn't need to be set to true
 	// explicitly.
@@ -515,7 +572,7 @@ type BenchmarkProperties struct {
 type benchmarkDecorator struct {
 	*binaryDecorator
 	Properties BenchmarkProperties
-	data       android.Paths
+	data       []android.DataPath
 	testConfig android.Path
 }
 
@@ -537,16 +594,28 @@ func (benchmark *benchmarkDecorator) linkerProps() []interface{} {
 func (benchmark *benchmarkDecorator) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	deps = benchmark.binaryDecorator.linkerDeps(ctx, deps)
 	deps.StaticLibs = append(deps.StaticLibs, "libgoogle-benchmark")
+	deps.DataLibs = append(deps.DataLibs, benchmark.Properties.Data_libs...)
 	return deps
 }
 
+func (benchmark *benchmarkDecorator) dataPaths() []android.DataPath {
+	return benchmark.data
+}
+
 func (benchmark *benchmarkDecorator) install(ctx ModuleContext, file android.Path) {
-	benchmark.data = android.PathsForModuleSrc(ctx, benchmark.Properties.Data)
+	for _, dataSrcPath := range android.PathsForModuleSrc(ctx, benchmark.Properties.Data) {
+		benchmark.data = append(benchmark.data, android.DataPath{SrcPath: dataSrcPath})
+	}
+	benchmark.data = append(benchmark.data, dataLibPaths(ctx)...)
 
 	var configs []tradefed.Config
 	if Bool(benchmark.Properties.Require_root) {
 		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", nil})
 	}
+	if Bool(benchmark.Properties.Disable_framework) {
+		var options []tradefed.Option
+		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.StopServicesSetup", options})
+	}
 	benchmark.testConfig = tradefed.AutoGenNativeBenchmarkTestConfig(ctx, benchmark.Properties.Test_config,
 		benchmark.Properties.Test_config_template, benchmark.Properties.Test_suites, configs, benchmark.Properties.Auto_gen_config)
 
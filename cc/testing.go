@@ -192,6 +192,7 @@ func GatherRequiredDepsForTest(oses ...android.OsType) string {
 			system_shared_libs: [],
 			recovery_available: true,
 			stubs: {
+				symbol_file: "libc.map.txt",
 				versions: ["27", "28", "29"],
 			},
 			llndk_stubs: "libc.llndk",
@@ -210,6 +211,7 @@ func GatherRequiredDepsForTest(oses ...android.OsType) string {
 			system_shared_libs: [],
 			recovery_available: true,
 			stubs: {
+				symbol_file: "libm.map.txt",
 				versions: ["27", "28", "29"],
 			},
 			apex_available: [
@@ -275,6 +277,7 @@ func GatherRequiredDepsForTest(oses ...android.OsType) string {
 			system_shared_libs: [],
 			recovery_available: true,
 			stubs: {
+				symbol_file: "libdl.map.txt",
 				versions: ["27", "28", "29"],
 			},
 			apex_available: [
@@ -578,6 +581,8 @@ func CreateTestContext(config android.Config) *android.TestContext {
 	ctx.RegisterSingletonType("vndk-snapshot", VndkSnapshotSingleton)
 	ctx.RegisterSingletonType("vendor-snapshot", VendorSnapshotSingleton)
 	ctx.RegisterSingletonType("recovery-snapshot", RecoverySnapshotSingleton)
+	ctx.RegisterSingletonType("cc_deps_audit", ccDepsAuditSingletonFactory)
+	ctx.RegisterSingletonType("cc_fuzz_packaging", fuzzPackagingFactory)
 
 	return ctx
 }
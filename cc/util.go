@@ -86,6 +86,10 @@ func flagsToBuilderFlags(in Flags) builderFlags {
 		tidy:          in.Tidy,
 		sAbiDump:      in.SAbiDump,
 		emitXrefs:     in.EmitXrefs,
+		depsAudit:     in.DepsAudit,
+
+		aidlStrictImports:    in.aidlStrictImports,
+		aidlStrictImportDirs: in.aidlStrictImportDirs,
 
 		systemIncludeFlags: strings.Join(in.SystemIncludeFlags, " "),
 
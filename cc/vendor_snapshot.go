@@ -288,6 +288,7 @@ func isSnapshotAware(m *Module, inProprietaryPath bool, apexInfo android.ApexInf
 type snapshotJsonFlags struct {
 	ModuleName          string `json:",omitempty"`
 	RelativeInstallPath string `json:",omitempty"`
+	MinSdkVersion       string `json:",omitempty"`
 
 	// library flags
 	ExportedDirs       []string `json:",omitempty"`
@@ -302,6 +303,8 @@ type snapshotJsonFlags struct {
 
 	// dependencies
 	SharedLibs  []string `json:",omitempty"`
+	StaticLibs  []string `json:",omitempty"`
+	HeaderLibs  []string `json:",omitempty"`
 	RuntimeLibs []string `json:",omitempty"`
 	Required    []string `json:",omitempty"`
 
@@ -386,6 +389,8 @@ func (c *snapshotSingleton) GenerateBuildActions(ctx android.SingletonContext) {
 		} else {
 			prop.RelativeInstallPath = m.RelativeInstallPath()
 		}
+		prop.MinSdkVersion = m.MinSdkVersion()
+		prop.HeaderLibs = m.Properties.SnapshotHeaderLibs
 		prop.RuntimeLibs = m.Properties.SnapshotRuntimeLibs
 		prop.Required = m.RequiredModuleNames()
 		for _, path := range m.InitRc() {
@@ -421,6 +426,10 @@ func (c *snapshotSingleton) GenerateBuildActions(ctx android.SingletonContext) {
 			if l.shared() {
 				prop.SharedLibs = m.Properties.SnapshotSharedLibs
 			}
+			// static libs dependencies are only needed to link static libs
+			if l.static() {
+				prop.StaticLibs = m.Properties.SnapshotStaticLibs
+			}
 			if l.static() && m.sanitize != nil {
 				prop.SanitizeMinimalDep = m.sanitize.Properties.MinimalRuntimeDep || enableMinimalRuntime(m.sanitize)
 				prop.SanitizeUbsanDep = m.sanitize.Properties.UbsanRuntimeDep || enableUbsanRuntime(m.sanitize)
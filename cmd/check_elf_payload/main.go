@@ -0,0 +1,215 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Verifies that the ELF files in an APEX payload use one of the device's dynamic linkers, and that they
+// don't have absolute RPATH or RUNPATH entries outside of /apex and /system, which would make
+// them load libraries from locations that aren't guaranteed to exist on the device.
+package main
+
+import (
+	"bufio"
+	"debug/elf"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	fileList      = flag.String("l", "", "file containing a list of '<path in payload> <built file>' lines")
+	outputFile    = flag.String("o", "", "timestamp file to write when all files pass")
+	interpreter   = flag.String("interpreter", strings.Join(defaultInterpreters(""), ","), "comma separated allowed interpreters of 32-bit executables")
+	interpreter64 = flag.String("interpreter64", strings.Join(defaultInterpreters("64"), ","), "comma separated allowed interpreters of 64-bit executables")
+)
+
+// defaultInterpreters returns the dynamic linkers that cc sets as the interpreter of executables
+// with the given suffix: the regular linker, the one of the bootstrap binaries, and their ASan
+// versions.
+func defaultInterpreters(suffix string) []string {
+	return []string{
+		"/system/bin/linker" + suffix,
+		"/system/bin/linker_asan" + suffix,
+		"/system/bin/bootstrap/linker" + suffix,
+		"/system/bin/bootstrap/linker_asan" + suffix,
+	}
+}
+
+// allowedRunpathDirs are the directories that absolute RPATH and RUNPATH entries may point into.
+var allowedRunpathDirs = []string{"/apex", "/system"}
+
+func main() {
+	flag.Parse()
+
+	if *fileList == "" || *outputFile == "" || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	f, err := os.Open(*fileList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	var findings []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			fmt.Fprintf(os.Stderr, "invalid line in %s: %q\n", *fileList, line)
+			os.Exit(1)
+		}
+		pathInPayload, builtFile := fields[0], fields[1]
+
+		info, err := readElf(builtFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", builtFile, err)
+			os.Exit(1)
+		}
+		if info == nil {
+			// Not an ELF file.
+			continue
+		}
+		for _, finding := range checkElf(info) {
+			findings = append(findings, pathInPayload+": "+finding)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if len(findings) > 0 {
+		fmt.Fprintln(os.Stderr, "ELF files in the payload don't follow the dynamic linking policy:")
+		for _, finding := range findings {
+			fmt.Fprintln(os.Stderr, "  "+finding)
+		}
+		os.Exit(1)
+	}
+
+	if err := ioutil.WriteFile(*outputFile, nil, 0666); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+// elfInfo holds the properties of an ELF file that affect how it is dynamically linked.
+type elfInfo struct {
+	class       elf.Class
+	interpreter string // empty if the file has no PT_INTERP segment
+	rpaths      []string
+	runpaths    []string
+}
+
+// readElf returns the dynamic linking properties of the ELF file at path, or nil if it is not an
+// ELF file.
+func readElf(path string) (*elfInfo, error) {
+	f, err := elf.Open(path)
+	if _, ok := err.(*elf.FormatError); ok {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info := &elfInfo{class: f.Class}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_INTERP {
+			buf, err := ioutil.ReadAll(prog.Open())
+			if err != nil {
+				return nil, err
+			}
+			info.interpreter = strings.TrimRight(string(buf), "\x00")
+		}
+	}
+
+	// Files without a dynamic section, like static executables, have no RPATH or RUNPATH.
+	if f.Section(".dynamic") == nil {
+		return info, nil
+	}
+	if info.rpaths, err = dynPaths(f, elf.DT_RPATH); err != nil {
+		return nil, err
+	}
+	if info.runpaths, err = dynPaths(f, elf.DT_RUNPATH); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func dynPaths(f *elf.File, tag elf.DynTag) ([]string, error) {
+	values, err := f.DynString(tag)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, value := range values {
+		paths = append(paths, strings.Split(value, ":")...)
+	}
+	return paths, nil
+}
+
+// checkElf returns a description of each way the ELF file violates the dynamic linking policy.
+func checkElf(info *elfInfo) []string {
+	var findings []string
+
+	if info.interpreter != "" {
+		allowed := *interpreter
+		if info.class == elf.ELFCLASS64 {
+			allowed = *interpreter64
+		}
+		if !inList(info.interpreter, strings.Split(allowed, ",")) {
+			findings = append(findings,
+				fmt.Sprintf("interpreter is %q, expected one of %s", info.interpreter, allowed))
+		}
+	}
+
+	check := func(tag string, paths []string) {
+		for _, path := range paths {
+			if filepath.IsAbs(path) && !isUnderAny(path, allowedRunpathDirs) {
+				findings = append(findings, fmt.Sprintf("%s entry %q is outside of %s",
+					tag, path, strings.Join(allowedRunpathDirs, " and ")))
+			}
+		}
+	}
+	check("RPATH", info.rpaths)
+	check("RUNPATH", info.runpaths)
+
+	return findings
+}
+
+func inList(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnderAny(path string, dirs []string) bool {
+	path = filepath.Clean(path)
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"debug/elf"
+	"reflect"
+	"testing"
+)
+
+func TestCheckElf(t *testing.T) {
+	interpreters64 := "/system/bin/linker64,/system/bin/linker_asan64,/system/bin/bootstrap/linker64,/system/bin/bootstrap/linker_asan64"
+
+	testCases := []struct {
+		name     string
+		info     elfInfo
+		findings []string
+	}{
+		{
+			name: "shared library",
+			info: elfInfo{class: elf.ELFCLASS64},
+		},
+		{
+			name: "64-bit executable",
+			info: elfInfo{class: elf.ELFCLASS64, interpreter: "/system/bin/linker64"},
+		},
+		{
+			name: "32-bit executable",
+			info: elfInfo{class: elf.ELFCLASS32, interpreter: "/system/bin/linker"},
+		},
+		{
+			name: "ASan executable",
+			info: elfInfo{class: elf.ELFCLASS64, interpreter: "/system/bin/linker_asan64"},
+		},
+		{
+			name: "bootstrap executable",
+			info: elfInfo{class: elf.ELFCLASS32, interpreter: "/system/bin/bootstrap/linker"},
+		},
+		{
+			name: "wrong interpreter",
+			info: elfInfo{class: elf.ELFCLASS64, interpreter: "/lib64/ld-linux-x86-64.so.2"},
+			findings: []string{
+				`interpreter is "/lib64/ld-linux-x86-64.so.2", expected one of ` + interpreters64,
+			},
+		},
+		{
+			name: "32-bit interpreter in 64-bit executable",
+			info: elfInfo{class: elf.ELFCLASS64, interpreter: "/system/bin/linker"},
+			findings: []string{
+				`interpreter is "/system/bin/linker", expected one of ` + interpreters64,
+			},
+		},
+		{
+			name: "allowed runpaths",
+			info: elfInfo{
+				class:    elf.ELFCLASS64,
+				rpaths:   []string{"$ORIGIN/../lib64"},
+				runpaths: []string{"/apex/com.android.foo/lib64", "/system/lib64"},
+			},
+		},
+		{
+			name: "absolute runpaths",
+			info: elfInfo{
+				class:    elf.ELFCLASS64,
+				rpaths:   []string{"/vendor/lib64"},
+				runpaths: []string{"/systemfoo/lib64", "/apex/../data/lib64"},
+			},
+			findings: []string{
+				`RPATH entry "/vendor/lib64" is outside of /apex and /system`,
+				`RUNPATH entry "/systemfoo/lib64" is outside of /apex and /system`,
+				`RUNPATH entry "/apex/../data/lib64" is outside of /apex and /system`,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			findings := checkElf(&testCase.info)
+			if !reflect.DeepEqual(findings, testCase.findings) {
+				t.Errorf("expected findings %q, got %q", testCase.findings, findings)
+			}
+		})
+	}
+}
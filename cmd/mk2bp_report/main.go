@@ -0,0 +1,172 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// mk2bp_report scores the remaining Android.mk files in the tree by how hard they are to convert
+// to Android.bp files, and lists the Soong module types that would replace them.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/soong/androidmk/androidmk"
+)
+
+var (
+	fileList   = flag.String("l", "", "file containing the list of Android.mk files to assess")
+	outputFile = flag.String("o", "", "report file to write")
+	depFile    = flag.String("d", "", "depfile to write, listing the assessed Android.mk files")
+)
+
+// directoryReport is the combined assessment of the Android.mk files in a directory.
+type directoryReport struct {
+	dir         string
+	assessments []androidmk.Assessment
+	errs        []error
+}
+
+func (r *directoryReport) score() int {
+	score := 0
+	for _, a := range r.assessments {
+		score += a.Score()
+	}
+	return score
+}
+
+func (r *directoryReport) readiness() string {
+	if len(r.errs) > 0 {
+		return "unparseable"
+	}
+	return androidmk.ReadinessForScore(r.score())
+}
+
+// union returns the sorted, deduplicated values returned by field for each assessment.
+func (r *directoryReport) union(field func(androidmk.Assessment) []string) []string {
+	seen := make(map[string]bool)
+	var ret []string
+	for _, a := range r.assessments {
+		for _, v := range field(a) {
+			if !seen[v] {
+				seen[v] = true
+				ret = append(ret, v)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func main() {
+	flag.Parse()
+
+	if *fileList == "" || *outputFile == "" || flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	list, err := ioutil.ReadFile(*fileList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	files := strings.Fields(string(list))
+
+	reports := make(map[string]*directoryReport)
+	for _, file := range files {
+		dir := filepath.Dir(file)
+		report := reports[dir]
+		if report == nil {
+			report = &directoryReport{dir: dir}
+			reports[dir] = report
+		}
+
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		assessment, errs := androidmk.AssessFile(file, bytes.NewBuffer(contents))
+		if len(errs) > 0 {
+			report.errs = append(report.errs, errs...)
+			continue
+		}
+		report.assessments = append(report.assessments, assessment)
+	}
+
+	if err := ioutil.WriteFile(*outputFile, []byte(formatReport(reports)), 0666); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if *depFile != "" {
+		deps := *outputFile + ": " + strings.Join(append([]string{*fileList}, files...), " ") + "\n"
+		if err := ioutil.WriteFile(*depFile, []byte(deps), 0666); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+// formatReport returns a tab separated table with a line for each directory, ordered from the
+// easiest to the hardest to convert. Directories with Android.mk files that can't be parsed are
+// listed last.
+func formatReport(reports map[string]*directoryReport) string {
+	var sorted []*directoryReport
+	for _, report := range reports {
+		sorted = append(sorted, report)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (len(a.errs) > 0) != (len(b.errs) > 0) {
+			return len(b.errs) > 0
+		}
+		if a.score() != b.score() {
+			return a.score() < b.score()
+		}
+		return a.dir < b.dir
+	})
+
+	counts := make(map[string]int)
+	for _, report := range sorted {
+		counts[report.readiness()]++
+	}
+
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "# %d directories with Android.mk files:", len(sorted))
+	for _, readiness := range []string{"ready", "easy", "moderate", "hard", "unparseable"} {
+		fmt.Fprintf(buf, " %d %s", counts[readiness], readiness)
+	}
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "# directory\treadiness\tscore\tmodule types\ttools\tspecial variables")
+
+	for _, report := range sorted {
+		if len(report.errs) > 0 {
+			fmt.Fprintf(buf, "%s\t%s\t\t\t\t%s\n", report.dir, report.readiness(),
+				strings.Join(strings.Fields(report.errs[0].Error()), " "))
+			continue
+		}
+		fmt.Fprintf(buf, "%s\t%s\t%d\t%s\t%s\t%s\n", report.dir, report.readiness(), report.score(),
+			strings.Join(report.union(func(a androidmk.Assessment) []string { return a.ModuleTypes }), ","),
+			strings.Join(report.union(func(a androidmk.Assessment) []string { return a.Tools }), ","),
+			strings.Join(report.union(func(a androidmk.Assessment) []string { return a.SpecialVariables }), ","))
+	}
+
+	return buf.String()
+}
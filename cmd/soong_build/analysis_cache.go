@@ -0,0 +1,185 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/blueprint/deptools"
+)
+
+// Ninja reruns soong_build whenever the timestamp of one of its inputs changes, even if the content
+// of the input didn't change, for example after switching branches back and forth or touching an
+// Android.bp file.  The analysis cache records the content hashes of all the inputs of a
+// successful run, so that the next run can reuse the ninja file it wrote instead of running the
+// whole analysis again when none of them changed.
+
+// analysisCacheInput is an input of soong_build and the hash of its content.
+type analysisCacheInput struct {
+	Path string
+	Hash string
+}
+
+// analysisCache describes everything the ninja file written by a soong_build run depends on.
+type analysisCache struct {
+	// The command line of soong_build, which includes the path of the ninja file.
+	Args []string
+
+	// The environment variables that were read, and their values.
+	Env map[string]string
+
+	// The files that were read, in the order of the depfile, including the soong_build binary.
+	Inputs []analysisCacheInput
+}
+
+// hashFile returns the hex encoded hash of the content of a file.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// parseDepFile returns the dependencies listed in a depfile written by deptools.WriteDepFile.
+func parseDepFile(depFile string) ([]string, error) {
+	data, err := ioutil.ReadFile(depFile)
+	if err != nil {
+		return nil, err
+	}
+	content := strings.ReplaceAll(string(data), "\\\n", " ")
+	if i := strings.Index(content, ": "); i >= 0 {
+		content = content[i+2:]
+	}
+
+	var deps []string
+	var dep strings.Builder
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '\\' && i+1 < len(content) && content[i+1] == ' ':
+			dep.WriteByte(' ')
+			i++
+		case c == ' ' || c == '\t' || c == '\n':
+			if dep.Len() > 0 {
+				deps = append(deps, dep.String())
+				dep.Reset()
+			}
+		default:
+			dep.WriteByte(c)
+		}
+	}
+	if dep.Len() > 0 {
+		deps = append(deps, dep.String())
+	}
+	return deps, nil
+}
+
+// writeAnalysisCache records the inputs of the ninja file written by a successful soong_build run.
+// The inputs are the dependencies in depFile and the extra files, usually the soong_build binary.
+func writeAnalysisCache(cacheFile, depFile string, args []string, env map[string]string,
+	extraInputs ...string) error {
+
+	deps, err := parseDepFile(depFile)
+	if err != nil {
+		return err
+	}
+
+	cache := analysisCache{Args: args, Env: env}
+	for _, path := range append(deps, extraInputs...) {
+		hash, err := hashFile(path)
+		if err != nil {
+			// An input that doesn't exist, e.g. the file used to force a rerun for the
+			// debugger, can never be cached.
+			os.Remove(cacheFile)
+			return nil
+		}
+		cache.Inputs = append(cache.Inputs, analysisCacheInput{path, hash})
+	}
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cacheFile, data, 0666)
+}
+
+// analysisUpToDate returns true if the ninja file recorded in cacheFile can be reused: soong_build
+// is run with the same arguments, and the environment variables and files it read are unchanged.
+func analysisUpToDate(cacheFile string, args []string, getenv func(string) string) bool {
+	data, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return false
+	}
+	var cache analysisCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return false
+	}
+
+	if !reflect.DeepEqual(cache.Args, args) {
+		return false
+	}
+	for key, value := range cache.Env {
+		if getenv(key) != value {
+			return false
+		}
+	}
+	for _, input := range cache.Inputs {
+		if hash, err := hashFile(input.Path); err != nil || hash != input.Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// reuseAnalysis makes ninja consider the outputs of the previous soong_build run as up to date
+// without running the analysis again.  The depfile is written again, as ninja removes it after
+// reading it, and the ninja file is touched so that it is newer than the inputs.
+func reuseAnalysis(cacheFile, outFile, depFile string) error {
+	data, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return err
+	}
+	var cache analysisCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return err
+	}
+
+	if depFile != "" {
+		var deps []string
+		for _, input := range cache.Inputs {
+			deps = append(deps, input.Path)
+		}
+		if err := deptools.WriteDepFile(depFile, outFile, deps); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	return os.Chtimes(outFile, now, now)
+}
@@ -0,0 +1,127 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/blueprint/deptools"
+)
+
+func TestParseDepFile(t *testing.T) {
+	dir := filepath.Join(buildDir, "parse_dep_file")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	depFile := filepath.Join(dir, "build.ninja.d")
+	deps := []string{"Android.bp", "foo/Android.bp", "with space/Android.bp"}
+	if err := deptools.WriteDepFile(depFile, "build.ninja", deps); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parseDepFile(depFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, deps) {
+		t.Errorf("expected deps %q, got %q", deps, got)
+	}
+}
+
+func TestAnalysisCache(t *testing.T) {
+	dir := filepath.Join(buildDir, "analysis_cache")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	bp := filepath.Join(dir, "Android.bp")
+	binary := filepath.Join(dir, "soong_build")
+	outFile := filepath.Join(dir, "build.ninja")
+	depFile := outFile + ".d"
+	cacheFile := outFile + ".analysis_cache"
+	var args []string
+	var env map[string]string
+	getenv := func(key string) string { return env[key] }
+
+	// writeCache resets the inputs and records them in the cache.
+	writeCache := func() {
+		t.Helper()
+		for file, content := range map[string]string{bp: "foo {}", binary: "v1", outFile: "ninja"} {
+			if err := ioutil.WriteFile(file, []byte(content), 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+		args = []string{"soong_build", "-o", outFile, "-d", depFile, "Android.bp"}
+		env = map[string]string{"FOO": "foo"}
+		if err := deptools.WriteDepFile(depFile, outFile, []string{bp}); err != nil {
+			t.Fatal(err)
+		}
+		err := writeAnalysisCache(cacheFile, depFile, args, map[string]string{"FOO": "foo"}, binary)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeCache()
+	if !analysisUpToDate(cacheFile, args, getenv) {
+		t.Error("expected the analysis to be up to date after writing the cache")
+	}
+
+	// Touching an input without changing its content keeps the analysis up to date.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(bp, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if !analysisUpToDate(cacheFile, args, getenv) {
+		t.Error("expected the analysis to be up to date after touching an input")
+	}
+
+	// Reusing the analysis writes the depfile again and makes the ninja file newer than the inputs.
+	os.Remove(depFile)
+	if err := reuseAnalysis(cacheFile, outFile, depFile); err != nil {
+		t.Fatal(err)
+	}
+	if deps, err := parseDepFile(depFile); err != nil {
+		t.Error(err)
+	} else if expected := []string{bp, binary}; !reflect.DeepEqual(deps, expected) {
+		t.Errorf("expected deps %q, got %q", expected, deps)
+	}
+	if info, err := os.Stat(outFile); err != nil {
+		t.Error(err)
+	} else if !info.ModTime().After(time.Now().Add(-time.Minute)) {
+		t.Errorf("expected %s to be touched, got mtime %s", outFile, info.ModTime())
+	}
+
+	check := func(name string, change func()) {
+		t.Helper()
+		writeCache()
+		change()
+		if analysisUpToDate(cacheFile, args, getenv) {
+			t.Errorf("expected the analysis to be out of date after %s", name)
+		}
+	}
+
+	check("changing an input", func() { ioutil.WriteFile(bp, []byte("bar {}"), 0666) })
+	check("removing an input", func() { os.Remove(bp) })
+	check("changing the binary", func() { ioutil.WriteFile(binary, []byte("v2"), 0666) })
+	check("changing an environment variable", func() { env["FOO"] = "bar" })
+	check("changing the arguments", func() { args = append(args, "-t") })
+}
@@ -0,0 +1,205 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"android/soong/android"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+// The name of the bp2build conversion coverage report, relative to the bp2build output directory.
+const bp2buildMetricsFile = "bp2build_metrics.json"
+
+// Reasons for a module not being converted to Bazel.
+const (
+	// The module type has no rule shim, so modules of the type have no Bazel equivalent.
+	missingHandlerReason = "missing_handler"
+	// The module sets a property that is dropped during the conversion.
+	unsupportedPropertyReason = "unsupported_property"
+)
+
+// conversionCount counts the converted modules out of a set of modules.
+type conversionCount struct {
+	Total     int `json:"total"`
+	Converted int `json:"converted"`
+}
+
+func (c *conversionCount) add(converted bool) {
+	c.Total++
+	if converted {
+		c.Converted++
+	}
+}
+
+type unconvertedReason struct {
+	Reason string `json:"reason"`
+
+	// The name of the unsupported property, for unsupported_property reasons.
+	Property string `json:"property,omitempty"`
+}
+
+type unconvertedModule struct {
+	Name       string              `json:"name"`
+	Variant    string              `json:"variant,omitempty"`
+	ModuleType string              `json:"module_type"`
+	Directory  string              `json:"directory"`
+	Reasons    []unconvertedReason `json:"reasons"`
+}
+
+// bp2buildMetrics is the bp2build conversion coverage report, which tracks how many modules are
+// converted to Bazel per module type and per directory, and why the remaining ones aren't.
+type bp2buildMetrics struct {
+	Modules            conversionCount             `json:"modules"`
+	ModuleTypes        map[string]*conversionCount `json:"module_types"`
+	Directories        map[string]*conversionCount `json:"directories"`
+	UnconvertedModules []unconvertedModule         `json:"unconverted_modules"`
+}
+
+// unsupportedProperties returns the sorted names of the properties that are set in the module but
+// are dropped when the module is converted to a Bazel target.
+func unsupportedProperties(aModule android.Module) []string {
+	var ret []string
+	for _, properties := range aModule.GetProperties() {
+		propertiesValue := reflect.ValueOf(properties)
+		if !isStructPtr(propertiesValue.Type()) {
+			continue
+		}
+		structValue := propertiesValue.Elem()
+		structType := structValue.Type()
+		for i := 0; i < structValue.NumField(); i++ {
+			field := structType.Field(i)
+			if field.PkgPath != "" || proptools.HasTag(field, "blueprint", "mutated") {
+				continue
+			}
+			propertyName := proptools.PropertyNameForField(field.Name)
+			if propertyName == "name" {
+				// The name is the target name.
+				continue
+			}
+
+			fieldValue := structValue.Field(i)
+			isInterface := fieldValue.Kind() == reflect.Interface
+			if isInterface {
+				// Interfaces are used for arch, multilib and target properties, and hold a
+				// nil pointer when the property isn't set.
+				if fieldValue.IsNil() {
+					continue
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if isZero(fieldValue) {
+				continue
+			}
+
+			if isInterface || !shouldGenerateAttribute(propertyName) {
+				ret = append(ret, propertyName)
+			}
+		}
+	}
+	ret = android.FirstUniqueStrings(ret)
+	sort.Strings(ret)
+	return ret
+}
+
+// collectBp2buildMetrics returns the conversion coverage of all modules in the context, given the
+// names of the rules that module types are converted to.
+func collectBp2buildMetrics(blueprintCtx *blueprint.Context, rules map[string]bool) *bp2buildMetrics {
+	metrics := &bp2buildMetrics{
+		ModuleTypes: map[string]*conversionCount{},
+		Directories: map[string]*conversionCount{},
+	}
+
+	count := func(counts map[string]*conversionCount, key string, converted bool) {
+		if counts[key] == nil {
+			counts[key] = &conversionCount{}
+		}
+		counts[key].add(converted)
+	}
+
+	blueprintCtx.VisitAllModules(func(module blueprint.Module) {
+		moduleType := blueprintCtx.ModuleType(module)
+		dir := packagePath(blueprintCtx, module)
+
+		var reasons []unconvertedReason
+		if !rules[canonicalizeModuleType(moduleType)] {
+			reasons = append(reasons, unconvertedReason{Reason: missingHandlerReason})
+		}
+		if aModule, ok := module.(android.Module); ok {
+			for _, property := range unsupportedProperties(aModule) {
+				reasons = append(reasons, unconvertedReason{
+					Reason:   unsupportedPropertyReason,
+					Property: property,
+				})
+			}
+		}
+
+		converted := len(reasons) == 0
+		metrics.Modules.add(converted)
+		count(metrics.ModuleTypes, moduleType, converted)
+		count(metrics.Directories, dir, converted)
+		if !converted {
+			metrics.UnconvertedModules = append(metrics.UnconvertedModules, unconvertedModule{
+				Name:       blueprintCtx.ModuleName(module),
+				Variant:    blueprintCtx.ModuleSubDir(module),
+				ModuleType: moduleType,
+				Directory:  dir,
+				Reasons:    reasons,
+			})
+		}
+	})
+
+	sort.SliceStable(metrics.UnconvertedModules, func(i, j int) bool {
+		a, b := metrics.UnconvertedModules[i], metrics.UnconvertedModules[j]
+		if a.Directory != b.Directory {
+			return a.Directory < b.Directory
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Variant < b.Variant
+	})
+
+	return metrics
+}
+
+// Writes the bp2build conversion coverage report into the bp2build output directory.
+func addBp2buildMetrics(ctx *android.Context, files outputTree) error {
+	packages, err := getPackages(ctx)
+	if err != nil {
+		return err
+	}
+	ruleShims, err := createRuleShims(packages)
+	if err != nil {
+		return err
+	}
+	rules := map[string]bool{}
+	for _, ruleShim := range ruleShims {
+		for _, rule := range ruleShim.rules {
+			rules[rule] = true
+		}
+	}
+
+	data, err := json.MarshalIndent(collectBp2buildMetrics(ctx.Context, rules), "", "  ")
+	if err != nil {
+		return err
+	}
+	files[bp2buildMetricsFile] = string(data) + "\n"
+	return nil
+}
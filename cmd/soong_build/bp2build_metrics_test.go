@@ -0,0 +1,92 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"android/soong/android"
+	"reflect"
+	"testing"
+)
+
+func TestBp2buildMetrics(t *testing.T) {
+	bp := `
+custom {
+	name: "foo",
+	ramdisk: true,
+}
+
+custom {
+	name: "bar",
+	visibility: ["//visibility:public"],
+}
+
+other {
+	name: "baz",
+}
+`
+	config := android.TestConfig(buildDir, nil, bp, nil)
+	ctx := android.NewTestContext(config)
+	ctx.RegisterModuleType("custom", customModuleFactory)
+	ctx.RegisterModuleType("other", customModuleFactory)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	metrics := collectBp2buildMetrics(ctx.Context.Context, map[string]bool{"custom": true})
+
+	if expected := (conversionCount{Total: 3, Converted: 1}); metrics.Modules != expected {
+		t.Errorf("expected module counts %v, got %v", expected, metrics.Modules)
+	}
+
+	expectedModuleTypes := map[string]*conversionCount{
+		"custom": {Total: 2, Converted: 1},
+		"other":  {Total: 1, Converted: 0},
+	}
+	if !reflect.DeepEqual(metrics.ModuleTypes, expectedModuleTypes) {
+		t.Errorf("expected module type counts %v, got %v", expectedModuleTypes, metrics.ModuleTypes)
+	}
+
+	expectedDirectories := map[string]*conversionCount{
+		".": {Total: 3, Converted: 1},
+	}
+	if !reflect.DeepEqual(metrics.Directories, expectedDirectories) {
+		t.Errorf("expected directory counts %v, got %v", expectedDirectories, metrics.Directories)
+	}
+
+	expectedUnconverted := []unconvertedModule{
+		{
+			Name:       "bar",
+			ModuleType: "custom",
+			Directory:  ".",
+			Reasons: []unconvertedReason{
+				{Reason: unsupportedPropertyReason, Property: "visibility"},
+			},
+		},
+		{
+			Name:       "baz",
+			ModuleType: "other",
+			Directory:  ".",
+			Reasons: []unconvertedReason{
+				{Reason: missingHandlerReason},
+			},
+		},
+	}
+	if !reflect.DeepEqual(metrics.UnconvertedModules, expectedUnconverted) {
+		t.Errorf("expected unconverted modules %v, got %v", expectedUnconverted, metrics.UnconvertedModules)
+	}
+}
@@ -23,16 +23,19 @@ import (
 	"github.com/google/blueprint/bootstrap"
 
 	"android/soong/android"
+	"android/soong/cc/config"
 )
 
 var (
-	docFile           string
-	bazelQueryViewDir string
+	docFile            string
+	bazelQueryViewDir  string
+	namespaceGraphFile string
 )
 
 func init() {
 	flag.StringVar(&docFile, "soong_docs", "", "build documentation file to output")
 	flag.StringVar(&bazelQueryViewDir, "bazel_queryview_dir", "", "path to the bazel queryview directory")
+	flag.StringVar(&namespaceGraphFile, "namespace_graph", "", "soong_namespace import graph file to output")
 }
 
 func newNameResolver(config android.Config) *android.NameResolver {
@@ -48,7 +51,9 @@ func newNameResolver(config android.Config) *android.NameResolver {
 		return namespacePathsToExport[namespace.Path]
 	}
 
-	return android.NewNameResolver(exportFilter)
+	resolver := android.NewNameResolver(exportFilter)
+	resolver.SetNamespaceGraphFile(namespaceGraphFile)
+	return resolver
 }
 
 // bazelConversionRequested checks that the user is intending to convert
@@ -100,6 +105,19 @@ func main() {
 		// enabled even if it completed successfully.
 		extraNinjaDeps = append(extraNinjaDeps, filepath.Join(configuration.BuildDir(), "always_rerun_for_delve"))
 	}
+	// Reuse the ninja file of the previous run if none of its inputs changed content.
+	outFile, depFile := ninjaFileFlags()
+	cacheFile := outFile + ".analysis_cache"
+	useAnalysisCache := shouldPrepareBuildActions(configuration) &&
+		!configuration.BazelContext.BazelEnabled() && outFile != ""
+	if useAnalysisCache && analysisUpToDate(cacheFile, os.Args, os.Getenv) {
+		if err := reuseAnalysis(cacheFile, outFile, depFile); err == nil {
+			return
+		}
+	}
+	os.Remove(cacheFile)
+
+	android.RemoveStaleJsonDiagnostics(configuration)
 	if configuration.BazelContext.BazelEnabled() {
 		// Bazel-enabled mode. Soong runs in two passes.
 		// First pass: Analyze the build tree, but only store all bazel commands
@@ -109,6 +127,17 @@ func main() {
 		firstCtx := newContext(srcDir, configuration)
 		configuration.SetStopBefore(bootstrap.StopBeforeWriteNinja)
 		bootstrap.Main(firstCtx.Context, configuration, extraNinjaDeps...)
+		// Export the cc toolchain configuration for the Bazel build.
+		ccToolchainFiles := outputTree{}
+		err := addCcToolchainVars(configuration, ccToolchainFiles)
+		if err == nil {
+			// The bazel directory contains other files, only write the cc toolchain files.
+			err = ccToolchainFiles.write(filepath.Join(configuration.BuildDir(), "bazel"))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", err)
+			os.Exit(1)
+		}
 		// Invoke bazel commands and save results for second pass.
 		if err := configuration.BazelContext.InvokeBazel(); err != nil {
 			fmt.Fprintf(os.Stderr, "%s", err)
@@ -129,7 +158,24 @@ func main() {
 
 	// Convert the Soong module graph into Bazel BUILD files.
 	if bazelQueryViewDir != "" {
-		if err := createBazelQueryView(ctx, bazelQueryViewDir); err != nil {
+		files, err := bazelQueryViewFiles(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s", err)
+			os.Exit(1)
+		}
+		// Report the conversion coverage of bp2build, and export the cc toolchain configuration.
+		// They are part of the output tree, so that writing it incrementally does not remove them.
+		if bazelConversionRequested(configuration) {
+			if err := addBp2buildMetrics(ctx, files); err != nil {
+				fmt.Fprintf(os.Stderr, "%s", err)
+				os.Exit(1)
+			}
+			if err := addCcToolchainVars(configuration, files); err != nil {
+				fmt.Fprintf(os.Stderr, "%s", err)
+				os.Exit(1)
+			}
+		}
+		if err := files.writeIncrementally(bazelQueryViewDir); err != nil {
 			fmt.Fprintf(os.Stderr, "%s", err)
 			os.Exit(1)
 		}
@@ -152,6 +198,29 @@ func main() {
 			os.Exit(1)
 		}
 	}
+
+	if useAnalysisCache && depFile != "" {
+		// The cache only saves time on the next run, the ninja file is fine without it.
+		executable, err := os.Executable()
+		if err == nil {
+			err = writeAnalysisCache(cacheFile, depFile, os.Args, configuration.EnvDeps(), executable)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to write soong_build analysis cache %s: %s\n", cacheFile, err)
+		}
+	}
+}
+
+// ninjaFileFlags returns the paths of the ninja file and of its depfile, which are command line
+// arguments of the bootstrap package.
+func ninjaFileFlags() (outFile, depFile string) {
+	if f := flag.Lookup("o"); f != nil {
+		outFile = f.Value.String()
+	}
+	if f := flag.Lookup("d"); f != nil {
+		depFile = f.Value.String()
+	}
+	return outFile, depFile
 }
 
 // shouldPrepareBuildActions reads configuration and flags if build actions
@@ -170,3 +239,17 @@ func shouldPrepareBuildActions(configuration android.Config) bool {
 	// Generating a directory for converted Bazel BUILD files
 	return !bazelConversionRequested(configuration)
 }
+
+// addCcToolchainVars adds the cc toolchain configuration of Soong to the
+// //soong_injection/cc_toolchain Bazel package of the files of a workspace, for
+// Bazel's cc toolchain to load.
+func addCcToolchainVars(configuration android.Config, files outputTree) error {
+	vars, err := config.BazelCcToolchainVars(configuration)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join("soong_injection", "cc_toolchain")
+	files[filepath.Join(dir, "BUILD")] = ""
+	files[filepath.Join(dir, "constants.bzl")] = vars
+	return nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// outputTree is a tree of generated files, keyed by their path relative to the root of the tree.
+type outputTree map[string]string
+
+// writeIncrementally makes dir contain the files of the tree. Regenerating a large tree, like the
+// BUILD files of the whole source tree, is dominated by writing the files, so files whose contents
+// didn't change are left untouched, and only the files that changed are written. Files in dir that
+// are not in the tree anymore are removed. The existing files are compared in parallel, one
+// directory at a time.
+func (t outputTree) writeIncrementally(dir string) error {
+	var lock sync.Mutex
+	unchanged := map[string]bool{}
+	var firstErr error
+	setErr := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Limit the number of directories that are read at the same time, to avoid running out of
+	// file descriptors.
+	semaphore := make(chan bool, runtime.NumCPU()*4)
+	var wg sync.WaitGroup
+	var walk func(relDir string)
+	walk = func(relDir string) {
+		defer wg.Done()
+
+		semaphore <- true
+		entries, err := ioutil.ReadDir(filepath.Join(dir, relDir))
+		if err != nil {
+			<-semaphore
+			setErr(err)
+			return
+		}
+		var subdirs []string
+		for _, entry := range entries {
+			relPath := filepath.Join(relDir, entry.Name())
+			if entry.IsDir() {
+				subdirs = append(subdirs, relPath)
+				continue
+			}
+			absPath := filepath.Join(dir, relPath)
+			content, inTree := t[relPath]
+			if !inTree {
+				if err := os.Remove(absPath); err != nil {
+					setErr(err)
+				}
+			} else if existing, err := ioutil.ReadFile(absPath); err == nil && string(existing) == content {
+				lock.Lock()
+				unchanged[relPath] = true
+				lock.Unlock()
+			}
+		}
+		<-semaphore
+
+		for _, subdir := range subdirs {
+			wg.Add(1)
+			go walk(subdir)
+		}
+	}
+
+	if _, err := os.Stat(dir); err == nil {
+		wg.Add(1)
+		go walk("")
+		wg.Wait()
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	for relPath, content := range t {
+		if unchanged[relPath] {
+			continue
+		}
+		if err := writeOutputFile(dir, relPath, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write writes all the files of the tree into dir, leaving the other files in dir untouched.
+func (t outputTree) write(dir string) error {
+	for relPath, content := range t {
+		if err := writeOutputFile(dir, relPath, content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The output files should be read-only, sufficient for bazel query. The files are not intended to
+// be edited by end users.
+func writeOutputFile(dir string, relPath string, content string) error {
+	absPath := filepath.Join(dir, relPath)
+	if err := os.MkdirAll(filepath.Dir(absPath), os.ModePerm); err != nil {
+		return err
+	}
+	// The files are read-only, so they are replaced instead of overwritten.
+	if err := os.Remove(absPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// 0444 is read-only
+	return ioutil.WriteFile(absPath, []byte(content), 0444)
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"android/soong/android"
+)
+
+func TestOutputTreeWriteIncrementally(t *testing.T) {
+	dir := filepath.Join(buildDir, "output_tree")
+
+	err := outputTree{
+		"BUILD":               "",
+		"foo/BUILD.bazel":     "foo",
+		"bar/BUILD.bazel":     "bar",
+		"bar/baz/BUILD.bazel": "baz",
+	}.writeIncrementally(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Backdate the files so that rewritten files can be told apart from unchanged ones.
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, file := range []string{"BUILD", "foo/BUILD.bazel", "bar/BUILD.bazel"} {
+		if err := os.Chtimes(filepath.Join(dir, file), past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = outputTree{
+		"BUILD":           "",
+		"foo/BUILD.bazel": "foo",
+		"bar/BUILD.bazel": "bar changed",
+		"qux/BUILD.bazel": "qux",
+	}.writeIncrementally(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkFile := func(file string, expectedContent string, expectUnchanged bool) {
+		t.Helper()
+		path := filepath.Join(dir, file)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Errorf("expected %s to exist: %s", file, err)
+			return
+		}
+		if string(content) != expectedContent {
+			t.Errorf("expected %s to contain %q, got %q", file, expectedContent, string(content))
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unchanged := info.ModTime().Equal(past); unchanged != expectUnchanged {
+			t.Errorf("expected %s to be unchanged: %v, got %v", file, expectUnchanged, unchanged)
+		}
+	}
+
+	checkFile("BUILD", "", true)
+	checkFile("foo/BUILD.bazel", "foo", true)
+	checkFile("bar/BUILD.bazel", "bar changed", false)
+	checkFile("qux/BUILD.bazel", "qux", false)
+
+	if _, err := os.Stat(filepath.Join(dir, "bar/baz/BUILD.bazel")); !os.IsNotExist(err) {
+		t.Errorf("expected bar/baz/BUILD.bazel to be removed, got %v", err)
+	}
+}
+
+func TestOutputTreeWrite(t *testing.T) {
+	dir := filepath.Join(buildDir, "output_tree_write")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "other"), []byte("other"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	err := outputTree{
+		"soong_injection/cc_toolchain/BUILD":         "",
+		"soong_injection/cc_toolchain/constants.bzl": "constants",
+	}.write(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for file, expectedContent := range map[string]string{
+		"other":                              "other",
+		"soong_injection/cc_toolchain/BUILD": "",
+		"soong_injection/cc_toolchain/constants.bzl": "constants",
+	} {
+		content, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Errorf("expected %s to exist: %s", file, err)
+		} else if string(content) != expectedContent {
+			t.Errorf("expected %s to contain %q, got %q", file, expectedContent, string(content))
+		}
+	}
+}
+
+func TestCcToolchainVarsInOutputTree(t *testing.T) {
+	// The cc toolchain files are part of the bp2build output tree, so writing the tree
+	// incrementally keeps them.
+	files := outputTree{"BUILD": ""}
+	if err := addCcToolchainVars(android.TestConfig(buildDir, nil, "", nil), files); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{
+		"soong_injection/cc_toolchain/BUILD",
+		"soong_injection/cc_toolchain/constants.bzl",
+	} {
+		if _, ok := files[file]; !ok {
+			t.Errorf("expected the output tree to contain %s, got %v", file, android.SortedStringKeys(files))
+		}
+	}
+}
@@ -17,8 +17,6 @@ package main
 import (
 	"android/soong/android"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -332,54 +330,53 @@ func createRuleShims(packages []*bpdoc.Package) (map[string]RuleShim, error) {
 	return ruleShims, nil
 }
 
-func createBazelQueryView(ctx *android.Context, bazelQueryViewDir string) error {
+// bazelQueryViewFiles returns the files of the Bazel workspace that represents the Soong module
+// graph.
+func bazelQueryViewFiles(ctx *android.Context) (outputTree, error) {
 	blueprintCtx := ctx.Context
+	files := outputTree{}
+
+	buildFiles := map[string]*strings.Builder{}
 	blueprintCtx.VisitAllModules(func(module blueprint.Module) {
-		buildFile, err := buildFileForModule(blueprintCtx, module, bazelQueryViewDir)
-		if err != nil {
-			panic(err)
+		buildFilePath := filepath.Join(packagePath(blueprintCtx, module), "BUILD.bazel")
+		buildFile, exists := buildFiles[buildFilePath]
+		if !exists {
+			// Start the file with the load statement for the `soong_module` rule
+			buildFile = &strings.Builder{}
+			buildFile.WriteString(soongModuleLoad + "\n")
+			buildFiles[buildFilePath] = buildFile
 		}
-
-		buildFile.Write([]byte(generateSoongModuleTarget(blueprintCtx, module) + "\n\n"))
-		buildFile.Close()
+		buildFile.WriteString(generateSoongModuleTarget(blueprintCtx, module) + "\n\n")
 	})
-	var err error
-
-	// Write top level files: WORKSPACE and BUILD. These files are empty.
-	if err = writeReadOnlyFile(bazelQueryViewDir, "WORKSPACE", ""); err != nil {
-		return err
+	for buildFilePath, buildFile := range buildFiles {
+		files[buildFilePath] = buildFile.String()
 	}
 
+	// Top level files: WORKSPACE and BUILD. These files are empty.
+	files["WORKSPACE"] = ""
+
 	// Used to denote that the top level directory is a package.
-	if err = writeReadOnlyFile(bazelQueryViewDir, "BUILD", ""); err != nil {
-		return err
-	}
+	files["BUILD"] = ""
 
 	packages, err := getPackages(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	ruleShims, err := createRuleShims(packages)
 	if err != nil {
-		return err
-	}
-
-	// Write .bzl Starlark files into the bazel_rules top level directory (provider and rule definitions)
-	bazelRulesDir := bazelQueryViewDir + "/build/bazel/queryview_rules"
-	if err = writeReadOnlyFile(bazelRulesDir, "BUILD", ""); err != nil {
-		return err
-	}
-	if err = writeReadOnlyFile(bazelRulesDir, "providers.bzl", providersBzl); err != nil {
-		return err
+		return nil, err
 	}
 
+	// .bzl Starlark files in the bazel_rules top level directory (provider and rule definitions)
+	bazelRulesDir := "build/bazel/queryview_rules"
+	files[filepath.Join(bazelRulesDir, "BUILD")] = ""
+	files[filepath.Join(bazelRulesDir, "providers.bzl")] = providersBzl
 	for bzlFileName, ruleShim := range ruleShims {
-		if err = writeReadOnlyFile(bazelRulesDir, bzlFileName+".bzl", ruleShim.content); err != nil {
-			return err
-		}
+		files[filepath.Join(bazelRulesDir, bzlFileName+".bzl")] = ruleShim.content
 	}
+	files[filepath.Join(bazelRulesDir, "soong_module.bzl")] = generateSoongModuleBzl(ruleShims)
 
-	return writeReadOnlyFile(bazelRulesDir, "soong_module.bzl", generateSoongModuleBzl(ruleShims))
+	return files, nil
 }
 
 // Generate the content of soong_module.bzl with the rule shim load statements
@@ -454,47 +451,6 @@ func generateSoongModuleTarget(
 		attributes)
 }
 
-func buildFileForModule(
-	ctx *blueprint.Context, module blueprint.Module, bazelQueryViewDir string) (*os.File, error) {
-	// Create nested directories for the BUILD file
-	dirPath := filepath.Join(bazelQueryViewDir, packagePath(ctx, module))
-	createDirectoryIfNonexistent(dirPath)
-	// Open the file for appending, and create it if it doesn't exist
-	f, err := os.OpenFile(
-		filepath.Join(dirPath, "BUILD.bazel"),
-		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
-		0644)
-	if err != nil {
-		return nil, err
-	}
-
-	// If the file is empty, add the load statement for the `soong_module` rule
-	fi, err := f.Stat()
-	if err != nil {
-		return nil, err
-	}
-	if fi.Size() == 0 {
-		f.Write([]byte(soongModuleLoad + "\n"))
-	}
-
-	return f, nil
-}
-
-func createDirectoryIfNonexistent(dir string) {
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		os.MkdirAll(dir, os.ModePerm)
-	}
-}
-
-// The QueryView directory should be read-only, sufficient for bazel query. The files
-// are not intended to be edited by end users.
-func writeReadOnlyFile(dir string, baseName string, content string) error {
-	createDirectoryIfNonexistent(dir)
-	pathToFile := filepath.Join(dir, baseName)
-	// 0444 is read-only
-	return ioutil.WriteFile(pathToFile, []byte(content), 0444)
-}
-
 func isZero(value reflect.Value) bool {
 	switch value.Kind() {
 	case reflect.Func, reflect.Map, reflect.Slice:
@@ -81,7 +81,7 @@ type GlobalConfig struct {
 
 	// Only used for boot image
 	DirtyImageObjects android.OptionalPath // path to a dirty-image-objects file
-	BootImageProfiles android.Paths        // path to a boot-image-profile.txt file
+	BootImageProfiles android.Paths        // paths to boot-image-profile.txt files, if the product doesn't set BootImageProfiles
 	BootFlags         string               // extra flags to pass to dex2oat for the boot image
 	Dex2oatImageXmx   string               // max heap size for dex2oat for the boot image
 	Dex2oatImageXms   string               // initial heap size for dex2oat for the boot image
@@ -110,8 +110,9 @@ func dexpreoptDisabled(ctx android.PathContext, global *GlobalConfig, module *Mo
 		return true
 	}
 
-	// Don't preopt system server jars that are updatable.
-	if global.UpdatableSystemServerJars.ContainsJar(module.Name) {
+	// Don't preopt system server jars that are updatable, unless they are preopted from the variant
+	// in their APEX, see UpdatableSystemServerJarOdexPath.
+	if global.UpdatableSystemServerJars.ContainsJar(module.Name) && !strings.HasPrefix(module.DexLocation, "/apex/") {
 		return true
 	}
 
@@ -222,7 +223,9 @@ func dexpreoptCommand(ctx android.PathContext, globalSoong *GlobalSoongConfig, g
 
 	odexPath := module.BuildPath.InSameDir(ctx, "oat", arch.String(), pathtools.ReplaceExtension(base, "odex"))
 	odexInstallPath := toOdexPath(module.DexLocation)
-	if odexOnSystemOther(module, global) {
+	if global.UpdatableSystemServerJars.ContainsJar(module.Name) && strings.HasPrefix(module.DexLocation, "/apex/") {
+		odexInstallPath = UpdatableSystemServerJarOdexPath(module.DexLocation, arch)
+	} else if odexOnSystemOther(module, global) {
 		odexInstallPath = filepath.Join(SystemOtherPartition, odexInstallPath)
 	}
 
@@ -236,23 +239,41 @@ func dexpreoptCommand(ctx android.PathContext, globalSoong *GlobalSoongConfig, g
 	rule.Command().FlagWithArg("mkdir -p ", filepath.Dir(odexPath.String()))
 	rule.Command().FlagWithOutput("rm -f ", odexPath)
 
-	if jarIndex := android.IndexList(module.Name, systemServerJars); jarIndex >= 0 {
+	jarIndex := android.IndexList(module.Name, systemServerJars)
+	updatableJarIndex := global.UpdatableSystemServerJars.IndexOfJar(module.Name)
+	if jarIndex >= 0 || updatableJarIndex >= 0 {
 		// System server jars should be dexpreopted together: class loader context of each jar
-		// should include all preceding jars on the system server classpath.
+		// should include all preceding jars on the system server classpath. The updatable jars
+		// come after all the non-updatable jars.
+
+		precedingJars := systemServerJars
+		if jarIndex >= 0 {
+			precedingJars = systemServerJars[:jarIndex]
+		}
 
 		var clcHost android.Paths
 		var clcTarget []string
-		for _, lib := range systemServerJars[:jarIndex] {
+		for _, lib := range precedingJars {
 			clcHost = append(clcHost, SystemServerDexJarHostPath(ctx, lib))
 			clcTarget = append(clcTarget, filepath.Join("/system/framework", lib+".jar"))
 		}
+		if updatableJarIndex >= 0 {
+			updatableLocations := global.UpdatableSystemServerJars.DevicePaths(ctx.Config(), android.Android)
+			for i := 0; i < updatableJarIndex; i++ {
+				lib := global.UpdatableSystemServerJars.Jar(i)
+				clcHost = append(clcHost, SystemServerDexJarHostPath(ctx, lib))
+				clcTarget = append(clcTarget, updatableLocations[i])
+			}
+		}
 
 		// Copy the system server jar to a predefined location where dex2oat will find it.
 		dexPathHost := SystemServerDexJarHostPath(ctx, module.Name)
 		rule.Command().Text("mkdir -p").Flag(filepath.Dir(dexPathHost.String()))
 		rule.Command().Text("cp -f").Input(module.DexPath).Output(dexPathHost)
 
-		checkSystemServerOrder(ctx, jarIndex)
+		if jarIndex >= 0 {
+			checkSystemServerOrder(ctx, jarIndex)
+		}
 
 		rule.Command().
 			Text("class_loader_context_arg=--class-loader-context=PCL[" + strings.Join(clcHost.Strings(), ":") + "]").
@@ -347,7 +368,7 @@ func dexpreoptCommand(ctx android.PathContext, globalSoong *GlobalSoongConfig, g
 
 	if !android.PrefixInList(preoptFlags, "--compiler-filter=") {
 		var compilerFilter string
-		if contains(global.SystemServerJars, module.Name) {
+		if isSystemServerJar(global, module.Name) {
 			// Jars of system server, use the product option if it is set, speed otherwise.
 			if global.SystemServerCompilerFilter != "" {
 				compilerFilter = global.SystemServerCompilerFilter
@@ -392,7 +413,7 @@ func dexpreoptCommand(ctx android.PathContext, globalSoong *GlobalSoongConfig, g
 
 	// PRODUCT_SYSTEM_SERVER_DEBUG_INFO overrides WITH_DEXPREOPT_DEBUG_INFO.
 	// PRODUCT_OTHER_JAVA_DEBUG_INFO overrides WITH_DEXPREOPT_DEBUG_INFO.
-	if contains(global.SystemServerJars, module.Name) {
+	if isSystemServerJar(global, module.Name) {
 		if global.AlwaysSystemServerDebugInfo {
 			debugInfo = true
 		} else if global.NeverSystemServerDebugInfo {
@@ -504,6 +525,20 @@ func NonUpdatableSystemServerJars(ctx android.PathContext, global *GlobalConfig)
 	}).([]string)
 }
 
+// isSystemServerJar returns true if the jar is on the system server classpath, either as a
+// non-updatable jar or as an updatable jar from an APEX.
+func isSystemServerJar(global *GlobalConfig, jar string) bool {
+	return contains(global.SystemServerJars, jar) || global.UpdatableSystemServerJars.ContainsJar(jar)
+}
+
+// UpdatableSystemServerJarOdexPath returns the install path of the odex file of the updatable system
+// server jar at the given location in an APEX. The odex file is installed in the system partition,
+// where ART looks for the preopted code of jars in APEXes, and ignores it once the APEX is updated.
+func UpdatableSystemServerJarOdexPath(dexLocation string, arch android.ArchType) string {
+	return filepath.Join("/system/framework/oat", arch.String(),
+		strings.ReplaceAll(strings.TrimPrefix(dexLocation, "/"), "/", "@")+"@classes.odex")
+}
+
 // A predefined location for the system server dex jars. This is needed in order to generate
 // class loader context for dex2oat, as the path to the jar in the Soong module may be unknown
 // at that time (Soong processes the jars in dependency order, which may be different from the
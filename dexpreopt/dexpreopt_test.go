@@ -17,6 +17,7 @@ package dexpreopt
 import (
 	"android/soong/android"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -165,3 +166,65 @@ func TestDexPreoptProfile(t *testing.T) {
 		t.Errorf("\nwant installs:\n   %v\ngot:\n   %v", wantInstalls, rule.Installs())
 	}
 }
+
+func TestDexPreoptUpdatableSystemServerJar(t *testing.T) {
+	config := android.TestConfig("out", nil, "", nil)
+	ctx := android.BuilderContextForTesting(config)
+	globalSoong := GlobalSoongConfigForTests(config)
+	global := GlobalConfigForTests(ctx)
+	global.SystemServerJars = []string{"foo"}
+	global.UpdatableSystemServerJars = android.CreateTestConfiguredJarList(
+		[]string{"com.android.bar:service-bar", "com.android.baz:service-baz"})
+
+	module := testSystemModuleConfig(ctx, "service-baz")
+	module.DexLocation = "/apex/com.android.baz/javalib/service-baz.jar"
+	module.BuildPath = android.PathForOutput(ctx, "service-baz/service-baz.jar")
+
+	rule, err := GenerateDexpreoptRule(ctx, globalSoong, global, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantInstalls := android.RuleBuilderInstalls{
+		{android.PathForOutput(ctx, "service-baz/oat/arm/javalib.odex"),
+			"/system/framework/oat/arm/apex@com.android.baz@javalib@service-baz.jar@classes.odex"},
+		{android.PathForOutput(ctx, "service-baz/oat/arm/javalib.vdex"),
+			"/system/framework/oat/arm/apex@com.android.baz@javalib@service-baz.jar@classes.vdex"},
+	}
+
+	if rule.Installs().String() != wantInstalls.String() {
+		t.Errorf("\nwant installs:\n   %v\ngot:\n   %v", wantInstalls, rule.Installs())
+	}
+
+	// The class loader context has all the non-updatable jars, followed by the preceding updatable
+	// jars.
+	wantContext := "--stored-class-loader-context=PCL[/system/framework/foo.jar:/apex/com.android.bar/javalib/service-bar.jar]"
+	if commands := strings.Join(rule.Commands(), "\n"); !strings.Contains(commands, wantContext) {
+		t.Errorf("expected commands to contain %q, got:\n%s", wantContext, commands)
+	}
+
+	// Other modules in APEXes keep their artifacts next to the dex file in the APEX.
+	app := testSystemModuleConfig(ctx, "app")
+	app.DexLocation = "/apex/com.android.baz/app/app/app.apk"
+	rule, err = GenerateDexpreoptRule(ctx, globalSoong, global, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInstalls = android.RuleBuilderInstalls{
+		{android.PathForOutput(ctx, "app/oat/arm/package.odex"), "/apex/com.android.baz/app/app/oat/arm/app.odex"},
+		{android.PathForOutput(ctx, "app/oat/arm/package.vdex"), "/apex/com.android.baz/app/app/oat/arm/app.vdex"},
+	}
+	if rule.Installs().String() != wantInstalls.String() {
+		t.Errorf("\nwant installs:\n   %v\ngot:\n   %v", wantInstalls, rule.Installs())
+	}
+
+	// The jar isn't preopted from a location outside its APEX.
+	module.DexLocation = "/system/framework/service-baz.jar"
+	rule, err = GenerateDexpreoptRule(ctx, globalSoong, global, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rule.Installs()) != 0 {
+		t.Errorf("expected no installs, got %v", rule.Installs())
+	}
+}
@@ -119,8 +119,8 @@ type PrebuiltEtc struct {
 	outputFilePath android.OutputPath
 	// The base install location, e.g. "etc" for prebuilt_etc, "usr/share" for prebuilt_usr_share.
 	installDirBase string
-	// The base install location when soc_specific property is set to true, e.g. "firmware" for
-	// prebuilt_firmware.
+	// The base install location when the module is installed in the vendor or odm partition, e.g.
+	// "firmware" for prebuilt_firmware.
 	socInstallDirBase      string
 	installDirPath         android.InstallPath
 	additionalDependencies *android.Paths
@@ -253,10 +253,12 @@ func (p *PrebuiltEtc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		ctx.PropertyErrorf("sub_dir", "relative_install_path is set. Cannot set sub_dir")
 	}
 
-	// If soc install dir was specified and SOC specific is set, set the installDirPath to the
-	// specified socInstallDirBase.
+	// If soc install dir was specified and the module is installed in the vendor or odm
+	// partition, set the installDirPath to the specified socInstallDirBase. The recovery and
+	// ramdisk images are laid out like the system partition, so they use installDirBase.
 	installBaseDir := p.installDirBase
-	if p.SocSpecific() && p.socInstallDirBase != "" {
+	if p.socInstallDirBase != "" && (p.SocSpecific() || p.DeviceSpecific()) &&
+		!p.inRecovery() && !p.inRamdisk() && !p.inVendorRamdisk() {
 		installBaseDir = p.socInstallDirBase
 	}
 	p.installDirPath = android.PathForModuleInstall(ctx, installBaseDir, p.SubDir())
@@ -365,8 +367,8 @@ func PrebuiltFontFactory() android.Module {
 
 // prebuilt_firmware installs a firmware file to <partition>/etc/firmware directory for system
 // image.
-// If soc_specific property is set to true, the firmware file is installed to the
-// vendor <partition>/firmware directory for vendor image.
+// If soc_specific or device_specific property is set to true, the firmware file is installed to
+// the vendor or odm <partition>/firmware directory.
 func PrebuiltFirmwareFactory() android.Module {
 	module := &PrebuiltEtc{}
 	module.socInstallDirBase = "firmware"
@@ -377,8 +379,8 @@ func PrebuiltFirmwareFactory() android.Module {
 }
 
 // prebuilt_dsp installs a DSP related file to <partition>/etc/dsp directory for system image.
-// If soc_specific property is set to true, the DSP related file is installed to the
-// vendor <partition>/dsp directory for vendor image.
+// If soc_specific or device_specific property is set to true, the DSP related file is installed
+// to the vendor or odm <partition>/dsp directory.
 func PrebuiltDSPFactory() android.Module {
 	module := &PrebuiltEtc{}
 	module.socInstallDirBase = "dsp"
@@ -324,6 +324,15 @@ func TestPrebuiltFirmwareDirPath(t *testing.T) {
 				sub_dir: "sub_dir",
 			}`,
 		expectedPath: filepath.Join(targetPath, "vendor/firmware/sub_dir"),
+	}, {
+		description: "prebuilt: odm firmware",
+		config: `
+			prebuilt_firmware {
+				name: "foo.conf",
+				src: "foo.conf",
+				device_specific: true,
+			}`,
+		expectedPath: filepath.Join(targetPath, "odm/firmware"),
 	}}
 	for _, tt := range tests {
 		t.Run(tt.description, func(t *testing.T) {
@@ -336,6 +345,28 @@ func TestPrebuiltFirmwareDirPath(t *testing.T) {
 	}
 }
 
+func TestPrebuiltFirmwareRecoveryDirPath(t *testing.T) {
+	ctx, _ := testPrebuiltEtc(t, `
+		prebuilt_firmware {
+			name: "foo.conf",
+			src: "foo.conf",
+			soc_specific: true,
+			recovery_available: true,
+		}
+	`)
+
+	targetPath := filepath.Join(buildDir, "target/product/test_device")
+	for variant, expectedPath := range map[string]string{
+		"android_arm64_armv8-a":          filepath.Join(targetPath, "vendor/firmware"),
+		"android_recovery_arm64_armv8-a": filepath.Join(targetPath, "recovery/root/system/etc/firmware"),
+	} {
+		p := ctx.ModuleForTests("foo.conf", variant).Module().(*PrebuiltEtc)
+		if p.installDirPath.String() != expectedPath {
+			t.Errorf("%s: expected %q, got %q", variant, expectedPath, p.installDirPath)
+		}
+	}
+}
+
 func TestPrebuiltDSPDirPath(t *testing.T) {
 	targetPath := filepath.Join(buildDir, "/target/product/test_device")
 	tests := []struct {
@@ -0,0 +1,443 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featureflags
+
+// This package implements build time feature flags.  A feature_flag_declarations module declares
+// typed flags and generates a Java class and a C++ header with a constant for each of them, which
+// java modules use with srcs: [":<name>{.java}"] and cc modules with
+// generated_headers: ["<name>"].  The Java class and the innermost C++ namespace are named after
+// the module, e.g. com.example.ExampleFlags and com::example::example_flags for the module
+// example_flags in the package com.example, so that several modules can declare flags in the same
+// package.  Flags default to false, 0 or "", and are set by the feature_flag_values modules listed
+// in the Release_feature_flag_values product variable, so that each release configuration can
+// choose its own values.  Code that references a flag that isn't declared fails to compile, and
+// setting a value for a flag that isn't declared is an error.
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/genrule"
+)
+
+func init() {
+	RegisterFeatureFlagsBuildComponents(android.InitRegistrationContext)
+}
+
+func RegisterFeatureFlagsBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("feature_flag_declarations", DeclarationsFactory)
+	ctx.RegisterModuleType("feature_flag_values", ValuesFactory)
+}
+
+// FlagType is the type of a feature flag.
+type FlagType string
+
+const (
+	BoolFlag   FlagType = "bool"
+	IntFlag    FlagType = "int"
+	StringFlag FlagType = "string"
+)
+
+// FeatureFlag is a declared feature flag with its value for the current release configuration.
+type FeatureFlag struct {
+	Name  string
+	Type  FlagType
+	Value string
+}
+
+// DeclarationsInfo is provided by feature_flag_declarations modules.
+type DeclarationsInfo struct {
+	// Java package of the generated class, also used as the outer C++ namespaces.
+	Package string
+
+	// Name of the generated Java class, and of the C++ namespace in the package namespace.
+	JavaClass   string
+	CcNamespace string
+
+	Flags []FeatureFlag
+
+	// The generated Java source file.
+	JavaSrc android.Path
+
+	// The generated C++ header and the directory to add to the include path to use it.
+	CcHeader     android.Path
+	CcIncludeDir android.Path
+}
+
+var DeclarationsProvider = blueprint.NewProvider(DeclarationsInfo{})
+
+// valuesInfo is provided by feature_flag_values modules.
+type valuesInfo struct {
+	Declarations string
+	Values       map[string]string
+}
+
+var valuesProvider = blueprint.NewProvider(valuesInfo{})
+
+type dependencyTag struct {
+	blueprint.BaseDependencyTag
+	name string
+}
+
+var valuesTag = dependencyTag{name: "values"}
+
+var flagNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+var packageRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// The flag names are used as C++ identifiers as they are, and the package names as Java package
+// and C++ namespace names, so neither can be a keyword of either language.  The Java class uses
+// the flag names in upper case, which can't be Java keywords.
+var reservedWords = []string{
+	// C++
+	"alignas", "alignof", "and", "and_eq", "asm", "auto", "bitand", "bitor", "bool", "break",
+	"case", "catch", "char", "char8_t", "char16_t", "char32_t", "class", "compl", "concept",
+	"const", "consteval", "constexpr", "constinit", "const_cast", "continue", "co_await",
+	"co_return", "co_yield", "decltype", "default", "delete", "do", "double", "dynamic_cast",
+	"else", "enum", "explicit", "export", "extern", "false", "float", "for", "friend", "goto", "if",
+	"inline", "int", "long", "mutable", "namespace", "new", "noexcept", "not", "not_eq", "nullptr",
+	"operator", "or", "or_eq", "private", "protected", "public", "register", "reinterpret_cast",
+	"requires", "return", "short", "signed", "sizeof", "static", "static_assert", "static_cast",
+	"struct", "switch", "template", "this", "thread_local", "throw", "true", "try", "typedef",
+	"typeid", "typename", "union", "unsigned", "using", "virtual", "void", "volatile", "wchar_t",
+	"while", "xor", "xor_eq",
+	// Java
+	"abstract", "assert", "boolean", "byte", "extends", "final", "finally", "implements", "import",
+	"instanceof", "interface", "native", "null", "package", "strictfp", "super", "synchronized",
+	"throws", "transient", "var",
+}
+
+var moduleNameWordRegexp = regexp.MustCompile(`[A-Za-z0-9]+`)
+
+// generatedNames returns the name of the Java class and of the C++ namespace generated for a
+// feature_flag_declarations module, e.g. ExampleFlags and example_flags for example_flags, or
+// false if they aren't valid identifiers.
+func generatedNames(moduleName string) (javaClass, ccNamespace string, ok bool) {
+	words := moduleNameWordRegexp.FindAllString(moduleName, -1)
+	if len(words) == 0 || (words[0][0] >= '0' && words[0][0] <= '9') {
+		return "", "", false
+	}
+	for i, word := range words {
+		javaClass += strings.ToUpper(word[:1]) + word[1:]
+		words[i] = strings.ToLower(word)
+	}
+	ccNamespace = strings.Join(words, "_")
+	return javaClass, ccNamespace, !android.InList(ccNamespace, reservedWords)
+}
+
+type declarationsProperties struct {
+	// Java package of the generated class.  The generated C++ header declares the flags in the
+	// namespace with the same name, with "." replaced by "::".  No part of the package may be a
+	// Java or C++ keyword.
+	Package *string
+
+	// Names of the boolean flags, which default to false.
+	Bool_flags []string
+
+	// Names of the integer flags, which default to 0.
+	Int_flags []string
+
+	// Names of the string flags, which default to "".
+	String_flags []string
+}
+
+type declarationsModule struct {
+	android.ModuleBase
+
+	properties declarationsProperties
+
+	javaSrc      android.Path
+	ccHeader     android.Path
+	ccIncludeDir android.Path
+}
+
+var _ genrule.SourceFileGenerator = (*declarationsModule)(nil)
+var _ android.OutputFileProducer = (*declarationsModule)(nil)
+
+// feature_flag_declarations declares build time feature flags, and generates a Java class and a
+// C++ header with their values for the current release configuration.
+func DeclarationsFactory() android.Module {
+	module := &declarationsModule{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidModule(module)
+	return module
+}
+
+func (m *declarationsModule) DepsMutator(ctx android.BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), valuesTag, ctx.Config().ReleaseFeatureFlagValues()...)
+}
+
+// declaredFlags returns the flags declared by the module with their default values.
+func (m *declarationsModule) declaredFlags(ctx android.ModuleContext) []FeatureFlag {
+	var flags []FeatureFlag
+	seen := make(map[string]bool)
+	add := func(property string, names []string, typ FlagType, defaultValue string) {
+		for _, name := range names {
+			if !flagNameRegexp.MatchString(name) {
+				ctx.PropertyErrorf(property, "invalid flag name %q, must match %s", name, flagNameRegexp)
+				continue
+			}
+			if android.InList(name, reservedWords) {
+				ctx.PropertyErrorf(property, "invalid flag name %q, must not be a Java or C++ keyword", name)
+				continue
+			}
+			if seen[name] {
+				ctx.PropertyErrorf(property, "flag %q is declared more than once", name)
+				continue
+			}
+			seen[name] = true
+			flags = append(flags, FeatureFlag{Name: name, Type: typ, Value: defaultValue})
+		}
+	}
+	add("bool_flags", m.properties.Bool_flags, BoolFlag, "false")
+	add("int_flags", m.properties.Int_flags, IntFlag, "0")
+	add("string_flags", m.properties.String_flags, StringFlag, "")
+	return flags
+}
+
+// checkFlagValue returns an error if value is not a valid value for a flag of the given type.
+func checkFlagValue(typ FlagType, value string) error {
+	switch typ {
+	case BoolFlag:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("must be true or false")
+		}
+	case IntFlag:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("must be a 64-bit integer")
+		}
+	case StringFlag:
+		for _, r := range value {
+			if r < 0x20 || r > 0x7e {
+				return fmt.Errorf("must only contain printable ASCII characters")
+			}
+		}
+	}
+	return nil
+}
+
+func (m *declarationsModule) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	pkg := proptools.String(m.properties.Package)
+	if pkg == "" {
+		ctx.PropertyErrorf("package", "missing package")
+		return
+	}
+	if !packageRegexp.MatchString(pkg) {
+		ctx.PropertyErrorf("package", "invalid package %q, must match %s", pkg, packageRegexp)
+		return
+	}
+	for _, part := range strings.Split(pkg, ".") {
+		if android.InList(part, reservedWords) {
+			ctx.PropertyErrorf("package", "invalid package %q, %q is a Java or C++ keyword", pkg, part)
+			return
+		}
+	}
+	javaClass, ccNamespace, ok := generatedNames(ctx.ModuleName())
+	if !ok {
+		ctx.ModuleErrorf("name must start with a letter and must not be a Java or C++ keyword, it is " +
+			"used to name the generated Java class and C++ namespace")
+		return
+	}
+
+	flags := m.declaredFlags(ctx)
+	flagIndex := make(map[string]int)
+	for i, flag := range flags {
+		flagIndex[flag.Name] = i
+	}
+
+	// Apply the values of the release configuration.
+	setBy := make(map[string]string)
+	ctx.VisitDirectDepsWithTag(valuesTag, func(dep android.Module) {
+		if !ctx.OtherModuleHasProvider(dep, valuesProvider) {
+			ctx.ModuleErrorf("%q listed in Release_feature_flag_values is not a feature_flag_values module",
+				ctx.OtherModuleName(dep))
+			return
+		}
+		info := ctx.OtherModuleProvider(dep, valuesProvider).(valuesInfo)
+		if info.Declarations != ctx.ModuleName() {
+			return
+		}
+		for _, name := range android.SortedStringKeys(info.Values) {
+			value := info.Values[name]
+			i, declared := flagIndex[name]
+			if !declared {
+				ctx.OtherModuleErrorf(dep, "flag %q is not declared in %q", name, ctx.ModuleName())
+				continue
+			}
+			if err := checkFlagValue(flags[i].Type, value); err != nil {
+				ctx.OtherModuleErrorf(dep, "invalid value %q for %s flag %q: %s", value, flags[i].Type, name, err)
+				continue
+			}
+			if other, set := setBy[name]; set {
+				ctx.ModuleErrorf("flag %q is set by both %q and %q", name, other, ctx.OtherModuleName(dep))
+				continue
+			}
+			setBy[name] = ctx.OtherModuleName(dep)
+			flags[i].Value = value
+		}
+	})
+
+	if ctx.Failed() {
+		return
+	}
+
+	javaSrc := android.PathForModuleGen(ctx, "java", strings.ReplaceAll(pkg, ".", "/"), javaClass+".java")
+	android.WriteFileRule(ctx, javaSrc, generateJava(ctx.ModuleName(), pkg, javaClass, flags))
+	m.javaSrc = javaSrc
+
+	ccIncludeDir := android.PathForModuleGen(ctx, "include")
+	ccHeader := ccIncludeDir.Join(ctx, ctx.ModuleName()+".h")
+	android.WriteFileRule(ctx, ccHeader, generateCcHeader(ctx.ModuleName(), pkg, ccNamespace, flags))
+	m.ccIncludeDir = ccIncludeDir
+	m.ccHeader = ccHeader
+
+	ctx.SetProvider(DeclarationsProvider, DeclarationsInfo{
+		Package:      pkg,
+		JavaClass:    javaClass,
+		CcNamespace:  ccNamespace,
+		Flags:        flags,
+		JavaSrc:      javaSrc,
+		CcHeader:     ccHeader,
+		CcIncludeDir: ccIncludeDir,
+	})
+}
+
+func generateJava(moduleName, pkg, class string, flags []FeatureFlag) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by feature_flag_declarations module %q.  Do not edit.\n", moduleName)
+	fmt.Fprintf(&b, "package %s;\n\n", pkg)
+	fmt.Fprintf(&b, "/** Build time feature flags. */\n")
+	fmt.Fprintf(&b, "public final class %s {\n", class)
+	fmt.Fprintf(&b, "    private %s() {}\n", class)
+	for _, flag := range flags {
+		name := strings.ToUpper(flag.Name)
+		switch flag.Type {
+		case BoolFlag:
+			fmt.Fprintf(&b, "    public static final boolean %s = %s;\n", name, flag.Value)
+		case IntFlag:
+			fmt.Fprintf(&b, "    public static final long %s = %sL;\n", name, flag.Value)
+		case StringFlag:
+			fmt.Fprintf(&b, "    public static final String %s = %s;\n", name, strconv.Quote(flag.Value))
+		}
+	}
+	fmt.Fprintf(&b, "}\n")
+	return b.String()
+}
+
+func generateCcHeader(moduleName, pkg, ccNamespace string, flags []FeatureFlag) string {
+	namespace := strings.ReplaceAll(pkg, ".", "::") + "::" + ccNamespace
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by feature_flag_declarations module %q.  Do not edit.\n", moduleName)
+	fmt.Fprintf(&b, "#pragma once\n\n")
+	fmt.Fprintf(&b, "#include <cstdint>\n\n")
+	fmt.Fprintf(&b, "namespace %s {\n\n", namespace)
+	for _, flag := range flags {
+		switch flag.Type {
+		case BoolFlag:
+			fmt.Fprintf(&b, "constexpr bool %s = %s;\n", flag.Name, flag.Value)
+		case IntFlag:
+			// Fully qualified, a flag or a package may be named int64_t or std.
+			fmt.Fprintf(&b, "constexpr ::std::int64_t %s = INT64_C(%s);\n", flag.Name, flag.Value)
+		case StringFlag:
+			fmt.Fprintf(&b, "constexpr const char* %s = %s;\n", flag.Name, strconv.Quote(flag.Value))
+		}
+	}
+	fmt.Fprintf(&b, "\n}  // namespace %s\n", namespace)
+	return b.String()
+}
+
+func (m *declarationsModule) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case "", ".java":
+		return android.Paths{m.javaSrc}, nil
+	case ".h":
+		return android.Paths{m.ccHeader}, nil
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
+func (m *declarationsModule) GeneratedSourceFiles() android.Paths {
+	return android.Paths{m.ccHeader}
+}
+
+func (m *declarationsModule) GeneratedHeaderDirs() android.Paths {
+	return android.Paths{m.ccIncludeDir}
+}
+
+func (m *declarationsModule) GeneratedDeps() android.Paths {
+	return android.Paths{m.ccHeader}
+}
+
+type valuesProperties struct {
+	// Name of the feature_flag_declarations module that declares the flags.
+	Declarations *string
+
+	// Values of the flags, in the form "<flag>=<value>".
+	Values []string
+}
+
+type valuesModule struct {
+	android.ModuleBase
+
+	properties valuesProperties
+}
+
+// feature_flag_values sets the values of feature flags declared by a feature_flag_declarations
+// module.  It only takes effect when it is listed in the Release_feature_flag_values product
+// variable.
+func ValuesFactory() android.Module {
+	module := &valuesModule{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidModule(module)
+	return module
+}
+
+func (m *valuesModule) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	declarations := proptools.String(m.properties.Declarations)
+	if declarations == "" {
+		ctx.PropertyErrorf("declarations", "missing declarations")
+		return
+	}
+	if !ctx.OtherModuleExists(declarations) && !ctx.Config().AllowMissingDependencies() {
+		ctx.PropertyErrorf("declarations", "unknown feature_flag_declarations module %q", declarations)
+		return
+	}
+
+	values := make(map[string]string)
+	for _, v := range m.properties.Values {
+		i := strings.IndexByte(v, '=')
+		if i < 0 {
+			ctx.PropertyErrorf("values", "%q is not in the form <flag>=<value>", v)
+			continue
+		}
+		name, value := v[:i], v[i+1:]
+		if _, exists := values[name]; exists {
+			ctx.PropertyErrorf("values", "flag %q is set more than once", name)
+			continue
+		}
+		values[name] = value
+	}
+
+	ctx.SetProvider(valuesProvider, valuesInfo{
+		Declarations: declarations,
+		Values:       values,
+	})
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package featureflags
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+var buildDir string
+
+func setUp() {
+	var err error
+	buildDir, err = ioutil.TempDir("", "soong_featureflags_test")
+	if err != nil {
+		panic(err)
+	}
+}
+
+func tearDown() {
+	os.RemoveAll(buildDir)
+}
+
+func TestMain(m *testing.M) {
+	run := func() int {
+		setUp()
+		defer tearDown()
+
+		return m.Run()
+	}
+
+	os.Exit(run())
+}
+
+func testPrepare(t *testing.T, bp string, values []string) (*android.TestContext, []error) {
+	t.Helper()
+
+	config := android.TestArchConfig(buildDir, nil, bp, nil)
+	config.TestProductVariables.Release_feature_flag_values = values
+
+	ctx := android.NewTestArchContext(config)
+	RegisterFeatureFlagsBuildComponents(ctx)
+	ctx.Register()
+
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+
+	return ctx, errs
+}
+
+const declarationsBp = `
+	feature_flag_declarations {
+		name: "example_flags",
+		package: "com.example.flags",
+		bool_flags: ["enable_foo"],
+		int_flags: ["max_bar"],
+		string_flags: ["baz_name"],
+	}
+`
+
+func TestFeatureFlagsCodegen(t *testing.T) {
+	ctx, errs := testPrepare(t, declarationsBp+`
+		feature_flag_values {
+			name: "example_flags_release",
+			declarations: "example_flags",
+			values: [
+				"enable_foo=true",
+				"max_bar=42",
+			],
+		}
+	`, []string{"example_flags_release"})
+	android.FailIfErrored(t, errs)
+
+	module := ctx.ModuleForTests("example_flags", "")
+
+	java := android.ContentFromFileRuleForTests(t, module.Output("java/com/example/flags/ExampleFlags.java"))
+	for _, expected := range []string{
+		"package com.example.flags;",
+		"public final class ExampleFlags {",
+		"public static final boolean ENABLE_FOO = true;",
+		"public static final long MAX_BAR = 42L;",
+		`public static final String BAZ_NAME = "";`,
+	} {
+		if !strings.Contains(java, expected) {
+			t.Errorf("expected %q in generated Java source, got:\n%s", expected, java)
+		}
+	}
+
+	header := android.ContentFromFileRuleForTests(t, module.Output("include/example_flags.h"))
+	for _, expected := range []string{
+		"namespace com::example::flags::example_flags {",
+		"constexpr bool enable_foo = true;",
+		"constexpr ::std::int64_t max_bar = INT64_C(42);",
+		`constexpr const char* baz_name = "";`,
+	} {
+		if !strings.Contains(header, expected) {
+			t.Errorf("expected %q in generated header, got:\n%s", expected, header)
+		}
+	}
+}
+
+func TestFeatureFlagsDefaults(t *testing.T) {
+	ctx, errs := testPrepare(t, declarationsBp+`
+		feature_flag_values {
+			name: "example_flags_release",
+			declarations: "example_flags",
+			values: ["enable_foo=true"],
+		}
+	`, nil)
+	android.FailIfErrored(t, errs)
+
+	java := android.ContentFromFileRuleForTests(t,
+		ctx.ModuleForTests("example_flags", "").Output("java/com/example/flags/ExampleFlags.java"))
+	if expected := "public static final boolean ENABLE_FOO = false;"; !strings.Contains(java, expected) {
+		t.Errorf("expected values that are not in the release configuration to be ignored, got:\n%s", java)
+	}
+}
+
+func TestFeatureFlagsUndeclared(t *testing.T) {
+	_, errs := testPrepare(t, declarationsBp+`
+		feature_flag_values {
+			name: "example_flags_release",
+			declarations: "example_flags",
+			values: ["enable_qux=true"],
+		}
+	`, []string{"example_flags_release"})
+	android.FailIfNoMatchingErrors(t, `flag "enable_qux" is not declared in "example_flags"`, errs)
+}
+
+func TestFeatureFlagsInvalidValue(t *testing.T) {
+	_, errs := testPrepare(t, declarationsBp+`
+		feature_flag_values {
+			name: "example_flags_release",
+			declarations: "example_flags",
+			values: ["max_bar=lots"],
+		}
+	`, []string{"example_flags_release"})
+	android.FailIfNoMatchingErrors(t, `invalid value "lots" for int flag "max_bar"`, errs)
+}
+
+func TestFeatureFlagsSharedPackage(t *testing.T) {
+	ctx, errs := testPrepare(t, declarationsBp+`
+		feature_flag_declarations {
+			name: "other-flags",
+			package: "com.example.flags",
+			bool_flags: ["enable_foo"],
+		}
+	`, nil)
+	android.FailIfErrored(t, errs)
+
+	// Modules that declare flags in the same package generate different classes and namespaces.
+	other := ctx.ModuleForTests("other-flags", "")
+	java := android.ContentFromFileRuleForTests(t, other.Output("java/com/example/flags/OtherFlags.java"))
+	if expected := "public final class OtherFlags {"; !strings.Contains(java, expected) {
+		t.Errorf("expected %q in generated Java source, got:\n%s", expected, java)
+	}
+	header := android.ContentFromFileRuleForTests(t, other.Output("include/other-flags.h"))
+	if expected := "namespace com::example::flags::other_flags {"; !strings.Contains(header, expected) {
+		t.Errorf("expected %q in generated header, got:\n%s", expected, header)
+	}
+}
+
+func TestFeatureFlagsKeywords(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bp       string
+		expected string
+	}{
+		{
+			name: "flag",
+			bp: `
+				feature_flag_declarations {
+					name: "example_flags",
+					package: "com.example.flags",
+					bool_flags: ["delete"],
+				}
+			`,
+			expected: `invalid flag name "delete", must not be a Java or C\+\+ keyword`,
+		},
+		{
+			name: "package",
+			bp: `
+				feature_flag_declarations {
+					name: "example_flags",
+					package: "com.example.new",
+					bool_flags: ["enable_foo"],
+				}
+			`,
+			expected: `invalid package "com.example.new", "new" is a Java or C\+\+ keyword`,
+		},
+		{
+			name: "module",
+			bp: `
+				feature_flag_declarations {
+					name: "class",
+					package: "com.example.flags",
+					bool_flags: ["enable_foo"],
+				}
+			`,
+			expected: `name must start with a letter and must not be a Java or C\+\+ keyword`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errs := testPrepare(t, tc.bp, nil)
+			android.FailIfNoMatchingErrors(t, tc.expected, errs)
+		})
+	}
+}
+
+func TestFeatureFlagsUnknownDeclarations(t *testing.T) {
+	_, errs := testPrepare(t, declarationsBp+`
+		feature_flag_values {
+			name: "example_flags_release",
+			declarations: "exmaple_flags",
+			values: ["enable_foo=true"],
+		}
+	`, nil)
+	android.FailIfNoMatchingErrors(t, `unknown feature_flag_declarations module "exmaple_flags"`, errs)
+}
@@ -16,10 +16,12 @@ package filesystem
 
 import (
 	"fmt"
+	"strings"
 
 	"android/soong/android"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 )
 
 func init() {
@@ -30,8 +32,55 @@ type filesystem struct {
 	android.ModuleBase
 	android.PackagingBase
 
-	output     android.OutputPath
-	installDir android.InstallPath
+	properties filesystemProperties
+
+	output          android.OutputPath
+	installDir      android.InstallPath
+	licenseMetadata android.OutputPath
+
+	// Adds build commands that create extra files in the root directory of the image, after the
+	// packaged modules have been copied there. Set by the module types that are based on
+	// android_filesystem.
+	buildExtraFiles func(ctx android.ModuleContext, builder *android.RuleBuilder, root android.OutputPath)
+}
+
+type filesystemProperties struct {
+	// When set to true, sign the image with avbtool. Default is false.
+	Use_avb *bool
+
+	// Path to the private key that avbtool will use to sign this filesystem image.
+	// TODO(jiyong): allow apex_key to be specified here
+	Avb_private_key *string `android:"path"`
+
+	// Hash and signing algorithm for avbtool. Default is SHA256_RSA4096.
+	Avb_algorithm *string
+
+	// Rollback index for avbtool. Not set by default.
+	Avb_rollback_index *int64
+
+	// Salt for the avb hashtree as a hex string. Default is derived from the product, the build
+	// and the name of the module, so that signing the image is reproducible within a build.
+	Avb_salt *string
+
+	// Type of the filesystem. Currently, ext4, erofs and compressed_cpio are supported. Default is
+	// ext4. compressed_cpio builds a ramdisk with mkbootfs.
+	Type *string
+
+	// Compressor of a compressed_cpio image, lz4 or gzip. Default is BOARD_RAMDISK_COMPRESSION, or
+	// lz4 if that isn't set either. Older kernels can only boot gzip compressed ramdisks.
+	Ramdisk_compression *string
+
+	// Compression level of a compressed_cpio image. Default is BOARD_RAMDISK_COMPRESSION_LEVEL, or
+	// the default level of the compressor if that isn't set either.
+	Ramdisk_compression_level *int64
+
+	// file_contexts file to make image. It is compiled into the binary format before it is
+	// used to label the files in the image.
+	File_contexts *string `android:"path"`
+
+	// fs_config file that sets the owner, group, mode and capabilities of the files in the image.
+	// If unset, the fs_config_files and fs_config_dirs installed in the image are used.
+	Fs_config *string `android:"path"`
 }
 
 // android_filesystem packages a set of modules and their transitive dependencies into a filesystem
@@ -41,12 +90,20 @@ type filesystem struct {
 // partitions like system.img. For example, cc_library modules are placed under ./lib[64] directory.
 func filesystemFactory() android.Module {
 	module := &filesystem{}
+	initFilesystemModule(module)
+	return module
+}
+
+func initFilesystemModule(module *filesystem) {
+	module.AddProperties(&module.properties)
 	android.InitPackageModule(module)
 	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
-	return module
 }
 
-var dependencyTag = struct{ blueprint.BaseDependencyTag }{}
+var dependencyTag = struct {
+	blueprint.BaseDependencyTag
+	android.InstallAlwaysNeededDependencyTag
+}{}
 
 func (f *filesystem) DepsMutator(ctx android.BottomUpMutatorContext) {
 	f.AddDeps(ctx, dependencyTag)
@@ -58,7 +115,36 @@ func (f *filesystem) installFileName() string {
 
 var pctx = android.NewPackageContext("android/soong/filesystem")
 
+type fsType int
+
+const (
+	ext4Type fsType = iota
+	erofsType
+	compressedCpioType
+	unknown
+)
+
+func (f *filesystem) fsType(ctx android.ModuleContext) fsType {
+	typeStr := proptools.StringDefault(f.properties.Type, "ext4")
+	switch typeStr {
+	case "ext4":
+		return ext4Type
+	case "erofs":
+		return erofsType
+	case "compressed_cpio":
+		return compressedCpioType
+	default:
+		ctx.PropertyErrorf("type", "%q not supported", typeStr)
+		return unknown
+	}
+}
+
 func (f *filesystem) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	fsType := f.fsType(ctx)
+	if fsType == unknown {
+		return
+	}
+
 	zipFile := android.PathForModuleOut(ctx, "temp.zip").OutputPath
 	f.CopyDepsToZip(ctx, zipFile)
 
@@ -69,29 +155,170 @@ func (f *filesystem) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		FlagWithArg("-d ", rootDir.String()). // zipsync wipes this. No need to clear.
 		Input(zipFile)
 
-	mkuserimg := ctx.Config().HostToolPath(ctx, "mkuserimg_mke2fs")
-	propFile := android.PathForModuleOut(ctx, "prop").OutputPath
-	// TODO(jiyong): support more filesystem types other than ext4
-	propsText := fmt.Sprintf(`mount_point=system\n`+
-		`fs_type=ext4\n`+
-		`use_dynamic_partition_size=true\n`+
-		`ext_mkuserimg=%s\n`, mkuserimg.String())
-	builder.Command().Text("echo").Flag("-e").Flag(`"` + propsText + `"`).
-		Text(">").Output(propFile).
-		Implicit(mkuserimg)
+	if f.buildExtraFiles != nil {
+		f.buildExtraFiles(ctx, builder, rootDir)
+	}
 
 	f.output = android.PathForModuleOut(ctx, "filesystem.img").OutputPath
-	builder.Command().BuiltTool("build_image").
+	if fsType == compressedCpioType {
+		f.buildCompressedCpioImage(ctx, builder, rootDir)
+	} else {
+		f.buildImage(ctx, builder, rootDir, fsType)
+	}
+
+	f.installDir = android.PathForModuleInstall(ctx, "etc")
+	ctx.InstallFile(f.installDir, f.installFileName(), f.output)
+
+	f.licenseMetadata = f.BuildLicenseMetadata(ctx)
+}
+
+// buildImage builds the image from rootDir with build_image.
+func (f *filesystem) buildImage(ctx android.ModuleContext, builder *android.RuleBuilder, rootDir android.OutputPath, fsType fsType) {
+	propFile, toolDeps := f.buildPropFile(ctx, fsType)
+	useAvb := proptools.Bool(f.properties.Use_avb)
+	image := f.output
+	if useAvb {
+		image = android.PathForModuleOut(ctx, "unsigned.img").OutputPath
+	}
+	cmd := builder.Command()
+	if fsType == erofsType {
+		// build_image runs mkfs.erofs from PATH.
+		cmd.Text("PATH=" + ctx.Config().HostToolPath(ctx, "").String() + ":$PATH")
+	}
+	cmd.BuiltTool("build_image").
 		Text(rootDir.String()). // input directory
 		Input(propFile).
-		Output(f.output).
+		Implicits(toolDeps).
+		Output(image).
 		Text(rootDir.String()) // directory where to find fs_config_files|dirs
 
 	// rootDir is not deleted. Might be useful for quick inspection.
 	builder.Build("build_filesystem_image", fmt.Sprintf("Creating filesystem %s", f.BaseModuleName()))
 
-	f.installDir = android.PathForModuleInstall(ctx, "etc")
-	ctx.InstallFile(f.installDir, f.installFileName(), f.output)
+	if useAvb {
+		f.signImage(ctx, image)
+	}
+}
+
+// buildCompressedCpioImage builds a ramdisk from rootDir with mkbootfs, compressed with lz4 or
+// gzip.
+func (f *filesystem) buildCompressedCpioImage(ctx android.ModuleContext, builder *android.RuleBuilder, rootDir android.OutputPath) {
+	if proptools.Bool(f.properties.Use_avb) {
+		ctx.PropertyErrorf("use_avb", "not supported for compressed_cpio images")
+		return
+	}
+
+	compression := proptools.StringDefault(f.properties.Ramdisk_compression,
+		ctx.DeviceConfig().BoardRamdiskCompression())
+	if compression == "" {
+		compression = "lz4"
+	}
+	level := f.properties.Ramdisk_compression_level
+	if level == nil {
+		level = ctx.DeviceConfig().BoardRamdiskCompressionLevel()
+	}
+
+	cmd := builder.Command().
+		BuiltTool("mkbootfs").
+		Text(rootDir.String()).
+		Text("|")
+	switch compression {
+	case "lz4":
+		// The kernel only decompresses the legacy lz4 frame format.
+		cmd.BuiltTool("lz4").Flag("-l")
+		if level != nil {
+			cmd.Flag(fmt.Sprintf("-%d", *level))
+		} else {
+			cmd.Flag("-12").Flag("--favor-decSpeed")
+		}
+	case "gzip":
+		cmd.BuiltTool("minigzip")
+		if level != nil {
+			cmd.Flag(fmt.Sprintf("-%d", *level))
+		} else {
+			cmd.Flag("-9")
+		}
+	default:
+		ctx.PropertyErrorf("ramdisk_compression", "%q not supported, must be lz4 or gzip", compression)
+		return
+	}
+	cmd.Text(">").Output(f.output)
+
+	builder.Build("build_filesystem_image", fmt.Sprintf("Creating filesystem %s", f.BaseModuleName()))
+}
+
+// buildPropFile writes the properties file that configures build_image, and returns it along
+// with the files that build_image reads through the properties.
+func (f *filesystem) buildPropFile(ctx android.ModuleContext, fsType fsType) (propFile android.OutputPath, deps android.Paths) {
+	var lines []string
+	addStr := func(name string, value string) {
+		lines = append(lines, name+"="+value)
+	}
+	addPath := func(name string, path android.Path) {
+		addStr(name, path.String())
+		deps = append(deps, path)
+	}
+
+	// TODO(jiyong): support mount points other than system
+	addStr("mount_point", "system")
+	switch fsType {
+	case ext4Type:
+		addStr("fs_type", "ext4")
+		addPath("ext_mkuserimg", ctx.Config().HostToolPath(ctx, "mkuserimg_mke2fs"))
+	case erofsType:
+		addStr("fs_type", "erofs")
+		deps = append(deps, ctx.Config().HostToolPath(ctx, "mkfs.erofs"))
+	}
+	addStr("use_dynamic_partition_size", "true")
+
+	if f.properties.File_contexts != nil {
+		addPath("selinux_fc", f.buildFileContexts(ctx))
+	}
+
+	if f.properties.Fs_config != nil {
+		addPath("fs_config", android.PathForModuleSrc(ctx, *f.properties.Fs_config))
+	}
+
+	propFile = android.PathForModuleOut(ctx, "prop").OutputPath
+	android.WriteFileRule(ctx, propFile, strings.Join(lines, "\n"))
+	return propFile, deps
+}
+
+// signImage adds the avb hashtree footer to the unsigned image, writing the result to f.output.
+func (f *filesystem) signImage(ctx android.ModuleContext, unsigned android.Path) {
+	if f.properties.Avb_private_key == nil {
+		ctx.PropertyErrorf("avb_private_key", "must be set when use_avb is true")
+		return
+	}
+	android.AvbAddFooter(ctx, unsigned, f.output, android.AvbFooterParams{
+		Hashtree:      true,
+		PartitionName: f.Name(),
+		Key:           android.PathForModuleSrc(ctx, *f.properties.Avb_private_key),
+		Algorithm:     proptools.String(f.properties.Avb_algorithm),
+		Salt:          proptools.String(f.properties.Avb_salt),
+		RollbackIndex: f.properties.Avb_rollback_index,
+	})
+}
+
+// buildFileContexts compiles file_contexts into the binary format read by the image builders.
+func (f *filesystem) buildFileContexts(ctx android.ModuleContext) android.OutputPath {
+	output := android.PathForModuleOut(ctx, "file_contexts.bin").OutputPath
+	builder := android.NewRuleBuilder(pctx, ctx)
+	builder.Command().BuiltTool("sefcontext_compile").
+		FlagWithOutput("-o ", output).
+		Input(android.PathForModuleSrc(ctx, *f.properties.File_contexts))
+	builder.Build("build_filesystem_file_contexts", fmt.Sprintf("Creating filesystem file contexts for %s", f.BaseModuleName()))
+	return output
+}
+
+var _ android.OutputFileProducer = (*filesystem)(nil)
+
+// Implements android.OutputFileProducer
+func (f *filesystem) OutputFiles(tag string) (android.Paths, error) {
+	if tag == "" {
+		return android.Paths{f.output}, nil
+	}
+	return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 }
 
 var _ android.AndroidMkEntriesProvider = (*filesystem)(nil)
@@ -105,6 +332,7 @@ func (f *filesystem) AndroidMkEntries() []android.AndroidMkEntries {
 			func(entries *android.AndroidMkEntries) {
 				entries.SetString("LOCAL_MODULE_PATH", f.installDir.ToMakePath().String())
 				entries.SetString("LOCAL_INSTALLED_MODULE_STEM", f.installFileName())
+				entries.SetPath("LOCAL_SOONG_LICENSE_METADATA", f.licenseMetadata)
 			},
 		},
 	}}
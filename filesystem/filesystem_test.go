@@ -0,0 +1,490 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+	"android/soong/cc"
+	"android/soong/etc"
+
+	"github.com/google/blueprint/proptools"
+)
+
+var buildDir string
+
+func setUp() {
+	var err error
+	buildDir, err = ioutil.TempDir("", "soong_filesystem_test")
+	if err != nil {
+		panic(err)
+	}
+}
+
+func tearDown() {
+	os.RemoveAll(buildDir)
+}
+
+func TestMain(m *testing.M) {
+	run := func() int {
+		setUp()
+		defer tearDown()
+
+		return m.Run()
+	}
+
+	os.Exit(run())
+}
+
+func testFilesystemContext(bp string) (*android.TestContext, android.Config) {
+	fs := map[string][]byte{
+		"foo.conf":      nil,
+		"file_contexts": nil,
+		"fs_config":     nil,
+		"testkey.pem":   nil,
+		"system.img":    nil,
+		"vendor.img":    nil,
+	}
+
+	config := android.TestArchConfig(buildDir, nil, bp, fs)
+
+	ctx := android.NewTestArchContext(config)
+	ctx.RegisterModuleType("android_filesystem", filesystemFactory)
+	ctx.RegisterModuleType("super_image", superImageFactory)
+	etc.RegisterPrebuiltEtcBuildComponents(ctx)
+	ctx.Register()
+
+	return ctx, config
+}
+
+func testFilesystem(t *testing.T, bp string) *android.TestContext {
+	t.Helper()
+
+	ctx, config := testFilesystemContext(bp)
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	return ctx
+}
+
+func testFilesystemError(t *testing.T, pattern, bp string) {
+	t.Helper()
+
+	ctx, config := testFilesystemContext(bp)
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	if len(errs) > 0 {
+		android.FailIfNoMatchingErrors(t, pattern, errs)
+		return
+	}
+	_, errs = ctx.PrepareBuildActions(config)
+	if len(errs) > 0 {
+		android.FailIfNoMatchingErrors(t, pattern, errs)
+		return
+	}
+
+	t.Fatalf("missing expected error %q (0 errors are returned)", pattern)
+}
+
+func propFileLines(t *testing.T, ctx *android.TestContext) []string {
+	t.Helper()
+	module := ctx.ModuleForTests("myfilesystem", "android_common")
+	return strings.Split(android.ContentFromFileRuleForTests(t, module.Output("prop")), "\n")
+}
+
+func ensurePropLine(t *testing.T, lines []string, prefix string) {
+	t.Helper()
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return
+		}
+	}
+	t.Errorf("expected a line starting with %q in the prop file, got %q", prefix, lines)
+}
+
+func TestFilesystemDefaults(t *testing.T) {
+	ctx := testFilesystem(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			deps: ["foo.conf"],
+		}
+
+		prebuilt_etc {
+			name: "foo.conf",
+			src: "foo.conf",
+		}
+	`)
+
+	lines := propFileLines(t, ctx)
+	ensurePropLine(t, lines, "mount_point=system")
+	ensurePropLine(t, lines, "fs_type=ext4")
+	ensurePropLine(t, lines, "ext_mkuserimg=")
+	for _, line := range lines {
+		if strings.HasPrefix(line, "avb_") {
+			t.Errorf("unexpected avb property %q in the prop file", line)
+		}
+	}
+
+	module := ctx.ModuleForTests("myfilesystem", "android_common")
+	image := module.Output("filesystem.img")
+	if !strings.Contains(image.RuleParams.Command, "build_image") {
+		t.Errorf("expected the image to be built with build_image, got %q", image.RuleParams.Command)
+	}
+}
+
+func TestFilesystemOptions(t *testing.T) {
+	ctx := testFilesystem(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "erofs",
+			use_avb: true,
+			avb_private_key: "testkey.pem",
+			avb_algorithm: "SHA256_RSA2048",
+			avb_rollback_index: 3,
+			file_contexts: "file_contexts",
+			fs_config: "fs_config",
+		}
+	`)
+
+	lines := propFileLines(t, ctx)
+	ensurePropLine(t, lines, "fs_type=erofs")
+	ensurePropLine(t, lines, "selinux_fc=")
+	ensurePropLine(t, lines, "fs_config=fs_config")
+
+	module := ctx.ModuleForTests("myfilesystem", "android_common")
+	unsigned := module.Output("unsigned.img")
+	if !strings.Contains(unsigned.RuleParams.Command, "build_image") {
+		t.Errorf("expected the unsigned image to be built with build_image, got %q", unsigned.RuleParams.Command)
+	}
+
+	signed := module.Output("filesystem.img")
+	if signed.Input.String() != unsigned.Output.String() {
+		t.Errorf("expected the unsigned image %q to be signed, got %q", unsigned.Output, signed.Input)
+	}
+	expectedArgs := map[string]string{
+		"footerCmd":     "add_hashtree_footer",
+		"partitionName": "myfilesystem",
+		"key":           "testkey.pem",
+		"algorithm":     "SHA256_RSA2048",
+	}
+	for arg, expected := range expectedArgs {
+		if got := signed.Args[arg]; got != expected {
+			t.Errorf("expected avbtool arg %s to be %q, got %q", arg, expected, got)
+		}
+	}
+	// The default salt is pinned, so that signing the image is reproducible.
+	if !regexp.MustCompile(`^--salt [0-9a-f]{64} --rollback_index 3$`).MatchString(signed.Args["signingArgs"]) {
+		t.Errorf("expected a pinned avb salt and the rollback index, got %q", signed.Args["signingArgs"])
+	}
+
+	fileContexts := module.Output("file_contexts.bin")
+	if !strings.Contains(fileContexts.RuleParams.Command, "sefcontext_compile") {
+		t.Errorf("expected file_contexts to be compiled with sefcontext_compile, got %q",
+			fileContexts.RuleParams.Command)
+	}
+}
+
+func TestFilesystemErrors(t *testing.T) {
+	testFilesystemError(t, `type: "ext3" not supported`, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "ext3",
+		}
+	`)
+
+	testFilesystemError(t, `avb_private_key: must be set when use_avb is true`, `
+		android_filesystem {
+			name: "myfilesystem",
+			use_avb: true,
+		}
+	`)
+}
+
+func TestSuperImage(t *testing.T) {
+	ctx := testFilesystem(t, `
+		android_filesystem {
+			name: "mysystem",
+		}
+
+		super_image {
+			name: "mysuper",
+			size: 4294967296,
+			virtual_ab: true,
+			sparse: true,
+			partition_groups: [
+				{
+					name: "google_dynamic_partitions",
+					size: 4290772992,
+					partitions: [
+						{
+							name: "system",
+							image: ":mysystem",
+						},
+						{
+							name: "vendor",
+							image: "vendor.img",
+						},
+					],
+				},
+			],
+		}
+	`)
+
+	module := ctx.ModuleForTests("mysuper", "android_common")
+	systemImage := ctx.ModuleForTests("mysystem", "android_common").Output("filesystem.img").Output
+
+	super := module.Output("super.img")
+	for _, w := range []string{
+		"--metadata-size 65536",
+		"--metadata-slots 3",
+		"--device super:4294967296",
+		"--virtual-ab",
+		"--group google_dynamic_partitions:4290772992",
+		"--partition system:readonly:$$(stat -L -c %s " + systemImage.String() + "):google_dynamic_partitions",
+		"--image system=" + systemImage.String(),
+		"--image vendor=vendor.img",
+		"--sparse",
+	} {
+		if !strings.Contains(super.RuleParams.Command, w) {
+			t.Errorf("expected %q in the super.img command %q", w, super.RuleParams.Command)
+		}
+	}
+	if !android.InList(systemImage.String(), super.Implicits.Strings()) {
+		t.Errorf("expected %q in the inputs of super.img, got %q", systemImage, super.Implicits)
+	}
+
+	empty := module.Output("super_empty.img")
+	for _, w := range []string{
+		"--partition system:readonly:0:google_dynamic_partitions",
+		"--partition vendor:readonly:0:google_dynamic_partitions",
+	} {
+		if !strings.Contains(empty.RuleParams.Command, w) {
+			t.Errorf("expected %q in the super_empty.img command %q", w, empty.RuleParams.Command)
+		}
+	}
+	for _, u := range []string{"--image", "--sparse"} {
+		if strings.Contains(empty.RuleParams.Command, u) {
+			t.Errorf("unexpected %q in the super_empty.img command %q", u, empty.RuleParams.Command)
+		}
+	}
+}
+
+func TestSuperImageErrors(t *testing.T) {
+	testFilesystemError(t, `size: must be set`, `
+		super_image {
+			name: "mysuper",
+			partition_groups: [{name: "group"}],
+		}
+	`)
+
+	testFilesystemError(t, `partition "system" is listed more than once`, `
+		super_image {
+			name: "mysuper",
+			size: 4294967296,
+			partition_groups: [
+				{
+					name: "group",
+					partitions: [
+						{name: "system", image: "system.img"},
+						{name: "system", image: "system.img"},
+					],
+				},
+			],
+		}
+	`)
+}
+
+func TestFilesystemCompressedCpio(t *testing.T) {
+	ctx := testFilesystem(t, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "compressed_cpio",
+		}
+
+		android_filesystem {
+			name: "mygzipfilesystem",
+			type: "compressed_cpio",
+			ramdisk_compression: "gzip",
+			ramdisk_compression_level: 6,
+		}
+	`)
+
+	lz4 := ctx.ModuleForTests("myfilesystem", "android_common").Output("filesystem.img")
+	for _, w := range []string{"mkbootfs", "lz4 -l -12 --favor-decSpeed"} {
+		if !strings.Contains(lz4.RuleParams.Command, w) {
+			t.Errorf("expected %q in the ramdisk command %q", w, lz4.RuleParams.Command)
+		}
+	}
+
+	gzip := ctx.ModuleForTests("mygzipfilesystem", "android_common").Output("filesystem.img")
+	for _, w := range []string{"mkbootfs", "minigzip -6"} {
+		if !strings.Contains(gzip.RuleParams.Command, w) {
+			t.Errorf("expected %q in the ramdisk command %q", w, gzip.RuleParams.Command)
+		}
+	}
+}
+
+func TestFilesystemCompressedCpioBoardConfig(t *testing.T) {
+	ctx, config := testFilesystemContext(`
+		android_filesystem {
+			name: "myfilesystem",
+			type: "compressed_cpio",
+		}
+	`)
+	config.TestProductVariables.BoardRamdiskCompression = proptools.StringPtr("gzip")
+	level := int64(9)
+	config.TestProductVariables.BoardRamdiskCompressionLevel = &level
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	image := ctx.ModuleForTests("myfilesystem", "android_common").Output("filesystem.img")
+	if !strings.Contains(image.RuleParams.Command, "minigzip -9") {
+		t.Errorf("expected the ramdisk to be compressed with gzip, got %q", image.RuleParams.Command)
+	}
+}
+
+func TestFilesystemCompressedCpioErrors(t *testing.T) {
+	testFilesystemError(t, `ramdisk_compression: "xz" not supported`, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "compressed_cpio",
+			ramdisk_compression: "xz",
+		}
+	`)
+
+	testFilesystemError(t, `use_avb: not supported for compressed_cpio images`, `
+		android_filesystem {
+			name: "myfilesystem",
+			type: "compressed_cpio",
+			use_avb: true,
+			avb_private_key: "testkey.pem",
+		}
+	`)
+}
+
+func testSystemImageContext(bp string) (*android.TestContext, android.Config) {
+	fs := map[string][]byte{
+		"linker.config.json":  nil,
+		"libprovider.map.txt": nil,
+		"libapex.map.txt":     nil,
+	}
+
+	config := cc.TestConfig(buildDir, android.Android, nil, bp, fs)
+
+	ctx := cc.CreateTestContext(config)
+	ctx.RegisterModuleType("android_filesystem", filesystemFactory)
+	ctx.RegisterModuleType("android_system_image", systemImageFactory)
+	ctx.Register()
+
+	return ctx, config
+}
+
+func TestSystemImageLinkerConfig(t *testing.T) {
+	ctx, config := testSystemImageContext(`
+		android_system_image {
+			name: "myimage",
+			linker_config_src: "linker.config.json",
+			multilib: {
+				lib64: {
+					deps: ["bin"],
+				},
+			},
+		}
+
+		cc_binary {
+			name: "bin",
+			shared_libs: ["libprovider", "libapex"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libprovider",
+			stubs: {
+				symbol_file: "libprovider.map.txt",
+				versions: ["1"],
+			},
+			system_shared_libs: [],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libapex",
+			stubs: {
+				symbol_file: "libapex.map.txt",
+				versions: ["1"],
+			},
+			apex_available: ["myapex"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`)
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfErrored(t, errs)
+
+	cmd := ctx.ModuleForTests("myimage", "android_common").Output("filesystem.img").RuleParams.Command
+	for _, w := range []string{
+		"conv_linker_config proto -s linker.config.json",
+		"conv_linker_config systemprovide",
+		`--value "libprovider.so"`,
+		"conv_linker_config append",
+		`--key requireLibs --value "libapex.so"`,
+	} {
+		if !strings.Contains(cmd, w) {
+			t.Errorf("expected %q in the command %q", w, cmd)
+		}
+	}
+}
+
+func TestSystemImageDanglingSharedLib(t *testing.T) {
+	ctx, config := testSystemImageContext(`
+		android_system_image {
+			name: "myimage",
+			multilib: {
+				lib64: {
+					deps: ["bin"],
+				},
+			},
+		}
+
+		cc_binary {
+			name: "bin",
+			shared_libs: ["libhidden"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+
+		cc_library {
+			name: "libhidden",
+			installable: false,
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`)
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfNoMatchingErrors(t, `"bin" depends on the shared library "libhidden", which is not installed in the image`, errs)
+}
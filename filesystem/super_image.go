@@ -0,0 +1,224 @@
+// Copyright (C) 2021 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"fmt"
+	"strconv"
+
+	"android/soong/android"
+
+	"github.com/google/blueprint/proptools"
+)
+
+func init() {
+	android.RegisterModuleType("super_image", superImageFactory)
+}
+
+type superImage struct {
+	android.ModuleBase
+
+	properties superImageProperties
+
+	output      android.OutputPath
+	emptyOutput android.OutputPath
+}
+
+type superImageProperties struct {
+	// Size of the super partition in bytes.
+	Size *int64
+
+	// Size of the metadata of the super partition in bytes. Default is 65536.
+	Metadata_size *int64
+
+	// Number of metadata slots. Default is 2, or 3 when virtual_ab is true.
+	Metadata_slots *int64
+
+	// Block size of the super partition in bytes. Default is 4096.
+	Block_size *int64
+
+	// When set to true, the device uses Virtual A/B. Default is false.
+	Virtual_ab *bool
+
+	// When set to true, super.img is built as a sparse image. Default is false.
+	Sparse *bool
+
+	// Groups of logical partitions in the super partition.
+	Partition_groups []superImageGroupProperties
+}
+
+type superImageGroupProperties struct {
+	// Name of the group.
+	Name *string
+
+	// Maximum total size of the partitions in the group in bytes. Default is 0, which doesn't limit
+	// the size of the group.
+	Size *int64
+
+	// Logical partitions in the group.
+	Partitions []superImagePartitionProperties
+}
+
+type superImagePartitionProperties struct {
+	// Name of the logical partition, e.g. system.
+	Name *string
+
+	// Image of the partition, e.g. the output of an android_filesystem module. The partition is as
+	// large as the image.
+	Image *string
+}
+
+// super_image assembles the images of a set of logical partitions into super.img with lpmake. It
+// also builds super_empty.img, which holds only the partition layout and is used to flash devices
+// with dynamic partitions. super_empty.img can be referenced with ":<name>{.empty}".
+func superImageFactory() android.Module {
+	module := &superImage{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
+	return module
+}
+
+func (s *superImage) DepsMutator(ctx android.BottomUpMutatorContext) {
+	// The images are nested in a list of groups, which the android:"path" tag doesn't reach.
+	for _, group := range s.properties.Partition_groups {
+		for _, partition := range group.Partitions {
+			android.ExtractSourceDeps(ctx, partition.Image)
+		}
+	}
+}
+
+func (s *superImage) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if s.properties.Size == nil {
+		ctx.PropertyErrorf("size", "must be set")
+		return
+	}
+	if len(s.properties.Partition_groups) == 0 {
+		ctx.PropertyErrorf("partition_groups", "must have at least one group")
+		return
+	}
+
+	partitions := make(map[string]bool)
+	for i, group := range s.properties.Partition_groups {
+		if proptools.String(group.Name) == "" {
+			ctx.PropertyErrorf("partition_groups", "group #%d doesn't have a name", i)
+		}
+		for _, partition := range group.Partitions {
+			name := proptools.String(partition.Name)
+			if name == "" {
+				ctx.PropertyErrorf("partition_groups", "a partition in group %q doesn't have a name",
+					proptools.String(group.Name))
+			} else if partitions[name] {
+				ctx.PropertyErrorf("partition_groups", "partition %q is listed more than once", name)
+			} else if partition.Image == nil {
+				ctx.PropertyErrorf("partition_groups", "partition %q doesn't have an image", name)
+			}
+			partitions[name] = true
+		}
+	}
+	if ctx.Failed() {
+		return
+	}
+
+	s.output = android.PathForModuleOut(ctx, "super.img").OutputPath
+	s.emptyOutput = android.PathForModuleOut(ctx, "super_empty.img").OutputPath
+
+	builder := android.NewRuleBuilder(pctx, ctx)
+	cmd := s.lpmakeCommand(ctx, builder, true)
+	if proptools.Bool(s.properties.Sparse) {
+		cmd.Flag("--sparse")
+	}
+	cmd.FlagWithOutput("--output ", s.output)
+	builder.Build("build_super_image", fmt.Sprintf("Creating super image %s", s.BaseModuleName()))
+
+	builder = android.NewRuleBuilder(pctx, ctx)
+	s.lpmakeCommand(ctx, builder, false).FlagWithOutput("--output ", s.emptyOutput)
+	builder.Build("build_super_empty_image", fmt.Sprintf("Creating empty super image %s", s.BaseModuleName()))
+}
+
+// lpmakeCommand returns an lpmake command with the partition layout of the super partition. When
+// withImages is true, the partitions are as large as their images and the images are written
+// into the super image, otherwise the partitions are empty.
+func (s *superImage) lpmakeCommand(ctx android.ModuleContext, builder *android.RuleBuilder, withImages bool) *android.RuleBuilderCommand {
+	metadataSlots := int64(2)
+	if proptools.Bool(s.properties.Virtual_ab) {
+		metadataSlots = 3
+	}
+	if s.properties.Metadata_slots != nil {
+		metadataSlots = *s.properties.Metadata_slots
+	}
+
+	cmd := builder.Command().BuiltTool("lpmake").
+		FlagWithArg("--metadata-size ", strconv.FormatInt(int64Default(s.properties.Metadata_size, 65536), 10)).
+		FlagWithArg("--metadata-slots ", strconv.FormatInt(metadataSlots, 10)).
+		FlagWithArg("--block-size ", strconv.FormatInt(int64Default(s.properties.Block_size, 4096), 10)).
+		FlagWithArg("--super-name ", "super").
+		FlagWithArg("--device ", "super:"+strconv.FormatInt(*s.properties.Size, 10))
+	if proptools.Bool(s.properties.Virtual_ab) {
+		cmd.Flag("--virtual-ab")
+	}
+
+	for _, group := range s.properties.Partition_groups {
+		groupName := proptools.String(group.Name)
+		cmd.FlagWithArg("--group ", groupName+":"+strconv.FormatInt(int64Default(group.Size, 0), 10))
+		for _, partition := range group.Partitions {
+			name := proptools.String(partition.Name)
+			if !withImages {
+				cmd.FlagWithArg("--partition ", name+":readonly:0:"+groupName)
+				continue
+			}
+			image := android.PathForModuleSrc(ctx, *partition.Image)
+			// The size of the image is only known when the command runs.
+			cmd.Textf("--partition %s:readonly:$(stat -L -c %%s %s):%s", name, image.String(), groupName).
+				FlagWithInput("--image "+name+"=", image)
+		}
+	}
+	return cmd
+}
+
+func int64Default(i *int64, def int64) int64 {
+	if i != nil {
+		return *i
+	}
+	return def
+}
+
+var _ android.OutputFileProducer = (*superImage)(nil)
+
+// Implements android.OutputFileProducer
+func (s *superImage) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case "":
+		return android.Paths{s.output}, nil
+	case ".empty":
+		return android.Paths{s.emptyOutput}, nil
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
+var _ android.AndroidMkEntriesProvider = (*superImage)(nil)
+
+// Implements android.AndroidMkEntriesProvider
+func (s *superImage) AndroidMkEntries() []android.AndroidMkEntries {
+	return []android.AndroidMkEntries{android.AndroidMkEntries{
+		Class:      "ETC",
+		OutputFile: android.OptionalPathForPath(s.output),
+		ExtraEntries: []android.AndroidMkExtraEntriesFunc{
+			func(entries *android.AndroidMkEntries) {
+				entries.SetBool("LOCAL_UNINSTALLABLE_MODULE", true)
+			},
+		},
+	}}
+}
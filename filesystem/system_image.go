@@ -0,0 +1,133 @@
+// Copyright (C) 2021 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"strings"
+
+	"android/soong/android"
+	"android/soong/cc"
+)
+
+func init() {
+	android.RegisterModuleType("android_system_image", systemImageFactory)
+}
+
+type systemImage struct {
+	filesystem
+
+	properties systemImageProperties
+}
+
+type systemImageProperties struct {
+	// Path to the input linker config json file. The provideLibs and requireLibs of the packaged
+	// modules are added to it. If unset, an empty linker config is used as the input.
+	Linker_config_src *string `android:"path"`
+}
+
+// android_system_image is a specialization of android_filesystem for the system partition. It
+// generates /system/etc/linker.config.pb from the packaged modules, and fails the build when a
+// packaged module depends on a shared library that is neither in the image nor provided by an APEX.
+func systemImageFactory() android.Module {
+	module := &systemImage{}
+	module.AddProperties(&module.properties)
+	module.filesystem.buildExtraFiles = module.buildExtraFiles
+	initFilesystemModule(&module.filesystem)
+	return module
+}
+
+func (s *systemImage) buildExtraFiles(ctx android.ModuleContext, builder *android.RuleBuilder, root android.OutputPath) {
+	provideLibs, requireLibs := s.collectLinkerConfigLibs(ctx)
+	if ctx.Failed() {
+		return
+	}
+
+	var input android.Path
+	if s.properties.Linker_config_src != nil {
+		input = android.PathForModuleSrc(ctx, *s.properties.Linker_config_src)
+	} else {
+		emptyConfig := android.PathForModuleOut(ctx, "linker.config.json")
+		android.WriteFileRule(ctx, emptyConfig, "{}")
+		input = emptyConfig
+	}
+
+	// The image is mounted at /system, so /system/etc/linker.config.pb is etc/linker.config.pb in
+	// the root directory.
+	output := root.Join(ctx, "etc", "linker.config.pb").String()
+	builder.Command().Text("mkdir").Flag("-p").Text(root.Join(ctx, "etc").String())
+	builder.Command().BuiltTool("conv_linker_config").
+		Flag("validate").
+		FlagWithInput("-s ", input)
+	builder.Command().BuiltTool("conv_linker_config").
+		Flag("proto").
+		FlagWithInput("-s ", input).
+		FlagWithArg("-o ", output)
+	if len(provideLibs) > 0 {
+		builder.Command().BuiltTool("conv_linker_config").
+			Flag("systemprovide").
+			FlagWithArg("--source ", output).
+			FlagWithArg("--output ", output).
+			FlagWithArg("--value ", "\""+strings.Join(provideLibs, " ")+"\"").
+			FlagWithArg("--system ", root.String())
+	}
+	if len(requireLibs) > 0 {
+		builder.Command().BuiltTool("conv_linker_config").
+			Flag("append").
+			FlagWithArg("--source ", output).
+			FlagWithArg("--output ", output).
+			FlagWithArg("--key ", "requireLibs").
+			FlagWithArg("--value ", "\""+strings.Join(requireLibs, " ")+"\"")
+	}
+}
+
+// collectLinkerConfigLibs returns the libraries in the image that provide stubs to other
+// partitions and APEXes, and the libraries whose stubs the packaged modules link against, which
+// are provided by APEXes at run time. It reports an error for every shared library dependency of a
+// packaged module that is not installed in the image, because it would be missing at run time.
+func (s *systemImage) collectLinkerConfigLibs(ctx android.ModuleContext) (provideLibs, requireLibs []string) {
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		tag := ctx.OtherModuleDependencyTag(child)
+		if !android.IsInstallDepNeeded(tag) {
+			return false
+		}
+
+		c, isCc := child.(*cc.Module)
+		if isCc && c.IsStubs() {
+			// The implementation of the library is in an APEX, so the stubs aren't packaged.
+			if c.OutputFile().Valid() {
+				requireLibs = append(requireLibs, c.OutputFile().Path().Base())
+			}
+			return false
+		}
+
+		if len(child.PackagingSpecs()) == 0 {
+			if parent != ctx.Module() && cc.IsSharedDepTag(tag) {
+				ctx.ModuleErrorf("%q depends on the shared library %q, which is not installed in the image",
+					ctx.OtherModuleName(parent), ctx.OtherModuleName(child))
+			}
+			return true
+		}
+
+		if isCc && c.HasStubsVariants() {
+			for _, ps := range child.PackagingSpecs() {
+				if strings.HasSuffix(ps.FileName(), ".so") {
+					provideLibs = append(provideLibs, ps.FileName())
+				}
+			}
+		}
+		return true
+	})
+	return android.SortedUniqueStrings(provideLibs), android.SortedUniqueStrings(requireLibs)
+}
@@ -201,7 +201,7 @@ func toolDepsMutator(ctx android.BottomUpMutatorContext) {
 // Returns true if information was available from Bazel, false if bazel invocation still needs to occur.
 func (c *Module) generateBazelBuildActions(ctx android.ModuleContext, label string) bool {
 	bazelCtx := ctx.Config().BazelContext
-	filePaths, ok := bazelCtx.GetAllFiles(label)
+	filePaths, ok := bazelCtx.GetAllFiles(label, android.GetConfigKey(ctx))
 	if ok {
 		var bazelOutputFiles android.Paths
 		for _, bazelOutputFile := range filePaths {
@@ -473,6 +473,10 @@ func (g *Module) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		cmd.ImplicitPackagedTools(packagedTools)
 		if Bool(g.properties.Depfile) {
 			cmd.ImplicitDepFile(task.depFile)
+		} else if ctx.Config().GenruleSandboxInputs() {
+			// Commands that write a depfile find some of their inputs themselves, so only
+			// the others can be limited to their declared inputs.
+			rule.SandboxInputs()
 		}
 
 		// Create the rule to run the genrule command inside sbox.
@@ -616,6 +620,29 @@ func NewGenSrcs() *Module {
 			shardSize = int(*s)
 		}
 
+		if !validateOutputTemplates(ctx, properties) {
+			return nil
+		}
+
+		// outputsFor returns the files generated from the input file in into subDir.
+		generatedFrom := make(map[string]android.Path)
+		outputsFor := func(subDir string, in android.Path) android.WritablePaths {
+			if len(properties.Output_templates) == 0 {
+				return android.WritablePaths{android.GenPathWithExt(ctx, subDir, in, String(properties.Output_extension))}
+			}
+			var outs android.WritablePaths
+			for _, template := range properties.Output_templates {
+				out := android.PathForModuleGen(ctx, subDir, expandOutputTemplate(template, in))
+				if other, exists := generatedFrom[out.String()]; exists && other.String() != in.String() {
+					ctx.PropertyErrorf("output_templates", "output %q is generated from both %q and %q",
+						out.Rel(), other, in)
+				}
+				generatedFrom[out.String()] = in
+				outs = append(outs, out)
+			}
+			return outs
+		}
+
 		// gensrcs rules can easily hit command line limits by repeating the command for
 		// every input file.  Shard the input files into groups.
 		shards := android.ShardPaths(srcFiles, shardSize)
@@ -643,31 +670,34 @@ func NewGenSrcs() *Module {
 			rule := android.NewRuleBuilder(pctx, ctx).Sbox(genDir, nil).SandboxTools()
 
 			for _, in := range shard {
-				outFile := android.GenPathWithExt(ctx, finalSubDir, in, String(properties.Output_extension))
+				inOutFiles := outputsFor(finalSubDir, in)
 
-				// If sharding is enabled, then outFile is the path to the output file in
-				// the shard directory, and copyTo is the path to the output file in the
+				// If sharding is enabled, then inOutFiles are the paths to the output files in
+				// the shard directory, and copyTo are the paths to the output files in the
 				// final directory.
 				if len(shards) > 1 {
-					shardFile := android.GenPathWithExt(ctx, genSubDir, in, String(properties.Output_extension))
-					copyTo = append(copyTo, outFile)
-					outFile = shardFile
+					copyTo = append(copyTo, inOutFiles...)
+					inOutFiles = outputsFor(genSubDir, in)
 				}
 
-				outFiles = append(outFiles, outFile)
+				outFiles = append(outFiles, inOutFiles...)
 
 				// pre-expand the command line to replace $in and $out with references to
-				// a single input and output file.
+				// a single input file and the output files generated from it.
 				command, err := android.Expand(rawCommand, func(name string) (string, error) {
 					switch name {
 					case "in":
 						return in.String(), nil
 					case "out":
-						return rule.Command().PathForOutput(outFile), nil
+						var sandboxOuts []string
+						for _, outFile := range inOutFiles {
+							sandboxOuts = append(sandboxOuts, rule.Command().PathForOutput(outFile))
+						}
+						return strings.Join(sandboxOuts, " "), nil
 					case "depfile":
-						// Generate a depfile for each output file.  Store the list for
+						// Generate a depfile for each input file.  Store the list for
 						// later in order to combine them all into a single depfile.
-						depFile := rule.Command().PathForOutput(outFile.ReplaceExtension(ctx, "d"))
+						depFile := rule.Command().PathForOutput(inOutFiles[0].ReplaceExtension(ctx, "d"))
 						commandDepFiles = append(commandDepFiles, depFile)
 						return depFile, nil
 					default:
@@ -729,12 +759,68 @@ type genSrcsProperties struct {
 	// extension that will be substituted for each output file
 	Output_extension *string
 
+	// templates for the names of the files generated from each input file, as an alternative to
+	// output_extension for commands that generate more than one file per input.  $(in) is replaced
+	// with the path of the input file relative to the module directory, and $(in_noext) with the
+	// same path without its extension, e.g. ["$(in_noext).pb.cc", "$(in_noext).pb.h"].  $(out) in
+	// the command is replaced with all the files generated from the input file.
+	Output_templates []string
+
 	// maximum number of files that will be passed on a single command line.
 	Shard_size *int64
 }
 
 const defaultShardSize = 50
 
+// validateOutputTemplates reports an error for each output template that doesn't generate a
+// different file for each input file, and returns false if there were any errors.
+func validateOutputTemplates(ctx android.ModuleContext, properties *genSrcsProperties) bool {
+	if len(properties.Output_templates) == 0 {
+		return true
+	}
+	if properties.Output_extension != nil {
+		ctx.PropertyErrorf("output_templates", "cannot be used together with output_extension")
+		return false
+	}
+	valid := true
+	for _, template := range properties.Output_templates {
+		referencesInput := false
+		_, err := android.Expand(template, func(name string) (string, error) {
+			switch name {
+			case "in", "in_noext":
+				referencesInput = true
+				return "", nil
+			default:
+				return "", fmt.Errorf("unknown variable '$(%s)'", name)
+			}
+		})
+		if err != nil {
+			ctx.PropertyErrorf("output_templates", "%q: %s", template, err)
+			valid = false
+		} else if !referencesInput {
+			ctx.PropertyErrorf("output_templates", "%q must reference $(in) or $(in_noext)", template)
+			valid = false
+		}
+	}
+	return valid
+}
+
+// expandOutputTemplate returns the name of the file generated from the input file in by the
+// output template, relative to the gensrcs output directory.
+func expandOutputTemplate(template string, in android.Path) string {
+	out, _ := android.Expand(template, func(name string) (string, error) {
+		switch name {
+		case "in":
+			return in.Rel(), nil
+		case "in_noext":
+			return strings.TrimSuffix(in.Rel(), filepath.Ext(in.Rel())), nil
+		default:
+			return "", nil
+		}
+	})
+	return out
+}
+
 func NewGenRule() *Module {
 	properties := &genRuleProperties{}
 
@@ -18,6 +18,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -580,6 +581,73 @@ func TestGenruleHashInputs(t *testing.T) {
 	}
 }
 
+func TestGenruleSandboxInputs(t *testing.T) {
+	bp := `
+		tool {
+			name: "tool",
+		}
+
+		genrule {
+			name: "gen",
+			tools: ["tool"],
+			srcs: ["in1.txt"],
+			out: ["out"],
+			cmd: "$(location) $(in) > $(out)",
+		}
+
+		genrule {
+			name: "gen_depfile",
+			tools: ["tool"],
+			srcs: ["in1.txt"],
+			out: ["out"],
+			depfile: true,
+			cmd: "$(location) $(in) > $(out) -d $(depfile)",
+		}
+	`
+
+	mockFS := map[string][]byte{
+		"tool":    nil,
+		"in1.txt": nil,
+	}
+
+	for _, sandbox := range []bool{false, true} {
+		env := map[string]string{}
+		if sandbox {
+			env["SOONG_GENRULE_SANDBOX_INPUTS"] = "true"
+		}
+		config := android.TestArchConfig(buildDir, env, bp, mockFS)
+		ctx := testContext(config)
+		_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+		if errs == nil {
+			_, errs = ctx.PrepareBuildActions(config)
+		}
+		if errs != nil {
+			t.Fatal(errs)
+		}
+
+		manifest := android.RuleBuilderSboxProtoForTests(t,
+			ctx.ModuleForTests("gen", "").Output("genrule.sbox.textproto"))
+		command := manifest.Commands[0]
+		if g, w := command.GetChdir(), sandbox; g != w {
+			t.Errorf("with sandboxing %v, expected chdir %v, got %v", sandbox, w, g)
+		}
+		var copied []string
+		for _, c := range command.CopyBefore {
+			copied = append(copied, c.GetTo())
+		}
+		if g, w := android.InList("in1.txt", copied), sandbox; g != w {
+			t.Errorf("with sandboxing %v, expected in1.txt copied into the sandbox %v, got %q", sandbox, w, copied)
+		}
+
+		// Rules that write a depfile can't be limited to their declared inputs.
+		manifest = android.RuleBuilderSboxProtoForTests(t,
+			ctx.ModuleForTests("gen_depfile", "").Output("genrule.sbox.textproto"))
+		if manifest.Commands[0].GetChdir() {
+			t.Errorf("with sandboxing %v, expected no chdir for a genrule with a depfile", sandbox)
+		}
+	}
+}
+
 func TestGenSrcs(t *testing.T) {
 	testcases := []struct {
 		name string
@@ -672,6 +740,101 @@ func TestGenSrcs(t *testing.T) {
 
 }
 
+func TestGenSrcsOutputTemplates(t *testing.T) {
+	bp := `
+		gensrcs {
+			name: "gen",
+			tools: ["tool"],
+			srcs: ["in1.txt", "in2.txt", "in3.txt"],
+			output_templates: ["$(in_noext).pb.cc", "$(in_noext).pb.h"],
+			cmd: "$(location) $(in) $(out)",
+			shard_size: 2,
+		}
+	`
+
+	config := testConfig(bp, nil)
+	ctx := testContext(config)
+	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+	if errs == nil {
+		_, errs = ctx.PrepareBuildActions(config)
+	}
+	android.FailIfErrored(t, errs)
+
+	gen := ctx.ModuleForTests("gen", "").Module().(*Module)
+
+	wantCmds := []string{
+		"bash -c '__SBOX_SANDBOX_DIR__/tools/out/bin/tool in1.txt __SBOX_SANDBOX_DIR__/out/in1.pb.cc __SBOX_SANDBOX_DIR__/out/in1.pb.h' && " +
+			"bash -c '__SBOX_SANDBOX_DIR__/tools/out/bin/tool in2.txt __SBOX_SANDBOX_DIR__/out/in2.pb.cc __SBOX_SANDBOX_DIR__/out/in2.pb.h'",
+		"bash -c '__SBOX_SANDBOX_DIR__/tools/out/bin/tool in3.txt __SBOX_SANDBOX_DIR__/out/in3.pb.cc __SBOX_SANDBOX_DIR__/out/in3.pb.h'",
+	}
+	if g, w := gen.rawCommands, wantCmds; !reflect.DeepEqual(w, g) {
+		t.Errorf("want %q, got %q", w, g)
+	}
+
+	var wantFiles []string
+	for _, in := range []string{"in1", "in2", "in3"} {
+		for _, ext := range []string{".pb.cc", ".pb.h"} {
+			wantFiles = append(wantFiles, buildDir+"/.intermediates/gen/gen/gensrcs/"+in+ext)
+		}
+	}
+	if g, w := gen.outputFiles.Strings(), wantFiles; !reflect.DeepEqual(w, g) {
+		t.Errorf("want files %q, got %q", w, g)
+	}
+}
+
+func TestGenSrcsOutputTemplatesErrors(t *testing.T) {
+	testcases := []struct {
+		name string
+		prop string
+		err  string
+	}{
+		{
+			name: "missing input",
+			prop: `output_templates: ["out.h"],`,
+			err:  `"out.h" must reference $(in) or $(in_noext)`,
+		},
+		{
+			name: "unknown variable",
+			prop: `output_templates: ["$(foo).h"],`,
+			err:  `unknown variable '$(foo)'`,
+		},
+		{
+			name: "output_extension",
+			prop: `output_templates: ["$(in).h"], output_extension: "h",`,
+			err:  `cannot be used together with output_extension`,
+		},
+		{
+			name: "conflicting outputs",
+			prop: `output_templates: ["$(in_noext).h"], srcs: ["in1", "in1.txt"],`,
+			err:  `is generated from both "in1" and "in1.txt"`,
+		},
+	}
+
+	for _, test := range testcases {
+		t.Run(test.name, func(t *testing.T) {
+			bp := `
+				gensrcs {
+					name: "gen",
+					tools: ["tool"],
+					cmd: "$(location) $(in) $(out)",
+					` + test.prop + `
+				}
+			`
+			if !strings.Contains(test.prop, "srcs:") {
+				bp = strings.Replace(bp, `tools: ["tool"],`, `tools: ["tool"], srcs: ["in1.txt"],`, 1)
+			}
+
+			config := testConfig(bp, nil)
+			ctx := testContext(config)
+			_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
+			if errs == nil {
+				_, errs = ctx.PrepareBuildActions(config)
+			}
+			android.FailIfNoMatchingErrors(t, regexp.QuoteMeta(test.err), errs)
+		})
+	}
+}
+
 func TestGenruleDefaults(t *testing.T) {
 	bp := `
 				genrule_defaults {
@@ -73,7 +73,11 @@ func aapt2Compile(ctx android.ModuleContext, dir android.Path, paths android.Pat
 	// with an individual action could take 100 CPU seconds. Sharding them reduces the overhead of
 	// starting actions by a factor of 100, at the expense of recompiling more files when one
 	// changes.  Since the individual compiles are trivial it's a good tradeoff.
-	shards := android.ShardPaths(paths, AAPT2_SHARD_SIZE)
+	//
+	// The paths are sharded per resource directory, e.g. res/drawable-hdpi, so that adding or
+	// removing a file only changes the inputs of the shards in that directory instead of shifting
+	// the boundaries of every shard after it, which would recompile most of the resources.
+	shards := shardResourcesByDir(paths, AAPT2_SHARD_SIZE)
 
 	ret := make(android.WritablePaths, 0, len(paths))
 
@@ -113,6 +117,27 @@ func aapt2Compile(ctx android.ModuleContext, dir android.Path, paths android.Pat
 	return ret
 }
 
+// shardResourcesByDir groups the resource paths by their resource directory, keeping the order
+// in which the directories first appear, and then splits each group into shards of at most
+// shardSize paths.
+func shardResourcesByDir(paths android.Paths, shardSize int) []android.Paths {
+	var dirs []string
+	byDir := make(map[string]android.Paths)
+	for _, path := range paths {
+		dir := filepath.Dir(path.String())
+		if _, exists := byDir[dir]; !exists {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], path)
+	}
+
+	var shards []android.Paths
+	for _, dir := range dirs {
+		shards = append(shards, android.ShardPaths(byDir[dir], shardSize)...)
+	}
+	return shards
+}
+
 var aapt2CompileZipRule = pctx.AndroidStaticRule("aapt2CompileZip",
 	blueprint.RuleParams{
 		Command: `${config.ZipSyncCmd} -d $resZipDir $zipSyncFlags $in && ` +
@@ -254,6 +279,37 @@ func aapt2Link(ctx android.ModuleContext,
 	})
 }
 
+var aapt2LinkFeatureSplitRule = pctx.AndroidStaticRule("aapt2LinkFeatureSplit",
+	blueprint.RuleParams{
+		Command: `${config.Aapt2Cmd} link -o $out --manifest $manifest -I $baseApk ` +
+			`--package-id $packageId $flags $inFlags`,
+		CommandDeps: []string{"${config.Aapt2Cmd}"},
+	},
+	"manifest", "baseApk", "packageId", "flags", "inFlags")
+
+// aapt2LinkFeatureSplit links the compiled resources of a feature split against the resource
+// package of the base APK. Each feature split of an app needs its own package ID, 0x80 and up, so
+// that its resource IDs don't collide with the base APK or the other feature splits.
+func aapt2LinkFeatureSplit(ctx android.ModuleContext, out android.WritablePath,
+	manifest, baseApk android.Path, packageId int, flags []string, deps android.Paths,
+	compiledRes android.Paths) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        aapt2LinkFeatureSplitRule,
+		Description: "aapt2 link feature split",
+		Inputs:      compiledRes,
+		Implicits:   append(android.Paths{manifest, baseApk}, deps...),
+		Output:      out,
+		Args: map[string]string{
+			"manifest":  manifest.String(),
+			"baseApk":   baseApk.String(),
+			"packageId": "0x" + strconv.FormatInt(int64(packageId), 16),
+			"flags":     strings.Join(flags, " "),
+			"inFlags":   strings.Join(compiledRes.Strings(), " "),
+		},
+	})
+}
+
 var aapt2ConvertRule = pctx.AndroidStaticRule("aapt2Convert",
 	blueprint.RuleParams{
 		Command:     `${config.Aapt2Cmd} convert --output-format proto $in -o $out`,
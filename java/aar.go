@@ -61,9 +61,13 @@ type androidLibraryProperties struct {
 }
 
 type aaptProperties struct {
-	// flags passed to aapt when creating the apk
+	// flags passed to aapt when creating the apk. $(location <label>) and $(locations <label>)
+	// expand to the paths of the files in aaptflags_files with the given label.
 	Aaptflags []string
 
+	// list of files or modules that can be referenced from aaptflags.
+	Aaptflags_files []string `android:"path"`
+
 	// include all resource configurations, not just the product-configured
 	// ones.
 	Aapt_include_all_resources *bool
@@ -114,8 +118,9 @@ type aapt struct {
 	LoggingParent           string
 	resourceFiles           android.Paths
 
-	splitNames []string
-	splits     []split
+	splitNames    []string
+	featureSplits []featureSplit
+	splits        []split
 
 	aaptProperties aaptProperties
 }
@@ -126,6 +131,14 @@ type split struct {
 	path   android.Path
 }
 
+// featureSplit describes a feature split APK, which is linked against the resources of the base
+// APK with its own manifest and resources.
+type featureSplit struct {
+	name         string
+	manifest     android.Path
+	resourceDirs android.Paths
+}
+
 // Propagate RRO enforcement flag to static lib dependencies transitively.
 func propagateRROEnforcementMutator(ctx android.TopDownMutatorContext) {
 	m := ctx.Module()
@@ -173,7 +186,10 @@ func (a *aapt) aapt2Flags(ctx android.ModuleContext, sdkContext sdkContext,
 	hasVersionName := android.PrefixInList(a.aaptProperties.Aaptflags, "--version-name")
 
 	// Flags specified in Android.bp
-	linkFlags = append(linkFlags, a.aaptProperties.Aaptflags...)
+	aaptflags, aaptflagsDeps := android.ExpandLocations(ctx, "aaptflags",
+		a.aaptProperties.Aaptflags, a.aaptProperties.Aaptflags_files)
+	linkFlags = append(linkFlags, aaptflags...)
+	linkDeps = append(linkDeps, aaptflagsDeps...)
 
 	linkFlags = append(linkFlags, "--no-static-lib-packages")
 
@@ -396,6 +412,21 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext sdkContext,
 	a.extraAaptPackagesFile = extraPackages
 	a.rTxt = rTxt
 	a.splits = splits
+
+	for i, fs := range a.featureSplits {
+		var compiledFeatureRes android.Paths
+		for _, dir := range fs.resourceDirs {
+			files := androidResourceGlob(ctx, dir)
+			compiledFeatureRes = append(compiledFeatureRes, aapt2Compile(ctx, dir, files, compileFlags).Paths()...)
+		}
+		path := android.PathForModuleOut(ctx, "feature_"+fs.name+".apk")
+		aapt2LinkFeatureSplit(ctx, path, fs.manifest, packageRes, 0x80+i, libFlags, libDeps, compiledFeatureRes)
+		a.splits = append(a.splits, split{
+			name:   fs.name,
+			suffix: fs.name,
+			path:   path,
+		})
+	}
 }
 
 // aaptLibs collects libraries from dependencies and sdk_version and converts them into paths
@@ -332,6 +332,9 @@ func (app *AndroidApp) AndroidMkEntries() []android.AndroidMkEntries {
 				}
 				entries.SetOptionalPath("LOCAL_SOONG_PROGUARD_DICT", app.dexer.proguardDictionary)
 				entries.SetOptionalPath("LOCAL_SOONG_PROGUARD_USAGE_ZIP", app.dexer.proguardUsageZip)
+				if app.licenseMetadataFile != nil {
+					entries.SetPath("LOCAL_SOONG_LICENSE_METADATA", app.licenseMetadataFile)
+				}
 
 				if app.Name() == "framework-res" {
 					entries.SetString("LOCAL_MODULE_PATH", "$(TARGET_OUT_JAVA_LIBRARIES)")
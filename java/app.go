@@ -47,6 +47,17 @@ func RegisterAppBuildComponents(ctx android.RegistrationContext) {
 // AndroidManifest.xml merging
 // package splits
 
+type featureSplitProperties struct {
+	// Name of the feature split. It must match the split attribute of the manifest.
+	Name *string
+
+	// AndroidManifest.xml of the feature split.
+	Manifest *string
+
+	// Resource directories of the feature split. Default is none.
+	Resource_dirs []string
+}
+
 type appProperties struct {
 	// Names of extra android_app_certificate modules to sign the apk with in the form ":module".
 	Additional_certificates []string
@@ -63,6 +74,10 @@ type appProperties struct {
 	// list of resource labels to generate individual resource packages
 	Package_splits []string
 
+	// Feature split APKs to build along with the app. Each is linked against the resources of the
+	// app with its own manifest and resources, and signed with the certificates of the app.
+	Feature_splits []featureSplitProperties
+
 	// Names of modules to be overridden. Listed modules can only be other binaries
 	// (in Make or Soong).
 	// This does not completely prevent installation of the overridden binaries, but if both
@@ -153,6 +168,9 @@ type AndroidApp struct {
 
 	bundleFile android.Path
 
+	// the signed package and feature split APKs.
+	splitApks []split
+
 	// the install APK name is normally the same as the module name, but can be overridden with PRODUCT_PACKAGE_NAME_OVERRIDES.
 	installApkName string
 
@@ -164,8 +182,15 @@ type AndroidApp struct {
 
 	noticeOutputs android.NoticeOutputs
 
+	// License metadata of the app and of the libraries packaged in it.
+	licenseMetadataFile android.Path
+
 	overriddenManifestPackageName string
 
+	// the certificate of the app instrumented by an android_test, used to sign the test when it
+	// doesn't set its own certificate.
+	instrumentationTargetCertificate *Certificate
+
 	android.ApexBundleDepsInfo
 }
 
@@ -249,6 +274,11 @@ func (a *AndroidApp) DepsMutator(ctx android.BottomUpMutatorContext) {
 	}
 
 	a.usesLibrary.deps(ctx, sdkDep.hasFrameworkLibs())
+
+	// The feature split manifests are nested in a list, which the android:"path" tag doesn't reach.
+	for _, fs := range a.appProperties.Feature_splits {
+		android.ExtractSourceDeps(ctx, fs.Manifest)
+	}
 }
 
 func (a *AndroidApp) OverridablePropertiesDepsMutator(ctx android.BottomUpMutatorContext) {
@@ -418,6 +448,7 @@ func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 	aaptLinkFlags = append(aaptLinkFlags, a.additionalAaptFlags...)
 
 	a.aapt.splitNames = a.appProperties.Package_splits
+	a.aapt.featureSplits = a.featureSplits(ctx)
 	a.aapt.LoggingParent = String(a.overridableAppProperties.Logging_parent)
 	a.aapt.buildActions(ctx, sdkContext(a), a.classLoaderContexts, aaptLinkFlags...)
 
@@ -425,6 +456,34 @@ func (a *AndroidApp) aaptBuildActions(ctx android.ModuleContext) {
 	a.properties.Manifest = nil
 }
 
+// featureSplits checks the feature_splits property and resolves the paths in it.
+func (a *AndroidApp) featureSplits(ctx android.ModuleContext) []featureSplit {
+	var ret []featureSplit
+	names := make(map[string]bool)
+	for _, props := range a.appProperties.Feature_splits {
+		name := String(props.Name)
+		if name == "" {
+			ctx.PropertyErrorf("feature_splits", "name must be set")
+			continue
+		}
+		if names[name] || android.InList(name, a.appProperties.Package_splits) {
+			ctx.PropertyErrorf("feature_splits", "duplicate split %q", name)
+			continue
+		}
+		names[name] = true
+		if props.Manifest == nil {
+			ctx.PropertyErrorf("feature_splits", "manifest of feature split %q must be set", name)
+			continue
+		}
+		ret = append(ret, featureSplit{
+			name:         name,
+			manifest:     android.PathForModuleSrc(ctx, *props.Manifest),
+			resourceDirs: android.PathsForModuleSrc(ctx, props.Resource_dirs),
+		})
+	}
+	return ret
+}
+
 func (a *AndroidApp) proguardBuildActions(ctx android.ModuleContext) {
 	var staticLibProguardFlagFiles android.Paths
 	ctx.VisitDirectDeps(func(m android.Module) {
@@ -667,7 +726,12 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 		return
 	}
 
-	certificates := processMainCert(a.ModuleBase, a.getCertString(ctx), certificateDeps, ctx)
+	var certificates []Certificate
+	if certPropValue := a.getCertString(ctx); certPropValue == "" && a.instrumentationTargetCertificate != nil {
+		certificates = append([]Certificate{*a.instrumentationTargetCertificate}, certificateDeps...)
+	} else {
+		certificates = processMainCert(a.ModuleBase, certPropValue, certificateDeps, ctx)
+	}
 	a.certificate = certificates[0]
 
 	// Build a final signed app package.
@@ -695,6 +759,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 		}
 		CreateAndSignAppPackage(ctx, packageFile, split.path, nil, nil, certificates, apkDeps, v4SignatureFile, lineageFile)
 		a.extraOutputFiles = append(a.extraOutputFiles, packageFile)
+		a.splitApks = append(a.splitApks, split{name: split.name, suffix: split.suffix, path: packageFile})
 		if v4SigningRequested {
 			a.extraOutputFiles = append(a.extraOutputFiles, v4SignatureFile)
 		}
@@ -716,6 +781,32 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	a.buildAppDependencyInfo(ctx)
+	a.licenseMetadataBuildActions(ctx)
+}
+
+// licenseMetadataBuildActions writes the licenses of the app and of the static libraries and JNI
+// libraries packaged with it.
+func (a *AndroidApp) licenseMetadataBuildActions(ctx android.ModuleContext) {
+	shouldCollectRecursiveNativeDeps := a.shouldEmbedJnis(ctx)
+
+	var contents []android.Module
+	ctx.WalkDeps(func(module android.Module, parent android.Module) bool {
+		tag := ctx.OtherModuleDependencyTag(module)
+		if tag == staticLibTag {
+			contents = append(contents, module)
+			return true
+		}
+		if IsJniDepTag(tag) || cc.IsSharedDepTag(tag) {
+			// Follow the same native dependencies as collectAppDeps.
+			if dep, ok := module.(*cc.Module); !ok || dep.IsNdk(ctx.Config()) || dep.IsStubs() {
+				return false
+			}
+			contents = append(contents, module)
+			return shouldCollectRecursiveNativeDeps
+		}
+		return false
+	})
+	a.licenseMetadataFile = android.BuildLicenseMetadata(ctx, contents)
 }
 
 type appDepsInterface interface {
@@ -854,6 +945,13 @@ func (a *AndroidApp) DepIsInSameApex(ctx android.BaseModuleContext, dep android.
 	return a.Library.DepIsInSameApex(ctx, dep)
 }
 
+// Overrides returns the names of the modules this app overrides.
+func (a *AndroidApp) Overrides() []string {
+	return a.appProperties.Overrides
+}
+
+var _ android.OverridingModule = (*AndroidApp)(nil)
+
 // For OutputFileProducer interface
 func (a *AndroidApp) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
@@ -862,6 +960,16 @@ func (a *AndroidApp) OutputFiles(tag string) (android.Paths, error) {
 	case ".export-package.apk":
 		return []android.Path{a.exportPackage}, nil
 	}
+	// The signed split APKs can be referenced with ".split-<suffix>.apk", e.g.
+	// ":foo{.split-v7_hdpi.apk}" for the package split "v7,hdpi".
+	if strings.HasPrefix(tag, ".split-") && strings.HasSuffix(tag, ".apk") {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(tag, ".split-"), ".apk")
+		for _, split := range a.splitApks {
+			if split.suffix == suffix {
+				return android.Paths{split.path}, nil
+			}
+		}
+	}
 	return a.Library.OutputFiles(tag)
 }
 
@@ -907,6 +1015,8 @@ func AndroidAppFactory() android.Module {
 
 	module.usesLibrary.enforce = true
 
+	module.Module.dexpreopter.preoptInApex = true
+
 	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
 	android.InitDefaultableModule(module)
 	android.InitOverridableModule(module, &module.appProperties.Overrides)
@@ -941,6 +1051,7 @@ func (a *AndroidTest) InstallInTestcases() bool {
 
 func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	var configs []tradefed.Config
+	target := a.instrumentationTarget(ctx)
 	if a.appTestProperties.Instrumentation_target_package != nil {
 		a.additionalAaptFlags = append(a.additionalAaptFlags,
 			"--rename-instrumentation-target-package "+*a.appTestProperties.Instrumentation_target_package)
@@ -949,10 +1060,25 @@ func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		manifestPackageName, overridden := ctx.DeviceConfig().OverrideManifestPackageNameFor(*a.appTestProperties.Instrumentation_for)
 		if overridden {
 			a.additionalAaptFlags = append(a.additionalAaptFlags, "--rename-instrumentation-target-package "+manifestPackageName)
+		} else if target != nil && target.overriddenManifestPackageName != "" {
+			// Follow the package_name of the target app so the test instruments the renamed package.
+			a.additionalAaptFlags = append(a.additionalAaptFlags, "--rename-instrumentation-target-package "+target.overriddenManifestPackageName)
 		}
 	}
+	if target != nil && target.Certificate().Pem != nil {
+		// An instrumentation test must be signed with the same certificate as the app it
+		// instruments, otherwise it fails to install.
+		targetCertificate := target.Certificate()
+		a.instrumentationTargetCertificate = &targetCertificate
+	}
 	a.generateAndroidBuildActions(ctx)
 
+	if a.instrumentationTargetCertificate != nil && a.certificate.Pem != nil &&
+		a.certificate.Pem.String() != a.instrumentationTargetCertificate.Pem.String() {
+		ctx.PropertyErrorf("certificate", "must match the certificate of the instrumentation target %q (%s), got %s",
+			ctx.OtherModuleName(target), a.instrumentationTargetCertificate.Pem, a.certificate.Pem)
+	}
+
 	for _, module := range a.testProperties.Test_mainline_modules {
 		configs = append(configs, tradefed.Option{Name: "config-descriptor:metadata", Key: "mainline-param", Value: module})
 	}
@@ -962,6 +1088,21 @@ func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	a.testConfig = a.FixTestConfig(ctx, testConfig)
 	a.extraTestConfigs = android.PathsForModuleSrc(ctx, a.testProperties.Test_options.Extra_test_configs)
 	a.data = android.PathsForModuleSrc(ctx, a.testProperties.Data)
+	if ctx.Config().TestAnnotationIndexEnabled() && a.implementationJarFile != nil {
+		a.data = append(a.data, buildTestAnnotationIndex(ctx, a.implementationJarFile))
+	}
+}
+
+// instrumentationTarget returns the android_app named by instrumentation_for, or nil if there is
+// none.
+func (a *AndroidTest) instrumentationTarget(ctx android.ModuleContext) *AndroidApp {
+	var target *AndroidApp
+	ctx.VisitDirectDepsWithTag(instrumentationForTag, func(m android.Module) {
+		if app, ok := m.(*AndroidApp); ok {
+			target = app
+		}
+	})
+	return target
 }
 
 func (a *AndroidTest) FixTestConfig(ctx android.ModuleContext, testConfig android.Path) android.Path {
@@ -86,14 +86,17 @@ func TestApp(t *testing.T) {
 			expectedLinkImplicits = append(expectedLinkImplicits,
 				frameworkRes.Output("package-res.apk").Output.String())
 
-			// Test the mapping from input files to compiled output file names
-			compile := foo.Output(compiledResourceFiles[0])
-			if !reflect.DeepEqual(resourceFiles, compile.Inputs.Strings()) {
-				t.Errorf("expected aapt2 compile inputs expected:\n  %#v\n got:\n  %#v",
-					resourceFiles, compile.Inputs.Strings())
+			// Test the mapping from input files to compiled output file names.  Each resource
+			// directory is compiled by a separate rule.
+			var compiledResourceOutputs []string
+			for i, compiled := range compiledResourceFiles {
+				compile := foo.Output(compiled)
+				if !reflect.DeepEqual([]string{resourceFiles[i]}, compile.Inputs.Strings()) {
+					t.Errorf("expected aapt2 compile inputs expected:\n  %#v\n got:\n  %#v",
+						[]string{resourceFiles[i]}, compile.Inputs.Strings())
+				}
+				compiledResourceOutputs = append(compiledResourceOutputs, compile.Outputs.Strings()...)
 			}
-
-			compiledResourceOutputs := compile.Outputs.Strings()
 			sort.Strings(compiledResourceOutputs)
 
 			expectedLinkImplicits = append(expectedLinkImplicits, compiledResourceOutputs...)
@@ -111,6 +114,31 @@ func TestApp(t *testing.T) {
 	}
 }
 
+func TestShardResourcesByDir(t *testing.T) {
+	paths := android.PathsForTesting(
+		"res/drawable/a.png",
+		"res/drawable/b.png",
+		"res/drawable/c.png",
+		"res/values/strings.xml",
+		"res/drawable-hdpi/a.png",
+	)
+
+	expected := [][]string{
+		{"res/drawable/a.png", "res/drawable/b.png"},
+		{"res/drawable/c.png"},
+		{"res/values/strings.xml"},
+		{"res/drawable-hdpi/a.png"},
+	}
+
+	var got [][]string
+	for _, shard := range shardResourcesByDir(paths, 2) {
+		got = append(got, shard.Strings())
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected shards %q, got %q", expected, got)
+	}
+}
+
 func TestAppSplits(t *testing.T) {
 	ctx := testApp(t, `
 				android_app {
@@ -140,6 +168,96 @@ func TestAppSplits(t *testing.T) {
 	}
 }
 
+func TestAppFeatureSplits(t *testing.T) {
+	config := testAppConfig(nil, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			package_splits: ["v7,hdpi"],
+			feature_splits: [
+				{
+					name: "feature1",
+					manifest: "feature1/AndroidManifest.xml",
+					resource_dirs: ["feature1/res"],
+				},
+				{
+					name: "feature2",
+					manifest: "feature2/AndroidManifest.xml",
+				},
+			],
+			sdk_version: "current",
+		}`, map[string][]byte{
+		"feature1/AndroidManifest.xml":    nil,
+		"feature1/res/values/strings.xml": nil,
+		"feature2/AndroidManifest.xml":    nil,
+	})
+	ctx := testContext(config)
+	run(t, ctx, config)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	baseRes := foo.Output("package-res.apk").Output.String()
+
+	feature1 := foo.Output("feature_feature1.apk")
+	if g, w := feature1.Args["manifest"], "feature1/AndroidManifest.xml"; g != w {
+		t.Errorf("want feature1 manifest %q, got %q", w, g)
+	}
+	if g, w := feature1.Args["baseApk"], baseRes; g != w {
+		t.Errorf("want feature1 to be linked against %q, got %q", w, g)
+	}
+	if g, w := feature1.Args["packageId"], "0x80"; g != w {
+		t.Errorf("want feature1 package id %q, got %q", w, g)
+	}
+	if g, w := feature1.Inputs.Strings(), []string{
+		filepath.Join(buildDir, ".intermediates/foo/android_common/aapt2/feature1/res/values_strings.arsc.flat"),
+	}; !reflect.DeepEqual(g, w) {
+		t.Errorf("want feature1 inputs %q, got %q", w, g)
+	}
+
+	feature2 := foo.Output("feature_feature2.apk")
+	if g, w := feature2.Args["packageId"], "0x81"; g != w {
+		t.Errorf("want feature2 package id %q, got %q", w, g)
+	}
+
+	for tag, w := range map[string]string{
+		".split-v7_hdpi.apk":  "foo_v7_hdpi.apk",
+		".split-feature1.apk": "foo_feature1.apk",
+		".split-feature2.apk": "foo_feature2.apk",
+	} {
+		outputFiles, err := foo.Module().(*AndroidApp).OutputFiles(tag)
+		if err != nil {
+			t.Errorf("OutputFiles(%q): %s", tag, err)
+			continue
+		}
+		if len(outputFiles) != 1 || outputFiles[0].Base() != w {
+			t.Errorf("want OutputFiles(%q) = [%q], got %q", tag, w, outputFiles)
+		}
+	}
+
+	// The signed feature splits are installed with the app.
+	foo.Output(filepath.Join(buildDir, ".intermediates/foo/android_common/foo_feature1.apk"))
+}
+
+func TestAppFeatureSplitsErrors(t *testing.T) {
+	testJavaError(t, `manifest of feature split "feature1" must be set`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			feature_splits: [{name: "feature1"}],
+			sdk_version: "current",
+		}
+	`)
+
+	testJavaError(t, `duplicate split "v4"`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			package_splits: ["v4"],
+			feature_splits: [{name: "v4", manifest: "AndroidManifest.xml"}],
+			sdk_version: "current",
+		}
+	`)
+}
+
 func TestPlatformAPIs(t *testing.T) {
 	testJava(t, `
 		android_app {
@@ -584,6 +702,35 @@ func TestResourceDirs(t *testing.T) {
 	}
 }
 
+func TestAaptflagsLocations(t *testing.T) {
+	ctx, _ := testJavaWithFS(t, `
+		android_app {
+			name: "foo",
+			sdk_version: "current",
+			aaptflags: ["--emit-ids $(location ids.txt)"],
+			aaptflags_files: ["ids.txt"],
+		}
+	`, map[string][]byte{
+		"ids.txt": nil,
+	})
+
+	link := ctx.ModuleForTests("foo", "android_common").Output("package-res.apk")
+	if g, w := link.Args["flags"], "--emit-ids ids.txt"; !strings.Contains(g, w) {
+		t.Errorf("expected aapt2 link flags to contain %q, got %q", w, g)
+	}
+	if g, w := link.Implicits.Strings(), "ids.txt"; !android.InList(w, g) {
+		t.Errorf("expected aapt2 link implicits to contain %q, got %q", w, g)
+	}
+
+	testJavaError(t, `unknown location label "res.txt"`, `
+		android_app {
+			name: "foo",
+			sdk_version: "current",
+			aaptflags: ["--emit-ids $(location res.txt)"],
+		}
+	`)
+}
+
 func TestLibraryAssets(t *testing.T) {
 	bp := `
 			android_app {
@@ -1712,6 +1859,94 @@ func TestInstrumentationTargetOverridden(t *testing.T) {
 	}
 }
 
+func TestInstrumentationTargetPackageName(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			package_name: "com.android.foo",
+			sdk_version: "current",
+		}
+
+		android_test {
+			name: "bar",
+			instrumentation_for: "foo",
+			sdk_version: "current",
+		}
+
+		android_test {
+			name: "baz",
+			instrumentation_for: "foo",
+			instrumentation_target_package: "com.android.baz",
+			sdk_version: "current",
+		}
+		`)
+
+	aapt2Flags := ctx.ModuleForTests("bar", "android_common").Output("package-res.apk").Args["flags"]
+	checkAapt2LinkFlag(t, aapt2Flags, "rename-instrumentation-target-package", "com.android.foo")
+
+	aapt2Flags = ctx.ModuleForTests("baz", "android_common").Output("package-res.apk").Args["flags"]
+	checkAapt2LinkFlag(t, aapt2Flags, "rename-instrumentation-target-package", "com.android.baz")
+}
+
+func TestInstrumentationTargetCertificate(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			certificate: ":new_certificate",
+			sdk_version: "current",
+		}
+
+		android_app_certificate {
+			name: "new_certificate",
+			certificate: "cert/new_cert",
+		}
+
+		android_test {
+			name: "bar",
+			instrumentation_for: "foo",
+			sdk_version: "current",
+		}
+
+		android_test {
+			name: "baz",
+			instrumentation_for: "foo",
+			certificate: ":new_certificate",
+			sdk_version: "current",
+		}
+		`)
+
+	expected := "cert/new_cert.x509.pem cert/new_cert.pk8"
+	for _, name := range []string{"bar", "baz"} {
+		signapk := ctx.ModuleForTests(name, "android_common").Output(name + ".apk")
+		if got := signapk.Args["certificates"]; got != expected {
+			t.Errorf("%s: incorrect signing flags, expected: %q, got: %q", name, expected, got)
+		}
+	}
+
+	testJavaError(t, `module "bar".*certificate: must match the certificate of the instrumentation target "foo"`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			certificate: ":new_certificate",
+			sdk_version: "current",
+		}
+
+		android_app_certificate {
+			name: "new_certificate",
+			certificate: "cert/new_cert",
+		}
+
+		android_test {
+			name: "bar",
+			instrumentation_for: "foo",
+			certificate: "platform",
+			sdk_version: "current",
+		}
+		`)
+}
+
 func TestOverrideAndroidApp(t *testing.T) {
 	ctx, _ := testJava(t, `
 		android_app {
@@ -2000,7 +2235,7 @@ func TestOverrideAndroidTest(t *testing.T) {
 			overrides:         nil,
 			targetVariant:     "android_common",
 			packageFlag:       "",
-			targetPackageFlag: "",
+			targetPackageFlag: "com.android.foo",
 		},
 		{
 			variantName:       "android_common_bar_test",
@@ -2303,6 +2538,17 @@ func TestUsesLibraries(t *testing.T) {
 		t.Errorf("wanted %q in %q", w, cmd)
 	}
 
+	// Test that the libraries derived from dependencies on java_sdk_library instances are verified
+	// against the manifest too, but the SDK library of a statically linked component isn't.
+	for _, w := range []string{"qux", "quuz", "runtime-library"} {
+		if !strings.Contains(cmd, "--uses-library "+w+" ") {
+			t.Errorf("wanted %q in %q", "--uses-library "+w, cmd)
+		}
+	}
+	if w := "--uses-library fred "; strings.Contains(cmd, w) {
+		t.Errorf("unexpected %q in %q", w, cmd)
+	}
+
 	cmd = prebuilt.Rule("verify_uses_libraries").RuleParams.Command
 
 	if w := `uses_library_names="foo android.test.runner"`; !strings.Contains(cmd, w) {
@@ -2704,3 +2950,104 @@ func TestExportedProguardFlagFiles(t *testing.T) {
 		t.Errorf("App does not use library proguard config")
 	}
 }
+
+func TestKeepAnnotations(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			optimize: {
+				keep_annotations: ["androidx.annotation.Keep"],
+			},
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	keepFlags := foo.Output("proguard/keep_annotations.flags")
+	r8 := foo.Rule("java.r8")
+	if !strings.Contains(r8.Args["r8Flags"], "-include "+keepFlags.Output.String()) {
+		t.Errorf("expected r8 to include %q, got %q", keepFlags.Output.String(), r8.Args["r8Flags"])
+	}
+	if !android.InList(keepFlags.Output.String(), r8.Implicits.Strings()) {
+		t.Errorf("expected r8 to depend on %q, got %q", keepFlags.Output.String(), r8.Implicits.Strings())
+	}
+
+	content := android.ContentFromFileRuleForTests(t, keepFlags)
+	for _, rule := range []string{
+		"-keep @androidx.annotation.Keep class * { *; }",
+		"-keepclasseswithmembers class * { @androidx.annotation.Keep <methods>; }",
+		"-keepclasseswithmembers class * { @androidx.annotation.Keep <fields>; }",
+		"-keepclasseswithmembers class * { @androidx.annotation.Keep <init>(...); }",
+	} {
+		if !strings.Contains(content, rule) {
+			t.Errorf("expected keep rule %q, got %q", rule, content)
+		}
+	}
+
+	outputs, err := foo.Module().(*AndroidApp).OutputFiles(".keep_annotations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || outputs[0] != keepFlags.Output {
+		t.Errorf("expected .keep_annotations output %q, got %q", keepFlags.Output, outputs)
+	}
+
+	bar := ctx.ModuleForTests("bar", "android_common")
+	if out := bar.MaybeOutput("proguard/keep_annotations.flags"); out.Rule != nil {
+		t.Errorf("expected no keep annotation flags for bar")
+	}
+}
+
+func TestCoreLibraryDesugaring(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			min_sdk_version: "21",
+			core_library_desugaring: true,
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			min_sdk_version: "21",
+			core_library_desugaring: true,
+			optimize: {
+				enabled: false,
+			},
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	r8 := foo.Rule("java.r8")
+	if !strings.Contains(r8.Args["r8Flags"], "--desugared-lib ") {
+		t.Errorf("expected --desugared-lib in r8 flags, got %q", r8.Args["r8Flags"])
+	}
+	keepRules := foo.Output("desugar/keep_rules.txt")
+	l8 := foo.Rule("java.l8")
+	if !strings.Contains(l8.Args["l8Flags"], "--pg-conf "+keepRules.Output.String()) {
+		t.Errorf("expected l8 to use the keep rules generated by r8, got %q", l8.Args["l8Flags"])
+	}
+	if !strings.Contains(l8.Args["l8Flags"], "--min-api 21") {
+		t.Errorf("expected l8 to use min_sdk_version, got %q", l8.Args["l8Flags"])
+	}
+	foo.Output("desugar/foo.jar")
+
+	bar := ctx.ModuleForTests("bar", "android_common")
+	d8 := bar.Rule("java.d8")
+	if !strings.Contains(d8.Args["d8Flags"], "--desugared-lib ") {
+		t.Errorf("expected --desugared-lib in d8 flags, got %q", d8.Args["d8Flags"])
+	}
+	if l8Flags := bar.Rule("java.l8").Args["l8Flags"]; strings.Contains(l8Flags, "--pg-conf") {
+		t.Errorf("expected l8 not to shrink without r8, got %q", l8Flags)
+	}
+}
@@ -113,6 +113,34 @@ var (
 		"javacFlags", "bootClasspath", "classpath", "processorpath", "processor", "srcJars", "srcJarDir",
 		"outDir", "annoDir", "javaVersion")
 
+	// errorprone compiles java sources like javac, but also writes the diagnostics to $findings so
+	// that they can be collected into the code health report.
+	errorprone = pctx.AndroidStaticRule("errorprone",
+		blueprint.RuleParams{
+			Command: `rm -rf "$outDir" "$annoDir" "$srcJarDir" "$out" "$findings" && mkdir -p "$outDir" "$annoDir" "$srcJarDir" && ` +
+				`touch "$findings" && ` +
+				`${config.ZipSyncCmd} -d $srcJarDir -l $srcJarDir/list -f "*.java" $srcJars && ` +
+				`(if [ -s $srcJarDir/list ] || [ -s $out.rsp ] ; then ` +
+				`${config.SoongJavacWrapper} ${config.JavacCmd} ` +
+				`${config.JavacHeapFlags} ${config.JavacVmFlags} ${config.CommonJdkFlags} ` +
+				`$processorpath $processor $javacFlags $bootClasspath $classpath ` +
+				`-source $javaVersion -target $javaVersion -Xstdout "$findings" ` +
+				`-d $outDir -s $annoDir @$out.rsp @$srcJarDir/list ; ` +
+				`ret=$$? ; cat "$findings" >&2 ; exit $$ret ; fi ) && ` +
+				`${config.SoongZipCmd} -jar -o $out -C $outDir -D $outDir && ` +
+				`rm -rf "$srcJarDir"`,
+			CommandDeps: []string{
+				"${config.JavacCmd}",
+				"${config.SoongZipCmd}",
+				"${config.ZipSyncCmd}",
+			},
+			CommandOrderOnly: []string{"${config.SoongJavacWrapper}"},
+			Rspfile:          "$out.rsp",
+			RspfileContent:   "$in",
+		},
+		"javacFlags", "bootClasspath", "classpath", "processorpath", "processor", "srcJars", "srcJarDir",
+		"outDir", "annoDir", "javaVersion", "findings")
+
 	extractMatchingApks = pctx.StaticRule(
 		"extractMatchingApks",
 		blueprint.RuleParams{
@@ -229,6 +257,13 @@ var (
 			CommandDeps: []string{"${config.ZipAlign}"},
 		},
 	)
+
+	testAnnotationIndex = pctx.AndroidStaticRule("testAnnotationIndex",
+		blueprint.RuleParams{
+			Command:     "${config.TestAnnotationIndexCmd} --jar $in --out $out",
+			CommandDeps: []string{"${config.TestAnnotationIndexCmd}"},
+		},
+	)
 )
 
 func init() {
@@ -249,6 +284,12 @@ type javaBuilderFlags struct {
 	aidlDeps       android.Paths
 	javaVersion    javaVersion
 
+	// The locations that the imports of aidl sources may resolve to when aidl.strict_imports is
+	// set, besides the sources themselves.
+	aidlStrictImports    bool
+	aidlStrictImportDirs android.Paths
+	aidlPreprocess       android.OptionalPath
+
 	errorProneExtraJavacFlags string
 	errorProneProcessorPath   classpath
 
@@ -267,10 +308,12 @@ func TransformJavaToClasses(ctx android.ModuleContext, outputFile android.Writab
 		desc += strconv.Itoa(shardIdx)
 	}
 
-	transformJavaToClasses(ctx, outputFile, shardIdx, srcFiles, srcJars, flags, deps, "javac", desc)
+	transformJavaToClasses(ctx, outputFile, nil, shardIdx, srcFiles, srcJars, flags, deps, "javac", desc)
 }
 
-func RunErrorProne(ctx android.ModuleContext, outputFile android.WritablePath,
+// RunErrorProne compiles the java sources with error-prone into outputFile, and writes the
+// findings to findingsFile.
+func RunErrorProne(ctx android.ModuleContext, outputFile, findingsFile android.WritablePath,
 	srcFiles, srcJars android.Paths, flags javaBuilderFlags) {
 
 	flags.processorPath = append(flags.errorProneProcessorPath, flags.processorPath...)
@@ -283,7 +326,7 @@ func RunErrorProne(ctx android.ModuleContext, outputFile android.WritablePath,
 		}
 	}
 
-	transformJavaToClasses(ctx, outputFile, -1, srcFiles, srcJars, flags, nil,
+	transformJavaToClasses(ctx, outputFile, findingsFile, -1, srcFiles, srcJars, flags, nil,
 		"errorprone", "errorprone")
 }
 
@@ -407,8 +450,9 @@ func TransformJavaToHeaderClasses(ctx android.ModuleContext, outputFile android.
 // argument specifies which command line to use and desc sets the description of the rule that will
 // be printed at build time.  The stem argument provides the file name of the output jar, and
 // suffix will be appended to various intermediate files and directories to avoid collisions when
-// this function is called twice in the same module directory.
-func transformJavaToClasses(ctx android.ModuleContext, outputFile android.WritablePath,
+// this function is called twice in the same module directory.  If findingsFile is not nil the
+// diagnostics of the compiler are also written to it.
+func transformJavaToClasses(ctx android.ModuleContext, outputFile, findingsFile android.WritablePath,
 	shardIdx int, srcFiles, srcJars android.Paths,
 	flags javaBuilderFlags, deps android.Paths,
 	intermediatesDir, desc string) {
@@ -455,24 +499,33 @@ func transformJavaToClasses(ctx android.ModuleContext, outputFile android.Writab
 	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_JAVAC") {
 		rule = javacRE
 	}
+	args := map[string]string{
+		"javacFlags":    flags.javacFlags,
+		"bootClasspath": bootClasspath,
+		"classpath":     classpath.FormJavaClassPath("-classpath"),
+		"processorpath": flags.processorPath.FormJavaClassPath("-processorpath"),
+		"processor":     processor,
+		"srcJars":       strings.Join(srcJars.Strings(), " "),
+		"srcJarDir":     android.PathForModuleOut(ctx, intermediatesDir, srcJarDir).String(),
+		"outDir":        android.PathForModuleOut(ctx, intermediatesDir, outDir).String(),
+		"annoDir":       android.PathForModuleOut(ctx, intermediatesDir, annoDir).String(),
+		"javaVersion":   flags.javaVersion.String(),
+	}
+	var implicitOutputs android.WritablePaths
+	if findingsFile != nil {
+		// javac writes the findings itself, so error-prone always runs locally.
+		rule = errorprone
+		args["findings"] = findingsFile.String()
+		implicitOutputs = append(implicitOutputs, findingsFile)
+	}
 	ctx.Build(pctx, android.BuildParams{
-		Rule:        rule,
-		Description: desc,
-		Output:      outputFile,
-		Inputs:      srcFiles,
-		Implicits:   deps,
-		Args: map[string]string{
-			"javacFlags":    flags.javacFlags,
-			"bootClasspath": bootClasspath,
-			"classpath":     classpath.FormJavaClassPath("-classpath"),
-			"processorpath": flags.processorPath.FormJavaClassPath("-processorpath"),
-			"processor":     processor,
-			"srcJars":       strings.Join(srcJars.Strings(), " "),
-			"srcJarDir":     android.PathForModuleOut(ctx, intermediatesDir, srcJarDir).String(),
-			"outDir":        android.PathForModuleOut(ctx, intermediatesDir, outDir).String(),
-			"annoDir":       android.PathForModuleOut(ctx, intermediatesDir, annoDir).String(),
-			"javaVersion":   flags.javaVersion.String(),
-		},
+		Rule:            rule,
+		Description:     desc,
+		Output:          outputFile,
+		ImplicitOutputs: implicitOutputs,
+		Inputs:          srcFiles,
+		Implicits:       deps,
+		Args:            args,
 	})
 }
 
@@ -584,6 +637,17 @@ func TransformZipAlign(ctx android.ModuleContext, outputFile android.WritablePat
 	})
 }
 
+// TransformJarToTestAnnotationIndex generates a JSON index of the test classes and methods in a
+// jar that are annotated with a test filtering annotation (flaky, presubmit or postsubmit).
+func TransformJarToTestAnnotationIndex(ctx android.ModuleContext, outputFile android.WritablePath, inputFile android.Path) {
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        testAnnotationIndex,
+		Description: "test annotation index",
+		Input:       inputFile,
+		Output:      outputFile,
+	})
+}
+
 type classpath android.Paths
 
 func (x *classpath) formJoinedClassPath(optName string, sep string) string {
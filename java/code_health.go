@@ -0,0 +1,123 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+func init() {
+	android.RegisterSingletonType("code_health_report", codeHealthReportSingletonFactory)
+	pctx.HostBinToolVariable("genCodeHealthReportCmd", "gen_code_health_report")
+}
+
+var codeHealthReportRule = pctx.AndroidStaticRule("codeHealthReport", blueprint.RuleParams{
+	Command:     `rm -f $out && ${genCodeHealthReportCmd} -o $out $in`,
+	CommandDeps: []string{"${genCodeHealthReportCmd}"},
+	Description: "code health report",
+})
+
+// errorproneFindingsIntf is implemented by modules that compile java sources, and returns the
+// diagnostics reported by error-prone, or nil if it is not enabled.
+type errorproneFindingsIntf interface {
+	errorproneFindings() android.Path
+}
+
+var _ errorproneFindingsIntf = (*Module)(nil)
+
+// codeHealthReportSingleton aggregates the lint and error-prone findings of all modules into a
+// single JSON report for dashboard ingestion.  The findings of each module are attributed to the
+// owner of the module.
+type codeHealthReportSingleton struct {
+	output android.OutputPath
+}
+
+func codeHealthReportSingletonFactory() android.Singleton {
+	return &codeHealthReportSingleton{}
+}
+
+func (s *codeHealthReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if ctx.Config().UnbundledBuild() {
+		return
+	}
+
+	var reports android.Paths
+	var lines []string
+	seen := make(map[string]bool)
+	ctx.VisitAllModules(func(m android.Module) {
+		var lintXML, findings android.Path
+		if l, ok := m.(lintOutputsIntf); ok {
+			lintXML = l.lintOutputs().xml
+		}
+		if e, ok := m.(errorproneFindingsIntf); ok {
+			findings = e.errorproneFindings()
+		}
+		if lintXML == nil && findings == nil {
+			return
+		}
+		if apex, ok := m.(android.ApexModule); ok && apex.NotAvailableForPlatform() {
+			apexInfo := ctx.ModuleProvider(m, android.ApexInfoProvider).(android.ApexInfo)
+			if apexInfo.IsForPlatform() {
+				// Stray platform variants of modules in apexes are not built, see
+				// lintSingleton.generateLintReportZips.
+				return
+			}
+		}
+
+		// Variants of a module share the same sources, only report the findings of the first one.
+		name := ctx.ModuleName(m)
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+
+		// An empty field means that the tool didn't run on the module.
+		fields := []string{name, m.Owner(), ctx.ModuleDir(m), "", ""}
+		if lintXML != nil {
+			reports = append(reports, lintXML)
+			fields[3] = lintXML.String()
+		}
+		if findings != nil {
+			reports = append(reports, findings)
+			fields[4] = findings.String()
+		}
+		lines = append(lines, strings.Join(fields, "\t"))
+	})
+
+	reportList := android.PathForOutput(ctx, "code_health", "lint-reports.txt")
+	android.WriteFileRule(ctx, reportList, strings.Join(lines, "\n"))
+
+	s.output = android.PathForOutput(ctx, "code_health", "code-health-report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:      codeHealthReportRule,
+		Input:     reportList,
+		Implicits: reports,
+		Output:    s.output,
+	})
+
+	ctx.Phony("code-health-report", s.output)
+}
+
+func (s *codeHealthReportSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if !ctx.Config().UnbundledBuild() {
+		ctx.DistForGoal("code-health-report", s.output)
+	}
+}
+
+var _ android.SingletonMakeVarsProvider = (*codeHealthReportSingleton)(nil)
@@ -126,6 +126,7 @@ func init() {
 	pctx.HostBinToolVariable("ApiCheckCmd", "apicheck")
 	pctx.HostBinToolVariable("D8Cmd", "d8")
 	pctx.HostBinToolVariable("R8Cmd", "r8-compat-proguard")
+	pctx.HostBinToolVariable("L8Cmd", "l8")
 	pctx.HostBinToolVariable("HiddenAPICmd", "hiddenapi")
 	pctx.HostBinToolVariable("ExtractApksCmd", "extract_apks")
 	pctx.VariableFunc("TurbineJar", func(ctx android.PackageVarContext) string {
@@ -146,6 +147,9 @@ func init() {
 	pctx.HostJavaToolVariable("R8Jar", "r8-compat-proguard.jar")
 	pctx.HostJavaToolVariable("D8Jar", "d8.jar")
 
+	pctx.SourcePathVariable("CoreLibDesugaringConfig", "prebuilts/r8/desugar_jdk_libs/desugar_jdk_libs.json")
+	pctx.SourcePathVariable("CoreLibDesugaringJar", "prebuilts/r8/desugar_jdk_libs/desugar_jdk_libs.jar")
+
 	pctx.HostBinToolVariable("SoongJavacWrapper", "soong_javac_wrapper")
 	pctx.HostBinToolVariable("DexpreoptGen", "dexpreopt_gen")
 
@@ -166,6 +170,7 @@ func init() {
 	pctx.HostBinToolVariable("ManifestMergerCmd", "manifest-merger")
 
 	pctx.HostBinToolVariable("Class2NonSdkList", "class2nonsdklist")
+	pctx.HostBinToolVariable("TestAnnotationIndexCmd", "test_annotation_index")
 	pctx.HostBinToolVariable("HiddenAPI", "hiddenapi")
 
 	hostBinToolVariableWithSdkToolsPrebuilt("Aapt2Cmd", "aapt2")
@@ -0,0 +1,116 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+func init() {
+	android.RegisterSingletonType("dead_code_report", deadCodeReportSingletonFactory)
+	pctx.HostBinToolVariable("genDeadCodeReportCmd", "gen_dead_code_report")
+}
+
+var (
+	mergeProguardUsageRule = pctx.AndroidStaticRule("mergeProguardUsage", blueprint.RuleParams{
+		Command:     `${config.MergeZipsCmd} --ignore-duplicates $out $in`,
+		CommandDeps: []string{"${config.MergeZipsCmd}"},
+		Description: "merge proguard usage",
+	})
+
+	deadCodeReportRule = pctx.AndroidStaticRule("deadCodeReport", blueprint.RuleParams{
+		Command:     `rm -f $out && ${genDeadCodeReportCmd} -o $out $in`,
+		CommandDeps: []string{"${genDeadCodeReportCmd}"},
+		Description: "dead code report",
+	})
+)
+
+type proguardUsageIntf interface {
+	proguardUsage() android.OptionalPath
+}
+
+func (d *dexer) proguardUsage() android.OptionalPath {
+	return d.proguardUsageZip
+}
+
+// deadCodeReportSingleton merges the code that R8 found unused in every optimized module of the
+// system image into a single proguard_usage.zip, and summarizes it per module in a dead code report
+// for size reduction analysis.
+type deadCodeReportSingleton struct {
+	usageZip android.OutputPath
+	report   android.OutputPath
+}
+
+func deadCodeReportSingletonFactory() android.Singleton {
+	return &deadCodeReportSingleton{}
+}
+
+func (s *deadCodeReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if ctx.Config().UnbundledBuild() {
+		return
+	}
+
+	var usageZips android.Paths
+	ctx.VisitAllModules(func(m android.Module) {
+		if !m.Enabled() || m.Target().Os.Class != android.Device {
+			return
+		}
+		if ctx.Config().KatiEnabled() && !m.ExportedToMake() {
+			return
+		}
+		if apex, ok := m.(android.ApexModule); ok {
+			// Variants of modules in apexes are not part of the system image, and neither are the
+			// stray platform variants of modules that are not available to the platform.
+			apexInfo := ctx.ModuleProvider(m, android.ApexInfoProvider).(android.ApexInfo)
+			if !apexInfo.IsForPlatform() || apex.NotAvailableForPlatform() {
+				return
+			}
+		}
+
+		if u, ok := m.(proguardUsageIntf); ok && u.proguardUsage().Valid() {
+			usageZips = append(usageZips, u.proguardUsage().Path())
+		}
+	})
+
+	// The usage of each module is stored under its own directory in its zip, which attributes the
+	// unused code to its module in the merged zip.
+	s.usageZip = android.PathForOutput(ctx, "dead_code", "proguard_usage.zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   mergeProguardUsageRule,
+		Inputs: android.SortedUniquePaths(usageZips),
+		Output: s.usageZip,
+	})
+
+	s.report = android.PathForOutput(ctx, "dead_code", "dead-code-report.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   deadCodeReportRule,
+		Input:  s.usageZip,
+		Output: s.report,
+	})
+
+	ctx.Phony("dead-code-report", s.usageZip, s.report)
+}
+
+func (s *deadCodeReportSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if !ctx.Config().UnbundledBuild() {
+		// Make already dists a proguard_usage.zip of all modules, use a distinct name.
+		ctx.DistForGoalWithFilename("dead-code-report", s.usageZip, "system_proguard_usage.zip")
+		ctx.DistForGoal("dead-code-report", s.report)
+	}
+}
+
+var _ android.SingletonMakeVarsProvider = (*deadCodeReportSingleton)(nil)
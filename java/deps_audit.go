@@ -0,0 +1,146 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// This file implements the java half of the dependency audit mode enabled by setting
+// SOONG_DEPS_AUDIT=true, see cc/deps_audit.go for the cc half.  It compares the libraries declared
+// by each java module with the classes that its compiled classes actually reference.  Each
+// referenced class is attributed to the first classpath jar that contains it, so the comparison
+// happens at build time.  Each module reports the declared libraries that provided no referenced
+// class, and the classpath jars that provided referenced classes without belonging to a declared
+// library, e.g. because they are static libraries of another dependency.  The reports of all
+// modules are collected in $OUT/soong/java_deps_audit.txt by the `java_deps_audit` phony target.
+
+func init() {
+	android.RegisterSingletonType("java_deps_audit", javaDepsAuditSingletonFactory)
+
+	pctx.HostBinToolVariable("javaDepsAuditCmd", "java_deps_audit")
+}
+
+// Rule to compare the classes used by a module with its declared dependencies.
+var javaDepsAudit = pctx.AndroidStaticRule("javaDepsAudit",
+	blueprint.RuleParams{
+		Command:     "$javaDepsAuditCmd --module $module --declared $declared --classpath $classpath -o $out $in",
+		CommandDeps: []string{"$javaDepsAuditCmd"},
+	},
+	"module", "declared", "classpath")
+
+// depsAuditBuildActions registers the build statement comparing the classes referenced by classJars,
+// the classes compiled from the sources of the module, with the header jars of its direct library
+// dependencies, and returns the path to the resulting report.
+func (j *Module) depsAuditBuildActions(ctx android.ModuleContext, classJars, classpath android.Paths) android.Path {
+	var declared strings.Builder
+	writeDeclared := func(kind, name string, jars android.Paths) {
+		fields := []string{kind, name}
+		fields = append(fields, jars.Strings()...)
+		declared.WriteString(strings.Join(fields, " "))
+		declared.WriteString("\n")
+	}
+
+	// The libraries implied by sdk_version are declared, but not reported when they are unused.
+	var sdkDep sdkDep
+	if ctx.Device() {
+		sdkDep = decodeSdkDep(ctx, sdkContext(j))
+		if sdkDep.useFiles {
+			writeDeclared("sdk", j.sdkVersion().raw, sdkDep.jars)
+		}
+	}
+
+	ctx.VisitDirectDeps(func(module android.Module) {
+		tag := ctx.OtherModuleDependencyTag(module)
+		var kind string
+		switch tag {
+		case libTag:
+			kind = "libs"
+			if android.InList(ctx.OtherModuleName(module), sdkDep.classpath) {
+				kind = "sdk"
+			}
+		case staticLibTag:
+			kind = "static_libs"
+		default:
+			return
+		}
+
+		var jars android.Paths
+		switch dep := module.(type) {
+		case SdkLibraryDependency:
+			jars = dep.SdkHeaderJars(ctx, j.sdkVersion())
+		case Dependency:
+			jars = dep.HeaderJars()
+		case android.SourceFileProducer:
+			jars = dep.Srcs()
+		}
+		if len(jars) > 0 {
+			writeDeclared(kind, ctx.OtherModuleName(module), jars)
+		}
+	})
+
+	declaredFile := android.PathForModuleOut(ctx, "deps_audit", "declared.txt")
+	android.WriteFileRule(ctx, declaredFile, declared.String())
+
+	classpathFile := android.PathForModuleOut(ctx, "deps_audit", "classpath.txt")
+	android.WriteFileRule(ctx, classpathFile, strings.Join(classpath.Strings(), "\n"))
+
+	report := android.PathForModuleOut(ctx, "deps_audit", "report.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        javaDepsAudit,
+		Description: "audit java dependencies",
+		Output:      report,
+		Inputs:      classJars,
+		Implicits:   append(android.Paths{declaredFile, classpathFile}, classpath...),
+		Args: map[string]string{
+			"module":    ctx.ModuleName() + ":" + ctx.ModuleSubDir(),
+			"declared":  declaredFile.String(),
+			"classpath": classpathFile.String(),
+		},
+	})
+	return report
+}
+
+func javaDepsAuditSingletonFactory() android.Singleton {
+	return &javaDepsAuditSingleton{}
+}
+
+type javaDepsAuditSingleton struct{}
+
+func (s *javaDepsAuditSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !ctx.Config().DepsAuditEnabled() {
+		return
+	}
+
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if m, ok := module.(*Module); ok && m.depsAuditReport.Valid() {
+			reports = append(reports, m.depsAuditReport.Path())
+		}
+	})
+
+	output := android.PathForOutput(ctx, "java_deps_audit.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        android.Cat,
+		Description: "java dependency audit report",
+		Output:      output,
+		Inputs:      reports,
+	})
+	ctx.Phony("java_deps_audit", output)
+}
@@ -15,6 +15,7 @@
 package java
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/google/blueprint"
@@ -61,8 +62,18 @@ type DexProperties struct {
 
 		// Specifies the locations of files containing proguard flags.
 		Proguard_flags_files []string `android:"path"`
+
+		// List of fully qualified annotation class names, for example androidx.annotation.Keep.
+		// Classes, methods, fields and constructors annotated with any of them are kept by R8.
+		// The generated keep rules are written to proguard/keep_annotations.flags.
+		Keep_annotations []string
 	}
 
+	// If true, rewrite references to java.* APIs that are not available at min_sdk_version to
+	// the desugared core library, and package the dexed desugared core library with the module.
+	// Only useful for unbundled apps with a low min_sdk_version.  Defaults to false.
+	Core_library_desugaring *bool
+
 	// Keep the data uncompressed. We always need uncompressed dex for execution,
 	// so this might actually save space by avoiding storing the same data twice.
 	// This defaults to reasonable value based on module and should not be set.
@@ -77,12 +88,19 @@ type dexer struct {
 	extraProguardFlagFiles android.Paths
 	proguardDictionary     android.OptionalPath
 	proguardUsageZip       android.OptionalPath
+
+	// proguard flags file generated from Optimize.Keep_annotations
+	keepAnnotationsFlags android.OptionalPath
 }
 
 func (d *dexer) effectiveOptimizeEnabled() bool {
 	return BoolDefault(d.dexProperties.Optimize.Enabled, d.dexProperties.Optimize.EnabledByDefault)
 }
 
+func (d *dexer) coreLibraryDesugaringEnabled() bool {
+	return proptools.Bool(d.dexProperties.Core_library_desugaring)
+}
+
 var d8, d8RE = remoteexec.MultiCommandStaticRules(pctx, "d8",
 	blueprint.RuleParams{
 		Command: `rm -rf "$outDir" && mkdir -p "$outDir" && ` +
@@ -157,6 +175,40 @@ var r8, r8RE = remoteexec.MultiCommandStaticRules(pctx, "r8",
 	}, []string{"outDir", "outDict", "outUsage", "outUsageZip", "outUsageDir",
 		"r8Flags", "zipFlags"}, []string{"implicits"})
 
+// l8 dexes the desugared core library, shrinking it with the keep rules generated by r8 when
+// $l8Flags contains them.
+var l8 = pctx.AndroidStaticRule("l8",
+	blueprint.RuleParams{
+		Command: `rm -rf "$outDir" && mkdir -p "$outDir" && ` +
+			`${config.L8Cmd} ${config.DexFlags} --release --desugared-lib ${config.CoreLibDesugaringConfig} ` +
+			`--output $outDir $l8Flags ${config.CoreLibDesugaringJar} && ` +
+			`${config.SoongZipCmd} -o $out -C $outDir -f "$outDir/classes*.dex"`,
+		CommandDeps: []string{
+			"${config.L8Cmd}",
+			"${config.SoongZipCmd}",
+			"${config.CoreLibDesugaringConfig}",
+			"${config.CoreLibDesugaringJar}",
+		},
+	}, "outDir", "l8Flags")
+
+// mergeDesugaredLibDex appends the dex files of the desugared core library to a dex jar, renaming
+// them in order so that they follow the last classes*.dex file of the jar.
+var mergeDesugaredLibDex = pctx.AndroidStaticRule("mergeDesugaredLibDex",
+	blueprint.RuleParams{
+		Command: `rm -rf "$outDir" && mkdir -p "$outDir/lib" && ` +
+			`unzip -qo $desugaredLib 'classes*.dex' -d $outDir/lib && ` +
+			`n=$$(zipinfo -1 $in 'classes*.dex' | wc -l) && i=1 && f=classes.dex && ` +
+			`while [ -f $outDir/lib/$$f ]; do ` +
+			`n=$$((n + 1)) && mv $outDir/lib/$$f $outDir/classes$$n.dex && i=$$((i + 1)) && f=classes$$i.dex; ` +
+			`done && ` +
+			`${config.SoongZipCmd} -o $outDir/desugared_lib.jar -C $outDir -f "$outDir/classes*.dex" && ` +
+			`${config.MergeZipsCmd} $out $in $outDir/desugared_lib.jar`,
+		CommandDeps: []string{
+			"${config.SoongZipCmd}",
+			"${config.MergeZipsCmd}",
+		},
+	}, "outDir", "desugaredLib")
+
 func (d *dexer) dexCommonFlags(ctx android.ModuleContext, minSdkVersion sdkSpec) []string {
 	flags := d.dexProperties.Dxflags
 	// Translate all the DX flags to D8 ones until all the build files have been migrated
@@ -193,6 +245,20 @@ func d8Flags(flags javaBuilderFlags) (d8Flags []string, d8Deps android.Paths) {
 	return d8Flags, d8Deps
 }
 
+// keepAnnotationRules returns the proguard rules that keep classes and members annotated with
+// any of the given annotations.
+func keepAnnotationRules(annotations []string) string {
+	var rules []string
+	for _, a := range annotations {
+		rules = append(rules,
+			fmt.Sprintf("-keep @%s class * { *; }", a),
+			fmt.Sprintf("-keepclasseswithmembers class * { @%s <methods>; }", a),
+			fmt.Sprintf("-keepclasseswithmembers class * { @%s <fields>; }", a),
+			fmt.Sprintf("-keepclasseswithmembers class * { @%s <init>(...); }", a))
+	}
+	return strings.Join(rules, "\n")
+}
+
 func (d *dexer) r8Flags(ctx android.ModuleContext, flags javaBuilderFlags) (r8Flags []string, r8Deps android.Paths) {
 	opt := d.dexProperties.Optimize
 
@@ -225,6 +291,13 @@ func (d *dexer) r8Flags(ctx android.ModuleContext, flags javaBuilderFlags) (r8Fl
 
 	flagFiles = append(flagFiles, android.PathsForModuleSrc(ctx, opt.Proguard_flags_files)...)
 
+	if len(opt.Keep_annotations) > 0 {
+		keepAnnotationsFlags := android.PathForModuleOut(ctx, "proguard", "keep_annotations.flags")
+		android.WriteFileRule(ctx, keepAnnotationsFlags, keepAnnotationRules(opt.Keep_annotations))
+		d.keepAnnotationsFlags = android.OptionalPathForPath(keepAnnotationsFlags)
+		flagFiles = append(flagFiles, keepAnnotationsFlags)
+	}
+
 	r8Flags = append(r8Flags, android.JoinWithPrefix(flagFiles.Strings(), "-include "))
 	r8Deps = append(r8Deps, flagFiles...)
 
@@ -276,6 +349,12 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 
 	commonFlags := d.dexCommonFlags(ctx, minSdkVersion)
 
+	desugarCoreLibrary := d.coreLibraryDesugaringEnabled()
+	var desugarKeepRules android.WritablePath
+	if desugarCoreLibrary {
+		commonFlags = append(commonFlags, "--desugared-lib ${config.CoreLibDesugaringConfig}")
+	}
+
 	useR8 := d.effectiveOptimizeEnabled()
 	if useR8 {
 		proguardDictionary := android.PathForModuleOut(ctx, "proguard_dictionary")
@@ -286,6 +365,14 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 		proguardUsageZip := android.PathForModuleOut(ctx, "proguard_usage.zip")
 		d.proguardUsageZip = android.OptionalPathForPath(proguardUsageZip)
 		r8Flags, r8Deps := d.r8Flags(ctx, flags)
+		implicitOutputs := android.WritablePaths{proguardDictionary, proguardUsageZip}
+		if desugarCoreLibrary {
+			// Have r8 generate the keep rules for the parts of the desugared core library that
+			// the module uses, so that l8 can shrink it.
+			desugarKeepRules = android.PathForModuleOut(ctx, "desugar", "keep_rules.txt")
+			r8Flags = append(r8Flags, "--desugared-lib-pg-conf-output "+desugarKeepRules.String())
+			implicitOutputs = append(implicitOutputs, desugarKeepRules)
+		}
 		rule := r8
 		args := map[string]string{
 			"r8Flags":     strings.Join(append(commonFlags, r8Flags...), " "),
@@ -304,7 +391,7 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 			Rule:            rule,
 			Description:     "r8",
 			Output:          javalibJar,
-			ImplicitOutputs: android.WritablePaths{proguardDictionary, proguardUsageZip},
+			ImplicitOutputs: implicitOutputs,
 			Input:           classesJar,
 			Implicits:       r8Deps,
 			Args:            args,
@@ -328,6 +415,11 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 			},
 		})
 	}
+	if desugarCoreLibrary {
+		javalibJar = d.desugaredCoreLibraryBuildActions(ctx, minSdkVersion, desugarKeepRules,
+			javalibJar, jarName)
+	}
+
 	if proptools.Bool(d.dexProperties.Uncompress_dex) {
 		alignedJavalibJar := android.PathForModuleOut(ctx, "aligned", jarName)
 		TransformZipAlign(ctx, alignedJavalibJar, javalibJar)
@@ -336,3 +428,47 @@ func (d *dexer) compileDex(ctx android.ModuleContext, flags javaBuilderFlags, mi
 
 	return javalibJar
 }
+
+// desugaredCoreLibraryBuildActions dexes the desugared core library, shrunk with keepRules if it is
+// not nil, and returns a dex jar containing both the dex files in dexJar and the desugared library.
+func (d *dexer) desugaredCoreLibraryBuildActions(ctx android.ModuleContext, minSdkVersion sdkSpec,
+	keepRules android.Path, dexJar android.Path, jarName string) android.ModuleOutPath {
+
+	effectiveVersion, err := minSdkVersion.effectiveVersion(ctx)
+	if err != nil {
+		ctx.PropertyErrorf("min_sdk_version", "%s", err)
+	}
+	l8Flags := []string{"--min-api " + effectiveVersion.asNumberString()}
+	var implicits android.Paths
+	if keepRules != nil {
+		l8Flags = append(l8Flags, "--pg-conf "+keepRules.String())
+		implicits = append(implicits, keepRules)
+	}
+
+	desugaredLib := android.PathForModuleOut(ctx, "desugar", "desugared_lib.dex.jar")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        l8,
+		Description: "l8",
+		Output:      desugaredLib,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"outDir":  android.PathForModuleOut(ctx, "desugar", "l8").String(),
+			"l8Flags": strings.Join(l8Flags, " "),
+		},
+	})
+
+	mergedJar := android.PathForModuleOut(ctx, "desugar", jarName)
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        mergeDesugaredLibDex,
+		Description: "merge desugared core library",
+		Output:      mergedJar,
+		Input:       dexJar,
+		Implicit:    desugaredLib,
+		Args: map[string]string{
+			"outDir":       android.PathForModuleOut(ctx, "desugar", "merge").String(),
+			"desugaredLib": desugaredLib.String(),
+		},
+	})
+
+	return mergedJar
+}
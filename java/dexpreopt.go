@@ -15,6 +15,9 @@
 package java
 
 import (
+	"path/filepath"
+	"strings"
+
 	"android/soong/android"
 	"android/soong/dexpreopt"
 )
@@ -33,11 +36,21 @@ type dexpreopter struct {
 	isTest              bool
 	isPresignedPrebuilt bool
 
+	// If true, the APEX variant of the module is dexpreopted into the payload of the APEX.
+	preoptInApex bool
+
 	manifestFile        android.Path
 	enforceUsesLibs     bool
 	classLoaderContexts dexpreopt.ClassLoaderContextMap
 
 	builtInstalled string
+
+	// The dexpreopt artifacts of the APEX variant, installed relative to the root of the APEX.
+	builtInstalledForApex android.RuleBuilderInstalls
+
+	// The dexpreopt artifacts of the APEX variant of an updatable system server jar, installed in
+	// the system partition.
+	builtInstalledOnSystem android.RuleBuilderInstalls
 }
 
 type DexpreoptProperties struct {
@@ -91,9 +104,25 @@ func (d *dexpreopter) dexpreoptDisabled(ctx android.BaseModuleContext) bool {
 		return true
 	}
 
-	// Don't preopt APEX variant module
+	// Don't preopt APEX variant module, unless it is preopted into the payload of the APEX.
 	if apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo); !apexInfo.IsForPlatform() {
-		return true
+		// Updatable system server jars are preopted from the variant in their APEX.
+		if _, ok := updatableSystemServerJarLocation(ctx, global); ok {
+			return false
+		}
+		if !d.preoptInApex {
+			return true
+		}
+		// The preopted code is only valid with the boot image it was compiled against, which an
+		// updatable APEX doesn't ship with.
+		if apexInfo.Updatable {
+			return true
+		}
+		// The dex location, which is encoded in the preopted files, is in the mount path of the
+		// APEX, so a variant that is shared by several APEXes can't be preopted.
+		if len(apexInfo.InApexes) != 1 {
+			return true
+		}
 	}
 
 	// TODO: contains no java code
@@ -101,6 +130,21 @@ func (d *dexpreopter) dexpreoptDisabled(ctx android.BaseModuleContext) bool {
 	return false
 }
 
+// updatableSystemServerJarLocation returns the on-device location of the module if it is an
+// updatable system server jar and this is the variant for the APEX it is configured in.
+func updatableSystemServerJarLocation(ctx android.BaseModuleContext, global *dexpreopt.GlobalConfig) (string, bool) {
+	jars := global.UpdatableSystemServerJars
+	i := jars.IndexOfJar(ctx.ModuleName())
+	if i < 0 {
+		return "", false
+	}
+	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
+	if !apexInfo.InApex(jars.Apex(i)) {
+		return "", false
+	}
+	return jars.DevicePaths(ctx.Config(), android.Android)[i], true
+}
+
 func dexpreoptToolDepsMutator(ctx android.BottomUpMutatorContext) {
 	if d, ok := ctx.Module().(dexpreopterInterface); !ok || d.dexpreoptDisabled(ctx) {
 		return
@@ -139,7 +183,9 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Mo
 				targets = append(targets, target)
 			}
 		}
-		if inList(ctx.ModuleName(), global.SystemServerJars) && !d.isSDKLibrary {
+		isSystemServerJar := inList(ctx.ModuleName(), global.SystemServerJars) ||
+			global.UpdatableSystemServerJars.ContainsJar(ctx.ModuleName())
+		if isSystemServerJar && !d.isSDKLibrary {
 			// If the module is not an SDK library and it's a system server jar, only preopt the primary arch.
 			targets = targets[:1]
 		}
@@ -158,6 +204,20 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Mo
 	imageLocations := bootImage.getAnyAndroidVariant().imageLocations()
 
 	dexLocation := android.InstallPathToOnDevicePath(ctx, d.installPath)
+	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
+	apexDir := ""
+	updatableSystemServerJar := false
+	if location, ok := updatableSystemServerJarLocation(ctx, global); ok {
+		// The jar is loaded from its APEX, but its preopted code is installed in the system
+		// partition.
+		dexLocation = location
+		updatableSystemServerJar = true
+	} else if !apexInfo.IsForPlatform() {
+		// The APEX variant is installed at the same path relative to the root of the APEX, which
+		// is mounted at /apex/<apex name> on the device.
+		apexDir = filepath.Join("/apex", apexInfo.InApexes[0])
+		dexLocation = filepath.Join(apexDir, d.installPath.Rel())
+	}
 
 	var profileClassListing android.OptionalPath
 	var profileBootListing android.OptionalPath
@@ -219,4 +279,30 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Mo
 	dexpreoptRule.Build("dexpreopt", "dexpreopt")
 
 	d.builtInstalled = dexpreoptRule.Installs().String()
+
+	if updatableSystemServerJar {
+		d.builtInstalledOnSystem = dexpreoptRule.Installs()
+	} else if apexDir != "" {
+		for _, install := range dexpreoptRule.Installs() {
+			rel, err := filepath.Rel(apexDir, install.To)
+			if err != nil || strings.HasPrefix(rel, "../") {
+				ctx.ModuleErrorf("dexpreopt artifact %q is not installed in %q", install.To, apexDir)
+				continue
+			}
+			d.builtInstalledForApex = append(d.builtInstalledForApex,
+				android.RuleBuilderInstall{From: install.From, To: rel})
+		}
+	}
+}
+
+// DexpreoptBuiltInstalledForApex returns the dexpreopt artifacts of the APEX variant of the
+// module, with the paths they are installed to relative to the root of the APEX.
+func (d *dexpreopter) DexpreoptBuiltInstalledForApex() android.RuleBuilderInstalls {
+	return d.builtInstalledForApex
+}
+
+// DexpreoptBuiltInstalledOnSystem returns the dexpreopt artifacts of the APEX variant of an
+// updatable system server jar, which are installed in the system partition.
+func (d *dexpreopter) DexpreoptBuiltInstalledOnSystem() android.RuleBuilderInstalls {
+	return d.builtInstalledOnSystem
 }
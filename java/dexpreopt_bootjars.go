@@ -733,13 +733,20 @@ func bootImageProfileRule(ctx android.SingletonContext, image *bootImageConfig,
 		rule := android.NewRuleBuilder(pctx, ctx)
 		rule.MissingDeps(missingDeps)
 
+		// The boot image profiles of the product take precedence over the ones that Make passes
+		// through dexpreopt.config.
+		bootImageProfiles := global.BootImageProfiles
+		if profiles := ctx.Config().BootImageProfiles(); len(profiles) > 0 {
+			bootImageProfiles = android.PathsForSource(ctx, profiles)
+		}
+
 		var bootImageProfile android.Path
-		if len(global.BootImageProfiles) > 1 {
+		if len(bootImageProfiles) > 1 {
 			combinedBootImageProfile := image.dir.Join(ctx, "boot-image-profile.txt")
-			rule.Command().Text("cat").Inputs(global.BootImageProfiles).Text(">").Output(combinedBootImageProfile)
+			rule.Command().Text("cat").Inputs(bootImageProfiles).Text(">").Output(combinedBootImageProfile)
 			bootImageProfile = combinedBootImageProfile
-		} else if len(global.BootImageProfiles) == 1 {
-			bootImageProfile = global.BootImageProfiles[0]
+		} else if len(bootImageProfiles) == 1 {
+			bootImageProfile = bootImageProfiles[0]
 		} else if path := android.ExistentPathForSource(ctx, defaultProfile); path.Valid() {
 			bootImageProfile = path.Path()
 		} else {
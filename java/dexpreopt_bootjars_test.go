@@ -133,3 +133,58 @@ func TestDexpreoptBootZip(t *testing.T) {
 
 	testDexpreoptBoot(t, ruleFile, expectedInputs, expectedOutputs)
 }
+
+func TestDexpreoptBootImageProfile(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			installable: true,
+		}
+	`
+
+	fs := map[string][]byte{
+		"device/vendor/boot-image-profile.txt":        nil,
+		"device/vendor/common/boot-image-profile.txt": nil,
+	}
+	config := testConfig(nil, bp, fs)
+	config.TestProductVariables.BootImageProfiles = []string{
+		"device/vendor/boot-image-profile.txt",
+		"device/vendor/common/boot-image-profile.txt",
+	}
+
+	pathCtx := android.PathContextForTesting(config)
+	dexpreoptConfig := dexpreopt.GlobalConfigForTests(pathCtx)
+	dexpreoptConfig.BootJars = android.CreateTestConfiguredJarList([]string{"platform:foo"})
+	// The profiles of the product take precedence over the ones from dexpreopt.config.
+	dexpreoptConfig.BootImageProfiles = android.Paths{android.PathForTesting("frameworks/base/config/boot-image-profile.txt")}
+	dexpreopt.SetTestGlobalConfig(config, dexpreoptConfig)
+
+	ctx := testContext(config)
+	RegisterDexpreoptBootJarsComponents(ctx)
+	run(t, ctx, config)
+
+	dexpreoptBootJars := ctx.SingletonForTests("dex_bootjars")
+
+	profile := dexpreoptBootJars.Output("boot.prof")
+	inputs := profile.Implicits.Strings()
+	for _, expected := range []string{
+		"device/vendor/boot-image-profile.txt",
+		"device/vendor/common/boot-image-profile.txt",
+	} {
+		if !android.InList(expected, inputs) {
+			t.Errorf("want boot.prof to be created from %q, got inputs %q", expected, inputs)
+		}
+	}
+	if android.InList("frameworks/base/config/boot-image-profile.txt", inputs) {
+		t.Errorf("want the profiles of the product only, got inputs %q", inputs)
+	}
+
+	// The boot image, which the dexpreopted apps depend on, is compiled with the profile, so the
+	// apps are dexpreopted again when the profile changes.
+	bootProf := filepath.Join(buildDir, "test_device", "dex_bootjars/boot.prof")
+	bootImage := dexpreoptBootJars.Output("boot-foo.art")
+	if !android.InList(bootProf, bootImage.Implicits.Strings()) {
+		t.Errorf("want boot image to depend on %q, got inputs %q", bootProf, bootImage.Implicits)
+	}
+}
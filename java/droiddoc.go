@@ -399,7 +399,7 @@ func (j *Javadoc) aidlFlags(ctx android.ModuleContext, aidlPreprocess android.Op
 	aidlIncludeDirs android.Paths) (string, android.Paths) {
 
 	aidlIncludes := android.PathsForModuleSrc(ctx, j.properties.Aidl.Local_include_dirs)
-	aidlIncludes = append(aidlIncludes, android.PathsForSource(ctx, j.properties.Aidl.Include_dirs)...)
+	aidlIncludes = append(aidlIncludes, android.PathsForSandboxedSource(ctx, "aidl.include_dirs", j.properties.Aidl.Include_dirs)...)
 
 	var flags []string
 	var deps android.Paths
@@ -155,11 +155,17 @@ func (j *Module) genSources(ctx android.ModuleContext, srcFiles android.Paths,
 	if len(protoSrcs) > 0 {
 		srcJarFiles := genProto(ctx, protoSrcs, flags.proto)
 		outSrcFiles = append(outSrcFiles, srcJarFiles...)
+		j.protoSrcJars = srcJarFiles
 	}
 
 	// Process all aidl files together to support sharding them into one or more rules that produce srcjars.
 	if len(aidlSrcs) > 0 {
-		srcJarFiles := genAidl(ctx, aidlSrcs, flags.aidlFlags+aidlIncludeFlags, flags.aidlDeps)
+		aidlDeps := flags.aidlDeps
+		if flags.aidlStrictImports {
+			aidlDeps = append(aidlDeps, android.AidlStrictImportsCheck(ctx, aidlSrcs, flags.aidlPreprocess,
+				flags.aidlStrictImportDirs))
+		}
+		srcJarFiles := genAidl(ctx, aidlSrcs, flags.aidlFlags+aidlIncludeFlags, aidlDeps)
 		outSrcFiles = append(outSrcFiles, srcJarFiles...)
 	}
 
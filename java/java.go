@@ -259,8 +259,19 @@ type CompilerProperties struct {
 	Proto struct {
 		// List of extra options that will be passed to the proto generator.
 		Output_params []string
+
+		// If true, compile the sources generated from .proto files into a separate header jar
+		// that does not include any static_libs, for use by modules that list this module in
+		// proto_api_libs.
+		Api_jar *bool
 	}
 
+	// List of modules with proto.api_jar set whose proto API header jars are added to the
+	// compile classpath.  Unlike libs, the static_libs of those modules (for example the
+	// protobuf runtime) are not added, so any runtime dependencies used directly must be
+	// listed explicitly.
+	Proto_api_libs []string
+
 	Instrument bool `blueprint:"mutated"`
 
 	// List of files to include in the META-INF/services folder of the resulting jar.
@@ -307,6 +318,13 @@ type CompilerDeviceProperties struct {
 
 		// list of flags that will be passed to the AIDL compiler
 		Flags []string
+
+		// if true, the imports of aidl sources must resolve to the sources of this module, to a
+		// file in aidl.local_include_dirs or aidl.export_include_dirs, to a directory exported by
+		// a dependency with aidl.export_include_dirs, or to the SDK.  An import that would only
+		// resolve through the directory of the module is reported, and aidl.include_dirs is not
+		// allowed.
+		Strict_imports *bool
 	}
 
 	// If true, export a copy of the module as a -hostdex module for host testing.
@@ -387,6 +405,13 @@ type Module struct {
 	// resources
 	implementationJarFile android.Path
 
+	// srcjars generated from .proto sources
+	protoSrcJars android.Paths
+
+	// jar file containing header classes compiled only from the sources generated from .proto
+	// files, without any static library dependencies
+	protoApiHeaderJarFile android.Path
+
 	// jar file containing only resources including from static library dependencies
 	resourceJar android.Path
 
@@ -407,6 +432,12 @@ type Module struct {
 	// output file containing uninstrumented classes that will be instrumented by jacoco
 	jacocoReportClassesFile android.Path
 
+	// diagnostics reported by error-prone, if it is enabled
+	errorproneFindingsFile android.Path
+
+	// report of the dependency audit, if it is enabled
+	depsAuditReport android.OptionalPath
+
 	// output file of the module, which may be a classes jar or a dex jar
 	outputFile       android.Path
 	extraOutputFiles android.Paths
@@ -496,6 +527,11 @@ func (j *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{j.dexer.proguardDictionary.Path()}, nil
 		}
 		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	case ".keep_annotations":
+		if j.dexer.keepAnnotationsFlags.Valid() {
+			return android.Paths{j.dexer.keepAnnotationsFlags.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -528,6 +564,12 @@ type Dependency interface {
 	JacocoReportClassesFile() android.Path
 }
 
+// ProtoApiDependency is implemented by modules that can export the header classes of their
+// generated proto sources separately from their implementation.
+type ProtoApiDependency interface {
+	ProtoApiHeaderJars() android.Paths
+}
+
 type xref interface {
 	XrefJavaFiles() android.Paths
 }
@@ -588,6 +630,7 @@ var (
 	staticLibTag          = dependencyTag{name: "staticlib"}
 	libTag                = dependencyTag{name: "javalib"}
 	java9LibTag           = dependencyTag{name: "java9lib"}
+	protoApiLibTag        = dependencyTag{name: "proto-api-lib"}
 	pluginTag             = dependencyTag{name: "plugin"}
 	errorpronePluginTag   = dependencyTag{name: "errorprone-plugin"}
 	exportedPluginTag     = dependencyTag{name: "exported-plugin"}
@@ -640,6 +683,11 @@ type sdkDep struct {
 	aidl android.OptionalPath
 
 	noStandardLibs, noFrameworksLibs bool
+
+	// The module that provides the framework API of a public, system, module or system_server SDK.
+	// Dependencies on the framework implementation library are redirected to it.  It is empty for
+	// the other SDKs, and when a prebuilt SDK only provides the jar of a narrower API surface.
+	frameworkStubs string
 }
 
 func (s sdkDep) hasStandardLibs() bool {
@@ -722,7 +770,7 @@ func (j *Module) AvailableFor(what string) bool {
 	return j.ApexModuleBase.AvailableFor(what)
 }
 
-func sdkDeps(ctx android.BottomUpMutatorContext, sdkContext sdkContext, d dexer) {
+func sdkDeps(ctx android.BottomUpMutatorContext, sdkContext sdkContext, d dexer) sdkDep {
 	sdkDep := decodeSdkDep(ctx, sdkContext)
 	if sdkDep.useModule {
 		ctx.AddVariationDependencies(nil, bootClasspathTag, sdkDep.bootclasspath...)
@@ -738,14 +786,17 @@ func sdkDeps(ctx android.BottomUpMutatorContext, sdkContext sdkContext, d dexer)
 	if sdkDep.systemModules != "" {
 		ctx.AddVariationDependencies(nil, systemModulesTag, sdkDep.systemModules)
 	}
+	return sdkDep
 }
 
 func (j *Module) deps(ctx android.BottomUpMutatorContext) {
+	var frameworkStubs string
 	if ctx.Device() {
 		j.linter.deps(ctx)
 
-		sdkDeps(ctx, sdkContext(j), j.dexer)
+		frameworkStubs = sdkDeps(ctx, sdkContext(j), j.dexer).frameworkStubs
 	}
+	sdkVersion := sdkContext(j).sdkVersion()
 
 	syspropPublicStubs := syspropPublicStubs(ctx.Config())
 
@@ -772,8 +823,36 @@ func (j *Module) deps(ctx android.BottomUpMutatorContext) {
 		return ret
 	}
 
-	libDeps := ctx.AddVariationDependencies(nil, libTag, rewriteSyspropLibs(j.properties.Libs, "libs")...)
+	// rewriteFrameworkLibs redirects dependencies on the framework implementation library to the
+	// stubs of the public, system, module or system_server SDK the module is built against, so that
+	// modules can't reach beyond the API surface of their sdk_version by listing the framework in
+	// libs.
+	rewriteFrameworkLibs := func(libs []string) []string {
+		if !ctx.Device() || !sdkVersion.kind.hasFrameworkStubs() {
+			return libs
+		}
+		ret := android.CopyOf(libs)
+		for idx, lib := range libs {
+			if lib != "framework" {
+				continue
+			}
+			if frameworkStubs == "" {
+				if ctx.Failed() {
+					// The sdk_version has already been reported as invalid.
+					continue
+				}
+				ctx.PropertyErrorf("libs", "sdk_version %q doesn't provide stubs of the framework", sdkVersion.raw)
+				continue
+			}
+			ret[idx] = frameworkStubs
+		}
+		return ret
+	}
+
+	libDeps := ctx.AddVariationDependencies(nil, libTag,
+		rewriteFrameworkLibs(rewriteSyspropLibs(j.properties.Libs, "libs"))...)
 	ctx.AddVariationDependencies(nil, staticLibTag, rewriteSyspropLibs(j.properties.Static_libs, "static_libs")...)
+	ctx.AddVariationDependencies(nil, protoApiLibTag, j.properties.Proto_api_libs...)
 
 	if ctx.DeviceConfig().VndkVersion() != "" && ctx.Config().EnforceInterPartitionJavaSdkLibrary() {
 		// Require java_sdk_library at inter-partition java dependency to ensure stable
@@ -870,7 +949,13 @@ func (j *Module) aidlFlags(ctx android.ModuleContext, aidlPreprocess android.Opt
 	aidlIncludes = append(aidlIncludes,
 		android.PathsForModuleSrc(ctx, j.deviceProperties.Aidl.Export_include_dirs)...)
 	aidlIncludes = append(aidlIncludes,
-		android.PathsForSource(ctx, j.deviceProperties.Aidl.Include_dirs)...)
+		android.PathsForSandboxedSource(ctx, "aidl.include_dirs", j.deviceProperties.Aidl.Include_dirs)...)
+
+	strictImports := Bool(j.deviceProperties.Aidl.Strict_imports)
+	if strictImports && len(j.deviceProperties.Aidl.Include_dirs) > 0 {
+		ctx.PropertyErrorf("aidl.include_dirs",
+			"not allowed with aidl.strict_imports, export the directories from a dependency with aidl.export_include_dirs instead")
+	}
 
 	var flags []string
 	var deps android.Paths
@@ -880,7 +965,10 @@ func (j *Module) aidlFlags(ctx android.ModuleContext, aidlPreprocess android.Opt
 	if aidlPreprocess.Valid() {
 		flags = append(flags, "-p"+aidlPreprocess.String())
 		deps = append(deps, aidlPreprocess.Path())
-	} else if len(aidlIncludeDirs) > 0 {
+	}
+	// With strict imports the dependencies are the only source of imports besides the SDK, so
+	// their exported directories are needed even when the SDK is preprocessed.
+	if (!aidlPreprocess.Valid() || strictImports) && len(aidlIncludeDirs) > 0 {
 		flags = append(flags, android.JoinWithPrefix(aidlIncludeDirs.Strings(), "-I"))
 	}
 
@@ -1101,6 +1189,13 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				deps.disableTurbine = deps.disableTurbine || disableTurbine
 			case java9LibTag:
 				deps.java9Classpath = append(deps.java9Classpath, dep.HeaderJars()...)
+			case protoApiLibTag:
+				if protoApiDep, ok := dep.(ProtoApiDependency); ok && len(protoApiDep.ProtoApiHeaderJars()) > 0 {
+					deps.classpath = append(deps.classpath, protoApiDep.ProtoApiHeaderJars()...)
+				} else {
+					ctx.PropertyErrorf("proto_api_libs",
+						"module %q does not export a proto API jar, it must set proto.api_jar: true", otherName)
+				}
 			case staticLibTag:
 				deps.classpath = append(deps.classpath, dep.HeaderJars()...)
 				deps.staticJars = append(deps.staticJars, dep.ImplementationJars()...)
@@ -1314,6 +1409,13 @@ func (j *Module) collectBuilderFlags(ctx android.ModuleContext, deps deps) javaB
 
 	// aidl flags.
 	flags.aidlFlags, flags.aidlDeps = j.aidlFlags(ctx, deps.aidlPreprocess, deps.aidlIncludeDirs)
+	if Bool(j.deviceProperties.Aidl.Strict_imports) {
+		flags.aidlStrictImports = true
+		flags.aidlStrictImportDirs = append(android.PathsForModuleSrc(ctx, j.deviceProperties.Aidl.Local_include_dirs),
+			android.PathsForModuleSrc(ctx, j.deviceProperties.Aidl.Export_include_dirs)...)
+		flags.aidlStrictImportDirs = append(flags.aidlStrictImportDirs, deps.aidlIncludeDirs...)
+		flags.aidlPreprocess = deps.aidlPreprocess
+	}
 
 	return flags
 }
@@ -1512,6 +1614,18 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 
 	enableSharding := false
 	var headerJarFileWithoutJarjar android.Path
+	if Bool(j.properties.Proto.Api_jar) {
+		if len(j.protoSrcJars) == 0 {
+			ctx.PropertyErrorf("proto.api_jar", "requires .proto files in srcs")
+		} else {
+			// Compile only the generated proto sources, so that modules depending on the proto API
+			// jar don't see any of the static_libs or other sources of this module.
+			protoApiJar := android.PathForModuleOut(ctx, "proto-api", jarName)
+			TransformJavaToHeaderClasses(ctx, protoApiJar, nil, j.protoSrcJars, flags)
+			j.protoApiHeaderJarFile = protoApiJar
+		}
+	}
+
 	if ctx.Device() && !ctx.Config().IsEnvFalse("TURBINE_ENABLED") && !deps.disableTurbine {
 		if j.properties.Javac_shard_size != nil && *(j.properties.Javac_shard_size) > 0 {
 			enableSharding = true
@@ -1536,8 +1650,10 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 			// TODO(ccross): Once we always compile with javac9 we may be able to conditionally
 			//    enable error-prone without affecting the output class files.
 			errorprone := android.PathForModuleOut(ctx, "errorprone", jarName)
-			RunErrorProne(ctx, errorprone, uniqueSrcFiles, srcJars, flags)
+			findings := android.PathForModuleOut(ctx, "errorprone", "findings.txt")
+			RunErrorProne(ctx, errorprone, findings, uniqueSrcFiles, srcJars, flags)
 			extraJarDeps = append(extraJarDeps, errorprone)
+			j.errorproneFindingsFile = findings
 		}
 
 		if enableSharding {
@@ -1566,6 +1682,10 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		}
 	}
 
+	if ctx.Config().DepsAuditEnabled() && len(jars) > 0 {
+		j.depsAuditReport = android.OptionalPathForPath(j.depsAuditBuildActions(ctx, jars, flags.classpath))
+	}
+
 	j.srcJarArgs, j.srcJarDeps = resourcePathsToJarArgs(srcFiles), srcFiles
 
 	var includeSrcJar android.WritablePath
@@ -1943,6 +2063,13 @@ func (j *Module) HeaderJars() android.Paths {
 	return android.Paths{j.headerJarFile}
 }
 
+func (j *Module) ProtoApiHeaderJars() android.Paths {
+	if j.protoApiHeaderJarFile == nil {
+		return nil
+	}
+	return android.Paths{j.protoApiHeaderJarFile}
+}
+
 func (j *Module) ImplementationJars() android.Paths {
 	if j.implementationJarFile == nil {
 		return nil
@@ -1958,6 +2085,10 @@ func (j *Module) DexJarInstallPath() android.Path {
 	return j.installFile
 }
 
+func (j *Module) errorproneFindings() android.Path {
+	return j.errorproneFindingsFile
+}
+
 func (j *Module) ResourceJars() android.Paths {
 	if j.resourceJar == nil {
 		return nil
@@ -2403,6 +2534,21 @@ func (j *Test) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	})
 
 	j.Library.GenerateAndroidBuildActions(ctx)
+
+	if ctx.Config().TestAnnotationIndexEnabled() && j.implementationJarFile != nil {
+		j.data = append(j.data, buildTestAnnotationIndex(ctx, j.implementationJarFile))
+	}
+}
+
+// buildTestAnnotationIndex generates an index of the test classes and methods of a test module by
+// filtering annotation, which is installed alongside the test so that test schedulers can select
+// flaky, presubmit or postsubmit tests without inspecting the test itself.  The index is only built
+// when SOONG_TEST_ANNOTATION_INDEX=true, as scanning the classes of every test slows down builds
+// that don't schedule tests.
+func buildTestAnnotationIndex(ctx android.ModuleContext, classesJar android.Path) android.Path {
+	index := android.PathForModuleOut(ctx, ctx.ModuleName()+".test_annotations.json")
+	TransformJarToTestAnnotationIndex(ctx, index, classesJar)
+	return index
 }
 
 func (j *TestHelperLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext) {
@@ -88,6 +88,9 @@ func testContext(config android.Config) *android.TestContext {
 	RegisterStubsBuildComponents(ctx)
 	RegisterPrebuiltApisBuildComponents(ctx)
 	RegisterSdkLibraryBuildComponents(ctx)
+	ctx.RegisterSingletonType("code_health_report", codeHealthReportSingletonFactory)
+	ctx.RegisterSingletonType("dead_code_report", deadCodeReportSingletonFactory)
+	ctx.RegisterSingletonType("java_deps_audit", javaDepsAuditSingletonFactory)
 	ctx.PreArchMutators(android.RegisterDefaultsPreArchMutators)
 	ctx.PreArchMutators(android.RegisterComponentsMutator)
 
@@ -1637,6 +1640,68 @@ func TestExcludeFileGroupInSrcs(t *testing.T) {
 	}
 }
 
+func TestProtoApiJar(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.proto", "a.java"],
+			proto: {
+				api_jar: true,
+			},
+			sdk_version: "14",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			proto_api_libs: ["foo"],
+			libs: ["libprotobuf-java-lite"],
+			sdk_version: "14",
+		}
+
+		java_library {
+			name: "libprotobuf-java-lite",
+			srcs: ["c.java"],
+			sdk_version: "14",
+		}
+		`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	fooProtoApi := foo.Output("proto-api/foo.jar")
+	protoSrcJar := filepath.Join(buildDir, ".intermediates", "foo", "android_common", "gen", "proto", "proto0.srcjar")
+	if len(fooProtoApi.Inputs) != 0 {
+		t.Errorf("expected no java sources in the proto API jar, got %v", fooProtoApi.Inputs)
+	}
+	if !android.InList(protoSrcJar, fooProtoApi.Implicits.Strings()) {
+		t.Errorf("expected proto API jar to be compiled from %q, got %v", protoSrcJar, fooProtoApi.Implicits)
+	}
+
+	barJavac := ctx.ModuleForTests("bar", "android_common").Rule("javac")
+	fooProtoApiJar := filepath.Join(buildDir, ".intermediates", "foo", "android_common", "proto-api", "foo.jar")
+	fooHeaderJar := filepath.Join(buildDir, ".intermediates", "foo", "android_common", "turbine-combined", "foo.jar")
+	if !strings.Contains(barJavac.Args["classpath"], fooProtoApiJar) {
+		t.Errorf("bar javac classpath %v does not contain %q", barJavac.Args["classpath"], fooProtoApiJar)
+	}
+	if strings.Contains(barJavac.Args["classpath"], fooHeaderJar) {
+		t.Errorf("bar javac classpath %v should not contain %q", barJavac.Args["classpath"], fooHeaderJar)
+	}
+
+	testJavaError(t, `module "baz" does not export a proto API jar`, `
+		java_library {
+			name: "baz",
+			srcs: ["a.java"],
+			sdk_version: "14",
+		}
+
+		java_library {
+			name: "qux",
+			srcs: ["b.java"],
+			proto_api_libs: ["baz"],
+			sdk_version: "14",
+		}
+		`)
+}
+
 func TestJavaLibrary(t *testing.T) {
 	config := testConfig(nil, "", map[string][]byte{
 		"libcore/Android.bp": []byte(`
@@ -2424,6 +2489,77 @@ func TestAidlExportIncludeDirsFromImports(t *testing.T) {
 	}
 }
 
+func TestAidlStrictImports(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["aidl/foo/IFoo.aidl"],
+			libs: ["bar"],
+			sdk_version: "29",
+			aidl: {
+				strict_imports: true,
+			},
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["aidl/foo/IFoo.aidl"],
+			libs: ["bar"],
+			sdk_version: "29",
+		}
+
+		java_import {
+			name: "bar",
+			jars: ["a.jar"],
+			sdk_version: "29",
+			aidl: {
+				export_include_dirs: ["aidl/bar"],
+			},
+		}
+	`)
+
+	// With strict imports the exported directories of dependencies are passed even though the
+	// SDK's framework.aidl is preprocessed.
+	aidlCommand := ctx.ModuleForTests("foo", "android_common").Rule("aidl").RuleParams.Command
+	for _, expected := range []string{"-pprebuilts/sdk/29/public/framework.aidl", "-Iaidl/bar"} {
+		if !strings.Contains(aidlCommand, expected) {
+			t.Errorf("aidl command %q does not contain %q", aidlCommand, expected)
+		}
+	}
+
+	// The imports are checked against the declared locations before compiling.
+	foo := ctx.ModuleForTests("foo", "android_common")
+	check := foo.Rule("aidl_strict_imports")
+	for _, expected := range []string{"--preprocessed prebuilts/sdk/29/public/framework.aidl", "-I aidl/bar", "aidl/foo/IFoo.aidl"} {
+		if !strings.Contains(check.RuleParams.Command, expected) {
+			t.Errorf("strict imports check %q does not contain %q", check.RuleParams.Command, expected)
+		}
+	}
+	if !android.InList(check.Output.String(), foo.Rule("aidl").Implicits.Strings()) {
+		t.Errorf("expected aidl to depend on the strict imports check %q, got %q", check.Output, foo.Rule("aidl").Implicits)
+	}
+
+	baz := ctx.ModuleForTests("baz", "android_common")
+	aidlCommand = baz.Rule("aidl").RuleParams.Command
+	if strings.Contains(aidlCommand, "-Iaidl/bar") {
+		t.Errorf("aidl command %q unexpectedly contains %q", aidlCommand, "-Iaidl/bar")
+	}
+	if check := baz.MaybeRule("aidl_strict_imports"); check.Rule != nil {
+		t.Errorf("expected no strict imports check for baz")
+	}
+
+	testJavaError(t, `aidl.include_dirs: not allowed with aidl.strict_imports`, `
+		java_library {
+			name: "foo",
+			srcs: ["aidl/foo/IFoo.aidl"],
+			aidl: {
+				include_dirs: ["frameworks/base/core/java"],
+				strict_imports: true,
+			},
+		}
+	`)
+}
+
 func TestAidlFlagsArePassedToTheAidlCompiler(t *testing.T) {
 	ctx, _ := testJava(t, `
 		java_library {
@@ -2440,6 +2576,51 @@ func TestAidlFlagsArePassedToTheAidlCompiler(t *testing.T) {
 	}
 }
 
+func TestTestAnnotationIndex(t *testing.T) {
+	bp := `
+		java_test {
+			name: "foo",
+			srcs: ["a.java"],
+		}
+
+		android_test {
+			name: "bar",
+			srcs: ["b.java"],
+			sdk_version: "current",
+		}
+	`
+
+	// The index is not built by default.
+	ctx, _ := testJava(t, bp)
+	for _, name := range []string{"foo", "bar"} {
+		variant := ctx.ModuleForTests(name, "android_common")
+		if index := variant.MaybeOutput(name + ".test_annotations.json"); index.Rule != nil {
+			t.Errorf("%s: expected no test annotation index, got %q", name, index.Output)
+		}
+	}
+
+	config := testConfig(map[string]string{"SOONG_TEST_ANNOTATION_INDEX": "true"}, bp, nil)
+	ctx, _ = testJavaWithConfig(t, config)
+	for _, name := range []string{"foo", "bar"} {
+		variant := ctx.ModuleForTests(name, "android_common")
+		index := variant.Output(name + ".test_annotations.json")
+		if index.Rule != testAnnotationIndex {
+			t.Errorf("%s: expected rule %q, got %q", name, testAnnotationIndex, index.Rule)
+		}
+		jars := variant.Module().(Dependency).ImplementationJars()
+		if len(jars) != 1 || index.Input != jars[0] {
+			t.Errorf("%s: expected index to be generated from %q, got %q", name, jars, index.Input)
+		}
+
+		entries := android.AndroidMkEntriesForTest(t, config, "", variant.Module())[0]
+		expected := index.Output.String() + ":" + name + ".test_annotations.json"
+		if !android.InList(expected, entries.EntryMap["LOCAL_COMPATIBILITY_SUPPORT_FILES"]) {
+			t.Errorf("%s: expected %q in LOCAL_COMPATIBILITY_SUPPORT_FILES, got %q",
+				name, expected, entries.EntryMap["LOCAL_COMPATIBILITY_SUPPORT_FILES"])
+		}
+	}
+}
+
 func TestDataNativeBinaries(t *testing.T) {
 	ctx, config := testJava(t, `
 		java_test_host {
@@ -2464,3 +2645,193 @@ func TestDataNativeBinaries(t *testing.T) {
 		t.Errorf("Unexpected test data - expected: %q, actual: %q", expected, actual)
 	}
 }
+
+func TestCodeHealthReport(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			owner: "foo_team",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+			lint: {
+				enabled: false,
+			},
+		}
+	`)
+
+	singleton := ctx.SingletonForTests("code_health_report")
+	reportList := singleton.Output("code_health/lint-reports.txt")
+	report := singleton.Output("code_health/code-health-report.json")
+
+	fooXML := ctx.ModuleForTests("foo", "android_common").Output("lint-report.xml").Output
+	barXML := ctx.ModuleForTests("bar", "android_common").Output("lint-report.xml").Output
+
+	lines := strings.Split(android.ContentFromFileRuleForTests(t, reportList), "\n")
+	for _, expected := range []string{
+		"foo\tfoo_team\t.\t" + fooXML.String() + "\t",
+		"bar\t\t.\t" + barXML.String() + "\t",
+	} {
+		if !android.InList(expected, lines) {
+			t.Errorf("expected %q in report list %q", expected, lines)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "baz\t") {
+			t.Errorf("expected baz with lint disabled to be missing from the report list, got %q", line)
+		}
+	}
+
+	if report.Input != reportList.Output {
+		t.Errorf("expected report input %q, got %q", reportList.Output, report.Input)
+	}
+	for _, xml := range []android.Path{fooXML, barXML} {
+		if !android.InList(xml.String(), report.Implicits.Strings()) {
+			t.Errorf("expected %q in report implicits %q", xml, report.Implicits)
+		}
+	}
+}
+
+func TestCodeHealthReportErrorProne(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			owner: "foo_team",
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			lint: {
+				enabled: false,
+			},
+		}
+	`
+
+	config := testConfig(map[string]string{"RUN_ERROR_PRONE": "true"}, bp, nil)
+	ctx, _ := testJavaWithConfig(t, config)
+
+	singleton := ctx.SingletonForTests("code_health_report")
+	reportList := singleton.Output("code_health/lint-reports.txt")
+	report := singleton.Output("code_health/code-health-report.json")
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	fooXML := foo.Output("lint-report.xml").Output
+	errorprone := foo.Output("errorprone/findings.txt")
+	if errorprone.Description != "errorprone" {
+		t.Errorf("expected the findings to be written by errorprone, got %q", errorprone.Description)
+	}
+	fooFindings := errorprone.ImplicitOutputs[0]
+	if g, w := errorprone.Args["findings"], fooFindings.String(); g != w {
+		t.Errorf("expected errorprone findings arg %q, got %q", w, g)
+	}
+	barFindings := ctx.ModuleForTests("bar", "android_common").Output("errorprone/findings.txt").ImplicitOutputs[0]
+
+	// bar has lint disabled, but is still reported for its error-prone findings.
+	lines := strings.Split(android.ContentFromFileRuleForTests(t, reportList), "\n")
+	for _, expected := range []string{
+		"foo\tfoo_team\t.\t" + fooXML.String() + "\t" + fooFindings.String(),
+		"bar\t\t.\t\t" + barFindings.String(),
+	} {
+		if !android.InList(expected, lines) {
+			t.Errorf("expected %q in report list %q", expected, lines)
+		}
+	}
+
+	for _, findings := range []android.Path{fooFindings, barFindings} {
+		if !android.InList(findings.String(), report.Implicits.Strings()) {
+			t.Errorf("expected %q in report implicits %q", findings, report.Implicits)
+		}
+	}
+}
+
+func TestDeadCodeReport(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			optimize: {enabled: true},
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["b.java"],
+			sdk_version: "current",
+			optimize: {enabled: false},
+		}
+	`)
+
+	singleton := ctx.SingletonForTests("dead_code_report")
+	usageZip := singleton.Output("dead_code/proguard_usage.zip")
+	report := singleton.Output("dead_code/dead-code-report.txt")
+
+	fooUsage := ctx.ModuleForTests("foo", "android_common").Module().(*AndroidApp).proguardUsageZip.Path()
+	if g, w := usageZip.Inputs.Strings(), []string{fooUsage.String()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected proguard usage inputs %q, got %q", w, g)
+	}
+
+	if report.Input != usageZip.Output {
+		t.Errorf("expected dead code report input %q, got %q", usageZip.Output, report.Input)
+	}
+}
+
+func TestJavaDepsAudit(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			libs: ["bar"],
+			static_libs: ["baz"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+		}
+	`
+
+	config := testConfig(map[string]string{"SOONG_DEPS_AUDIT": "true"}, bp, nil)
+	ctx, _ := testJavaWithConfig(t, config)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	declared := android.ContentFromFileRuleForTests(t, foo.Output("deps_audit/declared.txt"))
+	for _, expected := range []string{
+		"libs bar " + moduleToPath("bar"),
+		"static_libs baz " + moduleToPath("baz"),
+	} {
+		if !strings.Contains(declared, expected+"\n") {
+			t.Errorf("expected %q in declared dependencies %q", expected, declared)
+		}
+	}
+
+	audit := foo.Rule("javaDepsAudit")
+	if g, w := audit.Inputs.Strings(), []string{foo.Output("javac/foo.jar").Output.String()}; !reflect.DeepEqual(g, w) {
+		t.Errorf("expected audited jars %q, got %q", w, g)
+	}
+	if !android.InList(moduleToPath("bar"), audit.Implicits.Strings()) {
+		t.Errorf("expected %q in audit implicits %q", moduleToPath("bar"), audit.Implicits)
+	}
+
+	report := ctx.SingletonForTests("java_deps_audit").Output("java_deps_audit.txt")
+	for _, name := range []string{"foo", "bar", "baz"} {
+		expected := ctx.ModuleForTests(name, "android_common").Output("deps_audit/report.txt").Output
+		if !android.InList(expected.String(), report.Inputs.Strings()) {
+			t.Errorf("expected %q in the audit report inputs %q", expected, report.Inputs)
+		}
+	}
+}
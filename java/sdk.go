@@ -222,7 +222,8 @@ func (s sdkSpec) usePrebuilt(ctx android.EarlyModuleContext) bool {
 		return ctx.Config().AlwaysUsePrebuiltSdks()
 	} else if s.version.isNumbered() {
 		// validation check
-		if s.kind != sdkPublic && s.kind != sdkSystem && s.kind != sdkTest && s.kind != sdkModule {
+		if s.kind != sdkPublic && s.kind != sdkSystem && s.kind != sdkTest && s.kind != sdkModule &&
+			s.kind != sdkSystemServer {
 			panic(fmt.Errorf("prebuilt SDK is not not available for sdkKind=%q", s.kind))
 			return false
 		}
@@ -351,6 +352,51 @@ func (s sdkSpec) validateSystemSdk(ctx android.EarlyModuleContext) bool {
 	return true
 }
 
+// hasFrameworkStubs returns true if the API surface of the SDK kind provides stubs of the framework,
+// which replace a dependency on the framework implementation library of modules built against it.
+func (k sdkKind) hasFrameworkStubs() bool {
+	switch k {
+	case sdkPublic, sdkSystem, sdkModule, sdkSystemServer:
+		return true
+	default:
+		return false
+	}
+}
+
+// prebuiltSdkFallbacks lists, for each SDK kind, the kinds whose prebuilt android.jar is used in
+// its place in unbundled builds when a version of prebuilts/sdk doesn't provide the jar for that
+// kind, e.g. because the API surface didn't exist yet when that version was finalized.  Platform
+// builds require the jar of the requested kind.
+var prebuiltSdkFallbacks = map[sdkKind][]sdkKind{
+	sdkSystemServer: {sdkModule, sdkSystem, sdkPublic},
+	sdkModule:       {sdkSystem, sdkPublic},
+	sdkSystem:       {sdkPublic},
+}
+
+// prebuiltSdkJar returns the prebuilt android.jar for the given sdkSpec, falling back in unbundled
+// builds to the closest narrower API surface if the jar of the requested kind doesn't exist. It
+// returns the kind of the jar it found along with its path, or the requested kind and an invalid
+// path if none of them exist.
+func prebuiltSdkJar(ctx android.EarlyModuleContext, s sdkSpec) (sdkKind, string, android.OptionalPath) {
+	jarFor := func(kind sdkKind) string {
+		return filepath.Join("prebuilts", "sdk", s.version.String(), kind.String(), "android.jar")
+	}
+
+	jar := jarFor(s.kind)
+	if jarPath := android.ExistentPathForSource(ctx, jar); jarPath.Valid() {
+		return s.kind, jar, jarPath
+	}
+	if !ctx.Config().UnbundledBuild() {
+		return s.kind, jar, android.OptionalPath{}
+	}
+	for _, kind := range prebuiltSdkFallbacks[s.kind] {
+		if jarPath := android.ExistentPathForSource(ctx, jarFor(kind)); jarPath.Valid() {
+			return kind, jarFor(kind), jarPath
+		}
+	}
+	return s.kind, jar, android.OptionalPath{}
+}
+
 func decodeSdkDep(ctx android.EarlyModuleContext, sdkContext sdkContext) sdkDep {
 	sdkVersion := sdkContext.sdkVersion()
 	if !sdkVersion.valid() {
@@ -367,20 +413,25 @@ func decodeSdkDep(ctx android.EarlyModuleContext, sdkContext sdkContext) sdkDep
 	}
 
 	if sdkVersion.usePrebuilt(ctx) {
-		dir := filepath.Join("prebuilts", "sdk", sdkVersion.version.String(), sdkVersion.kind.String())
-		jar := filepath.Join(dir, "android.jar")
+		kind, jar, jarPath := prebuiltSdkJar(ctx, sdkVersion)
 		// There's no aidl for other SDKs yet.
 		// TODO(77525052): Add aidl files for other SDKs too.
 		publicDir := filepath.Join("prebuilts", "sdk", sdkVersion.version.String(), "public")
 		aidl := filepath.Join(publicDir, "framework.aidl")
-		jarPath := android.ExistentPathForSource(ctx, jar)
 		aidlPath := android.ExistentPathForSource(ctx, aidl)
 		lambdaStubsPath := android.PathForSource(ctx, config.SdkLambdaStubsPath)
+		sdkJarModule := fmt.Sprintf("sdk_%s_%s_android", kind, sdkVersion.version.String())
+		// The jar of a narrower API surface doesn't provide the framework stubs of the requested one.
+		var frameworkStubs string
+		if kind == sdkVersion.kind && kind.hasFrameworkStubs() {
+			frameworkStubs = sdkJarModule
+		}
 
 		if (!jarPath.Valid() || !aidlPath.Valid()) && ctx.Config().AllowMissingDependencies() {
 			return sdkDep{
 				invalidVersion: true,
-				bootclasspath:  []string{fmt.Sprintf("sdk_%s_%s_android", sdkVersion.kind, sdkVersion.version.String())},
+				bootclasspath:  []string{sdkJarModule},
+				frameworkStubs: frameworkStubs,
 			}
 		}
 
@@ -400,15 +451,16 @@ func decodeSdkDep(ctx android.EarlyModuleContext, sdkContext sdkContext) sdkDep
 		}
 
 		return sdkDep{
-			useFiles:      true,
-			jars:          android.Paths{jarPath.Path(), lambdaStubsPath},
-			aidl:          android.OptionalPathForPath(aidlPath.Path()),
-			systemModules: systemModules,
+			useFiles:       true,
+			jars:           android.Paths{jarPath.Path(), lambdaStubsPath},
+			aidl:           android.OptionalPathForPath(aidlPath.Path()),
+			systemModules:  systemModules,
+			frameworkStubs: frameworkStubs,
 		}
 	}
 
 	toModule := func(modules []string, res string, aidl android.Path) sdkDep {
-		return sdkDep{
+		ret := sdkDep{
 			useModule:          true,
 			bootclasspath:      append(modules, config.DefaultLambdaStubsLibrary),
 			systemModules:      "core-current-stubs-system-modules",
@@ -416,6 +468,10 @@ func decodeSdkDep(ctx android.EarlyModuleContext, sdkContext sdkContext) sdkDep
 			frameworkResModule: res,
 			aidl:               android.OptionalPathForPath(aidl),
 		}
+		if sdkVersion.kind.hasFrameworkStubs() {
+			ret.frameworkStubs = modules[0]
+		}
+		return ret
 	}
 
 	switch sdkVersion.kind {
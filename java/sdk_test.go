@@ -231,6 +231,15 @@ func TestClasspath(t *testing.T) {
 			java9classpath: []string{"android_system_server_stubs_current"},
 			aidl:           "-p" + buildDir + "/framework.aidl",
 		},
+		{
+			name:           "system_server_30",
+			properties:     `sdk_version: "system_server_30",`,
+			bootclasspath:  []string{`""`},
+			system:         "sdk_public_30_system_modules",
+			java8classpath: []string{"prebuilts/sdk/30/system-server/android.jar", "prebuilts/sdk/tools/core-lambda-stubs.jar"},
+			java9classpath: []string{"prebuilts/sdk/30/system-server/android.jar", "prebuilts/sdk/tools/core-lambda-stubs.jar"},
+			aidl:           "-pprebuilts/sdk/30/public/framework.aidl",
+		},
 	}
 
 	for _, testcase := range classpathTestcases {
@@ -425,3 +434,90 @@ func TestClasspath(t *testing.T) {
 		})
 	}
 }
+
+func TestPrebuiltSdkFallback(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "system_server_15",
+		}
+	`
+	// The shared fixture provides every API surface of its levels, use one it doesn't have.
+	fs := map[string][]byte{
+		"prebuilts/sdk/15/public/android.jar":    nil,
+		"prebuilts/sdk/15/public/framework.aidl": nil,
+		"prebuilts/sdk/15/system/android.jar":    nil,
+	}
+
+	// Platform builds require the jar of the requested API surface.
+	testJavaErrorWithConfig(t, `sdk_version: invalid sdk version "system_server_15", "prebuilts/sdk/15/system-server/android.jar" does not exist`,
+		testConfig(nil, bp, fs))
+
+	config := testConfig(nil, bp, fs)
+	config.TestProductVariables.Unbundled_build = proptools.BoolPtr(true)
+	ctx, _ := testJavaWithConfig(t, config)
+
+	classpath := ctx.ModuleForTests("foo", "android_common").Rule("javac").Args["classpath"]
+	if w := "prebuilts/sdk/15/system/android.jar"; !strings.Contains(classpath, w) {
+		t.Errorf("expected the system SDK to be used in place of the missing system_server SDK, got %q", classpath)
+	}
+
+	// The system SDK doesn't provide the framework stubs of the system_server SDK.
+	config = testConfig(nil, strings.Replace(bp, `srcs: ["a.java"],`, `srcs: ["a.java"], libs: ["framework"],`, 1), fs)
+	config.TestProductVariables.Unbundled_build = proptools.BoolPtr(true)
+	testJavaErrorWithConfig(t, `libs: sdk_version "system_server_15" doesn't provide stubs of the framework`, config)
+}
+
+func TestFrameworkLibsRewrite(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "module_current",
+			libs: ["framework"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "module_30",
+			libs: ["framework"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["a.java"],
+			libs: ["framework"],
+		}
+
+		java_library {
+			name: "qux",
+			srcs: ["a.java"],
+			sdk_version: "test_current",
+			libs: ["framework"],
+		}
+	`)
+
+	testCases := []struct {
+		module   string
+		expected string
+	}{
+		{module: "foo", expected: moduleToPath("android_module_lib_stubs_current")},
+		{module: "bar", expected: "sdk_module-lib_30_android"},
+		{module: "baz", expected: moduleToPath("framework")},
+		// Only the public, system, module and system_server SDKs provide the framework stubs.
+		{module: "qux", expected: moduleToPath("framework")},
+	}
+
+	for _, testCase := range testCases {
+		classpath := ctx.ModuleForTests(testCase.module, "android_common").Rule("javac").Args["classpath"]
+		if !strings.Contains(classpath, testCase.expected) {
+			t.Errorf("%s: expected %q in classpath, got %q", testCase.module, testCase.expected, classpath)
+		}
+		if testCase.module != "baz" && testCase.module != "qux" && strings.Contains(classpath, moduleToPath("framework")) {
+			t.Errorf("%s: expected the framework implementation to not be in classpath, got %q",
+				testCase.module, classpath)
+		}
+	}
+}
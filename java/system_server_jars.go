@@ -0,0 +1,127 @@
+// Copyright 2021 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"android/soong/android"
+	"android/soong/dexpreopt"
+)
+
+func init() {
+	RegisterUpdatableSystemServerJarsComponents(android.InitRegistrationContext)
+}
+
+func RegisterUpdatableSystemServerJarsComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("updatable_system_server_jars", updatableSystemServerJarsSingletonFactory)
+}
+
+func updatableSystemServerJarsSingletonFactory() android.Singleton {
+	return &updatableSystemServerJarsSingleton{}
+}
+
+// updatableSystemServerJarsSingleton checks the configuration of the system server jars that are
+// loaded from APEXes, and collects the dexpreopt artifacts of their APEX variants, which are
+// installed in the system partition by Make.
+type updatableSystemServerJarsSingleton struct {
+	installs android.RuleBuilderInstalls
+}
+
+func (s *updatableSystemServerJarsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	config := ctx.Config()
+	global := dexpreopt.GetGlobalConfig(ctx)
+
+	// Populate a map from module name to APEX from the updatable system server jars. If there is
+	// a problem such as duplicate modules then fail and return immediately.
+	moduleToApex := make(map[string]string)
+	jars := global.UpdatableSystemServerJars
+	if !populateMapFromConfiguredJarList(ctx, moduleToApex, jars, "UpdatableSystemServerJars") {
+		return
+	}
+
+	for _, name := range android.SortedStringKeys(moduleToApex) {
+		if apex := moduleToApex[name]; apex == "platform" || apex == "system_ext" {
+			ctx.Errorf("updatable system server jar %q must be in an APEX, not in %q, or be listed in SystemServerJars", name, apex)
+		}
+		if android.InList(name, global.SystemServerJars) {
+			ctx.Errorf("system server jar %q is listed in both SystemServerJars and UpdatableSystemServerJars", name)
+		}
+	}
+
+	// Map from module name to the correct apex variant.
+	nameToApexVariant := make(map[string]android.Module)
+	// The system server jars that have a platform variant, and those that have a variant in an
+	// updatable APEX.
+	hasPlatformVariant := make(map[string]bool)
+	hasUpdatableApexVariant := make(map[string]bool)
+
+	ctx.VisitAllModules(func(module android.Module) {
+		name := ctx.ModuleName(module)
+		apexInfo := ctx.ModuleProvider(module, android.ApexInfoProvider).(android.ApexInfo)
+		if apex, ok := moduleToApex[name]; ok && apexInfo.InApex(apex) {
+			// The module name/apex variant should be unique in the system but double check
+			// just in case something has gone wrong.
+			if existing, ok := nameToApexVariant[name]; ok {
+				ctx.Errorf("found multiple variants matching %s:%s: %q and %q", apex, name, existing, module)
+			}
+			nameToApexVariant[name] = module
+		}
+		if android.InList(name, global.SystemServerJars) {
+			// The platform variant is always created, but it isn't installed when the module is not
+			// available to the platform.
+			if apexInfo.IsForPlatform() {
+				if am, ok := module.(android.ApexModule); !ok || !am.NotAvailableForPlatform() {
+					hasPlatformVariant[name] = true
+				}
+			} else if apexInfo.Updatable {
+				hasUpdatableApexVariant[name] = true
+			}
+		}
+	})
+
+	// A system server jar that is only built for an updatable APEX is loaded from the APEX, so it
+	// must be declared as updatable to be on the system server classpath at the right location.
+	for _, name := range global.SystemServerJars {
+		if hasUpdatableApexVariant[name] && !hasPlatformVariant[name] {
+			ctx.Errorf("system server jar %q is only in updatable APEXes, it must be listed in UpdatableSystemServerJars instead of SystemServerJars", name)
+		}
+	}
+
+	// If this is not an unbundled build and missing dependencies are not allowed
+	// then all the updatable system server jars listed must have been found.
+	strict := !config.UnbundledBuild() && !config.AllowMissingDependencies()
+
+	s.installs = nil
+	for i := 0; i < jars.Len(); i++ {
+		name := jars.Jar(i)
+		if _, ok := moduleToApex[name]; !ok {
+			continue
+		}
+		if apexVariant, ok := nameToApexVariant[name]; ok {
+			if dep, ok := apexVariant.(interface {
+				DexpreoptBuiltInstalledOnSystem() android.RuleBuilderInstalls
+			}); ok {
+				s.installs = append(s.installs, dep.DexpreoptBuiltInstalledOnSystem()...)
+			} else {
+				ctx.Errorf("module %q is of type %q which is not supported as an updatable system server jar", name, ctx.ModuleType(apexVariant))
+			}
+		} else if strict {
+			ctx.Errorf("could not find updatable system server jar %q in apex %q", name, jars.Apex(i))
+		}
+	}
+}
+
+func (s *updatableSystemServerJarsSingleton) MakeVars(ctx android.MakeVarsContext) {
+	ctx.Strict("DEXPREOPT_UPDATABLE_SYSTEM_SERVER_JARS_BUILT_INSTALLED", s.installs.String())
+}
@@ -65,10 +65,18 @@ func (l *linkerConfig) OutputFile() android.OutputPath {
 }
 
 func (l *linkerConfig) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if l.properties.Src == nil {
+		ctx.PropertyErrorf("src", "missing linker configuration file")
+		return
+	}
 	inputFile := android.PathForModuleSrc(ctx, android.String(l.properties.Src))
 	l.outputFilePath = android.PathForModuleOut(ctx, "linker.config.pb").OutputPath
 	l.installDirPath = android.PathForModuleInstall(ctx, "etc")
 	linkerConfigRule := android.NewRuleBuilder(pctx, ctx)
+	linkerConfigRule.Command().
+		BuiltTool("conv_linker_config").
+		Flag("validate").
+		FlagWithInput("-s ", inputFile)
 	linkerConfigRule.Command().
 		BuiltTool("conv_linker_config").
 		Flag("proto").
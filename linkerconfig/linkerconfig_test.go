@@ -19,6 +19,7 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -47,7 +48,7 @@ func TestMain(m *testing.M) {
 	os.Exit(run())
 }
 
-func testContext(t *testing.T, bp string) (*android.TestContext, android.Config) {
+func testPrepare(t *testing.T, bp string) (*android.TestContext, android.Config, []error) {
 	t.Helper()
 
 	fs := map[string][]byte{
@@ -63,6 +64,14 @@ func testContext(t *testing.T, bp string) (*android.TestContext, android.Config)
 	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
 	android.FailIfErrored(t, errs)
 	_, errs = ctx.PrepareBuildActions(config)
+
+	return ctx, config, errs
+}
+
+func testContext(t *testing.T, bp string) (*android.TestContext, android.Config) {
+	t.Helper()
+
+	ctx, config, errs := testPrepare(t, bp)
 	android.FailIfErrored(t, errs)
 
 	return ctx, config
@@ -125,3 +134,29 @@ func TestUninstallableLinkerConfig(t *testing.T) {
 		t.Errorf("LOCAL_UNINSTALLABLE_MODULE is not defined")
 	}
 }
+
+func TestLinkerConfigValidation(t *testing.T) {
+	ctx, _ := testContext(t, `
+	linker_config {
+		name: "linker-config-base",
+		src: "linker.config.json",
+	}
+	`)
+
+	rule := ctx.ModuleForTests("linker-config-base", "android_arm64_armv8-a").Rule("conv_linker_config")
+	cmd := rule.RuleParams.Command
+	validate := strings.Index(cmd, "conv_linker_config validate -s linker.config.json")
+	proto := strings.Index(cmd, "conv_linker_config proto -s linker.config.json")
+	if validate == -1 || proto == -1 || validate > proto {
+		t.Errorf("expected the linker configuration to be validated before it is converted, got %q", cmd)
+	}
+}
+
+func TestLinkerConfigMissingSrc(t *testing.T) {
+	_, _, errs := testPrepare(t, `
+	linker_config {
+		name: "linker-config-base",
+	}
+	`)
+	android.FailIfNoMatchingErrors(t, `src: missing linker configuration file`, errs)
+}
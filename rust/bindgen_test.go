@@ -17,6 +17,8 @@ package rust
 import (
 	"strings"
 	"testing"
+
+	"android/soong/android"
 )
 
 func TestRustBindgen(t *testing.T) {
@@ -73,6 +75,35 @@ func TestRustBindgen(t *testing.T) {
 	}
 }
 
+func TestRustBindgenGeneratedHeaders(t *testing.T) {
+	ctx := testRust(t, `
+		rust_bindgen {
+			name: "libbindgen",
+			wrapper_src: "src/any.h",
+			crate_name: "bindgen",
+			stem: "libbindgen",
+			source_stem: "bindings",
+			header_libs: ["libfoo_header"],
+		}
+		cc_library_headers {
+			name: "libfoo_header",
+			export_include_dirs: ["header_include"],
+			generated_headers: ["foo_gen_header"],
+			export_generated_headers: ["foo_gen_header"],
+		}
+		genrule {
+			name: "foo_gen_header",
+			cmd: "touch $(out)",
+			out: ["foo_gen.h"],
+		}
+	`)
+	libbindgen := ctx.ModuleForTests("libbindgen", "android_arm64_armv8-a_source").Output("bindings.rs")
+	// Ensure that the bindings are regenerated when a header exported by a header_libs dependency changes.
+	if !android.SuffixInList(libbindgen.Implicits.Strings(), "/out/foo_gen.h") {
+		t.Errorf("missing generated header in rust_bindgen rule implicits: %#v", libbindgen.Implicits.Strings())
+	}
+}
+
 func TestRustBindgenCustomBindgen(t *testing.T) {
 	ctx := testRust(t, `
 		rust_bindgen {
@@ -53,6 +53,10 @@ type TestProperties struct {
 
 	// Test options.
 	Test_options TestOptions
+
+	// list of files or filegroup modules that provide data that should be installed alongside
+	// the test
+	Data []string `android:"path,arch_variant"`
 }
 
 // A test module is a binary module with extra --test compiler flag
@@ -62,6 +66,7 @@ type testDecorator struct {
 	*binaryDecorator
 	Properties TestProperties
 	testConfig android.Path
+	data       []android.DataPath
 }
 
 func (test *testDecorator) nativeCoverage() bool {
@@ -98,6 +103,10 @@ func (test *testDecorator) compilerProps() []interface{} {
 }
 
 func (test *testDecorator) install(ctx ModuleContext) {
+	for _, dataSrcPath := range android.PathsForModuleSrc(ctx, test.Properties.Data) {
+		test.data = append(test.data, android.DataPath{SrcPath: dataSrcPath})
+	}
+
 	test.testConfig = tradefed.AutoGenRustTestConfig(ctx,
 		test.Properties.Test_config,
 		test.Properties.Test_config_template,
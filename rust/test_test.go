@@ -15,6 +15,7 @@
 package rust
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -67,3 +68,20 @@ func TestRustTestLinkage(t *testing.T) {
 		t.Errorf("Device rust_test module 'my_test' does not link libstd as an rlib")
 	}
 }
+
+func TestRustTestData(t *testing.T) {
+	ctx := testRust(t, `
+		rust_test_host {
+			name: "my_test",
+			srcs: ["foo.rs"],
+			data: ["data/foo.txt"],
+		}`)
+
+	testingModule := ctx.ModuleForTests("my_test", "linux_glibc_x86_64").Module().(*Module)
+	entries := android.AndroidMkEntriesForTest(t, ctx.Config(), "", testingModule)[0]
+
+	expected := []string{":data/foo.txt"}
+	if actual := entries.EntryMap["LOCAL_TEST_DATA"]; !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected LOCAL_TEST_DATA %q, got %q", expected, actual)
+	}
+}
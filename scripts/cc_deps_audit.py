@@ -0,0 +1,110 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Compares the declared dependencies of a cc module with the headers it uses.
+
+The headers used by the module are read from make-style dependency files
+generated by the compiler.  The declared dependencies are read from a file with
+one line per dependency, containing the dependency kind, the dependency name and
+the include directories that the dependency exports, separated by spaces.
+"""
+
+from __future__ import print_function
+
+import argparse
+import os
+import sys
+
+
+def parse_args():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--module', required=True,
+                      help='name and variant of the audited module')
+  parser.add_argument('--declared', required=True,
+                      help='file listing the declared dependencies')
+  parser.add_argument('--allowed', action='append', default=[],
+                      help='directory whose headers need no declared dependency')
+  parser.add_argument('-o', dest='output', required=True,
+                      help='report to write')
+  parser.add_argument('depfiles', nargs='*',
+                      help='dependency files generated by the compiler')
+  return parser.parse_args()
+
+
+def read_depfile(path):
+  """Returns the prerequisites listed in a make-style dependency file."""
+  with open(path) as f:
+    contents = f.read().replace('\\\n', ' ')
+  deps = []
+  for line in contents.splitlines():
+    _, sep, prereqs = line.partition(': ')
+    if not sep:
+      continue
+    deps.extend(prereqs.split())
+  return deps
+
+
+def read_declared(path):
+  declared = []
+  with open(path) as f:
+    for line in f:
+      fields = line.split()
+      if len(fields) < 2:
+        continue
+      dirs = [os.path.normpath(d) for d in fields[2:]]
+      declared.append((fields[0], fields[1], dirs))
+  return declared
+
+
+def is_under(path, directory):
+  return path == directory or path.startswith(directory + os.sep)
+
+
+def audit(declared, allowed, depfiles):
+  used = set()
+  undeclared = set()
+  for depfile in depfiles:
+    # The first prerequisite is the source file itself.
+    for header in read_depfile(depfile)[1:]:
+      header = os.path.normpath(header)
+      providers = [name for _, name, dirs in declared
+                   if any(is_under(header, d) for d in dirs)]
+      if providers:
+        used.update(providers)
+      elif not any(is_under(header, os.path.normpath(d)) for d in allowed):
+        undeclared.add(header)
+
+  unused = [(kind, name) for kind, name, dirs in declared
+            if dirs and name not in used]
+  return sorted(set(unused)), sorted(undeclared)
+
+
+def main():
+  args = parse_args()
+  unused, undeclared = audit(read_declared(args.declared), args.allowed,
+                             args.depfiles)
+  with open(args.output, 'w') as f:
+    if not unused and not undeclared:
+      return 0
+    print('%s:' % args.module, file=f)
+    for kind, name in unused:
+      print('  unused %s dependency: %s' % (kind, name), file=f)
+    for header in undeclared:
+      print('  header from undeclared dependency: %s' % header, file=f)
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -0,0 +1,107 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Checks that the imports of aidl sources resolve to declared locations.
+
+Each import must name a type declared by one of the sources themselves, by one
+of the preprocessed files (e.g. the framework.aidl of the SDK), or by a .aidl
+file in one of the given include directories.  The include directories are the
+directories declared by the module and exported by its dependencies, so an
+import that the aidl compiler would otherwise find through an implicit include
+directory, like the directory of the module, is reported.
+"""
+
+import argparse
+import os
+import re
+import sys
+
+PACKAGE = re.compile(r'^\s*package\s+([\w.]+)\s*;', re.MULTILINE)
+IMPORT = re.compile(r'^\s*import\s+([\w.]+)\s*;', re.MULTILINE)
+# The preprocessed files declare one type per line, e.g. "parcelable android.os.Bundle;".
+PREPROCESSED = re.compile(r'^\s*\w+\s+([\w.]+)\s*;', re.MULTILINE)
+COMMENT = re.compile(r'//[^\n]*|/\*.*?\*/', re.DOTALL)
+
+
+def parse_args():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--preprocessed', action='append', default=[],
+                      help='preprocessed file declaring importable types')
+  parser.add_argument('-I', dest='include_dirs', action='append', default=[],
+                      help='declared include directory')
+  parser.add_argument('--stamp', required=True,
+                      help='file to touch when the check passes')
+  parser.add_argument('srcs', nargs='+', help='aidl sources to check')
+  return parser.parse_args()
+
+
+def read(path):
+  with open(path) as f:
+    return COMMENT.sub('', f.read())
+
+
+def declared_type(path, content):
+  """Returns the fully qualified name of the type declared by an aidl source."""
+  name = os.path.splitext(os.path.basename(path))[0]
+  package = PACKAGE.search(content)
+  return package.group(1) + '.' + name if package else name
+
+
+def resolves(name, types, include_dirs):
+  # The import may name a nested type, e.g. a.b.C.Inner is declared in a/b/C.aidl.
+  parts = name.split('.')
+  for i in range(len(parts), 0, -1):
+    if '.'.join(parts[:i]) in types:
+      return True
+    rel = os.path.join(*parts[:i]) + '.aidl'
+    if any(os.path.isfile(os.path.join(d, rel)) for d in include_dirs):
+      return True
+  return False
+
+
+def check(srcs, preprocessed, include_dirs):
+  """Returns the (source, import) pairs that don't resolve."""
+  contents = dict((src, read(src)) for src in srcs)
+  types = set(declared_type(src, content) for src, content in contents.items())
+  for path in preprocessed:
+    types.update(PREPROCESSED.findall(read(path)))
+
+  errors = []
+  for src in srcs:
+    for name in IMPORT.findall(contents[src]):
+      if not resolves(name, types, include_dirs):
+        errors.append((src, name))
+  return errors
+
+
+def main():
+  args = parse_args()
+  errors = check(args.srcs, args.preprocessed, args.include_dirs)
+  if errors:
+    for src, name in errors:
+      print('%s: import %s does not resolve to a source of the module, a '
+            'directory in aidl.local_include_dirs or aidl.export_include_dirs '
+            'or a directory exported by a dependency' % (src, name),
+            file=sys.stderr)
+    print('aidl.strict_imports is set, declare a dependency that exports the '
+          'imported types', file=sys.stderr)
+    return 1
+  with open(args.stamp, 'w'):
+    pass
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -19,6 +19,7 @@ import argparse
 import collections
 import json
 import os
+import sys
 
 import linker_config_pb2
 from google.protobuf.descriptor import FieldDescriptor
@@ -26,18 +27,48 @@ from google.protobuf.json_format import ParseDict
 from google.protobuf.text_format import MessageToString
 
 
-def Proto(args):
+def LoadJsonMessage(path):
   json_content = ''
-  with open(args.source) as f:
+  with open(path) as f:
     for line in f:
       if not line.lstrip().startswith('//'):
         json_content += line
   obj = json.loads(json_content, object_pairs_hook=collections.OrderedDict)
-  pb = ParseDict(obj, linker_config_pb2.LinkerConfig())
+  return ParseDict(obj, linker_config_pb2.LinkerConfig())
+
+
+def Proto(args):
+  pb = LoadJsonMessage(args.source)
   with open(args.output, 'wb') as f:
     f.write(pb.SerializeToString())
 
 
+def Validate(args):
+  """Checks the values in the JSON configuration that the schema alone can't."""
+  pb = LoadJsonMessage(args.source)
+  errors = []
+
+  for path in pb.permittedPaths:
+    if not path.startswith('/'):
+      errors.append('permittedPaths: %r is not an absolute path' % path)
+
+  for key in ('provideLibs', 'requireLibs'):
+    seen = set()
+    for lib in getattr(pb, key):
+      if '/' in lib:
+        errors.append('%s: %r must be a library name, not a path' % (key, lib))
+      if lib in seen:
+        errors.append('%s: %r is listed more than once' % (key, lib))
+      seen.add(lib)
+
+  for lib in sorted(set(pb.provideLibs) & set(pb.requireLibs)):
+    errors.append('%r is listed in both provideLibs and requireLibs' % lib)
+
+  if errors:
+    sys.exit('%s: invalid linker configuration:\n  %s' %
+             (args.source, '\n  '.join(errors)))
+
+
 def Print(args):
   with open(args.source, 'rb') as f:
     pb = linker_config_pb2.LinkerConfig()
@@ -99,6 +130,16 @@ def GetArgParser():
       help='Target path to create protobuf file.')
   parser_proto.set_defaults(func=Proto)
 
+  validate = subparsers.add_parser(
+      'validate', help='Check the input JSON configuration file for invalid values.')
+  validate.add_argument(
+      '-s',
+      '--source',
+      required=True,
+      type=str,
+      help='Source linker configuration file in JSON.')
+  validate.set_defaults(func=Validate)
+
   print_proto = subparsers.add_parser(
       'print', help='Print configuration in human-readable text format.')
   print_proto.add_argument(
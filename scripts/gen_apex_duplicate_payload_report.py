@@ -0,0 +1,110 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Reports files duplicated across APEXes and the platform image.
+
+The input lists one file per line as <location>\t<path>\t<file>, where the
+location is the name of the APEX the file is packaged into or "platform", the
+path is where the file ends up in that location, and the file is the path of its
+content in the build tree.  Files are compared by content, and every file that
+is found in more than one APEX, or in both an APEX and the platform, is reported
+with the number of bytes its extra copies cost.
+"""
+
+import argparse
+import collections
+import hashlib
+import os
+import sys
+
+PLATFORM = 'platform'
+
+
+def parse_args():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('-o', '--output', required=True,
+                      help='path to the report to write')
+  parser.add_argument('files', metavar='FILE_LIST',
+                      help='list of the files in the APEXes and the platform')
+  return parser.parse_args()
+
+
+def sha256(path):
+  h = hashlib.sha256()
+  with open(path, 'rb') as f:
+    for chunk in iter(lambda: f.read(1 << 20), b''):
+      h.update(chunk)
+  return h.hexdigest()
+
+
+def find_duplicates(entries):
+  """Groups (location, path, file) entries by content and returns the groups
+  that are duplicated across locations, as (wasted bytes, size, digest,
+  [(location, path)]) tuples sorted by decreasing cost."""
+  by_digest = collections.defaultdict(set)
+  sizes = {}
+  for location, path, f in entries:
+    digest = sha256(f)
+    by_digest[digest].add((location, path))
+    sizes[digest] = os.path.getsize(f)
+
+  duplicates = []
+  for digest, copies in by_digest.items():
+    locations = set(location for location, _ in copies)
+    if len(locations) < 2 or locations == set([PLATFORM]):
+      continue
+    size = sizes[digest]
+    duplicates.append((size * (len(copies) - 1), size, digest, sorted(copies)))
+  duplicates.sort(key=lambda d: (-d[0], d[2]))
+  return duplicates
+
+
+def read_entries(file_list):
+  entries = []
+  with open(file_list) as f:
+    for line in f:
+      line = line.rstrip('\n')
+      if not line:
+        continue
+      fields = line.split('\t')
+      if len(fields) != 3:
+        raise ValueError('expected LOCATION\\tPATH\\tFILE, got %r' % line)
+      entries.append(tuple(fields))
+  return entries
+
+
+def main():
+  args = parse_args()
+  try:
+    duplicates = find_duplicates(read_entries(args.files))
+  except (IOError, OSError, ValueError) as e:
+    print('error: %s' % e, file=sys.stderr)
+    return 1
+
+  with open(args.output, 'w') as f:
+    total = 0
+    for wasted, size, digest, copies in duplicates:
+      total += wasted
+      f.write('%d bytes wasted by %d copies of %s (%d bytes)\n' %
+              (wasted, len(copies), digest, size))
+      for location, path in copies:
+        f.write('    %s:%s\n' % (location, path))
+    f.write('total: %d bytes wasted by %d duplicated files\n' %
+            (total, len(duplicates)))
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -0,0 +1,77 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Generates the build time equivalent of apexd's apex-info-list.xml.
+
+Each APEX is passed as <apex_manifest.json>:<path on device>.  The name and
+version of the APEX are read from its manifest, and all APEXes are reported as
+factory installed and active, which is what apexd reports on a freshly flashed
+device.
+"""
+
+from __future__ import print_function
+
+import argparse
+import json
+import sys
+from xml.sax.saxutils import quoteattr
+
+
+def parse_args():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('-o', '--output', required=True,
+                      help='path to the apex-info-list.xml to write')
+  parser.add_argument('apexes', nargs='*', metavar='MANIFEST:PATH',
+                      help='apex_manifest.json of an APEX and its path on the device')
+  return parser.parse_args()
+
+
+def apex_info(manifest_path, device_path):
+  with open(manifest_path) as f:
+    manifest = json.load(f)
+  attrs = [
+      ('moduleName', manifest['name']),
+      ('modulePath', device_path),
+      ('preinstalledModulePath', device_path),
+      ('versionCode', str(manifest.get('version', 0))),
+      ('versionName', manifest.get('versionName', '')),
+      ('isFactory', 'true'),
+      ('isActive', 'true'),
+  ]
+  return '    <apex-info %s/>' % ' '.join(
+      '%s=%s' % (k, quoteattr(v)) for k, v in attrs)
+
+
+def main():
+  args = parse_args()
+  entries = []
+  for apex in args.apexes:
+    manifest_path, sep, device_path = apex.partition(':')
+    if not sep:
+      print('error: expected MANIFEST:PATH, got %s' % apex, file=sys.stderr)
+      return 1
+    entries.append(apex_info(manifest_path, device_path))
+
+  with open(args.output, 'w') as f:
+    f.write('<?xml version="1.0" encoding="utf-8"?>\n')
+    f.write('<apex-info-list>\n')
+    for entry in sorted(entries):
+      f.write(entry + '\n')
+    f.write('</apex-info-list>\n')
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
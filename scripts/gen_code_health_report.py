@@ -0,0 +1,121 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Aggregates the lint and error-prone findings of all modules into a report.
+
+The input lists one module per line as
+<name>\t<owner>\t<dir>\t<lint xml>\t<error-prone findings>, where a tool that
+didn't run on the module has an empty field.  The report is a JSON object with
+one entry per module, holding its owner, its directory and the issues found by
+lint and error-prone, so that dashboards can ingest the findings of the whole
+tree from a single file.
+"""
+
+import argparse
+import json
+import re
+import sys
+import xml.etree.ElementTree as ET
+
+
+def parse_args():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('-o', '--output', required=True,
+                      help='path to the report to write')
+  parser.add_argument('reports', metavar='REPORT_LIST',
+                      help='list of the modules and their lint and '
+                      'error-prone reports')
+  return parser.parse_args()
+
+
+def lint_findings(path):
+  findings = []
+  for issue in ET.parse(path).getroot().iter('issue'):
+    finding = {
+        'tool': 'lint',
+        'id': issue.get('id', ''),
+        'severity': issue.get('severity', ''),
+        'category': issue.get('category', ''),
+        'message': issue.get('message', ''),
+    }
+    location = issue.find('location')
+    if location is not None:
+      finding['file'] = location.get('file', '')
+      if location.get('line'):
+        finding['line'] = int(location.get('line'))
+    findings.append(finding)
+  return findings
+
+
+# javac diagnostics look like "<file>:<line>: warning: [<check>] <message>",
+# followed by the source line and a caret, which are skipped.
+ERRORPRONE_DIAGNOSTIC = re.compile(
+    r'^(?P<file>.+?):(?P<line>\d+): (?P<severity>error|warning): '
+    r'\[(?P<id>\w+)\] (?P<message>.*)$')
+
+
+def errorprone_findings(path):
+  findings = []
+  with open(path) as f:
+    for line in f:
+      match = ERRORPRONE_DIAGNOSTIC.match(line.rstrip('\n'))
+      if not match:
+        continue
+      findings.append({
+          'tool': 'errorprone',
+          'id': match.group('id'),
+          'severity': match.group('severity'),
+          'message': match.group('message'),
+          'file': match.group('file'),
+          'line': int(match.group('line')),
+      })
+  return findings
+
+
+def main():
+  args = parse_args()
+  modules = []
+  with open(args.reports) as f:
+    for line in f:
+      line = line.rstrip('\n')
+      if not line:
+        continue
+      fields = line.split('\t')
+      if len(fields) != 5:
+        print('error: expected NAME\\tOWNER\\tDIR\\tLINT_XML\\tERRORPRONE, '
+              'got %r' % line, file=sys.stderr)
+        return 1
+      name, owner, path, lint_xml, errorprone = fields
+      findings = []
+      if lint_xml:
+        findings.extend(lint_findings(lint_xml))
+      if errorprone:
+        findings.extend(errorprone_findings(errorprone))
+      modules.append({
+          'name': name,
+          'owner': owner,
+          'path': path,
+          'findings': findings,
+      })
+
+  modules.sort(key=lambda m: m['name'])
+  with open(args.output, 'w') as f:
+    json.dump({'modules': modules}, f, indent=2, sort_keys=True)
+    f.write('\n')
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
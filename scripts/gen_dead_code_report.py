@@ -0,0 +1,86 @@
+#!/usr/bin/env python3
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Summarizes the code removed by R8 across the system image.
+
+The input is a zip of the R8 -printusage outputs of the optimized modules, with
+one <module path>/unused.txt entry per module.  The report lists, for each
+module, how many classes R8 removed entirely and how many members it removed
+from the classes it kept, sorted by decreasing amount of removed code.
+"""
+
+import argparse
+import sys
+import zipfile
+
+USAGE_FILE = 'unused.txt'
+
+
+def parse_args():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('-o', '--output', required=True,
+                      help='path to the report to write')
+  parser.add_argument('usage_zip', metavar='USAGE_ZIP',
+                      help='zip of the R8 usage outputs of all modules')
+  return parser.parse_args()
+
+
+def count_unused(lines):
+  """Returns the number of unused classes and unused members in the output of
+  R8 -printusage.  Unused classes are listed on their own line, unused members
+  are listed indented below the name of their class followed by a colon."""
+  classes = 0
+  members = 0
+  for line in lines:
+    if not line.strip():
+      continue
+    if line[0].isspace():
+      members += 1
+    elif not line.rstrip().endswith(':'):
+      classes += 1
+  return classes, members
+
+
+def build_report(usage_zip):
+  modules = []
+  with zipfile.ZipFile(usage_zip) as z:
+    for name in z.namelist():
+      if name.split('/')[-1] != USAGE_FILE:
+        continue
+      module = name[:-len(USAGE_FILE)].rstrip('/')
+      lines = z.read(name).decode('utf-8').splitlines()
+      classes, members = count_unused(lines)
+      modules.append((module, classes, members))
+  modules.sort(key=lambda m: (-(m[1] + m[2]), m[0]))
+  return modules
+
+
+def main():
+  args = parse_args()
+  modules = build_report(args.usage_zip)
+  with open(args.output, 'w') as f:
+    f.write('# module\tunused_classes\tunused_members\n')
+    total_classes = 0
+    total_members = 0
+    for module, classes, members in modules:
+      total_classes += classes
+      total_members += members
+      f.write('%s\t%d\t%d\n' % (module, classes, members))
+    f.write('total\t%d\t%d\n' % (total_classes, total_members))
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -0,0 +1,186 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Compares the declared dependencies of a java module with the classes it uses.
+
+The classes used by the module are read from the constant pools of its compiled
+classes, and attributed to the first jar of the classpath that contains them.
+The declared dependencies are read from a file with one line per dependency,
+containing the dependency kind (libs, static_libs or sdk), the dependency name
+and its header jars, separated by spaces.  The classpath is read from a file
+with one jar per line.
+"""
+
+from __future__ import print_function
+
+import argparse
+import re
+import struct
+import sys
+import zipfile
+
+
+def parse_args():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--module', required=True,
+                      help='name and variant of the audited module')
+  parser.add_argument('--declared', required=True,
+                      help='file listing the declared dependencies')
+  parser.add_argument('--classpath', required=True,
+                      help='file listing the classpath of the module')
+  parser.add_argument('-o', dest='output', required=True,
+                      help='report to write')
+  parser.add_argument('jars', nargs='*',
+                      help='jars of the classes compiled from the module')
+  return parser.parse_args()
+
+
+# Sizes of the constant pool entries other than CONSTANT_Utf8, by tag.
+CONSTANT_SIZES = {
+    3: 4,  # Integer
+    4: 4,  # Float
+    5: 8,  # Long
+    6: 8,  # Double
+    7: 2,  # Class
+    8: 2,  # String
+    9: 4,  # Fieldref
+    10: 4,  # Methodref
+    11: 4,  # InterfaceMethodref
+    12: 4,  # NameAndType
+    15: 3,  # MethodHandle
+    16: 2,  # MethodType
+    17: 4,  # Dynamic
+    18: 4,  # InvokeDynamic
+    19: 2,  # Module
+    20: 2,  # Package
+}
+
+DESCRIPTOR_CLASS = re.compile(r'L([\w/$]+);')
+
+
+def referenced_classes(data):
+  """Returns the names of the classes referenced by a class file."""
+  count, = struct.unpack_from('>H', data, 8)
+  offset = 10
+  utf8 = {}
+  class_indexes = []
+  index = 1
+  while index < count:
+    tag = bytearray(data[offset:offset + 1])[0]
+    offset += 1
+    if tag == 1:
+      length, = struct.unpack_from('>H', data, offset)
+      utf8[index] = data[offset + 2:offset + 2 + length].decode('utf-8', 'replace')
+      offset += 2 + length
+    elif tag in CONSTANT_SIZES:
+      if tag == 7:
+        class_indexes.append(struct.unpack_from('>H', data, offset)[0])
+      offset += CONSTANT_SIZES[tag]
+    else:
+      raise ValueError('unknown constant pool tag %d' % tag)
+    # Long and Double constants take two entries.
+    index += 2 if tag in (5, 6) else 1
+
+  classes = set()
+  for i in class_indexes:
+    name = utf8.get(i, '')
+    if name.startswith('['):
+      classes.update(DESCRIPTOR_CLASS.findall(name))
+    elif name:
+      classes.add(name)
+  # Field and method descriptors and generic signatures reference classes too.
+  for value in utf8.values():
+    classes.update(DESCRIPTOR_CLASS.findall(value))
+  return classes
+
+
+def class_names(jar):
+  with zipfile.ZipFile(jar) as z:
+    return [n[:-len('.class')] for n in z.namelist() if n.endswith('.class')]
+
+
+def used_classes(jars):
+  """Returns the classes referenced by the classes in jars, but not defined in them."""
+  defined = set()
+  used = set()
+  for jar in jars:
+    with zipfile.ZipFile(jar) as z:
+      for name in z.namelist():
+        if name.endswith('.class'):
+          defined.add(name[:-len('.class')])
+          used.update(referenced_classes(z.read(name)))
+  return used - defined
+
+
+def read_declared(path):
+  declared = []
+  with open(path) as f:
+    for line in f:
+      fields = line.split()
+      if len(fields) < 2:
+        continue
+      declared.append((fields[0], fields[1], fields[2:]))
+  return declared
+
+
+def read_classpath(path):
+  with open(path) as f:
+    return [line.strip() for line in f if line.strip()]
+
+
+def audit(declared, classpath, used):
+  providers = {}
+  for jar in classpath:
+    for name in class_names(jar):
+      providers.setdefault(name, jar)
+
+  used_jars = {}
+  for name in sorted(used):
+    jar = providers.get(name)
+    if jar:
+      used_jars.setdefault(jar, name)
+
+  declared_jars = set()
+  unused = set()
+  for kind, name, jars in declared:
+    declared_jars.update(jars)
+    # The libraries implied by the sdk version can't be removed.
+    if kind != 'sdk' and jars and not any(jar in used_jars for jar in jars):
+      unused.add((kind, name))
+
+  undeclared = sorted((jar, name) for jar, name in used_jars.items()
+                      if jar not in declared_jars)
+  return sorted(unused), undeclared
+
+
+def main():
+  args = parse_args()
+  unused, undeclared = audit(read_declared(args.declared),
+                             read_classpath(args.classpath),
+                             used_classes(args.jars))
+  with open(args.output, 'w') as f:
+    if not unused and not undeclared:
+      return 0
+    print('%s:' % args.module, file=f)
+    for kind, name in unused:
+      print('  unused %s dependency: %s' % (kind, name), file=f)
+    for jar, name in undeclared:
+      print('  classes from undeclared dependency: %s (e.g. %s)' % (jar, name),
+            file=f)
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -0,0 +1,202 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""A tool for indexing test classes and methods by their filtering annotations.
+
+The index is generated from the compiled classes of a test module, so that test
+schedulers can select flaky, presubmit or postsubmit tests without having to
+dexdump the test APK.
+"""
+
+from __future__ import print_function
+
+import argparse
+import json
+import struct
+import sys
+import zipfile
+
+# Maps the simple name of a filtering annotation to its key in the index.  The
+# package of the annotation is ignored, so that both androidx.test.filters and
+# android.platform.test.annotations variants are recognized.
+ANNOTATIONS = {
+  'FlakyTest': 'flaky',
+  'Presubmit': 'presubmit',
+  'Postsubmit': 'postsubmit',
+}
+
+ANNOTATION_ATTRIBUTES = ('RuntimeVisibleAnnotations',
+                         'RuntimeInvisibleAnnotations')
+
+
+def parse_args(args):
+  """Parse commandline arguments."""
+  parser = argparse.ArgumentParser()
+  parser.add_argument('--jar', required=True,
+    help='jar file containing the compiled classes of the test module')
+  parser.add_argument('--out', required=True,
+    help='path of the generated JSON index')
+  return parser.parse_args(args)
+
+
+class ClassReader(object):
+  """Reads the parts of a class file needed to find its annotations."""
+
+  def __init__(self, data):
+    self.data = data
+    self.pos = 0
+
+  def u1(self):
+    self.pos += 1
+    return struct.unpack_from('>B', self.data, self.pos - 1)[0]
+
+  def u2(self):
+    self.pos += 2
+    return struct.unpack_from('>H', self.data, self.pos - 2)[0]
+
+  def u4(self):
+    self.pos += 4
+    return struct.unpack_from('>I', self.data, self.pos - 4)[0]
+
+  def skip(self, n):
+    self.pos += n
+
+
+# Sizes of the constant pool entries that are skipped, indexed by tag.
+CONSTANT_SIZES = {
+  3: 4, 4: 4, 5: 8, 6: 8, 7: 2, 8: 2, 9: 4, 10: 4, 11: 4, 12: 4, 15: 3,
+  16: 2, 17: 4, 18: 4, 19: 2, 20: 2,
+}
+
+
+def read_constant_pool(reader):
+  utf8 = {}
+  classes = {}
+  count = reader.u2()
+  i = 1
+  while i < count:
+    tag = reader.u1()
+    if tag == 1:
+      length = reader.u2()
+      utf8[i] = reader.data[reader.pos:reader.pos + length].decode(
+        'utf-8', 'replace')
+      reader.skip(length)
+    elif tag == 7:
+      classes[i] = reader.u2()
+    elif tag in CONSTANT_SIZES:
+      reader.skip(CONSTANT_SIZES[tag])
+    else:
+      raise ValueError('unknown constant pool tag %d' % tag)
+    # Long and double constants take two entries in the constant pool.
+    i += 2 if tag in (5, 6) else 1
+  return utf8, classes
+
+
+def skip_element_value(reader):
+  tag = chr(reader.u1())
+  if tag == 'e':
+    reader.skip(4)
+  elif tag == '@':
+    skip_annotation(reader)
+  elif tag == '[':
+    for _ in range(reader.u2()):
+      skip_element_value(reader)
+  else:
+    reader.skip(2)
+
+
+def skip_annotation(reader):
+  reader.skip(2)
+  for _ in range(reader.u2()):
+    reader.skip(2)
+    skip_element_value(reader)
+
+
+def read_annotations(reader, utf8):
+  """Reads the attributes of a class or method and returns its annotation
+  descriptors."""
+  annotations = []
+  for _ in range(reader.u2()):
+    name = utf8.get(reader.u2())
+    length = reader.u4()
+    end = reader.pos + length
+    if name in ANNOTATION_ATTRIBUTES:
+      for _ in range(reader.u2()):
+        annotations.append(utf8.get(reader.u2()))
+        for _ in range(reader.u2()):
+          reader.skip(2)
+          skip_element_value(reader)
+    reader.pos = end
+  return annotations
+
+
+def index_keys(descriptors):
+  keys = []
+  for descriptor in descriptors:
+    simple_name = descriptor.rstrip(';').split('/')[-1].split('$')[-1]
+    if simple_name in ANNOTATIONS:
+      keys.append(ANNOTATIONS[simple_name])
+  return keys
+
+
+def index_class(data, index):
+  """Adds the annotated class and methods of a class file to the index."""
+  reader = ClassReader(data)
+  if reader.u4() != 0xCAFEBABE:
+    raise ValueError('not a class file')
+  reader.skip(4)
+  utf8, classes = read_constant_pool(reader)
+  reader.skip(2)
+  class_name = utf8[classes[reader.u2()]].replace('/', '.')
+  reader.skip(2)
+  reader.skip(2 * reader.u2())
+
+  # Fields can't be annotated as tests, but still need to be read past.
+  for _ in range(reader.u2()):
+    reader.skip(6)
+    read_annotations(reader, utf8)
+
+  for _ in range(reader.u2()):
+    reader.skip(2)
+    method_name = utf8[reader.u2()]
+    reader.skip(2)
+    for key in index_keys(read_annotations(reader, utf8)):
+      index[key].add(class_name + '#' + method_name)
+
+  for key in index_keys(read_annotations(reader, utf8)):
+    index[key].add(class_name)
+
+
+def build_index(jar):
+  index = dict((key, set()) for key in ANNOTATIONS.values())
+  with zipfile.ZipFile(jar) as z:
+    for name in z.namelist():
+      if name.endswith('.class'):
+        index_class(z.read(name), index)
+  return dict((key, sorted(value)) for key, value in index.items())
+
+
+def main():
+  """Program entry point."""
+  args = parse_args(sys.argv[1:])
+  index = build_index(args.jar)
+  with open(args.out, 'w') as f:
+    json.dump(index, f, indent=2, sort_keys=True)
+    f.write('\n')
+
+
+if __name__ == '__main__':
+  main()
@@ -0,0 +1,113 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2021 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for test_annotation_index.py."""
+
+import io
+import struct
+import sys
+import unittest
+import zipfile
+
+# Set before importing the module under test, so that running the test doesn't
+# leave its bytecode next to the sources.
+sys.dont_write_bytecode = True
+
+import test_annotation_index as tai  # pylint: disable=wrong-import-position
+
+
+class ClassWriter(object):
+  """Writes a minimal class file with annotated methods."""
+
+  def __init__(self):
+    self.constants = []
+
+  def utf8(self, s):
+    data = s.encode('utf-8')
+    self.constants.append(struct.pack('>BH', 1, len(data)) + data)
+    return len(self.constants)
+
+  def cls(self, name):
+    index = self.utf8(name)
+    self.constants.append(struct.pack('>BH', 7, index))
+    return len(self.constants)
+
+  def annotations(self, descriptors):
+    attr = self.utf8('RuntimeVisibleAnnotations')
+    body = struct.pack('>H', len(descriptors))
+    for descriptor in descriptors:
+      # Annotations with a value, to check that element values are skipped.
+      body += struct.pack('>HHHBH', self.utf8(descriptor), 1,
+                          self.utf8('bugId'), ord('J'), self.utf8('unused'))
+    return struct.pack('>HHI', 1, attr, len(body)) + body
+
+  def write(self, name, class_annotations, methods):
+    this_class = self.cls(name)
+    super_class = self.cls('java/lang/Object')
+    method_data = b''
+    for method_name, descriptors in methods:
+      method_data += struct.pack('>HHH', 1, self.utf8(method_name),
+                                 self.utf8('()V'))
+      method_data += self.annotations(descriptors)
+    class_attrs = self.annotations(class_annotations)
+
+    # Include a long constant, which takes two constant pool entries.
+    self.constants.append(struct.pack('>Bq', 5, 0))
+    self.constants.append(b'')
+
+    data = struct.pack('>IHHH', 0xCAFEBABE, 0, 52, len(self.constants) + 1)
+    data += b''.join(self.constants)
+    data += struct.pack('>HHHHH', 1, this_class, super_class, 0, 0)
+    data += struct.pack('>H', len(methods)) + method_data
+    data += class_attrs
+    return data
+
+
+def make_jar(classes):
+  buf = io.BytesIO()
+  with zipfile.ZipFile(buf, 'w') as z:
+    for name, class_annotations, methods in classes:
+      z.writestr(name + '.class',
+                 ClassWriter().write(name, class_annotations, methods))
+  buf.seek(0)
+  return buf
+
+
+class TestAnnotationIndexTest(unittest.TestCase):
+  def test_build_index(self):
+    jar = make_jar([
+      ('com/android/FooTest', ['Landroid/platform/test/annotations/Presubmit;'], [
+        ('testA', ['Landroidx/test/filters/FlakyTest;']),
+        ('testB', ['Lorg/junit/Test;']),
+      ]),
+      ('com/android/BarTest', [], [
+        ('testC', ['Landroid/platform/test/annotations/Postsubmit;',
+                   'Landroidx/test/filters/FlakyTest;']),
+      ]),
+    ])
+    self.assertEqual(tai.build_index(jar), {
+      'flaky': ['com.android.BarTest#testC', 'com.android.FooTest#testA'],
+      'presubmit': ['com.android.FooTest'],
+      'postsubmit': ['com.android.BarTest#testC'],
+    })
+
+  def test_not_a_class(self):
+    with self.assertRaises(ValueError):
+      tai.index_class(b'\x00\x00\x00\x00', {})
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -800,7 +800,7 @@ func TestSnapshotWithSingleHostOsType(t *testing.T) {
 			],
 			stl: "none",
 		}
-	`, ccTestFs, []android.OsType{android.LinuxBionic})
+	`, nil, ccTestFs, []android.OsType{android.LinuxBionic})
 
 	result := runTests(t, ctx, config)
 
@@ -69,3 +69,18 @@ module_exports_snapshot {
 }
 `))
 }
+
+// Ensure that the member types that are only usable with module_exports, e.g. tests and host
+// tools, cannot be added to an sdk, whose members make up an API surface.
+func TestSnapshotOnlyMemberTypesAreNotUsableWithSdk(t *testing.T) {
+	for _, property := range []string{"java_tests", "java_libs", "native_binaries"} {
+		t.Run(property, func(t *testing.T) {
+			testSdkError(t, `unrecognized property "`+property+`"`, `
+				sdk {
+					name: "mysdk",
+					`+property+`: ["mymember"],
+				}
+			`)
+		})
+	}
+}
@@ -17,6 +17,7 @@ package sdk
 import (
 	"testing"
 
+	"android/soong/android"
 	"android/soong/java"
 )
 
@@ -287,6 +288,68 @@ aidl/foo/bar/Test.aidl -> aidl/aidl/foo/bar/Test.aidl
 	)
 }
 
+func TestVersionedSnapshot(t *testing.T) {
+	result := testSdkWithEnv(t, `
+		sdk {
+			name: "mysdk",
+			java_header_libs: ["myjavalib"],
+		}
+
+		java_library {
+			name: "myjavalib",
+			srcs: ["Test.java"],
+			system_modules: "none",
+			sdk_version: "none",
+			compile_dex: true,
+			host_supported: true,
+		}
+	`, map[string]string{"SOONG_SDK_SNAPSHOT_VERSION": "2"})
+
+	result.CheckSnapshot("mysdk", "",
+		checkAndroidBpContents(`
+// This is auto-generated. DO NOT EDIT.
+
+java_import {
+    name: "mysdk_myjavalib@2",
+    sdk_member_name: "myjavalib",
+    visibility: ["//visibility:public"],
+    apex_available: ["//apex_available:platform"],
+    jars: ["java/myjavalib.jar"],
+}
+
+java_import {
+    name: "myjavalib",
+    prefer: false,
+    visibility: ["//visibility:public"],
+    apex_available: ["//apex_available:platform"],
+    jars: ["java/myjavalib.jar"],
+}
+
+sdk_snapshot {
+    name: "mysdk@2",
+    visibility: ["//visibility:public"],
+    java_header_libs: ["mysdk_myjavalib@2"],
+}
+
+`),
+		checkAllCopyRules(`
+.intermediates/myjavalib/android_common/turbine-combined/myjavalib.jar -> java/myjavalib.jar
+`),
+	)
+}
+
+func TestVersionedSnapshotInvalidVersion(t *testing.T) {
+	ctx, config := testSdkContext(`
+		sdk {
+			name: "mysdk",
+		}
+	`, map[string]string{"SOONG_SDK_SNAPSHOT_VERSION": "next"}, nil, nil)
+	_, errs := ctx.ParseBlueprintsFiles(".")
+	android.FailIfErrored(t, errs)
+	_, errs = ctx.PrepareBuildActions(config)
+	android.FailIfNoMatchingErrors(t, `SOONG_SDK_SNAPSHOT_VERSION="next" is neither a positive number nor "current"`, errs)
+}
+
 func TestHostSnapshotWithJavaHeaderLibrary(t *testing.T) {
 	result := testSdkWithJava(t, `
 		sdk {
@@ -294,9 +294,10 @@ func (s *sdk) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		}
 
 		// Generate the snapshot from the member info.
-		p := s.buildSnapshot(ctx, sdkVariants)
+		version := snapshotVersion(ctx)
+		p := s.buildSnapshot(ctx, sdkVariants, version)
 		s.snapshotFile = android.OptionalPathForPath(p)
-		ctx.InstallFile(android.PathForMainlineSdksInstall(ctx), s.Name()+"-current.zip", p)
+		ctx.InstallFile(android.PathForMainlineSdksInstall(ctx), s.Name()+"-"+version+".zip", p)
 	}
 }
 
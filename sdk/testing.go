@@ -29,7 +29,7 @@ import (
 	"android/soong/java"
 )
 
-func testSdkContext(bp string, fs map[string][]byte, extraOsTypes []android.OsType) (*android.TestContext, android.Config) {
+func testSdkContext(bp string, env map[string]string, fs map[string][]byte, extraOsTypes []android.OsType) (*android.TestContext, android.Config) {
 	extraOsTypes = append(extraOsTypes, android.Android, android.Windows)
 
 	bp = bp + `
@@ -63,7 +63,7 @@ func testSdkContext(bp string, fs map[string][]byte, extraOsTypes []android.OsTy
 		mockFS[k] = v
 	}
 
-	config := android.TestArchConfig(buildDir, nil, bp, mockFS)
+	config := android.TestArchConfig(buildDir, env, bp, mockFS)
 
 	// Add windows as a default disable OS to test behavior when some OS variants
 	// are disabled.
@@ -150,13 +150,19 @@ func runTests(t *testing.T, ctx *android.TestContext, config android.Config) *te
 
 func testSdkWithFs(t *testing.T, bp string, fs map[string][]byte) *testSdkResult {
 	t.Helper()
-	ctx, config := testSdkContext(bp, fs, nil)
+	ctx, config := testSdkContext(bp, nil, fs, nil)
+	return runTests(t, ctx, config)
+}
+
+func testSdkWithEnv(t *testing.T, bp string, env map[string]string) *testSdkResult {
+	t.Helper()
+	ctx, config := testSdkContext(bp, env, nil, nil)
 	return runTests(t, ctx, config)
 }
 
 func testSdkError(t *testing.T, pattern, bp string) {
 	t.Helper()
-	ctx, config := testSdkContext(bp, nil, nil)
+	ctx, config := testSdkContext(bp, nil, nil, nil)
 	_, errs := ctx.ParseFileList(".", []string{"Android.bp"})
 	if len(errs) > 0 {
 		android.FailIfNoMatchingErrors(t, pattern, errs)
@@ -344,8 +350,9 @@ func (r *testSdkResult) CheckSnapshot(name string, dir string, checkers ...snaps
 	if dir != "" {
 		dir = filepath.Clean(dir) + "/"
 	}
+	version := r.config.GetenvWithDefault(sdkSnapshotVersionEnvVar, "current")
 	r.AssertStringEquals("Snapshot zip file in wrong place",
-		fmt.Sprintf(".intermediates/%s%s/%s/%s-current.zip", dir, name, variant, name), actual)
+		fmt.Sprintf(".intermediates/%s%s/%s/%s-%s.zip", dir, name, variant, name, version), actual)
 
 	// Populate a mock filesystem with the files that would have been copied by
 	// the rules.
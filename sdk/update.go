@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 
 	"android/soong/apex"
@@ -31,6 +32,24 @@ import (
 
 var pctx = android.NewPackageContext("android/soong/sdk")
 
+// SOONG_SDK_SNAPSHOT_VERSION sets the version of the snapshots built by the sdk modules. It is
+// either "current", the default, or the number of a frozen version of the sdk, e.g. "2". The
+// snapshot of version N is named <sdk>@N and its zip file is <sdk>-N.zip.
+const sdkSnapshotVersionEnvVar = "SOONG_SDK_SNAPSHOT_VERSION"
+
+// snapshotVersion returns the version of the snapshot to build.
+func snapshotVersion(ctx android.ModuleContext) string {
+	version := ctx.Config().GetenvWithDefault(sdkSnapshotVersionEnvVar, "current")
+	if version != "current" {
+		if n, err := strconv.Atoi(version); err != nil || n <= 0 {
+			ctx.ModuleErrorf("%s=%q is neither a positive number nor \"current\"",
+				sdkSnapshotVersionEnvVar, version)
+			return "current"
+		}
+	}
+	return version
+}
+
 var (
 	repackageZip = pctx.AndroidStaticRule("SnapshotRepackageZip",
 		blueprint.RuleParams{
@@ -213,7 +232,7 @@ func versionedSdkMemberName(ctx android.ModuleContext, memberName string, versio
 
 // buildSnapshot is the main function in this source file. It creates rules to copy
 // the contents (header files, stub libraries, etc) into the zip file.
-func (s *sdk) buildSnapshot(ctx android.ModuleContext, sdkVariants []*sdk) android.OutputPath {
+func (s *sdk) buildSnapshot(ctx android.ModuleContext, sdkVariants []*sdk, version string) android.OutputPath {
 
 	allMembersByName := make(map[string]struct{})
 	exportedMembersByName := make(map[string]struct{})
@@ -244,7 +263,7 @@ func (s *sdk) buildSnapshot(ctx android.ModuleContext, sdkVariants []*sdk) andro
 	builder := &snapshotBuilder{
 		ctx:                   ctx,
 		sdk:                   s,
-		version:               "current",
+		version:               version,
 		snapshotDir:           snapshotDir.OutputPath,
 		copies:                make(map[string]string),
 		filesToZip:            []android.Path{bp.path},
@@ -403,7 +422,7 @@ func (s *sdk) buildSnapshot(ctx android.ModuleContext, sdkVariants []*sdk) andro
 	filesToZip := builder.filesToZip
 
 	// zip them all
-	outputZipFile := android.PathForModuleOut(ctx, ctx.ModuleName()+"-current.zip").OutputPath
+	outputZipFile := android.PathForModuleOut(ctx, ctx.ModuleName()+"-"+version+".zip").OutputPath
 	outputDesc := "Building snapshot for " + ctx.ModuleName()
 
 	// If there are no zips to merge then generate the output zip directly.
@@ -415,7 +434,7 @@ func (s *sdk) buildSnapshot(ctx android.ModuleContext, sdkVariants []*sdk) andro
 		zipFile = outputZipFile
 		desc = outputDesc
 	} else {
-		zipFile = android.PathForModuleOut(ctx, ctx.ModuleName()+"-current.unmerged.zip").OutputPath
+		zipFile = android.PathForModuleOut(ctx, ctx.ModuleName()+"-"+version+".unmerged.zip").OutputPath
 		desc = "Building intermediate snapshot for " + ctx.ModuleName()
 	}
 
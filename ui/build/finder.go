@@ -124,6 +124,14 @@ func FindSources(ctx Context, config Config, f *finder.Finder) {
 		ctx.Fatalf("Could not export module list: %v", err)
 	}
 
+	// All the Android.mk files, including those included by another Android.mk, for the mk2bp
+	// conversion report.
+	allAndroidMks := f.FindNamedAt(".", "Android.mk")
+	err = dumpListToFile(ctx, config, allAndroidMks, filepath.Join(dumpDir, "Android.mk.all.list"))
+	if err != nil {
+		ctx.Fatalf("Could not export module list: %v", err)
+	}
+
 	// Stop searching a subdirectory recursively after finding a CleanSpec.mk.
 	cleanSpecs := f.FindFirstNamedAt(".", "CleanSpec.mk")
 	err = dumpListToFile(ctx, config, cleanSpecs, filepath.Join(dumpDir, "CleanSpec.mk.list"))
@@ -904,10 +904,12 @@ type SoongBuildMetrics struct {
 	// The total size of allocations in soong_build in bytes.
 	TotalAllocSize *uint64 `protobuf:"varint,4,opt,name=total_alloc_size,json=totalAllocSize" json:"total_alloc_size,omitempty"`
 	// The approximate maximum size of the heap in soong_build in bytes.
-	MaxHeapSize          *uint64  `protobuf:"varint,5,opt,name=max_heap_size,json=maxHeapSize" json:"max_heap_size,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	MaxHeapSize *uint64 `protobuf:"varint,5,opt,name=max_heap_size,json=maxHeapSize" json:"max_heap_size,omitempty"`
+	// Runtimes of the mutators, singletons and globs run by soong_build.
+	Events               []*PerfInfo `protobuf:"bytes,6,rep,name=events" json:"events,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
 }
 
 func (m *SoongBuildMetrics) Reset()         { *m = SoongBuildMetrics{} }
@@ -970,6 +972,13 @@ func (m *SoongBuildMetrics) GetMaxHeapSize() uint64 {
 	return 0
 }
 
+func (m *SoongBuildMetrics) GetEvents() []*PerfInfo {
+	if m != nil {
+		return m.Events
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("soong_build_metrics.MetricsBase_BuildVariant", MetricsBase_BuildVariant_name, MetricsBase_BuildVariant_value)
 	proto.RegisterEnum("soong_build_metrics.MetricsBase_Arch", MetricsBase_Arch_name, MetricsBase_Arch_value)
@@ -990,92 +999,91 @@ func init() {
 }
 
 var fileDescriptor_6039342a2ba47b72 = []byte{
-	// 1380 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x57, 0xef, 0x52, 0x1b, 0x37,
-	0x10, 0x8f, 0xc1, 0x60, 0x7b, 0xfd, 0x07, 0x23, 0xa0, 0x5c, 0x48, 0xd2, 0x52, 0xb7, 0x49, 0x99,
-	0x4e, 0x43, 0x32, 0x34, 0xc3, 0x64, 0x98, 0x4c, 0xa7, 0xe0, 0xd0, 0x34, 0x65, 0xc0, 0x8c, 0x08,
-	0x69, 0xda, 0x7e, 0x50, 0xe5, 0xb3, 0x0c, 0x97, 0xdc, 0x9d, 0x6e, 0x24, 0x1d, 0xc5, 0x79, 0xb3,
-	0x7e, 0xee, 0x4b, 0xf4, 0x05, 0xfa, 0x04, 0x7d, 0x81, 0x8e, 0x56, 0x77, 0xe6, 0x20, 0x6e, 0xc2,
-	0xe4, 0xdb, 0xe9, 0xb7, 0xbf, 0xdf, 0x6a, 0xb5, 0xd2, 0xee, 0xda, 0xd0, 0x8c, 0x84, 0x51, 0x81,
-	0xaf, 0xd7, 0x13, 0x25, 0x8d, 0x24, 0x0b, 0x5a, 0xca, 0xf8, 0x84, 0xf5, 0xd3, 0x20, 0x1c, 0xb0,
-	0xcc, 0xd4, 0xf9, 0xbb, 0x01, 0xf5, 0x7d, 0xf7, 0xbd, 0xc3, 0xb5, 0x20, 0x0f, 0x61, 0xd1, 0x11,
-	0x06, 0xdc, 0x08, 0x66, 0x82, 0x48, 0x68, 0xc3, 0xa3, 0xc4, 0x2b, 0xad, 0x96, 0xd6, 0xa6, 0x29,
-	0x41, 0xdb, 0x53, 0x6e, 0xc4, 0x8b, 0xdc, 0x42, 0x6e, 0x42, 0xd5, 0x29, 0x82, 0x81, 0x37, 0xb5,
-	0x5a, 0x5a, 0xab, 0xd1, 0x0a, 0xae, 0x9f, 0x0f, 0xc8, 0x16, 0xdc, 0x4c, 0x42, 0x6e, 0x86, 0x52,
-	0x45, 0xec, 0x4c, 0x28, 0x1d, 0xc8, 0x98, 0xf9, 0x72, 0x20, 0x62, 0x1e, 0x09, 0x6f, 0x1a, 0xb9,
-	0xcb, 0x39, 0xe1, 0xa5, 0xb3, 0x77, 0x33, 0x33, 0xb9, 0x0b, 0x2d, 0xc3, 0xd5, 0x89, 0x30, 0x2c,
-	0x51, 0x72, 0x90, 0xfa, 0xc6, 0x2b, 0xa3, 0xa0, 0xe9, 0xd0, 0x43, 0x07, 0x92, 0x01, 0x2c, 0x66,
-	0x34, 0x17, 0xc4, 0x19, 0x57, 0x01, 0x8f, 0x8d, 0x37, 0xb3, 0x5a, 0x5a, 0x6b, 0x6d, 0xdc, 0x5f,
-	0x9f, 0x70, 0xe6, 0xf5, 0xc2, 0x79, 0xd7, 0x77, 0xac, 0xe5, 0xa5, 0x13, 0x6d, 0x4d, 0xef, 0x1e,
-	0x3c, 0xa3, 0xc4, 0xf9, 0x2b, 0x1a, 0x48, 0x0f, 0xea, 0xd9, 0x2e, 0x5c, 0xf9, 0xa7, 0xde, 0x2c,
-	0x3a, 0xbf, 0xfb, 0x41, 0xe7, 0xdb, 0xca, 0x3f, 0xdd, 0xaa, 0x1c, 0x1f, 0xec, 0x1d, 0xf4, 0x7e,
-	0x3e, 0xa0, 0xe0, 0x5c, 0x58, 0x90, 0xac, 0xc3, 0x42, 0xc1, 0xe1, 0x38, 0xea, 0x0a, 0x1e, 0x71,
-	0xfe, 0x82, 0x98, 0x07, 0xf0, 0x0d, 0x64, 0x61, 0x31, 0x3f, 0x49, 0xc7, 0xf4, 0x2a, 0xd2, 0xdb,
-	0xce, 0xd2, 0x4d, 0xd2, 0x9c, 0xbd, 0x07, 0xb5, 0x53, 0xa9, 0xb3, 0x60, 0x6b, 0x1f, 0x15, 0x6c,
-	0xd5, 0x3a, 0xc0, 0x50, 0x29, 0x34, 0xd1, 0xd9, 0x46, 0x3c, 0x70, 0x0e, 0xe1, 0xa3, 0x1c, 0xd6,
-	0xad, 0x93, 0x8d, 0x78, 0x80, 0x3e, 0x97, 0xa1, 0x82, 0x3e, 0xa5, 0xf6, 0xea, 0x78, 0x86, 0x59,
-	0xbb, 0xec, 0x69, 0xd2, 0xc9, 0x36, 0x93, 0x9a, 0x89, 0x73, 0xa3, 0xb8, 0xd7, 0x40, 0x73, 0xdd,
-	0x99, 0x77, 0x2d, 0x34, 0xe6, 0xf8, 0x4a, 0x6a, 0x6d, 0x5d, 0x34, 0x2f, 0x38, 0x5d, 0x8b, 0xf5,
-	0x34, 0xb9, 0x07, 0x73, 0x05, 0x0e, 0x86, 0xdd, 0x72, 0xcf, 0x67, 0xcc, 0xc2, 0x40, 0xee, 0xc3,
-	0x42, 0x81, 0x37, 0x3e, 0xe2, 0x9c, 0x4b, 0xec, 0x98, 0x5b, 0x88, 0x5b, 0xa6, 0x86, 0x0d, 0x02,
-	0xe5, 0xb5, 0x5d, 0xdc, 0x32, 0x35, 0x4f, 0x03, 0x45, 0xbe, 0x83, 0xba, 0x16, 0x26, 0x4d, 0x98,
-	0x91, 0x32, 0xd4, 0xde, 0xfc, 0xea, 0xf4, 0x5a, 0x7d, 0xe3, 0xce, 0xc4, 0x14, 0x1d, 0x0a, 0x35,
-	0x7c, 0x1e, 0x0f, 0x25, 0x05, 0x54, 0xbc, 0xb0, 0x02, 0xb2, 0x05, 0xb5, 0x37, 0xdc, 0x04, 0x4c,
-	0xa5, 0xb1, 0xf6, 0xc8, 0x75, 0xd4, 0x55, 0xcb, 0xa7, 0x69, 0xac, 0xc9, 0x13, 0x00, 0xc7, 0x44,
-	0xf1, 0xc2, 0x75, 0xc4, 0x35, 0xb4, 0xe6, 0xea, 0x38, 0x88, 0x5f, 0x73, 0xa7, 0x5e, 0xbc, 0x96,
-	0x1a, 0x05, 0xa8, 0xfe, 0x16, 0x66, 0x8c, 0x34, 0x3c, 0xf4, 0x96, 0x56, 0x4b, 0x1f, 0x16, 0x3a,
-	0x2e, 0x79, 0x09, 0x93, 0x5a, 0x91, 0xf7, 0x09, 0xba, 0xb8, 0x37, 0xd1, 0xc5, 0x91, 0xc5, 0xb0,
-	0x24, 0xb3, 0x17, 0x46, 0xe7, 0xf5, 0x55, 0x88, 0x74, 0xa1, 0xe1, 0x54, 0xbe, 0x8c, 0x87, 0xc1,
-	0x89, 0xb7, 0x8c, 0x0e, 0x57, 0x27, 0x3a, 0x44, 0x61, 0x17, 0x79, 0xb4, 0xde, 0xbf, 0x58, 0x90,
-	0x15, 0xc0, 0xa7, 0x8f, 0x2d, 0xca, 0xc3, 0x3b, 0x1e, 0xaf, 0xc9, 0x2f, 0xb0, 0xa8, 0x47, 0xda,
-	0x88, 0x88, 0x29, 0xa1, 0x65, 0xaa, 0x7c, 0xc1, 0x82, 0x78, 0x28, 0xbd, 0x9b, 0xb8, 0xd1, 0x57,
-	0x93, 0x23, 0x47, 0x01, 0xcd, 0xf8, 0x98, 0x06, 0xa2, 0xdf, 0xc1, 0xc8, 0x17, 0xd0, 0xcc, 0x63,
-	0x8f, 0x22, 0x1e, 0x0f, 0xbc, 0x15, 0xdc, 0xbb, 0x91, 0x85, 0x86, 0x98, 0xbd, 0xab, 0x3e, 0x7f,
-	0x2b, 0x42, 0x77, 0x57, 0xb7, 0xae, 0x75, 0x57, 0x28, 0xb0, 0x77, 0xd5, 0x79, 0x08, 0x8d, 0x4b,
-	0x4d, 0xad, 0x0a, 0xe5, 0xe3, 0xa3, 0x5d, 0xda, 0xbe, 0x41, 0x9a, 0x50, 0xb3, 0x5f, 0x4f, 0x77,
-	0x77, 0x8e, 0x9f, 0xb5, 0x4b, 0xa4, 0x02, 0xb6, 0x11, 0xb6, 0xa7, 0x3a, 0x4f, 0xa0, 0x8c, 0xcf,
-	0xbe, 0x0e, 0x79, 0x19, 0xb7, 0x6f, 0x58, 0xeb, 0x36, 0xdd, 0x6f, 0x97, 0x48, 0x0d, 0x66, 0xb6,
-	0xe9, 0xfe, 0xe6, 0xa3, 0xf6, 0x94, 0xc5, 0x5e, 0x3d, 0xde, 0x6c, 0x4f, 0x13, 0x80, 0xd9, 0x57,
-	0x8f, 0x37, 0xd9, 0xe6, 0xa3, 0x76, 0xb9, 0x73, 0x02, 0xf5, 0x42, 0x96, 0xed, 0x9c, 0x48, 0xb5,
-	0x60, 0x27, 0x32, 0xe2, 0x38, 0x4d, 0xaa, 0xb4, 0x92, 0x6a, 0xf1, 0x4c, 0x46, 0xdc, 0x96, 0x95,
-	0x35, 0xa9, 0xbe, 0xc0, 0x09, 0x52, 0xa5, 0xb3, 0xa9, 0x16, 0xb4, 0x2f, 0xc8, 0x97, 0xd0, 0x1a,
-	0x4a, 0x9b, 0xe6, 0xb1, 0x72, 0x1a, 0xed, 0x0d, 0x44, 0x8f, 0x9d, 0xbc, 0x23, 0x81, 0xbc, 0x9b,
-	0x65, 0xb2, 0x01, 0x4b, 0xf8, 0xdc, 0x58, 0x72, 0x3a, 0xd2, 0x81, 0xcf, 0x43, 0x16, 0x89, 0x48,
-	0xaa, 0x11, 0x6e, 0x5e, 0xa6, 0x0b, 0x68, 0x3c, 0xcc, 0x6c, 0xfb, 0x68, 0xb2, 0x43, 0x87, 0x9f,
-	0xf1, 0x20, 0xe4, 0xfd, 0x50, 0xd8, 0x4e, 0xab, 0x31, 0x9e, 0x19, 0xda, 0x1c, 0xa3, 0xdd, 0x24,
-	0xd5, 0x9d, 0x7f, 0x4b, 0x50, 0xcd, 0x33, 0x4c, 0x08, 0x94, 0x07, 0x42, 0xfb, 0xe8, 0xb6, 0x46,
-	0xf1, 0xdb, 0x62, 0xf8, 0x80, 0xdc, 0x3c, 0xc4, 0x6f, 0x72, 0x07, 0x40, 0x1b, 0xae, 0x0c, 0x0e,
-	0x55, 0x3c, 0x47, 0x99, 0xd6, 0x10, 0xb1, 0xb3, 0x94, 0xdc, 0x82, 0x9a, 0x12, 0x3c, 0x74, 0xd6,
-	0x32, 0x5a, 0xab, 0x16, 0x40, 0xe3, 0xe7, 0x00, 0x2e, 0x78, 0x9b, 0x08, 0x9c, 0x6d, 0xe5, 0x9d,
-	0x29, 0xaf, 0x44, 0x6b, 0x0e, 0x3d, 0xd6, 0x82, 0xfc, 0x0e, 0xcb, 0x89, 0x92, 0xbe, 0xd0, 0x5a,
-	0xe8, 0x2b, 0xcf, 0x73, 0x16, 0x1f, 0xca, 0xda, 0xe4, 0x87, 0xe2, 0x34, 0x97, 0xde, 0xe7, 0xd2,
-	0xd8, 0x51, 0x11, 0xee, 0xfc, 0x39, 0x0d, 0x0b, 0x13, 0xe8, 0xe3, 0xc3, 0x96, 0x0a, 0x87, 0x5d,
-	0x83, 0x76, 0xaa, 0x85, 0xc2, 0xd3, 0xb0, 0x28, 0xb0, 0xed, 0x15, 0x93, 0x51, 0xa6, 0x2d, 0x8b,
-	0xdb, 0x43, 0xed, 0x23, 0x6a, 0x27, 0x5b, 0x56, 0x53, 0x45, 0xae, 0x4b, 0x4f, 0xdb, 0x59, 0x0a,
-	0xec, 0xdb, 0x00, 0x11, 0x3f, 0x67, 0x4a, 0x6b, 0xf6, 0xa6, 0x9f, 0xa7, 0x29, 0xe2, 0xe7, 0x54,
-	0xeb, 0xbd, 0x3e, 0xf9, 0x1a, 0xe6, 0xa3, 0x20, 0x96, 0x8a, 0x25, 0xfc, 0x44, 0xb0, 0x21, 0x4f,
-	0x43, 0xa3, 0x5d, 0xb6, 0xe8, 0x1c, 0x1a, 0x0e, 0xf9, 0x89, 0xf8, 0x01, 0x61, 0xe4, 0xf2, 0xd7,
-	0x57, 0xb8, 0xb3, 0x19, 0xd7, 0x1a, 0x0a, 0xdc, 0x4f, 0xa1, 0x1e, 0x48, 0x16, 0xc4, 0x49, 0x6a,
-	0xec, 0xb6, 0x15, 0x77, 0x77, 0x81, 0x7c, 0x6e, 0x91, 0xbd, 0x3e, 0x59, 0x85, 0x46, 0x20, 0x99,
-	0x4c, 0x4d, 0x46, 0xa8, 0x22, 0x01, 0x02, 0xd9, 0x43, 0x68, 0xaf, 0x4f, 0x9e, 0xc0, 0xca, 0x99,
-	0x0c, 0xd3, 0xd8, 0x70, 0x35, 0xb2, 0xed, 0xc9, 0x88, 0x73, 0xc3, 0xf4, 0x1f, 0x81, 0xf1, 0x4f,
-	0x85, 0xc6, 0x11, 0x5d, 0xa6, 0xde, 0x98, 0xd1, 0x75, 0x84, 0xa3, 0xcc, 0x4e, 0xbe, 0x87, 0xdb,
-	0x41, 0xfc, 0x1e, 0x3d, 0xa0, 0x7e, 0xa5, 0xc0, 0xb9, 0xe2, 0xa1, 0xf3, 0x4f, 0x09, 0x5a, 0xfb,
-	0x72, 0x90, 0x86, 0xe2, 0xc5, 0x28, 0x71, 0xd7, 0xf6, 0x5b, 0xde, 0x2d, 0x5d, 0x92, 0xf1, 0xfa,
-	0x5a, 0x1b, 0x0f, 0x26, 0x8f, 0xf5, 0x4b, 0x52, 0xd7, 0x3c, 0x5d, 0xc9, 0x15, 0x06, 0x7c, 0xff,
-	0x02, 0x25, 0x9f, 0x41, 0x3d, 0x42, 0x0d, 0x33, 0xa3, 0x24, 0xaf, 0x03, 0x88, 0xc6, 0x6e, 0x6c,
-	0x65, 0xc7, 0x69, 0xc4, 0xe4, 0x90, 0x39, 0xd0, 0x5d, 0x79, 0x93, 0x36, 0xe2, 0x34, 0xea, 0x0d,
-	0xdd, 0x7e, 0xba, 0xf3, 0x20, 0x6b, 0x21, 0x99, 0xd7, 0x4b, 0x7d, 0xa8, 0x06, 0x33, 0x47, 0xbd,
-	0xde, 0x81, 0x6d, 0x58, 0x55, 0x28, 0xef, 0x6f, 0xef, 0xed, 0xb6, 0xa7, 0x3a, 0x21, 0xac, 0x74,
-	0x55, 0x60, 0x6c, 0x49, 0x1f, 0x6b, 0xa1, 0x7e, 0x92, 0xa9, 0x8a, 0xc5, 0x28, 0x1f, 0x10, 0x93,
-	0x5e, 0xea, 0x16, 0x54, 0xf2, 0x01, 0x34, 0xf5, 0x9e, 0x79, 0x51, 0xf8, 0x61, 0x43, 0x73, 0x41,
-	0xa7, 0x0f, 0xb7, 0x26, 0xec, 0xa6, 0x2f, 0xe6, 0x51, 0xd9, 0x4f, 0x5f, 0x6b, 0xaf, 0x84, 0xf5,
-	0x37, 0x39, 0xb3, 0xff, 0x1f, 0x2d, 0x45, 0x71, 0xe7, 0xaf, 0x12, 0xcc, 0xbf, 0x33, 0xfd, 0x88,
-	0x07, 0x95, 0x3c, 0x6f, 0x25, 0xcc, 0x5b, 0xbe, 0xb4, 0xf3, 0x2b, 0xfb, 0x79, 0xe8, 0x0e, 0xd4,
-	0xa4, 0xe3, 0xb5, 0x7d, 0xf3, 0xae, 0x25, 0xf2, 0x30, 0x94, 0x3e, 0xf3, 0x65, 0x1a, 0x9b, 0xac,
-	0xd4, 0xe6, 0xd0, 0xb0, 0x6d, 0xf1, 0xae, 0x85, 0x6d, 0x05, 0x17, 0xb9, 0x3a, 0x78, 0x9b, 0xb7,
-	0xa5, 0xd6, 0x05, 0xf5, 0x28, 0x78, 0x2b, 0xec, 0xef, 0x31, 0x5b, 0x93, 0xa7, 0x82, 0x27, 0x8e,
-	0xe6, 0x2a, 0xae, 0x1e, 0xf1, 0xf3, 0x1f, 0x05, 0x4f, 0x2c, 0x67, 0x67, 0xe9, 0xd7, 0x6c, 0xe4,
-	0x67, 0xe7, 0x66, 0xf8, 0x97, 0xe4, 0xbf, 0x00, 0x00, 0x00, 0xff, 0xff, 0xcb, 0xfb, 0x8e, 0xf5,
-	0xa2, 0x0c, 0x00, 0x00,
+	// 1374 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9d, 0x57, 0x6d, 0x53, 0xdb, 0x46,
+	0x10, 0xae, 0xb1, 0xf1, 0xcb, 0xca, 0x36, 0xe6, 0x80, 0xa2, 0x90, 0xa4, 0xa5, 0x6e, 0x93, 0x32,
+	0x9d, 0x86, 0x64, 0x68, 0x9b, 0xe9, 0x30, 0x99, 0x4e, 0xc1, 0xa1, 0x29, 0x65, 0xc0, 0xcc, 0x11,
+	0xd2, 0xb7, 0x0f, 0xaa, 0x2c, 0x9f, 0x41, 0x89, 0xa5, 0xf3, 0xe8, 0x24, 0x1a, 0xf2, 0xcf, 0xfa,
+	0x4f, 0xfa, 0x07, 0xfa, 0x0b, 0xfa, 0xad, 0x9f, 0xba, 0xb7, 0x27, 0x09, 0x41, 0x9c, 0x84, 0xc9,
+	0xb7, 0xbb, 0x67, 0x9f, 0xdd, 0xdb, 0xdb, 0xdb, 0x17, 0x09, 0x5a, 0x81, 0x88, 0x23, 0xdf, 0x53,
+	0xeb, 0x93, 0x48, 0xc6, 0x92, 0x2d, 0x28, 0x29, 0xc3, 0x13, 0x67, 0x90, 0xf8, 0xe3, 0xa1, 0x93,
+	0x8a, 0xba, 0x7f, 0x37, 0xc1, 0xda, 0x37, 0xeb, 0x6d, 0x57, 0x09, 0xf6, 0x00, 0x16, 0x0d, 0x61,
+	0xe8, 0xc6, 0xc2, 0x89, 0xfd, 0x40, 0xa8, 0xd8, 0x0d, 0x26, 0x76, 0x69, 0xb5, 0xb4, 0x56, 0xe6,
+	0x8c, 0x64, 0x8f, 0x51, 0xf4, 0x34, 0x93, 0xb0, 0x1b, 0x50, 0x37, 0x1a, 0xfe, 0xd0, 0x9e, 0x41,
+	0x56, 0x83, 0xd7, 0x68, 0xbf, 0x3b, 0x64, 0x9b, 0x70, 0x63, 0x32, 0x76, 0xe3, 0x91, 0x8c, 0x02,
+	0xe7, 0x4c, 0x44, 0xca, 0x97, 0xa1, 0xe3, 0xc9, 0xa1, 0x08, 0xdd, 0x40, 0xd8, 0x65, 0xe2, 0x2e,
+	0x67, 0x84, 0x67, 0x46, 0xde, 0x4b, 0xc5, 0xec, 0x0e, 0xb4, 0x63, 0x37, 0x3a, 0x11, 0xb1, 0x83,
+	0xde, 0x0f, 0x13, 0x2f, 0xb6, 0x2b, 0xa4, 0xd0, 0x32, 0xe8, 0xa1, 0x01, 0xd9, 0x10, 0x16, 0x53,
+	0x9a, 0x71, 0xe2, 0xcc, 0x8d, 0x7c, 0x37, 0x8c, 0xed, 0x59, 0x24, 0xb7, 0x37, 0xee, 0xad, 0x4f,
+	0xb9, 0xf3, 0x7a, 0xe1, 0xbe, 0xeb, 0xdb, 0x5a, 0xf2, 0xcc, 0x28, 0x6d, 0x96, 0x77, 0x0e, 0x9e,
+	0x70, 0x66, 0xec, 0x15, 0x05, 0xac, 0x0f, 0x56, 0x7a, 0x8a, 0x1b, 0x79, 0xa7, 0x76, 0x95, 0x8c,
+	0xdf, 0x79, 0xa7, 0xf1, 0x2d, 0x24, 0x6f, 0xd6, 0x8e, 0x0f, 0xf6, 0x0e, 0xfa, 0x3f, 0x1f, 0x70,
+	0x30, 0x26, 0x34, 0xc8, 0xd6, 0x61, 0xa1, 0x60, 0x30, 0xf7, 0xba, 0x46, 0x57, 0x9c, 0xbf, 0x20,
+	0x66, 0x0e, 0x7c, 0x09, 0xa9, 0x5b, 0x8e, 0x37, 0x49, 0x72, 0x7a, 0x9d, 0xe8, 0x1d, 0x23, 0xe9,
+	0x4d, 0x92, 0x8c, 0xbd, 0x07, 0x8d, 0x53, 0xa9, 0x52, 0x67, 0x1b, 0xef, 0xe5, 0x6c, 0x5d, 0x1b,
+	0x20, 0x57, 0x39, 0xb4, 0xc8, 0xd8, 0x46, 0x38, 0x34, 0x06, 0xe1, 0xbd, 0x0c, 0x5a, 0xda, 0x08,
+	0xda, 0x20, 0x9b, 0xcb, 0x50, 0x23, 0x9b, 0x52, 0xd9, 0x16, 0xdd, 0xa1, 0xaa, 0xb7, 0x7d, 0xc5,
+	0xba, 0xe9, 0x61, 0x52, 0x39, 0xe2, 0x65, 0x1c, 0xb9, 0x76, 0x93, 0xc4, 0x96, 0x11, 0xef, 0x68,
+	0x28, 0xe7, 0x78, 0x91, 0x54, 0x4a, 0x9b, 0x68, 0x5d, 0x70, 0x7a, 0x1a, 0x43, 0x3b, 0x77, 0x61,
+	0xae, 0xc0, 0x21, 0xb7, 0xdb, 0x26, 0x7d, 0x72, 0x16, 0x39, 0x72, 0x0f, 0x16, 0x0a, 0xbc, 0xfc,
+	0x8a, 0x73, 0x26, 0xb0, 0x39, 0xb7, 0xe0, 0xb7, 0x4c, 0x62, 0x67, 0xe8, 0x47, 0x76, 0xc7, 0xf8,
+	0x8d, 0xdb, 0xc7, 0x7e, 0xc4, 0xbe, 0x03, 0x4b, 0x89, 0x38, 0x99, 0x38, 0xb1, 0x94, 0x63, 0x65,
+	0xcf, 0xaf, 0x96, 0xd7, 0xac, 0x8d, 0xdb, 0x53, 0x43, 0x74, 0x28, 0xa2, 0xd1, 0x6e, 0x38, 0x92,
+	0x1c, 0x48, 0xe3, 0xa9, 0x56, 0xc0, 0x4a, 0x69, 0xbc, 0x70, 0x63, 0xdf, 0x89, 0x92, 0x50, 0xd9,
+	0xec, 0x3a, 0xda, 0x75, 0xcd, 0xe7, 0x48, 0x67, 0x8f, 0x00, 0x0c, 0x93, 0x94, 0x17, 0xae, 0xa3,
+	0xdc, 0x20, 0x69, 0xa6, 0x1d, 0xfa, 0xe1, 0x73, 0xd7, 0x68, 0x2f, 0x5e, 0x4b, 0x9b, 0x14, 0x48,
+	0xfb, 0x2b, 0x98, 0x8d, 0x65, 0xec, 0x8e, 0xed, 0x25, 0x0c, 0xc7, 0x3b, 0x15, 0x0d, 0x97, 0x3d,
+	0x83, 0x69, 0xad, 0xc8, 0xfe, 0x90, 0x4c, 0xdc, 0x9d, 0x6a, 0xe2, 0x48, 0x63, 0x54, 0x92, 0x69,
+	0x86, 0xf1, 0x79, 0x75, 0x15, 0x62, 0x3d, 0x68, 0x1a, 0x2d, 0x4f, 0x86, 0x23, 0xff, 0xc4, 0x5e,
+	0x26, 0x83, 0xab, 0x53, 0x0d, 0x92, 0x62, 0x8f, 0x78, 0xdc, 0x1a, 0x5c, 0x6c, 0xd8, 0x0a, 0x50,
+	0xea, 0x53, 0x8b, 0xb2, 0xe9, 0x8d, 0xf3, 0x3d, 0xfb, 0x15, 0x16, 0xd5, 0xb9, 0x8a, 0x45, 0xe0,
+	0x44, 0x42, 0xc9, 0x24, 0xf2, 0x84, 0xe3, 0xe3, 0xbd, 0xec, 0x1b, 0x74, 0xd0, 0xe7, 0xd3, 0x3d,
+	0x27, 0x05, 0x9e, 0xf2, 0x29, 0x0c, 0x4c, 0xbd, 0x86, 0xb1, 0x4f, 0xa1, 0x95, 0xf9, 0x1e, 0x04,
+	0x6e, 0x38, 0xb4, 0x57, 0xe8, 0xec, 0x66, 0xea, 0x1a, 0x61, 0xfa, 0xad, 0x06, 0xee, 0x2b, 0x31,
+	0x36, 0x6f, 0x75, 0xf3, 0x5a, 0x6f, 0x45, 0x0a, 0xfa, 0xad, 0xba, 0x0f, 0xa0, 0x79, 0xa9, 0xa9,
+	0xd5, 0xa1, 0x72, 0x7c, 0xb4, 0xc3, 0x3b, 0x1f, 0xb0, 0x16, 0x34, 0xf4, 0xea, 0xf1, 0xce, 0xf6,
+	0xf1, 0x93, 0x4e, 0x89, 0xd5, 0x40, 0x37, 0xc2, 0xce, 0x4c, 0xf7, 0x11, 0x54, 0x28, 0xed, 0x2d,
+	0xc8, 0xca, 0x18, 0xc9, 0x28, 0xdd, 0xe2, 0xfb, 0x48, 0x6b, 0xc0, 0x2c, 0x2e, 0x1e, 0x7e, 0xdd,
+	0x99, 0xd1, 0xd8, 0x2f, 0xdf, 0x3e, 0xec, 0x94, 0x19, 0x40, 0x15, 0x17, 0x0e, 0x82, 0x95, 0xee,
+	0x09, 0x58, 0x85, 0x28, 0xeb, 0x39, 0x91, 0x28, 0xe1, 0x9c, 0xc8, 0xc0, 0xa5, 0x69, 0x52, 0xe7,
+	0x35, 0xdc, 0x3f, 0xc1, 0xad, 0x2e, 0x2b, 0x2d, 0x8a, 0x06, 0x82, 0x26, 0x48, 0x9d, 0x57, 0x71,
+	0xcb, 0x07, 0x82, 0x7d, 0x06, 0x6d, 0x9c, 0x0d, 0x18, 0xe6, 0x5c, 0xb3, 0x4c, 0xf2, 0x26, 0xa1,
+	0xc7, 0x46, 0xbd, 0x2b, 0x81, 0xbd, 0x1e, 0x65, 0xb6, 0x01, 0x4b, 0x94, 0x6e, 0xce, 0xe4, 0xf4,
+	0x5c, 0xf9, 0x1e, 0x2e, 0x02, 0x11, 0xc8, 0xe8, 0x9c, 0x0e, 0xaf, 0xf0, 0x05, 0x12, 0x1e, 0xa6,
+	0xb2, 0x7d, 0x12, 0xe9, 0xa1, 0xe3, 0x9e, 0xb9, 0xfe, 0xd8, 0x1d, 0x8c, 0x85, 0xee, 0xb4, 0x8a,
+	0xfc, 0x99, 0xe5, 0xad, 0x1c, 0xc5, 0x2e, 0xab, 0xba, 0xff, 0x96, 0xa0, 0x9e, 0x45, 0x98, 0x31,
+	0xa8, 0x0c, 0x85, 0xf2, 0xc8, 0x6c, 0x83, 0xd3, 0x5a, 0x63, 0x94, 0x40, 0x66, 0x1e, 0xd2, 0x9a,
+	0xdd, 0xc6, 0x32, 0xc5, 0x4e, 0x1d, 0xd3, 0x50, 0xa5, 0x7b, 0x54, 0xb0, 0x0e, 0x35, 0xa2, 0x67,
+	0x29, 0xbb, 0x09, 0x8d, 0x48, 0xa0, 0x93, 0x24, 0xad, 0x90, 0xb4, 0xae, 0x01, 0x12, 0x7e, 0x02,
+	0x60, 0x9c, 0xd7, 0x81, 0xa0, 0xd9, 0x56, 0xd9, 0x9e, 0xb1, 0x4b, 0xbc, 0x61, 0x50, 0x0c, 0x04,
+	0xfb, 0x03, 0x96, 0x71, 0x50, 0x7a, 0x42, 0x29, 0xa1, 0xae, 0xa4, 0x67, 0x95, 0x12, 0x65, 0x6d,
+	0x7a, 0xa2, 0x18, 0x9d, 0x4b, 0xf9, 0xb9, 0x94, 0x1b, 0x2a, 0xc2, 0xdd, 0xbf, 0xca, 0xb0, 0x30,
+	0x85, 0x9e, 0x5f, 0xb6, 0x54, 0xb8, 0xec, 0x1a, 0x74, 0xd0, 0xd3, 0x88, 0x6e, 0xe3, 0x04, 0xbe,
+	0x6e, 0xaf, 0x14, 0x8c, 0x0a, 0x6f, 0x6b, 0x5c, 0x5f, 0x6a, 0x9f, 0x50, 0x3d, 0xd9, 0xd2, 0x9a,
+	0x2a, 0x72, 0x4d, 0x78, 0x3a, 0x46, 0x52, 0x60, 0xdf, 0xc2, 0x40, 0xb8, 0x2f, 0x9d, 0x08, 0x9b,
+	0xf5, 0x8b, 0x41, 0x16, 0x26, 0x44, 0xb8, 0x52, 0x7b, 0x03, 0xf6, 0x05, 0xcc, 0x07, 0x7e, 0x28,
+	0x23, 0x67, 0xe2, 0x9e, 0x08, 0x67, 0xe4, 0x26, 0xe3, 0x58, 0x99, 0x68, 0xf1, 0x39, 0x12, 0x1c,
+	0x22, 0xfe, 0x03, 0xc1, 0xc4, 0x75, 0x9f, 0x5f, 0xe1, 0x56, 0x53, 0xae, 0x16, 0x14, 0xb8, 0x1f,
+	0x81, 0xe5, 0x4b, 0x8c, 0xe5, 0x04, 0x7b, 0x3f, 0x1e, 0x5b, 0x33, 0x6f, 0xe7, 0xcb, 0x5d, 0x8d,
+	0xe0, 0xb9, 0xab, 0xd0, 0x44, 0x39, 0x8e, 0x82, 0x94, 0x50, 0x27, 0x02, 0xf8, 0xb2, 0x4f, 0x10,
+	0x32, 0x1e, 0xc1, 0xca, 0x99, 0x1c, 0x27, 0x21, 0x3e, 0xf7, 0xb9, 0x6e, 0x4f, 0x31, 0x4e, 0x37,
+	0x47, 0xfd, 0xe9, 0xc7, 0xde, 0xa9, 0x50, 0x34, 0xa2, 0x2b, 0xdc, 0xce, 0x19, 0x3d, 0x43, 0x38,
+	0x4a, 0xe5, 0xec, 0x7b, 0xb8, 0xe5, 0x87, 0x6f, 0xd1, 0x07, 0xd2, 0x5f, 0x29, 0x70, 0xae, 0x58,
+	0xe8, 0xfe, 0x53, 0x82, 0xf6, 0x3e, 0x7e, 0x31, 0x8d, 0xc5, 0xd3, 0xf3, 0x89, 0x79, 0xb6, 0xdf,
+	0xb3, 0x6e, 0x69, 0x82, 0x4c, 0xcf, 0xd7, 0xde, 0xb8, 0x3f, 0x7d, 0xac, 0x5f, 0x52, 0x35, 0xcd,
+	0xd3, 0x94, 0x5c, 0x61, 0xc0, 0x0f, 0x2e, 0x50, 0xf6, 0x31, 0x58, 0x01, 0xe9, 0x38, 0x31, 0x2a,
+	0xa5, 0x75, 0x00, 0x41, 0x6e, 0x46, 0x57, 0x76, 0x98, 0x04, 0x8e, 0x1c, 0x39, 0x06, 0x34, 0x4f,
+	0xde, 0xe2, 0x4d, 0x44, 0xfb, 0x23, 0x73, 0x9e, 0xea, 0xde, 0x4f, 0x5b, 0x48, 0x6a, 0xf5, 0x52,
+	0x1f, 0xc2, 0xf6, 0x73, 0xd4, 0xef, 0x1f, 0xe8, 0x86, 0x85, 0x9d, 0x6c, 0x7f, 0x6b, 0x6f, 0x07,
+	0x3b, 0xd6, 0x18, 0x56, 0x7a, 0x91, 0x1f, 0xeb, 0x92, 0xc6, 0xa2, 0x88, 0x7e, 0xc2, 0x2c, 0x0d,
+	0xc5, 0x79, 0x36, 0x20, 0xa6, 0x65, 0xea, 0x26, 0xd4, 0xb2, 0x01, 0x34, 0xf3, 0x96, 0x79, 0x51,
+	0xf8, 0xb0, 0xe1, 0x99, 0x42, 0x77, 0x00, 0x37, 0xa7, 0x9c, 0xa6, 0x2e, 0xe6, 0x51, 0xc5, 0x4b,
+	0x9e, 0x2b, 0x3c, 0x4e, 0xd7, 0xdf, 0xf4, 0xc8, 0xbe, 0xd9, 0x5b, 0x4e, 0xca, 0xdd, 0xff, 0x4a,
+	0x30, 0xff, 0xda, 0xf4, 0x63, 0x36, 0x7a, 0x9d, 0xc6, 0xad, 0x44, 0x71, 0xcb, 0xb6, 0x7a, 0x7e,
+	0xa5, 0x9f, 0x87, 0xe6, 0x42, 0x2d, 0x9e, 0xef, 0x75, 0xce, 0x9b, 0x96, 0xe8, 0x8e, 0xc7, 0xd2,
+	0xc3, 0x3c, 0xc2, 0x64, 0x49, 0x4b, 0x6d, 0x8e, 0x04, 0x5b, 0x1a, 0xef, 0x69, 0x58, 0x57, 0x70,
+	0x91, 0xab, 0xfc, 0x57, 0x59, 0x5b, 0x6a, 0x5f, 0x50, 0x8f, 0x10, 0xd5, 0xdf, 0x63, 0xba, 0x26,
+	0x4f, 0x85, 0x3b, 0x31, 0x34, 0x53, 0x71, 0x16, 0x82, 0x3f, 0x22, 0x46, 0x9c, 0x6f, 0xa0, 0x2a,
+	0xce, 0x44, 0x48, 0x25, 0x76, 0x8d, 0xa9, 0x95, 0x92, 0xb7, 0x97, 0x7e, 0x4b, 0xbf, 0x14, 0x52,
+	0x86, 0x43, 0x7f, 0x32, 0xff, 0x03, 0xf2, 0x4a, 0xa4, 0x04, 0xd9, 0x0c, 0x00, 0x00,
 }